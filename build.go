@@ -62,22 +62,46 @@ var candidates = map[string][]string{
 	},
 	"test": []string{
 		"database",
+		"filter",
+		"nfo",
+		"diary",
+		"imdb",
+		"metadata",
 		"objects",
+		"tree",
+		"media",
+		"player",
 	},
 	"vet": []string{
 		"common",
 		"database",
 		"database/query",
+		"filter",
 		"logdomain",
+		"nfo",
+		"diary",
+		"imdb",
+		"metadata",
 		"objects",
+		"tree",
+		"media",
+		"player",
 		"ui",
 	},
 	"lint": []string{
 		"common",
 		"database",
 		"database/query",
+		"filter",
 		"logdomain",
+		"nfo",
+		"diary",
+		"imdb",
+		"metadata",
 		"objects",
+		"tree",
+		"media",
+		"player",
 		"ui",
 	},
 }
@@ -241,7 +265,8 @@ This flag is not case-sensitive.`, strings.Join(orderedSteps, ", ")))
 		var sWorkerCnt = strconv.FormatInt(int64(workerCnt), 10)
 		// var cmd = exec.Command("go", "build", "-v", "-p", sWorkerCnt)
 		// The -tags flag is required so the build will succeed on Debian.
-		var args = []string{"build", "-v", "-tags", "pango_1_42,gtk_3_22", "-p", sWorkerCnt}
+		// sqlite_fts5 is required for the full-text search.
+		var args = []string{"build", "-v", "-tags", "pango_1_42,gtk_3_22,sqlite_fts5", "-p", sWorkerCnt}
 		// var args = []string{"build", "-v", "-p", sWorkerCnt}
 		// if (runtime.GOOS == "linux" || runtime.GOOS == "freebsd") && runtime.GOARCH == "amd64" {
 		// 	args = append(args, "-race")
@@ -372,10 +397,13 @@ func worker(n int, op string, pkgq <-chan string, errq chan<- error, wg *sync.Wa
 			// 	pkg)
 		} else if op == "test" {
 			if runtime.GOOS == "openbsd" || runtime.GOARCH == "386" || runtime.GOARCH == "arm" {
-				cmd = exec.Command("go", op, "-v", "-timeout", "30m", pkg)
+				cmd = exec.Command("go", op, "-v", "-tags", "sqlite_fts5", "-timeout", "30m", pkg)
 			} else {
-				cmd = exec.Command("go", op, "-v", "-timeout", "30m", "-race", pkg)
+				cmd = exec.Command("go", op, "-v", "-tags", "sqlite_fts5", "-timeout", "30m", "-race", pkg)
 			}
+		} else if op == "vet" {
+			// Same as for the tests, the full-text search needs FTS5.
+			cmd = exec.Command("go", op, "-v", "-tags", "sqlite_fts5", pkg)
 		} else {
 			cmd = exec.Command("go", op, "-v", pkg)
 		}
@@ -88,6 +88,9 @@ var LogPath = filepath.Join(BaseDir, fmt.Sprintf("%s.log", strings.ToLower(AppNa
 // DbPath is the filename of the database.
 var DbPath = filepath.Join(BaseDir, fmt.Sprintf("%s.db", strings.ToLower(AppName)))
 
+// SnapshotDir is the folder where snapshots of the database are kept.
+var SnapshotDir = filepath.Join(BaseDir, "snapshots")
+
 // InitApp performs some basic preparations for the application to run.
 // Currently, this means creating the BaseDir folder.
 func InitApp() error {
@@ -99,6 +102,7 @@ func InitApp() error {
 
 	LogPath = filepath.Join(BaseDir, fmt.Sprintf("%s.log", strings.ToLower(AppName)))
 	DbPath = filepath.Join(BaseDir, fmt.Sprintf("%s.db", strings.ToLower(AppName)))
+	SnapshotDir = filepath.Join(BaseDir, "snapshots")
 
 	return nil
 } // func InitApp() error
@@ -118,6 +122,7 @@ func SetBaseDir(path string) error {
 	BaseDir = path
 	LogPath = filepath.Join(BaseDir, fmt.Sprintf("%s.log", strings.ToLower(AppName)))
 	DbPath = filepath.Join(BaseDir, fmt.Sprintf("%s.db", strings.ToLower(AppName)))
+	SnapshotDir = filepath.Join(BaseDir, "snapshots")
 
 	var (
 		err error
@@ -7,6 +7,7 @@
 package database
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/blicero/blockbuster/objects"
@@ -30,3 +31,42 @@ func TestFolderAdd(t *testing.T) {
 			err.Error())
 	}
 } // func TestFolderAdd(t *testing.T)
+
+func TestFolderUpdateRules(t *testing.T) {
+	if tdb == nil || folder == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err      error
+		f        *objects.Folder
+		suffixes = []string{".ts", ".m2ts"}
+		exclude  = []string{"*sample*", "!keep/sample.mkv", "extras/"}
+	)
+
+	if err = tdb.FolderUpdateRules(folder, 1024*1024, suffixes, exclude); err != nil {
+		t.Fatalf("Cannot update scan rules of Folder %s: %s",
+			folder.Path,
+			err.Error())
+	} else if f, err = tdb.FolderGetByPath(folder.Path); err != nil {
+		t.Fatalf("Cannot look up Folder %s: %s",
+			folder.Path,
+			err.Error())
+	} else if f.MinSize != 1024*1024 {
+		t.Errorf("Unexpected minimum size: %d", f.MinSize)
+	} else if !reflect.DeepEqual(f.Suffixes, suffixes) {
+		t.Errorf("Unexpected suffixes: %v (expected %v)", f.Suffixes, suffixes)
+	} else if !reflect.DeepEqual(f.Exclude, exclude) {
+		t.Errorf("Unexpected exclude patterns: %v (expected %v)", f.Exclude, exclude)
+	} else if err = tdb.FolderUpdateRules(folder, 0, nil, nil); err != nil {
+		t.Fatalf("Cannot reset scan rules of Folder %s: %s",
+			folder.Path,
+			err.Error())
+	} else if f, err = tdb.FolderGetByPath(folder.Path); err != nil {
+		t.Fatalf("Cannot look up Folder %s: %s",
+			folder.Path,
+			err.Error())
+	} else if f.MinSize != 0 || len(f.Suffixes) != 0 || len(f.Exclude) != 0 {
+		t.Errorf("Scan rules were not reset: %#v", f)
+	}
+} // func TestFolderUpdateRules(t *testing.T)
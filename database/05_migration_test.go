@@ -0,0 +1,211 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/database/05_migration_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 27. 08. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-08-27 19:20:37 krylon>
+
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/blicero/blockbuster/common"
+	"github.com/blicero/blockbuster/objects"
+)
+
+// oldQueries populate a database created with the schema of version 0.0.5
+// with some data that we expect to survive the migration.
+var oldQueries = []string{
+	"INSERT INTO folder (id, path, last_scan) VALUES (1, '/data/Video', 1629900000)",
+	"INSERT INTO file (id, folder_id, path, title, year) VALUES (1, 1, '/data/Video/alien.mkv', 'Alien', 1979)",
+	"INSERT INTO file (id, folder_id, path, title, year) VALUES (2, 1, '/data/Video/aliens.mkv', 'Aliens', 1986)",
+	"INSERT INTO file (id, folder_id, path) VALUES (3, 1, '/data/Video/unknown.avi')",
+	"INSERT INTO file_url (file_id, url, description) VALUES (1, 'https://www.imdb.com/title/tt0078748/', 'IMDB')",
+	"INSERT INTO tag (id, name) VALUES (1, 'Sci-Fi')",
+	"INSERT INTO tag (id, name) VALUES (2, 'Horror')",
+	"INSERT INTO tag_link (file_id, tag_id) VALUES (1, 1), (1, 2), (2, 1)",
+	"INSERT INTO person (id, name, birthday) VALUES (1, 'Sigourney Weaver', -639360000)",
+	"INSERT INTO person (id, name, birthday) VALUES (2, 'Ridley Scott', -1010966400)",
+	"INSERT INTO person (id, name, birthday) VALUES (3, 'James Cameron', -486864000)",
+	"INSERT INTO person_url (person_id, url, title) VALUES (1, 'https://en.wikipedia.org/wiki/Sigourney_Weaver', 'Wikipedia')",
+	"INSERT INTO actor (file_id, person_id) VALUES (1, 1), (2, 1)",
+	"INSERT INTO director (file_id, person_id) VALUES (1, 2), (2, 3)",
+}
+
+var oldDbPath string
+
+func TestMigrateCreateOld(t *testing.T) {
+	var (
+		err error
+		db  *sql.DB
+	)
+
+	oldDbPath = filepath.Join(common.BaseDir, "old.db")
+
+	if db, err = sql.Open("sqlite3", oldDbPath+"?_fk=1"); err != nil {
+		oldDbPath = ""
+		t.Fatalf("Cannot open database %s: %s",
+			oldDbPath,
+			err.Error())
+	}
+
+	defer db.Close() // nolint: errcheck
+
+	for _, qlist := range [][]string{initQueries, oldQueries} {
+		for _, q := range qlist {
+			if _, err = db.Exec(q); err != nil {
+				oldDbPath = ""
+				t.Fatalf("Cannot execute query: %s\n%s",
+					err.Error(),
+					q)
+			}
+		}
+	}
+} // func TestMigrateCreateOld(t *testing.T)
+
+func TestMigrateUpgrade(t *testing.T) {
+	if oldDbPath == "" {
+		t.SkipNow()
+	}
+
+	var (
+		err     error
+		db      *Database
+		version int
+		backups []string
+		files   []objects.File
+		people  []objects.Person
+		tags    []objects.Tag
+		links   []objects.Link
+	)
+
+	if db, err = Open(oldDbPath); err != nil {
+		t.Fatalf("Cannot open old database %s: %s",
+			oldDbPath,
+			err.Error())
+	}
+
+	defer db.Close() // nolint: errcheck
+
+	if version, err = db.SchemaVersion(); err != nil {
+		t.Fatalf("Cannot query schema version: %s", err.Error())
+	} else if version != schemaVersion() {
+		t.Fatalf("Unexpected schema version after migration: %d (expected %d)",
+			version,
+			schemaVersion())
+	} else if backups, err = filepath.Glob(oldDbPath + ".v0.*.bak"); err != nil {
+		t.Fatalf("Cannot look for backup of old database: %s", err.Error())
+	} else if len(backups) != 1 {
+		t.Fatalf("Expected 1 backup of the old database, found %d", len(backups))
+	}
+
+	if files, err = db.FileGetAll(); err != nil {
+		t.Fatalf("Cannot load Files: %s", err.Error())
+	} else if len(files) != 3 {
+		t.Fatalf("Unexpected number of Files after migration: %d (expected 3)",
+			len(files))
+	} else if people, err = db.PersonGetAll(); err != nil {
+		t.Fatalf("Cannot load People: %s", err.Error())
+	} else if len(people) != 3 {
+		t.Fatalf("Unexpected number of People after migration: %d (expected 3)",
+			len(people))
+	} else if tags, err = db.TagGetAll(); err != nil {
+		t.Fatalf("Cannot load Tags: %s", err.Error())
+	} else if len(tags) != 2 {
+		t.Fatalf("Unexpected number of Tags after migration: %d (expected 2)",
+			len(tags))
+	} else if links, err = db.PersonURLGetByPerson(&people[2]); err != nil {
+		t.Fatalf("Cannot load Links for %s: %s",
+			people[2].Name,
+			err.Error())
+	} else if len(links) != 1 {
+		t.Fatalf("Unexpected number of Links for %s: %d (expected 1)",
+			people[2].Name,
+			len(links))
+	}
+
+	for _, f := range files {
+		var (
+			actors, directors []objects.Person
+			tmap              map[int64]objects.Tag
+			expect            = [][3]int{
+				{1, 1, 2},
+				{1, 1, 1},
+				{0, 0, 0},
+			}[f.ID-1]
+		)
+
+		if actors, err = db.ActorGetByFile(&f); err != nil {
+			t.Fatalf("Cannot load Actors for %s: %s",
+				f.DisplayTitle(),
+				err.Error())
+		} else if directors, err = db.DirectorGetByFile(&f); err != nil {
+			t.Fatalf("Cannot load Directors for %s: %s",
+				f.DisplayTitle(),
+				err.Error())
+		} else if tmap, err = db.TagLinkGetByFile(&f); err != nil {
+			t.Fatalf("Cannot load Tags for %s: %s",
+				f.DisplayTitle(),
+				err.Error())
+		} else if len(actors) != expect[0] || len(directors) != expect[1] || len(tmap) != expect[2] {
+			t.Errorf("Unexpected number of Actors/Directors/Tags for %s: %d/%d/%d (expected %d/%d/%d)",
+				f.DisplayTitle(),
+				len(actors),
+				len(directors),
+				len(tmap),
+				expect[0],
+				expect[1],
+				expect[2])
+		}
+	}
+} // func TestMigrateUpgrade(t *testing.T)
+
+func TestMigrateTooNew(t *testing.T) {
+	if oldDbPath == "" {
+		t.SkipNow()
+	}
+
+	var (
+		err error
+		raw *sql.DB
+		db  *Database
+	)
+
+	if raw, err = sql.Open("sqlite3", oldDbPath); err != nil {
+		t.Fatalf("Cannot open database %s: %s",
+			oldDbPath,
+			err.Error())
+	} else if _, err = raw.Exec(
+		"INSERT INTO schema_version (version, timestamp, description) VALUES (?, ?, ?)",
+		schemaVersion()+1,
+		0,
+		"From the future"); err != nil {
+		raw.Close() // nolint: errcheck
+		t.Fatalf("Cannot bump schema version: %s", err.Error())
+	} else if err = raw.Close(); err != nil {
+		t.Fatalf("Cannot close database: %s", err.Error())
+	}
+
+	if db, err = Open(oldDbPath); err == nil {
+		db.Close() // nolint: errcheck
+		t.Fatalf("Opening a database with a schema from the future should have failed")
+	} else if !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("Unexpected error opening a database with a schema from the future: %s",
+			err.Error())
+	}
+} // func TestMigrateTooNew(t *testing.T)
+
+func TestMigrationOrder(t *testing.T) {
+	for i, m := range migrations {
+		if m.version != i+1 {
+			t.Errorf("Migration #%d (%s) has version %d, expected %d",
+				i,
+				m.description,
+				m.version,
+				i+1)
+		}
+	}
+} // func TestMigrationOrder(t *testing.T)
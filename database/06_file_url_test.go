@@ -0,0 +1,73 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/database/06_file_url_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 28. 08. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-08-28 15:02:44 krylon>
+
+package database
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/blicero/blockbuster/objects"
+	"github.com/blicero/krylib"
+)
+
+func TestFileURL(t *testing.T) {
+	if tdb == nil || folder == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err   error
+		f     *objects.File
+		links []objects.Link
+		path  = filepath.Join(basePath, "linked_video.mkv")
+		l     = objects.Link{
+			URL:         krylib.ParseURL("https://www.imdb.com/title/tt0078748/"),
+			Title:       "IMDB",
+			Description: "Alien (1979)",
+		}
+	)
+
+	if f, err = tdb.FileAdd(path, folder); err != nil {
+		t.Fatalf("Cannot add File %s: %s",
+			path,
+			err.Error())
+	} else if err = tdb.FileURLAdd(f, &l); err != nil {
+		t.Fatalf("Cannot add Link %s to File %s: %s",
+			l.URL,
+			path,
+			err.Error())
+	} else if l.ID == 0 {
+		t.Fatalf("FileURLAdd did not set the ID of the Link")
+	} else if links, err = tdb.FileURLGetByFile(f); err != nil {
+		t.Fatalf("Cannot get Links for File %s: %s",
+			path,
+			err.Error())
+	} else if len(links) != 1 {
+		t.Fatalf("Unexpected number of Links for File %s: %d (expected 1)",
+			path,
+			len(links))
+	} else if links[0].ID != l.ID ||
+		links[0].URL.String() != l.URL.String() ||
+		links[0].Title != l.Title ||
+		links[0].Description != l.Description {
+		t.Fatalf("Link returned from the database does not match the one we added:\n%#v\n%#v",
+			links[0],
+			l)
+	} else if err = tdb.FileURLDelete(&l); err != nil {
+		t.Fatalf("Cannot delete Link %s: %s",
+			l.URL,
+			err.Error())
+	} else if links, err = tdb.FileURLGetByFile(f); err != nil {
+		t.Fatalf("Cannot get Links for File %s: %s",
+			path,
+			err.Error())
+	} else if len(links) != 0 {
+		t.Fatalf("File %s should not have any Links left, but it has %d",
+			path,
+			len(links))
+	}
+} // func TestFileURL(t *testing.T)
@@ -0,0 +1,213 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/database/07_series_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 29. 08. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-08-29 18:40:12 krylon>
+
+package database
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/blicero/blockbuster/objects"
+)
+
+func TestSeries(t *testing.T) {
+	if tdb == nil || folder == nil {
+		t.SkipNow()
+	}
+
+	const (
+		title      = "Firefly"
+		seasonCnt  = 2
+		episodeCnt = 3
+	)
+
+	var (
+		err      error
+		series   *objects.Series
+		list     []objects.Series
+		season   *objects.Season
+		seasons  []objects.Season
+		episodes []objects.Episode
+	)
+
+	if series, err = tdb.SeriesAdd(title, 2002); err != nil {
+		t.Fatalf("Cannot add Series %s: %s",
+			title,
+			err.Error())
+	} else if series.ID == 0 {
+		t.Fatalf("SeriesAdd returned a Series without an ID")
+	}
+
+	// We add the Seasons and Episodes in reverse order to check they come
+	// back sorted by their number.
+	for sn := int64(seasonCnt); sn > 0; sn-- {
+		var s *objects.Season
+
+		if s, err = tdb.SeasonAdd(series, sn, "", 2001+sn); err != nil {
+			t.Fatalf("Cannot add Season %d to %s: %s",
+				sn,
+				title,
+				err.Error())
+		}
+
+		for en := int64(episodeCnt); en > 0; en-- {
+			var etitle = fmt.Sprintf("Episode %d.%d", sn, en)
+			if _, err = tdb.EpisodeAdd(s, en, etitle); err != nil {
+				t.Fatalf("Cannot add Episode %q: %s",
+					etitle,
+					err.Error())
+			}
+		}
+	}
+
+	if _, err = tdb.SeasonAdd(series, 1, "Duplicate", 0); err == nil {
+		t.Errorf("Adding a Season with the same number twice should have failed")
+	}
+
+	if list, err = tdb.SeriesGetAll(); err != nil {
+		t.Fatalf("Cannot get all Series: %s", err.Error())
+	} else if len(list) != 1 {
+		t.Fatalf("Unexpected number of Series: %d (expected 1)", len(list))
+	} else if list[0] != *series {
+		t.Fatalf("Series returned from the database does not match the one we added:\n%#v\n%#v",
+			list[0],
+			series)
+	} else if seasons, err = tdb.SeasonGetBySeries(series); err != nil {
+		t.Fatalf("Cannot get Seasons of %s: %s",
+			title,
+			err.Error())
+	} else if len(seasons) != seasonCnt {
+		t.Fatalf("Unexpected number of Seasons: %d (expected %d)",
+			len(seasons),
+			seasonCnt)
+	}
+
+	for idx, s := range seasons {
+		if s.Number != int64(idx+1) {
+			t.Fatalf("Seasons are not ordered by number: #%d is %s",
+				idx,
+				s.DisplayTitle())
+		} else if s.Year != 2001+s.Number {
+			t.Errorf("Unexpected Year of %s: %d (expected %d)",
+				s.DisplayTitle(),
+				s.Year,
+				2001+s.Number)
+		} else if episodes, err = tdb.EpisodeGetBySeason(&s); err != nil {
+			t.Fatalf("Cannot get Episodes of %s: %s",
+				s.DisplayTitle(),
+				err.Error())
+		} else if len(episodes) != episodeCnt {
+			t.Fatalf("Unexpected number of Episodes in %s: %d (expected %d)",
+				s.DisplayTitle(),
+				len(episodes),
+				episodeCnt)
+		}
+
+		for eidx, e := range episodes {
+			if e.Number != int64(eidx+1) {
+				t.Fatalf("Episodes are not ordered by number: #%d is %s",
+					eidx,
+					e.DisplayTitle())
+			}
+		}
+	}
+
+	if err = tdb.SeasonSetYear(&seasons[0], 1999); err != nil {
+		t.Fatalf("Cannot set Year of %s: %s",
+			seasons[0].DisplayTitle(),
+			err.Error())
+	} else if season, err = tdb.SeasonGetByID(seasons[0].ID); err != nil {
+		t.Fatalf("Cannot look up Season %d: %s",
+			seasons[0].ID,
+			err.Error())
+	} else if season == nil {
+		t.Fatalf("Season %d was not found", seasons[0].ID)
+	} else if season.Year != 1999 {
+		t.Errorf("Unexpected Year of %s: %d (expected 1999)",
+			season.DisplayTitle(),
+			season.Year)
+	}
+} // func TestSeries(t *testing.T)
+
+func TestFileEpisode(t *testing.T) {
+	if tdb == nil || folder == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err      error
+		f, f2    *objects.File
+		list     []objects.Series
+		seasons  []objects.Season
+		episodes []objects.Episode
+		files    []objects.File
+		ep       *objects.Episode
+		path     = filepath.Join(basePath, "firefly_s01e01.mkv")
+	)
+
+	if list, err = tdb.SeriesGetAll(); err != nil {
+		t.Fatalf("Cannot get all Series: %s", err.Error())
+	} else if len(list) == 0 {
+		t.SkipNow()
+	} else if seasons, err = tdb.SeasonGetBySeries(&list[0]); err != nil {
+		t.Fatalf("Cannot get Seasons of %s: %s",
+			list[0].Title,
+			err.Error())
+	} else if episodes, err = tdb.EpisodeGetBySeason(&seasons[0]); err != nil {
+		t.Fatalf("Cannot get Episodes of %s: %s",
+			seasons[0].DisplayTitle(),
+			err.Error())
+	}
+
+	ep = &episodes[0]
+
+	if f, err = tdb.FileAdd(path, folder); err != nil {
+		t.Fatalf("Cannot add File %s: %s",
+			path,
+			err.Error())
+	} else if err = tdb.FileSetEpisode(f, ep); err != nil {
+		t.Fatalf("Cannot link File %s to Episode %s: %s",
+			path,
+			ep.DisplayTitle(),
+			err.Error())
+	} else if f.EpisodeID != ep.ID {
+		t.Fatalf("FileSetEpisode did not set the EpisodeID of the File")
+	} else if f2, err = tdb.FileGetByID(f.ID); err != nil {
+		t.Fatalf("Cannot load File #%d: %s",
+			f.ID,
+			err.Error())
+	} else if f2.EpisodeID != ep.ID {
+		t.Fatalf("Unexpected EpisodeID of File %s: %d (expected %d)",
+			path,
+			f2.EpisodeID,
+			ep.ID)
+	} else if files, err = tdb.FileGetByEpisode(ep); err != nil {
+		t.Fatalf("Cannot get Files for Episode %s: %s",
+			ep.DisplayTitle(),
+			err.Error())
+	} else if len(files) != 1 || files[0].ID != f.ID {
+		t.Fatalf("Unexpected Files for Episode %s: %#v",
+			ep.DisplayTitle(),
+			files)
+	} else if err = tdb.EpisodeDelete(ep); err != nil {
+		t.Fatalf("Cannot delete Episode %s: %s",
+			ep.DisplayTitle(),
+			err.Error())
+	} else if f2, err = tdb.FileGetByID(f.ID); err != nil {
+		t.Fatalf("Cannot load File #%d: %s",
+			f.ID,
+			err.Error())
+	} else if f2 == nil {
+		t.Fatalf("Deleting Episode %s removed File %s",
+			ep.DisplayTitle(),
+			path)
+	} else if f2.EpisodeID != 0 {
+		t.Fatalf("File %s is still linked to deleted Episode #%d",
+			path,
+			f2.EpisodeID)
+	}
+} // func TestFileEpisode(t *testing.T)
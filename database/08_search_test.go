@@ -0,0 +1,146 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/database/08_search_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 30. 08. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-08-30 21:03:55 krylon>
+
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blicero/blockbuster/objects"
+	"github.com/blicero/krylib"
+)
+
+func TestFTSQuery(t *testing.T) {
+	type testCase struct {
+		input    string
+		expected string
+	}
+
+	var cases = []testCase{
+		{input: "", expected: ""},
+		{input: "   ", expected: ""},
+		{input: "alien", expected: `"alien"*`},
+		{input: "blade  runner", expected: `"blade"* "runner"*`},
+		{input: `-x "quoted`, expected: `"-x"* """quoted"*`},
+		{input: "AND OR NOT", expected: `"AND"* "OR"* "NOT"*`},
+	}
+
+	for _, c := range cases {
+		if s := ftsQuery(c.input); s != c.expected {
+			t.Errorf("Unexpected FTS query for %q: %s (expected %s)",
+				c.input,
+				s,
+				c.expected)
+		}
+	}
+} // func TestFTSQuery(t *testing.T)
+
+// countHits returns how many SearchHits of the given kind refer to the
+// object with the given ID.
+func countHits(hits []objects.SearchHit, kind objects.SearchKind, id int64) int {
+	var cnt int
+
+	for _, h := range hits {
+		if h.Kind == kind && h.ID == id {
+			cnt++
+		}
+	}
+
+	return cnt
+} // func countHits(hits []objects.SearchHit, kind objects.SearchKind, id int64) int
+
+func TestSearch(t *testing.T) {
+	if tdb == nil || folder == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err  error
+		f    *objects.File
+		p    *objects.Person
+		tag  *objects.Tag
+		hits []objects.SearchHit
+		path = filepath.Join(basePath, "zardoz_1974.mkv")
+		l    = objects.Link{
+			URL:         krylib.ParseURL("https://en.wikipedia.org/wiki/Zardoz"),
+			Title:       "Wikipedia",
+			Description: "Flying stone heads",
+		}
+	)
+
+	if f, err = tdb.FileAdd(path, folder); err != nil {
+		t.Fatalf("Cannot add File %s: %s",
+			path,
+			err.Error())
+	} else if p, err = tdb.PersonAdd("Sean Connery", time.Date(1930, 8, 25, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Cannot add Person: %s", err.Error())
+	} else if tag, err = tdb.TagAdd("Zardozian"); err != nil {
+		t.Fatalf("Cannot add Tag: %s", err.Error())
+	} else if err = tdb.FileURLAdd(f, &l); err != nil {
+		t.Fatalf("Cannot add Link to %s: %s",
+			path,
+			err.Error())
+	}
+
+	// The path, the Tag and the Link all contain the word "zardoz",
+	// either in full or as a prefix.
+	if hits, err = tdb.Search("zardoz", 0); err != nil {
+		t.Fatalf("Search failed: %s", err.Error())
+	} else if countHits(hits, objects.SearchFile, f.ID) != 1 {
+		t.Errorf("Search did not find File %s: %#v", path, hits)
+	} else if countHits(hits, objects.SearchTag, tag.ID) != 1 {
+		t.Errorf("Search did not find Tag %s: %#v", tag.Name, hits)
+	} else if countHits(hits, objects.SearchFileURL, l.ID) != 1 {
+		t.Errorf("Search did not find Link %s: %#v", l.URL, hits)
+	}
+
+	for _, h := range hits {
+		if h.Kind == objects.SearchFileURL && h.OwnerID != f.ID {
+			t.Errorf("Link hit should be owned by File #%d, not #%d",
+				f.ID,
+				h.OwnerID)
+		} else if h.Title == "" {
+			t.Errorf("Search hit without a title: %#v", h)
+		}
+	}
+
+	if hits, err = tdb.Search("zard", 1); err != nil {
+		t.Fatalf("Search failed: %s", err.Error())
+	} else if len(hits) != 1 {
+		t.Errorf("Search returned %d hits despite a limit of 1", len(hits))
+	}
+
+	// Changing the title should be reflected in the index.
+	if err = tdb.FileUpdateTitle(f, "Zardoz (Director's Cut)"); err != nil {
+		t.Fatalf("Cannot set title of %s: %s",
+			path,
+			err.Error())
+	} else if hits, err = tdb.Search("director's", 0); err != nil {
+		t.Fatalf("Search failed: %s", err.Error())
+	} else if countHits(hits, objects.SearchFile, f.ID) != 1 {
+		t.Errorf("Search did not find File %s by its new title: %#v",
+			path,
+			hits)
+	}
+
+	if hits, err = tdb.Search("connery", 0); err != nil {
+		t.Fatalf("Search failed: %s", err.Error())
+	} else if countHits(hits, objects.SearchPerson, p.ID) != 1 {
+		t.Errorf("Search did not find Person %s: %#v", p.Name, hits)
+	} else if err = tdb.TagDelete(tag); err != nil {
+		t.Fatalf("Cannot delete Tag %s: %s",
+			tag.Name,
+			err.Error())
+	} else if hits, err = tdb.Search("zardozian", 0); err != nil {
+		t.Fatalf("Search failed: %s", err.Error())
+	} else if countHits(hits, objects.SearchTag, tag.ID) != 0 {
+		t.Errorf("Deleted Tag %s is still in the search index: %#v",
+			tag.Name,
+			hits)
+	}
+} // func TestSearch(t *testing.T)
@@ -0,0 +1,74 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/database/09_fingerprint_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 31. 08. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-08-31 20:12:08 krylon>
+
+package database
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/blicero/blockbuster/objects"
+)
+
+func TestFileFingerprint(t *testing.T) {
+	if tdb == nil || folder == nil {
+		t.SkipNow()
+	}
+
+	const fp = "00c0ffee00c0ffee"
+
+	var (
+		err     error
+		f, f2   *objects.File
+		files   []objects.File
+		path    = filepath.Join(basePath, "old_name.mkv")
+		newPath = filepath.Join(basePath, "subfolder", "new_name.mkv")
+	)
+
+	if f, err = tdb.FileAdd(path, folder); err != nil {
+		t.Fatalf("Cannot add File %s: %s",
+			path,
+			err.Error())
+	} else if err = tdb.FileSetFingerprint(f, fp); err != nil {
+		t.Fatalf("Cannot set fingerprint of File %s: %s",
+			path,
+			err.Error())
+	} else if files, err = tdb.FileGetByFingerprint(fp); err != nil {
+		t.Fatalf("Cannot look up Files by fingerprint %s: %s",
+			fp,
+			err.Error())
+	} else if len(files) != 1 {
+		t.Fatalf("Unexpected number of Files with fingerprint %s: %d (expected 1)",
+			fp,
+			len(files))
+	} else if files[0].ID != f.ID || files[0].Path != path {
+		t.Fatalf("FileGetByFingerprint returned the wrong File: %#v",
+			files[0])
+	} else if err = tdb.FileMove(f, newPath, folder); err != nil {
+		t.Fatalf("Cannot move File %s to %s: %s",
+			path,
+			newPath,
+			err.Error())
+	} else if f.Path != newPath {
+		t.Fatalf("FileMove did not update the Path of the File: %s", f.Path)
+	} else if f2, err = tdb.FileGetByPath(path); err != nil {
+		t.Fatalf("Cannot look up File %s: %s",
+			path,
+			err.Error())
+	} else if f2 != nil {
+		t.Fatalf("File is still there under its old path %s", path)
+	} else if f2, err = tdb.FileGetByPath(newPath); err != nil {
+		t.Fatalf("Cannot look up File %s: %s",
+			newPath,
+			err.Error())
+	} else if f2 == nil {
+		t.Fatalf("File was not found under its new path %s", newPath)
+	} else if f2.ID != f.ID || f2.Fingerprint != fp {
+		t.Fatalf("File under new path does not match the one we moved:\n%#v\n%#v",
+			f2,
+			f)
+	}
+} // func TestFileFingerprint(t *testing.T)
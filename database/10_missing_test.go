@@ -0,0 +1,112 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/database/10_missing_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 01. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-01 19:42:10 krylon>
+
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blicero/blockbuster/objects"
+)
+
+func TestFileMissing(t *testing.T) {
+	if tdb == nil || folder == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err   error
+		f, f2 *objects.File
+		tag   *objects.Tag
+		files []objects.File
+		path  = filepath.Join(basePath, "gone_girl_2014.mkv")
+		stamp = time.Now().Truncate(time.Second)
+	)
+
+	if f, err = tdb.FileAdd(path, folder); err != nil {
+		t.Fatalf("Cannot add File %s: %s",
+			path,
+			err.Error())
+	} else if f.IsMissing() {
+		t.Fatalf("New File %s is already missing", path)
+	} else if err = tdb.FileSetMissing(f, stamp); err != nil {
+		t.Fatalf("Cannot mark File %s as missing: %s",
+			path,
+			err.Error())
+	} else if f2, err = tdb.FileGetByID(f.ID); err != nil {
+		t.Fatalf("Cannot look up File %d: %s",
+			f.ID,
+			err.Error())
+	} else if !f2.MissingSince.Equal(stamp) {
+		t.Fatalf("Unexpected missing timestamp for File %s: %s (expected %s)",
+			path,
+			f2.MissingSince,
+			stamp)
+	} else if files, err = tdb.FileGetMissing(); err != nil {
+		t.Fatalf("Cannot get missing Files: %s", err.Error())
+	} else if len(files) != 1 || files[0].ID != f.ID {
+		t.Fatalf("Unexpected list of missing Files: %#v", files)
+	} else if files, err = tdb.FileGetByFolder(folder); err != nil {
+		t.Fatalf("Cannot get Files in Folder %s: %s",
+			folder.Path,
+			err.Error())
+	}
+
+	var found bool
+
+	for _, file := range files {
+		if file.ID == f.ID {
+			found = true
+			if !file.IsMissing() {
+				t.Errorf("FileGetByFolder returned File %s as not missing",
+					path)
+			}
+		}
+	}
+
+	if !found {
+		t.Errorf("FileGetByFolder did not return File %s", path)
+	}
+
+	if err = tdb.FileSetMissing(f, time.Time{}); err != nil {
+		t.Fatalf("Cannot unmark File %s as missing: %s",
+			path,
+			err.Error())
+	} else if files, err = tdb.FileGetMissing(); err != nil {
+		t.Fatalf("Cannot get missing Files: %s", err.Error())
+	} else if len(files) != 0 {
+		t.Fatalf("File %s is still missing: %#v", path, files)
+	}
+
+	// A File that has Tags attached to it cannot simply be removed, but
+	// it can be purged.
+	if tag, err = tdb.TagAdd("Vanished"); err != nil {
+		t.Fatalf("Cannot add Tag: %s", err.Error())
+	} else if err = tdb.TagLinkAdd(f, tag); err != nil {
+		t.Fatalf("Cannot attach Tag %s to File %s: %s",
+			tag.Name,
+			path,
+			err.Error())
+	} else if err = tdb.FilePurge(f); err != nil {
+		t.Fatalf("Cannot purge File %s: %s",
+			path,
+			err.Error())
+	} else if f2, err = tdb.FileGetByID(f.ID); err != nil {
+		t.Fatalf("Cannot look up File %d: %s",
+			f.ID,
+			err.Error())
+	} else if f2 != nil {
+		t.Fatalf("File %s is still in the database after purge", path)
+	} else if files, err = tdb.TagLinkGetByTag(tag); err != nil {
+		t.Fatalf("Cannot get Files for Tag %s: %s",
+			tag.Name,
+			err.Error())
+	} else if len(files) != 0 {
+		t.Fatalf("Tag %s is still linked to %d Files", tag.Name, len(files))
+	}
+} // func TestFileMissing(t *testing.T)
@@ -0,0 +1,123 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/database/11_media_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 03. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-03 20:01:17 krylon>
+
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blicero/blockbuster/objects"
+)
+
+func TestMediaInfo(t *testing.T) {
+	if tdb == nil || folder == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err      error
+		f        *objects.File
+		info     *objects.MediaInfo
+		files    []objects.File
+		path     = filepath.Join(basePath, "metropolis_1927.mkv")
+		expected = &objects.MediaInfo{
+			Container:  "matroska",
+			Duration:   time.Minute*153 + time.Millisecond*250,
+			Width:      1440,
+			Height:     1080,
+			FrameRate:  24,
+			VideoCodec: "h264",
+			Tracks: []objects.Track{
+				{Kind: objects.TrackAudio, Codec: "flac", Language: "ger", Channels: 2},
+				{Kind: objects.TrackSubtitle, Codec: "subrip", Language: "eng"},
+			},
+			Probed: time.Now().Truncate(time.Second),
+		}
+	)
+
+	if f, err = tdb.FileAdd(path, folder); err != nil {
+		t.Fatalf("Cannot add File %s: %s",
+			path,
+			err.Error())
+	} else if info, err = tdb.MediaInfoGetByFile(f); err != nil {
+		t.Fatalf("Cannot get MediaInfo for File %s: %s",
+			path,
+			err.Error())
+	} else if info != nil {
+		t.Fatalf("New File %s already has MediaInfo: %#v", path, info)
+	} else if files, err = tdb.FileGetUnprobed(); err != nil {
+		t.Fatalf("Cannot get unprobed Files: %s", err.Error())
+	} else if !containsFile(files, f.ID) {
+		t.Fatalf("New File %s is not among the unprobed Files", path)
+	} else if err = tdb.MediaInfoSet(f, expected); err != nil {
+		t.Fatalf("Cannot set MediaInfo for File %s: %s",
+			path,
+			err.Error())
+	} else if info, err = tdb.MediaInfoGetByFile(f); err != nil {
+		t.Fatalf("Cannot get MediaInfo for File %s: %s",
+			path,
+			err.Error())
+	} else if info == nil {
+		t.Fatalf("MediaInfo for File %s was not found", path)
+	} else if info.Container != expected.Container ||
+		info.Duration != expected.Duration ||
+		info.Resolution() != expected.Resolution() ||
+		info.FrameRate != expected.FrameRate ||
+		info.VideoCodec != expected.VideoCodec ||
+		!info.Probed.Equal(expected.Probed) {
+		t.Errorf("MediaInfo does not match:\n%#v\n(expected)\n%#v",
+			info,
+			expected)
+	} else if len(info.Tracks) != len(expected.Tracks) {
+		t.Fatalf("Unexpected number of Tracks: %d (expected %d)",
+			len(info.Tracks),
+			len(expected.Tracks))
+	}
+
+	for i, track := range info.Tracks {
+		if track != expected.Tracks[i] {
+			t.Errorf("Track #%d does not match:\n%#v\n(expected)\n%#v",
+				i,
+				track,
+				expected.Tracks[i])
+		}
+	}
+
+	// Storing it again replaces the old MediaInfo.
+	expected.Tracks = expected.Tracks[:1]
+	if err = tdb.MediaInfoSet(f, expected); err != nil {
+		t.Fatalf("Cannot set MediaInfo for File %s: %s",
+			path,
+			err.Error())
+	} else if info, err = tdb.MediaInfoGetByFile(f); err != nil {
+		t.Fatalf("Cannot get MediaInfo for File %s: %s",
+			path,
+			err.Error())
+	} else if len(info.Tracks) != 1 {
+		t.Errorf("Unexpected number of Tracks: %d (expected 1)",
+			len(info.Tracks))
+	} else if files, err = tdb.FileGetUnprobed(); err != nil {
+		t.Fatalf("Cannot get unprobed Files: %s", err.Error())
+	} else if containsFile(files, f.ID) {
+		t.Errorf("File %s is still among the unprobed Files", path)
+	} else if err = tdb.FilePurge(f); err != nil {
+		t.Fatalf("Cannot purge File %s: %s",
+			path,
+			err.Error())
+	}
+} // func TestMediaInfo(t *testing.T)
+
+func containsFile(files []objects.File, id int64) bool {
+	for _, f := range files {
+		if f.ID == id {
+			return true
+		}
+	}
+
+	return false
+} // func containsFile(files []objects.File, id int64) bool
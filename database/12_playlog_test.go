@@ -0,0 +1,107 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/database/12_playlog_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 04. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-04 18:31:52 krylon>
+
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blicero/blockbuster/objects"
+)
+
+func TestPlayLog(t *testing.T) {
+	if tdb == nil || folder == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err   error
+		f, f2 *objects.File
+		l     *objects.PlayLog
+		log   []objects.PlayLog
+		files []objects.File
+		path  = filepath.Join(basePath, "stalker_1979.mkv")
+		start = time.Now().Add(-time.Hour).Truncate(time.Second)
+		end   = start.Add(time.Minute * 42)
+		pos   = time.Minute*41 + time.Second*17
+	)
+
+	if f, err = tdb.FileAdd(path, folder); err != nil {
+		t.Fatalf("Cannot add File %s: %s",
+			path,
+			err.Error())
+	} else if l, err = tdb.PlayLogAdd(f, start); err != nil {
+		t.Fatalf("Cannot add PlayLog for File %s: %s",
+			path,
+			err.Error())
+	} else if l.IsFinished() {
+		t.Errorf("New PlayLog is already finished: %#v", l)
+	} else if err = tdb.PlayLogFinish(l, end, 0, pos); err != nil {
+		t.Fatalf("Cannot finish PlayLog %d: %s",
+			l.ID,
+			err.Error())
+	} else if err = tdb.FileSetResumePosition(f, pos); err != nil {
+		t.Fatalf("Cannot set resume position of File %s: %s",
+			path,
+			err.Error())
+	} else if log, err = tdb.PlayLogGetByFile(f); err != nil {
+		t.Fatalf("Cannot get PlayLog for File %s: %s",
+			path,
+			err.Error())
+	} else if len(log) != 1 {
+		t.Fatalf("Unexpected number of PlayLog entries: %d (expected 1)",
+			len(log))
+	} else if log[0] != *l {
+		t.Errorf("PlayLog does not match:\n%#v\n(expected)\n%#v",
+			log[0],
+			*l)
+	} else if log[0].Duration() != time.Minute*42 {
+		t.Errorf("Unexpected duration of PlayLog: %s", log[0].Duration())
+	} else if files, err = tdb.FileGetResumable(); err != nil {
+		t.Fatalf("Cannot get resumable Files: %s", err.Error())
+	} else if !containsFile(files, f.ID) {
+		t.Errorf("File %s is not among the resumable Files", path)
+	} else if f2, err = tdb.FileGetByID(f.ID); err != nil {
+		t.Fatalf("Cannot look up File %d: %s",
+			f.ID,
+			err.Error())
+	} else if f2.ResumePosition != pos || f2.Watched {
+		t.Errorf("Unexpected resume position / watched flag: %s / %t",
+			f2.ResumePosition,
+			f2.Watched)
+	}
+
+	// Once the File has been watched, there is nothing to resume.
+	if err = tdb.FileSetWatched(f, true); err != nil {
+		t.Fatalf("Cannot mark File %s as watched: %s",
+			path,
+			err.Error())
+	} else if f2, err = tdb.FileGetByID(f.ID); err != nil {
+		t.Fatalf("Cannot look up File %d: %s",
+			f.ID,
+			err.Error())
+	} else if !f2.Watched || f2.ResumePosition != 0 {
+		t.Errorf("Unexpected resume position / watched flag: %s / %t",
+			f2.ResumePosition,
+			f2.Watched)
+	} else if files, err = tdb.FileGetResumable(); err != nil {
+		t.Fatalf("Cannot get resumable Files: %s", err.Error())
+	} else if containsFile(files, f.ID) {
+		t.Errorf("Watched File %s is still among the resumable Files", path)
+	} else if err = tdb.FileSetWatched(f, false); err != nil {
+		t.Fatalf("Cannot mark File %s as unwatched: %s",
+			path,
+			err.Error())
+	} else if f2, err = tdb.FileGetByID(f.ID); err != nil {
+		t.Fatalf("Cannot look up File %d: %s",
+			f.ID,
+			err.Error())
+	} else if f2.Watched {
+		t.Errorf("File %s is still marked as watched", path)
+	}
+} // func TestPlayLog(t *testing.T)
@@ -0,0 +1,154 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/database/13_credit_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 06. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-06 21:30:18 krylon>
+
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blicero/blockbuster/objects"
+)
+
+func TestCredit(t *testing.T) {
+	if tdb == nil || folder == nil {
+		t.SkipNow()
+	}
+
+	type credit struct {
+		name      string
+		role      objects.Role
+		character string
+		billing   int64
+	}
+
+	var (
+		err     error
+		f1, f2  *objects.File
+		c       *objects.Credit
+		cast    []objects.CastMember
+		films   objects.Filmography
+		actors  []objects.Person
+		people  = make(map[string]*objects.Person)
+		p1      = filepath.Join(basePath, "brazil_1985.mkv")
+		p2      = filepath.Join(basePath, "twelve_monkeys_1995.mkv")
+		credits = []credit{
+			{"Jonathan Pryce", objects.RoleActor, "Sam Lowry", 1},
+			{"Robert De Niro", objects.RoleActor, "Archibald 'Harry' Tuttle", 2},
+			{"Michael Palin", objects.RoleActor, "Jack Lint", 3},
+			{"Terry Gilliam", objects.RoleDirector, "", 0},
+			{"Terry Gilliam", objects.RoleWriter, "", 1},
+			{"Tom Stoppard", objects.RoleWriter, "", 2},
+			{"Michael Kamen", objects.RoleComposer, "", 0},
+		}
+	)
+
+	if f1, err = tdb.FileAdd(p1, folder); err != nil {
+		t.Fatalf("Cannot add File %s: %s", p1, err.Error())
+	} else if f2, err = tdb.FileAdd(p2, folder); err != nil {
+		t.Fatalf("Cannot add File %s: %s", p2, err.Error())
+	}
+
+	// We add the credits in reverse order, so the ordering has to come
+	// from the database.
+	for idx := len(credits) - 1; idx >= 0; idx-- {
+		var (
+			p  *objects.Person
+			ok bool
+			cr = &credits[idx]
+		)
+
+		if p, ok = people[cr.name]; !ok {
+			if p, err = tdb.PersonAdd(cr.name, time.Time{}); err != nil {
+				t.Fatalf("Cannot add Person %s: %s", cr.name, err.Error())
+			}
+			people[cr.name] = p
+		}
+
+		if c, err = tdb.CreditAdd(f1, p, cr.role, cr.character, cr.billing); err != nil {
+			t.Fatalf("Cannot credit %s as %s: %s",
+				cr.name,
+				cr.role,
+				err.Error())
+		} else if c.ID == 0 {
+			t.Errorf("Credit for %s as %s has no ID", cr.name, cr.role)
+		}
+	}
+
+	if _, err = tdb.CreditAdd(f1, people["Terry Gilliam"], objects.RoleDirector, "", 0); err == nil {
+		t.Error("Adding the same Credit twice did not cause an error")
+	} else if err = tdb.DirectorAdd(f2, people["Terry Gilliam"]); err != nil {
+		t.Fatalf("Cannot add Director to %s: %s", p2, err.Error())
+	} else if err = tdb.ActorAdd(f2, people["Jonathan Pryce"]); err != nil {
+		t.Fatalf("Cannot add Actor to %s: %s", p2, err.Error())
+	}
+
+	if cast, err = tdb.CreditGetByFile(f1); err != nil {
+		t.Fatalf("Cannot get Credits for %s: %s", p1, err.Error())
+	} else if len(cast) != len(credits) {
+		t.Fatalf("Unexpected number of Credits for %s: %d (expected %d)",
+			p1,
+			len(cast),
+			len(credits))
+	}
+
+	for idx, m := range cast {
+		var cr = &credits[idx]
+
+		if m.Person.Name != cr.name || m.Credit.Role != cr.role ||
+			m.Credit.Character != cr.character || m.Credit.Billing != cr.billing {
+			t.Errorf("Credit #%d does not match: %s as %s (%q, %d), expected %s as %s (%q, %d)",
+				idx,
+				m.Person.Name,
+				m.Credit.Role,
+				m.Credit.Character,
+				m.Credit.Billing,
+				cr.name,
+				cr.role,
+				cr.character,
+				cr.billing)
+		}
+	}
+
+	// Terry Gilliam directed both, but only wrote one of them.
+	if films, err = tdb.CreditGetByPerson(people["Terry Gilliam"]); err != nil {
+		t.Fatalf("Cannot get filmography of Terry Gilliam: %s", err.Error())
+	} else if len(films) != 2 {
+		t.Errorf("Unexpected number of Roles for Terry Gilliam: %d (expected 2)",
+			len(films))
+	} else if len(films[objects.RoleDirector]) != 2 {
+		t.Errorf("Unexpected number of films directed by Terry Gilliam: %d (expected 2)",
+			len(films[objects.RoleDirector]))
+	} else if len(films[objects.RoleWriter]) != 1 || films[objects.RoleWriter][0].File.ID != f1.ID {
+		t.Errorf("Unexpected writing credits for Terry Gilliam: %v",
+			films[objects.RoleWriter])
+	}
+
+	c = &cast[0].Credit
+	c.Character = "Sam Lowry, Information Retrieval"
+	c.Billing = 4
+
+	if err = tdb.CreditUpdate(c); err != nil {
+		t.Fatalf("Cannot update Credit %d: %s", c.ID, err.Error())
+	} else if actors, err = tdb.ActorGetByFile(f1); err != nil {
+		t.Fatalf("Cannot get Actors for %s: %s", p1, err.Error())
+	} else if len(actors) != 3 {
+		t.Fatalf("Unexpected number of Actors for %s: %d (expected 3)",
+			p1,
+			len(actors))
+	} else if actors[2].Name != "Jonathan Pryce" {
+		t.Errorf("Billing order was not updated: %s is billed last", actors[2].Name)
+	}
+
+	if err = tdb.CreditDelete(f1, people["Terry Gilliam"], objects.RoleWriter); err != nil {
+		t.Fatalf("Cannot delete writing Credit: %s", err.Error())
+	} else if films, err = tdb.CreditGetByPerson(people["Terry Gilliam"]); err != nil {
+		t.Fatalf("Cannot get filmography of Terry Gilliam: %s", err.Error())
+	} else if len(films[objects.RoleWriter]) != 0 || len(films[objects.RoleDirector]) != 2 {
+		t.Errorf("Unexpected filmography after deleting writing Credit: %v", films)
+	}
+} // func TestCredit(t *testing.T)
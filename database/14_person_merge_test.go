@@ -0,0 +1,182 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/database/14_person_merge_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 07. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-07 20:11:36 krylon>
+
+package database
+
+import (
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blicero/blockbuster/objects"
+)
+
+func TestPersonMerge(t *testing.T) {
+	if tdb == nil || folder == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err        error
+		keep, drop *objects.Person
+		p          *objects.Person
+		f1, f2     *objects.File
+		actors     []objects.Person
+		films      objects.Filmography
+		links      []objects.Link
+		aliases    []string
+		hits       []objects.SearchHit
+		l          = &objects.Link{Title: "Wikipedia"}
+		p1         = filepath.Join(basePath, "face_off_1997.mkv")
+		p2         = filepath.Join(basePath, "con_air_1997.mkv")
+	)
+
+	if l.URL, err = url.Parse("https://en.wikipedia.org/wiki/Nicolas_Cage"); err != nil {
+		t.Fatalf("Cannot parse URL: %s", err.Error())
+	} else if keep, err = tdb.PersonAdd("Nicolas Cage", time.Time{}); err != nil {
+		t.Fatalf("Cannot add Person: %s", err.Error())
+	} else if drop, err = tdb.PersonAdd("Cage, Nicolas", time.Time{}); err != nil {
+		t.Fatalf("Cannot add Person: %s", err.Error())
+	} else if f1, err = tdb.FileAdd(p1, folder); err != nil {
+		t.Fatalf("Cannot add File %s: %s", p1, err.Error())
+	} else if f2, err = tdb.FileAdd(p2, folder); err != nil {
+		t.Fatalf("Cannot add File %s: %s", p2, err.Error())
+	}
+
+	// Both are credited on Face/Off, only the duplicate on Con Air.
+	if err = tdb.ActorAdd(f1, keep); err != nil {
+		t.Fatalf("Cannot add Actor: %s", err.Error())
+	} else if err = tdb.ActorAdd(f1, drop); err != nil {
+		t.Fatalf("Cannot add Actor: %s", err.Error())
+	} else if err = tdb.ActorAdd(f2, drop); err != nil {
+		t.Fatalf("Cannot add Actor: %s", err.Error())
+	} else if err = tdb.PersonURLAdd(drop, l); err != nil {
+		t.Fatalf("Cannot add Link: %s", err.Error())
+	} else if err = tdb.PersonAliasAdd(drop, "Nicolas Kim Coppola"); err != nil {
+		t.Fatalf("Cannot add alias: %s", err.Error())
+	}
+
+	if err = tdb.PersonMerge(keep, keep); err == nil {
+		t.Error("Merging a Person with themselves did not cause an error")
+	} else if err = tdb.PersonMerge(keep, drop); err != nil {
+		t.Fatalf("Cannot merge %s into %s: %s",
+			drop.Name,
+			keep.Name,
+			err.Error())
+	}
+
+	if p, err = tdb.PersonGetByID(drop.ID); err != nil {
+		t.Fatalf("Cannot look up Person %d: %s", drop.ID, err.Error())
+	} else if p != nil {
+		t.Errorf("Merged Person %s still exists", drop.Name)
+	}
+
+	for _, name := range []string{"Nicolas Cage", "Cage, Nicolas", "Nicolas Kim Coppola"} {
+		if p, err = tdb.PersonGetByName(name); err != nil {
+			t.Fatalf("Cannot look up Person %q: %s", name, err.Error())
+		} else if p == nil {
+			t.Errorf("Person %q was not found", name)
+		} else if p.ID != keep.ID || p.Name != keep.Name {
+			t.Errorf("Looking up %q found %s (%d), expected %s (%d)",
+				name,
+				p.Name,
+				p.ID,
+				keep.Name,
+				keep.ID)
+		}
+	}
+
+	if aliases, err = tdb.PersonAliasGetByPerson(keep); err != nil {
+		t.Fatalf("Cannot get aliases of %s: %s", keep.Name, err.Error())
+	} else if len(aliases) != 2 {
+		t.Errorf("Unexpected aliases of %s: %v", keep.Name, aliases)
+	}
+
+	if actors, err = tdb.ActorGetByFile(f1); err != nil {
+		t.Fatalf("Cannot get Actors for %s: %s", p1, err.Error())
+	} else if len(actors) != 1 || actors[0].ID != keep.ID {
+		t.Errorf("Unexpected Actors for %s after merge: %v", p1, actors)
+	} else if films, err = tdb.CreditGetByPerson(keep); err != nil {
+		t.Fatalf("Cannot get filmography of %s: %s", keep.Name, err.Error())
+	} else if len(films[objects.RoleActor]) != 2 {
+		t.Errorf("Unexpected number of acting credits for %s: %d (expected 2)",
+			keep.Name,
+			len(films[objects.RoleActor]))
+	} else if links, err = tdb.PersonURLGetByPerson(keep); err != nil {
+		t.Fatalf("Cannot get Links for %s: %s", keep.Name, err.Error())
+	} else if len(links) != 1 {
+		t.Errorf("Unexpected number of Links for %s: %d (expected 1)",
+			keep.Name,
+			len(links))
+	}
+
+	// The search index knows about the aliases, too.
+	if hits, err = tdb.Search("coppola", 0); err != nil {
+		t.Fatalf("Cannot search for alias: %s", err.Error())
+	} else if countHits(hits, objects.SearchPerson, keep.ID) != 1 {
+		t.Errorf("Searching for an alias did not find %s: %v", keep.Name, hits)
+	} else if hits, err = tdb.Search("cage", 0); err != nil {
+		t.Fatalf("Cannot search for name: %s", err.Error())
+	} else if countHits(hits, objects.SearchPerson, drop.ID) != 0 {
+		t.Errorf("Search still finds merged Person %s", drop.Name)
+	} else if countHits(hits, objects.SearchPersonURL, links[0].ID) != 1 {
+		t.Errorf("Search does not find Link %d", links[0].ID)
+	}
+
+	for _, h := range hits {
+		if h.Kind == objects.SearchPersonURL && h.ID == links[0].ID && h.OwnerID != keep.ID {
+			t.Errorf("Link %d still belongs to %d in the search index (expected %d)",
+				h.ID,
+				h.OwnerID,
+				keep.ID)
+		}
+	}
+} // func TestPersonMerge(t *testing.T)
+
+func TestPersonAliasSearch(t *testing.T) {
+	if tdb == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err        error
+		from, to   *objects.Person
+		hits       []objects.SearchHit
+		fromAlias  = "Marion Morrison"
+		movedAlias = "The Duke"
+	)
+
+	if from, err = tdb.PersonAdd("John Wayne", time.Time{}); err != nil {
+		t.Fatalf("Cannot add Person: %s", err.Error())
+	} else if to, err = tdb.PersonAdd("Duke Wayne", time.Time{}); err != nil {
+		t.Fatalf("Cannot add Person: %s", err.Error())
+	} else if err = tdb.PersonAliasAdd(from, fromAlias); err != nil {
+		t.Fatalf("Cannot add alias: %s", err.Error())
+	} else if err = tdb.PersonAliasAdd(from, movedAlias); err != nil {
+		t.Fatalf("Cannot add alias: %s", err.Error())
+	}
+
+	// There is no method to move or delete a single alias, so we
+	// modify the table directly; the search index must follow either way.
+	if _, err = tdb.db.Exec("UPDATE person_alias SET person_id = ? WHERE name = ?", to.ID, movedAlias); err != nil {
+		t.Fatalf("Cannot move alias %q: %s", movedAlias, err.Error())
+	} else if hits, err = tdb.Search("duke", 0); err != nil {
+		t.Fatalf("Cannot search for alias: %s", err.Error())
+	} else if countHits(hits, objects.SearchPerson, from.ID) != 0 {
+		t.Errorf("Moved alias %q is still found for %s: %v", movedAlias, from.Name, hits)
+	} else if countHits(hits, objects.SearchPerson, to.ID) != 1 {
+		t.Errorf("Moved alias %q is not found for %s: %v", movedAlias, to.Name, hits)
+	}
+
+	if _, err = tdb.db.Exec("DELETE FROM person_alias WHERE name = ?", fromAlias); err != nil {
+		t.Fatalf("Cannot delete alias %q: %s", fromAlias, err.Error())
+	} else if hits, err = tdb.Search("morrison", 0); err != nil {
+		t.Fatalf("Cannot search for alias: %s", err.Error())
+	} else if countHits(hits, objects.SearchPerson, from.ID) != 0 {
+		t.Errorf("Deleted alias %q is still found: %v", fromAlias, hits)
+	}
+} // func TestPersonAliasSearch(t *testing.T)
@@ -0,0 +1,144 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/database/15_tag_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 08. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-08 20:14:52 krylon>
+
+package database
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/blicero/blockbuster/objects"
+)
+
+func TestTagHierarchy(t *testing.T) {
+	if tdb == nil || folder == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err                       error
+		genre, scifi, cyber, noir *objects.Tag
+		edition, criterion, crit2 *objects.Tag
+		tag                       *objects.Tag
+		f1, f2                    *objects.File
+		files                     []objects.File
+		tmap                      map[int64]objects.Tag
+		p1                        = filepath.Join(basePath, "blade_runner_1982.mkv")
+		p2                        = filepath.Join(basePath, "alphaville_1965.mkv")
+		names                     = []string{"Genre", "Sci-Fi", "Cyberpunk", "Noir", "Edition", "Criterion", "CC"}
+		tags                      = make([]*objects.Tag, len(names))
+	)
+
+	for idx, name := range names {
+		if tags[idx], err = tdb.TagAdd(name); err != nil {
+			t.Fatalf("Cannot add Tag %s: %s", name, err.Error())
+		}
+	}
+
+	genre, scifi, cyber, noir = tags[0], tags[1], tags[2], tags[3]
+	edition, criterion, crit2 = tags[4], tags[5], tags[6]
+
+	if f1, err = tdb.FileAdd(p1, folder); err != nil {
+		t.Fatalf("Cannot add File %s: %s", p1, err.Error())
+	} else if f2, err = tdb.FileAdd(p2, folder); err != nil {
+		t.Fatalf("Cannot add File %s: %s", p2, err.Error())
+	} else if err = tdb.TagSetParent(scifi, genre); err != nil {
+		t.Fatalf("Cannot move %s below %s: %s", scifi.Name, genre.Name, err.Error())
+	} else if err = tdb.TagSetParent(cyber, scifi); err != nil {
+		t.Fatalf("Cannot move %s below %s: %s", cyber.Name, scifi.Name, err.Error())
+	} else if err = tdb.TagSetParent(noir, genre); err != nil {
+		t.Fatalf("Cannot move %s below %s: %s", noir.Name, genre.Name, err.Error())
+	} else if err = tdb.TagSetParent(criterion, edition); err != nil {
+		t.Fatalf("Cannot move %s below %s: %s", criterion.Name, edition.Name, err.Error())
+	} else if err = tdb.TagLinkAdd(f1, cyber); err != nil {
+		t.Fatalf("Cannot tag %s: %s", p1, err.Error())
+	} else if err = tdb.TagLinkAdd(f1, noir); err != nil {
+		t.Fatalf("Cannot tag %s: %s", p1, err.Error())
+	} else if err = tdb.TagLinkAdd(f2, scifi); err != nil {
+		t.Fatalf("Cannot tag %s: %s", p2, err.Error())
+	} else if err = tdb.TagLinkAdd(f1, crit2); err != nil {
+		t.Fatalf("Cannot tag %s: %s", p1, err.Error())
+	} else if err = tdb.TagLinkAdd(f1, criterion); err != nil {
+		t.Fatalf("Cannot tag %s: %s", p1, err.Error())
+	}
+
+	// Cycles are not allowed.
+	if err = tdb.TagSetParent(genre, cyber); err == nil {
+		t.Errorf("Moving %s below its descendant %s did not cause an error",
+			genre.Name,
+			cyber.Name)
+	} else if err = tdb.TagSetParent(genre, genre); err == nil {
+		t.Errorf("Moving %s below itself did not cause an error", genre.Name)
+	} else if tag, err = tdb.TagGetByID(genre.ID); err != nil {
+		t.Fatalf("Cannot look up Tag %d: %s", genre.ID, err.Error())
+	} else if tag == nil || tag.Parent != 0 {
+		t.Errorf("Unexpected Tag after failed moves: %v", tag)
+	}
+
+	// Files tagged with a descendant count for the ancestors, too, but
+	// only once.
+	if files, err = tdb.TagLinkGetByTag(genre); err != nil {
+		t.Fatalf("Cannot get Files for %s: %s", genre.Name, err.Error())
+	} else if len(files) != 2 {
+		t.Errorf("Unexpected number of Files for %s: %d (expected 2)",
+			genre.Name,
+			len(files))
+	} else if files, err = tdb.TagLinkGetByTag(cyber); err != nil {
+		t.Fatalf("Cannot get Files for %s: %s", cyber.Name, err.Error())
+	} else if len(files) != 1 || files[0].ID != f1.ID {
+		t.Errorf("Unexpected Files for %s: %v", cyber.Name, files)
+	}
+
+	if err = tdb.TagRename(crit2, "Criterion Collection"); err != nil {
+		t.Fatalf("Cannot rename %s: %s", crit2.Name, err.Error())
+	} else if tag, err = tdb.TagGetByID(crit2.ID); err != nil {
+		t.Fatalf("Cannot look up Tag %d: %s", crit2.ID, err.Error())
+	} else if tag.Name != "Criterion Collection" || crit2.Name != tag.Name {
+		t.Errorf("Tag was not renamed: %q / %q", tag.Name, crit2.Name)
+	} else if err = tdb.TagMerge(criterion, criterion); err == nil {
+		t.Error("Merging a Tag with itself did not cause an error")
+	} else if err = tdb.TagMerge(criterion, crit2); err != nil {
+		t.Fatalf("Cannot merge %s into %s: %s", crit2.Name, criterion.Name, err.Error())
+	} else if tag, err = tdb.TagGetByID(crit2.ID); err != nil {
+		t.Fatalf("Cannot look up Tag %d: %s", crit2.ID, err.Error())
+	} else if tag != nil {
+		t.Errorf("Merged Tag %s still exists", crit2.Name)
+	} else if tmap, err = tdb.TagLinkGetByFile(f1); err != nil {
+		t.Fatalf("Cannot get Tags for %s: %s", p1, err.Error())
+	} else if _, ok := tmap[criterion.ID]; !ok || len(tmap) != 3 {
+		t.Errorf("Unexpected Tags for %s after merge: %v", p1, tmap)
+	}
+
+	// Merging a Tag into one of its descendants moves the descendant up,
+	// the other children end up below it.
+	if err = tdb.TagMerge(cyber, genre); err != nil {
+		t.Fatalf("Cannot merge %s into %s: %s", genre.Name, cyber.Name, err.Error())
+	}
+
+	for _, c := range []struct {
+		tag    *objects.Tag
+		parent int64
+	}{
+		{cyber, 0},
+		{scifi, cyber.ID},
+		{noir, cyber.ID},
+	} {
+		if tag, err = tdb.TagGetByID(c.tag.ID); err != nil {
+			t.Fatalf("Cannot look up Tag %d: %s", c.tag.ID, err.Error())
+		} else if tag == nil {
+			t.Errorf("Tag %s has disappeared", c.tag.Name)
+		} else if tag.Parent != c.parent {
+			t.Errorf("Unexpected parent of %s: %d (expected %d)",
+				tag.Name,
+				tag.Parent,
+				c.parent)
+		}
+	}
+
+	if err = tdb.TagSetParent(cyber, nil); err != nil {
+		t.Errorf("Cannot move %s to the top level: %s", cyber.Name, err.Error())
+	}
+} // func TestTagHierarchy(t *testing.T)
@@ -0,0 +1,191 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/database/16_collection_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 09. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-09 22:03:18 krylon>
+
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blicero/blockbuster/objects"
+)
+
+func fileIDs(files []objects.File) map[int64]bool {
+	var ids = make(map[int64]bool, len(files))
+
+	for _, f := range files {
+		ids[f.ID] = true
+	}
+
+	return ids
+} // func fileIDs(files []objects.File) map[int64]bool
+
+func TestCollection(t *testing.T) {
+	if tdb == nil || folder == nil {
+		t.SkipNow()
+	}
+
+	type movie struct {
+		name    string
+		year    int64
+		tags    []*objects.Tag
+		watched bool
+	}
+
+	var (
+		err            error
+		p              *objects.Person
+		ta, tb, tbSub  *objects.Tag
+		coll           *objects.Collection
+		colls          []objects.Collection
+		files          []objects.File
+		ids            map[int64]bool
+		movies         []movie
+		fmap           = make(map[string]*objects.File)
+		condAnotB      objects.Condition
+		condUnwatched  objects.Condition
+		condUntagged   objects.Condition
+		badCond        = objects.Condition{Kind: objects.CondYear, Op: 42}
+		emptyAnd       = objects.And()
+		withPersonName = "Warren Oates"
+	)
+
+	if ta, err = tdb.TagAdd("Smart A"); err != nil {
+		t.Fatalf("Cannot add Tag: %s", err.Error())
+	} else if tb, err = tdb.TagAdd("Smart B"); err != nil {
+		t.Fatalf("Cannot add Tag: %s", err.Error())
+	} else if tbSub, err = tdb.TagAdd("Smart B/Sub"); err != nil {
+		t.Fatalf("Cannot add Tag: %s", err.Error())
+	} else if err = tdb.TagSetParent(tbSub, tb); err != nil {
+		t.Fatalf("Cannot move Tag: %s", err.Error())
+	} else if p, err = tdb.PersonAdd(withPersonName, time.Time{}); err != nil {
+		t.Fatalf("Cannot add Person: %s", err.Error())
+	}
+
+	movies = []movie{
+		{"smart_a_1975.mkv", 1975, []*objects.Tag{ta}, false},
+		{"smart_ab_1985.mkv", 1985, []*objects.Tag{ta, tb}, false},
+		{"smart_asub_1970.mkv", 1970, []*objects.Tag{ta, tbSub}, false},
+		{"smart_oates_1974.mkv", 1974, nil, false},
+		{"smart_oates_1971.mkv", 1971, nil, true},
+	}
+
+	for _, m := range movies {
+		var (
+			f    *objects.File
+			path = filepath.Join(basePath, m.name)
+		)
+
+		if f, err = tdb.FileAdd(path, folder); err != nil {
+			t.Fatalf("Cannot add File %s: %s", path, err.Error())
+		} else if err = tdb.FileUpdateYear(f, m.year); err != nil {
+			t.Fatalf("Cannot set year of %s: %s", path, err.Error())
+		} else if err = tdb.FileSetWatched(f, m.watched); err != nil {
+			t.Fatalf("Cannot set watched flag of %s: %s", path, err.Error())
+		}
+
+		for _, tag := range m.tags {
+			if err = tdb.TagLinkAdd(f, tag); err != nil {
+				t.Fatalf("Cannot tag %s with %s: %s", path, tag.Name, err.Error())
+			}
+		}
+
+		fmap[m.name] = f
+	}
+
+	for _, name := range []string{"smart_oates_1974.mkv", "smart_oates_1971.mkv"} {
+		if err = tdb.ActorAdd(fmap[name], p); err != nil {
+			t.Fatalf("Cannot add Actor to %s: %s", name, err.Error())
+		}
+	}
+
+	// "Tag A and not Tag B, year < 1980"
+	condAnotB = objects.And(
+		objects.Condition{Kind: objects.CondTag, ID: ta.ID},
+		objects.Not(objects.Condition{Kind: objects.CondTag, ID: tb.ID}),
+		objects.Condition{Kind: objects.CondYear, Op: objects.OpLt, Value: 1980},
+	)
+
+	// "films with Person X that I haven't watched"
+	condUnwatched = objects.And(
+		objects.Condition{Kind: objects.CondPerson, ID: p.ID, Role: objects.RoleActor},
+		objects.Not(objects.Condition{Kind: objects.CondWatched}),
+	)
+
+	// "untagged files added this year"
+	condUntagged = objects.And(
+		objects.Condition{Kind: objects.CondUntagged},
+		objects.Condition{Kind: objects.CondAdded, Op: objects.OpEq},
+		objects.Condition{Kind: objects.CondTitle, Text: "smart_"},
+	)
+
+	if files, err = tdb.FileGetByCondition(&condAnotB); err != nil {
+		t.Fatalf("Cannot evaluate Condition: %s", err.Error())
+	} else if len(files) != 1 || files[0].ID != fmap["smart_a_1975.mkv"].ID {
+		t.Errorf("Unexpected Files for Tag A and not Tag B: %v", files)
+	}
+
+	if files, err = tdb.FileGetByCondition(&condUnwatched); err != nil {
+		t.Fatalf("Cannot evaluate Condition: %s", err.Error())
+	} else if len(files) != 1 || files[0].ID != fmap["smart_oates_1974.mkv"].ID {
+		t.Errorf("Unexpected unwatched Files with %s: %v", withPersonName, files)
+	}
+
+	if files, err = tdb.FileGetByCondition(&condUntagged); err != nil {
+		t.Fatalf("Cannot evaluate Condition: %s", err.Error())
+	} else if ids = fileIDs(files); len(ids) != 2 ||
+		!ids[fmap["smart_oates_1974.mkv"].ID] || !ids[fmap["smart_oates_1971.mkv"].ID] {
+		t.Errorf("Unexpected untagged Files: %v", files)
+	}
+
+	if _, err = tdb.FileGetByCondition(&badCond); err == nil {
+		t.Error("Invalid Condition did not cause an error")
+	} else if _, err = tdb.CollectionAdd("Broken", badCond); err == nil {
+		t.Error("Adding a Collection with an invalid Condition did not cause an error")
+	} else if files, err = tdb.FileGetByCondition(&emptyAnd); err != nil {
+		t.Errorf("Cannot evaluate empty And: %s", err.Error())
+	} else if len(files) < len(movies) {
+		t.Errorf("Empty And matches only %d Files", len(files))
+	}
+
+	if coll, err = tdb.CollectionAdd("A, not B, old", condAnotB); err != nil {
+		t.Fatalf("Cannot add Collection: %s", err.Error())
+	} else if _, err = tdb.CollectionAdd("Unwatched Oates", condUnwatched); err != nil {
+		t.Fatalf("Cannot add Collection: %s", err.Error())
+	} else if colls, err = tdb.CollectionGetAll(); err != nil {
+		t.Fatalf("Cannot load Collections: %s", err.Error())
+	} else if len(colls) != 2 {
+		t.Fatalf("Unexpected number of Collections: %d (expected 2)", len(colls))
+	} else if colls[0].ID != coll.ID || len(colls[0].Cond.Sub) != 3 {
+		t.Errorf("Collection did not survive the round trip: %v", colls[0])
+	} else if files, err = tdb.CollectionGetMembers(&colls[0]); err != nil {
+		t.Fatalf("Cannot get members of Collection %s: %s", colls[0].Name, err.Error())
+	} else if len(files) != 1 {
+		t.Errorf("Unexpected members of Collection %s: %v", colls[0].Name, files)
+	}
+
+	// Membership is live: Untagging a File makes it show up.
+	if err = tdb.TagLinkDelete(fmap["smart_ab_1985.mkv"], tb); err != nil {
+		t.Fatalf("Cannot remove Tag: %s", err.Error())
+	}
+
+	coll.Cond.Sub[2].Value = 1990
+
+	if err = tdb.CollectionUpdate(coll); err != nil {
+		t.Fatalf("Cannot update Collection %s: %s", coll.Name, err.Error())
+	} else if files, err = tdb.CollectionGetMembers(coll); err != nil {
+		t.Fatalf("Cannot get members of Collection %s: %s", coll.Name, err.Error())
+	} else if len(files) != 2 {
+		t.Errorf("Unexpected members of Collection %s after update: %v", coll.Name, files)
+	} else if err = tdb.CollectionDelete(coll); err != nil {
+		t.Fatalf("Cannot delete Collection %s: %s", coll.Name, err.Error())
+	} else if colls, err = tdb.CollectionGetAll(); err != nil {
+		t.Fatalf("Cannot load Collections: %s", err.Error())
+	} else if len(colls) != 1 {
+		t.Errorf("Unexpected number of Collections after delete: %d (expected 1)", len(colls))
+	}
+} // func TestCollection(t *testing.T)
@@ -0,0 +1,85 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/database/17_filter_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 10. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-10 22:08:45 krylon>
+
+package database
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/blicero/blockbuster/filter"
+	"github.com/blicero/blockbuster/objects"
+)
+
+// TestFilter runs queries in the language of the search bars against the
+// Files, Tags and People TestCollection left behind.
+func TestFilter(t *testing.T) {
+	if tdb == nil || folder == nil {
+		t.SkipNow()
+	}
+
+	type testCase struct {
+		query string
+		files []string
+	}
+
+	var cases = []testCase{
+		{
+			query: `tag:"smart a" -tag:"Smart B" year:..1980`,
+			files: []string{"smart_a_1975.mkv"},
+		},
+		{
+			query: `tag:"Smart B"`,
+			files: []string{"smart_asub_1970.mkv"},
+		},
+		{
+			query: `actor:"warren oates" -is:watched`,
+			files: []string{"smart_oates_1974.mkv"},
+		},
+		{
+			query: `director:"Warren Oates"`,
+			files: nil,
+		},
+		{
+			query: `person:"Warren Oates" year:1971..1972`,
+			files: []string{"smart_oates_1971.mkv"},
+		},
+		{
+			query: `smart_ tag:nonexistent`,
+			files: nil,
+		},
+	}
+
+	for _, c := range cases {
+		var (
+			err   error
+			q     *filter.Query
+			files []objects.File
+		)
+
+		if q, err = filter.Parse(c.query); err != nil {
+			t.Fatalf("Cannot parse %q: %s", c.query, err.Error())
+		} else if files, err = tdb.FileGetByCondition(&q.Cond); err != nil {
+			t.Fatalf("Cannot evaluate %q: %s", c.query, err.Error())
+		} else if len(files) != len(c.files) {
+			t.Errorf("Unexpected number of Files for %q: %d (expected %d)",
+				c.query,
+				len(files),
+				len(c.files))
+			continue
+		}
+
+		for i, name := range c.files {
+			if files[i].Path != filepath.Join(basePath, name) {
+				t.Errorf("Unexpected File #%d for %q: %s (expected %s)",
+					i,
+					c.query,
+					files[i].Path,
+					name)
+			}
+		}
+	}
+} // func TestFilter(t *testing.T)
@@ -0,0 +1,220 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/database/18_backup_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 11. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-11 19:02:16 krylon>
+
+package database
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blicero/blockbuster/common"
+	"github.com/blicero/blockbuster/objects"
+)
+
+func TestBackup(t *testing.T) {
+	if tdb == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err          error
+		bdb          *Database
+		orig, bfiles []objects.File
+		dest         = filepath.Join(common.BaseDir, "backup_test.db")
+		junk         = filepath.Join(common.BaseDir, "junk.db")
+	)
+
+	if err = tdb.Backup(dest); err != nil {
+		t.Fatalf("Cannot back up database: %s", err.Error())
+	} else if err = CheckSnapshot(dest); err != nil {
+		t.Fatalf("Backup %s does not pass inspection: %s", dest, err.Error())
+	} else if bdb, err = Open(dest); err != nil {
+		t.Fatalf("Cannot open backup %s: %s", dest, err.Error())
+	}
+
+	defer bdb.Close() // nolint: errcheck
+
+	if orig, err = tdb.FileGetAll(); err != nil {
+		t.Fatalf("Cannot get Files from database: %s", err.Error())
+	} else if bfiles, err = bdb.FileGetAll(); err != nil {
+		t.Fatalf("Cannot get Files from backup: %s", err.Error())
+	} else if len(orig) == 0 || len(orig) != len(bfiles) {
+		t.Errorf("Backup has %d Files, database has %d", len(bfiles), len(orig))
+	}
+
+	if err = ioutil.WriteFile(junk, []byte("This is not a database"), 0600); err != nil {
+		t.Fatalf("Cannot write %s: %s", junk, err.Error())
+	} else if err = CheckSnapshot(junk); err == nil {
+		t.Errorf("Garbage file %s passes inspection", junk)
+	} else if err = CheckSnapshot(junk + ".nonexistent"); err == nil {
+		t.Error("Nonexistent file passes inspection")
+	}
+} // func TestBackup(t *testing.T)
+
+func TestSnapshotRotate(t *testing.T) {
+	if tdb == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err   error
+		list  []Snapshot
+		taken []Snapshot
+		dir   = filepath.Join(common.BaseDir, "snapshot_test")
+		now   = time.Date(2021, time.September, 11, 3, 0, 0, 0, time.Local)
+	)
+
+	if taken, err = tdb.SnapshotRotate(dir, now); err != nil {
+		t.Fatalf("Cannot take snapshots: %s", err.Error())
+	} else if len(taken) != 3 {
+		t.Fatalf("Expected 3 snapshots in an empty directory, got %d", len(taken))
+	} else if taken, err = tdb.SnapshotRotate(dir, now.Add(time.Hour)); err != nil {
+		t.Fatalf("Cannot take snapshots: %s", err.Error())
+	} else if len(taken) != 0 {
+		t.Errorf("Expected no snapshots after an hour, got %d", len(taken))
+	}
+
+	// Forty days later, we should have hit the limit on daily snapshots,
+	// while the others are still accumulating.
+	for day := 1; day <= 40; day++ {
+		if _, err = tdb.SnapshotRotate(dir, now.AddDate(0, 0, day)); err != nil {
+			t.Fatalf("Cannot take snapshots on day %d: %s", day, err.Error())
+		}
+	}
+
+	if list, err = SnapshotList(dir); err != nil {
+		t.Fatalf("Cannot list snapshots: %s", err.Error())
+	}
+
+	var cnt = make(map[SnapshotKind]int)
+
+	for _, s := range list {
+		cnt[s.Kind]++
+	}
+
+	if cnt[SnapshotDaily] != 7 || cnt[SnapshotWeekly] != 4 || cnt[SnapshotMonthly] != 2 {
+		t.Errorf("Unexpected number of snapshots: %v", cnt)
+	} else if !list[0].Time.Equal(now.AddDate(0, 0, 40)) {
+		t.Errorf("Newest snapshot is from %s", list[0].Time)
+	} else if err = CheckSnapshot(list[0].Path); err != nil {
+		t.Errorf("Snapshot %s does not pass inspection: %s", list[0].Path, err.Error())
+	}
+} // func TestSnapshotRotate(t *testing.T)
+
+func TestRestore(t *testing.T) {
+	if tdb == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err    error
+		db     *Database
+		dir    *objects.Folder
+		files  []objects.File
+		path   = filepath.Join(common.BaseDir, "restore_test.db")
+		snap   = filepath.Join(common.BaseDir, "restore_test_snapshot.db")
+		movie1 = filepath.Join(basePath, "restore_1.mkv")
+		movie2 = filepath.Join(basePath, "restore_2.mkv")
+	)
+
+	if db, err = Open(path); err != nil {
+		t.Fatalf("Cannot create database %s: %s", path, err.Error())
+	} else if dir, err = db.FolderAdd(basePath); err != nil {
+		t.Fatalf("Cannot add Folder %s: %s", basePath, err.Error())
+	} else if _, err = db.FileAdd(movie1, dir); err != nil {
+		t.Fatalf("Cannot add File %s: %s", movie1, err.Error())
+	} else if err = db.Backup(snap); err != nil {
+		t.Fatalf("Cannot back up database: %s", err.Error())
+	} else if _, err = db.FileAdd(movie2, dir); err != nil {
+		t.Fatalf("Cannot add File %s: %s", movie2, err.Error())
+	} else if err = restoreFile(snap, path); err != ErrDatabaseInUse {
+		t.Errorf("Restoring a database in use did not fail as expected: %v", err)
+	} else if err = db.Close(); err != nil {
+		t.Fatalf("Cannot close database: %s", err.Error())
+	} else if err = restoreFile(snap, path); err != nil {
+		t.Fatalf("Cannot restore database from %s: %s", snap, err.Error())
+	} else if db, err = Open(path); err != nil {
+		t.Fatalf("Cannot open restored database %s: %s", path, err.Error())
+	}
+
+	defer db.Close() // nolint: errcheck
+
+	if files, err = db.FileGetAll(); err != nil {
+		t.Fatalf("Cannot get Files from restored database: %s", err.Error())
+	} else if len(files) != 1 || files[0].Path != movie1 {
+		t.Errorf("Unexpected Files in restored database: %v", files)
+	}
+} // func TestRestore(t *testing.T)
+
+func TestPoolRestore(t *testing.T) {
+	if tdb == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err          error
+		pool         *Pool
+		db1, db2, db *Database
+		extra        *Database
+		done         = make(chan error)
+		snap         = filepath.Join(common.BaseDir, "pool_restore_snapshot.db")
+	)
+
+	if err = tdb.Backup(snap); err != nil {
+		t.Fatalf("Cannot back up database: %s", err.Error())
+	} else if pool, err = NewPool(2); err != nil {
+		t.Fatalf("Cannot create Pool: %s", err.Error())
+	}
+
+	defer pool.Close() // nolint: errcheck
+
+	db1 = pool.Get()
+	db2 = pool.Get()
+
+	// The Pool is empty, so GetNoWait opens an additional connection.
+	if extra, err = pool.GetNoWait(); err != nil {
+		t.Fatalf("Cannot get additional connection: %s", err.Error())
+	}
+
+	pool.Put(db1)
+	pool.Put(extra)
+
+	go func() { done <- pool.Restore(snap) }()
+
+	select {
+	case err = <-done:
+		t.Fatalf("Restore did not wait for the last connection: %v", err)
+	case <-time.After(time.Millisecond * 250):
+	}
+
+	pool.Put(db2)
+
+	select {
+	case err = <-done:
+		// tdb is still open, so the database cannot be replaced,
+		// but Restore must have waited for us nonetheless.
+		if err != ErrDatabaseInUse {
+			t.Errorf("Unexpected result from Restore: %v", err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("Restore did not finish after all connections were returned")
+	}
+
+	if pool.cnt != pool.size || pool.out != 0 {
+		t.Errorf("Unexpected state of Pool after Restore: %d connections, %d handed out",
+			pool.cnt,
+			pool.out)
+	}
+
+	db = pool.Get()
+	defer pool.Put(db)
+
+	if _, err = db.FolderGetAll(); err != nil {
+		t.Errorf("Cannot use connection after Restore: %s", err.Error())
+	}
+} // func TestPoolRestore(t *testing.T)
@@ -0,0 +1,176 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/database/19_export_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 12. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-12 20:31:07 krylon>
+
+package database
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blicero/blockbuster/common"
+	"github.com/blicero/blockbuster/objects"
+)
+
+// TestExport exports the database the previous tests have left behind,
+// imports it into a fresh database and exports that, which should give us
+// the same document.
+func TestExport(t *testing.T) {
+	if tdb == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err           error
+		db            *Database
+		report        *ImportReport
+		first, second bytes.Buffer
+		series        []objects.Series
+		seasons       []objects.Season
+		episodes      []objects.Episode
+		files         []objects.File
+		colls         []objects.Collection
+		members       = make(map[string]int)
+		path          = filepath.Join(common.BaseDir, "export_test.db")
+		info          = &objects.MediaInfo{
+			Container:  "matroska,webm",
+			Duration:   time.Minute * 97,
+			Width:      1920,
+			Height:     1080,
+			FrameRate:  23.976,
+			VideoCodec: "h264",
+			Probed:     time.Now().Truncate(time.Second),
+			Tracks: []objects.Track{
+				{Kind: objects.TrackAudio, Codec: "ac3", Language: "eng", Channels: 6},
+				{Kind: objects.TrackSubtitle, Codec: "subrip", Language: "ger", Name: "Forced"},
+			},
+		}
+	)
+
+	// The Episode the Series test has assigned to a File is gone by now,
+	// and so is the File the media test has probed.
+	if series, err = tdb.SeriesGetAll(); err != nil || len(series) == 0 {
+		t.Fatalf("Cannot get Series: %v", err)
+	} else if seasons, err = tdb.SeasonGetBySeries(&series[0]); err != nil || len(seasons) == 0 {
+		t.Fatalf("Cannot get Seasons of %s: %v", series[0].Title, err)
+	} else if episodes, err = tdb.EpisodeGetBySeason(&seasons[0]); err != nil || len(episodes) == 0 {
+		t.Fatalf("Cannot get Episodes of Season %d: %v", seasons[0].Number, err)
+	} else if files, err = tdb.FileGetAll(); err != nil || len(files) == 0 {
+		t.Fatalf("Cannot get Files: %v", err)
+	} else if err = tdb.FileSetEpisode(&files[0], &episodes[0]); err != nil {
+		t.Fatalf("Cannot set Episode of File %s: %s", files[0].Path, err.Error())
+	} else if err = tdb.MediaInfoSet(&files[0], info); err != nil {
+		t.Fatalf("Cannot set MediaInfo of File %s: %s", files[0].Path, err.Error())
+	} else if colls, err = tdb.CollectionGetAll(); err != nil {
+		t.Fatalf("Cannot get Collections: %s", err.Error())
+	}
+
+	for idx := range colls {
+		if files, err = tdb.CollectionGetMembers(&colls[idx]); err != nil {
+			t.Fatalf("Cannot get members of Collection %s: %s",
+				colls[idx].Name,
+				err.Error())
+		}
+		members[colls[idx].Name] = len(files)
+	}
+
+	if err = tdb.Export(&first); err != nil {
+		t.Fatalf("Cannot export database: %s", err.Error())
+	} else if db, err = Open(path); err != nil {
+		t.Fatalf("Cannot create database %s: %s", path, err.Error())
+	}
+
+	defer db.Close() // nolint: errcheck
+
+	if report, err = db.Import(bytes.NewReader(first.Bytes())); err != nil {
+		t.Fatalf("Cannot import library: %s", err.Error())
+	} else if len(report.Conflicts) != 0 {
+		t.Errorf("Import into empty database had conflicts: %v", report.Conflicts)
+	}
+
+	for _, section := range []string{
+		"files",
+		"tags",
+		"credits",
+		"series",
+		"seasons",
+		"episodes",
+		"episode_links",
+		"play_log",
+		"media_info",
+		"media_tracks",
+		"collections",
+	} {
+		if report.Added[section] == 0 {
+			t.Errorf("Import is missing %s: %v", section, report.Added)
+		}
+	}
+
+	if err = db.Export(&second); err != nil {
+		t.Fatalf("Cannot export imported database: %s", err.Error())
+	} else if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Errorf("Exports differ after round trip:\n%s\n\n%s",
+			first.String(),
+			second.String())
+	}
+
+	// Collections refer to Tags and People by their ID, those have to be
+	// translated, too.
+	if colls, err = db.CollectionGetAll(); err != nil {
+		t.Fatalf("Cannot get imported Collections: %s", err.Error())
+	} else if len(colls) != len(members) {
+		t.Errorf("Unexpected number of imported Collections: %d (expected %d)",
+			len(colls),
+			len(members))
+	}
+
+	for idx := range colls {
+		if files, err = db.CollectionGetMembers(&colls[idx]); err != nil {
+			t.Fatalf("Cannot get members of imported Collection %s: %s",
+				colls[idx].Name,
+				err.Error())
+		} else if len(files) != members[colls[idx].Name] {
+			t.Errorf("Imported Collection %s has %d members, expected %d",
+				colls[idx].Name,
+				len(files),
+				members[colls[idx].Name])
+		}
+	}
+
+	// Importing the library into itself adds nothing, but everything
+	// that already exists is reported.
+	if report, err = tdb.Import(bytes.NewReader(first.Bytes())); err != nil {
+		t.Fatalf("Cannot import library into itself: %s", err.Error())
+	} else if len(report.Conflicts) == 0 {
+		t.Error("Import into same database had no conflicts")
+	}
+
+	for section, cnt := range report.Added {
+		if cnt != 0 {
+			t.Errorf("Import into same database added %d to %s", cnt, section)
+		}
+	}
+
+	var broken = []string{
+		`{"format": "something else", "version": 1, "folders": []}`,
+		`{"format": "blockbuster-library", "version": 99, "folders": []}`,
+		`{"folders": []}`,
+		`{"format": "blockbuster-library", "version": 1, "files": [{"id": 1, "folder": 42, "path": "/nowhere.mkv"}]}`,
+	}
+
+	for _, doc := range broken {
+		if _, err = db.Import(strings.NewReader(doc)); err == nil {
+			t.Errorf("Import of broken document did not fail: %s", doc)
+		} else if strings.HasPrefix(doc, `{"format": "blockbuster-library", "version": 1`) {
+			continue
+		} else if !errors.Is(err, ErrExportFormat) {
+			t.Errorf("Unexpected error importing %s: %s", doc, err.Error())
+		}
+	}
+} // func TestExport(t *testing.T)
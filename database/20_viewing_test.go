@@ -0,0 +1,113 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/database/20_viewing_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 14. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-14 19:20:44 krylon>
+
+package database
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blicero/blockbuster/common"
+	"github.com/blicero/blockbuster/objects"
+)
+
+func TestViewing(t *testing.T) {
+	if tdb == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err     error
+		added   bool
+		files   []objects.File
+		list    []objects.Viewing
+		ratings map[int64]int64
+		watched = time.Date(2019, time.March, 3, 0, 0, 0, 0, time.Local)
+	)
+
+	if files, err = tdb.FileGetAll(); err != nil {
+		t.Fatalf("Cannot get Files: %s", err.Error())
+	} else if len(files) == 0 {
+		t.Fatal("No Files in database")
+	}
+
+	var f = &files[0]
+
+	if added, err = tdb.ViewingAdd(f, watched, "letterboxd"); err != nil {
+		t.Fatalf("Cannot add Viewing: %s", err.Error())
+	} else if !added {
+		t.Error("Viewing was not added")
+	} else if added, err = tdb.ViewingAdd(f, watched, "imdb"); err != nil {
+		t.Fatalf("Cannot add Viewing twice: %s", err.Error())
+	} else if added {
+		t.Error("The same Viewing was added twice")
+	} else if list, err = tdb.ViewingGetAll(); err != nil {
+		t.Fatalf("Cannot get Viewings: %s", err.Error())
+	}
+
+	var found bool
+
+	for _, v := range list {
+		if v.FileID == f.ID && v.Watched.Equal(watched) {
+			if v.Source != "letterboxd" || v.ID == 0 {
+				t.Errorf("Unexpected Viewing: %#v", v)
+			}
+			found = true
+		}
+	}
+
+	if !found {
+		t.Errorf("Viewing of File %d was not found in %v", f.ID, list)
+	}
+
+	if err = tdb.FileSetRating(f, 7); err != nil {
+		t.Fatalf("Cannot set rating: %s", err.Error())
+	} else if err = tdb.FileSetRating(f, objects.MaxRating+1); err == nil {
+		t.Error("Setting an invalid rating did not fail")
+	} else if ratings, err = tdb.FileGetRatings(); err != nil {
+		t.Fatalf("Cannot get ratings: %s", err.Error())
+	} else if ratings[f.ID] != 7 {
+		t.Errorf("Unexpected rating for File %d: %d", f.ID, ratings[f.ID])
+	}
+
+	// Ratings and Viewings make it through an export, too.
+	var (
+		db   *Database
+		doc  bytes.Buffer
+		path = filepath.Join(common.BaseDir, "viewing_test.db")
+	)
+
+	if err = tdb.Export(&doc); err != nil {
+		t.Fatalf("Cannot export database: %s", err.Error())
+	} else if db, err = Open(path); err != nil {
+		t.Fatalf("Cannot create database %s: %s", path, err.Error())
+	}
+
+	defer db.Close() // nolint: errcheck
+
+	if _, err = db.Import(bytes.NewReader(doc.Bytes())); err != nil {
+		t.Fatalf("Cannot import library: %s", err.Error())
+	} else if ratings, err = db.FileGetRatings(); err != nil {
+		t.Fatalf("Cannot get imported ratings: %s", err.Error())
+	} else if len(ratings) != 1 {
+		t.Errorf("Expected 1 rating after import, got %d", len(ratings))
+	} else if list, err = db.ViewingGetAll(); err != nil {
+		t.Fatalf("Cannot get imported Viewings: %s", err.Error())
+	}
+
+	found = false
+	for _, v := range list {
+		if v.Source == "letterboxd" && v.Watched.Equal(watched) {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Errorf("Viewing was lost on export: %v", list)
+	}
+} // func TestViewing(t *testing.T)
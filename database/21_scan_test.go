@@ -0,0 +1,167 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/database/21_scan_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 17. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-17 14:52:09 krylon>
+
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blicero/blockbuster/objects"
+)
+
+func TestFileStat(t *testing.T) {
+	if tdb == nil || folder == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err   error
+		f, f2 *objects.File
+		files []objects.File
+		path  = filepath.Join(basePath, "the_conversation_1974.mkv")
+		st    = objects.FileStat{
+			Size:  1024 * 1024 * 700,
+			Mtime: time.Unix(1631881234, 123456789),
+			Inode: 4711,
+		}
+	)
+
+	if f, err = tdb.FileAdd(path, folder); err != nil {
+		t.Fatalf("Cannot add File %s: %s", path, err.Error())
+	} else if err = tdb.FileSetStat(f, st); err != nil {
+		t.Fatalf("Cannot set stat data of File %s: %s", path, err.Error())
+	} else if f2, err = tdb.FileGetByPath(path); err != nil {
+		t.Fatalf("Cannot look up File %s: %s", path, err.Error())
+	} else if !f2.Stat.Equal(st) {
+		t.Errorf("Unexpected stat data: %#v (expected %#v)", f2.Stat, st)
+	} else if files, err = tdb.FileGetByFolder(folder); err != nil {
+		t.Fatalf("Cannot get Files in Folder %s: %s", folder.Path, err.Error())
+	}
+
+	for _, file := range files {
+		if file.ID == f.ID && !file.Stat.Equal(st) {
+			t.Errorf("FileGetByFolder returned unexpected stat data: %#v (expected %#v)",
+				file.Stat,
+				st)
+		}
+	}
+} // func TestFileStat(t *testing.T)
+
+func TestDirCache(t *testing.T) {
+	if tdb == nil || folder == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err    error
+		cached map[string]time.Time
+		dirs   = map[string]time.Time{
+			basePath:                      time.Unix(1631881234, 5),
+			filepath.Join(basePath, "a"):  time.Unix(1631881235, 0),
+			filepath.Join(basePath, "ab"): time.Unix(1631881236, 999999999),
+		}
+	)
+
+	if err = tdb.DirCacheSet(folder, dirs); err != nil {
+		t.Fatalf("Cannot set directory cache: %s", err.Error())
+	} else if cached, err = tdb.DirCacheGet(folder); err != nil {
+		t.Fatalf("Cannot get directory cache: %s", err.Error())
+	} else if len(cached) != len(dirs) {
+		t.Fatalf("Unexpected number of cached directories: %d (expected %d)",
+			len(cached),
+			len(dirs))
+	}
+
+	for path, mtime := range dirs {
+		if !cached[path].Equal(mtime) {
+			t.Errorf("Unexpected mtime for %s: %s (expected %s)",
+				path,
+				cached[path],
+				mtime)
+		}
+	}
+
+	// Setting the cache again must replace it, not add to it.
+	delete(dirs, basePath)
+
+	if err = tdb.DirCacheSet(folder, dirs); err != nil {
+		t.Fatalf("Cannot set directory cache: %s", err.Error())
+	} else if cached, err = tdb.DirCacheGet(folder); err != nil {
+		t.Fatalf("Cannot get directory cache: %s", err.Error())
+	} else if len(cached) != len(dirs) {
+		t.Errorf("Unexpected number of cached directories: %d (expected %d)",
+			len(cached),
+			len(dirs))
+	} else if err = tdb.DirCacheClear(folder); err != nil {
+		t.Fatalf("Cannot clear directory cache: %s", err.Error())
+	} else if cached, err = tdb.DirCacheGet(folder); err != nil {
+		t.Fatalf("Cannot get directory cache: %s", err.Error())
+	} else if len(cached) != 0 {
+		t.Errorf("Directory cache was not cleared: %v", cached)
+	}
+} // func TestDirCache(t *testing.T)
+
+func TestScanRun(t *testing.T) {
+	if tdb == nil || folder == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err   error
+		runs  []objects.ScanRun
+		start = time.Unix(1631890000, 250*int64(time.Millisecond))
+	)
+
+	for i := 0; i < scanRunKeep+5; i++ {
+		var r = objects.ScanRun{
+			FolderID: folder.ID,
+			Start:    start.Add(time.Minute * time.Duration(i)),
+			End:      start.Add(time.Minute*time.Duration(i) + time.Millisecond*1500),
+			Status:   objects.ScanStatus(i % 3),
+			Dirs:     i,
+			Files:    i * 2,
+			FilesNew: 1,
+			Errors:   i % 2,
+		}
+
+		if err = tdb.ScanRunAdd(&r); err != nil {
+			t.Fatalf("Cannot add ScanRun #%d: %s", i, err.Error())
+		} else if r.ID == 0 {
+			t.Fatalf("ScanRun #%d did not get an ID", i)
+		}
+	}
+
+	if runs, err = tdb.ScanRunGetByFolder(folder, scanRunKeep*2); err != nil {
+		t.Fatalf("Cannot get ScanRuns: %s", err.Error())
+	} else if len(runs) != scanRunKeep {
+		t.Fatalf("Unexpected number of ScanRuns: %d (expected %d)",
+			len(runs),
+			scanRunKeep)
+	}
+
+	var (
+		last = scanRunKeep + 4
+		r    = runs[0]
+	)
+
+	if r.Dirs != last || r.Files != last*2 || r.Status != objects.ScanStatus(last%3) || r.Errors != last%2 {
+		t.Errorf("Unexpected most recent ScanRun: %#v", r)
+	} else if !r.Start.Equal(start.Add(time.Minute * time.Duration(last))) {
+		t.Errorf("Unexpected start time: %s", r.Start)
+	} else if r.Duration() != time.Millisecond*1500 {
+		t.Errorf("Unexpected duration: %s", r.Duration())
+	} else if runs[len(runs)-1].Dirs != 5 {
+		t.Errorf("The oldest ScanRuns were not removed: %#v", runs[len(runs)-1])
+	}
+
+	if runs, err = tdb.ScanRunGetByFolder(folder, 3); err != nil {
+		t.Fatalf("Cannot get ScanRuns: %s", err.Error())
+	} else if len(runs) != 3 {
+		t.Errorf("Unexpected number of ScanRuns: %d (expected 3)", len(runs))
+	}
+} // func TestScanRun(t *testing.T)
@@ -0,0 +1,427 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/database/backup.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 11. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-11 18:27:03 krylon>
+
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/blicero/blockbuster/common"
+	"github.com/blicero/blockbuster/logdomain"
+	"github.com/blicero/krylib"
+	"github.com/mattn/go-sqlite3"
+)
+
+// The library is curated by hand over the course of years, so losing it
+// would hurt. Backup uses SQLite's online backup API, so it is safe to call
+// while other connections are writing to the database.
+// On top of that, we keep rotating snapshots, daily, weekly, and monthly,
+// and we can restore the database from any of them.
+
+// ErrDatabaseInUse is returned when we try to restore the database while
+// there are still open connections to it.
+var ErrDatabaseInUse = errors.New("database is still in use")
+
+// openRaw opens a connection to the database at the given path, bypassing
+// database/sql, so we can get at the backup API.
+func openRaw(path string) (*sqlite3.SQLiteConn, error) {
+	var (
+		err  error
+		conn interface{}
+		drv  = &sqlite3.SQLiteDriver{}
+	)
+
+	if conn, err = drv.Open(path); err != nil {
+		return nil, err
+	}
+
+	return conn.(*sqlite3.SQLiteConn), nil
+} // func openRaw(path string) (*sqlite3.SQLiteConn, error)
+
+// Backup writes a consistent copy of the database to dest. The copy is
+// written to a temporary file first and renamed to dest once it is
+// complete, so dest is never left half-written.
+func (db *Database) Backup(dest string) error {
+	var (
+		err      error
+		done     bool
+		src, dst *sqlite3.SQLiteConn
+		bak      *sqlite3.SQLiteBackup
+		tmp      = dest + ".tmp"
+	)
+
+	if src, err = openRaw(db.path); err != nil {
+		db.log.Printf("[ERROR] Cannot open %s for backup: %s\n",
+			db.path,
+			err.Error())
+		return err
+	}
+
+	defer src.Close() // nolint: errcheck
+
+	if err = os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+		db.log.Printf("[ERROR] Cannot remove stale temporary file %s: %s\n",
+			tmp,
+			err.Error())
+		return err
+	} else if dst, err = openRaw(tmp); err != nil {
+		db.log.Printf("[ERROR] Cannot create backup file %s: %s\n",
+			tmp,
+			err.Error())
+		return err
+	}
+
+	defer func() {
+		if dst != nil {
+			dst.Close() // nolint: errcheck,gosec
+		}
+		if err != nil {
+			os.Remove(tmp) // nolint: errcheck,gosec
+		}
+	}()
+
+	if bak, err = dst.Backup("main", src, "main"); err != nil {
+		db.log.Printf("[ERROR] Cannot start backup of %s: %s\n",
+			db.path,
+			err.Error())
+		return err
+	}
+
+	// Step returns false without an error if the database is busy or
+	// locked, in which case we try again in a moment.
+	for !done {
+		if done, err = bak.Step(-1); err != nil {
+			db.log.Printf("[ERROR] Backup of %s to %s failed: %s\n",
+				db.path,
+				dest,
+				err.Error())
+			bak.Finish() // nolint: errcheck,gosec
+			return err
+		} else if !done {
+			waitForRetry()
+		}
+	}
+
+	if err = bak.Finish(); err != nil {
+		db.log.Printf("[ERROR] Cannot finish backup of %s: %s\n",
+			db.path,
+			err.Error())
+		return err
+	}
+
+	// The copy inherits the write-ahead log from the original. A snapshot
+	// should be a single, self-contained file, though.
+	if _, err = dst.Exec("PRAGMA journal_mode = DELETE", nil); err != nil {
+		db.log.Printf("[ERROR] Cannot switch journal mode of %s: %s\n",
+			tmp,
+			err.Error())
+		return err
+	} else if err = dst.Close(); err != nil {
+		dst = nil
+		db.log.Printf("[ERROR] Cannot close backup file %s: %s\n",
+			tmp,
+			err.Error())
+		return err
+	}
+
+	dst = nil
+
+	if err = os.Rename(tmp, dest); err != nil {
+		db.log.Printf("[ERROR] Cannot rename %s to %s: %s\n",
+			tmp,
+			dest,
+			err.Error())
+		return err
+	}
+
+	db.log.Printf("[INFO] Backed up database %s to %s\n",
+		db.path,
+		dest)
+
+	return nil
+} // func (db *Database) Backup(dest string) error
+
+// SnapshotKind tells how often a snapshot is taken.
+type SnapshotKind uint8
+
+// These are the kinds of snapshots we take.
+const (
+	SnapshotDaily SnapshotKind = iota
+	SnapshotWeekly
+	SnapshotMonthly
+)
+
+var snapshotKindNames = []string{
+	"daily",
+	"weekly",
+	"monthly",
+}
+
+func (k SnapshotKind) String() string {
+	if int(k) < len(snapshotKindNames) {
+		return snapshotKindNames[k]
+	}
+
+	return fmt.Sprintf("SnapshotKind(%d)", k)
+} // func (k SnapshotKind) String() string
+
+// snapshotPolicy says how often to take each kind of snapshot and how many
+// of them to keep.
+var snapshotPolicy = []struct {
+	kind     SnapshotKind
+	interval time.Duration
+	keep     int
+}{
+	{SnapshotDaily, time.Hour * 24, 7},
+	{SnapshotWeekly, time.Hour * 24 * 7, 4},
+	{SnapshotMonthly, time.Hour * 24 * 30, 12},
+}
+
+const snapshotTimeFormat = "20060102_150405"
+
+var snapshotPattern = regexp.MustCompile(`^` +
+	regexp.QuoteMeta(strings.ToLower(common.AppName)) +
+	`\.(daily|weekly|monthly)\.(\d{8}_\d{6})\.db$`)
+
+// Snapshot is a copy of the database taken at a certain point in time.
+type Snapshot struct {
+	Path string
+	Kind SnapshotKind
+	Time time.Time
+}
+
+func snapshotName(kind SnapshotKind, t time.Time) string {
+	return fmt.Sprintf("%s.%s.%s.db",
+		strings.ToLower(common.AppName),
+		kind,
+		t.Format(snapshotTimeFormat))
+} // func snapshotName(kind SnapshotKind, t time.Time) string
+
+// SnapshotList returns the snapshots in the given directory, newest first.
+func SnapshotList(dir string) ([]Snapshot, error) {
+	var (
+		err   error
+		dh    *os.File
+		names []string
+		list  []Snapshot
+	)
+
+	if dh, err = os.Open(dir); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	defer dh.Close() // nolint: errcheck
+
+	if names, err = dh.Readdirnames(-1); err != nil {
+		return nil, err
+	}
+
+	list = make([]Snapshot, 0, len(names))
+
+	for _, name := range names {
+		var (
+			match = snapshotPattern.FindStringSubmatch(name)
+			snap  = Snapshot{Path: filepath.Join(dir, name)}
+		)
+
+		if match == nil {
+			continue
+		} else if snap.Time, err = time.ParseInLocation(snapshotTimeFormat, match[2], time.Local); err != nil {
+			continue
+		}
+
+		for kind, kname := range snapshotKindNames {
+			if kname == match[1] {
+				snap.Kind = SnapshotKind(kind)
+				break
+			}
+		}
+
+		list = append(list, snap)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Time.After(list[j].Time) })
+
+	return list, nil
+} // func SnapshotList(dir string) ([]Snapshot, error)
+
+// SnapshotRotate takes all the snapshots that are due at the given time and
+// removes those that have expired. It returns the snapshots it has taken.
+func (db *Database) SnapshotRotate(dir string, now time.Time) ([]Snapshot, error) {
+	var (
+		err   error
+		list  []Snapshot
+		taken []Snapshot
+	)
+
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		db.log.Printf("[ERROR] Cannot create snapshot directory %s: %s\n",
+			dir,
+			err.Error())
+		return nil, err
+	} else if list, err = SnapshotList(dir); err != nil {
+		db.log.Printf("[ERROR] Cannot list snapshots in %s: %s\n",
+			dir,
+			err.Error())
+		return nil, err
+	}
+
+	for _, p := range snapshotPolicy {
+		var (
+			due  = true
+			snap = Snapshot{
+				Path: filepath.Join(dir, snapshotName(p.kind, now)),
+				Kind: p.kind,
+				Time: now,
+			}
+		)
+
+		for _, s := range list {
+			if s.Kind == p.kind {
+				due = now.Sub(s.Time) >= p.interval
+				break
+			}
+		}
+
+		if !due {
+			continue
+		}
+
+		// We only need to talk to the database once, if more than one
+		// snapshot is due, the others are copies of the first.
+		if len(taken) == 0 {
+			err = db.Backup(snap.Path)
+		} else {
+			err = krylib.CopyFile(taken[0].Path, snap.Path)
+		}
+
+		if err != nil {
+			db.log.Printf("[ERROR] Cannot take %s snapshot: %s\n",
+				p.kind,
+				err.Error())
+			return taken, err
+		}
+
+		taken = append(taken, snap)
+	}
+
+	list = append(taken, list...)
+
+	for _, p := range snapshotPolicy {
+		var cnt int
+
+		for _, s := range list {
+			if s.Kind != p.kind {
+				continue
+			} else if cnt++; cnt <= p.keep {
+				continue
+			} else if err = os.Remove(s.Path); err != nil {
+				db.log.Printf("[ERROR] Cannot remove expired snapshot %s: %s\n",
+					s.Path,
+					err.Error())
+				return taken, err
+			}
+
+			db.log.Printf("[DEBUG] Removed expired snapshot %s\n", s.Path)
+		}
+	}
+
+	return taken, nil
+} // func (db *Database) SnapshotRotate(dir string, now time.Time) ([]Snapshot, error)
+
+// CheckSnapshot makes sure the database at the given path is intact and
+// something we can open.
+func CheckSnapshot(path string) error {
+	var (
+		err     error
+		cnt     int
+		version int
+		result  string
+		snap    = &Database{path: path}
+	)
+
+	if snap.log, err = common.GetLogger(logdomain.Database); err != nil {
+		return err
+	} else if _, err = os.Stat(path); err != nil {
+		return err
+	} else if snap.db, err = sql.Open("sqlite3", path+"?mode=ro"); err != nil {
+		snap.log.Printf("[ERROR] Cannot open snapshot %s: %s\n",
+			path,
+			err.Error())
+		return err
+	}
+
+	defer snap.db.Close() // nolint: errcheck
+
+	if err = snap.db.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil {
+		snap.log.Printf("[ERROR] Cannot check integrity of snapshot %s: %s\n",
+			path,
+			err.Error())
+		return err
+	} else if result != "ok" {
+		snap.log.Printf("[ERROR] Snapshot %s is damaged: %s\n",
+			path,
+			result)
+		return fmt.Errorf("Snapshot %s is damaged: %s", path, result)
+	} else if err = snap.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'file'").Scan(&cnt); err != nil {
+		snap.log.Printf("[ERROR] Cannot look for file table in snapshot %s: %s\n",
+			path,
+			err.Error())
+		return err
+	} else if cnt == 0 {
+		return fmt.Errorf("%s is not a %s database", path, common.AppName)
+	} else if version, err = snap.SchemaVersion(); err != nil {
+		return err
+	} else if version > schemaVersion() {
+		return ErrSchemaTooNew
+	}
+
+	return nil
+} // func CheckSnapshot(path string) error
+
+// restoreFile replaces the database at path with a copy of the given
+// snapshot. The previous database is kept next to it.
+// There must not be any open connections to the database, which we
+// (roughly) check by looking for its write-ahead log.
+func restoreFile(snapshot, path string) error {
+	var (
+		err    error
+		exists bool
+		tmp    = path + ".restore"
+		old    = fmt.Sprintf("%s.%s.before_restore",
+			path,
+			time.Now().Format(snapshotTimeFormat))
+	)
+
+	if err = CheckSnapshot(snapshot); err != nil {
+		return err
+	} else if exists, err = krylib.Fexists(path + "-wal"); err != nil {
+		return err
+	} else if exists {
+		return ErrDatabaseInUse
+	} else if err = krylib.CopyFile(snapshot, tmp); err != nil {
+		return err
+	} else if err = os.Rename(path, old); err != nil {
+		os.Remove(tmp) // nolint: errcheck,gosec
+		return err
+	} else if err = os.Rename(tmp, path); err != nil {
+		os.Rename(old, path) // nolint: errcheck,gosec
+		return err
+	}
+
+	return nil
+} // func restoreFile(snapshot, path string) error
@@ -0,0 +1,570 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/database/collection.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 09. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-09 21:17:40 krylon>
+
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/blicero/blockbuster/database/query"
+	"github.com/blicero/blockbuster/objects"
+)
+
+// Smart collections are Conditions that are turned into SQL when we look at
+// them, so unlike all the other queries, the ones to fetch their members
+// cannot be prepared in advance.
+
+const qCondFiles = `
+SELECT
+    f.id,
+    f.folder_id,
+    f.path,
+    f.title,
+    f.year,
+    f.hidden,
+    f.episode_id,
+    f.fingerprint,
+    f.missing_since,
+    f.watched,
+    f.resume_pos
+FROM file f
+WHERE `
+
+const qCondOrder = `
+ORDER BY COALESCE(NULLIF(f.title, ''), f.path)
+`
+
+const qCondTag = `f.id IN (
+    WITH RECURSIVE subtree(id) AS (
+        SELECT ?
+        UNION
+        SELECT t.id FROM tag t INNER JOIN subtree s ON t.parent = s.id
+    )
+    SELECT l.file_id FROM tag_link l WHERE l.tag_id IN (SELECT id FROM subtree)
+)`
+
+// The query language of the search bars refers to Tags and People by name,
+// the aliases of People count, too.
+const qCondTagName = `f.id IN (
+    WITH RECURSIVE subtree(id) AS (
+        SELECT id FROM tag WHERE name = ? COLLATE NOCASE
+        UNION
+        SELECT t.id FROM tag t INNER JOIN subtree s ON t.parent = s.id
+    )
+    SELECT l.file_id FROM tag_link l WHERE l.tag_id IN (SELECT id FROM subtree)
+)`
+
+const qCondPersonName = `(
+    SELECT id FROM person WHERE name = ? COLLATE NOCASE
+    UNION
+    SELECT person_id FROM person_alias WHERE name = ? COLLATE NOCASE
+)`
+
+// likeEscape escapes the wildcards of SQL's LIKE operator, so s is matched
+// literally.
+var likeEscape = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// compileCondition turns a Condition into an SQL expression on the file
+// table, which is called f. The values for the placeholders in the
+// expression are appended to args.
+func compileCondition(c *objects.Condition, args *[]interface{}) (string, error) {
+	switch c.Kind {
+	case objects.CondAnd, objects.CondOr:
+		var (
+			op    = " AND "
+			parts = make([]string, len(c.Sub))
+		)
+
+		if c.Kind == objects.CondOr {
+			op = " OR "
+		}
+
+		if len(c.Sub) == 0 {
+			// An empty And is true, an empty Or is false, as usual.
+			if c.Kind == objects.CondAnd {
+				return "1", nil
+			}
+			return "0", nil
+		}
+
+		for i := range c.Sub {
+			var err error
+
+			if parts[i], err = compileCondition(&c.Sub[i], args); err != nil {
+				return "", err
+			}
+		}
+
+		return "(" + strings.Join(parts, op) + ")", nil
+
+	case objects.CondNot:
+		if len(c.Sub) != 1 {
+			return "", fmt.Errorf("Not needs exactly one Condition, not %d",
+				len(c.Sub))
+		}
+
+		var expr, err = compileCondition(&c.Sub[0], args)
+
+		if err != nil {
+			return "", err
+		}
+
+		return "(NOT " + expr + ")", nil
+
+	case objects.CondTag:
+		if c.ID == 0 && c.Text != "" {
+			*args = append(*args, c.Text)
+			return qCondTagName, nil
+		}
+
+		*args = append(*args, c.ID)
+		return qCondTag, nil
+
+	case objects.CondUntagged:
+		return "(NOT EXISTS (SELECT 1 FROM tag_link l WHERE l.file_id = f.id))", nil
+
+	case objects.CondPerson:
+		var person = "c.person_id = ?"
+
+		if c.ID == 0 && c.Text != "" {
+			person = "c.person_id IN " + qCondPersonName
+			*args = append(*args, c.Text, c.Text)
+		} else {
+			*args = append(*args, c.ID)
+		}
+
+		if c.Role != 0 {
+			*args = append(*args, c.Role)
+			return "(EXISTS (SELECT 1 FROM credit c WHERE c.file_id = f.id AND " + person + " AND c.role = ?))", nil
+		}
+		return "(EXISTS (SELECT 1 FROM credit c WHERE c.file_id = f.id AND " + person + "))", nil
+
+	case objects.CondYear:
+		if _, ok := cmpOps[c.Op]; !ok {
+			return "", fmt.Errorf("Invalid comparison for Year: %s", c.Op)
+		}
+
+		// Files without a year never match.
+		*args = append(*args, c.Value)
+		return "(f.year > 0 AND f.year " + c.Op.String() + " ?)", nil
+
+	case objects.CondAdded:
+		var year = c.Value
+
+		if _, ok := cmpOps[c.Op]; !ok {
+			return "", fmt.Errorf("Invalid comparison for Added: %s", c.Op)
+		} else if year == 0 {
+			year = int64(time.Now().Year())
+		}
+
+		*args = append(*args, year)
+		return "(f.added > 0 AND CAST(strftime('%Y', f.added, 'unixepoch', 'localtime') AS INTEGER) " +
+			c.Op.String() + " ?)", nil
+
+	case objects.CondWatched:
+		return "(f.watched <> 0)", nil
+
+	case objects.CondTitle:
+		var pattern = "%" + likeEscape.Replace(c.Text) + "%"
+
+		*args = append(*args, pattern, pattern)
+		return `(f.title LIKE ? ESCAPE '\' OR f.path LIKE ? ESCAPE '\')`, nil
+
+	default:
+		return "", fmt.Errorf("Unknown kind of Condition: %s", c.Kind)
+	}
+} // func compileCondition(c *objects.Condition, args *[]interface{}) (string, error)
+
+var cmpOps = map[objects.CmpOp]bool{
+	objects.OpEq: true,
+	objects.OpNe: true,
+	objects.OpLt: true,
+	objects.OpLe: true,
+	objects.OpGt: true,
+	objects.OpGe: true,
+}
+
+// FileGetByCondition returns all Files that match the given Condition.
+func (db *Database) FileGetByCondition(c *objects.Condition) ([]objects.File, error) {
+	var (
+		err  error
+		expr string
+		args []interface{}
+		rows *sql.Rows
+	)
+
+	if expr, err = compileCondition(c, &args); err != nil {
+		db.log.Printf("[ERROR] Cannot compile Condition: %s\n",
+			err.Error())
+		return nil, err
+	}
+
+	var q = qCondFiles + expr + qCondOrder
+
+EXEC_QUERY:
+	if db.tx != nil {
+		rows, err = db.tx.Query(q, args...)
+	} else {
+		rows, err = db.db.Query(q, args...)
+	}
+
+	if err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		db.log.Printf("[ERROR] Cannot query Files by Condition: %s\n%s\n",
+			err.Error(),
+			q)
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+
+	var list = make([]objects.File, 0, 32)
+
+	for rows.Next() {
+		var (
+			f       objects.File
+			title   *string
+			year    *int64
+			episode *int64
+			missing int64
+			resume  int64
+		)
+
+		if err = rows.Scan(&f.ID, &f.FolderID, &f.Path, &title, &year, &f.Hidden, &episode, &f.Fingerprint, &missing, &f.Watched, &resume); err != nil {
+			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
+			return nil, err
+		}
+
+		if title != nil {
+			f.Title = *title
+		}
+
+		if year != nil {
+			f.Year = *year
+		}
+
+		if episode != nil {
+			f.EpisodeID = *episode
+		}
+
+		if missing != 0 {
+			f.MissingSince = time.Unix(missing, 0)
+		}
+
+		f.ResumePosition = time.Duration(resume) * time.Millisecond
+		list = append(list, f)
+	}
+
+	return list, nil
+} // func (db *Database) FileGetByCondition(c *objects.Condition) ([]objects.File, error)
+
+// CollectionGetMembers returns the Files currently in the given Collection.
+func (db *Database) CollectionGetMembers(c *objects.Collection) ([]objects.File, error) {
+	return db.FileGetByCondition(&c.Cond)
+} // func (db *Database) CollectionGetMembers(c *objects.Collection) ([]objects.File, error)
+
+// CollectionAdd adds a new smart collection to the database.
+func (db *Database) CollectionAdd(name string, cond objects.Condition) (*objects.Collection, error) {
+	const qid query.ID = query.CollectionAdd
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+		raw    []byte
+	)
+
+	if _, err = compileCondition(&cond, new([]interface{})); err != nil {
+		db.log.Printf("[ERROR] Invalid Condition for Collection %s: %s\n",
+			name,
+			err.Error())
+		return nil, err
+	} else if raw, err = json.Marshal(&cond); err != nil {
+		db.log.Printf("[ERROR] Cannot serialize Condition for Collection %s: %s\n",
+			name,
+			err.Error())
+		return nil, err
+	} else if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return nil, errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	stmt = tx.Stmt(stmt)
+	var res sql.Result
+
+EXEC_QUERY:
+	if res, err = stmt.Exec(name, string(raw)); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		} else {
+			err = fmt.Errorf("Cannot add Collection %s to database: %s",
+				name,
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return nil, err
+		}
+	} else {
+		var id int64
+
+		if id, err = res.LastInsertId(); err != nil {
+			db.log.Printf("[ERROR] Cannot get ID of new Collection: %s\n",
+				err.Error())
+			return nil, err
+		}
+
+		status = true
+		return &objects.Collection{
+			ID:   id,
+			Name: name,
+			Cond: cond,
+		}, nil
+	}
+} // func (db *Database) CollectionAdd(name string, cond objects.Condition) (*objects.Collection, error)
+
+// CollectionUpdate saves the name and Condition of a Collection.
+func (db *Database) CollectionUpdate(c *objects.Collection) error {
+	const qid query.ID = query.CollectionUpdate
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+		raw    []byte
+	)
+
+	if _, err = compileCondition(&c.Cond, new([]interface{})); err != nil {
+		db.log.Printf("[ERROR] Invalid Condition for Collection %s: %s\n",
+			c.Name,
+			err.Error())
+		return err
+	} else if raw, err = json.Marshal(&c.Cond); err != nil {
+		db.log.Printf("[ERROR] Cannot serialize Condition for Collection %s: %s\n",
+			c.Name,
+			err.Error())
+		return err
+	} else if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	stmt = tx.Stmt(stmt)
+
+EXEC_QUERY:
+	if _, err = stmt.Exec(c.Name, string(raw), c.ID); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		} else {
+			err = fmt.Errorf("Cannot update Collection %s: %s",
+				c.Name,
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return err
+		}
+	}
+
+	status = true
+	return nil
+} // func (db *Database) CollectionUpdate(c *objects.Collection) error
+
+// CollectionDelete removes a Collection from the database. The Files in it
+// are not affected.
+func (db *Database) CollectionDelete(c *objects.Collection) error {
+	const qid query.ID = query.CollectionDelete
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	stmt = tx.Stmt(stmt)
+
+EXEC_QUERY:
+	if _, err = stmt.Exec(c.ID); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		} else {
+			err = fmt.Errorf("Cannot delete Collection %s: %s",
+				c.Name,
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return err
+		}
+	}
+
+	status = true
+	return nil
+} // func (db *Database) CollectionDelete(c *objects.Collection) error
+
+// CollectionGetAll loads all Collections, ordered by name.
+func (db *Database) CollectionGetAll() ([]objects.Collection, error) {
+	const qid query.ID = query.CollectionGetAll
+	var (
+		err  error
+		stmt *sql.Stmt
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+
+	var list = make([]objects.Collection, 0, 8)
+
+	for rows.Next() {
+		var (
+			c   objects.Collection
+			raw []byte
+		)
+
+		if err = rows.Scan(&c.ID, &c.Name, &raw); err != nil {
+			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
+			return nil, err
+		}
+
+		if err = json.Unmarshal(raw, &c.Cond); err != nil {
+			db.log.Printf("[ERROR] Cannot parse Condition of Collection %s: %s\n",
+				c.Name,
+				err.Error())
+			return nil, err
+		}
+
+		list = append(list, c)
+	}
+
+	return list, nil
+} // func (db *Database) CollectionGetAll() ([]objects.Collection, error)
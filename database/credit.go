@@ -0,0 +1,433 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/database/credit.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 06. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-06 20:48:31 krylon>
+
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/blicero/blockbuster/database/query"
+	"github.com/blicero/blockbuster/objects"
+)
+
+// CreditAdd credits a Person on a File in the given Role.
+func (db *Database) CreditAdd(f *objects.File, p *objects.Person, role objects.Role, character string, billing int64) (*objects.Credit, error) {
+	const qid query.ID = query.CreditAdd
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return nil, errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	stmt = tx.Stmt(stmt)
+	var res sql.Result
+
+EXEC_QUERY:
+	if res, err = stmt.Exec(f.ID, p.ID, role, character, billing); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		} else {
+			err = fmt.Errorf("Cannot add %s %s to Film %s: %s",
+				role,
+				p.Name,
+				f.DisplayTitle(),
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return nil, err
+		}
+	} else {
+		var id int64
+
+		if id, err = res.LastInsertId(); err != nil {
+			db.log.Printf("[ERROR] Cannot get ID of new Credit: %s\n",
+				err.Error())
+			return nil, err
+		}
+
+		status = true
+		return &objects.Credit{
+			ID:        id,
+			FileID:    f.ID,
+			PersonID:  p.ID,
+			Role:      role,
+			Character: character,
+			Billing:   billing,
+		}, nil
+	}
+} // func (db *Database) CreditAdd(f *objects.File, p *objects.Person, role objects.Role, character string, billing int64) (*objects.Credit, error)
+
+// CreditUpdate saves the character name and billing order of a Credit.
+func (db *Database) CreditUpdate(c *objects.Credit) error {
+	const qid query.ID = query.CreditUpdate
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	stmt = tx.Stmt(stmt)
+
+EXEC_QUERY:
+	if _, err = stmt.Exec(c.Character, c.Billing, c.ID); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		} else {
+			err = fmt.Errorf("Cannot update Credit %d: %s",
+				c.ID,
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return err
+		}
+	}
+
+	status = true
+	return nil
+} // func (db *Database) CreditUpdate(c *objects.Credit) error
+
+// CreditDelete removes a Person's Credit in the given Role from a File.
+func (db *Database) CreditDelete(f *objects.File, p *objects.Person, role objects.Role) error {
+	const qid query.ID = query.CreditDelete
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	stmt = tx.Stmt(stmt)
+
+EXEC_QUERY:
+	if _, err = stmt.Exec(f.ID, p.ID, role); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		} else {
+			err = fmt.Errorf("Cannot remove %s %s from Film %s: %s",
+				role,
+				p.Name,
+				f.DisplayTitle(),
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return err
+		}
+	}
+
+	status = true
+	return nil
+} // func (db *Database) CreditDelete(f *objects.File, p *objects.Person, role objects.Role) error
+
+// CreditGetByFile returns the full cast and crew of a File, grouped by Role
+// and in billing order within each Role.
+func (db *Database) CreditGetByFile(f *objects.File) ([]objects.CastMember, error) {
+	const qid query.ID = query.CreditGetByFile
+	var (
+		err  error
+		stmt *sql.Stmt
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(f.ID); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+
+	var cast = make([]objects.CastMember, 0, 16)
+
+	for rows.Next() {
+		var (
+			m      = objects.CastMember{Credit: objects.Credit{FileID: f.ID}}
+			bstamp int64
+		)
+
+		if err = rows.Scan(&m.Credit.ID, &m.Credit.PersonID, &m.Credit.Role, &m.Credit.Character, &m.Credit.Billing, &m.Person.Name, &bstamp); err != nil {
+			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
+			return nil, err
+		}
+
+		m.Person.ID = m.Credit.PersonID
+		if bstamp != 0 {
+			m.Person.Birthday = time.Unix(bstamp, 0)
+		}
+
+		cast = append(cast, m)
+	}
+
+	return cast, nil
+} // func (db *Database) CreditGetByFile(f *objects.File) ([]objects.CastMember, error)
+
+// CreditGetByPerson returns a Person's filmography, grouped by Role. Within
+// each Role, Files are ordered by year.
+func (db *Database) CreditGetByPerson(p *objects.Person) (objects.Filmography, error) {
+	const qid query.ID = query.CreditGetByPerson
+	var (
+		err  error
+		stmt *sql.Stmt
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(p.ID); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+
+	var films = make(objects.Filmography)
+
+	for rows.Next() {
+		var (
+			fc = objects.FilmCredit{Credit: objects.Credit{PersonID: p.ID}}
+		)
+
+		if err = rows.Scan(&fc.Credit.ID, &fc.Credit.FileID, &fc.Credit.Role, &fc.Credit.Character, &fc.Credit.Billing, &fc.File.FolderID, &fc.File.Path, &fc.File.Title, &fc.File.Year); err != nil {
+			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
+			return nil, err
+		}
+
+		fc.File.ID = fc.Credit.FileID
+
+		films[fc.Credit.Role] = append(films[fc.Credit.Role], fc)
+	}
+
+	return films, nil
+} // func (db *Database) CreditGetByPerson(p *objects.Person) (objects.Filmography, error)
+
+// creditPeople returns the People credited on a File in the given Role, in
+// billing order.
+func (db *Database) creditPeople(f *objects.File, role objects.Role) ([]objects.Person, error) {
+	var (
+		err    error
+		cast   []objects.CastMember
+		people []objects.Person
+	)
+
+	if cast, err = db.CreditGetByFile(f); err != nil {
+		return nil, err
+	}
+
+	people = make([]objects.Person, 0, len(cast))
+
+	for _, m := range cast {
+		if m.Credit.Role == role {
+			people = append(people, m.Person)
+		}
+	}
+
+	return people, nil
+} // func (db *Database) creditPeople(f *objects.File, role objects.Role) ([]objects.Person, error)
+
+// creditFiles returns the Files a Person is credited on in the given Role.
+func (db *Database) creditFiles(p *objects.Person, role objects.Role) ([]objects.File, error) {
+	var (
+		err   error
+		films objects.Filmography
+		files []objects.File
+	)
+
+	if films, err = db.CreditGetByPerson(p); err != nil {
+		return nil, err
+	}
+
+	files = make([]objects.File, len(films[role]))
+
+	for idx, fc := range films[role] {
+		files[idx] = fc.File
+	}
+
+	return files, nil
+} // func (db *Database) creditFiles(p *objects.Person, role objects.Role) ([]objects.File, error)
+
+// ActorAdd adds a Person to a File as an actor/actress.
+func (db *Database) ActorAdd(f *objects.File, p *objects.Person) error {
+	var _, err = db.CreditAdd(f, p, objects.RoleActor, "", 0)
+	return err
+} // func (db *Database) ActorAdd(f *objects.File, p *objects.Person) error
+
+// ActorDelete removes a Person from a Files "acting credits".
+func (db *Database) ActorDelete(f *objects.File, p *objects.Person) error {
+	return db.CreditDelete(f, p, objects.RoleActor)
+} // func (db *Database) ActorDelete(f *objects.File, p *objects.Person) error
+
+// ActorGetByPerson gets all the Files the given Person has acted in.
+func (db *Database) ActorGetByPerson(p *objects.Person) ([]objects.File, error) {
+	return db.creditFiles(p, objects.RoleActor)
+} // func (db *Database) ActorGetByPerson(p *objects.Person) ([]objects.File, error)
+
+// ActorGetByFile gets all the People that have acted in the given File.
+func (db *Database) ActorGetByFile(f *objects.File) ([]objects.Person, error) {
+	return db.creditPeople(f, objects.RoleActor)
+} // func (db *Database) ActorGetByFile(f *objects.File) ([]objects.Person, error)
+
+// DirectorAdd adds a Person to a File as its director.
+func (db *Database) DirectorAdd(f *objects.File, p *objects.Person) error {
+	var _, err = db.CreditAdd(f, p, objects.RoleDirector, "", 0)
+	return err
+} // func (db *Database) DirectorAdd(f *objects.File, p *objects.Person) error
+
+// DirectorDelete removes a Person from a File's directors.
+func (db *Database) DirectorDelete(f *objects.File, p *objects.Person) error {
+	return db.CreditDelete(f, p, objects.RoleDirector)
+} // func (db *Database) DirectorDelete(f *objects.File, p *objects.Person) error
+
+// DirectorGetByPerson gets all the Files the given Person has directed.
+func (db *Database) DirectorGetByPerson(p *objects.Person) ([]objects.File, error) {
+	return db.creditFiles(p, objects.RoleDirector)
+} // func (db *Database) DirectorGetByPerson(p *objects.Person) ([]objects.File, error)
+
+// DirectorGetByFile gets all the People that have directed the given File.
+func (db *Database) DirectorGetByFile(f *objects.File) ([]objects.Person, error) {
+	return db.creditPeople(f, objects.RoleDirector)
+} // func (db *Database) DirectorGetByFile(f *objects.File) ([]objects.Person, error)
@@ -16,6 +16,7 @@ import (
 	"net/url"
 	"os"
 	"regexp"
+	"strings"
 	"sync"
 	"time"
 
@@ -90,6 +91,10 @@ type Database struct {
 
 // Open opens a Database. If the database specified by the path does not exist,
 // yet, it is created and initialized.
+// If the database schema is outdated, it is migrated to the current version,
+// after a backup copy of the database file has been made. If the schema is
+// newer than what this version of the application knows about, Open returns
+// ErrSchemaTooNew.
 func Open(path string) (*Database, error) {
 	var (
 		err      error
@@ -126,6 +131,12 @@ func Open(path string) (*Database, error) {
 			path,
 			err.Error())
 		return nil, err
+	} else if err = db.checkFTS5(); err != nil {
+		db.db.Close() // nolint: errcheck,gosec
+		if !dbExists {
+			os.Remove(path) // nolint: errcheck,gosec
+		}
+		return nil, err
 	}
 
 	if !dbExists {
@@ -146,6 +157,19 @@ func Open(path string) (*Database, error) {
 			path)
 	}
 
+	if err = db.migrate(dbExists); err != nil {
+		db.log.Printf("[ERROR] Cannot migrate database %s: %s\n",
+			path,
+			err.Error())
+		if e2 := db.db.Close(); e2 != nil {
+			db.log.Printf("[CRITICAL] Failed to close database: %s\n",
+				e2.Error())
+		} else if !dbExists {
+			os.Remove(path) // nolint: errcheck,gosec
+		}
+		return nil, err
+	}
+
 	return db, nil
 } // func Open(path string) (*Database, error)
 
@@ -807,16 +831,18 @@ EXEC_QUERY:
 
 	for rows.Next() {
 		var (
-			f     objects.Folder
-			stamp int64
+			f                 objects.Folder
+			stamp             int64
+			suffixes, exclude string
 		)
 
-		if err = rows.Scan(&f.ID, &f.Path, &stamp); err != nil {
+		if err = rows.Scan(&f.ID, &f.Path, &stamp, &f.MinSize, &suffixes, &exclude); err != nil {
 			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
 			return nil, err
 		}
 
 		f.LastScan = time.Unix(stamp, 0)
+		f.Suffixes, f.Exclude = splitRules(suffixes, exclude)
 		list = append(list, f)
 	}
 
@@ -856,22 +882,109 @@ EXEC_QUERY:
 
 	if rows.Next() {
 		var (
-			f     = &objects.Folder{Path: path}
-			stamp int64
+			f                 = &objects.Folder{Path: path}
+			stamp             int64
+			suffixes, exclude string
 		)
 
-		if err = rows.Scan(&f.ID, &stamp); err != nil {
+		if err = rows.Scan(&f.ID, &stamp, &f.MinSize, &suffixes, &exclude); err != nil {
 			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
 			return nil, err
 		}
 
 		f.LastScan = time.Unix(stamp, 0)
+		f.Suffixes, f.Exclude = splitRules(suffixes, exclude)
 		return f, nil
 	}
 
 	return nil, nil
 } // func (db *Database) FolderGetByPath(path string) (*objects.Folder, error)
 
+// FolderUpdateRules sets the scan rules of a Folder.
+func (db *Database) FolderUpdateRules(f *objects.Folder, minSize int64, suffixes, exclude []string) error {
+	const qid query.ID = query.FolderUpdateRules
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	stmt = tx.Stmt(stmt)
+
+EXEC_QUERY:
+	if _, err = stmt.Exec(minSize, strings.Join(suffixes, " "), strings.Join(exclude, "\n"), f.ID); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		} else {
+			err = fmt.Errorf("Cannot update scan rules of Folder %s (%d): %s",
+				f.Path,
+				f.ID,
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return err
+		}
+	}
+
+	status = true
+	f.MinSize = minSize
+	f.Suffixes = suffixes
+	f.Exclude = exclude
+	return nil
+} // func (db *Database) FolderUpdateRules(f *objects.Folder, minSize int64, suffixes, exclude []string) error
+
+// splitRules turns the suffixes and exclude patterns of a Folder, as they
+// are stored in the database, back into lists.
+func splitRules(suffixes, exclude string) ([]string, []string) {
+	var patterns []string
+
+	for _, line := range strings.Split(exclude, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			patterns = append(patterns, line)
+		}
+	}
+
+	return strings.Fields(suffixes), patterns
+} // func splitRules(suffixes, exclude string) ([]string, []string)
+
 // FileAdd registers a File with the Database.
 func (db *Database) FileAdd(path string, folder *objects.Folder) (*objects.File, error) {
 	const qid query.ID = query.FileAdd
@@ -1021,69 +1134,83 @@ EXEC_QUERY:
 	return nil
 } // func (db *Database) FileRemove(f *objects.File) error
 
-// FileGetAll retrieves all registered Files from the Database.
-func (db *Database) FileGetAll() ([]objects.File, error) {
-	const qid query.ID = query.FileGetAll
-
+// FileSetMissing records that a File has gone missing at the given time.
+// Passing the zero time.Time marks the File as present again.
+func (db *Database) FileSetMissing(f *objects.File, stamp time.Time) error {
+	const qid query.ID = query.FileSetMissing
 	var (
-		err  error
-		stmt *sql.Stmt
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+		ts     int64
 	)
 
 	if stmt, err = db.getQuery(qid); err != nil {
 		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
-			qid,
+			qid.String(),
 			err.Error())
-		return nil, err
+		return err
 	} else if db.tx != nil {
-		stmt = db.tx.Stmt(stmt)
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
 	}
 
-	var rows *sql.Rows
+	stmt = tx.Stmt(stmt)
+
+	if !stamp.IsZero() {
+		ts = stamp.Unix()
+	}
 
 EXEC_QUERY:
-	if rows, err = stmt.Query(); err != nil {
+	if _, err = stmt.Exec(ts, f.ID); err != nil {
 		if worthARetry(err) {
 			waitForRetry()
 			goto EXEC_QUERY
+		} else {
+			err = fmt.Errorf("Cannot set missing timestamp of File %s: %s",
+				f.Path,
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return err
 		}
-
-		return nil, err
-	}
-
-	defer rows.Close() // nolint: errcheck,gosec
-
-	var list = make([]objects.File, 0, 256)
-
-	for rows.Next() {
-		var (
-			f     objects.File
-			title *string
-			year  *int64
-		)
-
-		if err = rows.Scan(&f.ID, &f.FolderID, &f.Path, &title, &year, &f.Hidden); err != nil {
-			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
-			return nil, err
-		}
-
-		if title != nil {
-			f.Title = *title
-		}
-
-		if year != nil {
-			f.Year = *year
-		}
-
-		list = append(list, f)
 	}
 
-	return list, nil
-} // func (db *Database) FileGetAll() ([]objects.File, error)
+	status = true
+	f.MissingSince = stamp
+	return nil
+} // func (db *Database) FileSetMissing(f *objects.File, stamp time.Time) error
 
-// FileGetByPath retrieves a File objects by its path in the file system.
-func (db *Database) FileGetByPath(path string) (*objects.File, error) {
-	const qid query.ID = query.FileGetByPath
+// FileGetByFolder returns all Files that belong to the given Folder.
+func (db *Database) FileGetByFolder(folder *objects.Folder) ([]objects.File, error) {
+	const qid query.ID = query.FileGetByFolder
 	var (
 		err  error
 		stmt *sql.Stmt
@@ -1101,7 +1228,7 @@ func (db *Database) FileGetByPath(path string) (*objects.File, error) {
 	var rows *sql.Rows
 
 EXEC_QUERY:
-	if rows, err = stmt.Query(path); err != nil {
+	if rows, err = stmt.Query(folder.ID); err != nil {
 		if worthARetry(err) {
 			waitForRetry()
 			goto EXEC_QUERY
@@ -1112,25 +1239,36 @@ EXEC_QUERY:
 
 	defer rows.Close() // nolint: errcheck,gosec
 
-	if rows.Next() {
+	var files = make([]objects.File, 0, 256)
+
+	for rows.Next() {
 		var (
-			f = &objects.File{Path: path}
+			f                  = objects.File{FolderID: folder.ID}
+			missing            int64
+			size, mtime, inode int64
 		)
 
-		if err = rows.Scan(&f.ID, &f.FolderID, &f.Title, &f.Year, &f.Hidden); err != nil {
+		if err = rows.Scan(&f.ID, &f.Path, &f.Title, &f.Year, &f.Hidden, &missing, &f.Fingerprint, &size, &mtime, &inode); err != nil {
 			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
 			return nil, err
 		}
 
-		return f, nil
+		f.Stat = fileStat(size, mtime, inode)
+
+		if missing != 0 {
+			f.MissingSince = time.Unix(missing, 0)
+		}
+
+		files = append(files, f)
 	}
 
-	return nil, nil
-} // func (db *Database) FileGetByPath(path string) (*objects.File, error)
+	return files, nil
+} // func (db *Database) FileGetByFolder(folder *objects.Folder) ([]objects.File, error)
 
-// FileGetByID loads a File by its ID.
-func (db *Database) FileGetByID(id int64) (*objects.File, error) {
-	const qid query.ID = query.FileGetByID
+// FileGetMissing returns all Files that have gone missing, the ones that
+// have been missing the longest come first.
+func (db *Database) FileGetMissing() ([]objects.File, error) {
+	const qid query.ID = query.FileGetMissing
 	var (
 		err  error
 		stmt *sql.Stmt
@@ -1148,7 +1286,7 @@ func (db *Database) FileGetByID(id int64) (*objects.File, error) {
 	var rows *sql.Rows
 
 EXEC_QUERY:
-	if rows, err = stmt.Query(id); err != nil {
+	if rows, err = stmt.Query(); err != nil {
 		if worthARetry(err) {
 			waitForRetry()
 			goto EXEC_QUERY
@@ -1159,39 +1297,44 @@ EXEC_QUERY:
 
 	defer rows.Close() // nolint: errcheck,gosec
 
-	if rows.Next() {
+	var files = make([]objects.File, 0, 16)
+
+	for rows.Next() {
 		var (
-			f = &objects.File{ID: id}
+			f       objects.File
+			missing int64
 		)
 
-		if err = rows.Scan(&f.FolderID, &f.Path, &f.Title, &f.Year, &f.Hidden); err != nil {
+		if err = rows.Scan(&f.ID, &f.FolderID, &f.Path, &f.Title, &f.Year, &f.Hidden, &missing); err != nil {
 			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
 			return nil, err
 		}
 
-		return f, nil
+		f.MissingSince = time.Unix(missing, 0)
+
+		files = append(files, f)
 	}
 
-	return nil, nil
-} // func (db *Database) FileGetByID(id int64) (*objects.File, error)
+	return files, nil
+} // func (db *Database) FileGetMissing() ([]objects.File, error)
 
-// FileUpdateTitle sets the title of a File.
-func (db *Database) FileUpdateTitle(f *objects.File, title string) error {
-	const qid query.ID = query.FileUpdateTitle
+// FilePurge removes a File from the Database, along with its Tags, Links,
+// acting and directing credits.
+func (db *Database) FilePurge(f *objects.File) error {
 	var (
 		err    error
 		msg    string
-		stmt   *sql.Stmt
 		tx     *sql.Tx
 		status bool
+		qlist  = []query.ID{
+			query.FilePurgeTags,
+			query.FilePurgeCredits,
+			query.FilePurgeURLs,
+			query.FileRemove,
+		}
 	)
 
-	if stmt, err = db.getQuery(qid); err != nil {
-		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
-			qid.String(),
-			err.Error())
-		return err
-	} else if db.tx != nil {
+	if db.tx != nil {
 		tx = db.tx
 	} else {
 	BEGIN_AD_HOC:
@@ -1222,61 +1365,1223 @@ func (db *Database) FileUpdateTitle(f *objects.File, title string) error {
 		}
 	}
 
-	stmt = tx.Stmt(stmt)
+	for _, qid := range qlist {
+		var stmt *sql.Stmt
 
-EXEC_QUERY:
-	if _, err = stmt.Exec(title, f.ID); err != nil {
-		if worthARetry(err) {
-			waitForRetry()
-			goto EXEC_QUERY
-		} else {
-			err = fmt.Errorf("Cannot set Title of File %q (%d) to %q: %s",
-				f.DisplayTitle(),
-				f.ID,
-				title,
+		if stmt, err = db.getQuery(qid); err != nil {
+			db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+				qid.String(),
 				err.Error())
-			db.log.Printf("[ERROR] %s\n", err.Error())
 			return err
 		}
+
+		stmt = tx.Stmt(stmt)
+
+	EXEC_QUERY:
+		if _, err = stmt.Exec(f.ID); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto EXEC_QUERY
+			} else {
+				err = fmt.Errorf("Cannot purge File %s (%d) from database (%s): %s",
+					f.Path,
+					f.ID,
+					qid.String(),
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", err.Error())
+				return err
+			}
+		}
 	}
 
 	status = true
-	f.Title = title
 	return nil
-} // func (db *Database) FileUpdateTitle(f *objects.File, title string) error
+} // func (db *Database) FilePurge(f *objects.File) error
+
+// FileGetAll retrieves all registered Files from the Database.
+func (db *Database) FileGetAll() ([]objects.File, error) {
+	const qid query.ID = query.FileGetAll
 
-// FileUpdateYear sets the year of a File
-func (db *Database) FileUpdateYear(f *objects.File, year int64) error {
-	const qid query.ID = query.FileUpdateYear
 	var (
-		err    error
-		msg    string
-		stmt   *sql.Stmt
-		tx     *sql.Tx
-		status bool
+		err  error
+		stmt *sql.Stmt
 	)
 
 	if stmt, err = db.getQuery(qid); err != nil {
 		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
-			qid.String(),
+			qid,
 			err.Error())
-		return err
+		return nil, err
 	} else if db.tx != nil {
-		tx = db.tx
-	} else {
-	BEGIN_AD_HOC:
-		if tx, err = db.db.Begin(); err != nil {
-			if worthARetry(err) {
-				waitForRetry()
-				goto BEGIN_AD_HOC
-			} else {
-				msg = fmt.Sprintf("Error starting transaction: %s\n",
-					err.Error())
-				db.log.Printf("[ERROR] %s\n", msg)
-				return errors.New(msg)
-			}
+		stmt = db.tx.Stmt(stmt)
+	}
 
-		} else {
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+
+	var list = make([]objects.File, 0, 256)
+
+	for rows.Next() {
+		var (
+			f       objects.File
+			title   *string
+			year    *int64
+			episode *int64
+			missing int64
+			resume  int64
+		)
+
+		if err = rows.Scan(&f.ID, &f.FolderID, &f.Path, &title, &year, &f.Hidden, &episode, &f.Fingerprint, &missing, &f.Watched, &resume); err != nil {
+			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
+			return nil, err
+		}
+
+		if title != nil {
+			f.Title = *title
+		}
+
+		if year != nil {
+			f.Year = *year
+		}
+
+		if episode != nil {
+			f.EpisodeID = *episode
+		}
+
+		if missing != 0 {
+			f.MissingSince = time.Unix(missing, 0)
+		}
+
+		f.ResumePosition = time.Duration(resume) * time.Millisecond
+		list = append(list, f)
+	}
+
+	return list, nil
+} // func (db *Database) FileGetAll() ([]objects.File, error)
+
+// FileGetByPath retrieves a File objects by its path in the file system.
+func (db *Database) FileGetByPath(path string) (*objects.File, error) {
+	const qid query.ID = query.FileGetByPath
+	var (
+		err  error
+		stmt *sql.Stmt
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(path); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+
+	if rows.Next() {
+		var (
+			f                  = &objects.File{Path: path}
+			episode            *int64
+			missing            int64
+			resume             int64
+			size, mtime, inode int64
+		)
+
+		if err = rows.Scan(&f.ID, &f.FolderID, &f.Title, &f.Year, &f.Hidden, &episode, &f.Fingerprint, &missing, &f.Watched, &resume, &size, &mtime, &inode); err != nil {
+			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
+			return nil, err
+		}
+
+		f.Stat = fileStat(size, mtime, inode)
+
+		if episode != nil {
+			f.EpisodeID = *episode
+		}
+
+		if missing != 0 {
+			f.MissingSince = time.Unix(missing, 0)
+		}
+
+		f.ResumePosition = time.Duration(resume) * time.Millisecond
+		return f, nil
+	}
+
+	return nil, nil
+} // func (db *Database) FileGetByPath(path string) (*objects.File, error)
+
+// FileGetByID loads a File by its ID.
+func (db *Database) FileGetByID(id int64) (*objects.File, error) {
+	const qid query.ID = query.FileGetByID
+	var (
+		err  error
+		stmt *sql.Stmt
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(id); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+
+	if rows.Next() {
+		var (
+			f       = &objects.File{ID: id}
+			episode *int64
+			missing int64
+			resume  int64
+		)
+
+		if err = rows.Scan(&f.FolderID, &f.Path, &f.Title, &f.Year, &f.Hidden, &episode, &f.Fingerprint, &missing, &f.Watched, &resume); err != nil {
+			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
+			return nil, err
+		}
+
+		if episode != nil {
+			f.EpisodeID = *episode
+		}
+
+		if missing != 0 {
+			f.MissingSince = time.Unix(missing, 0)
+		}
+
+		f.ResumePosition = time.Duration(resume) * time.Millisecond
+		return f, nil
+	}
+
+	return nil, nil
+} // func (db *Database) FileGetByID(id int64) (*objects.File, error)
+
+// FileUpdateTitle sets the title of a File.
+func (db *Database) FileUpdateTitle(f *objects.File, title string) error {
+	const qid query.ID = query.FileUpdateTitle
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	stmt = tx.Stmt(stmt)
+
+EXEC_QUERY:
+	if _, err = stmt.Exec(title, f.ID); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		} else {
+			err = fmt.Errorf("Cannot set Title of File %q (%d) to %q: %s",
+				f.DisplayTitle(),
+				f.ID,
+				title,
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return err
+		}
+	}
+
+	status = true
+	f.Title = title
+	return nil
+} // func (db *Database) FileUpdateTitle(f *objects.File, title string) error
+
+// FileUpdateYear sets the year of a File
+func (db *Database) FileUpdateYear(f *objects.File, year int64) error {
+	const qid query.ID = query.FileUpdateYear
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	stmt = tx.Stmt(stmt)
+
+EXEC_QUERY:
+	if _, err = stmt.Exec(year, f.ID); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		} else {
+			err = fmt.Errorf("Cannot set Year of File %q (%d) to %d: %s",
+				f.DisplayTitle(),
+				f.ID,
+				year,
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return err
+		}
+	}
+
+	status = true
+	f.Year = year
+	return nil
+} // func (db *Database) FileUpdateYear(f *objects.File, year int64) error
+
+// FileSetFingerprint stores the fingerprint of the File's content.
+func (db *Database) FileSetFingerprint(f *objects.File, fp string) error {
+	const qid query.ID = query.FileSetFingerprint
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	stmt = tx.Stmt(stmt)
+
+EXEC_QUERY:
+	if _, err = stmt.Exec(fp, f.ID); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		} else {
+			err = fmt.Errorf("Cannot set fingerprint of File %s to %s: %s",
+				f.DisplayTitle(),
+				fp,
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return err
+		}
+	}
+
+	status = true
+	f.Fingerprint = fp
+	return nil
+} // func (db *Database) FileSetFingerprint(f *objects.File, fp string) error
+
+// FileMove updates the path and Folder of a File, e.g. after the file has
+// been moved or renamed. If the File was marked as missing, it no longer is.
+func (db *Database) FileMove(f *objects.File, path string, folder *objects.Folder) error {
+	const qid query.ID = query.FileMove
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	stmt = tx.Stmt(stmt)
+
+EXEC_QUERY:
+	if _, err = stmt.Exec(path, folder.ID, f.ID); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		} else {
+			err = fmt.Errorf("Cannot move File %s to %s: %s",
+				f.Path,
+				path,
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return err
+		}
+	}
+
+	status = true
+	f.Path = path
+	f.FolderID = folder.ID
+	f.MissingSince = time.Time{}
+	return nil
+} // func (db *Database) FileMove(f *objects.File, path string, folder *objects.Folder) error
+
+// FileGetByFingerprint returns all Files with the given fingerprint.
+func (db *Database) FileGetByFingerprint(fp string) ([]objects.File, error) {
+	const qid query.ID = query.FileGetByFingerprint
+	var (
+		err  error
+		stmt *sql.Stmt
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(fp); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+
+	var files = make([]objects.File, 0, 1)
+
+	for rows.Next() {
+		var (
+			f = objects.File{Fingerprint: fp}
+		)
+
+		if err = rows.Scan(&f.ID, &f.FolderID, &f.Path, &f.Title, &f.Year, &f.Hidden); err != nil {
+			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
+			return nil, err
+		}
+
+		files = append(files, f)
+	}
+
+	return files, nil
+} // func (db *Database) FileGetByFingerprint(fp string) ([]objects.File, error)
+
+// TagAdd adds a new Tag to the Database.
+func (db *Database) TagAdd(name string) (*objects.Tag, error) {
+	const qid query.ID = query.TagAdd
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return nil, errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	stmt = tx.Stmt(stmt)
+	var res sql.Result
+
+EXEC_QUERY:
+	if res, err = stmt.Exec(name); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		} else {
+			err = fmt.Errorf("Cannot add Tag %s to database: %s",
+				name,
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return nil, err
+		}
+	} else {
+		var tagID int64
+
+		if tagID, err = res.LastInsertId(); err != nil {
+			db.log.Printf("[ERROR] Cannot get ID of new Feed %s: %s\n",
+				name,
+				err.Error())
+			return nil, err
+		}
+
+		status = true
+		return &objects.Tag{
+			ID:   tagID,
+			Name: name,
+		}, nil
+	}
+} // func (db *Database) TagAdd(name string) (*objects.Tag, error)
+
+// TagDelete removes a Tag from the Database.
+// NB, all the links pointing to the Tag have to be deleted before the Tag itself can be
+// deleted.
+func (db *Database) TagDelete(t *objects.Tag) error {
+	const qid query.ID = query.TagDelete
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	stmt = tx.Stmt(stmt)
+
+EXEC_QUERY:
+	if _, err = stmt.Exec(t.ID); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		} else {
+			err = fmt.Errorf("Cannot delete Tag %s from database: %s",
+				t.Name,
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return err
+		}
+	}
+
+	status = true
+	return nil
+} // func (db *Database) TagDelete(t *objects.Tag) error
+
+// TagGetAll fetches all Tags from the Database.
+func (db *Database) TagGetAll() ([]objects.Tag, error) {
+	const qid query.ID = query.TagGetAll
+	var (
+		err  error
+		stmt *sql.Stmt
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+
+	var list = make([]objects.Tag, 0, 32)
+
+	for rows.Next() {
+		var (
+			t objects.Tag
+		)
+
+		if err = rows.Scan(&t.ID, &t.Name, &t.Parent); err != nil {
+			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
+			return nil, err
+		}
+
+		list = append(list, t)
+	}
+
+	return list, nil
+} // func (db *Database) TagGetAll() ([]objects.Tag, error)
+
+// TagLinkAdd links the given Tag to the given File
+func (db *Database) TagLinkAdd(f *objects.File, t *objects.Tag) error {
+	const qid query.ID = query.TagLinkAdd
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	stmt = tx.Stmt(stmt)
+
+EXEC_QUERY:
+	if _, err = stmt.Exec(f.ID, t.ID); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		} else {
+			err = fmt.Errorf("Cannot add Tag %s to File %s: %s",
+				t.Name,
+				f.DisplayTitle(),
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return err
+		}
+	}
+
+	status = true
+	return nil
+} // func (db *Database) TagLinkAdd(f *objects.File, t *objects.Tag) error
+
+// TagLinkDelete removes the link between the given Tag and File.
+func (db *Database) TagLinkDelete(f *objects.File, t *objects.Tag) error {
+	const qid query.ID = query.TagLinkDelete
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	stmt = tx.Stmt(stmt)
+
+EXEC_QUERY:
+	if _, err = stmt.Exec(f.ID, t.ID); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		} else {
+			err = fmt.Errorf("Cannot remove link of Tag %s to File %s: %s",
+				t.Name,
+				f.DisplayTitle(),
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return err
+		}
+	}
+
+	status = true
+	return nil
+} // func (db *Database) TagLinkDelete(f *objects.File, t *objects.Tag) error
+
+// TagLinkGetByTag fetches all Files linked to the given Tag or any of its
+// descendants.
+func (db *Database) TagLinkGetByTag(t *objects.Tag) ([]objects.File, error) {
+	const qid query.ID = query.TagLinkGetByTag
+	var (
+		err  error
+		stmt *sql.Stmt
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(t.ID); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+
+	var files = make([]objects.File, 0, 10)
+
+	for rows.Next() {
+		var (
+			f objects.File
+		)
+
+		if err = rows.Scan(&f.ID, &f.FolderID, &f.Path, &f.Title, &f.Year); err != nil {
+			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
+			return nil, err
+		}
+
+		files = append(files, f)
+	}
+
+	return files, nil
+} // func (db *Database) TagLinkGetByTag(t *objects.Tag) ([]objects.File, error)
+
+// TagLinkGetByFile loads all Tags linked to the given File.
+func (db *Database) TagLinkGetByFile(f *objects.File) (map[int64]objects.Tag, error) {
+	const qid query.ID = query.TagLinkGetByFile
+	var (
+		err  error
+		stmt *sql.Stmt
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(f.ID); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+
+	var tags = make(map[int64]objects.Tag)
+
+	for rows.Next() {
+		var (
+			t objects.Tag
+		)
+
+		if err = rows.Scan(&t.ID, &t.Name); err != nil {
+			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
+			return nil, err
+		}
+
+		tags[t.ID] = t
+	}
+
+	return tags, nil
+} // func (db *Database) TagLinkGetByFile(f *objects.File) (map[int64]objects.Tag, error)
+
+// PersonAdd adds a new Person to the database.
+func (db *Database) PersonAdd(name string, birthday time.Time) (*objects.Person, error) {
+	const qid query.ID = query.PersonAdd
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return nil, errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	stmt = tx.Stmt(stmt)
+	var (
+		res    sql.Result
+		bstamp int64
+	)
+
+	if birthday.IsZero() {
+		bstamp = 0
+	} else {
+		bstamp = birthday.Unix()
+	}
+
+EXEC_QUERY:
+	if res, err = stmt.Exec(name, bstamp); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		} else {
+			err = fmt.Errorf("Cannot add Tag %s to database: %s",
+				name,
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return nil, err
+		}
+	} else {
+		var id int64
+
+		if id, err = res.LastInsertId(); err != nil {
+			db.log.Printf("[ERROR] Cannot get ID of new Feed %s: %s\n",
+				name,
+				err.Error())
+			return nil, err
+		}
+
+		status = true
+		return &objects.Person{
+			ID:       id,
+			Name:     name,
+			Birthday: birthday,
+		}, nil
+	}
+} // func (db *Database) PersonAdd(name string, birthday time.Time) (*objects.Person, error)
+
+// PersonGetAll loads all Persons from the Database, in no particular order.
+func (db *Database) PersonGetAll() ([]objects.Person, error) {
+	const qid query.ID = query.PersonGetAll
+	var (
+		err  error
+		stmt *sql.Stmt
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+
+	var list = make([]objects.Person, 0, 32)
+
+	for rows.Next() {
+		var (
+			p      objects.Person
+			bstamp int64
+		)
+
+		if err = rows.Scan(&p.ID, &p.Name, &bstamp); err != nil {
+			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
+			return nil, err
+		}
+
+		if bstamp != 0 {
+			p.Birthday = time.Unix(bstamp, 0)
+		}
+
+		list = append(list, p)
+	}
+
+	return list, nil
+} // func (db *Database) PersonGetAll() ([]objects.Person, error)
+
+// PersonGetByID looks up a Person by their ID.
+func (db *Database) PersonGetByID(id int64) (*objects.Person, error) {
+	const qid query.ID = query.PersonGetByID
+	var (
+		err  error
+		stmt *sql.Stmt
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(id); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+
+	if rows.Next() {
+		var (
+			p      = &objects.Person{ID: id}
+			bstamp int64
+		)
+
+		if err = rows.Scan(&p.Name, &bstamp); err != nil {
+			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
+			return nil, err
+		}
+
+		if bstamp != 0 {
+			p.Birthday = time.Unix(bstamp, 0)
+		}
+
+		return p, nil
+	}
+
+	return nil, nil
+} // func (db *Database) PersonGetByID(id int64) (*objects.Person, error)
+
+// PersonURLAdd attaches a Link to a Person.
+func (db *Database) PersonURLAdd(p *objects.Person, l *objects.Link) error {
+	const qid query.ID = query.PersonURLAdd
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
 			defer func() {
 				var err2 error
 				if status {
@@ -1293,31 +2598,39 @@ func (db *Database) FileUpdateYear(f *objects.File, year int64) error {
 	}
 
 	stmt = tx.Stmt(stmt)
+	var (
+		res sql.Result
+		id  int64
+	)
 
 EXEC_QUERY:
-	if _, err = stmt.Exec(year, f.ID); err != nil {
+	if res, err = stmt.Exec(p.ID, l.URL.String(), l.Title, l.Description); err != nil {
 		if worthARetry(err) {
 			waitForRetry()
 			goto EXEC_QUERY
 		} else {
-			err = fmt.Errorf("Cannot set Year of File %q (%d) to %d: %s",
-				f.DisplayTitle(),
-				f.ID,
-				year,
+			err = fmt.Errorf("Cannot add Link %s to Person %s: %s",
+				l.DisplayTitle(),
+				p.Name,
 				err.Error())
 			db.log.Printf("[ERROR] %s\n", err.Error())
 			return err
 		}
+	} else if id, err = res.LastInsertId(); err != nil {
+		db.log.Printf("[ERROR] Cannot get ID of newly added Link %s: %s\n",
+			l.DisplayTitle(),
+			err.Error())
+		return err
 	}
 
+	l.ID = id
 	status = true
-	f.Year = year
 	return nil
-} // func (db *Database) FileUpdateYear(f *objects.File, year int64) error
+} // func (db *Database) PersonURLAdd(p *objects.Person, l *objects.Link) error
 
-// TagAdd adds a new Tag to the Database.
-func (db *Database) TagAdd(name string) (*objects.Tag, error) {
-	const qid query.ID = query.TagAdd
+// PersonURLDelete deletes a Link that has been attached to a Person
+func (db *Database) PersonURLDelete(l *objects.Link) error {
+	const qid query.ID = query.PersonURLDelete
 	var (
 		err    error
 		msg    string
@@ -1330,7 +2643,7 @@ func (db *Database) TagAdd(name string) (*objects.Tag, error) {
 		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
 			qid.String(),
 			err.Error())
-		return nil, err
+		return err
 	} else if db.tx != nil {
 		tx = db.tx
 	} else {
@@ -1343,7 +2656,7 @@ func (db *Database) TagAdd(name string) (*objects.Tag, error) {
 				msg = fmt.Sprintf("Error starting transaction: %s\n",
 					err.Error())
 				db.log.Printf("[ERROR] %s\n", msg)
-				return nil, errors.New(msg)
+				return errors.New(msg)
 			}
 
 		} else {
@@ -1363,43 +2676,83 @@ func (db *Database) TagAdd(name string) (*objects.Tag, error) {
 	}
 
 	stmt = tx.Stmt(stmt)
-	var res sql.Result
 
 EXEC_QUERY:
-	if res, err = stmt.Exec(name); err != nil {
+	if _, err = stmt.Exec(l.ID); err != nil {
 		if worthARetry(err) {
 			waitForRetry()
 			goto EXEC_QUERY
 		} else {
-			err = fmt.Errorf("Cannot add Tag %s to database: %s",
-				name,
+			err = fmt.Errorf("Cannot delete Link %s: %s",
+				l.DisplayTitle(),
 				err.Error())
 			db.log.Printf("[ERROR] %s\n", err.Error())
-			return nil, err
+			return err
 		}
-	} else {
-		var tagID int64
+	}
 
-		if tagID, err = res.LastInsertId(); err != nil {
-			db.log.Printf("[ERROR] Cannot get ID of new Feed %s: %s\n",
-				name,
+	status = true
+	return nil
+} // func (db *Database) PersonURLDelete(l *objects.Link) error
+
+// PersonURLGetByPerson returns all Links attached to the given Person.
+func (db *Database) PersonURLGetByPerson(p *objects.Person) ([]objects.Link, error) {
+	const qid query.ID = query.PersonURLGetByPerson
+	var (
+		err  error
+		stmt *sql.Stmt
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(p.ID); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+
+	var links = make([]objects.Link, 0, 4)
+
+	for rows.Next() {
+		var (
+			l    objects.Link
+			ustr string
+		)
+
+		if err = rows.Scan(&l.ID, &ustr, &l.Title, &l.Description); err != nil {
+			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
+			return nil, err
+		} else if l.URL, err = url.Parse(ustr); err != nil {
+			db.log.Printf("[ERROR] Cannot parse URL %q: %s\n",
+				ustr,
 				err.Error())
 			return nil, err
 		}
 
-		status = true
-		return &objects.Tag{
-			ID:   tagID,
-			Name: name,
-		}, nil
+		links = append(links, l)
 	}
-} // func (db *Database) TagAdd(name string) (*objects.Tag, error)
 
-// TagDelete removes a Tag from the Database.
-// NB, all the links pointing to the Tag have to be deleted before the Tag itself can be
-// deleted.
-func (db *Database) TagDelete(t *objects.Tag) error {
-	const qid query.ID = query.TagDelete
+	return links, nil
+} // func (db *Database) PersonURLGetByPerson(p *objects.Person) ([]objects.Link, error)
+
+// FileURLAdd attaches a Link to a File.
+func (db *Database) FileURLAdd(f *objects.File, l *objects.Link) error {
+	const qid query.ID = query.FileURLAdd
 	var (
 		err    error
 		msg    string
@@ -1445,28 +2798,106 @@ func (db *Database) TagDelete(t *objects.Tag) error {
 	}
 
 	stmt = tx.Stmt(stmt)
+	var (
+		res sql.Result
+		id  int64
+	)
 
 EXEC_QUERY:
-	if _, err = stmt.Exec(t.ID); err != nil {
+	if res, err = stmt.Exec(f.ID, l.URL.String(), l.Title, l.Description); err != nil {
 		if worthARetry(err) {
 			waitForRetry()
 			goto EXEC_QUERY
 		} else {
-			err = fmt.Errorf("Cannot delete Tag %s from database: %s",
-				t.Name,
+			err = fmt.Errorf("Cannot add Link %s to File %s: %s",
+				l.DisplayTitle(),
+				f.DisplayTitle(),
 				err.Error())
 			db.log.Printf("[ERROR] %s\n", err.Error())
 			return err
 		}
+	} else if id, err = res.LastInsertId(); err != nil {
+		db.log.Printf("[ERROR] Cannot get ID of newly added Link %s: %s\n",
+			l.DisplayTitle(),
+			err.Error())
+		return err
 	}
 
+	l.ID = id
 	status = true
 	return nil
-} // func (db *Database) TagDelete(t *objects.Tag) error
+} // func (db *Database) FileURLAdd(f *objects.File, l *objects.Link) error
 
-// TagGetAll fetches all Tags from the Database.
-func (db *Database) TagGetAll() ([]objects.Tag, error) {
-	const qid query.ID = query.TagGetAll
+// FileURLDelete deletes a Link that has been attached to a File
+func (db *Database) FileURLDelete(l *objects.Link) error {
+	const qid query.ID = query.FileURLDelete
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	stmt = tx.Stmt(stmt)
+
+EXEC_QUERY:
+	if _, err = stmt.Exec(l.ID); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		} else {
+			err = fmt.Errorf("Cannot delete Link %s: %s",
+				l.DisplayTitle(),
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return err
+		}
+	}
+
+	status = true
+	return nil
+} // func (db *Database) FileURLDelete(l *objects.Link) error
+
+// FileURLGetByFile returns all Links attached to the given File.
+func (db *Database) FileURLGetByFile(f *objects.File) ([]objects.Link, error) {
+	const qid query.ID = query.FileURLGetByFile
 	var (
 		err  error
 		stmt *sql.Stmt
@@ -1484,7 +2915,7 @@ func (db *Database) TagGetAll() ([]objects.Tag, error) {
 	var rows *sql.Rows
 
 EXEC_QUERY:
-	if rows, err = stmt.Query(); err != nil {
+	if rows, err = stmt.Query(f.ID); err != nil {
 		if worthARetry(err) {
 			waitForRetry()
 			goto EXEC_QUERY
@@ -1495,27 +2926,33 @@ EXEC_QUERY:
 
 	defer rows.Close() // nolint: errcheck,gosec
 
-	var list = make([]objects.Tag, 0, 32)
+	var links = make([]objects.Link, 0, 4)
 
 	for rows.Next() {
 		var (
-			t objects.Tag
+			l    objects.Link
+			ustr string
 		)
 
-		if err = rows.Scan(&t.ID, &t.Name); err != nil {
+		if err = rows.Scan(&l.ID, &ustr, &l.Title, &l.Description); err != nil {
 			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
 			return nil, err
+		} else if l.URL, err = url.Parse(ustr); err != nil {
+			db.log.Printf("[ERROR] Cannot parse URL %q: %s\n",
+				ustr,
+				err.Error())
+			return nil, err
 		}
 
-		list = append(list, t)
+		links = append(links, l)
 	}
 
-	return list, nil
-} // func (db *Database) TagGetAll() ([]objects.Tag, error)
+	return links, nil
+} // func (db *Database) FileURLGetByFile(f *objects.File) ([]objects.Link, error)
 
-// TagLinkAdd links the given Tag to the given File
-func (db *Database) TagLinkAdd(f *objects.File, t *objects.Tag) error {
-	const qid query.ID = query.TagLinkAdd
+// SeriesAdd adds a new Series to the Database.
+func (db *Database) SeriesAdd(title string, year int64) (*objects.Series, error) {
+	const qid query.ID = query.SeriesAdd
 	var (
 		err    error
 		msg    string
@@ -1528,7 +2965,7 @@ func (db *Database) TagLinkAdd(f *objects.File, t *objects.Tag) error {
 		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
 			qid.String(),
 			err.Error())
-		return err
+		return nil, err
 	} else if db.tx != nil {
 		tx = db.tx
 	} else {
@@ -1541,7 +2978,7 @@ func (db *Database) TagLinkAdd(f *objects.File, t *objects.Tag) error {
 				msg = fmt.Sprintf("Error starting transaction: %s\n",
 					err.Error())
 				db.log.Printf("[ERROR] %s\n", msg)
-				return errors.New(msg)
+				return nil, errors.New(msg)
 			}
 
 		} else {
@@ -1561,29 +2998,42 @@ func (db *Database) TagLinkAdd(f *objects.File, t *objects.Tag) error {
 	}
 
 	stmt = tx.Stmt(stmt)
+	var res sql.Result
 
 EXEC_QUERY:
-	if _, err = stmt.Exec(f.ID, t.ID); err != nil {
+	if res, err = stmt.Exec(title, year); err != nil {
 		if worthARetry(err) {
 			waitForRetry()
 			goto EXEC_QUERY
 		} else {
-			err = fmt.Errorf("Cannot add Tag %s to File %s: %s",
-				t.Name,
-				f.DisplayTitle(),
+			err = fmt.Errorf("Cannot add Series %s to database: %s",
+				title,
 				err.Error())
 			db.log.Printf("[ERROR] %s\n", err.Error())
-			return err
+			return nil, err
 		}
-	}
+	} else {
+		var seriesID int64
 
-	status = true
-	return nil
-} // func (db *Database) TagLinkAdd(f *objects.File, t *objects.Tag) error
+		if seriesID, err = res.LastInsertId(); err != nil {
+			db.log.Printf("[ERROR] Cannot get ID of new Series: %s\n",
+				err.Error())
+			return nil, err
+		}
+
+		status = true
+		return &objects.Series{
+			ID:    seriesID,
+			Title: title,
+			Year:  year,
+		}, nil
+	}
+} // func (db *Database) SeriesAdd(title string, year int64) (*objects.Series, error)
 
-// TagLinkDelete removes the link between the given Tag and File.
-func (db *Database) TagLinkDelete(f *objects.File, t *objects.Tag) error {
-	const qid query.ID = query.TagLinkDelete
+// SeriesDelete removes a Series from the Database, along with all its
+// Seasons and Episodes.
+func (db *Database) SeriesDelete(s *objects.Series) error {
+	const qid query.ID = query.SeriesDelete
 	var (
 		err    error
 		msg    string
@@ -1631,14 +3081,14 @@ func (db *Database) TagLinkDelete(f *objects.File, t *objects.Tag) error {
 	stmt = tx.Stmt(stmt)
 
 EXEC_QUERY:
-	if _, err = stmt.Exec(f.ID, t.ID); err != nil {
+	if _, err = stmt.Exec(s.ID); err != nil {
 		if worthARetry(err) {
 			waitForRetry()
 			goto EXEC_QUERY
 		} else {
-			err = fmt.Errorf("Cannot remove link of Tag %s to File %s: %s",
-				t.Name,
-				f.DisplayTitle(),
+			err = fmt.Errorf("Cannot delete Series %s (%d) from database: %s",
+				s.Title,
+				s.ID,
 				err.Error())
 			db.log.Printf("[ERROR] %s\n", err.Error())
 			return err
@@ -1647,109 +3097,11 @@ EXEC_QUERY:
 
 	status = true
 	return nil
-} // func (db *Database) TagLinkDelete(f *objects.File, t *objects.Tag) error
-
-// TagLinkGetByTag fetches all Files linked to the given Tag.
-func (db *Database) TagLinkGetByTag(t *objects.Tag) ([]objects.File, error) {
-	const qid query.ID = query.TagLinkGetByTag
-	var (
-		err  error
-		stmt *sql.Stmt
-	)
-
-	if stmt, err = db.getQuery(qid); err != nil {
-		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
-			qid,
-			err.Error())
-		return nil, err
-	} else if db.tx != nil {
-		stmt = db.tx.Stmt(stmt)
-	}
-
-	var rows *sql.Rows
-
-EXEC_QUERY:
-	if rows, err = stmt.Query(t.ID); err != nil {
-		if worthARetry(err) {
-			waitForRetry()
-			goto EXEC_QUERY
-		}
-
-		return nil, err
-	}
-
-	defer rows.Close() // nolint: errcheck,gosec
-
-	var files = make([]objects.File, 0, 10)
-
-	for rows.Next() {
-		var (
-			f objects.File
-		)
-
-		if err = rows.Scan(&f.ID, &f.FolderID, &f.Path, &f.Title, &f.Year); err != nil {
-			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
-			return nil, err
-		}
-
-		files = append(files, f)
-	}
-
-	return files, nil
-} // func (db *Database) TagLinkGetByTag(t *objects.Tag) ([]objects.File, error)
-
-// TagLinkGetByFile loads all Tags linked to the given File.
-func (db *Database) TagLinkGetByFile(f *objects.File) (map[int64]objects.Tag, error) {
-	const qid query.ID = query.TagLinkGetByFile
-	var (
-		err  error
-		stmt *sql.Stmt
-	)
+} // func (db *Database) SeriesDelete(s *objects.Series) error
 
-	if stmt, err = db.getQuery(qid); err != nil {
-		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
-			qid,
-			err.Error())
-		return nil, err
-	} else if db.tx != nil {
-		stmt = db.tx.Stmt(stmt)
-	}
-
-	var rows *sql.Rows
-
-EXEC_QUERY:
-	if rows, err = stmt.Query(f.ID); err != nil {
-		if worthARetry(err) {
-			waitForRetry()
-			goto EXEC_QUERY
-		}
-
-		return nil, err
-	}
-
-	defer rows.Close() // nolint: errcheck,gosec
-
-	var tags = make(map[int64]objects.Tag)
-
-	for rows.Next() {
-		var (
-			t objects.Tag
-		)
-
-		if err = rows.Scan(&t.ID, &t.Name); err != nil {
-			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
-			return nil, err
-		}
-
-		tags[t.ID] = t
-	}
-
-	return tags, nil
-} // func (db *Database) TagLinkGetByFile(f *objects.File) (map[int64]objects.Tag, error)
-
-// PersonAdd adds a new Person to the database.
-func (db *Database) PersonAdd(name string, birthday time.Time) (*objects.Person, error) {
-	const qid query.ID = query.PersonAdd
+// SeriesUpdateTitle sets the title of a Series.
+func (db *Database) SeriesUpdateTitle(s *objects.Series, title string) error {
+	const qid query.ID = query.SeriesUpdateTitle
 	var (
 		err    error
 		msg    string
@@ -1762,7 +3114,7 @@ func (db *Database) PersonAdd(name string, birthday time.Time) (*objects.Person,
 		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
 			qid.String(),
 			err.Error())
-		return nil, err
+		return err
 	} else if db.tx != nil {
 		tx = db.tx
 	} else {
@@ -1775,7 +3127,7 @@ func (db *Database) PersonAdd(name string, birthday time.Time) (*objects.Person,
 				msg = fmt.Sprintf("Error starting transaction: %s\n",
 					err.Error())
 				db.log.Printf("[ERROR] %s\n", msg)
-				return nil, errors.New(msg)
+				return errors.New(msg)
 			}
 
 		} else {
@@ -1795,51 +3147,31 @@ func (db *Database) PersonAdd(name string, birthday time.Time) (*objects.Person,
 	}
 
 	stmt = tx.Stmt(stmt)
-	var (
-		res    sql.Result
-		bstamp int64
-	)
-
-	if birthday.IsZero() {
-		bstamp = 0
-	} else {
-		bstamp = birthday.Unix()
-	}
 
 EXEC_QUERY:
-	if res, err = stmt.Exec(name, bstamp); err != nil {
+	if _, err = stmt.Exec(title, s.ID); err != nil {
 		if worthARetry(err) {
 			waitForRetry()
 			goto EXEC_QUERY
 		} else {
-			err = fmt.Errorf("Cannot add Tag %s to database: %s",
-				name,
+			err = fmt.Errorf("Cannot set Title of Series %q (%d) to %q: %s",
+				s.Title,
+				s.ID,
+				title,
 				err.Error())
 			db.log.Printf("[ERROR] %s\n", err.Error())
-			return nil, err
-		}
-	} else {
-		var id int64
-
-		if id, err = res.LastInsertId(); err != nil {
-			db.log.Printf("[ERROR] Cannot get ID of new Feed %s: %s\n",
-				name,
-				err.Error())
-			return nil, err
+			return err
 		}
-
-		status = true
-		return &objects.Person{
-			ID:       id,
-			Name:     name,
-			Birthday: birthday,
-		}, nil
 	}
-} // func (db *Database) PersonAdd(name string, birthday time.Time) (*objects.Person, error)
 
-// PersonGetAll loads all Persons from the Database, in no particular order.
-func (db *Database) PersonGetAll() ([]objects.Person, error) {
-	const qid query.ID = query.PersonGetAll
+	status = true
+	s.Title = title
+	return nil
+} // func (db *Database) SeriesUpdateTitle(s *objects.Series, title string) error
+
+// SeriesGetAll loads all Series, ordered by their Title.
+func (db *Database) SeriesGetAll() ([]objects.Series, error) {
+	const qid query.ID = query.SeriesGetAll
 	var (
 		err  error
 		stmt *sql.Stmt
@@ -1868,32 +3200,27 @@ EXEC_QUERY:
 
 	defer rows.Close() // nolint: errcheck,gosec
 
-	var list = make([]objects.Person, 0, 32)
+	var list = make([]objects.Series, 0, 32)
 
 	for rows.Next() {
 		var (
-			p      objects.Person
-			bstamp int64
+			s objects.Series
 		)
 
-		if err = rows.Scan(&p.ID, &p.Name, &bstamp); err != nil {
+		if err = rows.Scan(&s.ID, &s.Title, &s.Year); err != nil {
 			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
 			return nil, err
 		}
 
-		if bstamp != 0 {
-			p.Birthday = time.Unix(bstamp, 0)
-		}
-
-		list = append(list, p)
+		list = append(list, s)
 	}
 
 	return list, nil
-} // func (db *Database) PersonGetAll() ([]objects.Person, error)
+} // func (db *Database) SeriesGetAll() ([]objects.Series, error)
 
-// PersonGetByID looks up a Person by their ID.
-func (db *Database) PersonGetByID(id int64) (*objects.Person, error) {
-	const qid query.ID = query.PersonGetByID
+// SeriesGetByID looks up a Series by its ID.
+func (db *Database) SeriesGetByID(id int64) (*objects.Series, error) {
+	const qid query.ID = query.SeriesGetByID
 	var (
 		err  error
 		stmt *sql.Stmt
@@ -1924,28 +3251,24 @@ EXEC_QUERY:
 
 	if rows.Next() {
 		var (
-			p      = &objects.Person{ID: id}
-			bstamp int64
+			s = &objects.Series{ID: id}
 		)
 
-		if err = rows.Scan(&p.Name, &bstamp); err != nil {
+		if err = rows.Scan(&s.Title, &s.Year); err != nil {
 			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
 			return nil, err
 		}
 
-		if bstamp != 0 {
-			p.Birthday = time.Unix(bstamp, 0)
-		}
-
-		return p, nil
+		return s, nil
 	}
 
 	return nil, nil
-} // func (db *Database) PersonGetByID(id int64) (*objects.Person, error)
+} // func (db *Database) SeriesGetByID(id int64) (*objects.Series, error)
 
-// PersonURLAdd attaches a Link to a Person.
-func (db *Database) PersonURLAdd(p *objects.Person, l *objects.Link) error {
-	const qid query.ID = query.PersonURLAdd
+// SeasonAdd adds a new Season to the given Series. If we do not know the
+// year the Season aired, year is 0.
+func (db *Database) SeasonAdd(s *objects.Series, number int64, title string, year int64) (*objects.Season, error) {
+	const qid query.ID = query.SeasonAdd
 	var (
 		err    error
 		msg    string
@@ -1958,7 +3281,7 @@ func (db *Database) PersonURLAdd(p *objects.Person, l *objects.Link) error {
 		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
 			qid.String(),
 			err.Error())
-		return err
+		return nil, err
 	} else if db.tx != nil {
 		tx = db.tx
 	} else {
@@ -1971,7 +3294,7 @@ func (db *Database) PersonURLAdd(p *objects.Person, l *objects.Link) error {
 				msg = fmt.Sprintf("Error starting transaction: %s\n",
 					err.Error())
 				db.log.Printf("[ERROR] %s\n", msg)
-				return errors.New(msg)
+				return nil, errors.New(msg)
 			}
 
 		} else {
@@ -1991,39 +3314,44 @@ func (db *Database) PersonURLAdd(p *objects.Person, l *objects.Link) error {
 	}
 
 	stmt = tx.Stmt(stmt)
-	var (
-		res sql.Result
-		id  int64
-	)
+	var res sql.Result
 
 EXEC_QUERY:
-	if res, err = stmt.Exec(p.ID, l.URL.String(), l.Title, l.Description); err != nil {
+	if res, err = stmt.Exec(s.ID, number, title, year); err != nil {
 		if worthARetry(err) {
 			waitForRetry()
 			goto EXEC_QUERY
 		} else {
-			err = fmt.Errorf("Cannot add Link %s to Person %s: %s",
-				l.DisplayTitle(),
-				p.Name,
+			err = fmt.Errorf("Cannot add Season %d to Series %s: %s",
+				number,
+				s.Title,
 				err.Error())
 			db.log.Printf("[ERROR] %s\n", err.Error())
-			return err
+			return nil, err
 		}
-	} else if id, err = res.LastInsertId(); err != nil {
-		db.log.Printf("[ERROR] Cannot get ID of newly added Link %s: %s\n",
-			l.DisplayTitle(),
-			err.Error())
-		return err
-	}
+	} else {
+		var seasonID int64
 
-	l.ID = id
-	status = true
-	return nil
-} // func (db *Database) PersonURLAdd(p *objects.Person, l *objects.Link) error
+		if seasonID, err = res.LastInsertId(); err != nil {
+			db.log.Printf("[ERROR] Cannot get ID of new Season: %s\n",
+				err.Error())
+			return nil, err
+		}
 
-// PersonURLDelete deletes a Link that has been attached to a Person
-func (db *Database) PersonURLDelete(l *objects.Link) error {
-	const qid query.ID = query.PersonURLDelete
+		status = true
+		return &objects.Season{
+			ID:       seasonID,
+			SeriesID: s.ID,
+			Number:   number,
+			Title:    title,
+			Year:     year,
+		}, nil
+	}
+} // func (db *Database) SeasonAdd(s *objects.Series, number int64, title string, year int64) (*objects.Season, error)
+
+// SeasonDelete removes a Season from the Database, along with all its Episodes.
+func (db *Database) SeasonDelete(s *objects.Season) error {
+	const qid query.ID = query.SeasonDelete
 	var (
 		err    error
 		msg    string
@@ -2059,93 +3387,39 @@ func (db *Database) PersonURLDelete(l *objects.Link) error {
 					if err2 = tx.Commit(); err2 != nil {
 						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
 							err2.Error())
-					}
-				} else if err2 = tx.Rollback(); err2 != nil {
-					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
-						err2.Error())
-				}
-			}()
-		}
-	}
-
-	stmt = tx.Stmt(stmt)
-
-EXEC_QUERY:
-	if _, err = stmt.Exec(l.ID); err != nil {
-		if worthARetry(err) {
-			waitForRetry()
-			goto EXEC_QUERY
-		} else {
-			err = fmt.Errorf("Cannot delete Link %s: %s",
-				l.DisplayTitle(),
-				err.Error())
-			db.log.Printf("[ERROR] %s\n", err.Error())
-			return err
-		}
-	}
-
-	status = true
-	return nil
-} // func (db *Database) PersonURLDelete(l *objects.Link) error
-
-// PersonURLGetByPerson returns all Links attached to the given Person.
-func (db *Database) PersonURLGetByPerson(p *objects.Person) ([]objects.Link, error) {
-	const qid query.ID = query.PersonURLGetByPerson
-	var (
-		err  error
-		stmt *sql.Stmt
-	)
-
-	if stmt, err = db.getQuery(qid); err != nil {
-		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
-			qid,
-			err.Error())
-		return nil, err
-	} else if db.tx != nil {
-		stmt = db.tx.Stmt(stmt)
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
 	}
 
-	var rows *sql.Rows
+	stmt = tx.Stmt(stmt)
 
 EXEC_QUERY:
-	if rows, err = stmt.Query(p.ID); err != nil {
+	if _, err = stmt.Exec(s.ID); err != nil {
 		if worthARetry(err) {
 			waitForRetry()
 			goto EXEC_QUERY
-		}
-
-		return nil, err
-	}
-
-	defer rows.Close() // nolint: errcheck,gosec
-
-	var links = make([]objects.Link, 0, 4)
-
-	for rows.Next() {
-		var (
-			l    objects.Link
-			ustr string
-		)
-
-		if err = rows.Scan(&l.ID, &ustr, &l.Title, &l.Description); err != nil {
-			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
-			return nil, err
-		} else if l.URL, err = url.Parse(ustr); err != nil {
-			db.log.Printf("[ERROR] Cannot parse URL %q: %s\n",
-				ustr,
+		} else {
+			err = fmt.Errorf("Cannot delete Season %s (%d) from database: %s",
+				s.DisplayTitle(),
+				s.ID,
 				err.Error())
-			return nil, err
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return err
 		}
-
-		links = append(links, l)
 	}
 
-	return links, nil
-} // func (db *Database) PersonURLGetByPerson(p *objects.Person) ([]objects.Link, error)
+	status = true
+	return nil
+} // func (db *Database) SeasonDelete(s *objects.Season) error
 
-// ActorAdd adds a Person to a File as an actor/actress.
-func (db *Database) ActorAdd(f *objects.File, p *objects.Person) error {
-	const qid query.ID = query.ActorAdd
+// SeasonUpdateTitle sets the title of a Season.
+func (db *Database) SeasonUpdateTitle(s *objects.Season, title string) error {
+	const qid query.ID = query.SeasonUpdateTitle
 	var (
 		err    error
 		msg    string
@@ -2193,14 +3467,15 @@ func (db *Database) ActorAdd(f *objects.File, p *objects.Person) error {
 	stmt = tx.Stmt(stmt)
 
 EXEC_QUERY:
-	if _, err = stmt.Exec(f.ID, p.ID); err != nil {
+	if _, err = stmt.Exec(title, s.ID); err != nil {
 		if worthARetry(err) {
 			waitForRetry()
 			goto EXEC_QUERY
 		} else {
-			err = fmt.Errorf("Cannot add Actor %s to Film %s: %s",
-				p.Name,
-				f.DisplayTitle(),
+			err = fmt.Errorf("Cannot set Title of Season %q (%d) to %q: %s",
+				s.DisplayTitle(),
+				s.ID,
+				title,
 				err.Error())
 			db.log.Printf("[ERROR] %s\n", err.Error())
 			return err
@@ -2208,12 +3483,13 @@ EXEC_QUERY:
 	}
 
 	status = true
+	s.Title = title
 	return nil
-} // func (db *Database) ActorAdd(f *objects.File, p *objects.Person) error
+} // func (db *Database) SeasonUpdateTitle(s *objects.Season, title string) error
 
-// ActorDelete removes a Person from a Files "acting credits".
-func (db *Database) ActorDelete(f *objects.File, p *objects.Person) error {
-	const qid query.ID = query.ActorDelete
+// SeasonSetYear sets the year a Season aired.
+func (db *Database) SeasonSetYear(s *objects.Season, year int64) error {
+	const qid query.ID = query.SeasonSetYear
 	var (
 		err    error
 		msg    string
@@ -2261,14 +3537,15 @@ func (db *Database) ActorDelete(f *objects.File, p *objects.Person) error {
 	stmt = tx.Stmt(stmt)
 
 EXEC_QUERY:
-	if _, err = stmt.Exec(f.ID, p.ID); err != nil {
+	if _, err = stmt.Exec(year, s.ID); err != nil {
 		if worthARetry(err) {
 			waitForRetry()
 			goto EXEC_QUERY
 		} else {
-			err = fmt.Errorf("Cannot remove Actor %s from Film %s: %s",
-				p.Name,
-				f.DisplayTitle(),
+			err = fmt.Errorf("Cannot set Year of Season %q (%d) to %d: %s",
+				s.DisplayTitle(),
+				s.ID,
+				year,
 				err.Error())
 			db.log.Printf("[ERROR] %s\n", err.Error())
 			return err
@@ -2276,12 +3553,13 @@ EXEC_QUERY:
 	}
 
 	status = true
+	s.Year = year
 	return nil
-} // func (db *Database) ActorDelete(f *objects.File, p *objects.Person) error
+} // func (db *Database) SeasonSetYear(s *objects.Season, year int64) error
 
-// ActorGetByPerson gets all the Files the given Person has acted in.
-func (db *Database) ActorGetByPerson(p *objects.Person) ([]objects.File, error) {
-	const qid query.ID = query.ActorGetByPerson
+// SeasonGetBySeries loads all Seasons of the given Series, ordered by their number.
+func (db *Database) SeasonGetBySeries(s *objects.Series) ([]objects.Season, error) {
+	const qid query.ID = query.SeasonGetBySeries
 	var (
 		err  error
 		stmt *sql.Stmt
@@ -2299,7 +3577,7 @@ func (db *Database) ActorGetByPerson(p *objects.Person) ([]objects.File, error)
 	var rows *sql.Rows
 
 EXEC_QUERY:
-	if rows, err = stmt.Query(p.ID); err != nil {
+	if rows, err = stmt.Query(s.ID); err != nil {
 		if worthARetry(err) {
 			waitForRetry()
 			goto EXEC_QUERY
@@ -2310,27 +3588,27 @@ EXEC_QUERY:
 
 	defer rows.Close() // nolint: errcheck,gosec
 
-	var files = make([]objects.File, 0, 10)
+	var seasons = make([]objects.Season, 0, 8)
 
 	for rows.Next() {
 		var (
-			f objects.File
+			season = objects.Season{SeriesID: s.ID}
 		)
 
-		if err = rows.Scan(&f.ID, &f.FolderID, &f.Path, &f.Title, &f.Year); err != nil {
+		if err = rows.Scan(&season.ID, &season.Number, &season.Title, &season.Year); err != nil {
 			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
 			return nil, err
 		}
 
-		files = append(files, f)
+		seasons = append(seasons, season)
 	}
 
-	return files, nil
-} // func (db *Database) ActorGetByPerson(p *objects.Person) ([]objects.File, error)
+	return seasons, nil
+} // func (db *Database) SeasonGetBySeries(s *objects.Series) ([]objects.Season, error)
 
-// ActorGetByFile gets all the People that have acted in the given File.
-func (db *Database) ActorGetByFile(f *objects.File) ([]objects.Person, error) {
-	const qid query.ID = query.ActorGetByFile
+// SeasonGetByID looks up a Season by its ID.
+func (db *Database) SeasonGetByID(id int64) (*objects.Season, error) {
+	const qid query.ID = query.SeasonGetByID
 	var (
 		err  error
 		stmt *sql.Stmt
@@ -2348,7 +3626,7 @@ func (db *Database) ActorGetByFile(f *objects.File) ([]objects.Person, error) {
 	var rows *sql.Rows
 
 EXEC_QUERY:
-	if rows, err = stmt.Query(f.ID); err != nil {
+	if rows, err = stmt.Query(id); err != nil {
 		if worthARetry(err) {
 			waitForRetry()
 			goto EXEC_QUERY
@@ -2359,32 +3637,108 @@ EXEC_QUERY:
 
 	defer rows.Close() // nolint: errcheck,gosec
 
-	var people = make([]objects.Person, 0, 10)
-
-	for rows.Next() {
+	if rows.Next() {
 		var (
-			p      objects.Person
-			bstamp int64
+			s = &objects.Season{ID: id}
 		)
 
-		if err = rows.Scan(&p.ID, &p.Name, &bstamp); err != nil {
+		if err = rows.Scan(&s.SeriesID, &s.Number, &s.Title, &s.Year); err != nil {
 			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
 			return nil, err
 		}
 
-		if bstamp != 0 {
-			p.Birthday = time.Unix(bstamp, 0)
-		}
+		return s, nil
+	}
+
+	return nil, nil
+} // func (db *Database) SeasonGetByID(id int64) (*objects.Season, error)
+
+// EpisodeAdd adds a new Episode to the given Season.
+func (db *Database) EpisodeAdd(s *objects.Season, number int64, title string) (*objects.Episode, error) {
+	const qid query.ID = query.EpisodeAdd
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return nil, errors.New(msg)
+			}
 
-		people = append(people, p)
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
 	}
 
-	return people, nil
-} // func (db *Database) ActorGetByFile(f *objects.File) ([]objects.Person, error)
+	stmt = tx.Stmt(stmt)
+	var res sql.Result
+
+EXEC_QUERY:
+	if res, err = stmt.Exec(s.ID, number, title); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		} else {
+			err = fmt.Errorf("Cannot add Episode %d to Season %s: %s",
+				number,
+				s.DisplayTitle(),
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return nil, err
+		}
+	} else {
+		var episodeID int64
+
+		if episodeID, err = res.LastInsertId(); err != nil {
+			db.log.Printf("[ERROR] Cannot get ID of new Episode: %s\n",
+				err.Error())
+			return nil, err
+		}
+
+		status = true
+		return &objects.Episode{
+			ID:       episodeID,
+			SeasonID: s.ID,
+			Number:   number,
+			Title:    title,
+		}, nil
+	}
+} // func (db *Database) EpisodeAdd(s *objects.Season, number int64, title string) (*objects.Episode, error)
 
-// DirectorAdd adds a Person to a File as an director/actress.
-func (db *Database) DirectorAdd(f *objects.File, p *objects.Person) error {
-	const qid query.ID = query.DirectorAdd
+// EpisodeDelete removes an Episode from the Database.
+// Files linked to the Episode are unlinked, but not removed.
+func (db *Database) EpisodeDelete(e *objects.Episode) error {
+	const qid query.ID = query.EpisodeDelete
 	var (
 		err    error
 		msg    string
@@ -2432,14 +3786,14 @@ func (db *Database) DirectorAdd(f *objects.File, p *objects.Person) error {
 	stmt = tx.Stmt(stmt)
 
 EXEC_QUERY:
-	if _, err = stmt.Exec(f.ID, p.ID); err != nil {
+	if _, err = stmt.Exec(e.ID); err != nil {
 		if worthARetry(err) {
 			waitForRetry()
 			goto EXEC_QUERY
 		} else {
-			err = fmt.Errorf("Cannot add Director %s to Film %s: %s",
-				p.Name,
-				f.DisplayTitle(),
+			err = fmt.Errorf("Cannot delete Episode %s (%d) from database: %s",
+				e.DisplayTitle(),
+				e.ID,
 				err.Error())
 			db.log.Printf("[ERROR] %s\n", err.Error())
 			return err
@@ -2448,11 +3802,11 @@ EXEC_QUERY:
 
 	status = true
 	return nil
-} // func (db *Database) DirectorAdd(f *objects.File, p *objects.Person) error
+} // func (db *Database) EpisodeDelete(e *objects.Episode) error
 
-// DirectorDelete removes a Person from a Files "acting credits".
-func (db *Database) DirectorDelete(f *objects.File, p *objects.Person) error {
-	const qid query.ID = query.DirectorDelete
+// EpisodeUpdateTitle sets the title of an Episode.
+func (db *Database) EpisodeUpdateTitle(e *objects.Episode, title string) error {
+	const qid query.ID = query.EpisodeUpdateTitle
 	var (
 		err    error
 		msg    string
@@ -2500,14 +3854,15 @@ func (db *Database) DirectorDelete(f *objects.File, p *objects.Person) error {
 	stmt = tx.Stmt(stmt)
 
 EXEC_QUERY:
-	if _, err = stmt.Exec(f.ID, p.ID); err != nil {
+	if _, err = stmt.Exec(title, e.ID); err != nil {
 		if worthARetry(err) {
 			waitForRetry()
 			goto EXEC_QUERY
 		} else {
-			err = fmt.Errorf("Cannot remove Director %s from Film %s: %s",
-				p.Name,
-				f.DisplayTitle(),
+			err = fmt.Errorf("Cannot set Title of Episode %q (%d) to %q: %s",
+				e.DisplayTitle(),
+				e.ID,
+				title,
 				err.Error())
 			db.log.Printf("[ERROR] %s\n", err.Error())
 			return err
@@ -2515,12 +3870,13 @@ EXEC_QUERY:
 	}
 
 	status = true
+	e.Title = title
 	return nil
-} // func (db *Database) DirectorDelete(f *objects.File, p *objects.Person) error
+} // func (db *Database) EpisodeUpdateTitle(e *objects.Episode, title string) error
 
-// DirectorGetByPerson gets all the Files the given Person has acted in.
-func (db *Database) DirectorGetByPerson(p *objects.Person) ([]objects.File, error) {
-	const qid query.ID = query.DirectorGetByPerson
+// EpisodeGetBySeason loads all Episodes of the given Season, ordered by their number.
+func (db *Database) EpisodeGetBySeason(s *objects.Season) ([]objects.Episode, error) {
+	const qid query.ID = query.EpisodeGetBySeason
 	var (
 		err  error
 		stmt *sql.Stmt
@@ -2538,7 +3894,7 @@ func (db *Database) DirectorGetByPerson(p *objects.Person) ([]objects.File, erro
 	var rows *sql.Rows
 
 EXEC_QUERY:
-	if rows, err = stmt.Query(p.ID); err != nil {
+	if rows, err = stmt.Query(s.ID); err != nil {
 		if worthARetry(err) {
 			waitForRetry()
 			goto EXEC_QUERY
@@ -2549,27 +3905,27 @@ EXEC_QUERY:
 
 	defer rows.Close() // nolint: errcheck,gosec
 
-	var files = make([]objects.File, 0, 10)
+	var episodes = make([]objects.Episode, 0, 16)
 
 	for rows.Next() {
 		var (
-			f objects.File
+			e = objects.Episode{SeasonID: s.ID}
 		)
 
-		if err = rows.Scan(&f.ID, &f.FolderID, &f.Path, &f.Title, &f.Year); err != nil {
+		if err = rows.Scan(&e.ID, &e.Number, &e.Title); err != nil {
 			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
 			return nil, err
 		}
 
-		files = append(files, f)
+		episodes = append(episodes, e)
 	}
 
-	return files, nil
-} // func (db *Database) DirectorGetByPerson(p *objects.Person) ([]objects.File, error)
+	return episodes, nil
+} // func (db *Database) EpisodeGetBySeason(s *objects.Season) ([]objects.Episode, error)
 
-// DirectorGetByFile gets all the People that have acted in the given File.
-func (db *Database) DirectorGetByFile(f *objects.File) ([]objects.Person, error) {
-	const qid query.ID = query.DirectorGetByFile
+// EpisodeGetByID looks up an Episode by its ID.
+func (db *Database) EpisodeGetByID(id int64) (*objects.Episode, error) {
+	const qid query.ID = query.EpisodeGetByID
 	var (
 		err  error
 		stmt *sql.Stmt
@@ -2587,7 +3943,7 @@ func (db *Database) DirectorGetByFile(f *objects.File) ([]objects.Person, error)
 	var rows *sql.Rows
 
 EXEC_QUERY:
-	if rows, err = stmt.Query(f.ID); err != nil {
+	if rows, err = stmt.Query(id); err != nil {
 		if worthARetry(err) {
 			waitForRetry()
 			goto EXEC_QUERY
@@ -2598,25 +3954,145 @@ EXEC_QUERY:
 
 	defer rows.Close() // nolint: errcheck,gosec
 
-	var people = make([]objects.Person, 0, 10)
-
-	for rows.Next() {
+	if rows.Next() {
 		var (
-			p      objects.Person
-			bstamp int64
+			e = &objects.Episode{ID: id}
 		)
 
-		if err = rows.Scan(&p.ID, &p.Name, &bstamp); err != nil {
+		if err = rows.Scan(&e.SeasonID, &e.Number, &e.Title); err != nil {
 			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
 			return nil, err
 		}
 
-		if bstamp != 0 {
-			p.Birthday = time.Unix(bstamp, 0)
+		return e, nil
+	}
+
+	return nil, nil
+} // func (db *Database) EpisodeGetByID(id int64) (*objects.Episode, error)
+
+// FileSetEpisode links a File to an Episode.
+// If e is nil, the File is unlinked from whatever Episode it was linked to.
+func (db *Database) FileSetEpisode(f *objects.File, e *objects.Episode) error {
+	const qid query.ID = query.FileSetEpisode
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+		eid    *int64
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	stmt = tx.Stmt(stmt)
+
+	if e != nil {
+		eid = &e.ID
+	}
+
+EXEC_QUERY:
+	if _, err = stmt.Exec(eid, f.ID); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		} else {
+			err = fmt.Errorf("Cannot set Episode of File %s: %s",
+				f.DisplayTitle(),
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return err
+		}
+	}
+
+	status = true
+	if e != nil {
+		f.EpisodeID = e.ID
+	} else {
+		f.EpisodeID = 0
+	}
+	return nil
+} // func (db *Database) FileSetEpisode(f *objects.File, e *objects.Episode) error
+
+// FileGetByEpisode returns all Files linked to the given Episode.
+func (db *Database) FileGetByEpisode(e *objects.Episode) ([]objects.File, error) {
+	const qid query.ID = query.FileGetByEpisode
+	var (
+		err  error
+		stmt *sql.Stmt
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(e.ID); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
 		}
 
-		people = append(people, p)
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+
+	var files = make([]objects.File, 0, 2)
+
+	for rows.Next() {
+		var (
+			f = objects.File{EpisodeID: e.ID}
+		)
+
+		if err = rows.Scan(&f.ID, &f.FolderID, &f.Path, &f.Title, &f.Year, &f.Hidden); err != nil {
+			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
+			return nil, err
+		}
+
+		files = append(files, f)
 	}
 
-	return people, nil
-} // func (db *Database) DirectorGetByFile(f *objects.File) ([]objects.Person, error)
+	return files, nil
+} // func (db *Database) FileGetByEpisode(e *objects.Episode) ([]objects.File, error)
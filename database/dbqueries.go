@@ -10,30 +10,46 @@ import "github.com/blicero/blockbuster/database/query"
 
 var dbQueries = map[query.ID]string{
 	query.FileAdd: `
-INSERT INTO file (path, folder_id)
-VALUES           (   ?,         ?)
+INSERT INTO file (path, folder_id, added)
+VALUES           (   ?,         ?, CAST(strftime('%s', 'now') AS INTEGER))
 `,
 	query.FileRemove:         "DELETE FROM file WHERE id = ?",
 	query.FileRemoveByFolder: "DELETE FROM file WHERE folder_id = ?",
-	query.FileGetAll:         "SELECT id, folder_id, path, title, year, hidden FROM file",
-	query.FileGetByPath:      "SELECT id, folder_id, title, year, hidden FROM file WHERE path = ?",
-	query.FileGetByID:        "SELECT folder_id, path, title, year, hidden FROM file WHERE id = ?",
+	query.FileGetAll:         "SELECT id, folder_id, path, title, year, hidden, episode_id, fingerprint, missing_since, watched, resume_pos FROM file",
+	query.FileGetByPath:      "SELECT id, folder_id, title, year, hidden, episode_id, fingerprint, missing_since, watched, resume_pos, size, mtime, inode FROM file WHERE path = ?",
+	query.FileGetByID:        "SELECT folder_id, path, title, year, hidden, episode_id, fingerprint, missing_since, watched, resume_pos FROM file WHERE id = ?",
 	query.FileUpdateTitle:    "UPDATE file SET title = ? WHERE id = ?",
 	query.FileUpdateYear:     "UPDATE file SET year = ? WHERE id = ?",
+	query.FileSetFingerprint: "UPDATE file SET fingerprint = ? WHERE id = ?",
+	query.FileMove:           "UPDATE file SET path = ?, folder_id = ?, missing_since = 0 WHERE id = ?",
+	query.FileSetMissing:     "UPDATE file SET missing_since = ? WHERE id = ?",
+	query.FilePurgeTags:      "DELETE FROM tag_link WHERE file_id = ?",
+	query.FilePurgeCredits:   "DELETE FROM credit WHERE file_id = ?",
+	query.FilePurgeURLs:      "DELETE FROM file_url WHERE file_id = ?",
 	query.FolderAdd:          "INSERT INTO folder(path) VALUES (?)",
 	query.FolderRemove:       "DELETE FROM folder WHERE id = ?",
 	query.FolderUpdateScan:   "UPDATE folder SET last_scan = ? WHERE id = ?",
-	query.FolderGetAll:       "SELECT id, path, last_scan FROM folder",
-	query.FolderGetByPath:    "SELECT id, last_scan FROM folder WHERE path = ?",
+	query.FolderGetAll:       "SELECT id, path, last_scan, min_size, suffixes, exclude FROM folder",
+	query.FolderGetByPath:    "SELECT id, last_scan, min_size, suffixes, exclude FROM folder WHERE path = ?",
+	query.FolderUpdateRules:  "UPDATE folder SET min_size = ?, suffixes = ?, exclude = ? WHERE id = ?",
+	query.FileSetStat:        "UPDATE file SET size = ?, mtime = ?, inode = ? WHERE id = ?",
+	query.DirCacheGet:        "SELECT path, mtime FROM dir_cache WHERE folder_id = ?",
+	query.DirCacheClear:      "DELETE FROM dir_cache WHERE folder_id = ?",
+	query.DirCacheAdd:        "INSERT INTO dir_cache (folder_id, path, mtime) VALUES (?, ?, ?)",
 	query.TagAdd:             "INSERT INTO tag (name) VALUES (?)",
 	query.TagDelete:          "DELETE FROM tag WHERE id = ?",
-	query.TagGetAll:          "SELECT id, name FROM tag",
-	query.TagGetByID:         "SELECT name FROM tag WHERE id = ?",
-	query.TagGetByName:       "SELECT id FROM tag WHERE name = ?",
+	query.TagGetAll:          "SELECT id, name, COALESCE(parent, 0) FROM tag",
+	query.TagGetByID:         "SELECT name, COALESCE(parent, 0) FROM tag WHERE id = ?",
+	query.TagGetByName:       "SELECT id, COALESCE(parent, 0) FROM tag WHERE name = ?",
 	query.TagLinkAdd:         "INSERT INTO tag_link (file_id, tag_id) VALUES (?, ?)",
 	query.TagLinkDelete:      "DELETE FROM tag_link WHERE file_id = ? AND tag_id = ?",
 	query.TagLinkGetByTag: `
-SELECT
+WITH RECURSIVE subtree(id) AS (
+    SELECT ?
+    UNION
+    SELECT t.id FROM tag t INNER JOIN subtree s ON t.parent = s.id
+)
+SELECT DISTINCT
     f.id,
     f.folder_id,
     f.path,
@@ -41,7 +57,7 @@ SELECT
     f.year
 FROM tag_link l
 INNER JOIN file f ON l.file_id = f.id
-WHERE l.tag_id = ?
+WHERE l.tag_id IN (SELECT id FROM subtree)
 `,
 	query.TagLinkGetByFile: `
 SELECT
@@ -55,54 +71,427 @@ WHERE l.file_id = ?
 	query.PersonDelete:         "DELETE FROM person WHERE id = ?",
 	query.PersonGetAll:         "SELECT id, name, birthday FROM person ORDER BY name",
 	query.PersonGetByID:        "SELECT name, birthday FROM person WHERE id = ?",
-	query.PersonGetByName:      "SELECT id, birthday FROM person WHERE name = ?",
 	query.PersonURLAdd:         "INSERT INTO person_url (person_id, url, title, description) VALUES (?, ?, ?, ?)",
 	query.PersonURLDelete:      "DELETE FROM person_url WHERE id = ?",
 	query.PersonURLGetByPerson: "SELECT id, url, title, description FROM person_url WHERE person_id = ?",
-	query.ActorAdd:             "INSERT INTO actor (file_id, person_id) VALUES (?, ?)",
-	query.ActorDelete:          "DELETE FROM actor WHERE file_id = ? AND person_id = ?",
-	query.ActorGetByPerson: `
+	query.FileURLAdd:           "INSERT INTO file_url (file_id, url, title, description) VALUES (?, ?, ?, ?)",
+	query.FileURLDelete:        "DELETE FROM file_url WHERE id = ?",
+	query.FileURLGetByFile:     "SELECT id, url, title, description FROM file_url WHERE file_id = ?",
+	query.SeriesAdd:            "INSERT INTO series (title, year) VALUES (?, ?)",
+	query.SeriesDelete:         "DELETE FROM series WHERE id = ?",
+	query.SeriesUpdateTitle:    "UPDATE series SET title = ? WHERE id = ?",
+	query.SeriesGetAll:         "SELECT id, title, year FROM series ORDER BY title",
+	query.SeriesGetByID:        "SELECT title, year FROM series WHERE id = ?",
+	query.SeasonAdd:            "INSERT INTO season (series_id, number, title, year) VALUES (?, ?, ?, ?)",
+	query.SeasonDelete:         "DELETE FROM season WHERE id = ?",
+	query.SeasonUpdateTitle:    "UPDATE season SET title = ? WHERE id = ?",
+	query.SeasonSetYear:        "UPDATE season SET year = ? WHERE id = ?",
+	query.SeasonGetBySeries:    "SELECT id, number, title, year FROM season WHERE series_id = ? ORDER BY number",
+	query.SeasonGetByID:        "SELECT series_id, number, title, year FROM season WHERE id = ?",
+	query.EpisodeAdd:           "INSERT INTO episode (season_id, number, title) VALUES (?, ?, ?)",
+	query.EpisodeDelete:        "DELETE FROM episode WHERE id = ?",
+	query.EpisodeUpdateTitle:   "UPDATE episode SET title = ? WHERE id = ?",
+	query.EpisodeGetBySeason:   "SELECT id, number, title FROM episode WHERE season_id = ? ORDER BY number",
+	query.EpisodeGetByID:       "SELECT season_id, number, title FROM episode WHERE id = ?",
+	query.FileSetEpisode:       "UPDATE file SET episode_id = ? WHERE id = ?",
+	query.FileGetByEpisode: `
+SELECT
+    id,
+    folder_id,
+    path,
+    title,
+    year,
+    hidden
+FROM file
+WHERE episode_id = ?
+ORDER BY path
+`,
+	query.Search: `
+SELECT
+    kind,
+    object_id,
+    owner_id,
+    title,
+    body,
+    snippet(search_index, -1, '[', ']', '...', 8),
+    bm25(search_index, 0.0, 0.0, 0.0, 10.0, 1.0) AS rank
+FROM search_index
+WHERE search_index MATCH ?
+ORDER BY rank
+LIMIT ?
+`,
+	query.FileGetByFingerprint: `
+SELECT
+    id,
+    folder_id,
+    path,
+    title,
+    year,
+    hidden
+FROM file
+WHERE fingerprint = ?
+`,
+	query.FileGetByFolder: `
+SELECT
+    id,
+    path,
+    title,
+    year,
+    hidden,
+    missing_since,
+    fingerprint,
+    size,
+    mtime,
+    inode
+FROM file
+WHERE folder_id = ?
+`,
+	query.FileGetMissing: `
+SELECT
+    id,
+    folder_id,
+    path,
+    title,
+    year,
+    hidden,
+    missing_since
+FROM file
+WHERE missing_since <> 0
+ORDER BY missing_since, path
+`,
+	query.MediaInfoAdd: `
+INSERT INTO media_info (file_id, container, duration, width, height, frame_rate, video_codec, probed)
+                VALUES (      ?,         ?,        ?,     ?,      ?,          ?,           ?,      ?)
+`,
+	query.MediaInfoDelete: "DELETE FROM media_info WHERE file_id = ?",
+	query.MediaInfoGetByFile: `
+SELECT
+    container,
+    duration,
+    width,
+    height,
+    frame_rate,
+    video_codec,
+    probed
+FROM media_info
+WHERE file_id = ?
+`,
+	query.MediaTrackAdd: `
+INSERT INTO media_track (file_id, kind, codec, language, name, channels)
+                 VALUES (      ?,    ?,     ?,        ?,    ?,        ?)
+`,
+	query.MediaTrackGetByFile: `
+SELECT
+    id,
+    kind,
+    codec,
+    language,
+    name,
+    channels
+FROM media_track
+WHERE file_id = ?
+ORDER BY id
+`,
+	query.FileGetUnprobed: `
 SELECT
     f.id,
     f.folder_id,
     f.path,
     f.title,
-    f.year
-FROM actor a
-INNER JOIN file f ON a.file_id = f.id
-WHERE a.person_id = ?
+    f.year,
+    f.hidden
+FROM file f
+LEFT OUTER JOIN media_info m ON f.id = m.file_id
+WHERE m.file_id IS NULL AND f.missing_since = 0
 `,
-	query.ActorGetByFile: `
+	query.PlayLogAdd:    "INSERT INTO play_log (file_id, start_time) VALUES (?, ?)",
+	query.PlayLogFinish: "UPDATE play_log SET end_time = ?, exit_status = ?, position = ? WHERE id = ?",
+	query.PlayLogGetByFile: `
 SELECT
-    p.id,
+    id,
+    start_time,
+    end_time,
+    exit_status,
+    position
+FROM play_log
+WHERE file_id = ?
+ORDER BY start_time DESC
+`,
+	query.FileSetWatched: `
+UPDATE file
+SET watched = ?1,
+    resume_pos = CASE WHEN ?1 THEN 0 ELSE resume_pos END
+WHERE id = ?2
+`,
+	query.FileSetResumePosition: "UPDATE file SET resume_pos = ? WHERE id = ?",
+	query.FileGetResumable: `
+SELECT
+    f.id,
+    f.folder_id,
+    f.path,
+    f.title,
+    f.year,
+    f.hidden,
+    f.resume_pos
+FROM file f
+LEFT OUTER JOIN play_log l ON f.id = l.file_id
+WHERE f.resume_pos > 0 AND NOT f.watched AND f.missing_since = 0
+GROUP BY f.id
+ORDER BY MAX(l.start_time) DESC
+`,
+	query.CreditAdd:    "INSERT INTO credit (file_id, person_id, role, character, billing) VALUES (?, ?, ?, ?, ?)",
+	query.CreditUpdate: "UPDATE credit SET character = ?, billing = ? WHERE id = ?",
+	query.CreditDelete: "DELETE FROM credit WHERE file_id = ? AND person_id = ? AND role = ?",
+	query.CreditGetByFile: `
+SELECT
+    c.id,
+    c.person_id,
+    c.role,
+    c.character,
+    c.billing,
     p.name,
     p.birthday
-FROM actor a
-INNER JOIN person p ON a.person_id = p.id
-WHERE a.file_id = ?
-ORDER BY p.name
+FROM credit c
+INNER JOIN person p ON c.person_id = p.id
+WHERE c.file_id = ?
+ORDER BY c.role, c.billing = 0, c.billing, p.name
 `,
-	query.DirectorAdd:    "INSERT INTO director (file_id, person_id) VALUES (?, ?)",
-	query.DirectorDelete: "DELETE FROM director WHERE file_id = ? AND person_id = ?",
-	query.DirectorGetByPerson: `
+	query.CreditGetByPerson: `
 SELECT
-    f.id,
+    c.id,
+    c.file_id,
+    c.role,
+    c.character,
+    c.billing,
     f.folder_id,
     f.path,
     f.title,
     f.year
-FROM director a
-INNER JOIN file f ON a.file_id = f.id
-WHERE a.person_id = ?
+FROM credit c
+INNER JOIN file f ON c.file_id = f.id
+WHERE c.person_id = ?
+ORDER BY c.role, f.year, f.title
 `,
-	query.DirectorGetByFile: `
+	query.PersonAliasAdd:         "INSERT INTO person_alias (person_id, name) VALUES (?, ?)",
+	query.PersonAliasGetByPerson: "SELECT name FROM person_alias WHERE person_id = ? ORDER BY name",
+	query.PersonAliasGetAll:      "SELECT person_id, name FROM person_alias ORDER BY name",
+	query.PersonMergeCredits:     "UPDATE OR IGNORE credit SET person_id = ? WHERE person_id = ?",
+	query.PersonMergeURLs:        "UPDATE OR IGNORE person_url SET person_id = ? WHERE person_id = ?",
+	query.PersonMergeAliases:     "UPDATE person_alias SET person_id = ? WHERE person_id = ?",
+	query.PersonPurgeCredits:     "DELETE FROM credit WHERE person_id = ?",
+	query.PersonPurgeURLs:        "DELETE FROM person_url WHERE person_id = ?",
+	query.PersonGetByName: `
+SELECT id, name, birthday FROM person WHERE name = ?1
+UNION ALL
 SELECT
     p.id,
     p.name,
     p.birthday
-FROM director a
+FROM person_alias a
 INNER JOIN person p ON a.person_id = p.id
-WHERE a.file_id = ?
-ORDER BY p.name
+WHERE a.name = ?1
+`,
+	query.CollectionAdd:    "INSERT INTO collection (name, cond) VALUES (?, ?)",
+	query.CollectionUpdate: "UPDATE collection SET name = ?, cond = ? WHERE id = ?",
+	query.CollectionDelete: "DELETE FROM collection WHERE id = ?",
+	query.CollectionGetAll: "SELECT id, name, cond FROM collection ORDER BY name",
+	// The export writes everything in a fixed order, so exporting the
+	// same library twice gives the same result.
+	query.ExportFolders:    "SELECT id, path, last_scan, min_size, suffixes, exclude FROM folder ORDER BY id",
+	query.ExportFiles:      "SELECT id, folder_id, path, title, year, hidden, fingerprint, missing_since, watched, resume_pos, added, rating FROM file ORDER BY id",
+	query.ExportTags:       "SELECT id, name, COALESCE(parent, 0) FROM tag ORDER BY id",
+	query.ExportTagLinks:   "SELECT file_id, tag_id FROM tag_link ORDER BY file_id, tag_id",
+	query.ExportPeople:     "SELECT id, name, birthday FROM person ORDER BY id",
+	query.ExportAliases:    "SELECT person_id, name FROM person_alias ORDER BY person_id, name",
+	query.ExportPersonURLs: "SELECT person_id, url, title, description FROM person_url ORDER BY person_id, url",
+	query.ExportFileURLs:   "SELECT file_id, url, title, description FROM file_url ORDER BY file_id, url",
+	query.ExportCredits:    "SELECT file_id, person_id, role, character, billing FROM credit ORDER BY file_id, role, billing, person_id",
+	// When importing, objects that already exist are reused, the import
+	// only counts how often that happened.
+	query.ImportFolder:       "INSERT INTO folder (path, last_scan, min_size, suffixes, exclude) VALUES (?, ?, ?, ?, ?) ON CONFLICT (path) DO NOTHING",
+	query.ImportFolderLookup: "SELECT id FROM folder WHERE path = ?",
+	query.ImportFile: `INSERT INTO file (folder_id, path, title, year, hidden, fingerprint, missing_since, watched, resume_pos, added, rating)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (path) DO NOTHING`,
+	query.ImportFileLookup:   "SELECT id FROM file WHERE path = ?",
+	query.ImportTag:          "INSERT INTO tag (name) VALUES (?) ON CONFLICT (name) DO NOTHING",
+	query.ImportTagLookup:    "SELECT id FROM tag WHERE name = ?",
+	query.ImportTagLink:      "INSERT OR IGNORE INTO tag_link (file_id, tag_id) VALUES (?, ?)",
+	query.ImportPerson:       "INSERT INTO person (name, birthday) VALUES (?, ?) ON CONFLICT (name) DO NOTHING",
+	query.ImportPersonLookup: "SELECT id FROM person WHERE name = ?",
+	query.ImportAlias:        "INSERT INTO person_alias (person_id, name) VALUES (?, ?) ON CONFLICT (name) DO NOTHING",
+	query.ImportAliasLookup:  "SELECT person_id FROM person_alias WHERE name = ?",
+	query.ImportPersonURL:    "INSERT OR IGNORE INTO person_url (person_id, url, title, description) VALUES (?, ?, ?, ?)",
+	query.ImportFileURL:      "INSERT OR IGNORE INTO file_url (file_id, url, title, description) VALUES (?, ?, ?, ?)",
+	query.ImportCredit:       "INSERT OR IGNORE INTO credit (file_id, person_id, role, character, billing) VALUES (?, ?, ?, ?, ?)",
+	query.TagRename:          "UPDATE tag SET name = ? WHERE id = ?",
+	query.TagMergeLinks:      "UPDATE OR IGNORE tag_link SET tag_id = ? WHERE tag_id = ?",
+	query.TagMergeChildren:   "UPDATE tag SET parent = ?1 WHERE parent = ?2 AND id <> ?1",
+	query.TagPurgeLinks:      "DELETE FROM tag_link WHERE tag_id = ?",
+	// A Tag cannot become a descendant of itself.
+	query.TagSetParent: `
+UPDATE tag
+SET parent = NULLIF(?1, 0)
+WHERE id = ?2
+  AND ?1 NOT IN (
+    WITH RECURSIVE subtree(id) AS (
+        SELECT ?2
+        UNION
+        SELECT t.id FROM tag t INNER JOIN subtree s ON t.parent = s.id
+    )
+    SELECT id FROM subtree
+)
+`,
+	// If the Tag we keep is a descendant of the one we merge into it, it
+	// takes the latter's place in the hierarchy first, otherwise merging
+	// the children would create a cycle.
+	query.TagMergeDetach: `
+UPDATE tag
+SET parent = (SELECT parent FROM tag WHERE id = ?2)
+WHERE id = ?1
+  AND ?1 IN (
+    WITH RECURSIVE subtree(id) AS (
+        SELECT id FROM tag WHERE parent = ?2
+        UNION
+        SELECT t.id FROM tag t INNER JOIN subtree s ON t.parent = s.id
+    )
+    SELECT id FROM subtree
+)
+`,
+	query.ExportViewings: "SELECT file_id, watched, source FROM viewing ORDER BY file_id, watched",
+	query.ImportViewing:  "INSERT OR IGNORE INTO viewing (file_id, watched, source) VALUES (?, ?, ?)",
+	query.ViewingAdd:     "INSERT OR IGNORE INTO viewing (file_id, watched, source) VALUES (?, ?, ?)",
+	// Files we have played ourselves and marked as watched count as
+	// viewings, too, as of the last time we played them.
+	query.ViewingGetAll: `
+SELECT id, file_id, watched, source FROM viewing
+UNION ALL
+SELECT
+    0,
+    p.file_id,
+    MAX(p.start_time),
+    ?
+FROM play_log p
+INNER JOIN file f ON p.file_id = f.id
+WHERE f.watched
+GROUP BY p.file_id
+ORDER BY 3, 2
+`,
+	query.FileSetRating:  "UPDATE file SET rating = ? WHERE id = ?",
+	query.FileGetRatings: "SELECT id, rating FROM file WHERE rating <> 0",
+	query.FileURLGetAll:  "SELECT id, file_id, url, title, description FROM file_url ORDER BY file_id, id",
+
+	// The staging area for the datasets from IMDb.
+	query.IMDbClearTitles:     "DELETE FROM imdb_title",
+	query.IMDbClearKeys:       "DELETE FROM imdb_title_key",
+	query.IMDbClearPrincipals: "DELETE FROM imdb_principal",
+	query.IMDbClearNames:      "DELETE FROM imdb_name",
+	query.IMDbTitleAdd:        "INSERT OR REPLACE INTO imdb_title (id, type, title, original_title, year) VALUES (?, ?, ?, ?, ?)",
+	query.IMDbTitleKeyAdd:     "INSERT OR IGNORE INTO imdb_title_key (key, title_id) VALUES (?, ?)",
+	query.IMDbPrincipalAdd:    "INSERT OR REPLACE INTO imdb_principal (title_id, ordering, name_id, category, characters) VALUES (?, ?, ?, ?, ?)",
+	query.IMDbNameAdd:         "INSERT OR REPLACE INTO imdb_name (id, name, birth_year) VALUES (?, ?, ?)",
+	query.IMDbTitleGetByID:    "SELECT type, title, original_title, year FROM imdb_title WHERE id = ?",
+	query.IMDbTitleGetByKey: `
+SELECT
+    t.id,
+    t.type,
+    t.title,
+    t.original_title,
+    t.year
+FROM imdb_title_key k
+INNER JOIN imdb_title t ON k.title_id = t.id
+WHERE k.key = ?
+ORDER BY t.id
+`,
+	query.IMDbPrincipalGetByTitle: `
+SELECT
+    p.ordering,
+    p.name_id,
+    p.category,
+    p.characters,
+    COALESCE(n.name, ''),
+    COALESCE(n.birth_year, 0)
+FROM imdb_principal p
+LEFT OUTER JOIN imdb_name n ON p.name_id = n.id
+WHERE p.title_id = ?
+ORDER BY p.ordering
+`,
+	query.ScanRunAdd: `
+INSERT INTO scan_run (folder_id, start_time, end_time, status,
+                      dirs, dirs_skipped, files, files_skipped,
+                      files_new, files_changed, errors)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+`,
+	query.ScanRunPrune: `
+DELETE FROM scan_run
+WHERE folder_id = ?
+  AND id NOT IN (SELECT id FROM scan_run
+                 WHERE folder_id = ?
+                 ORDER BY start_time DESC
+                 LIMIT ?)
+`,
+	query.ScanRunGetByFolder: `
+SELECT
+    id,
+    start_time,
+    end_time,
+    status,
+    dirs,
+    dirs_skipped,
+    files,
+    files_skipped,
+    files_new,
+    files_changed,
+    errors
+FROM scan_run
+WHERE folder_id = ?
+ORDER BY start_time DESC
+LIMIT ?
+`,
+	query.ExportSeries:       "SELECT id, title, year FROM series ORDER BY id",
+	query.ExportSeasons:      "SELECT id, series_id, number, title, year FROM season ORDER BY id",
+	query.ExportEpisodes:     "SELECT id, season_id, number, title FROM episode ORDER BY id",
+	query.ExportEpisodeLinks: "SELECT id, episode_id FROM file WHERE episode_id IS NOT NULL ORDER BY id",
+	query.ExportPlayLog:      "SELECT file_id, start_time, end_time, exit_status, position FROM play_log ORDER BY file_id, start_time, id",
+	query.ExportMediaInfo: `
+SELECT
+    file_id,
+    container,
+    duration,
+    width,
+    height,
+    frame_rate,
+    video_codec,
+    probed
+FROM media_info
+ORDER BY file_id
+`,
+	query.ExportMediaTracks:   "SELECT file_id, kind, codec, language, name, channels FROM media_track ORDER BY file_id, id",
+	query.ExportCollections:   "SELECT name, cond FROM collection ORDER BY id",
+	query.ImportSeries:        "INSERT INTO series (title, year) VALUES (?, ?) ON CONFLICT (title) DO NOTHING",
+	query.ImportSeriesLookup:  "SELECT id FROM series WHERE title = ?",
+	query.ImportSeason:        "INSERT INTO season (series_id, number, title, year) VALUES (?, ?, ?, ?) ON CONFLICT (series_id, number) DO NOTHING",
+	query.ImportSeasonLookup:  "SELECT id FROM season WHERE series_id = ? AND number = ?",
+	query.ImportEpisode:       "INSERT INTO episode (season_id, number, title) VALUES (?, ?, ?) ON CONFLICT (season_id, number) DO NOTHING",
+	query.ImportEpisodeLookup: "SELECT id FROM episode WHERE season_id = ? AND number = ?",
+	// A File that already belongs to an Episode keeps it.
+	query.ImportEpisodeLink:       "UPDATE file SET episode_id = ?1 WHERE id = ?2 AND episode_id IS NULL",
+	query.ImportEpisodeLinkLookup: "SELECT COALESCE(episode_id, 0) FROM file WHERE id = ?",
+	// The play log has no natural key, but two playbacks of the same
+	// File do not start in the same second.
+	query.ImportPlayLog: `
+INSERT INTO play_log (file_id, start_time, end_time, exit_status, position)
+SELECT ?1, ?2, ?3, ?4, ?5
+WHERE NOT EXISTS (SELECT 1 FROM play_log WHERE file_id = ?1 AND start_time = ?2)
+`,
+	query.ImportMediaInfo: `
+INSERT INTO media_info (file_id, container, duration, width, height, frame_rate, video_codec, probed)
+                VALUES (      ?,         ?,        ?,     ?,      ?,          ?,           ?,      ?)
+ON CONFLICT (file_id) DO NOTHING
+`,
+	query.ImportMediaTrack: `
+INSERT INTO media_track (file_id, kind, codec, language, name, channels)
+                 VALUES (      ?,    ?,     ?,        ?,    ?,        ?)
 `,
+	query.ImportCollection: "INSERT INTO collection (name, cond) VALUES (?, ?) ON CONFLICT (name) DO NOTHING",
 }
@@ -0,0 +1,1392 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/database/export.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 12. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-14 18:51:26 krylon>
+
+package database
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/blicero/blockbuster/database/query"
+	"github.com/blicero/blockbuster/objects"
+)
+
+// Export writes the library - Folders, Files, Tags, People and their
+// Credits, ratings, imported Viewings, Series, media info, the play log and
+// smart collections - as a single JSON document, so it can be moved to
+// another machine or kept under version control.
+//
+// The document is written and read one element at a time, so neither side
+// needs to hold the whole library in memory. Each element goes on a line of
+// its own, which keeps diffs readable.
+//
+// IDs in the document have nothing to do with the IDs in the database,
+// objects of each kind are numbered consecutively, starting at 1. That way,
+// exporting a library, importing it elsewhere and exporting it again gives
+// the same document.
+// Collections refer to Tags and People by their ID, too, so their
+// Conditions are translated the same way.
+
+const (
+	exportFormat  = "blockbuster-library"
+	exportVersion = 2
+)
+
+// ErrExportFormat is returned when a document to import is not something
+// Export has written.
+var ErrExportFormat = errors.New("not a library export")
+
+type exportFolder struct {
+	ID       int64  `json:"id"`
+	Path     string `json:"path"`
+	LastScan int64  `json:"last_scan"`
+	MinSize  int64  `json:"min_size"`
+	Suffixes string `json:"suffixes"`
+	Exclude  string `json:"exclude"`
+}
+
+type exportFile struct {
+	ID           int64  `json:"id"`
+	Folder       int64  `json:"folder"`
+	Path         string `json:"path"`
+	Title        string `json:"title"`
+	Year         int64  `json:"year"`
+	Hidden       bool   `json:"hidden"`
+	Fingerprint  string `json:"fingerprint"`
+	MissingSince int64  `json:"missing_since"`
+	Watched      bool   `json:"watched"`
+	ResumePos    int64  `json:"resume_pos"`
+	Added        int64  `json:"added"`
+	Rating       int64  `json:"rating,omitempty"`
+}
+
+type exportTag struct {
+	ID     int64  `json:"id"`
+	Name   string `json:"name"`
+	Parent int64  `json:"parent,omitempty"`
+}
+
+type exportTagLink struct {
+	File int64 `json:"file"`
+	Tag  int64 `json:"tag"`
+}
+
+type exportPerson struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Birthday int64  `json:"birthday"`
+}
+
+type exportAlias struct {
+	Person int64  `json:"person"`
+	Name   string `json:"name"`
+}
+
+// exportURL is used for the URLs of both Files and People, Owner refers to
+// one or the other, depending on the section.
+type exportURL struct {
+	Owner       int64  `json:"owner"`
+	URL         string `json:"url"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+type exportCredit struct {
+	File      int64  `json:"file"`
+	Person    int64  `json:"person"`
+	Role      int64  `json:"role"`
+	Character string `json:"character"`
+	Billing   int64  `json:"billing"`
+}
+
+type exportViewing struct {
+	File    int64  `json:"file"`
+	Watched int64  `json:"watched"`
+	Source  string `json:"source"`
+}
+
+type exportSeries struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+	Year  int64  `json:"year"`
+}
+
+type exportSeason struct {
+	ID     int64  `json:"id"`
+	Series int64  `json:"series"`
+	Number int64  `json:"number"`
+	Title  string `json:"title"`
+	Year   int64  `json:"year"`
+}
+
+type exportEpisode struct {
+	ID     int64  `json:"id"`
+	Season int64  `json:"season"`
+	Number int64  `json:"number"`
+	Title  string `json:"title"`
+}
+
+type exportEpisodeLink struct {
+	File    int64 `json:"file"`
+	Episode int64 `json:"episode"`
+}
+
+type exportPlay struct {
+	File       int64 `json:"file"`
+	Start      int64 `json:"start"`
+	End        int64 `json:"end"`
+	ExitStatus int64 `json:"exit_status"`
+	Position   int64 `json:"position"`
+}
+
+type exportMediaInfo struct {
+	File       int64   `json:"file"`
+	Container  string  `json:"container"`
+	Duration   int64   `json:"duration"`
+	Width      int64   `json:"width"`
+	Height     int64   `json:"height"`
+	FrameRate  float64 `json:"frame_rate"`
+	VideoCodec string  `json:"video_codec"`
+	Probed     int64   `json:"probed"`
+}
+
+type exportTrack struct {
+	File     int64  `json:"file"`
+	Kind     int64  `json:"kind"`
+	Codec    string `json:"codec"`
+	Language string `json:"language"`
+	Name     string `json:"name"`
+	Channels int64  `json:"channels"`
+}
+
+type exportCollection struct {
+	Name string            `json:"name"`
+	Cond objects.Condition `json:"cond"`
+}
+
+// exportWriter writes the sections of the document.
+type exportWriter struct {
+	w   *bufio.Writer
+	cnt int
+}
+
+func (ew *exportWriter) begin(name string) {
+	ew.cnt = 0
+	fmt.Fprintf(ew.w, ",\n%q: [", name) // nolint: errcheck
+} // func (ew *exportWriter) begin(name string)
+
+func (ew *exportWriter) add(item interface{}) error {
+	var (
+		err error
+		buf []byte
+	)
+
+	if buf, err = json.Marshal(item); err != nil {
+		return err
+	}
+
+	if ew.cnt > 0 {
+		ew.w.WriteByte(',') // nolint: errcheck
+	}
+
+	ew.cnt++
+	ew.w.WriteByte('\n') // nolint: errcheck
+	_, err = ew.w.Write(buf)
+	return err
+} // func (ew *exportWriter) add(item interface{}) error
+
+func (ew *exportWriter) end() {
+	if ew.cnt > 0 {
+		ew.w.WriteByte('\n') // nolint: errcheck
+	}
+	ew.w.WriteByte(']') // nolint: errcheck
+} // func (ew *exportWriter) end()
+
+// exportQuery runs one of the Export queries and calls fn for every row.
+func (db *Database) exportQuery(tx *sql.Tx, qid query.ID, fn func(*sql.Rows) error) error {
+	var (
+		err  error
+		stmt *sql.Stmt
+		rows *sql.Rows
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return err
+	}
+
+	stmt = tx.Stmt(stmt)
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		db.log.Printf("[ERROR] Cannot query database (%s): %s\n",
+			qid.String(),
+			err.Error())
+		return err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+
+	for rows.Next() {
+		if err = fn(rows); err != nil {
+			db.log.Printf("[ERROR] Cannot export row (%s): %s\n",
+				qid.String(),
+				err.Error())
+			return err
+		}
+	}
+
+	return rows.Err()
+} // func (db *Database) exportQuery(tx *sql.Tx, qid query.ID, fn func(*sql.Rows) error) error
+
+// remap translates an ID from the database to the document on export, and
+// the other way around on import.
+func remap(ids map[int64]int64, kind string, id int64) (int64, error) {
+	if n, ok := ids[id]; ok {
+		return n, nil
+	}
+
+	return 0, fmt.Errorf("Reference to unknown %s %d", kind, id)
+} // func remap(ids map[int64]int64, kind string, id int64) (int64, error)
+
+// remapCondition translates the IDs of the Tags and People a Condition
+// refers to, including those of its sub-Conditions.
+// Tags and People can be deleted while a Collection still refers to them.
+// Such a Condition matches nothing, and neither does one that refers to ID
+// 0, so unless strict is set, we translate IDs we do not know to 0.
+func remapCondition(c *objects.Condition, tags, people map[int64]int64, strict bool) error {
+	var (
+		err  error
+		ids  map[int64]int64
+		kind string
+	)
+
+	switch c.Kind {
+	case objects.CondTag:
+		ids, kind = tags, "Tag"
+	case objects.CondPerson:
+		ids, kind = people, "Person"
+	}
+
+	if ids != nil && c.ID != 0 {
+		if c.ID, err = remap(ids, kind, c.ID); err != nil && strict {
+			return err
+		}
+	}
+
+	for idx := range c.Sub {
+		if err = remapCondition(&c.Sub[idx], tags, people, strict); err != nil {
+			return err
+		}
+	}
+
+	return nil
+} // func remapCondition(c *objects.Condition, tags, people map[int64]int64, strict bool) error
+
+// Export writes the library to w. Everything is read within a single
+// transaction, so the export is consistent even if the library is modified
+// at the same time.
+func (db *Database) Export(w io.Writer) error {
+	var (
+		err     error
+		msg     string
+		tx      *sql.Tx
+		folders = make(map[int64]int64)
+		files   = make(map[int64]int64)
+		tags    = make(map[int64]int64)
+		people  = make(map[int64]int64)
+		series  = make(map[int64]int64)
+		seasons = make(map[int64]int64)
+		eps     = make(map[int64]int64)
+		ew      = &exportWriter{w: bufio.NewWriter(w)}
+	)
+
+	if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+		}
+
+		// We do not change anything, so there is nothing to commit.
+		defer tx.Rollback() // nolint: errcheck
+	}
+
+	fmt.Fprintf(ew.w, "{\"format\": %q, \"version\": %d", // nolint: errcheck
+		exportFormat,
+		exportVersion)
+
+	ew.begin("folders")
+	if err = db.exportQuery(tx, query.ExportFolders, func(rows *sql.Rows) error {
+		var f exportFolder
+		if err := rows.Scan(&f.ID, &f.Path, &f.LastScan, &f.MinSize, &f.Suffixes, &f.Exclude); err != nil {
+			return err
+		}
+		folders[f.ID] = int64(len(folders) + 1)
+		f.ID = folders[f.ID]
+		return ew.add(&f)
+	}); err != nil {
+		return err
+	}
+	ew.end()
+
+	ew.begin("files")
+	if err = db.exportQuery(tx, query.ExportFiles, func(rows *sql.Rows) error {
+		var (
+			err error
+			f   exportFile
+		)
+		if err = rows.Scan(
+			&f.ID,
+			&f.Folder,
+			&f.Path,
+			&f.Title,
+			&f.Year,
+			&f.Hidden,
+			&f.Fingerprint,
+			&f.MissingSince,
+			&f.Watched,
+			&f.ResumePos,
+			&f.Added,
+			&f.Rating); err != nil {
+			return err
+		} else if f.Folder, err = remap(folders, "Folder", f.Folder); err != nil {
+			return err
+		}
+		files[f.ID] = int64(len(files) + 1)
+		f.ID = files[f.ID]
+		return ew.add(&f)
+	}); err != nil {
+		return err
+	}
+	ew.end()
+
+	// A Tag's parent may well have been created after the Tag itself, so
+	// we need to number all Tags before we can write any of them.
+	if err = db.exportQuery(tx, query.ExportTags, func(rows *sql.Rows) error {
+		var t exportTag
+		if err := rows.Scan(&t.ID, &t.Name, &t.Parent); err != nil {
+			return err
+		}
+		tags[t.ID] = int64(len(tags) + 1)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	ew.begin("tags")
+	if err = db.exportQuery(tx, query.ExportTags, func(rows *sql.Rows) error {
+		var (
+			err error
+			t   exportTag
+		)
+		if err = rows.Scan(&t.ID, &t.Name, &t.Parent); err != nil {
+			return err
+		} else if t.ID, err = remap(tags, "Tag", t.ID); err != nil {
+			return err
+		} else if t.Parent != 0 {
+			if t.Parent, err = remap(tags, "Tag", t.Parent); err != nil {
+				return err
+			}
+		}
+		return ew.add(&t)
+	}); err != nil {
+		return err
+	}
+	ew.end()
+
+	ew.begin("tag_links")
+	if err = db.exportQuery(tx, query.ExportTagLinks, func(rows *sql.Rows) error {
+		var (
+			err error
+			l   exportTagLink
+		)
+		if err = rows.Scan(&l.File, &l.Tag); err != nil {
+			return err
+		} else if l.File, err = remap(files, "File", l.File); err != nil {
+			return err
+		} else if l.Tag, err = remap(tags, "Tag", l.Tag); err != nil {
+			return err
+		}
+		return ew.add(&l)
+	}); err != nil {
+		return err
+	}
+	ew.end()
+
+	ew.begin("people")
+	if err = db.exportQuery(tx, query.ExportPeople, func(rows *sql.Rows) error {
+		var p exportPerson
+		if err := rows.Scan(&p.ID, &p.Name, &p.Birthday); err != nil {
+			return err
+		}
+		people[p.ID] = int64(len(people) + 1)
+		p.ID = people[p.ID]
+		return ew.add(&p)
+	}); err != nil {
+		return err
+	}
+	ew.end()
+
+	ew.begin("aliases")
+	if err = db.exportQuery(tx, query.ExportAliases, func(rows *sql.Rows) error {
+		var (
+			err error
+			a   exportAlias
+		)
+		if err = rows.Scan(&a.Person, &a.Name); err != nil {
+			return err
+		} else if a.Person, err = remap(people, "Person", a.Person); err != nil {
+			return err
+		}
+		return ew.add(&a)
+	}); err != nil {
+		return err
+	}
+	ew.end()
+
+	var urlSections = []struct {
+		name string
+		qid  query.ID
+		kind string
+		ids  map[int64]int64
+	}{
+		{"person_urls", query.ExportPersonURLs, "Person", people},
+		{"file_urls", query.ExportFileURLs, "File", files},
+	}
+
+	for _, s := range urlSections {
+		ew.begin(s.name)
+		if err = db.exportQuery(tx, s.qid, func(rows *sql.Rows) error {
+			var (
+				err error
+				u   exportURL
+			)
+			if err = rows.Scan(&u.Owner, &u.URL, &u.Title, &u.Description); err != nil {
+				return err
+			} else if u.Owner, err = remap(s.ids, s.kind, u.Owner); err != nil {
+				return err
+			}
+			return ew.add(&u)
+		}); err != nil {
+			return err
+		}
+		ew.end()
+	}
+
+	ew.begin("credits")
+	if err = db.exportQuery(tx, query.ExportCredits, func(rows *sql.Rows) error {
+		var (
+			err error
+			c   exportCredit
+		)
+		if err = rows.Scan(&c.File, &c.Person, &c.Role, &c.Character, &c.Billing); err != nil {
+			return err
+		} else if c.File, err = remap(files, "File", c.File); err != nil {
+			return err
+		} else if c.Person, err = remap(people, "Person", c.Person); err != nil {
+			return err
+		}
+		return ew.add(&c)
+	}); err != nil {
+		return err
+	}
+	ew.end()
+
+	ew.begin("viewings")
+	if err = db.exportQuery(tx, query.ExportViewings, func(rows *sql.Rows) error {
+		var (
+			err error
+			v   exportViewing
+		)
+		if err = rows.Scan(&v.File, &v.Watched, &v.Source); err != nil {
+			return err
+		} else if v.File, err = remap(files, "File", v.File); err != nil {
+			return err
+		}
+		return ew.add(&v)
+	}); err != nil {
+		return err
+	}
+	ew.end()
+
+	ew.begin("series")
+	if err = db.exportQuery(tx, query.ExportSeries, func(rows *sql.Rows) error {
+		var s exportSeries
+		if err := rows.Scan(&s.ID, &s.Title, &s.Year); err != nil {
+			return err
+		}
+		series[s.ID] = int64(len(series) + 1)
+		s.ID = series[s.ID]
+		return ew.add(&s)
+	}); err != nil {
+		return err
+	}
+	ew.end()
+
+	ew.begin("seasons")
+	if err = db.exportQuery(tx, query.ExportSeasons, func(rows *sql.Rows) error {
+		var (
+			err error
+			s   exportSeason
+		)
+		if err = rows.Scan(&s.ID, &s.Series, &s.Number, &s.Title, &s.Year); err != nil {
+			return err
+		} else if s.Series, err = remap(series, "Series", s.Series); err != nil {
+			return err
+		}
+		seasons[s.ID] = int64(len(seasons) + 1)
+		s.ID = seasons[s.ID]
+		return ew.add(&s)
+	}); err != nil {
+		return err
+	}
+	ew.end()
+
+	ew.begin("episodes")
+	if err = db.exportQuery(tx, query.ExportEpisodes, func(rows *sql.Rows) error {
+		var (
+			err error
+			e   exportEpisode
+		)
+		if err = rows.Scan(&e.ID, &e.Season, &e.Number, &e.Title); err != nil {
+			return err
+		} else if e.Season, err = remap(seasons, "Season", e.Season); err != nil {
+			return err
+		}
+		eps[e.ID] = int64(len(eps) + 1)
+		e.ID = eps[e.ID]
+		return ew.add(&e)
+	}); err != nil {
+		return err
+	}
+	ew.end()
+
+	ew.begin("episode_links")
+	if err = db.exportQuery(tx, query.ExportEpisodeLinks, func(rows *sql.Rows) error {
+		var (
+			err error
+			l   exportEpisodeLink
+		)
+		if err = rows.Scan(&l.File, &l.Episode); err != nil {
+			return err
+		} else if l.File, err = remap(files, "File", l.File); err != nil {
+			return err
+		} else if l.Episode, err = remap(eps, "Episode", l.Episode); err != nil {
+			return err
+		}
+		return ew.add(&l)
+	}); err != nil {
+		return err
+	}
+	ew.end()
+
+	ew.begin("play_log")
+	if err = db.exportQuery(tx, query.ExportPlayLog, func(rows *sql.Rows) error {
+		var (
+			err error
+			p   exportPlay
+		)
+		if err = rows.Scan(&p.File, &p.Start, &p.End, &p.ExitStatus, &p.Position); err != nil {
+			return err
+		} else if p.File, err = remap(files, "File", p.File); err != nil {
+			return err
+		}
+		return ew.add(&p)
+	}); err != nil {
+		return err
+	}
+	ew.end()
+
+	ew.begin("media_info")
+	if err = db.exportQuery(tx, query.ExportMediaInfo, func(rows *sql.Rows) error {
+		var (
+			err error
+			m   exportMediaInfo
+		)
+		if err = rows.Scan(
+			&m.File,
+			&m.Container,
+			&m.Duration,
+			&m.Width,
+			&m.Height,
+			&m.FrameRate,
+			&m.VideoCodec,
+			&m.Probed); err != nil {
+			return err
+		} else if m.File, err = remap(files, "File", m.File); err != nil {
+			return err
+		}
+		return ew.add(&m)
+	}); err != nil {
+		return err
+	}
+	ew.end()
+
+	ew.begin("media_tracks")
+	if err = db.exportQuery(tx, query.ExportMediaTracks, func(rows *sql.Rows) error {
+		var (
+			err error
+			t   exportTrack
+		)
+		if err = rows.Scan(&t.File, &t.Kind, &t.Codec, &t.Language, &t.Name, &t.Channels); err != nil {
+			return err
+		} else if t.File, err = remap(files, "File", t.File); err != nil {
+			return err
+		}
+		return ew.add(&t)
+	}); err != nil {
+		return err
+	}
+	ew.end()
+
+	ew.begin("collections")
+	if err = db.exportQuery(tx, query.ExportCollections, func(rows *sql.Rows) error {
+		var (
+			err error
+			raw []byte
+			c   exportCollection
+		)
+		if err = rows.Scan(&c.Name, &raw); err != nil {
+			return err
+		} else if err = json.Unmarshal(raw, &c.Cond); err != nil {
+			return fmt.Errorf("Cannot parse Condition of Collection %s: %s",
+				c.Name,
+				err.Error())
+		} else if err = remapCondition(&c.Cond, tags, people, false); err != nil {
+			return err
+		}
+		return ew.add(&c)
+	}); err != nil {
+		return err
+	}
+	ew.end()
+
+	ew.w.WriteString("\n}\n") // nolint: errcheck
+
+	if err = ew.w.Flush(); err != nil {
+		db.log.Printf("[ERROR] Cannot write export: %s\n",
+			err.Error())
+		return err
+	}
+
+	db.log.Printf("[INFO] Exported %d Files, %d Tags and %d People\n",
+		len(files),
+		len(tags),
+		len(people))
+
+	return nil
+} // func (db *Database) Export(w io.Writer) error
+
+// ImportReport sums up what an import did. Added counts the objects that
+// were added to the database, per section. Objects that were already in the
+// database are kept as they are, Conflicts lists them along with anything
+// else from the document that could not be imported as it was.
+type ImportReport struct {
+	Added     map[string]int
+	Conflicts []string
+}
+
+func (r *ImportReport) conflict(format string, args ...interface{}) {
+	r.Conflicts = append(r.Conflicts, fmt.Sprintf(format, args...))
+} // func (r *ImportReport) conflict(format string, args ...interface{})
+
+// importer holds the state while we work our way through an import.
+type importer struct {
+	db      *Database
+	tx      *sql.Tx
+	dec     *json.Decoder
+	stmts   map[query.ID]*sql.Stmt
+	report  *ImportReport
+	folders map[int64]int64
+	files   map[int64]int64
+	tags    map[int64]int64
+	people  map[int64]int64
+	series  map[int64]int64
+	seasons map[int64]int64
+	eps     map[int64]int64
+	// probed holds the Files whose media info the import has added, only
+	// those get the media tracks from the document.
+	probed map[int64]bool
+}
+
+func (im *importer) stmt(qid query.ID) (*sql.Stmt, error) {
+	if s, ok := im.stmts[qid]; ok {
+		return s, nil
+	}
+
+	var s, err = im.db.getQuery(qid)
+
+	if err != nil {
+		im.db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return nil, err
+	}
+
+	s = im.tx.Stmt(s)
+	im.stmts[qid] = s
+	return s, nil
+} // func (im *importer) stmt(qid query.ID) (*sql.Stmt, error)
+
+// exec runs one of the Import queries and returns the number of rows it
+// has added or changed.
+func (im *importer) exec(qid query.ID, args ...interface{}) (int64, error) {
+	var (
+		err  error
+		stmt *sql.Stmt
+		res  sql.Result
+	)
+
+	if stmt, err = im.stmt(qid); err != nil {
+		return 0, err
+	}
+
+EXEC_QUERY:
+	if res, err = stmt.Exec(args...); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return 0, fmt.Errorf("Cannot import %v (%s): %s",
+			args,
+			qid.String(),
+			err.Error())
+	}
+
+	return res.RowsAffected()
+} // func (im *importer) exec(qid query.ID, args ...interface{}) (int64, error)
+
+// lookup runs one of the queries to find the ID of an object that is
+// already in the database.
+func (im *importer) lookup(qid query.ID, key ...interface{}) (int64, error) {
+	var (
+		err  error
+		id   int64
+		stmt *sql.Stmt
+	)
+
+	if stmt, err = im.stmt(qid); err != nil {
+		return 0, err
+	}
+
+EXEC_QUERY:
+	if err = stmt.QueryRow(key...).Scan(&id); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return 0, fmt.Errorf("Cannot look up %v (%s): %s",
+			key,
+			qid.String(),
+			err.Error())
+	}
+
+	return id, nil
+} // func (im *importer) lookup(qid query.ID, key ...interface{}) (int64, error)
+
+// add inserts an object unless it already exists, identified by key.
+// Either way it returns the object's ID in the database and records it in
+// ids under the ID from the document.
+func (im *importer) add(section string, ids map[int64]int64, docID int64, key string, qid, lookup query.ID, args ...interface{}) (bool, error) {
+	return im.addBy(section, ids, docID, []interface{}{key}, qid, lookup, args...)
+} // func (im *importer) add(section string, ids map[int64]int64, docID int64, key string, qid, lookup query.ID, args ...interface{}) (bool, error)
+
+// addBy works like add for objects that are identified by more than one
+// column, like the Seasons of a Series.
+func (im *importer) addBy(section string, ids map[int64]int64, docID int64, key []interface{}, qid, lookup query.ID, args ...interface{}) (bool, error) {
+	var (
+		err   error
+		cnt   int64
+		dbID  int64
+		added bool
+	)
+
+	if _, ok := ids[docID]; ok {
+		return false, fmt.Errorf("Duplicate ID %d in %s", docID, section)
+	} else if cnt, err = im.exec(qid, args...); err != nil {
+		return false, err
+	} else if dbID, err = im.lookup(lookup, key...); err != nil {
+		return false, err
+	}
+
+	if added = cnt > 0; added {
+		im.report.Added[section]++
+	}
+
+	ids[docID] = dbID
+	return added, nil
+} // func (im *importer) addBy(section string, ids map[int64]int64, docID int64, key []interface{}, qid, lookup query.ID, args ...interface{}) (bool, error)
+
+// expectDelim reads the next token and makes sure it is the given
+// delimiter.
+func (im *importer) expectDelim(d json.Delim) error {
+	var tok, err = im.dec.Token()
+
+	if err != nil {
+		return err
+	} else if tok != d {
+		return fmt.Errorf("%w: expected %s, got %v", ErrExportFormat, d, tok)
+	}
+
+	return nil
+} // func (im *importer) expectDelim(d json.Delim) error
+
+// section reads an array, decoding one element at a time into a value
+// created by mk and passing it to fn.
+func (im *importer) section(mk func() interface{}, fn func(interface{}) error) error {
+	var err error
+
+	if err = im.expectDelim('['); err != nil {
+		return err
+	}
+
+	for im.dec.More() {
+		var item = mk()
+
+		if err = im.dec.Decode(item); err != nil {
+			return err
+		} else if err = fn(item); err != nil {
+			return err
+		}
+	}
+
+	return im.expectDelim(']')
+} // func (im *importer) section(mk func() interface{}, fn func(interface{}) error) error
+
+// Import reads a document written by Export and adds its content to the
+// database. Folders, Files, Tags, People, Series and Collections that
+// already exist - going by their path, name or title - are kept as they
+// are, and the Tags, Credits, etc. from the document are added to them.
+// The import happens in a single transaction, if anything goes wrong, the
+// database is left untouched.
+func (db *Database) Import(r io.Reader) (*ImportReport, error) {
+	var (
+		err     error
+		msg     string
+		tx      *sql.Tx
+		status  bool
+		tok     json.Token
+		format  string
+		version float64
+		parents []exportTag
+		im      = &importer{
+			db:      db,
+			dec:     json.NewDecoder(bufio.NewReader(r)),
+			stmts:   make(map[query.ID]*sql.Stmt),
+			report:  &ImportReport{Added: make(map[string]int)},
+			folders: make(map[int64]int64),
+			files:   make(map[int64]int64),
+			tags:    make(map[int64]int64),
+			people:  make(map[int64]int64),
+			series:  make(map[int64]int64),
+			seasons: make(map[int64]int64),
+			eps:     make(map[int64]int64),
+			probed:  make(map[int64]bool),
+		}
+	)
+
+	if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return nil, errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	im.tx = tx
+
+	if err = im.expectDelim('{'); err != nil {
+		goto ERROR
+	}
+
+	for im.dec.More() {
+		var key string
+
+		if tok, err = im.dec.Token(); err != nil {
+			goto ERROR
+		}
+
+		key, _ = tok.(string)
+
+		switch key {
+		case "format":
+			err = im.dec.Decode(&format)
+		case "version":
+			err = im.dec.Decode(&version)
+		default:
+			// The header has to come first, we do not want to find out
+			// halfway through that we do not understand the document.
+			if format != exportFormat {
+				err = fmt.Errorf("%w: format is %q", ErrExportFormat, format)
+			} else if version < 1 || version > exportVersion {
+				err = fmt.Errorf("%w: cannot import version %v", ErrExportFormat, version)
+			}
+		}
+
+		if err != nil {
+			goto ERROR
+		}
+
+		switch key {
+		case "format", "version":
+			continue
+
+		case "folders":
+			err = im.section(func() interface{} { return new(exportFolder) }, func(item interface{}) error {
+				var (
+					f          = item.(*exportFolder)
+					added, err = im.add(key, im.folders, f.ID, f.Path,
+						query.ImportFolder, query.ImportFolderLookup,
+						f.Path, f.LastScan, f.MinSize, f.Suffixes, f.Exclude)
+				)
+				if err == nil && !added {
+					im.report.conflict("Folder %s already exists", f.Path)
+				}
+				return err
+			})
+
+		case "files":
+			err = im.section(func() interface{} { return new(exportFile) }, func(item interface{}) error {
+				var (
+					err    error
+					added  bool
+					folder int64
+					f      = item.(*exportFile)
+				)
+				if folder, err = remap(im.folders, "Folder", f.Folder); err != nil {
+					return err
+				} else if added, err = im.add(key, im.files, f.ID, f.Path,
+					query.ImportFile, query.ImportFileLookup,
+					folder,
+					f.Path,
+					f.Title,
+					f.Year,
+					f.Hidden,
+					f.Fingerprint,
+					f.MissingSince,
+					f.Watched,
+					f.ResumePos,
+					f.Added,
+					f.Rating); err != nil {
+					return err
+				} else if !added {
+					im.report.conflict("File %s already exists, keeping title, year and state from the database",
+						f.Path)
+				}
+				return nil
+			})
+
+		case "tags":
+			// Parents are assigned once we know all the Tags.
+			err = im.section(func() interface{} { return new(exportTag) }, func(item interface{}) error {
+				var (
+					t          = item.(*exportTag)
+					added, err = im.add(key, im.tags, t.ID, t.Name,
+						query.ImportTag, query.ImportTagLookup,
+						t.Name)
+				)
+				if err != nil {
+					return err
+				} else if !added {
+					im.report.conflict("Tag %s already exists, keeping its place in the hierarchy",
+						t.Name)
+				} else if t.Parent != 0 {
+					parents = append(parents, *t)
+				}
+				return nil
+			})
+
+			for _, p := range parents {
+				var (
+					cnt          int64
+					tagID, parID int64
+				)
+
+				if err != nil {
+					break
+				} else if tagID, err = remap(im.tags, "Tag", p.ID); err != nil {
+					break
+				} else if parID, err = remap(im.tags, "Tag", p.Parent); err != nil {
+					break
+				} else if cnt, err = im.exec(query.TagSetParent, parID, tagID); err != nil {
+					break
+				} else if cnt == 0 {
+					im.report.conflict("Cannot move Tag %s below Tag #%d, that would make a cycle",
+						p.Name,
+						p.Parent)
+				}
+			}
+
+		case "tag_links":
+			err = im.section(func() interface{} { return new(exportTagLink) }, func(item interface{}) error {
+				var (
+					err         error
+					cnt         int64
+					file, tagID int64
+					l           = item.(*exportTagLink)
+				)
+				if file, err = remap(im.files, "File", l.File); err != nil {
+					return err
+				} else if tagID, err = remap(im.tags, "Tag", l.Tag); err != nil {
+					return err
+				} else if cnt, err = im.exec(query.ImportTagLink, file, tagID); err != nil {
+					return err
+				}
+				im.report.Added[key] += int(cnt)
+				return nil
+			})
+
+		case "people":
+			err = im.section(func() interface{} { return new(exportPerson) }, func(item interface{}) error {
+				var (
+					p          = item.(*exportPerson)
+					added, err = im.add(key, im.people, p.ID, p.Name,
+						query.ImportPerson, query.ImportPersonLookup,
+						p.Name, p.Birthday)
+				)
+				if err == nil && !added {
+					im.report.conflict("Person %s already exists", p.Name)
+				}
+				return err
+			})
+
+		case "aliases":
+			err = im.section(func() interface{} { return new(exportAlias) }, func(item interface{}) error {
+				var (
+					err            error
+					cnt            int64
+					person, holder int64
+					a              = item.(*exportAlias)
+				)
+				if person, err = remap(im.people, "Person", a.Person); err != nil {
+					return err
+				} else if cnt, err = im.exec(query.ImportAlias, person, a.Name); err != nil {
+					return err
+				} else if cnt > 0 {
+					im.report.Added[key]++
+				} else if holder, err = im.lookup(query.ImportAliasLookup, a.Name); err != nil {
+					return err
+				} else if holder != person {
+					im.report.conflict("Alias %s is already taken by Person #%d",
+						a.Name,
+						holder)
+				}
+				return nil
+			})
+
+		case "person_urls", "file_urls":
+			var (
+				qid  = query.ImportPersonURL
+				kind = "Person"
+				ids  = im.people
+			)
+
+			if key == "file_urls" {
+				qid, kind, ids = query.ImportFileURL, "File", im.files
+			}
+
+			err = im.section(func() interface{} { return new(exportURL) }, func(item interface{}) error {
+				var (
+					err   error
+					cnt   int64
+					owner int64
+					u     = item.(*exportURL)
+				)
+				if owner, err = remap(ids, kind, u.Owner); err != nil {
+					return err
+				} else if cnt, err = im.exec(qid, owner, u.URL, u.Title, u.Description); err != nil {
+					return err
+				}
+				im.report.Added[key] += int(cnt)
+				return nil
+			})
+
+		case "credits":
+			err = im.section(func() interface{} { return new(exportCredit) }, func(item interface{}) error {
+				var (
+					err          error
+					cnt          int64
+					file, person int64
+					c            = item.(*exportCredit)
+				)
+				if file, err = remap(im.files, "File", c.File); err != nil {
+					return err
+				} else if person, err = remap(im.people, "Person", c.Person); err != nil {
+					return err
+				} else if cnt, err = im.exec(query.ImportCredit, file, person, c.Role, c.Character, c.Billing); err != nil {
+					return err
+				}
+				im.report.Added[key] += int(cnt)
+				return nil
+			})
+
+		case "viewings":
+			err = im.section(func() interface{} { return new(exportViewing) }, func(item interface{}) error {
+				var (
+					err  error
+					cnt  int64
+					file int64
+					v    = item.(*exportViewing)
+				)
+				if file, err = remap(im.files, "File", v.File); err != nil {
+					return err
+				} else if cnt, err = im.exec(query.ImportViewing, file, v.Watched, v.Source); err != nil {
+					return err
+				}
+				im.report.Added[key] += int(cnt)
+				return nil
+			})
+
+		case "series":
+			err = im.section(func() interface{} { return new(exportSeries) }, func(item interface{}) error {
+				var (
+					s          = item.(*exportSeries)
+					added, err = im.add(key, im.series, s.ID, s.Title,
+						query.ImportSeries, query.ImportSeriesLookup,
+						s.Title, s.Year)
+				)
+				if err == nil && !added {
+					im.report.conflict("Series %s already exists", s.Title)
+				}
+				return err
+			})
+
+		case "seasons":
+			err = im.section(func() interface{} { return new(exportSeason) }, func(item interface{}) error {
+				var (
+					err    error
+					added  bool
+					series int64
+					s      = item.(*exportSeason)
+				)
+				if series, err = remap(im.series, "Series", s.Series); err != nil {
+					return err
+				} else if added, err = im.addBy(key, im.seasons, s.ID,
+					[]interface{}{series, s.Number},
+					query.ImportSeason, query.ImportSeasonLookup,
+					series, s.Number, s.Title, s.Year); err != nil {
+					return err
+				} else if !added {
+					im.report.conflict("Season %d of Series #%d already exists",
+						s.Number,
+						s.Series)
+				}
+				return nil
+			})
+
+		case "episodes":
+			err = im.section(func() interface{} { return new(exportEpisode) }, func(item interface{}) error {
+				var (
+					err    error
+					added  bool
+					season int64
+					e      = item.(*exportEpisode)
+				)
+				if season, err = remap(im.seasons, "Season", e.Season); err != nil {
+					return err
+				} else if added, err = im.addBy(key, im.eps, e.ID,
+					[]interface{}{season, e.Number},
+					query.ImportEpisode, query.ImportEpisodeLookup,
+					season, e.Number, e.Title); err != nil {
+					return err
+				} else if !added {
+					im.report.conflict("Episode %d of Season #%d already exists",
+						e.Number,
+						e.Season)
+				}
+				return nil
+			})
+
+		case "episode_links":
+			err = im.section(func() interface{} { return new(exportEpisodeLink) }, func(item interface{}) error {
+				var (
+					err               error
+					cnt               int64
+					file, ep, current int64
+					l                 = item.(*exportEpisodeLink)
+				)
+				if file, err = remap(im.files, "File", l.File); err != nil {
+					return err
+				} else if ep, err = remap(im.eps, "Episode", l.Episode); err != nil {
+					return err
+				} else if cnt, err = im.exec(query.ImportEpisodeLink, ep, file); err != nil {
+					return err
+				} else if cnt > 0 {
+					im.report.Added[key]++
+				} else if current, err = im.lookup(query.ImportEpisodeLinkLookup, file); err != nil {
+					return err
+				} else if current != ep {
+					im.report.conflict("File #%d already belongs to another Episode",
+						l.File)
+				}
+				return nil
+			})
+
+		case "play_log":
+			err = im.section(func() interface{} { return new(exportPlay) }, func(item interface{}) error {
+				var (
+					err  error
+					cnt  int64
+					file int64
+					p    = item.(*exportPlay)
+				)
+				if file, err = remap(im.files, "File", p.File); err != nil {
+					return err
+				} else if cnt, err = im.exec(query.ImportPlayLog, file, p.Start, p.End, p.ExitStatus, p.Position); err != nil {
+					return err
+				}
+				im.report.Added[key] += int(cnt)
+				return nil
+			})
+
+		case "media_info":
+			// Media info we already have was probed from the File
+			// itself, so we keep it.
+			err = im.section(func() interface{} { return new(exportMediaInfo) }, func(item interface{}) error {
+				var (
+					err  error
+					cnt  int64
+					file int64
+					m    = item.(*exportMediaInfo)
+				)
+				if file, err = remap(im.files, "File", m.File); err != nil {
+					return err
+				} else if cnt, err = im.exec(query.ImportMediaInfo,
+					file,
+					m.Container,
+					m.Duration,
+					m.Width,
+					m.Height,
+					m.FrameRate,
+					m.VideoCodec,
+					m.Probed); err != nil {
+					return err
+				} else if cnt > 0 {
+					im.report.Added[key]++
+					im.probed[file] = true
+				}
+				return nil
+			})
+
+		case "media_tracks":
+			err = im.section(func() interface{} { return new(exportTrack) }, func(item interface{}) error {
+				var (
+					err  error
+					cnt  int64
+					file int64
+					t    = item.(*exportTrack)
+				)
+				if file, err = remap(im.files, "File", t.File); err != nil {
+					return err
+				} else if !im.probed[file] {
+					return nil
+				} else if cnt, err = im.exec(query.ImportMediaTrack, file, t.Kind, t.Codec, t.Language, t.Name, t.Channels); err != nil {
+					return err
+				}
+				im.report.Added[key] += int(cnt)
+				return nil
+			})
+
+		case "collections":
+			err = im.section(func() interface{} { return new(exportCollection) }, func(item interface{}) error {
+				var (
+					err error
+					cnt int64
+					raw []byte
+					c   = item.(*exportCollection)
+				)
+				if err = remapCondition(&c.Cond, im.tags, im.people, true); err != nil {
+					return err
+				} else if raw, err = json.Marshal(&c.Cond); err != nil {
+					return err
+				} else if cnt, err = im.exec(query.ImportCollection, c.Name, string(raw)); err != nil {
+					return err
+				} else if cnt > 0 {
+					im.report.Added[key]++
+				} else {
+					im.report.conflict("Collection %s already exists", c.Name)
+				}
+				return nil
+			})
+
+		default:
+			// A later version of the format may know about more
+			// sections, but those would come with a higher version
+			// number, so we can safely skip whatever we do not know.
+			var skip json.RawMessage
+			err = im.dec.Decode(&skip)
+		}
+
+		if err != nil {
+			err = fmt.Errorf("Error in section %s: %w", key, err)
+			goto ERROR
+		}
+	}
+
+	if err = im.expectDelim('}'); err != nil {
+		goto ERROR
+	} else if format != exportFormat {
+		err = fmt.Errorf("%w: format is %q", ErrExportFormat, format)
+		goto ERROR
+	}
+
+	status = true
+	db.log.Printf("[INFO] Imported library: %v, %d conflicts\n",
+		im.report.Added,
+		len(im.report.Conflicts))
+	return im.report, nil
+
+ERROR:
+	db.log.Printf("[ERROR] Cannot import library: %s\n",
+		err.Error())
+	return nil, err
+} // func (db *Database) Import(r io.Reader) (*ImportReport, error)
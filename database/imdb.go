@@ -0,0 +1,511 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/database/imdb.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 15. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-15 19:32:10 krylon>
+
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/blicero/blockbuster/database/query"
+	"github.com/blicero/blockbuster/objects"
+)
+
+// The tables these methods work on are a staging area for the datasets IMDb
+// offers for download. They have nothing to do with the rest of the
+// database, until we match them to our Files.
+
+// IMDbClear empties the staging tables.
+func (db *Database) IMDbClear() error {
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+	)
+
+	if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	for _, qid := range []query.ID{
+		query.IMDbClearTitles,
+		query.IMDbClearKeys,
+		query.IMDbClearPrincipals,
+		query.IMDbClearNames,
+	} {
+		if stmt, err = db.getQuery(qid); err != nil {
+			db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+				qid.String(),
+				err.Error())
+			return err
+		}
+
+		stmt = tx.Stmt(stmt)
+
+	EXEC_QUERY:
+		if _, err = stmt.Exec(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto EXEC_QUERY
+			}
+
+			err = fmt.Errorf("Cannot clear IMDb staging tables (%s): %s",
+				qid.String(),
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return err
+		}
+	}
+
+	status = true
+	return nil
+} // func (db *Database) IMDbClear() error
+
+// IMDbTitleAdd adds a title from title.basics.tsv.gz to the staging area.
+func (db *Database) IMDbTitleAdd(t *objects.IMDbTitle) error {
+	const qid query.ID = query.IMDbTitleAdd
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	stmt = tx.Stmt(stmt)
+
+EXEC_QUERY:
+	if _, err = stmt.Exec(t.ID, t.Type, t.Title, t.OriginalTitle, t.Year); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		} else {
+			err = fmt.Errorf("Cannot add IMDb title %s (%s): %s",
+				t.ID,
+				t.Title,
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return err
+		}
+	}
+
+	status = true
+	return nil
+} // func (db *Database) IMDbTitleAdd(t *objects.IMDbTitle) error
+
+// IMDbTitleKeyAdd adds a key under which a title can be found, usually its
+// normalized title.
+func (db *Database) IMDbTitleKeyAdd(id, key string) error {
+	const qid query.ID = query.IMDbTitleKeyAdd
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	stmt = tx.Stmt(stmt)
+
+EXEC_QUERY:
+	if _, err = stmt.Exec(key, id); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		} else {
+			err = fmt.Errorf("Cannot add key %q for IMDb title %s: %s",
+				key,
+				id,
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return err
+		}
+	}
+
+	status = true
+	return nil
+} // func (db *Database) IMDbTitleKeyAdd(id, key string) error
+
+// IMDbPrincipalAdd adds an entry from title.principals.tsv.gz to the staging
+// area.
+func (db *Database) IMDbPrincipalAdd(p *objects.IMDbPrincipal) error {
+	const qid query.ID = query.IMDbPrincipalAdd
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	stmt = tx.Stmt(stmt)
+
+EXEC_QUERY:
+	if _, err = stmt.Exec(p.TitleID, p.Ordering, p.NameID, p.Category, p.Characters); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		} else {
+			err = fmt.Errorf("Cannot add principal %d of IMDb title %s: %s",
+				p.Ordering,
+				p.TitleID,
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return err
+		}
+	}
+
+	status = true
+	return nil
+} // func (db *Database) IMDbPrincipalAdd(p *objects.IMDbPrincipal) error
+
+// IMDbNameAdd adds a person from name.basics.tsv.gz to the staging area.
+func (db *Database) IMDbNameAdd(n *objects.IMDbName) error {
+	const qid query.ID = query.IMDbNameAdd
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	stmt = tx.Stmt(stmt)
+
+EXEC_QUERY:
+	if _, err = stmt.Exec(n.ID, n.Name, n.BirthYear); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		} else {
+			err = fmt.Errorf("Cannot add IMDb name %s (%s): %s",
+				n.ID,
+				n.Name,
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return err
+		}
+	}
+
+	status = true
+	return nil
+} // func (db *Database) IMDbNameAdd(n *objects.IMDbName) error
+
+// IMDbTitleGetByID looks up a title in the staging area by its ID.
+func (db *Database) IMDbTitleGetByID(id string) (*objects.IMDbTitle, error) {
+	const qid query.ID = query.IMDbTitleGetByID
+	var (
+		err  error
+		stmt *sql.Stmt
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(id); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+
+	if rows.Next() {
+		var t = &objects.IMDbTitle{ID: id}
+
+		if err = rows.Scan(&t.Type, &t.Title, &t.OriginalTitle, &t.Year); err != nil {
+			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
+			return nil, err
+		}
+
+		return t, nil
+	}
+
+	return nil, nil
+} // func (db *Database) IMDbTitleGetByID(id string) (*objects.IMDbTitle, error)
+
+// IMDbTitleGetByKey returns the titles in the staging area that can be
+// found under the given key, ordered by their IDs.
+func (db *Database) IMDbTitleGetByKey(key string) ([]objects.IMDbTitle, error) {
+	const qid query.ID = query.IMDbTitleGetByKey
+	var (
+		err  error
+		stmt *sql.Stmt
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(key); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+
+	var titles = make([]objects.IMDbTitle, 0, 4)
+
+	for rows.Next() {
+		var t objects.IMDbTitle
+
+		if err = rows.Scan(&t.ID, &t.Type, &t.Title, &t.OriginalTitle, &t.Year); err != nil {
+			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
+			return nil, err
+		}
+
+		titles = append(titles, t)
+	}
+
+	return titles, nil
+} // func (db *Database) IMDbTitleGetByKey(key string) ([]objects.IMDbTitle, error)
+
+// IMDbPrincipalGetByTitle returns the cast and crew of a title in the
+// staging area, in the order IMDb lists them. Name and BirthYear are empty
+// if the person is missing from name.basics.tsv.gz.
+func (db *Database) IMDbPrincipalGetByTitle(id string) ([]objects.IMDbPrincipal, error) {
+	const qid query.ID = query.IMDbPrincipalGetByTitle
+	var (
+		err  error
+		stmt *sql.Stmt
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(id); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+
+	var list = make([]objects.IMDbPrincipal, 0, 16)
+
+	for rows.Next() {
+		var p = objects.IMDbPrincipal{TitleID: id}
+
+		if err = rows.Scan(&p.Ordering, &p.NameID, &p.Category, &p.Characters, &p.Name, &p.BirthYear); err != nil {
+			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
+			return nil, err
+		}
+
+		list = append(list, p)
+	}
+
+	return list, nil
+} // func (db *Database) IMDbPrincipalGetByTitle(id string) ([]objects.IMDbPrincipal, error)
@@ -6,6 +6,8 @@
 
 package database
 
+// initQueries creates the schema as of version 0.0.5, which is schema
+// version 0. Do not change these, add a migration instead.
 var initQueries = []string{
 	`CREATE TABLE folder(
     id            INTEGER PRIMARY KEY,
@@ -0,0 +1,305 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/database/media.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 03. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-03 19:28:44 krylon>
+
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/blicero/blockbuster/database/query"
+	"github.com/blicero/blockbuster/objects"
+)
+
+// MediaInfoSet stores the MediaInfo for a File, replacing whatever was
+// stored before.
+// Durations are stored in milliseconds, that is precise enough for our
+// purposes.
+func (db *Database) MediaInfoSet(f *objects.File, info *objects.MediaInfo) error {
+	var (
+		err                     error
+		msg                     string
+		tx                      *sql.Tx
+		status                  bool
+		delStmt, addStmt, tStmt *sql.Stmt
+	)
+
+	if delStmt, err = db.getQuery(query.MediaInfoDelete); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			query.MediaInfoDelete,
+			err.Error())
+		return err
+	} else if addStmt, err = db.getQuery(query.MediaInfoAdd); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			query.MediaInfoAdd,
+			err.Error())
+		return err
+	} else if tStmt, err = db.getQuery(query.MediaTrackAdd); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			query.MediaTrackAdd,
+			err.Error())
+		return err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	// Deleting the old MediaInfo takes its Tracks with it.
+EXEC_DELETE:
+	if _, err = tx.Stmt(delStmt).Exec(f.ID); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_DELETE
+		}
+
+		err = fmt.Errorf("Cannot delete old MediaInfo for File %s: %s",
+			f.Path,
+			err.Error())
+		db.log.Printf("[ERROR] %s\n", err.Error())
+		return err
+	}
+
+EXEC_ADD:
+	if _, err = tx.Stmt(addStmt).Exec(
+		f.ID,
+		info.Container,
+		info.Duration.Milliseconds(),
+		info.Width,
+		info.Height,
+		info.FrameRate,
+		info.VideoCodec,
+		info.Probed.Unix(),
+	); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_ADD
+		}
+
+		err = fmt.Errorf("Cannot add MediaInfo for File %s: %s",
+			f.Path,
+			err.Error())
+		db.log.Printf("[ERROR] %s\n", err.Error())
+		return err
+	}
+
+	tStmt = tx.Stmt(tStmt)
+
+	for idx := range info.Tracks {
+		var (
+			res sql.Result
+			t   = &info.Tracks[idx]
+		)
+
+	EXEC_TRACK:
+		if res, err = tStmt.Exec(f.ID, t.Kind, t.Codec, t.Language, t.Name, t.Channels); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto EXEC_TRACK
+			}
+
+			err = fmt.Errorf("Cannot add %s Track for File %s: %s",
+				t.Kind,
+				f.Path,
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return err
+		} else if t.ID, err = res.LastInsertId(); err != nil {
+			db.log.Printf("[ERROR] Cannot get ID of new Track: %s\n",
+				err.Error())
+			return err
+		}
+	}
+
+	status = true
+	info.FileID = f.ID
+	return nil
+} // func (db *Database) MediaInfoSet(f *objects.File, info *objects.MediaInfo) error
+
+// MediaInfoGetByFile loads the MediaInfo for a File, including its Tracks.
+// If the File has not been probed, yet, it returns nil.
+func (db *Database) MediaInfoGetByFile(f *objects.File) (*objects.MediaInfo, error) {
+	const qid query.ID = query.MediaInfoGetByFile
+	var (
+		err  error
+		stmt *sql.Stmt
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(f.ID); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+
+	if !rows.Next() {
+		return nil, nil
+	}
+
+	var (
+		duration, probed int64
+		info             = &objects.MediaInfo{FileID: f.ID}
+	)
+
+	if err = rows.Scan(
+		&info.Container,
+		&duration,
+		&info.Width,
+		&info.Height,
+		&info.FrameRate,
+		&info.VideoCodec,
+		&probed,
+	); err != nil {
+		db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
+		return nil, err
+	}
+
+	info.Duration = time.Duration(duration) * time.Millisecond
+	info.Probed = time.Unix(probed, 0)
+
+	if info.Tracks, err = db.mediaTrackGetByFile(f); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+} // func (db *Database) MediaInfoGetByFile(f *objects.File) (*objects.MediaInfo, error)
+
+func (db *Database) mediaTrackGetByFile(f *objects.File) ([]objects.Track, error) {
+	const qid query.ID = query.MediaTrackGetByFile
+	var (
+		err  error
+		stmt *sql.Stmt
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(f.ID); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+
+	var tracks = make([]objects.Track, 0, 4)
+
+	for rows.Next() {
+		var t objects.Track
+
+		if err = rows.Scan(&t.ID, &t.Kind, &t.Codec, &t.Language, &t.Name, &t.Channels); err != nil {
+			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
+			return nil, err
+		}
+
+		tracks = append(tracks, t)
+	}
+
+	return tracks, nil
+} // func (db *Database) mediaTrackGetByFile(f *objects.File) ([]objects.Track, error)
+
+// FileGetUnprobed returns all Files that are not missing and that have not
+// been probed for their MediaInfo, yet.
+func (db *Database) FileGetUnprobed() ([]objects.File, error) {
+	const qid query.ID = query.FileGetUnprobed
+	var (
+		err  error
+		stmt *sql.Stmt
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+
+	var files = make([]objects.File, 0, 16)
+
+	for rows.Next() {
+		var f objects.File
+
+		if err = rows.Scan(&f.ID, &f.FolderID, &f.Path, &f.Title, &f.Year, &f.Hidden); err != nil {
+			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
+			return nil, err
+		}
+
+		files = append(files, f)
+	}
+
+	return files, nil
+} // func (db *Database) FileGetUnprobed() ([]objects.File, error)
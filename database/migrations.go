@@ -0,0 +1,728 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/database/migrations.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 27. 08. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-08-27 18:42:11 krylon>
+
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/blicero/blockbuster/common"
+	"github.com/blicero/krylib"
+)
+
+// ErrSchemaTooNew indicates that the database was created or upgraded by a
+// newer version of the application than the one trying to open it.
+var ErrSchemaTooNew = errors.New("database schema is newer than this version of the application supports")
+
+// A migration upgrades the database schema from the previous version to
+// the version given in the version field.
+// Migrations are applied strictly in the order they appear in the migrations
+// list, and once a migration has been released, it must never be changed.
+// If something needs fixing, add another migration.
+type migration struct {
+	version     int
+	description string
+	queries     []string
+}
+
+// migrations lists all changes made to the schema since version 0.0.5 of the
+// application, which had no notion of schema versions, yet. initQueries
+// creates that schema, I consider it schema version 0. Freshly created
+// databases go through the same migrations as existing ones.
+var migrations = []migration{
+	{
+		version:     1,
+		description: "Keep track of the schema version",
+		queries: []string{
+			`
+CREATE TABLE schema_version (
+    version     INTEGER PRIMARY KEY,
+    timestamp   INTEGER NOT NULL,
+    description TEXT NOT NULL DEFAULT ''
+)`,
+		},
+	},
+	{
+		version:     2,
+		description: "Give Links attached to Files a title",
+		queries: []string{
+			"ALTER TABLE file_url ADD COLUMN title TEXT NOT NULL DEFAULT ''",
+			"CREATE UNIQUE INDEX file_url_file_url_idx ON file_url (file_id, url)",
+			"CREATE INDEX file_url_file_idx ON file_url (file_id)",
+		},
+	},
+	{
+		version:     3,
+		description: "Series, Seasons and Episodes",
+		queries: []string{
+			`
+CREATE TABLE series (
+    id		INTEGER PRIMARY KEY,
+    title	TEXT UNIQUE NOT NULL,
+    year	INTEGER NOT NULL DEFAULT 0
+)`,
+			"CREATE INDEX series_title_idx ON series (title)",
+			`
+CREATE TABLE season (
+    id		INTEGER PRIMARY KEY,
+    series_id	INTEGER NOT NULL,
+    number	INTEGER NOT NULL,
+    title	TEXT NOT NULL DEFAULT '',
+    year	INTEGER NOT NULL DEFAULT 0,
+    FOREIGN KEY (series_id) REFERENCES series (id)
+       ON DELETE CASCADE
+       ON UPDATE RESTRICT,
+    UNIQUE (series_id, number)
+)`,
+			"CREATE INDEX season_series_idx ON season (series_id)",
+			`
+CREATE TABLE episode (
+    id		INTEGER PRIMARY KEY,
+    season_id	INTEGER NOT NULL,
+    number	INTEGER NOT NULL,
+    title	TEXT NOT NULL DEFAULT '',
+    FOREIGN KEY (season_id) REFERENCES season (id)
+       ON DELETE CASCADE
+       ON UPDATE RESTRICT,
+    UNIQUE (season_id, number)
+)`,
+			"CREATE INDEX episode_season_idx ON episode (season_id)",
+			`
+ALTER TABLE file ADD COLUMN episode_id INTEGER
+    REFERENCES episode (id)
+    ON DELETE SET NULL
+    ON UPDATE RESTRICT
+`,
+			"CREATE INDEX file_episode_idx ON file (episode_id)",
+		},
+	},
+	{
+		version:     4,
+		description: "Full-text search",
+		// The numbers in the kind column are the values of
+		// objects.SearchKind.
+		// For Links, owner_id is the ID of the File or Person the Link is
+		// attached to, for everything else it is the same as object_id.
+		queries: []string{
+			`
+CREATE VIRTUAL TABLE search_index USING fts5 (
+    kind UNINDEXED,
+    object_id UNINDEXED,
+    owner_id UNINDEXED,
+    title,
+    body,
+    tokenize = 'unicode61 remove_diacritics 2'
+)`,
+			// File
+			`
+CREATE TRIGGER file_search_ins AFTER INSERT ON file
+BEGIN
+    INSERT INTO search_index (kind, object_id, owner_id, title, body)
+    VALUES (1, new.id, new.id, new.title, new.path);
+END`,
+			`
+CREATE TRIGGER file_search_upd AFTER UPDATE OF title, path ON file
+BEGIN
+    UPDATE search_index
+    SET title = new.title, body = new.path
+    WHERE kind = 1 AND object_id = old.id;
+END`,
+			`
+CREATE TRIGGER file_search_del AFTER DELETE ON file
+BEGIN
+    DELETE FROM search_index WHERE kind = 1 AND object_id = old.id;
+END`,
+			// Person
+			`
+CREATE TRIGGER person_search_ins AFTER INSERT ON person
+BEGIN
+    INSERT INTO search_index (kind, object_id, owner_id, title, body)
+    VALUES (2, new.id, new.id, new.name, '');
+END`,
+			`
+CREATE TRIGGER person_search_upd AFTER UPDATE OF name ON person
+BEGIN
+    UPDATE search_index
+    SET title = new.name
+    WHERE kind = 2 AND object_id = old.id;
+END`,
+			`
+CREATE TRIGGER person_search_del AFTER DELETE ON person
+BEGIN
+    DELETE FROM search_index WHERE kind = 2 AND object_id = old.id;
+END`,
+			// Tag
+			`
+CREATE TRIGGER tag_search_ins AFTER INSERT ON tag
+BEGIN
+    INSERT INTO search_index (kind, object_id, owner_id, title, body)
+    VALUES (3, new.id, new.id, new.name, '');
+END`,
+			`
+CREATE TRIGGER tag_search_upd AFTER UPDATE OF name ON tag
+BEGIN
+    UPDATE search_index
+    SET title = new.name
+    WHERE kind = 3 AND object_id = old.id;
+END`,
+			`
+CREATE TRIGGER tag_search_del AFTER DELETE ON tag
+BEGIN
+    DELETE FROM search_index WHERE kind = 3 AND object_id = old.id;
+END`,
+			// PersonURL
+			`
+CREATE TRIGGER person_url_search_ins AFTER INSERT ON person_url
+BEGIN
+    INSERT INTO search_index (kind, object_id, owner_id, title, body)
+    VALUES (4, new.id, new.person_id, new.title, new.url || ' ' || new.description);
+END`,
+			`
+CREATE TRIGGER person_url_search_upd AFTER UPDATE OF url, title, description ON person_url
+BEGIN
+    UPDATE search_index
+    SET title = new.title, body = new.url || ' ' || new.description
+    WHERE kind = 4 AND object_id = old.id;
+END`,
+			`
+CREATE TRIGGER person_url_search_del AFTER DELETE ON person_url
+BEGIN
+    DELETE FROM search_index WHERE kind = 4 AND object_id = old.id;
+END`,
+			// FileURL
+			`
+CREATE TRIGGER file_url_search_ins AFTER INSERT ON file_url
+BEGIN
+    INSERT INTO search_index (kind, object_id, owner_id, title, body)
+    VALUES (5, new.id, new.file_id, new.title, new.url || ' ' || new.description);
+END`,
+			`
+CREATE TRIGGER file_url_search_upd AFTER UPDATE OF url, title, description ON file_url
+BEGIN
+    UPDATE search_index
+    SET title = new.title, body = new.url || ' ' || new.description
+    WHERE kind = 5 AND object_id = old.id;
+END`,
+			`
+CREATE TRIGGER file_url_search_del AFTER DELETE ON file_url
+BEGIN
+    DELETE FROM search_index WHERE kind = 5 AND object_id = old.id;
+END`,
+			// Index whatever is already in the database.
+			`
+INSERT INTO search_index (kind, object_id, owner_id, title, body)
+SELECT 1, id, id, title, path FROM file
+UNION ALL
+SELECT 2, id, id, name, '' FROM person
+UNION ALL
+SELECT 3, id, id, name, '' FROM tag
+UNION ALL
+SELECT 4, id, person_id, title, url || ' ' || description FROM person_url
+UNION ALL
+SELECT 5, id, file_id, title, url || ' ' || description FROM file_url
+`,
+		},
+	},
+	{
+		version:     5,
+		description: "Content fingerprints for Files",
+		queries: []string{
+			"ALTER TABLE file ADD COLUMN fingerprint TEXT NOT NULL DEFAULT ''",
+			"CREATE INDEX file_fingerprint_idx ON file (fingerprint)",
+		},
+	},
+	{
+		version:     6,
+		description: "Keep track of Files that have gone missing",
+		queries: []string{
+			"ALTER TABLE file ADD COLUMN missing_since INTEGER NOT NULL DEFAULT 0",
+			"CREATE INDEX file_missing_idx ON file (missing_since)",
+		},
+	},
+	{
+		version:     7,
+		description: "Media information: duration, resolution, codecs",
+		queries: []string{
+			`
+CREATE TABLE media_info (
+    file_id	INTEGER PRIMARY KEY,
+    container	TEXT NOT NULL DEFAULT '',
+    duration	INTEGER NOT NULL DEFAULT 0,
+    width	INTEGER NOT NULL DEFAULT 0,
+    height	INTEGER NOT NULL DEFAULT 0,
+    frame_rate	REAL NOT NULL DEFAULT 0,
+    video_codec	TEXT NOT NULL DEFAULT '',
+    probed	INTEGER NOT NULL,
+    FOREIGN KEY (file_id) REFERENCES file (id)
+       ON DELETE CASCADE
+       ON UPDATE RESTRICT
+)`,
+			`
+CREATE TABLE media_track (
+    id		INTEGER PRIMARY KEY,
+    file_id	INTEGER NOT NULL,
+    kind	INTEGER NOT NULL,
+    codec	TEXT NOT NULL DEFAULT '',
+    language	TEXT NOT NULL DEFAULT '',
+    name	TEXT NOT NULL DEFAULT '',
+    channels	INTEGER NOT NULL DEFAULT 0,
+    FOREIGN KEY (file_id) REFERENCES media_info (file_id)
+       ON DELETE CASCADE
+       ON UPDATE RESTRICT,
+    CHECK (kind IN (1, 2))
+)`,
+			"CREATE INDEX media_track_file_idx ON media_track (file_id)",
+		},
+	},
+	{
+		version:     8,
+		description: "Watch history and resume positions",
+		queries: []string{
+			"ALTER TABLE file ADD COLUMN watched INTEGER NOT NULL DEFAULT 0",
+			"ALTER TABLE file ADD COLUMN resume_pos INTEGER NOT NULL DEFAULT 0",
+			`
+CREATE TABLE play_log (
+    id		INTEGER PRIMARY KEY,
+    file_id	INTEGER NOT NULL,
+    start_time	INTEGER NOT NULL,
+    end_time	INTEGER NOT NULL DEFAULT 0,
+    exit_status	INTEGER NOT NULL DEFAULT 0,
+    position	INTEGER NOT NULL DEFAULT 0,
+    FOREIGN KEY (file_id) REFERENCES file (id)
+       ON DELETE CASCADE
+       ON UPDATE RESTRICT
+)`,
+			"CREATE INDEX play_log_file_idx ON play_log (file_id, start_time)",
+		},
+	},
+	{
+		version:     9,
+		description: "Replace actor and director with a generic credit table",
+		queries: []string{
+			`
+CREATE TABLE credit (
+    id		INTEGER PRIMARY KEY,
+    file_id	INTEGER NOT NULL,
+    person_id	INTEGER NOT NULL,
+    role	INTEGER NOT NULL,
+    character	TEXT NOT NULL DEFAULT '',
+    billing	INTEGER NOT NULL DEFAULT 0,
+    UNIQUE (file_id, person_id, role),
+    FOREIGN KEY (file_id) REFERENCES file (id)
+        ON DELETE RESTRICT
+        ON UPDATE RESTRICT,
+    FOREIGN KEY (person_id) REFERENCES person (id)
+        ON DELETE RESTRICT
+        ON UPDATE RESTRICT,
+    CHECK (role BETWEEN 1 AND 8)
+)`,
+			"CREATE INDEX credit_file_idx ON credit (file_id, role, billing)",
+			"CREATE INDEX credit_person_idx ON credit (person_id, role)",
+			// The role numbers are those of objects.Role
+			"INSERT INTO credit (file_id, person_id, role) SELECT file_id, person_id, 1 FROM actor",
+			"INSERT INTO credit (file_id, person_id, role) SELECT file_id, person_id, 2 FROM director",
+			"DROP TABLE actor",
+			"DROP TABLE director",
+		},
+	},
+	{
+		version:     10,
+		description: "Aliases for People",
+		queries: []string{
+			`
+CREATE TABLE person_alias (
+    id		INTEGER PRIMARY KEY,
+    person_id	INTEGER NOT NULL,
+    name	TEXT UNIQUE NOT NULL,
+    FOREIGN KEY (person_id) REFERENCES person (id)
+        ON DELETE CASCADE
+        ON UPDATE RESTRICT
+)`,
+			"CREATE INDEX person_alias_person_idx ON person_alias (person_id)",
+			// Aliases are searchable as part of the Person they belong
+			// to.
+			`
+CREATE TRIGGER person_alias_search_ins AFTER INSERT ON person_alias
+BEGIN
+    UPDATE search_index
+    SET body = trim(body || ' ' || new.name)
+    WHERE kind = 2 AND object_id = new.person_id;
+END`,
+			`
+CREATE TRIGGER person_alias_search_upd AFTER UPDATE OF person_id ON person_alias
+BEGIN
+    UPDATE search_index
+    SET body = trim(body || ' ' || new.name)
+    WHERE kind = 2 AND object_id = new.person_id;
+END`,
+			// When People are merged, their Links change owners.
+			`
+CREATE TRIGGER person_url_search_owner AFTER UPDATE OF person_id ON person_url
+BEGIN
+    UPDATE search_index
+    SET owner_id = new.person_id
+    WHERE kind = 4 AND object_id = old.id;
+END`,
+		},
+	},
+	{
+		version:     11,
+		description: "Tag hierarchy",
+		queries: []string{
+			`
+ALTER TABLE tag ADD COLUMN parent INTEGER
+    REFERENCES tag (id)
+    ON DELETE SET NULL
+    ON UPDATE RESTRICT`,
+			"CREATE INDEX tag_parent_idx ON tag (parent)",
+		},
+	},
+	{
+		version:     12,
+		description: "Smart collections",
+		queries: []string{
+			// For Files we already know about, we do not know when
+			// they were added, so they get 0.
+			"ALTER TABLE file ADD COLUMN added INTEGER NOT NULL DEFAULT 0",
+			`
+CREATE TABLE collection (
+    id		INTEGER PRIMARY KEY,
+    name	TEXT UNIQUE NOT NULL,
+    cond	TEXT NOT NULL
+)`,
+		},
+	},
+	{
+		version:     13,
+		description: "Ratings and imported watch history",
+		queries: []string{
+			// Ratings are counted in half stars, from 1 to 10, 0 means
+			// the File has not been rated.
+			"ALTER TABLE file ADD COLUMN rating INTEGER NOT NULL DEFAULT 0",
+			// Viewings we know about from elsewhere, like a diary on
+			// Letterboxd. Our own are in play_log.
+			`
+CREATE TABLE viewing (
+    id		INTEGER PRIMARY KEY,
+    file_id	INTEGER NOT NULL,
+    watched	INTEGER NOT NULL,
+    source	TEXT NOT NULL DEFAULT '',
+    UNIQUE (file_id, watched),
+    FOREIGN KEY (file_id) REFERENCES file (id)
+       ON DELETE CASCADE
+       ON UPDATE RESTRICT
+)`,
+			"CREATE INDEX viewing_file_idx ON viewing (file_id, watched)",
+		},
+	},
+	{
+		version:     14,
+		description: "Staging tables for the IMDb datasets",
+		queries: []string{
+			`
+CREATE TABLE imdb_title (
+    id			TEXT PRIMARY KEY,
+    type		TEXT NOT NULL,
+    title		TEXT NOT NULL,
+    original_title	TEXT NOT NULL,
+    year		INTEGER NOT NULL DEFAULT 0
+)`,
+			`
+CREATE TABLE imdb_title_key (
+    key		TEXT NOT NULL,
+    title_id	TEXT NOT NULL,
+    PRIMARY KEY (key, title_id)
+)`,
+			`
+CREATE TABLE imdb_principal (
+    title_id	TEXT NOT NULL,
+    ordering	INTEGER NOT NULL,
+    name_id	TEXT NOT NULL,
+    category	TEXT NOT NULL,
+    characters	TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (title_id, ordering)
+)`,
+			`
+CREATE TABLE imdb_name (
+    id		TEXT PRIMARY KEY,
+    name	TEXT NOT NULL,
+    birth_year	INTEGER NOT NULL DEFAULT 0
+)`,
+		},
+	},
+	{
+		version:     15,
+		description: "Scan rules for Folders",
+		queries: []string{
+			// A min_size of 0 means the default size threshold applies.
+			// suffixes is a space-separated list of extensions, exclude
+			// holds one pattern per line, like a .gitignore file.
+			"ALTER TABLE folder ADD COLUMN min_size INTEGER NOT NULL DEFAULT 0",
+			"ALTER TABLE folder ADD COLUMN suffixes TEXT NOT NULL DEFAULT ''",
+			"ALTER TABLE folder ADD COLUMN exclude TEXT NOT NULL DEFAULT ''",
+		},
+	},
+	{
+		version:     16,
+		description: "Remember what Files and directories looked like on disk",
+		queries: []string{
+			// mtime is in nanoseconds, some file systems are that
+			// precise, and we compare for equality.
+			"ALTER TABLE file ADD COLUMN size INTEGER NOT NULL DEFAULT 0",
+			"ALTER TABLE file ADD COLUMN mtime INTEGER NOT NULL DEFAULT 0",
+			"ALTER TABLE file ADD COLUMN inode INTEGER NOT NULL DEFAULT 0",
+			`
+CREATE TABLE dir_cache (
+    id		INTEGER PRIMARY KEY,
+    folder_id	INTEGER NOT NULL,
+    path	TEXT NOT NULL,
+    mtime	INTEGER NOT NULL,
+    UNIQUE (folder_id, path),
+    FOREIGN KEY (folder_id) REFERENCES folder (id)
+       ON DELETE CASCADE
+       ON UPDATE RESTRICT
+)`,
+		},
+	},
+	{
+		version:     17,
+		description: "Scan history",
+		queries: []string{
+			// start_time and end_time are in milliseconds, most scans
+			// do not take very long.
+			`
+CREATE TABLE scan_run (
+    id			INTEGER PRIMARY KEY,
+    folder_id		INTEGER NOT NULL,
+    start_time		INTEGER NOT NULL,
+    end_time		INTEGER NOT NULL,
+    status		INTEGER NOT NULL DEFAULT 0,
+    dirs		INTEGER NOT NULL DEFAULT 0,
+    dirs_skipped	INTEGER NOT NULL DEFAULT 0,
+    files		INTEGER NOT NULL DEFAULT 0,
+    files_skipped	INTEGER NOT NULL DEFAULT 0,
+    files_new		INTEGER NOT NULL DEFAULT 0,
+    files_changed	INTEGER NOT NULL DEFAULT 0,
+    errors		INTEGER NOT NULL DEFAULT 0,
+    FOREIGN KEY (folder_id) REFERENCES folder (id)
+       ON DELETE CASCADE
+       ON UPDATE RESTRICT
+)`,
+			"CREATE INDEX scan_run_folder_idx ON scan_run (folder_id, start_time)",
+		},
+	},
+	{
+		version:     18,
+		description: "Rebuild the search entry of a Person from their aliases",
+		queries: []string{
+			// The triggers from version 10 only ever appended to the
+			// body, so aliases that were moved to another Person or
+			// deleted stayed behind. Instead, we rebuild the body
+			// from the aliases a Person has now. Their name is
+			// indexed as the title.
+			"DROP TRIGGER person_alias_search_ins",
+			"DROP TRIGGER person_alias_search_upd",
+			`
+CREATE TRIGGER person_alias_search_ins AFTER INSERT ON person_alias
+BEGIN
+    UPDATE search_index
+    SET body = (SELECT COALESCE(group_concat(name, ' '), '')
+                FROM person_alias
+                WHERE person_id = new.person_id)
+    WHERE kind = 2 AND object_id = new.person_id;
+END`,
+			`
+CREATE TRIGGER person_alias_search_upd AFTER UPDATE OF person_id, name ON person_alias
+BEGIN
+    UPDATE search_index
+    SET body = (SELECT COALESCE(group_concat(name, ' '), '')
+                FROM person_alias
+                WHERE person_id = old.person_id)
+    WHERE kind = 2 AND object_id = old.person_id;
+    UPDATE search_index
+    SET body = (SELECT COALESCE(group_concat(name, ' '), '')
+                FROM person_alias
+                WHERE person_id = new.person_id)
+    WHERE kind = 2 AND object_id = new.person_id;
+END`,
+			`
+CREATE TRIGGER person_alias_search_del AFTER DELETE ON person_alias
+BEGIN
+    UPDATE search_index
+    SET body = (SELECT COALESCE(group_concat(name, ' '), '')
+                FROM person_alias
+                WHERE person_id = old.person_id)
+    WHERE kind = 2 AND object_id = old.person_id;
+END`,
+			`
+UPDATE search_index
+SET body = (SELECT COALESCE(group_concat(a.name, ' '), '')
+            FROM person_alias a
+            WHERE a.person_id = search_index.object_id)
+WHERE kind = 2`,
+		},
+	},
+}
+
+// schemaVersion returns the most recent schema version, i.e. the one the
+// last migration leaves the database at.
+func schemaVersion() int {
+	return migrations[len(migrations)-1].version
+} // func schemaVersion() int
+
+// SchemaVersion returns the version of the database schema.
+func (db *Database) SchemaVersion() (int, error) {
+	const (
+		qTable   = "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_version'"
+		qVersion = "SELECT COALESCE(MAX(version), 0) FROM schema_version"
+	)
+	var (
+		err          error
+		cnt, version int
+	)
+
+	if err = db.db.QueryRow(qTable).Scan(&cnt); err != nil {
+		db.log.Printf("[ERROR] Cannot check if schema_version table exists: %s\n",
+			err.Error())
+		return 0, err
+	} else if cnt == 0 {
+		return 0, nil
+	} else if err = db.db.QueryRow(qVersion).Scan(&version); err != nil {
+		db.log.Printf("[ERROR] Cannot query schema version: %s\n",
+			err.Error())
+		return 0, err
+	}
+
+	return version, nil
+} // func (db *Database) SchemaVersion() (int, error)
+
+// migrate brings the database schema up to date by applying all pending
+// migrations within a single transaction.
+// If backup is true, a copy of the database file is made before any changes
+// are applied.
+func (db *Database) migrate(backup bool) error {
+	var (
+		err     error
+		version int
+		tx      *sql.Tx
+	)
+
+	if version, err = db.SchemaVersion(); err != nil {
+		return err
+	} else if version > schemaVersion() {
+		db.log.Printf("[CRITICAL] Database %s has schema version %d, we only know up to version %d\n",
+			db.path,
+			version,
+			schemaVersion())
+		return ErrSchemaTooNew
+	} else if version == schemaVersion() {
+		return nil
+	}
+
+	db.log.Printf("[INFO] Migrate database %s from schema version %d to %d\n",
+		db.path,
+		version,
+		schemaVersion())
+
+	if backup {
+		if err = db.backupFile(version); err != nil {
+			return err
+		}
+	}
+
+	if tx, err = db.db.Begin(); err != nil {
+		db.log.Printf("[ERROR] Cannot begin transaction: %s\n",
+			err.Error())
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		} else if err = db.applyMigration(tx, &m); err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				db.log.Printf("[CANTHAPPEN] Cannot rollback transaction: %s\n",
+					rbErr.Error())
+				return rbErr
+			}
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		db.log.Printf("[CANTHAPPEN] Failed to commit migration transaction: %s\n",
+			err.Error())
+		return err
+	}
+
+	return nil
+} // func (db *Database) migrate(backup bool) error
+
+func (db *Database) applyMigration(tx *sql.Tx, m *migration) error {
+	const qVersion = "INSERT INTO schema_version (version, timestamp, description) VALUES (?, ?, ?)"
+	var err error
+
+	db.log.Printf("[DEBUG] Apply migration %d: %s\n",
+		m.version,
+		m.description)
+
+	for _, q := range m.queries {
+		db.log.Printf("[TRACE] Execute migration query:\n%s\n",
+			q)
+		if _, err = tx.Exec(q); err != nil {
+			db.log.Printf("[ERROR] Cannot execute query for migration %d: %s\n%s\n",
+				m.version,
+				err.Error(),
+				q)
+			return err
+		}
+	}
+
+	if _, err = tx.Exec(qVersion, m.version, time.Now().Unix(), m.description); err != nil {
+		db.log.Printf("[ERROR] Cannot record schema version %d: %s\n",
+			m.version,
+			err.Error())
+		return err
+	}
+
+	return nil
+} // func (db *Database) applyMigration(tx *sql.Tx, m *migration) error
+
+// backupFile copies the database file before it is migrated. The copy lives
+// next to the original and carries the schema version and a timestamp in its
+// name.
+func (db *Database) backupFile(version int) error {
+	var (
+		err  error
+		path = fmt.Sprintf("%s.v%d.%s.bak",
+			db.path,
+			version,
+			time.Now().Format("20060102_150405"))
+	)
+
+	// Make sure everything in the write-ahead log has made it into the
+	// database file proper, or the backup will be missing data.
+	if _, err = db.db.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		db.log.Printf("[ERROR] Cannot checkpoint database before backup: %s\n",
+			err.Error())
+		return err
+	} else if err = krylib.CopyFile(db.path, path); err != nil {
+		db.log.Printf("[ERROR] Cannot back up database %s to %s: %s\n",
+			db.path,
+			path,
+			err.Error())
+		return err
+	} else if common.Debug {
+		db.log.Printf("[DEBUG] Backed up database %s to %s\n",
+			db.path,
+			path)
+	}
+
+	return nil
+} // func (db *Database) backupFile(version int) error
@@ -0,0 +1,336 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/database/person.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 07. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-07 19:02:44 krylon>
+
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/blicero/blockbuster/database/query"
+	"github.com/blicero/blockbuster/objects"
+)
+
+// PersonGetByName looks up a Person by their name or one of their aliases.
+func (db *Database) PersonGetByName(name string) (*objects.Person, error) {
+	const qid query.ID = query.PersonGetByName
+	var (
+		err  error
+		stmt *sql.Stmt
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(name); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+
+	if rows.Next() {
+		var (
+			p      = new(objects.Person)
+			bstamp int64
+		)
+
+		if err = rows.Scan(&p.ID, &p.Name, &bstamp); err != nil {
+			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
+			return nil, err
+		}
+
+		if bstamp != 0 {
+			p.Birthday = time.Unix(bstamp, 0)
+		}
+
+		return p, nil
+	}
+
+	return nil, nil
+} // func (db *Database) PersonGetByName(name string) (*objects.Person, error)
+
+// PersonAliasAdd records an alternative name for a Person.
+func (db *Database) PersonAliasAdd(p *objects.Person, name string) error {
+	const qid query.ID = query.PersonAliasAdd
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	stmt = tx.Stmt(stmt)
+
+EXEC_QUERY:
+	if _, err = stmt.Exec(p.ID, name); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		} else {
+			err = fmt.Errorf("Cannot add alias %q for %s: %s",
+				name,
+				p.Name,
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return err
+		}
+	}
+
+	status = true
+	return nil
+} // func (db *Database) PersonAliasAdd(p *objects.Person, name string) error
+
+// PersonAliasGetByPerson returns the alternative names of a Person.
+func (db *Database) PersonAliasGetByPerson(p *objects.Person) ([]string, error) {
+	const qid query.ID = query.PersonAliasGetByPerson
+	var (
+		err  error
+		stmt *sql.Stmt
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(p.ID); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+
+	var aliases = make([]string, 0, 4)
+
+	for rows.Next() {
+		var (
+			name string
+		)
+
+		if err = rows.Scan(&name); err != nil {
+			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
+			return nil, err
+		}
+
+		aliases = append(aliases, name)
+	}
+
+	return aliases, nil
+} // func (db *Database) PersonAliasGetByPerson(p *objects.Person) ([]string, error)
+
+// PersonAliasGetAll returns the alternative names of all People, by the ID of
+// the Person they belong to.
+func (db *Database) PersonAliasGetAll() (map[int64][]string, error) {
+	const qid query.ID = query.PersonAliasGetAll
+	var (
+		err  error
+		stmt *sql.Stmt
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+
+	var aliases = make(map[int64][]string)
+
+	for rows.Next() {
+		var (
+			id   int64
+			name string
+		)
+
+		if err = rows.Scan(&id, &name); err != nil {
+			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
+			return nil, err
+		}
+
+		aliases[id] = append(aliases[id], name)
+	}
+
+	return aliases, nil
+} // func (db *Database) PersonAliasGetAll() (map[int64][]string, error)
+
+// PersonMerge merges two Persons that are really the same person, e.g.
+// "Nicolas Cage" and "Cage, Nicolas". All Credits and Links of drop are
+// moved to keep, drop's name (and aliases) become aliases of keep, and drop
+// is deleted.
+// Credits and Links both Persons already have are not duplicated.
+func (db *Database) PersonMerge(keep, drop *objects.Person) error {
+	type step struct {
+		qid  query.ID
+		args []interface{}
+	}
+
+	var (
+		err    error
+		msg    string
+		tx     *sql.Tx
+		status bool
+		steps  = []step{
+			{query.PersonMergeCredits, []interface{}{keep.ID, drop.ID}},
+			{query.PersonMergeURLs, []interface{}{keep.ID, drop.ID}},
+			{query.PersonMergeAliases, []interface{}{keep.ID, drop.ID}},
+			{query.PersonAliasAdd, []interface{}{keep.ID, drop.Name}},
+			{query.PersonPurgeCredits, []interface{}{drop.ID}},
+			{query.PersonPurgeURLs, []interface{}{drop.ID}},
+			{query.PersonDelete, []interface{}{drop.ID}},
+		}
+	)
+
+	if keep.ID == drop.ID {
+		err = fmt.Errorf("Cannot merge %s (%d) with itself",
+			keep.Name,
+			keep.ID)
+		db.log.Printf("[ERROR] %s\n", err.Error())
+		return err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	for _, s := range steps {
+		var stmt *sql.Stmt
+
+		if stmt, err = db.getQuery(s.qid); err != nil {
+			db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+				s.qid.String(),
+				err.Error())
+			return err
+		}
+
+		stmt = tx.Stmt(stmt)
+
+	EXEC_QUERY:
+		if _, err = stmt.Exec(s.args...); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto EXEC_QUERY
+			} else {
+				err = fmt.Errorf("Cannot merge %s (%d) into %s (%d) (%s): %s",
+					drop.Name,
+					drop.ID,
+					keep.Name,
+					keep.ID,
+					s.qid.String(),
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", err.Error())
+				return err
+			}
+		}
+	}
+
+	status = true
+	return nil
+} // func (db *Database) PersonMerge(keep, drop *objects.Person) error
@@ -28,12 +28,17 @@ type dblink struct {
 }
 
 // Pool is a pool of database connections
+// cnt is the number of connections in the Pool, out the number of
+// connections that have been handed out and not been returned, yet.
 type Pool struct {
-	cnt   int
-	log   *log.Logger
-	link  *dblink
-	lock  sync.RWMutex
-	empty *sync.Cond
+	cnt       int
+	out       int
+	size      int
+	restoring bool
+	log       *log.Logger
+	link      *dblink
+	lock      sync.RWMutex
+	empty     *sync.Cond
 }
 
 // NewPool creates a Pool of database connections.
@@ -42,7 +47,7 @@ type Pool struct {
 func NewPool(cnt int) (*Pool, error) {
 	var (
 		err  error
-		pool = &Pool{cnt: cnt}
+		pool = &Pool{cnt: cnt, size: cnt}
 	)
 
 	pool.empty = sync.NewCond(&pool.lock)
@@ -96,10 +101,11 @@ func (pool *Pool) Get() *Database {
 	defer pool.lock.Unlock()
 
 WAIT_FOR_LINK:
-	if pool.link != nil {
+	if pool.link != nil && !pool.restoring {
 		link = pool.link
 		pool.link = link.next
 		pool.cnt--
+		pool.out++
 
 		link.next = nil
 		return link.db
@@ -111,7 +117,8 @@ WAIT_FOR_LINK:
 } // func (pool *Pool) Get() *DB
 
 // GetNoWait returns a DB connection from the pool.
-// If the pool is empty, it creates a new one.
+// If the pool is empty, it creates a new one. Put closes such extra
+// connections instead of adding them to the pool.
 func (pool *Pool) GetNoWait() (*Database, error) {
 	var db *Database
 	var err error
@@ -119,10 +126,13 @@ func (pool *Pool) GetNoWait() (*Database, error) {
 	pool.lock.Lock()
 	defer pool.lock.Unlock()
 
-	if pool.link != nil {
+	if pool.restoring {
+		return nil, ErrDatabaseInUse
+	} else if pool.link != nil {
 		link := pool.link
 		pool.link = link.next
 		pool.cnt--
+		pool.out++
 		return link.db, nil
 	} else if db, err = Open(common.DbPath); err != nil {
 		pool.log.Printf("[ERROR] Error opening new database connection: %s",
@@ -130,10 +140,12 @@ func (pool *Pool) GetNoWait() (*Database, error) {
 		return nil, err
 	}
 
+	pool.out++
 	return db, nil
 } // func (pool *Pool) GetNoWait() *Database
 
-// Put returns a DB connection to the pool.
+// Put returns a DB connection to the pool. If the pool is full already, the
+// connection is closed.
 func (pool *Pool) Put(db *Database) {
 	link := &dblink{
 		db: db,
@@ -148,13 +160,81 @@ func (pool *Pool) Put(db *Database) {
 	}
 
 	pool.lock.Lock()
-	link.next = pool.link
-	pool.link = link
-	pool.cnt++
+	pool.out--
+	if pool.cnt < pool.size {
+		link.next = pool.link
+		pool.link = link
+		pool.cnt++
+	} else {
+		db.Close() // nolint: errcheck,gosec
+	}
 	pool.lock.Unlock()
-	pool.empty.Signal()
+	// Restore might be waiting, too, so Signal is not enough.
+	pool.empty.Broadcast()
 } // func (pool *Pool) Put(db *Database)
 
+// Restore replaces the database with the given snapshot.
+// It waits for all connections to be returned to the Pool and closes them,
+// so the database files can be swapped safely, then it opens fresh
+// connections to the restored database.
+// Other connections to the database, that did not come from the Pool, must
+// be closed by the caller beforehand.
+func (pool *Pool) Restore(snapshot string) error {
+	var err error
+
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	pool.restoring = true
+
+	// Connections opened by GetNoWait are not counted in cnt, so we
+	// cannot tell from cnt alone if every connection is back.
+	for pool.out > 0 {
+		pool.empty.Wait()
+	}
+
+	for link := pool.link; link != nil; link = link.next {
+		link.db.Close() // nolint: errcheck,gosec
+		link.db = nil
+	}
+
+	pool.link = nil
+	pool.cnt = 0
+
+	if err = restoreFile(snapshot, common.DbPath); err != nil {
+		pool.log.Printf("[ERROR] Cannot restore database from %s: %s\n",
+			snapshot,
+			err.Error())
+	} else {
+		pool.log.Printf("[INFO] Restored database from %s\n", snapshot)
+	}
+
+	// Whether or not the restore worked, we need our connections back.
+	for i := 0; i < pool.size; i++ {
+		var (
+			e2   error
+			link = &dblink{next: pool.link}
+		)
+
+		if link.db, e2 = Open(common.DbPath); e2 != nil {
+			pool.log.Printf("[CRITICAL] Cannot reopen database: %s\n",
+				e2.Error())
+			if err == nil {
+				err = e2
+			}
+			break
+		}
+
+		pool.link = link
+		pool.cnt++
+	}
+
+	pool.restoring = false
+	pool.empty.Broadcast()
+
+	return err
+} // func (pool *Pool) Restore(snapshot string) error
+
 // IsEmpty returns true if the pool is currently empty.
 func (pool *Pool) IsEmpty() bool {
 	pool.lock.RLock()
@@ -22,11 +22,40 @@ const (
 	FileGetByID
 	FileUpdateTitle
 	FileUpdateYear
+	FileSetFingerprint
+	FileMove
+	FileGetByFingerprint
+	FileSetMissing
+	FileGetByFolder
+	FileGetMissing
+	FilePurgeTags
+	FilePurgeCredits
+	FilePurgeURLs
+	MediaInfoAdd
+	MediaInfoDelete
+	MediaInfoGetByFile
+	MediaTrackAdd
+	MediaTrackGetByFile
+	FileGetUnprobed
+	PlayLogAdd
+	PlayLogFinish
+	PlayLogGetByFile
+	FileSetWatched
+	FileSetResumePosition
+	FileGetResumable
 	FolderAdd
 	FolderUpdateScan
 	FolderRemove
 	FolderGetAll
 	FolderGetByPath
+	FolderUpdateRules
+	FileSetStat
+	DirCacheGet
+	DirCacheClear
+	DirCacheAdd
+	ScanRunAdd
+	ScanRunPrune
+	ScanRunGetByFolder
 	TagAdd
 	TagDelete
 	TagGetAll
@@ -36,6 +65,12 @@ const (
 	TagLinkDelete
 	TagLinkGetByTag
 	TagLinkGetByFile
+	TagRename
+	TagSetParent
+	TagMergeLinks
+	TagMergeChildren
+	TagMergeDetach
+	TagPurgeLinks
 	PersonAdd
 	PersonDelete
 	PersonGetAll
@@ -44,12 +79,104 @@ const (
 	PersonURLAdd
 	PersonURLDelete
 	PersonURLGetByPerson
-	ActorAdd
-	ActorDelete
-	ActorGetByPerson
-	ActorGetByFile
-	DirectorAdd
-	DirectorDelete
-	DirectorGetByPerson
-	DirectorGetByFile
+	PersonAliasAdd
+	PersonAliasGetByPerson
+	PersonAliasGetAll
+	PersonMergeCredits
+	PersonMergeURLs
+	PersonMergeAliases
+	PersonPurgeCredits
+	PersonPurgeURLs
+	CollectionAdd
+	CollectionUpdate
+	CollectionDelete
+	CollectionGetAll
+	ExportFolders
+	ExportFiles
+	ExportTags
+	ExportTagLinks
+	ExportPeople
+	ExportAliases
+	ExportPersonURLs
+	ExportFileURLs
+	ExportCredits
+	ImportFolder
+	ImportFolderLookup
+	ImportFile
+	ImportFileLookup
+	ImportTag
+	ImportTagLookup
+	ImportTagLink
+	ImportPerson
+	ImportPersonLookup
+	ImportAlias
+	ImportAliasLookup
+	ImportPersonURL
+	ImportFileURL
+	ImportCredit
+	ExportViewings
+	ImportViewing
+	ExportSeries
+	ExportSeasons
+	ExportEpisodes
+	ExportEpisodeLinks
+	ExportPlayLog
+	ExportMediaInfo
+	ExportMediaTracks
+	ExportCollections
+	ImportSeries
+	ImportSeriesLookup
+	ImportSeason
+	ImportSeasonLookup
+	ImportEpisode
+	ImportEpisodeLookup
+	ImportEpisodeLink
+	ImportEpisodeLinkLookup
+	ImportPlayLog
+	ImportMediaInfo
+	ImportMediaTrack
+	ImportCollection
+	ViewingAdd
+	ViewingGetAll
+	FileSetRating
+	FileGetRatings
+	FileURLGetAll
+	IMDbClearTitles
+	IMDbClearKeys
+	IMDbClearPrincipals
+	IMDbClearNames
+	IMDbTitleAdd
+	IMDbTitleKeyAdd
+	IMDbPrincipalAdd
+	IMDbNameAdd
+	IMDbTitleGetByID
+	IMDbTitleGetByKey
+	IMDbPrincipalGetByTitle
+	FileURLAdd
+	FileURLDelete
+	FileURLGetByFile
+	CreditAdd
+	CreditUpdate
+	CreditDelete
+	CreditGetByFile
+	CreditGetByPerson
+	SeriesAdd
+	SeriesDelete
+	SeriesUpdateTitle
+	SeriesGetAll
+	SeriesGetByID
+	SeasonAdd
+	SeasonDelete
+	SeasonUpdateTitle
+	SeasonSetYear
+	SeasonGetBySeries
+	SeasonGetByID
+	EpisodeAdd
+	EpisodeDelete
+	EpisodeUpdateTitle
+	EpisodeGetBySeason
+	EpisodeGetByID
+	FileSetEpisode
+	FileGetByEpisode
+	Search
 )
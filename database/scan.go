@@ -0,0 +1,447 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/database/scan.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 17. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-17 18:11:37 krylon>
+
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/blicero/blockbuster/database/query"
+	"github.com/blicero/blockbuster/objects"
+)
+
+// The Scanner remembers what Files and directories looked like the last
+// time it saw them, so it does not need to look at them again if they have
+// not changed. It also keeps a summary of each scan, for the user's benefit.
+
+// fileStat turns the size, mtime and inode of a File, as they are stored in
+// the database, into a FileStat.
+func fileStat(size, mtime, inode int64) objects.FileStat {
+	var st = objects.FileStat{
+		Size:  size,
+		Inode: uint64(inode),
+	}
+
+	if mtime != 0 {
+		st.Mtime = time.Unix(0, mtime)
+	}
+
+	return st
+} // func fileStat(size, mtime, inode int64) objects.FileStat
+
+// FileSetStat records the size, modification time and inode of a File.
+func (db *Database) FileSetStat(f *objects.File, st objects.FileStat) error {
+	const qid query.ID = query.FileSetStat
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+		mtime  int64
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	if !st.Mtime.IsZero() {
+		mtime = st.Mtime.UnixNano()
+	}
+
+	stmt = tx.Stmt(stmt)
+
+EXEC_QUERY:
+	if _, err = stmt.Exec(st.Size, mtime, int64(st.Inode), f.ID); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		} else {
+			err = fmt.Errorf("Cannot set stat data of File %s: %s",
+				f.Path,
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return err
+		}
+	}
+
+	status = true
+	f.Stat = st
+	return nil
+} // func (db *Database) FileSetStat(f *objects.File, st objects.FileStat) error
+
+// DirCacheGet returns the modification times of the directories in a
+// Folder, as we saw them during the last scan.
+func (db *Database) DirCacheGet(folder *objects.Folder) (map[string]time.Time, error) {
+	const qid query.ID = query.DirCacheGet
+	var (
+		err  error
+		stmt *sql.Stmt
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(folder.ID); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+
+	var dirs = make(map[string]time.Time)
+
+	for rows.Next() {
+		var (
+			path  string
+			mtime int64
+		)
+
+		if err = rows.Scan(&path, &mtime); err != nil {
+			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
+			return nil, err
+		}
+
+		dirs[path] = time.Unix(0, mtime)
+	}
+
+	return dirs, nil
+} // func (db *Database) DirCacheGet(folder *objects.Folder) (map[string]time.Time, error)
+
+// DirCacheSet replaces the modification times of the directories in a
+// Folder.
+func (db *Database) DirCacheSet(folder *objects.Folder, dirs map[string]time.Time) error {
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+		paths  = make([]string, 0, len(dirs))
+	)
+
+	if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	if stmt, err = db.getQuery(query.DirCacheClear); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			query.DirCacheClear,
+			err.Error())
+		return err
+	}
+
+	stmt = tx.Stmt(stmt)
+
+EXEC_CLEAR:
+	if _, err = stmt.Exec(folder.ID); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_CLEAR
+		}
+
+		err = fmt.Errorf("Cannot clear directory cache of Folder %s: %s",
+			folder.Path,
+			err.Error())
+		db.log.Printf("[ERROR] %s\n", err.Error())
+		return err
+	} else if stmt, err = db.getQuery(query.DirCacheAdd); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			query.DirCacheAdd,
+			err.Error())
+		return err
+	}
+
+	stmt = tx.Stmt(stmt)
+
+	for path := range dirs {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	for _, path := range paths {
+	EXEC_ADD:
+		if _, err = stmt.Exec(folder.ID, path, dirs[path].UnixNano()); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto EXEC_ADD
+			}
+
+			err = fmt.Errorf("Cannot add directory %s to cache: %s",
+				path,
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return err
+		}
+	}
+
+	status = true
+	return nil
+} // func (db *Database) DirCacheSet(folder *objects.Folder, dirs map[string]time.Time) error
+
+// DirCacheClear forgets the modification times of the directories in a
+// Folder, so the next scan looks at all of them.
+func (db *Database) DirCacheClear(folder *objects.Folder) error {
+	return db.DirCacheSet(folder, nil)
+} // func (db *Database) DirCacheClear(folder *objects.Folder) error
+
+// scanRunKeep is the number of ScanRuns we keep for each Folder. The
+// watcher rescans some Folders periodically, so the history would grow
+// without bounds otherwise.
+const scanRunKeep = 100
+
+// ScanRunAdd records the summary of a scan. Older ScanRuns of the same
+// Folder beyond the most recent scanRunKeep are removed.
+func (db *Database) ScanRunAdd(r *objects.ScanRun) error {
+	const qid query.ID = query.ScanRunAdd
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+		res    sql.Result
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	stmt = tx.Stmt(stmt)
+
+EXEC_QUERY:
+	if res, err = stmt.Exec(
+		r.FolderID,
+		millis(r.Start),
+		millis(r.End),
+		r.Status,
+		r.Dirs,
+		r.DirsSkipped,
+		r.Files,
+		r.FilesSkipped,
+		r.FilesNew,
+		r.FilesChanged,
+		r.Errors,
+	); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		err = fmt.Errorf("Cannot add ScanRun for Folder %d: %s",
+			r.FolderID,
+			err.Error())
+		db.log.Printf("[ERROR] %s\n", err.Error())
+		return err
+	} else if r.ID, err = res.LastInsertId(); err != nil {
+		db.log.Printf("[ERROR] Cannot get ID of new ScanRun: %s\n",
+			err.Error())
+		return err
+	} else if stmt, err = db.getQuery(query.ScanRunPrune); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			query.ScanRunPrune,
+			err.Error())
+		return err
+	}
+
+	stmt = tx.Stmt(stmt)
+
+EXEC_PRUNE:
+	if _, err = stmt.Exec(r.FolderID, r.FolderID, scanRunKeep); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_PRUNE
+		}
+
+		err = fmt.Errorf("Cannot prune ScanRuns of Folder %d: %s",
+			r.FolderID,
+			err.Error())
+		db.log.Printf("[ERROR] %s\n", err.Error())
+		return err
+	}
+
+	status = true
+	return nil
+} // func (db *Database) ScanRunAdd(r *objects.ScanRun) error
+
+// ScanRunGetByFolder returns the most recent ScanRuns of a Folder, the most
+// recent one comes first. At most limit ScanRuns are returned.
+func (db *Database) ScanRunGetByFolder(folder *objects.Folder, limit int) ([]objects.ScanRun, error) {
+	const qid query.ID = query.ScanRunGetByFolder
+	var (
+		err  error
+		stmt *sql.Stmt
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(folder.ID, limit); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+
+	var runs = make([]objects.ScanRun, 0, limit)
+
+	for rows.Next() {
+		var (
+			r          = objects.ScanRun{FolderID: folder.ID}
+			start, end int64
+		)
+
+		if err = rows.Scan(
+			&r.ID,
+			&start,
+			&end,
+			&r.Status,
+			&r.Dirs,
+			&r.DirsSkipped,
+			&r.Files,
+			&r.FilesSkipped,
+			&r.FilesNew,
+			&r.FilesChanged,
+			&r.Errors,
+		); err != nil {
+			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
+			return nil, err
+		}
+
+		r.Start = time.Unix(0, start*int64(time.Millisecond))
+		r.End = time.Unix(0, end*int64(time.Millisecond))
+		runs = append(runs, r)
+	}
+
+	return runs, nil
+} // func (db *Database) ScanRunGetByFolder(folder *objects.Folder, limit int) ([]objects.ScanRun, error)
+
+// millis returns t as the number of milliseconds since the epoch.
+func millis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+} // func millis(t time.Time) int64
@@ -0,0 +1,136 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/database/search.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 30. 08. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-08-30 20:14:37 krylon>
+
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"github.com/blicero/blockbuster/database/query"
+	"github.com/blicero/blockbuster/objects"
+)
+
+// The search index is an FTS5 table, which requires go-sqlite3 to be built
+// with the sqlite_fts5 tag. The index is kept up to date by triggers, see
+// the migrations.
+
+// ErrNoFTS5 is returned by Open if SQLite lacks the FTS5 extension.
+var ErrNoFTS5 = errors.New("SQLite was built without FTS5, build blockbuster with -tags sqlite_fts5")
+
+// checkFTS5 makes sure SQLite has the FTS5 extension. Without it, the
+// migrations that create the search index fail with an error that does not
+// tell what went wrong.
+func (db *Database) checkFTS5() error {
+	var (
+		err  error
+		used bool
+	)
+
+	if err = db.db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&used); err != nil {
+		db.log.Printf("[ERROR] Cannot check if SQLite supports FTS5: %s\n",
+			err.Error())
+		return err
+	} else if !used {
+		db.log.Printf("[CRITICAL] %s\n", ErrNoFTS5.Error())
+		return ErrNoFTS5
+	}
+
+	return nil
+} // func (db *Database) checkFTS5() error
+
+// ftsQuery turns whatever the user typed into an FTS5 query.
+// FTS5 has its own query syntax, and a lot of harmless-looking input - say,
+// a hyphen or an unbalanced quote - is a syntax error. So we treat each word
+// of the input as a quoted string and match it as a prefix. All words have
+// to match.
+func ftsQuery(s string) string {
+	var words = strings.Fields(s)
+
+	for i, w := range words {
+		words[i] = `"` + strings.ReplaceAll(w, `"`, `""`) + `"*`
+	}
+
+	return strings.Join(words, " ")
+} // func ftsQuery(s string) string
+
+// Search performs a full-text search across Files, People, Tags, and Links
+// attached to People and Files.
+// The hits are ordered by relevance, the best match comes first.
+// If limit is zero or less, all hits are returned.
+func (db *Database) Search(q string, limit int) ([]objects.SearchHit, error) {
+	const qid query.ID = query.Search
+	var (
+		err    error
+		stmt   *sql.Stmt
+		ftsQry = ftsQuery(q)
+	)
+
+	if ftsQry == "" {
+		return nil, nil
+	} else if limit <= 0 {
+		limit = -1
+	}
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(ftsQry, limit); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		db.log.Printf("[ERROR] Cannot search for %q: %s\n",
+			q,
+			err.Error())
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+
+	var hits = make([]objects.SearchHit, 0, 16)
+
+	for rows.Next() {
+		var (
+			h    objects.SearchHit
+			body string
+		)
+
+		if err = rows.Scan(&h.Kind, &h.ID, &h.OwnerID, &h.Title, &body, &h.Snippet, &h.Rank); err != nil {
+			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
+			return nil, err
+		}
+
+		// Files and Links do not necessarily have a title, so we fall back
+		// to the file name or the URL, respectively.
+		if h.Title == "" {
+			switch h.Kind {
+			case objects.SearchFile:
+				h.Title = filepath.Base(body)
+			case objects.SearchPersonURL, objects.SearchFileURL:
+				if fields := strings.Fields(body); len(fields) > 0 {
+					h.Title = fields[0]
+				}
+			}
+		}
+
+		hits = append(hits, h)
+	}
+
+	return hits, nil
+} // func (db *Database) Search(q string, limit int) ([]objects.SearchHit, error)
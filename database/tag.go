@@ -0,0 +1,366 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/database/tag.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 08. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-08 19:37:05 krylon>
+
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/blicero/blockbuster/database/query"
+	"github.com/blicero/blockbuster/objects"
+)
+
+// TagGetByID looks up a Tag by its ID.
+func (db *Database) TagGetByID(id int64) (*objects.Tag, error) {
+	const qid query.ID = query.TagGetByID
+	var (
+		err  error
+		stmt *sql.Stmt
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(id); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+
+	if rows.Next() {
+		var (
+			t = &objects.Tag{ID: id}
+		)
+
+		if err = rows.Scan(&t.Name, &t.Parent); err != nil {
+			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
+			return nil, err
+		}
+
+		return t, nil
+	}
+
+	return nil, nil
+} // func (db *Database) TagGetByID(id int64) (*objects.Tag, error)
+
+// TagGetByName looks up a Tag by its name.
+func (db *Database) TagGetByName(name string) (*objects.Tag, error) {
+	const qid query.ID = query.TagGetByName
+	var (
+		err  error
+		stmt *sql.Stmt
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(name); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+
+	if rows.Next() {
+		var (
+			t = &objects.Tag{Name: name}
+		)
+
+		if err = rows.Scan(&t.ID, &t.Parent); err != nil {
+			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
+			return nil, err
+		}
+
+		return t, nil
+	}
+
+	return nil, nil
+} // func (db *Database) TagGetByName(name string) (*objects.Tag, error)
+
+// TagRename gives a Tag a new name.
+func (db *Database) TagRename(t *objects.Tag, name string) error {
+	const qid query.ID = query.TagRename
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	stmt = tx.Stmt(stmt)
+
+EXEC_QUERY:
+	if _, err = stmt.Exec(name, t.ID); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		} else {
+			err = fmt.Errorf("Cannot rename Tag %s to %q: %s",
+				t.Name,
+				name,
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return err
+		}
+	}
+
+	status = true
+	t.Name = name
+	return nil
+} // func (db *Database) TagRename(t *objects.Tag, name string) error
+
+// TagSetParent moves a Tag below another one in the hierarchy. If parent is
+// nil, the Tag is moved to the top level.
+// It is an error to move a Tag below itself or one of its descendants.
+func (db *Database) TagSetParent(t, parent *objects.Tag) error {
+	const qid query.ID = query.TagSetParent
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+		cnt    int64
+		pid    int64
+		pname  = "(top level)"
+	)
+
+	if parent != nil {
+		pid = parent.ID
+		pname = parent.Name
+	}
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	stmt = tx.Stmt(stmt)
+	var res sql.Result
+
+EXEC_QUERY:
+	if res, err = stmt.Exec(pid, t.ID); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		} else {
+			err = fmt.Errorf("Cannot move Tag %s below %s: %s",
+				t.Name,
+				pname,
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return err
+		}
+	} else if cnt, err = res.RowsAffected(); err != nil {
+		db.log.Printf("[ERROR] Cannot get number of affected rows: %s\n",
+			err.Error())
+		return err
+	} else if cnt == 0 {
+		err = fmt.Errorf("Cannot move Tag %s below %s: %s is %s or one of its descendants",
+			t.Name,
+			pname,
+			pname,
+			t.Name)
+		db.log.Printf("[ERROR] %s\n", err.Error())
+		return err
+	}
+
+	status = true
+	t.Parent = pid
+	return nil
+} // func (db *Database) TagSetParent(t, parent *objects.Tag) error
+
+// TagMerge merges the Tag drop into keep: All Files tagged with drop are
+// tagged with keep instead, drop's children become keep's children, and
+// drop is deleted.
+func (db *Database) TagMerge(keep, drop *objects.Tag) error {
+	type step struct {
+		qid  query.ID
+		args []interface{}
+	}
+
+	var (
+		err    error
+		msg    string
+		tx     *sql.Tx
+		status bool
+		steps  = []step{
+			{query.TagMergeDetach, []interface{}{keep.ID, drop.ID}},
+			{query.TagMergeChildren, []interface{}{keep.ID, drop.ID}},
+			{query.TagMergeLinks, []interface{}{keep.ID, drop.ID}},
+			{query.TagPurgeLinks, []interface{}{drop.ID}},
+			{query.TagDelete, []interface{}{drop.ID}},
+		}
+	)
+
+	if keep.ID == drop.ID {
+		err = fmt.Errorf("Cannot merge Tag %s (%d) with itself",
+			keep.Name,
+			keep.ID)
+		db.log.Printf("[ERROR] %s\n", err.Error())
+		return err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	for _, s := range steps {
+		var stmt *sql.Stmt
+
+		if stmt, err = db.getQuery(s.qid); err != nil {
+			db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+				s.qid.String(),
+				err.Error())
+			return err
+		}
+
+		stmt = tx.Stmt(stmt)
+
+	EXEC_QUERY:
+		if _, err = stmt.Exec(s.args...); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto EXEC_QUERY
+			} else {
+				err = fmt.Errorf("Cannot merge Tag %s (%d) into %s (%d) (%s): %s",
+					drop.Name,
+					drop.ID,
+					keep.Name,
+					keep.ID,
+					s.qid.String(),
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", err.Error())
+				return err
+			}
+		}
+	}
+
+	status = true
+	return nil
+} // func (db *Database) TagMerge(keep, drop *objects.Tag) error
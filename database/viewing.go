@@ -0,0 +1,325 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/database/viewing.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 14. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-14 18:40:03 krylon>
+
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/blicero/blockbuster/database/query"
+	"github.com/blicero/blockbuster/objects"
+)
+
+// ViewingAdd records that a File was watched at the given time, according
+// to source. It returns false if the Viewing was already known.
+func (db *Database) ViewingAdd(f *objects.File, watched time.Time, source string) (bool, error) {
+	const qid query.ID = query.ViewingAdd
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return false, err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return false, errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	stmt = tx.Stmt(stmt)
+	var res sql.Result
+
+EXEC_QUERY:
+	if res, err = stmt.Exec(f.ID, watched.Unix(), source); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		} else {
+			err = fmt.Errorf("Cannot add Viewing of File %s: %s",
+				f.Path,
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return false, err
+		}
+	}
+
+	var cnt int64
+
+	if cnt, err = res.RowsAffected(); err != nil {
+		db.log.Printf("[ERROR] Cannot get number of rows affected: %s\n",
+			err.Error())
+		return false, err
+	}
+
+	status = true
+	return cnt > 0, nil
+} // func (db *Database) ViewingAdd(f *objects.File, watched time.Time, source string) (bool, error)
+
+// ViewingGetAll returns all Viewings we know of, including the last time
+// we played each File that is marked as watched. The oldest come first.
+func (db *Database) ViewingGetAll() ([]objects.Viewing, error) {
+	const qid query.ID = query.ViewingGetAll
+	var (
+		err  error
+		stmt *sql.Stmt
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(objects.SourcePlayer); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+
+	var list = make([]objects.Viewing, 0, 64)
+
+	for rows.Next() {
+		var (
+			v       objects.Viewing
+			watched int64
+		)
+
+		if err = rows.Scan(&v.ID, &v.FileID, &watched, &v.Source); err != nil {
+			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
+			return nil, err
+		}
+
+		v.Watched = time.Unix(watched, 0)
+		list = append(list, v)
+	}
+
+	return list, nil
+} // func (db *Database) ViewingGetAll() ([]objects.Viewing, error)
+
+// FileSetRating sets the rating of a File, in half stars. 0 removes the
+// rating.
+func (db *Database) FileSetRating(f *objects.File, rating int64) error {
+	const qid query.ID = query.FileSetRating
+	var (
+		err    error
+		msg    string
+		stmt   *sql.Stmt
+		tx     *sql.Tx
+		status bool
+	)
+
+	if rating < 0 || rating > objects.MaxRating {
+		return fmt.Errorf("Invalid rating for File %s: %d",
+			f.Path,
+			rating)
+	}
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid.String(),
+			err.Error())
+		return err
+	} else if db.tx != nil {
+		tx = db.tx
+	} else {
+	BEGIN_AD_HOC:
+		if tx, err = db.db.Begin(); err != nil {
+			if worthARetry(err) {
+				waitForRetry()
+				goto BEGIN_AD_HOC
+			} else {
+				msg = fmt.Sprintf("Error starting transaction: %s\n",
+					err.Error())
+				db.log.Printf("[ERROR] %s\n", msg)
+				return errors.New(msg)
+			}
+
+		} else {
+			defer func() {
+				var err2 error
+				if status {
+					if err2 = tx.Commit(); err2 != nil {
+						db.log.Printf("[ERROR] Failed to commit ad-hoc transaction: %s\n",
+							err2.Error())
+					}
+				} else if err2 = tx.Rollback(); err2 != nil {
+					db.log.Printf("[ERROR] Rollback of ad-hoc transaction failed: %s\n",
+						err2.Error())
+				}
+			}()
+		}
+	}
+
+	stmt = tx.Stmt(stmt)
+
+EXEC_QUERY:
+	if _, err = stmt.Exec(rating, f.ID); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		} else {
+			err = fmt.Errorf("Cannot set rating of File %s: %s",
+				f.Path,
+				err.Error())
+			db.log.Printf("[ERROR] %s\n", err.Error())
+			return err
+		}
+	}
+
+	status = true
+	return nil
+} // func (db *Database) FileSetRating(f *objects.File, rating int64) error
+
+// FileGetRatings returns the ratings of all Files that have one, by the
+// Files' IDs.
+func (db *Database) FileGetRatings() (map[int64]int64, error) {
+	const qid query.ID = query.FileGetRatings
+	var (
+		err  error
+		stmt *sql.Stmt
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+
+	var ratings = make(map[int64]int64)
+
+	for rows.Next() {
+		var id, rating int64
+
+		if err = rows.Scan(&id, &rating); err != nil {
+			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
+			return nil, err
+		}
+
+		ratings[id] = rating
+	}
+
+	return ratings, nil
+} // func (db *Database) FileGetRatings() (map[int64]int64, error)
+
+// FileURLGetAll returns the links of all Files, by the Files' IDs.
+func (db *Database) FileURLGetAll() (map[int64][]objects.Link, error) {
+	const qid query.ID = query.FileURLGetAll
+	var (
+		err  error
+		stmt *sql.Stmt
+	)
+
+	if stmt, err = db.getQuery(qid); err != nil {
+		db.log.Printf("[ERROR] Cannot prepare query %s: %s\n",
+			qid,
+			err.Error())
+		return nil, err
+	} else if db.tx != nil {
+		stmt = db.tx.Stmt(stmt)
+	}
+
+	var rows *sql.Rows
+
+EXEC_QUERY:
+	if rows, err = stmt.Query(); err != nil {
+		if worthARetry(err) {
+			waitForRetry()
+			goto EXEC_QUERY
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close() // nolint: errcheck,gosec
+
+	var links = make(map[int64][]objects.Link)
+
+	for rows.Next() {
+		var (
+			l      objects.Link
+			fileID int64
+			ustr   string
+		)
+
+		if err = rows.Scan(&l.ID, &fileID, &ustr, &l.Title, &l.Description); err != nil {
+			db.log.Printf("[ERROR] Cannot scan row: %s\n", err.Error())
+			return nil, err
+		} else if l.URL, err = url.Parse(ustr); err != nil {
+			db.log.Printf("[ERROR] Cannot parse URL %q: %s\n",
+				ustr,
+				err.Error())
+			return nil, err
+		}
+
+		links[fileID] = append(links[fileID], l)
+	}
+
+	return links, nil
+} // func (db *Database) FileURLGetAll() (map[int64][]objects.Link, error)
@@ -0,0 +1,128 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/diary/01_csv_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 14. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-14 23:04:37 krylon>
+
+package diary
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	var t, _ = time.ParseInLocation(dateFormat, s, time.Local)
+	return t
+} // func date(s string) time.Time
+
+func TestRead(t *testing.T) {
+	type testCase struct {
+		file    string
+		format  Format
+		entries []Entry
+		err     bool
+	}
+
+	var cases = []testCase{
+		{
+			file:   "letterboxd_diary.csv",
+			format: FormatLetterboxd,
+			entries: []Entry{
+				{Line: 2, Title: "Alien", Year: 1979, Watched: date("2021-01-02"), Rating: 9},
+				{Line: 3, Title: "The Thing", Year: 1982, Watched: date("2021-02-10"), Rating: 10},
+				{Line: 4, Title: "Alien", Year: 1979, Watched: date("2021-02-28"), Rating: 9, Rewatch: true},
+				{Line: 5, Title: "Crouching Tiger, Hidden Dragon", Year: 2000, Watched: date("2021-03-05")},
+			},
+		},
+		{
+			file:   "letterboxd_watched.csv",
+			format: FormatLetterboxd,
+			entries: []Entry{
+				{Line: 2, Title: "Heat", Year: 1995, Watched: date("2020-05-01")},
+			},
+		},
+		{
+			file:   "imdb_ratings.csv",
+			format: FormatIMDb,
+			entries: []Entry{
+				{Line: 2, Title: "Alien", Year: 1979, IMDbID: "tt0078748", Watched: date("2020-12-24"), Rating: 9},
+				{Line: 4, Title: "Heat", Year: 1995, IMDbID: "tt0113277", Watched: date("2020-10-10"), Rating: 8},
+			},
+		},
+		{
+			file: "broken.csv",
+			err:  true,
+		},
+	}
+
+	for _, c := range cases {
+		var (
+			err     error
+			fh      *os.File
+			format  Format
+			entries []Entry
+		)
+
+		if fh, err = os.Open(filepath.Join("testdata", c.file)); err != nil {
+			t.Fatalf("Cannot open %s: %s", c.file, err.Error())
+		}
+
+		format, entries, err = Read(fh)
+		fh.Close() // nolint: errcheck,gosec
+
+		if c.err {
+			if err == nil {
+				t.Errorf("Reading %s should have failed", c.file)
+			}
+			continue
+		} else if err != nil {
+			t.Fatalf("Cannot read %s: %s", c.file, err.Error())
+		} else if format != c.format {
+			t.Errorf("Unexpected format for %s: %s (expected %s)", c.file, format, c.format)
+		} else if !reflect.DeepEqual(entries, c.entries) {
+			t.Errorf("Unexpected entries in %s:\n%#v\n\n%#v", c.file, entries, c.entries)
+		}
+	}
+
+	if _, _, err := Read(strings.NewReader("Foo,Bar\n1,2\n")); !errors.Is(err, ErrFormat) {
+		t.Errorf("Reading a CSV file without titles did not fail properly: %v", err)
+	}
+} // func TestRead(t *testing.T)
+
+// TestWriteLetterboxd checks that we can read what we write, and that it is
+// still the same.
+func TestWriteLetterboxd(t *testing.T) {
+	var (
+		err     error
+		buf     bytes.Buffer
+		format  Format
+		entries []Entry
+		orig    = []Entry{
+			{Line: 2, Title: "Alien", Year: 1979, IMDbID: "tt0078748", Watched: date("2021-01-02"), Rating: 9},
+			{Line: 3, Title: "Alien", Year: 1979, IMDbID: "tt0078748", Watched: date("2021-02-28"), Rating: 9, Rewatch: true},
+			{Line: 4, Title: "Crouching Tiger, Hidden Dragon", Year: 2000, Rating: 1},
+			{Line: 5, Title: "Unknown"},
+		}
+	)
+
+	if err = WriteLetterboxd(&buf, orig); err != nil {
+		t.Fatalf("Cannot write CSV: %s", err.Error())
+	} else if !strings.HasPrefix(buf.String(), "Title,Year,imdbID,WatchedDate,Rating,Rewatch\n") {
+		t.Errorf("Unexpected header:\n%s", buf.String())
+	} else if !strings.Contains(buf.String(), ",4.5,") || !strings.Contains(buf.String(), ",0.5,") {
+		t.Errorf("Ratings are not in stars:\n%s", buf.String())
+	} else if format, entries, err = Read(&buf); err != nil {
+		t.Fatalf("Cannot read back CSV: %s", err.Error())
+	} else if format != FormatLetterboxd {
+		t.Errorf("CSV we wrote was recognized as %s", format)
+	} else if !reflect.DeepEqual(entries, orig) {
+		t.Errorf("Entries changed on the way through CSV:\n%#v\n\n%#v", entries, orig)
+	}
+} // func TestWriteLetterboxd(t *testing.T)
@@ -0,0 +1,120 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/diary/02_match_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 14. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-14 23:31:50 krylon>
+
+package diary
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/blicero/blockbuster/objects"
+)
+
+func TestCleanTitle(t *testing.T) {
+	type testCase struct {
+		name  string
+		title string
+		year  int64
+	}
+
+	var cases = []testCase{
+		{"The.Thing.1982.1080p.BluRay.x264.mkv", "The Thing", 1982},
+		{"Alien (1979).mkv", "Alien", 1979},
+		{"Heat_1995_[720p].avi", "Heat", 1995},
+		{"Blade Runner 2049 (2017) 2160p.mkv", "Blade Runner 2049", 2017},
+		{"1917.mkv", "1917", 0},
+		{"2001 - A Space Odyssey.mp4", "2001 - A Space Odyssey", 0},
+		{"Ghostbusters.WEBRip.mkv", "Ghostbusters", 0},
+	}
+
+	for _, c := range cases {
+		if title, year := CleanTitle(c.name); title != c.title || year != c.year {
+			t.Errorf("CleanTitle(%q) = %q, %d (expected %q, %d)",
+				c.name,
+				title,
+				year,
+				c.title,
+				c.year)
+		}
+	}
+} // func TestCleanTitle(t *testing.T)
+
+func TestMatch(t *testing.T) {
+	var (
+		imdb, _ = url.Parse("https://www.imdb.com/title/tt0113277/")
+		files   = []objects.File{
+			{ID: 1, Path: "/movies/Alien.1979.1080p.mkv"},
+			{ID: 2, Path: "/movies/Aliens.mkv", Title: "Aliens", Year: 1986},
+			{ID: 3, Path: "/movies/thing.mkv", Title: "The Thing", Year: 1982},
+			{ID: 4, Path: "/movies/thing2.mkv", Title: "The Thing", Year: 2011},
+			{ID: 5, Path: "/movies/heat.mkv", Title: "Heat (Director's Cut)"},
+			{ID: 6, Path: "/movies/Crouching.Tiger.Hidden.Dragon.mkv"},
+			{ID: 7, Path: "/movies/Ghostbusters.mkv"},
+			{ID: 8, Path: "/movies/Ghostbusters.II.mkv"},
+		}
+		links = map[int64][]objects.Link{
+			5: {{URL: imdb, Title: "IMDb"}},
+		}
+		cands = make([]Candidate, len(files))
+	)
+
+	for idx := range files {
+		cands[idx] = NewCandidate(&files[idx], links[files[idx].ID])
+	}
+
+	type testCase struct {
+		entry  Entry
+		status Status
+		file   int64
+	}
+
+	var cases = []testCase{
+		{Entry{Title: "Alien", Year: 1979}, Matched, 1},
+		{Entry{Title: "Aliens", Year: 1986}, Matched, 2},
+		{Entry{Title: "The Thing", Year: 1982}, Matched, 3},
+		{Entry{Title: "The Thing", Year: 2011}, Matched, 4},
+		{Entry{Title: "The Thing"}, Ambiguous, 0},
+		{Entry{Title: "Heat", Year: 1995, IMDbID: "tt0113277"}, Matched, 5},
+		{Entry{Title: "Crouching Tiger, Hidden Dragon", Year: 2000}, Matched, 6},
+		{Entry{Title: "Ghostbusters 2", Year: 1989}, Ambiguous, 0},
+		{Entry{Title: "Casablanca", Year: 1942}, Unmatched, 0},
+	}
+
+	var m = NewMatcher(cands)
+
+	for _, c := range cases {
+		var r = m.Match(&c.entry)
+
+		if r.Status != c.status {
+			t.Errorf("Unexpected status for %s: %d (expected %d), candidates: %v",
+				c.entry.String(),
+				r.Status,
+				c.status,
+				r.Candidates)
+		} else if f := r.File(); c.file != 0 && (f == nil || f.ID != c.file) {
+			t.Errorf("%s was matched to %v (expected File %d)",
+				c.entry.String(),
+				f,
+				c.file)
+		} else if r.Status == Ambiguous && len(r.Candidates) < 2 {
+			t.Errorf("Ambiguous match for %s has %d candidates",
+				c.entry.String(),
+				len(r.Candidates))
+		}
+	}
+
+	var r = m.Match(&Entry{Title: "The Thing"})
+
+	r.Resolve(1)
+	if f := r.File(); f == nil || r.Status != Matched {
+		t.Error("Resolving an ambiguous match did not work")
+	}
+
+	r.Resolve(-1)
+	if r.Status != Unmatched || r.File() != nil {
+		t.Error("Rejecting all candidates did not work")
+	}
+} // func TestMatch(t *testing.T)
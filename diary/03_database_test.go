@@ -0,0 +1,124 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/diary/03_database_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 14. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-14 23:58:12 krylon>
+
+package diary
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/blicero/blockbuster/common"
+	"github.com/blicero/blockbuster/database"
+	"github.com/blicero/blockbuster/objects"
+)
+
+// TestApply imports Letterboxd's diary into a fresh database, then exports
+// our history and reads it back.
+func TestApply(t *testing.T) {
+	var (
+		err     error
+		db      *database.Database
+		folder  *objects.Folder
+		fh      *os.File
+		format  Format
+		entries []Entry
+		cands   []Candidate
+		cnt     int
+		dir     = filepath.Join(common.BaseDir, "movies")
+		ids     = make(map[string]int64)
+	)
+
+	if db, err = database.Open(common.DbPath); err != nil {
+		t.Fatalf("Cannot open database: %s", err.Error())
+	}
+
+	defer db.Close() // nolint: errcheck
+
+	if folder, err = db.FolderAdd(dir); err != nil {
+		t.Fatalf("Cannot add Folder %s: %s", dir, err.Error())
+	}
+
+	for _, name := range []string{"Alien.1979.mkv", "The.Thing.1982.mkv", "Casablanca.1942.mkv"} {
+		var f *objects.File
+
+		if f, err = db.FileAdd(filepath.Join(dir, name), folder); err != nil {
+			t.Fatalf("Cannot add File %s: %s", name, err.Error())
+		}
+
+		ids[name] = f.ID
+	}
+
+	if fh, err = os.Open(filepath.Join("testdata", "letterboxd_diary.csv")); err != nil {
+		t.Fatalf("Cannot open diary: %s", err.Error())
+	}
+
+	defer fh.Close() // nolint: errcheck
+
+	if format, entries, err = Read(fh); err != nil {
+		t.Fatalf("Cannot read diary: %s", err.Error())
+	} else if cands, err = Candidates(db); err != nil {
+		t.Fatalf("Cannot get Candidates: %s", err.Error())
+	}
+
+	var results = NewMatcher(cands).MatchAll(entries)
+
+	if cnt, err = Apply(db, results, format); err != nil {
+		t.Fatalf("Cannot apply diary: %s", err.Error())
+	} else if cnt != 3 {
+		t.Errorf("Expected 3 Viewings to be added, not %d", cnt)
+	} else if cnt, err = Apply(db, results, format); err != nil {
+		t.Fatalf("Cannot apply diary again: %s", err.Error())
+	} else if cnt != 0 {
+		t.Errorf("Applying the diary again added %d Viewings", cnt)
+	}
+
+	var (
+		ratings map[int64]int64
+		f       *objects.File
+	)
+
+	if ratings, err = db.FileGetRatings(); err != nil {
+		t.Fatalf("Cannot get ratings: %s", err.Error())
+	} else if ratings[ids["Alien.1979.mkv"]] != 9 || ratings[ids["The.Thing.1982.mkv"]] != 10 {
+		t.Errorf("Unexpected ratings: %v", ratings)
+	} else if f, err = db.FileGetByID(ids["Alien.1979.mkv"]); err != nil {
+		t.Fatalf("Cannot look up File: %s", err.Error())
+	} else if !f.Watched {
+		t.Error("File was not marked as watched")
+	} else if f, err = db.FileGetByID(ids["Casablanca.1942.mkv"]); err != nil {
+		t.Fatalf("Cannot look up File: %s", err.Error())
+	} else if f.Watched {
+		t.Error("File that was not in the diary was marked as watched")
+	}
+
+	var (
+		history, back []Entry
+		buf           bytes.Buffer
+	)
+
+	if history, err = History(db); err != nil {
+		t.Fatalf("Cannot get history: %s", err.Error())
+	} else if len(history) != 3 {
+		t.Fatalf("Expected 3 entries in history, got %d: %v", len(history), history)
+	} else if err = WriteLetterboxd(&buf, history); err != nil {
+		t.Fatalf("Cannot write history: %s", err.Error())
+	} else if _, back, err = Read(&buf); err != nil {
+		t.Fatalf("Cannot read history: %s", err.Error())
+	}
+
+	for idx, e := range back {
+		var orig = entries[idx]
+
+		if e.Title != orig.Title || e.Year != orig.Year || !e.Watched.Equal(orig.Watched) || e.Rating != orig.Rating || e.Rewatch != orig.Rewatch {
+			t.Errorf("Entry %d changed on the way through the database:\n%#v\n\n%#v",
+				idx,
+				orig,
+				e)
+		}
+	}
+} // func TestApply(t *testing.T)
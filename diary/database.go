@@ -0,0 +1,171 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/diary/database.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 14. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-14 22:41:19 krylon>
+
+package diary
+
+import (
+	"fmt"
+
+	"github.com/blicero/blockbuster/database"
+	"github.com/blicero/blockbuster/objects"
+)
+
+// Candidates returns all Files from the database, prepared for matching.
+func Candidates(db *database.Database) ([]Candidate, error) {
+	var (
+		err   error
+		files []objects.File
+		links map[int64][]objects.Link
+	)
+
+	if files, err = db.FileGetAll(); err != nil {
+		return nil, err
+	} else if links, err = db.FileURLGetAll(); err != nil {
+		return nil, err
+	}
+
+	var cands = make([]Candidate, len(files))
+
+	for idx := range files {
+		cands[idx] = NewCandidate(&files[idx], links[files[idx].ID])
+	}
+
+	return cands, nil
+} // func Candidates(db *database.Database) ([]Candidate, error)
+
+// Apply records the ratings and Viewings from the matched Results and marks
+// the Files as watched. Results that are not Matched are ignored. Applying
+// the same Results twice does no harm. It returns the number of Viewings
+// that were added.
+func Apply(db *database.Database, results []Result, source Format) (int, error) {
+	var (
+		err   error
+		added bool
+		cnt   int
+	)
+
+	if err = db.Begin(); err != nil {
+		return 0, err
+	}
+
+	defer func() {
+		if err != nil {
+			db.Rollback() // nolint: errcheck,gosec
+		}
+	}()
+
+	for idx := range results {
+		var (
+			r = &results[idx]
+			f = r.File()
+		)
+
+		if f == nil {
+			continue
+		} else if r.Entry.Rating != 0 {
+			if err = db.FileSetRating(f, r.Entry.Rating); err != nil {
+				return cnt, err
+			}
+		}
+
+		if !r.Entry.Watched.IsZero() {
+			if added, err = db.ViewingAdd(f, r.Entry.Watched, string(source)); err != nil {
+				return cnt, err
+			} else if added {
+				cnt++
+			}
+		}
+
+		// Letterboxd and IMDb only let you rate movies you have seen, so
+		// every Entry means the File was watched.
+		if !f.Watched {
+			if err = db.FileSetWatched(f, true); err != nil {
+				return cnt, err
+			}
+		}
+	}
+
+	err = db.Commit()
+	return cnt, err
+} // func Apply(db *database.Database, results []Result, source Format) (int, error)
+
+// History returns our watch history, the oldest Viewings first, followed by
+// the Files that were rated or marked as watched without us knowing when
+// they were watched.
+func History(db *database.Database) ([]Entry, error) {
+	var (
+		err      error
+		files    []objects.File
+		viewings []objects.Viewing
+		links    map[int64][]objects.Link
+		ratings  map[int64]int64
+	)
+
+	if files, err = db.FileGetAll(); err != nil {
+		return nil, err
+	} else if links, err = db.FileURLGetAll(); err != nil {
+		return nil, err
+	} else if ratings, err = db.FileGetRatings(); err != nil {
+		return nil, err
+	} else if viewings, err = db.ViewingGetAll(); err != nil {
+		return nil, err
+	}
+
+	var (
+		byID    = make(map[int64]*Candidate, len(files))
+		seen    = make(map[int64]bool)
+		days    = make(map[string]bool)
+		entries = make([]Entry, 0, len(viewings))
+	)
+
+	for idx := range files {
+		var c = NewCandidate(&files[idx], links[files[idx].ID])
+		byID[c.File.ID] = &c
+	}
+
+	var mkEntry = func(c *Candidate) Entry {
+		return Entry{
+			Title:  c.Title,
+			Year:   c.Year,
+			IMDbID: c.IMDbID,
+			Rating: ratings[c.File.ID],
+		}
+	}
+
+	for _, v := range viewings {
+		var (
+			c   *Candidate
+			e   Entry
+			ok  bool
+			day = fmt.Sprintf("%d/%s", v.FileID, v.Watched.Format(dateFormat))
+		)
+
+		// A Viewing we imported and one we recorded ourselves may well be
+		// the same one.
+		if c, ok = byID[v.FileID]; !ok || days[day] {
+			continue
+		}
+
+		e = mkEntry(c)
+		e.Watched = v.Watched
+		e.Rewatch = seen[v.FileID]
+		entries = append(entries, e)
+		days[day] = true
+		seen[v.FileID] = true
+	}
+
+	for idx := range files {
+		var f = &files[idx]
+
+		if seen[f.ID] || (!f.Watched && ratings[f.ID] == 0) {
+			continue
+		}
+
+		entries = append(entries, mkEntry(byID[f.ID]))
+	}
+
+	return entries, nil
+} // func History(db *database.Database) ([]Entry, error)
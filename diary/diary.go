@@ -0,0 +1,293 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/diary/diary.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 14. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-14 20:37:15 krylon>
+
+// Package diary imports the watch history and ratings people keep on
+// Letterboxd or IMDb, and exports ours in a format Letterboxd can import.
+//
+// Both services let you download your data as CSV files. Letterboxd gives
+// you diary.csv, ratings.csv and watched.csv, IMDb gives you ratings.csv.
+// The columns differ, but they all have the title and year of a movie,
+// which is what we match against our Files, and a rating and/or the date
+// it was watched.
+package diary
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blicero/blockbuster/objects"
+)
+
+// Format identifies where a CSV file comes from.
+type Format string
+
+// These are the formats we can read.
+const (
+	FormatLetterboxd Format = "letterboxd"
+	FormatIMDb       Format = "imdb"
+)
+
+// ErrFormat is returned when a CSV file does not look like anything we
+// know how to read.
+var ErrFormat = errors.New("unknown CSV format")
+
+const dateFormat = "2006-01-02"
+
+// Entry is a movie from someone's watch history. Rating is counted in half
+// stars, from 1 to 10, like we do, 0 means the movie was not rated. Watched
+// is the zero time.Time if we do not know when the movie was watched. Line
+// is where in the CSV file the Entry came from.
+type Entry struct {
+	Line    int
+	Title   string
+	Year    int64
+	IMDbID  string
+	Watched time.Time
+	Rating  int64
+	Rewatch bool
+}
+
+func (e *Entry) String() string {
+	if e.Year == 0 {
+		return e.Title
+	}
+
+	return fmt.Sprintf("%s (%d)", e.Title, e.Year)
+} // func (e *Entry) String() string
+
+// columns maps the fields of an Entry to the columns of a CSV file. A
+// value of -1 means the column is not there.
+type columns struct {
+	title, year, imdb, watched, rating, rating10, rewatch, titleType int
+}
+
+// The column names we know about, in order of preference. Letterboxd's
+// diary.csv has both Date and Watched Date, the former being the day the
+// entry was logged, but in watched.csv, Date is all there is.
+var (
+	colTitle     = []string{"Title", "Name"}
+	colYear      = []string{"Year"}
+	colIMDb      = []string{"imdbID", "Const"}
+	colWatched   = []string{"Watched Date", "WatchedDate", "Date Rated"}
+	colRating    = []string{"Rating"}
+	colRating10  = []string{"Rating10", "Your Rating"}
+	colRewatch   = []string{"Rewatch"}
+	colTitleType = []string{"Title Type"}
+)
+
+// skipTypes are the kinds of titles on IMDb that are not movies.
+var skipTypes = map[string]bool{
+	"tvSeries":       true,
+	"tvMiniSeries":   true,
+	"tvEpisode":      true,
+	"videoGame":      true,
+	"podcastSeries":  true,
+	"podcastEpisode": true,
+}
+
+func findColumn(header map[string]int, names []string) int {
+	for _, n := range names {
+		if idx, ok := header[n]; ok {
+			return idx
+		}
+	}
+
+	return -1
+} // func findColumn(header map[string]int, names []string) int
+
+// detect figures out which columns hold what, and where the file comes
+// from.
+func detect(row []string) (Format, columns, error) {
+	var (
+		cols   columns
+		format = FormatLetterboxd
+		header = make(map[string]int, len(row))
+	)
+
+	for idx, name := range row {
+		header[strings.TrimSpace(name)] = idx
+	}
+
+	cols.title = findColumn(header, colTitle)
+	cols.year = findColumn(header, colYear)
+	cols.imdb = findColumn(header, colIMDb)
+	cols.watched = findColumn(header, colWatched)
+	cols.rating = findColumn(header, colRating)
+	cols.rating10 = findColumn(header, colRating10)
+	cols.rewatch = findColumn(header, colRewatch)
+	cols.titleType = findColumn(header, colTitleType)
+
+	if _, ok := header["Const"]; ok {
+		format = FormatIMDb
+	}
+
+	if cols.watched == -1 && cols.rating == -1 && cols.rating10 == -1 {
+		cols.watched = findColumn(header, []string{"Date"})
+	}
+
+	if cols.title == -1 && cols.imdb == -1 {
+		return "", cols, fmt.Errorf("%w: no title column in %v", ErrFormat, row)
+	}
+
+	return format, cols, nil
+} // func detect(row []string) (Format, columns, error)
+
+func field(row []string, idx int) string {
+	if idx < 0 || idx >= len(row) {
+		return ""
+	}
+
+	return strings.TrimSpace(row[idx])
+} // func field(row []string, idx int) string
+
+// parseRow turns a row into an Entry. It returns nil if the row is not
+// about a movie.
+func parseRow(row []string, cols columns) (*Entry, error) {
+	var (
+		err error
+		e   = &Entry{
+			Title:  field(row, cols.title),
+			IMDbID: field(row, cols.imdb),
+		}
+	)
+
+	if skipTypes[field(row, cols.titleType)] || (e.Title == "" && e.IMDbID == "") {
+		return nil, nil
+	}
+
+	if s := field(row, cols.year); s != "" {
+		if e.Year, err = strconv.ParseInt(s, 10, 64); err != nil {
+			return nil, fmt.Errorf("Invalid year %q", s)
+		}
+	}
+
+	if s := field(row, cols.watched); s != "" {
+		if e.Watched, err = time.ParseInLocation(dateFormat, s, time.Local); err != nil {
+			return nil, fmt.Errorf("Invalid date %q", s)
+		}
+	}
+
+	if s := field(row, cols.rating10); s != "" {
+		if e.Rating, err = strconv.ParseInt(s, 10, 64); err != nil {
+			return nil, fmt.Errorf("Invalid rating %q", s)
+		}
+	} else if s = field(row, cols.rating); s != "" {
+		var stars float64
+
+		if stars, err = strconv.ParseFloat(s, 64); err != nil {
+			return nil, fmt.Errorf("Invalid rating %q", s)
+		}
+
+		e.Rating = int64(math.Round(stars * 2))
+	}
+
+	if e.Rating < 0 || e.Rating > objects.MaxRating {
+		return nil, fmt.Errorf("Rating %d is out of range", e.Rating)
+	}
+
+	switch strings.ToLower(field(row, cols.rewatch)) {
+	case "yes", "true", "1":
+		e.Rewatch = true
+	}
+
+	return e, nil
+} // func parseRow(row []string, cols columns) (*Entry, error)
+
+// Read reads a CSV file exported from Letterboxd or IMDb. Rows that are not
+// about movies, like TV series on IMDb, are skipped.
+func Read(r io.Reader) (Format, []Entry, error) {
+	var (
+		err     error
+		format  Format
+		cols    columns
+		row     []string
+		entries []Entry
+		br      = bufio.NewReader(r)
+	)
+
+	// Some tools put a byte order mark at the beginning of the file,
+	// which would end up in the name of the first column.
+	if bom, _ := br.Peek(3); string(bom) == "\xef\xbb\xbf" {
+		br.Discard(3) // nolint: errcheck,gosec
+	}
+
+	var cr = csv.NewReader(br)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+
+	if row, err = cr.Read(); err != nil {
+		if err == io.EOF {
+			err = fmt.Errorf("%w: file is empty", ErrFormat)
+		}
+		return "", nil, err
+	} else if format, cols, err = detect(row); err != nil {
+		return "", nil, err
+	}
+
+	for line := 2; ; line++ {
+		var e *Entry
+
+		if row, err = cr.Read(); err == io.EOF {
+			break
+		} else if err != nil {
+			return format, nil, err
+		} else if e, err = parseRow(row, cols); err != nil {
+			return format, nil, fmt.Errorf("Line %d: %w", line, err)
+		} else if e != nil {
+			e.Line = line
+			entries = append(entries, *e)
+		}
+	}
+
+	return format, entries, nil
+} // func Read(r io.Reader) (Format, []Entry, error)
+
+// letterboxdHeader lists the columns Letterboxd's importer understands that
+// we have something to put in.
+var letterboxdHeader = []string{"Title", "Year", "imdbID", "WatchedDate", "Rating", "Rewatch"}
+
+// WriteLetterboxd writes entries as a CSV file Letterboxd can import.
+// Letterboxd counts ratings in stars, so a rating of 7 becomes 3.5.
+func WriteLetterboxd(w io.Writer, entries []Entry) error {
+	var cw = csv.NewWriter(w)
+
+	if err := cw.Write(letterboxdHeader); err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		var row = make([]string, len(letterboxdHeader))
+
+		row[0] = e.Title
+		if e.Year != 0 {
+			row[1] = strconv.FormatInt(e.Year, 10)
+		}
+		row[2] = e.IMDbID
+		if !e.Watched.IsZero() {
+			row[3] = e.Watched.Format(dateFormat)
+		}
+		if e.Rating != 0 {
+			row[4] = strconv.FormatFloat(float64(e.Rating)/2, 'f', -1, 64)
+		}
+		if e.Rewatch {
+			row[5] = "true"
+		}
+
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+} // func WriteLetterboxd(w io.Writer, entries []Entry) error
@@ -0,0 +1,376 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/diary/match.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 14. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-14 21:58:02 krylon>
+
+package diary
+
+import (
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/blicero/blockbuster/objects"
+)
+
+// Titles in a CSV file hardly ever look exactly like the titles of our
+// Files, many of which are just cleaned-up file names. So we normalize
+// both, compare them by edit distance and use the year to tell remakes
+// apart. If we know a File's IMDb ID, that settles it.
+
+// Status tells how well an Entry matched our Files.
+type Status uint8
+
+// Matched means we are confident we found the right File, Ambiguous means
+// there are candidates the user needs to choose from, Unmatched means we
+// found nothing.
+const (
+	Unmatched Status = iota
+	Ambiguous
+	Matched
+)
+
+const (
+	matchThreshold  = 0.92
+	matchMargin     = 0.1
+	reviewThreshold = 0.6
+	maxCandidates   = 5
+)
+
+var (
+	imdbIDPattern = regexp.MustCompile(`/title/(tt\d{7,8})`)
+	yearPattern   = regexp.MustCompile(`[\[(]?\b((?:19|20)\d{2})\b[\])]?`)
+	junkPattern   = regexp.MustCompile(`(?i)\b(?:480p|576p|720p|1080p|2160p|4k|uhd|bluray|blu-ray|bdrip|brrip|dvdrip|webrip|web-dl|hdtv|remux|x264|x265|h264|h265|hevc|xvid)\b`)
+	articles      = []string{"the ", "a ", "an "}
+)
+
+// Candidate is a File we may match an Entry to.
+type Candidate struct {
+	File   *objects.File
+	Title  string
+	Year   int64
+	IMDbID string
+	norm   string
+}
+
+// NewCandidate prepares a File for matching. If the File has no title,
+// we make one up from its file name, and the same goes for the year. The
+// IMDb ID comes from the File's links, if there is one.
+func NewCandidate(f *objects.File, links []objects.Link) Candidate {
+	var c = Candidate{
+		File:  f,
+		Title: f.Title,
+		Year:  f.Year,
+	}
+
+	if c.Title == "" {
+		var year int64
+
+		c.Title, year = CleanTitle(filepath.Base(f.Path))
+		if c.Year == 0 {
+			c.Year = year
+		}
+	}
+
+	for _, l := range links {
+		if l.URL == nil || !strings.HasSuffix(l.URL.Host, "imdb.com") {
+			continue
+		} else if m := imdbIDPattern.FindStringSubmatch(l.URL.Path); m != nil {
+			c.IMDbID = m[1]
+			break
+		}
+	}
+
+	c.norm = Normalize(c.Title)
+	return c
+} // func NewCandidate(f *objects.File, links []objects.Link) Candidate
+
+// CleanTitle guesses the title and year of a movie from a file name like
+// "The.Thing.1982.1080p.BluRay.x264.mkv". The year is 0 if there is none.
+func CleanTitle(name string) (string, int64) {
+	var (
+		year  int64
+		title = strings.TrimSuffix(name, filepath.Ext(name))
+	)
+
+	title = strings.NewReplacer(".", " ", "_", " ").Replace(title)
+
+	if loc := junkPattern.FindStringIndex(title); loc != nil && loc[0] > 0 {
+		title = title[:loc[0]]
+	}
+
+	// A year at the very beginning is most likely part of the title, as
+	// in "1917" or "2001: A Space Odyssey".
+	if all := yearPattern.FindAllStringSubmatchIndex(title, -1); len(all) > 0 {
+		var loc = all[len(all)-1]
+
+		if loc[0] > 0 {
+			year, _ = strconv.ParseInt(title[loc[2]:loc[3]], 10, 64)
+			title = title[:loc[0]]
+		}
+	}
+
+	return strings.Join(strings.Fields(strings.Trim(title, " -[(")), " "), year
+} // func CleanTitle(name string) (string, int64)
+
+// Normalize reduces a title to lower case letters and digits, separated by
+// single spaces, without a leading article.
+func Normalize(title string) string {
+	var b strings.Builder
+
+	title = strings.ReplaceAll(strings.ToLower(title), "&", " and ")
+
+	for _, r := range title {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		} else if r != '\'' {
+			b.WriteRune(' ')
+		}
+	}
+
+	var norm = strings.Join(strings.Fields(b.String()), " ")
+
+	for _, a := range articles {
+		if strings.HasPrefix(norm, a) {
+			return norm[len(a):]
+		}
+	}
+
+	return norm
+} // func Normalize(title string) string
+
+// distance returns the Levenshtein distance between two strings.
+func distance(a, b []rune) int {
+	var prev, cur = make([]int, len(b)+1), make([]int, len(b)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			var cost = 1
+
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+} // func distance(a, b []rune) int
+
+func min(x int, rest ...int) int {
+	for _, y := range rest {
+		if y < x {
+			x = y
+		}
+	}
+
+	return x
+} // func min(x int, rest ...int) int
+
+// similarity returns a value between 0 and 1, 1 meaning the strings are
+// the same.
+func similarity(a, b string) float64 {
+	var ra, rb = []rune(a), []rune(b)
+
+	if len(ra) == 0 && len(rb) == 0 {
+		return 1
+	} else if len(rb) > len(ra) {
+		ra, rb = rb, ra
+	}
+
+	return 1 - float64(distance(ra, rb))/float64(len(ra))
+} // func similarity(a, b string) float64
+
+// yearPenalty is subtracted from the similarity of the titles. Release
+// dates differ between countries, so being off by one is not that bad.
+func yearPenalty(a, b int64) float64 {
+	switch {
+	case a == 0 || b == 0:
+		return 0.05
+	case a == b:
+		return 0
+	case a-b == 1 || b-a == 1:
+		return 0.1
+	default:
+		return 0.4
+	}
+} // func yearPenalty(a, b int64) float64
+
+// Scored is a Candidate along with how well it matches an Entry.
+type Scored struct {
+	*Candidate
+	Score float64
+}
+
+// Result is what we found for an Entry. If Status is Matched, the first
+// Candidate is the one. If it is Ambiguous, Candidates holds the best ones,
+// best first.
+type Result struct {
+	Entry      *Entry
+	Status     Status
+	Candidates []Scored
+}
+
+// File returns the File the Entry was matched to, or nil.
+func (r *Result) File() *objects.File {
+	if r.Status != Matched || len(r.Candidates) == 0 {
+		return nil
+	}
+
+	return r.Candidates[0].File
+} // func (r *Result) File() *objects.File
+
+// Resolve settles an ambiguous Result with the Candidate at the given
+// index. A negative index means none of them is right.
+func (r *Result) Resolve(idx int) {
+	if idx < 0 || idx >= len(r.Candidates) {
+		r.Status = Unmatched
+		r.Candidates = nil
+		return
+	}
+
+	r.Status = Matched
+	r.Candidates = r.Candidates[idx : idx+1]
+} // func (r *Result) Resolve(idx int)
+
+// Matcher matches Entries to Candidates. To avoid comparing every Entry to
+// every File, it only looks at Candidates whose titles have a fair share of
+// trigrams in common with the Entry's.
+type Matcher struct {
+	cands  []Candidate
+	grams  map[string][]int
+	byIMDb map[string]int
+}
+
+// minShared is the share of an Entry's trigrams a Candidate needs to have
+// to be considered at all.
+const minShared = 0.3
+
+// trigrams returns the distinct trigrams of a normalized title.
+func trigrams(norm string) []string {
+	var (
+		runes = []rune(" " + norm + " ")
+		seen  = make(map[string]bool)
+		list  = make([]string, 0, len(runes))
+	)
+
+	for i := 0; i+3 <= len(runes); i++ {
+		var g = string(runes[i : i+3])
+
+		if !seen[g] {
+			seen[g] = true
+			list = append(list, g)
+		}
+	}
+
+	return list
+} // func trigrams(norm string) []string
+
+// NewMatcher creates a Matcher for the given Candidates.
+func NewMatcher(cands []Candidate) *Matcher {
+	var m = &Matcher{
+		cands:  cands,
+		grams:  make(map[string][]int),
+		byIMDb: make(map[string]int),
+	}
+
+	for idx := range cands {
+		if cands[idx].IMDbID != "" {
+			m.byIMDb[cands[idx].IMDbID] = idx
+		}
+
+		for _, g := range trigrams(cands[idx].norm) {
+			m.grams[g] = append(m.grams[g], idx)
+		}
+	}
+
+	return m
+} // func NewMatcher(cands []Candidate) *Matcher
+
+// Match finds the Candidates for a single Entry.
+func (m *Matcher) Match(e *Entry) Result {
+	var res = Result{Entry: e}
+
+	if idx, ok := m.byIMDb[e.IMDbID]; ok && e.IMDbID != "" {
+		res.Status = Matched
+		res.Candidates = []Scored{{Candidate: &m.cands[idx], Score: 1}}
+		return res
+	}
+
+	var (
+		norm   = Normalize(e.Title)
+		grams  = trigrams(norm)
+		shared = make(map[int]int)
+		scored []Scored
+	)
+
+	for _, g := range grams {
+		for _, idx := range m.grams[g] {
+			shared[idx]++
+		}
+	}
+
+	for idx, cnt := range shared {
+		if float64(cnt) < minShared*float64(len(grams)) {
+			continue
+		}
+
+		var (
+			c     = &m.cands[idx]
+			score = similarity(norm, c.norm) - yearPenalty(e.Year, c.Year)
+		)
+
+		if score >= reviewThreshold {
+			scored = append(scored, Scored{Candidate: c, Score: score})
+		}
+	}
+
+	if len(scored) == 0 {
+		return res
+	}
+
+	sort.Slice(scored, func(i, j int) bool {
+		if scored[i].Score != scored[j].Score {
+			return scored[i].Score > scored[j].Score
+		}
+		return scored[i].File.ID < scored[j].File.ID
+	})
+
+	if len(scored) > maxCandidates {
+		scored = scored[:maxCandidates]
+	}
+
+	res.Candidates = scored
+
+	if scored[0].Score >= matchThreshold && (len(scored) == 1 || scored[0].Score-scored[1].Score >= matchMargin) {
+		res.Status = Matched
+		res.Candidates = scored[:1]
+	} else {
+		res.Status = Ambiguous
+	}
+
+	return res
+} // func (m *Matcher) Match(e *Entry) Result
+
+// MatchAll matches all Entries.
+func (m *Matcher) MatchAll(entries []Entry) []Result {
+	var results = make([]Result, len(entries))
+
+	for idx := range entries {
+		results[idx] = m.Match(&entries[idx])
+	}
+
+	return results
+} // func (m *Matcher) MatchAll(entries []Entry) []Result
@@ -0,0 +1,157 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/filter/01_parse_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 10. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-10 21:52:30 krylon>
+
+package filter
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/blicero/blockbuster/objects"
+)
+
+func TestParse(t *testing.T) {
+	type testCase struct {
+		query string
+		plain bool
+		cond  objects.Condition
+	}
+
+	var cases = []testCase{
+		{
+			query: "",
+			plain: true,
+			cond:  objects.And(),
+		},
+		{
+			query: "  zardoz  connery ",
+			plain: true,
+			cond: objects.And(
+				objects.Condition{Kind: objects.CondTitle, Text: "zardoz"},
+				objects.Condition{Kind: objects.CondTitle, Text: "connery"},
+			),
+		},
+		{
+			query: `tag:scifi -tag:seen year:1990..1999 actor:"Sigourney Weaver" title:alien`,
+			cond: objects.And(
+				objects.Condition{Kind: objects.CondTag, Text: "scifi"},
+				objects.Not(objects.Condition{Kind: objects.CondTag, Text: "seen"}),
+				objects.And(
+					objects.Condition{Kind: objects.CondYear, Op: objects.OpGe, Value: 1990},
+					objects.Condition{Kind: objects.CondYear, Op: objects.OpLe, Value: 1999},
+				),
+				objects.Condition{Kind: objects.CondPerson, Role: objects.RoleActor, Text: "Sigourney Weaver"},
+				objects.Condition{Kind: objects.CondTitle, Text: "alien"},
+			),
+		},
+		{
+			query: `year:..1970 added:2021.. YEAR:1984 "blade runner" -is:watched is:Untagged`,
+			cond: objects.And(
+				objects.Condition{Kind: objects.CondYear, Op: objects.OpLe, Value: 1970},
+				objects.Condition{Kind: objects.CondAdded, Op: objects.OpGe, Value: 2021},
+				objects.Condition{Kind: objects.CondYear, Op: objects.OpEq, Value: 1984},
+				objects.Condition{Kind: objects.CondTitle, Text: "blade runner"},
+				objects.Not(objects.Condition{Kind: objects.CondWatched}),
+				objects.Condition{Kind: objects.CondUntagged},
+			),
+		},
+		{
+			query: `director:Kubrick person:"Kim Coppola" spider-man`,
+			cond: objects.And(
+				objects.Condition{Kind: objects.CondPerson, Role: objects.RoleDirector, Text: "Kubrick"},
+				objects.Condition{Kind: objects.CondPerson, Text: "Kim Coppola"},
+				objects.Condition{Kind: objects.CondTitle, Text: "spider-man"},
+			),
+		},
+		// Colons in titles do not make fields of the words before them.
+		{
+			query: "Alien: Resurrection",
+			plain: true,
+			cond: objects.And(
+				objects.Condition{Kind: objects.CondTitle, Text: "Alien:"},
+				objects.Condition{Kind: objects.CondTitle, Text: "Resurrection"},
+			),
+		},
+		{
+			query: "Star Trek:Voyager genre:horror :alien",
+			plain: true,
+			cond: objects.And(
+				objects.Condition{Kind: objects.CondTitle, Text: "Star"},
+				objects.Condition{Kind: objects.CondTitle, Text: "Trek:Voyager"},
+				objects.Condition{Kind: objects.CondTitle, Text: "genre:horror"},
+				objects.Condition{Kind: objects.CondTitle, Text: ":alien"},
+			),
+		},
+		{
+			query: `-Alien: tag:scifi`,
+			cond: objects.And(
+				objects.Not(objects.Condition{Kind: objects.CondTitle, Text: "Alien:"}),
+				objects.Condition{Kind: objects.CondTag, Text: "scifi"},
+			),
+		},
+	}
+
+	for _, c := range cases {
+		var (
+			err error
+			q   *Query
+		)
+
+		if q, err = Parse(c.query); err != nil {
+			t.Errorf("Cannot parse %q: %s", c.query, err.Error())
+		} else if q.Plain != c.plain {
+			t.Errorf("Unexpected Plain flag for %q: %t (expected %t)",
+				c.query,
+				q.Plain,
+				c.plain)
+		} else if !reflect.DeepEqual(q.Cond, c.cond) {
+			t.Errorf("Unexpected Condition for %q:\n%#v\n(expected %#v)",
+				c.query,
+				q.Cond,
+				c.cond)
+		}
+	}
+} // func TestParse(t *testing.T)
+
+func TestParseErrors(t *testing.T) {
+	type testCase struct {
+		query string
+		pos   int
+	}
+
+	var cases = []testCase{
+		{`title:"alien`, 6},
+		{`- alien`, 0},
+		{`alien -`, 6},
+		{`tag:`, 4},
+		{`year:198x`, 5},
+		{`year:1990..19x`, 11},
+		{`year:..`, 5},
+		{`year:1999..1990`, 5},
+		{`alien is:boring`, 9},
+		{`""`, 0},
+	}
+
+	for _, c := range cases {
+		var (
+			err  error
+			serr *SyntaxError
+		)
+
+		if _, err = Parse(c.query); err == nil {
+			t.Errorf("Parsing %q did not cause an error", c.query)
+		} else if !errors.As(err, &serr) {
+			t.Errorf("Unexpected type of error for %q: %T", c.query, err)
+		} else if serr.Pos != c.pos {
+			t.Errorf("Unexpected position of error in %q: %d (expected %d) - %s",
+				c.query,
+				serr.Pos,
+				c.pos,
+				serr.Error())
+		}
+	}
+} // func TestParseErrors(t *testing.T)
@@ -0,0 +1,321 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/filter/filter.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 10. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-10 21:34:07 krylon>
+
+// Package filter implements the little query language of the search bars.
+// A query is a list of terms separated by whitespace, all of which must
+// match, e.g.
+//
+//	tag:scifi -tag:seen year:1990..1999 actor:"Sigourney Weaver" title:alien
+//
+// A term is either a word or a quoted string, which is looked for in the
+// title, or a field, a colon, and a value. A word that merely contains a
+// colon, as in "Alien: Resurrection", is looked for in the title, too,
+// unless the part before the colon is one of the fields below. A term that
+// starts with a minus matches the Files the term without the minus does not
+// match.
+//
+// The fields are:
+//
+//	tag       Tagged with the Tag of that name or one of its descendants
+//	actor     The Person of that name (or alias) is credited as an Actor
+//	director  The Person of that name (or alias) is credited as Director
+//	person    The Person of that name (or alias) is credited at all
+//	title     Title or path contain the value
+//	year      The year is in the range, e.g. 1984, 1990..1999, ..1970, 2000..
+//	added     The File was added in the range of years, like year
+//	is        watched or untagged
+//
+// Queries are compiled into objects.Condition, the database turns them
+// into SQL.
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/blicero/blockbuster/objects"
+)
+
+// SyntaxError is returned when a query cannot be parsed. Pos is the offset,
+// in bytes, into the query where the problem was found.
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("Syntax error at position %d: %s", e.Pos+1, e.Msg)
+} // func (e *SyntaxError) Error() string
+
+// Query is the result of parsing a query string.
+type Query struct {
+	Cond objects.Condition
+	// Plain is true if the query consists of nothing but plain words,
+	// i.e. does not use any of the syntax of the query language. The
+	// search bars use the full-text search for those.
+	Plain bool
+}
+
+// fields are the field names the query language knows about, see the
+// package documentation.
+var fields = map[string]bool{
+	"tag":      true,
+	"actor":    true,
+	"director": true,
+	"person":   true,
+	"title":    true,
+	"year":     true,
+	"added":    true,
+	"is":       true,
+}
+
+// parser holds the state while we work our way through a query.
+type parser struct {
+	s     string
+	pos   int
+	plain bool
+}
+
+// Parse parses a query. An empty query matches everything.
+func Parse(s string) (*Query, error) {
+	var (
+		p     = &parser{s: s, plain: true}
+		conds []objects.Condition
+	)
+
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.s) {
+			break
+		}
+
+		var c, err = p.term()
+
+		if err != nil {
+			return nil, err
+		}
+
+		conds = append(conds, c)
+	}
+
+	return &Query{Cond: objects.And(conds...), Plain: p.plain}, nil
+} // func Parse(s string) (*Query, error)
+
+func (p *parser) errorf(pos int, format string, args ...interface{}) error {
+	return &SyntaxError{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+} // func (p *parser) errorf(pos int, format string, args ...interface{}) error
+
+func (p *parser) peek() rune {
+	if p.pos >= len(p.s) {
+		return 0
+	}
+
+	var r, _ = utf8.DecodeRuneInString(p.s[p.pos:])
+	return r
+} // func (p *parser) peek() rune
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.s) {
+		var r, size = utf8.DecodeRuneInString(p.s[p.pos:])
+		if !unicode.IsSpace(r) {
+			return
+		}
+		p.pos += size
+	}
+} // func (p *parser) skipSpace()
+
+// word reads everything up to the next whitespace or, if stopAtColon is
+// true, the next colon.
+func (p *parser) word(stopAtColon bool) string {
+	var start = p.pos
+
+	for p.pos < len(p.s) {
+		var r, size = utf8.DecodeRuneInString(p.s[p.pos:])
+		if unicode.IsSpace(r) || (stopAtColon && r == ':') {
+			break
+		}
+		p.pos += size
+	}
+
+	return p.s[start:p.pos]
+} // func (p *parser) word(stopAtColon bool) string
+
+// quoted reads a string in double quotes. p.pos must point at the opening
+// quote.
+func (p *parser) quoted() (string, error) {
+	var (
+		start = p.pos
+		end   = strings.IndexByte(p.s[start+1:], '"')
+	)
+
+	if end < 0 {
+		return "", p.errorf(start, "Unterminated string")
+	}
+
+	p.pos = start + 1 + end + 1
+	return p.s[start+1 : start+1+end], nil
+} // func (p *parser) quoted() (string, error)
+
+// value reads the value of a field, which is a word or a quoted string.
+func (p *parser) value() (string, error) {
+	if p.peek() == '"' {
+		return p.quoted()
+	}
+
+	return p.word(false), nil
+} // func (p *parser) value() (string, error)
+
+func (p *parser) term() (objects.Condition, error) {
+	var (
+		err    error
+		c      objects.Condition
+		negate bool
+		start  = p.pos
+	)
+
+	if p.peek() == '-' {
+		negate = true
+		p.plain = false
+		p.pos++
+
+		if r := p.peek(); r == 0 || unicode.IsSpace(r) {
+			return c, p.errorf(start, "Expected a term after -")
+		}
+	}
+
+	if p.peek() == '"' {
+		var text string
+
+		p.plain = false
+		if text, err = p.quoted(); err != nil {
+			return c, err
+		} else if text == "" {
+			return c, p.errorf(start, "Empty string")
+		}
+
+		c = objects.Condition{Kind: objects.CondTitle, Text: text}
+	} else {
+		var (
+			fieldPos = p.pos
+			w        = p.word(true)
+		)
+
+		if p.peek() == ':' && fields[strings.ToLower(w)] {
+			p.plain = false
+			p.pos++
+
+			if c, err = p.field(strings.ToLower(w), fieldPos); err != nil {
+				return c, err
+			}
+		} else {
+			// Not a field, so the colon is part of the word.
+			p.pos = fieldPos
+			c = objects.Condition{Kind: objects.CondTitle, Text: p.word(false)}
+		}
+	}
+
+	if negate {
+		return objects.Not(c), nil
+	}
+
+	return c, nil
+} // func (p *parser) term() (objects.Condition, error)
+
+// field parses the value of a field and turns it into a Condition.
+func (p *parser) field(name string, fieldPos int) (objects.Condition, error) {
+	var (
+		err      error
+		val      string
+		c        objects.Condition
+		valuePos = p.pos
+	)
+
+	if val, err = p.value(); err != nil {
+		return c, err
+	} else if val == "" {
+		return c, p.errorf(valuePos, "Missing value for %s", name)
+	}
+
+	switch name {
+	case "tag":
+		return objects.Condition{Kind: objects.CondTag, Text: val}, nil
+	case "actor":
+		return objects.Condition{Kind: objects.CondPerson, Role: objects.RoleActor, Text: val}, nil
+	case "director":
+		return objects.Condition{Kind: objects.CondPerson, Role: objects.RoleDirector, Text: val}, nil
+	case "person":
+		return objects.Condition{Kind: objects.CondPerson, Text: val}, nil
+	case "title":
+		return objects.Condition{Kind: objects.CondTitle, Text: val}, nil
+	case "year":
+		return p.yearRange(objects.CondYear, val, valuePos)
+	case "added":
+		return p.yearRange(objects.CondAdded, val, valuePos)
+	case "is":
+		switch strings.ToLower(val) {
+		case "watched":
+			return objects.Condition{Kind: objects.CondWatched}, nil
+		case "untagged":
+			return objects.Condition{Kind: objects.CondUntagged}, nil
+		default:
+			return c, p.errorf(valuePos, "Unknown value for is: %q (expected watched or untagged)", val)
+		}
+	default:
+		return c, p.errorf(fieldPos, "Unknown field %q", name)
+	}
+} // func (p *parser) field(name string, fieldPos int) (objects.Condition, error)
+
+// yearRange parses a single year or a range of years, where either end of
+// the range may be left open, and returns a Condition of the given kind.
+func (p *parser) yearRange(kind objects.CondKind, val string, pos int) (objects.Condition, error) {
+	var (
+		err        error
+		from, to   int64
+		fromS, toS string
+		isRange    bool
+		c          objects.Condition
+	)
+
+	if idx := strings.Index(val, ".."); idx >= 0 {
+		isRange = true
+		fromS, toS = val[:idx], val[idx+2:]
+	} else {
+		fromS = val
+	}
+
+	if fromS != "" {
+		if from, err = strconv.ParseInt(fromS, 10, 64); err != nil || from <= 0 {
+			return c, p.errorf(pos, "Invalid year: %q", fromS)
+		}
+	}
+
+	if toS != "" {
+		if to, err = strconv.ParseInt(toS, 10, 64); err != nil || to <= 0 {
+			return c, p.errorf(pos+len(fromS)+2, "Invalid year: %q", toS)
+		}
+	}
+
+	switch {
+	case !isRange:
+		return objects.Condition{Kind: kind, Op: objects.OpEq, Value: from}, nil
+	case from == 0 && to == 0:
+		return c, p.errorf(pos, "A range needs at least one end")
+	case from == 0:
+		return objects.Condition{Kind: kind, Op: objects.OpLe, Value: to}, nil
+	case to == 0:
+		return objects.Condition{Kind: kind, Op: objects.OpGe, Value: from}, nil
+	case from > to:
+		return c, p.errorf(pos, "Empty range: %d is after %d", from, to)
+	default:
+		return objects.And(
+			objects.Condition{Kind: kind, Op: objects.OpGe, Value: from},
+			objects.Condition{Kind: kind, Op: objects.OpLe, Value: to},
+		), nil
+	}
+} // func (p *parser) yearRange(kind objects.CondKind, val string, pos int) (objects.Condition, error)
@@ -0,0 +1,79 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/imdb/01_load_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 15. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-15 22:41:07 krylon>
+
+package imdb
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blicero/blockbuster/common"
+	"github.com/blicero/blockbuster/database"
+	"github.com/blicero/blockbuster/objects"
+)
+
+var tdb *database.Database
+
+func TestLoad(t *testing.T) {
+	var (
+		err        error
+		stats      *Stats
+		titles     []objects.IMDbTitle
+		principals []objects.IMDbPrincipal
+		calls      int
+	)
+
+	if tdb, err = database.Open(common.DbPath); err != nil {
+		t.Fatalf("Cannot open database: %s", err.Error())
+	} else if _, err = Load(tdb, "nonexistent", nil); err == nil {
+		t.Error("Loading from a directory without datasets did not fail")
+	} else if stats, err = Load(tdb, "testdata", func(string, int) { calls++ }); err != nil {
+		t.Fatalf("Cannot load datasets: %s", err.Error())
+	} else if exp := (Stats{Titles: 10, Principals: 11, Names: 9}); *stats != exp {
+		t.Errorf("Unexpected number of rows loaded: %#v (expected %#v)", *stats, exp)
+	} else if calls != 0 {
+		t.Errorf("Progress was reported %d times for a handful of rows", calls)
+	}
+
+	// Loading the same datasets twice replaces the first load.
+	if stats, err = Load(tdb, "testdata", nil); err != nil {
+		t.Fatalf("Cannot load datasets again: %s", err.Error())
+	} else if stats.Titles != 10 {
+		t.Errorf("Unexpected number of titles loaded the second time: %d", stats.Titles)
+	} else if titles, err = tdb.IMDbTitleGetByKey("thing"); err != nil {
+		t.Fatalf("Cannot look up titles: %s", err.Error())
+	} else if len(titles) != 2 || titles[0].ID != "tt0084787" || titles[1].ID != "tt0905372" {
+		t.Errorf("Unexpected titles for \"thing\": %v", titles)
+	} else if titles, err = tdb.IMDbTitleGetByKey("wo hu cang long"); err != nil {
+		t.Fatalf("Cannot look up titles: %s", err.Error())
+	} else if len(titles) != 1 || titles[0].Title != "Crouching Tiger, Hidden Dragon" {
+		t.Errorf("Title was not found by its original title: %v", titles)
+	} else if titles, err = tdb.IMDbTitleGetByKey("breaking bad"); err != nil {
+		t.Fatalf("Cannot look up titles: %s", err.Error())
+	} else if len(titles) != 0 {
+		t.Errorf("TV series was loaded: %v", titles)
+	} else if principals, err = tdb.IMDbPrincipalGetByTitle("tt0078748"); err != nil {
+		t.Fatalf("Cannot get principals: %s", err.Error())
+	} else if len(principals) != 6 {
+		t.Fatalf("Expected 6 principals for Alien, got %d", len(principals))
+	}
+
+	var exp = objects.IMDbPrincipal{
+		TitleID:    "tt0078748",
+		Ordering:   1,
+		NameID:     "nm0000244",
+		Category:   "actress",
+		Characters: `["Ripley"]`,
+		Name:       "Sigourney Weaver",
+		BirthYear:  1949,
+	}
+
+	if !reflect.DeepEqual(principals[0], exp) {
+		t.Errorf("Unexpected principal:\n%#v\n\n%#v", principals[0], exp)
+	} else if principals[5].Name != "Michael Seymour" || principals[5].BirthYear != 0 {
+		t.Errorf("Missing birth year was not handled: %#v", principals[5])
+	}
+} // func TestLoad(t *testing.T)
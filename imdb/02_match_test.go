@@ -0,0 +1,152 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/imdb/02_match_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 15. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-15 23:17:42 krylon>
+
+package imdb
+
+import (
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/blicero/blockbuster/common"
+	"github.com/blicero/blockbuster/objects"
+)
+
+var files = make(map[string]*objects.File)
+
+func TestMatch(t *testing.T) {
+	if tdb == nil {
+		t.SkipNow()
+	}
+
+	var (
+		err           error
+		folder        *objects.Folder
+		first, second []Match
+		dir           = filepath.Join(common.BaseDir, "movies")
+		expect        = map[string]string{
+			"Alien (1979).mkv":                       "tt0078748",
+			"Aliens.mkv":                             "tt0090605",
+			"The.Thing.1982.mkv":                     "tt0084787",
+			"Heat.mkv":                               "tt0113277",
+			"heat-dc.mkv":                            "tt0113277",
+			"Crouching.Tiger.Hidden.Dragon.2001.mkv": "tt0190332",
+			"Wo Hu Cang Long.mkv":                    "tt0190332",
+			"2001 - A Space Odyssey.mkv":             "tt0062622",
+			"Solaris.1972.mkv":                       "tt0069293",
+			// No way to tell which one it is.
+			"The.Thing.mkv": "",
+			"Solaris.mkv":   "",
+			// Not a movie.
+			"Breaking Bad.mkv": "",
+		}
+	)
+
+	if folder, err = tdb.FolderAdd(dir); err != nil {
+		t.Fatalf("Cannot add Folder %s: %s", dir, err.Error())
+	}
+
+	for name := range expect {
+		if files[name], err = tdb.FileAdd(filepath.Join(dir, name), folder); err != nil {
+			t.Fatalf("Cannot add File %s: %s", name, err.Error())
+		}
+	}
+
+	var l = objects.Link{Title: "IMDb"}
+
+	l.URL, _ = url.Parse("https://www.imdb.com/title/tt0113277/")
+
+	if err = tdb.FileUpdateTitle(files["heat-dc.mkv"], "Heat (Director's Cut)"); err != nil {
+		t.Fatalf("Cannot set title: %s", err.Error())
+	} else if err = tdb.FileURLAdd(files["heat-dc.mkv"], &l); err != nil {
+		t.Fatalf("Cannot add link: %s", err.Error())
+	} else if first, err = FindMatches(tdb); err != nil {
+		t.Fatalf("Cannot match Files: %s", err.Error())
+	} else if second, err = FindMatches(tdb); err != nil {
+		t.Fatalf("Cannot match Files again: %s", err.Error())
+	} else if !reflect.DeepEqual(first, second) {
+		t.Errorf("Matching the same Files twice gave different results:\n%v\n\n%v",
+			first,
+			second)
+	}
+
+	var found = make(map[string]string)
+
+	for _, m := range first {
+		found[filepath.Base(m.File.Path)] = m.Title.ID
+
+		if m.ByID != (filepath.Base(m.File.Path) == "heat-dc.mkv") {
+			t.Errorf("%s was matched by ID: %t", m.File.Path, m.ByID)
+		}
+	}
+
+	for name, id := range expect {
+		if found[name] != id {
+			t.Errorf("%s was matched to %q (expected %q)", name, found[name], id)
+		}
+	}
+} // func TestMatch(t *testing.T)
+
+func TestApply(t *testing.T) {
+	if tdb == nil || len(files) == 0 {
+		t.SkipNow()
+	}
+
+	var (
+		err     error
+		matches []Match
+		res     *Result
+		p       *objects.Person
+		f       *objects.File
+		cast    []objects.CastMember
+		links   []objects.Link
+	)
+
+	if matches, err = FindMatches(tdb); err != nil {
+		t.Fatalf("Cannot match Files: %s", err.Error())
+	} else if res, err = Apply(tdb, matches); err != nil {
+		t.Fatalf("Cannot apply matches: %s", err.Error())
+	} else if exp := (Result{Files: 9, People: 9, Credits: 14}); *res != exp {
+		t.Errorf("Unexpected result: %#v (expected %#v)", *res, exp)
+	} else if res, err = Apply(tdb, matches); err != nil {
+		t.Fatalf("Cannot apply matches again: %s", err.Error())
+	} else if res.People != 0 || res.Credits != 0 {
+		t.Errorf("Applying the same matches twice added something: %#v", *res)
+	}
+
+	if p, err = tdb.PersonGetByName("Sigourney Weaver"); err != nil {
+		t.Fatalf("Cannot look up Person: %s", err.Error())
+	} else if p == nil {
+		t.Fatal("Sigourney Weaver was not added")
+	} else if p.Birthday.Year() != 1949 {
+		t.Errorf("Unexpected birthday: %s", p.BDayString())
+	} else if links, err = tdb.PersonURLGetByPerson(p); err != nil {
+		t.Fatalf("Cannot get links: %s", err.Error())
+	} else if len(links) != 1 || links[0].URL.String() != NameURL("nm0000244") {
+		t.Errorf("Unexpected links for %s: %v", p.Name, links)
+	}
+
+	if f, err = tdb.FileGetByID(files["Alien (1979).mkv"].ID); err != nil {
+		t.Fatalf("Cannot look up File: %s", err.Error())
+	} else if cast, err = tdb.CreditGetByFile(f); err != nil {
+		t.Fatalf("Cannot get Credits: %s", err.Error())
+	} else if len(cast) != 6 {
+		t.Errorf("Expected 6 Credits for Alien, got %d", len(cast))
+	} else if f, err = tdb.FileGetByID(files["Aliens.mkv"].ID); err != nil {
+		t.Fatalf("Cannot look up File: %s", err.Error())
+	} else if f.Title != "Aliens" || f.Year != 1986 {
+		t.Errorf("Title and year were not set: %q (%d)", f.Title, f.Year)
+	}
+
+	for _, c := range cast {
+		if c.Person.Name == "Sigourney Weaver" && (c.Credit.Role != objects.RoleActor || c.Credit.Character != "Ripley" || c.Credit.Billing != 1) {
+			t.Errorf("Unexpected Credit for %s: %#v", c.Person.Name, c.Credit)
+		} else if c.Person.Name == "Michael Seymour" && c.Credit.Role != objects.RoleOther {
+			t.Errorf("Unexpected Role for %s: %s", c.Person.Name, c.Credit.Role)
+		}
+	}
+} // func TestApply(t *testing.T)
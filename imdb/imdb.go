@@ -0,0 +1,295 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/imdb/imdb.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 15. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-15 20:44:31 krylon>
+
+// Package imdb imports the datasets IMDb offers for non-commercial use, see
+// https://www.imdb.com/interfaces/
+//
+// The datasets are gzipped TSV files, and they are large, so we stream them
+// into staging tables, keeping only movies and the people who worked on
+// them. From there, we match them to our Files and add the cast and crew.
+// Nothing ever goes out to the network, the user downloads the files.
+package imdb
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/blicero/blockbuster/database"
+	"github.com/blicero/blockbuster/diary"
+	"github.com/blicero/blockbuster/objects"
+)
+
+// These are the names of the files we need, as IMDb calls them.
+const (
+	FileTitles     = "title.basics.tsv.gz"
+	FilePrincipals = "title.principals.tsv.gz"
+	FileNames      = "name.basics.tsv.gz"
+)
+
+// null is how the datasets spell a missing value.
+const null = `\N`
+
+// progressInterval is the number of rows after which we report progress.
+const progressInterval = 100000
+
+// movieTypes are the kinds of titles we keep, everything else - mostly TV
+// series and their episodes - is skipped.
+var movieTypes = map[string]bool{
+	"movie":     true,
+	"tvMovie":   true,
+	"video":     true,
+	"short":     true,
+	"tvSpecial": true,
+}
+
+// Stats counts the rows we put in the staging tables.
+type Stats struct {
+	Titles     int
+	Principals int
+	Names      int
+}
+
+// Progress is called every now and then while loading the datasets, with
+// the name of the file we are reading and the number of rows read from it
+// so far.
+type Progress func(file string, rows int)
+
+// row gives access to the columns of a row by name. Missing values are
+// returned as empty strings.
+type row struct {
+	cols   map[string]int
+	fields []string
+}
+
+func (r *row) get(col string) string {
+	if idx, ok := r.cols[col]; ok && idx < len(r.fields) && r.fields[idx] != null {
+		return r.fields[idx]
+	}
+
+	return ""
+} // func (r *row) get(col string) string
+
+func (r *row) getInt(col string) (int64, error) {
+	if s := r.get(col); s != "" {
+		return strconv.ParseInt(s, 10, 64)
+	}
+
+	return 0, nil
+} // func (r *row) getInt(col string) (int64, error)
+
+// readTSV reads a gzipped TSV file one row at a time and passes each row
+// to fn. The columns listed in required must be present in the header.
+// IMDb does not quote anything, so a tab is always a separator.
+func readTSV(path string, required []string, progress Progress, fn func(r *row) error) error {
+	var (
+		err error
+		fh  *os.File
+		gz  *gzip.Reader
+		sc  *bufio.Scanner
+		r   = row{cols: make(map[string]int)}
+		cnt int
+	)
+
+	if fh, err = os.Open(path); err != nil {
+		return err
+	}
+
+	defer fh.Close() // nolint: errcheck
+
+	if gz, err = gzip.NewReader(fh); err != nil {
+		return fmt.Errorf("Cannot decompress %s: %w", path, err)
+	}
+
+	sc = bufio.NewScanner(gz)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	if !sc.Scan() {
+		if err = sc.Err(); err == nil {
+			err = fmt.Errorf("%s is empty", path)
+		}
+		return err
+	}
+
+	for idx, name := range strings.Split(sc.Text(), "\t") {
+		r.cols[name] = idx
+	}
+
+	for _, name := range required {
+		if _, ok := r.cols[name]; !ok {
+			return fmt.Errorf("%s does not have a column %s", path, name)
+		}
+	}
+
+	for line := 2; sc.Scan(); line++ {
+		r.fields = strings.Split(sc.Text(), "\t")
+
+		if err = fn(&r); err != nil {
+			return fmt.Errorf("%s, line %d: %w",
+				filepath.Base(path),
+				line,
+				err)
+		}
+
+		if cnt++; progress != nil && cnt%progressInterval == 0 {
+			progress(filepath.Base(path), cnt)
+		}
+	}
+
+	if err = sc.Err(); err != nil {
+		return err
+	} else if err = gz.Close(); err != nil {
+		return fmt.Errorf("Cannot decompress %s: %w", path, err)
+	}
+
+	return nil
+} // func readTSV(path string, required []string, progress Progress, fn func(r *row) error) error
+
+// Keys returns the keys under which a title can be found in the staging
+// area, the normalized primary and original title.
+func Keys(t *objects.IMDbTitle) []string {
+	var keys = []string{diary.Normalize(t.Title)}
+
+	if orig := diary.Normalize(t.OriginalTitle); orig != "" && orig != keys[0] {
+		keys = append(keys, orig)
+	}
+
+	return keys
+} // func Keys(t *objects.IMDbTitle) []string
+
+// Load reads the datasets from the given directory into the staging
+// tables, replacing whatever was there before. Only movies and the people
+// who worked on them are kept. progress may be nil.
+// Everything happens in a single transaction, so if anything goes wrong,
+// the staging area is left as it was.
+func Load(db *database.Database, dir string, progress Progress) (*Stats, error) {
+	var (
+		err    error
+		stats  = new(Stats)
+		titles = make(map[string]bool)
+		people = make(map[string]bool)
+	)
+
+	if err = db.Begin(); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			db.Rollback() // nolint: errcheck,gosec
+		}
+	}()
+
+	if err = db.IMDbClear(); err != nil {
+		return nil, err
+	}
+
+	err = readTSV(filepath.Join(dir, FileTitles),
+		[]string{"tconst", "titleType", "primaryTitle", "originalTitle", "startYear"},
+		progress,
+		func(r *row) error {
+			var (
+				err error
+				t   = objects.IMDbTitle{
+					ID:            r.get("tconst"),
+					Type:          r.get("titleType"),
+					Title:         r.get("primaryTitle"),
+					OriginalTitle: r.get("originalTitle"),
+				}
+			)
+
+			if !movieTypes[t.Type] {
+				return nil
+			} else if t.Year, err = r.getInt("startYear"); err != nil {
+				return err
+			} else if err = db.IMDbTitleAdd(&t); err != nil {
+				return err
+			}
+
+			for _, key := range Keys(&t) {
+				if err = db.IMDbTitleKeyAdd(t.ID, key); err != nil {
+					return err
+				}
+			}
+
+			titles[t.ID] = true
+			stats.Titles++
+			return nil
+		})
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = readTSV(filepath.Join(dir, FilePrincipals),
+		[]string{"tconst", "ordering", "nconst", "category", "characters"},
+		progress,
+		func(r *row) error {
+			var (
+				err error
+				p   = objects.IMDbPrincipal{
+					TitleID:    r.get("tconst"),
+					NameID:     r.get("nconst"),
+					Category:   r.get("category"),
+					Characters: r.get("characters"),
+				}
+			)
+
+			if !titles[p.TitleID] {
+				return nil
+			} else if p.Ordering, err = r.getInt("ordering"); err != nil {
+				return err
+			} else if err = db.IMDbPrincipalAdd(&p); err != nil {
+				return err
+			}
+
+			people[p.NameID] = true
+			stats.Principals++
+			return nil
+		})
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = readTSV(filepath.Join(dir, FileNames),
+		[]string{"nconst", "primaryName", "birthYear"},
+		progress,
+		func(r *row) error {
+			var (
+				err error
+				n   = objects.IMDbName{
+					ID:   r.get("nconst"),
+					Name: r.get("primaryName"),
+				}
+			)
+
+			if !people[n.ID] {
+				return nil
+			} else if n.BirthYear, err = r.getInt("birthYear"); err != nil {
+				return err
+			} else if err = db.IMDbNameAdd(&n); err != nil {
+				return err
+			}
+
+			stats.Names++
+			return nil
+		})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = db.Commit(); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+} // func Load(db *database.Database, dir string, progress Progress) (*Stats, error)
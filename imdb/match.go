@@ -0,0 +1,320 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/imdb/match.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 15. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-15 22:03:56 krylon>
+
+package imdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/blicero/blockbuster/database"
+	"github.com/blicero/blockbuster/diary"
+	"github.com/blicero/blockbuster/nfo"
+	"github.com/blicero/blockbuster/objects"
+)
+
+// Unlike the watch history we import in package diary, we do not guess
+// here. A File matches a title if we have its IMDb ID, or if the normalized
+// titles are the same and there is only one such title from that year, or
+// the year before or after. If we do not know the year, there may only be
+// one title at all, though a movie beats a TV movie or a short.
+// That way, matching the same Files against the same datasets always gives
+// the same result, and when in doubt, we leave a File alone.
+
+// Match pairs a File with a title from the staging area. ByID is true if
+// we found the title by the IMDb link the File already had.
+type Match struct {
+	File  *objects.File
+	Title objects.IMDbTitle
+	ByID  bool
+}
+
+// NameURL returns the URL of a person's page on IMDb.
+func NameURL(id string) string {
+	return fmt.Sprintf("https://www.imdb.com/name/%s/", id)
+} // func NameURL(id string) string
+
+// roles maps the categories IMDb uses to our Roles. Anything not in here,
+// like "self" or "production_designer", becomes objects.RoleOther.
+var roles = map[string]objects.Role{
+	"actor":           objects.RoleActor,
+	"actress":         objects.RoleActor,
+	"director":        objects.RoleDirector,
+	"writer":          objects.RoleWriter,
+	"composer":        objects.RoleComposer,
+	"producer":        objects.RoleProducer,
+	"cinematographer": objects.RoleCinematographer,
+	"editor":          objects.RoleEditor,
+}
+
+// pick chooses the title for a File from the titles with the same
+// normalized title, or returns nil if the choice is not clear.
+func pick(titles []objects.IMDbTitle, year int64) *objects.IMDbTitle {
+	var filter = func(list []objects.IMDbTitle, ok func(t *objects.IMDbTitle) bool) []objects.IMDbTitle {
+		var res []objects.IMDbTitle
+
+		for idx := range list {
+			if ok(&list[idx]) {
+				res = append(res, list[idx])
+			}
+		}
+
+		return res
+	}
+
+	if year != 0 {
+		var exact = filter(titles, func(t *objects.IMDbTitle) bool { return t.Year == year })
+
+		if len(exact) == 0 {
+			exact = filter(titles, func(t *objects.IMDbTitle) bool {
+				return t.Year == year-1 || t.Year == year+1
+			})
+		}
+
+		titles = exact
+	}
+
+	if len(titles) > 1 {
+		titles = filter(titles, func(t *objects.IMDbTitle) bool { return t.Type == "movie" })
+	}
+
+	if len(titles) != 1 {
+		return nil
+	}
+
+	return &titles[0]
+} // func pick(titles []objects.IMDbTitle, year int64) *objects.IMDbTitle
+
+// FindMatches matches our Files against the staging area. Episodes of
+// Series are left out, the datasets we load do not have them.
+func FindMatches(db *database.Database) ([]Match, error) {
+	var (
+		err     error
+		cands   []diary.Candidate
+		matches []Match
+	)
+
+	if cands, err = diary.Candidates(db); err != nil {
+		return nil, err
+	}
+
+	for idx := range cands {
+		var (
+			t      *objects.IMDbTitle
+			titles []objects.IMDbTitle
+			c      = &cands[idx]
+		)
+
+		if c.File.EpisodeID != 0 {
+			continue
+		} else if c.IMDbID != "" {
+			if t, err = db.IMDbTitleGetByID(c.IMDbID); err != nil {
+				return nil, err
+			} else if t != nil {
+				matches = append(matches, Match{File: c.File, Title: *t, ByID: true})
+			}
+			continue
+		} else if titles, err = db.IMDbTitleGetByKey(diary.Normalize(c.Title)); err != nil {
+			return nil, err
+		} else if t = pick(titles, c.Year); t != nil {
+			matches = append(matches, Match{File: c.File, Title: *t})
+		}
+	}
+
+	return matches, nil
+} // func FindMatches(db *database.Database) ([]Match, error)
+
+// Result counts what Apply has added to the database.
+type Result struct {
+	Files   int
+	People  int
+	Credits int
+}
+
+// characters turns the JSON array IMDb uses for the parts an actor played
+// into something we can put in a Credit.
+func characters(raw string) string {
+	var list []string
+
+	if raw == "" || json.Unmarshal([]byte(raw), &list) != nil {
+		return ""
+	}
+
+	return strings.Join(list, " / ")
+} // func characters(raw string) string
+
+// Apply adds the cast and crew of the matched titles to the Files, along
+// with a link to the title on IMDb. People we do not know, yet, are added
+// with their year of birth, if IMDb knows it, and a link to their page on
+// IMDb. Like the rest of our data, People are identified by their name.
+// Files get the title and year from IMDb if they do not have them, yet.
+// Applying the same Matches twice does no harm.
+func Apply(db *database.Database, matches []Match) (*Result, error) {
+	var (
+		err    error
+		res    = new(Result)
+		people = make(map[string]*objects.Person)
+	)
+
+	if err = db.Begin(); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			db.Rollback() // nolint: errcheck,gosec
+		}
+	}()
+
+	for idx := range matches {
+		var (
+			links      []objects.Link
+			cast       []objects.CastMember
+			principals []objects.IMDbPrincipal
+			crew       = make(map[string]bool)
+			m          = &matches[idx]
+			f          = m.File
+			linked     bool
+		)
+
+		if links, err = db.FileURLGetByFile(f); err != nil {
+			return nil, err
+		} else if cast, err = db.CreditGetByFile(f); err != nil {
+			return nil, err
+		} else if principals, err = db.IMDbPrincipalGetByTitle(m.Title.ID); err != nil {
+			return nil, err
+		}
+
+		for _, l := range links {
+			linked = linked || l.URL.String() == nfo.IMDbURL(m.Title.ID)
+		}
+
+		for _, c := range cast {
+			crew[fmt.Sprintf("%d/%d", c.Person.ID, c.Credit.Role)] = true
+		}
+
+		if !linked {
+			var l = objects.Link{Title: "IMDb"}
+
+			if l.URL, err = url.Parse(nfo.IMDbURL(m.Title.ID)); err != nil {
+				return nil, err
+			} else if err = db.FileURLAdd(f, &l); err != nil {
+				return nil, err
+			}
+		}
+
+		if f.Title == "" {
+			if err = db.FileUpdateTitle(f, m.Title.Title); err != nil {
+				return nil, err
+			}
+		}
+
+		if f.Year == 0 && m.Title.Year != 0 {
+			if err = db.FileUpdateYear(f, m.Title.Year); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, pr := range principals {
+			var (
+				p         *objects.Person
+				key       string
+				ok        bool
+				billing   int64
+				character string
+				role      = roles[pr.Category]
+			)
+
+			if pr.Name == "" {
+				continue
+			} else if role == 0 {
+				role = objects.RoleOther
+			}
+
+			if p, ok = people[pr.NameID]; !ok {
+				if p, err = person(db, &pr, res); err != nil {
+					return nil, err
+				}
+				people[pr.NameID] = p
+			}
+
+			if key = fmt.Sprintf("%d/%d", p.ID, role); crew[key] {
+				continue
+			}
+
+			if role == objects.RoleActor {
+				billing = pr.Ordering
+				character = characters(pr.Characters)
+			}
+
+			if _, err = db.CreditAdd(f, p, role, character, billing); err != nil {
+				return nil, err
+			}
+
+			crew[key] = true
+			res.Credits++
+		}
+
+		res.Files++
+	}
+
+	if err = db.Commit(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+} // func Apply(db *database.Database, matches []Match) (*Result, error)
+
+// person looks up the Person for a principal, or adds them, and makes sure
+// they have a link to their page on IMDb.
+func person(db *database.Database, pr *objects.IMDbPrincipal, res *Result) (*objects.Person, error) {
+	var (
+		err   error
+		p     *objects.Person
+		links []objects.Link
+		u     = NameURL(pr.NameID)
+	)
+
+	if p, err = db.PersonGetByName(pr.Name); err != nil {
+		return nil, err
+	} else if p == nil {
+		var birthday time.Time
+
+		// IMDb only gives us the year.
+		if pr.BirthYear != 0 {
+			birthday = time.Date(int(pr.BirthYear), time.January, 1, 0, 0, 0, 0, time.Local)
+		}
+
+		if p, err = db.PersonAdd(pr.Name, birthday); err != nil {
+			return nil, err
+		}
+
+		res.People++
+	}
+
+	if links, err = db.PersonURLGetByPerson(p); err != nil {
+		return nil, err
+	}
+
+	for _, l := range links {
+		if l.URL.String() == u {
+			return p, nil
+		}
+	}
+
+	var l = objects.Link{Title: "IMDb"}
+
+	if l.URL, err = url.Parse(u); err != nil {
+		return nil, err
+	} else if err = db.PersonURLAdd(p, &l); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+} // func person(db *database.Database, pr *objects.IMDbPrincipal, res *Result) (*objects.Person, error)
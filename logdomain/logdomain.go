@@ -19,6 +19,7 @@ const (
 	Database
 	GUI
 	Scanner
+	Player
 )
 
 // AllDomains returns a slice of all the known log sources.
@@ -29,5 +30,6 @@ func AllDomains() []ID {
 		Database,
 		GUI,
 		Scanner,
+		Player,
 	}
 } // func AllDomains() []ID
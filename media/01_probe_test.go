@@ -0,0 +1,323 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/media/01_probe_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 02. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-02 23:52:36 krylon>
+
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/blicero/blockbuster/objects"
+)
+
+// We build our test files from scratch, they contain the headers and
+// nothing else.
+
+// ebml encodes an element with the given ID and payload. The size is always
+// encoded as 8 bytes, which is legal, if wasteful.
+func ebml(id uint64, payload ...[]byte) []byte {
+	var (
+		buf  bytes.Buffer
+		data = bytes.Join(payload, nil)
+		size = make([]byte, 8)
+	)
+
+	for shift := 24; shift >= 0; shift -= 8 {
+		if b := byte(id >> uint(shift)); b != 0 || buf.Len() > 0 {
+			buf.WriteByte(b)
+		}
+	}
+
+	binary.BigEndian.PutUint64(size, uint64(len(data)))
+	size[0] = 0x01
+	buf.Write(size)
+	buf.Write(data)
+
+	return buf.Bytes()
+} // func ebml(id uint64, payload ...[]byte) []byte
+
+func ebmlU(id, val uint64) []byte {
+	var b = make([]byte, 8)
+	binary.BigEndian.PutUint64(b, val)
+	return ebml(id, b)
+} // func ebmlU(id, val uint64) []byte
+
+func ebmlF(id uint64, val float64) []byte {
+	var b = make([]byte, 8)
+	binary.BigEndian.PutUint64(b, math.Float64bits(val))
+	return ebml(id, b)
+} // func ebmlF(id uint64, val float64) []byte
+
+func ebmlS(id uint64, val string) []byte {
+	return ebml(id, []byte(val))
+} // func ebmlS(id uint64, val string) []byte
+
+// box encodes an MP4 box of the given type and payload.
+func box(typ string, payload ...[]byte) []byte {
+	var (
+		data = bytes.Join(payload, nil)
+		buf  = make([]byte, 8, 8+len(data))
+	)
+
+	binary.BigEndian.PutUint32(buf, uint32(8+len(data)))
+	copy(buf[4:], typ)
+
+	return append(buf, data...)
+} // func box(typ string, payload ...[]byte) []byte
+
+// be encodes a list of numbers in big endian byte order, using as many
+// bytes for each as the type requires.
+func be(vals ...interface{}) []byte {
+	var buf bytes.Buffer
+
+	for _, v := range vals {
+		binary.Write(&buf, binary.BigEndian, v) // nolint: errcheck
+	}
+
+	return buf.Bytes()
+} // func be(vals ...interface{}) []byte
+
+func packLang(lang string) uint16 {
+	return uint16(lang[0]-0x60)<<10 | uint16(lang[1]-0x60)<<5 | uint16(lang[2]-0x60)
+} // func packLang(lang string) uint16
+
+func mkTrak(handler, codec, lang string, width, height uint32, sampleEntry []byte, timescale, duration, samples uint32) []byte {
+	return box("trak",
+		box("tkhd",
+			be(uint32(0)),    // version and flags
+			make([]byte, 20), // times, track ID, duration
+			make([]byte, 52), // reserved, layer, volume, matrix
+			be(width<<16, height<<16)),
+		box("mdia",
+			box("mdhd",
+				be(uint32(0), uint32(0), uint32(0), timescale, duration, packLang(lang), uint16(0))),
+			box("hdlr",
+				be(uint32(0), uint32(0)),
+				[]byte(handler),
+				make([]byte, 13)),
+			box("minf",
+				box("stbl",
+					box("stsd",
+						be(uint32(0), uint32(1)),
+						box(codec, sampleEntry)),
+					box("stts",
+						be(uint32(0), uint32(1), samples, duration/samples))))))
+} // func mkTrak(...) []byte
+
+func checkInfo(t *testing.T, mi, expected *objects.MediaInfo) {
+	if mi.Container != expected.Container {
+		t.Errorf("Unexpected container: %q (expected %q)",
+			mi.Container,
+			expected.Container)
+	}
+
+	if mi.Duration != expected.Duration {
+		t.Errorf("Unexpected duration: %s (expected %s)",
+			mi.Duration,
+			expected.Duration)
+	}
+
+	if mi.Resolution() != expected.Resolution() {
+		t.Errorf("Unexpected resolution: %s (expected %s)",
+			mi.Resolution(),
+			expected.Resolution())
+	}
+
+	if math.Abs(mi.FrameRate-expected.FrameRate) > 0.001 {
+		t.Errorf("Unexpected frame rate: %f (expected %f)",
+			mi.FrameRate,
+			expected.FrameRate)
+	}
+
+	if mi.VideoCodec != expected.VideoCodec {
+		t.Errorf("Unexpected video codec: %q (expected %q)",
+			mi.VideoCodec,
+			expected.VideoCodec)
+	}
+
+	if len(mi.Tracks) != len(expected.Tracks) {
+		t.Fatalf("Unexpected number of tracks: %d (expected %d)\n%#v",
+			len(mi.Tracks),
+			len(expected.Tracks),
+			mi.Tracks)
+	}
+
+	for i, track := range mi.Tracks {
+		if track != expected.Tracks[i] {
+			t.Errorf("Unexpected Track #%d:\n%#v\n(expected)\n%#v",
+				i,
+				track,
+				expected.Tracks[i])
+		}
+	}
+} // func checkInfo(t *testing.T, mi, expected *objects.MediaInfo)
+
+func TestProbeMatroska(t *testing.T) {
+	var (
+		err      error
+		mi       *objects.MediaInfo
+		header   = ebml(ebmlIDHeader, ebmlS(ebmlIDDocType, "webm"))
+		contents = [][]byte{
+			ebml(ebmlIDInfo,
+				ebmlU(ebmlIDTimecodeScale, 1000000),
+				ebmlF(ebmlIDDuration, 5400500)),
+			ebml(ebmlIDTracks,
+				ebml(ebmlIDTrackEntry,
+					ebmlU(ebmlIDTrackType, ebmlTrackVideo),
+					ebmlS(ebmlIDCodecID, "V_VP9"),
+					ebmlU(ebmlIDDefaultDuration, 41708333),
+					ebml(ebmlIDVideo,
+						ebmlU(ebmlIDPixelWidth, 1920),
+						ebmlU(ebmlIDPixelHeight, 1080))),
+				ebml(ebmlIDTrackEntry,
+					ebmlU(ebmlIDTrackType, ebmlTrackAudio),
+					ebmlS(ebmlIDCodecID, "A_OPUS"),
+					ebmlS(ebmlIDLanguage, "ger"),
+					ebml(ebmlIDAudio,
+						ebmlU(ebmlIDChannels, 6))),
+				ebml(ebmlIDTrackEntry,
+					ebmlU(ebmlIDTrackType, ebmlTrackAudio),
+					ebmlS(ebmlIDCodecID, "A_SOMETHING"),
+					ebmlS(ebmlIDName, "Commentary"),
+					ebml(ebmlIDAudio,
+						ebmlU(ebmlIDChannels, 2))),
+				ebml(ebmlIDTrackEntry,
+					ebmlU(ebmlIDTrackType, ebmlTrackSubtitle),
+					ebmlS(ebmlIDCodecID, "S_TEXT/UTF8"),
+					ebmlS(ebmlIDLanguage, "ger"),
+					ebmlS(ebmlIDLanguageBCP47, "de-CH"))),
+			ebml(ebmlIDCluster, make([]byte, 1024)),
+		}
+		expected = &objects.MediaInfo{
+			Container:  "webm",
+			Duration:   time.Millisecond * 5400500,
+			Width:      1920,
+			Height:     1080,
+			FrameRate:  23.976,
+			VideoCodec: "vp9",
+			Tracks: []objects.Track{
+				{Kind: objects.TrackAudio, Codec: "opus", Language: "ger", Channels: 6},
+				{Kind: objects.TrackAudio, Codec: "A_SOMETHING", Language: "eng", Name: "Commentary", Channels: 2},
+				{Kind: objects.TrackSubtitle, Codec: "subrip", Language: "de-CH"},
+			},
+		}
+	)
+
+	var file = append(header, ebml(ebmlIDSegment, contents...)...)
+
+	if mi, err = probe(bytes.NewReader(file), int64(len(file))); err != nil {
+		t.Fatalf("Cannot probe Matroska file: %s", err.Error())
+	}
+
+	checkInfo(t, mi, expected)
+
+	// Segments written by live encoders have an unknown size.
+	var segment = ebml(ebmlIDSegment, contents...)
+	copy(segment[4:], []byte{0x01, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	file = append(header, segment...)
+
+	if mi, err = probe(bytes.NewReader(file), int64(len(file))); err != nil {
+		t.Fatalf("Cannot probe Matroska file with unknown Segment size: %s",
+			err.Error())
+	}
+
+	checkInfo(t, mi, expected)
+} // func TestProbeMatroska(t *testing.T)
+
+func TestProbeMP4(t *testing.T) {
+	var (
+		err       error
+		mi        *objects.MediaInfo
+		audioDesc = bytes.Join([][]byte{
+			make([]byte, 8),  // reserved, data reference index
+			make([]byte, 8),  // reserved
+			be(uint16(2)),    // channel count
+			make([]byte, 10), // sample size, reserved, sample rate
+		}, nil)
+		videoDesc = bytes.Join([][]byte{
+			make([]byte, 8),
+			make([]byte, 16),
+			be(uint16(1280), uint16(720)),
+			make([]byte, 50),
+		}, nil)
+		moov = box("moov",
+			box("mvhd",
+				be(uint32(0), uint32(0), uint32(0), uint32(1000), uint32(5400500)),
+				make([]byte, 80)),
+			mkTrak("vide", "avc1", "und", 1280, 720, videoDesc, 24000, 1001*129600, 129600),
+			mkTrak("soun", "mp4a", "deu", 0, 0, audioDesc, 48000, 48000*5400, 253125),
+			mkTrak("sbtl", "tx3g", "eng", 0, 0, make([]byte, 8), 1000, 5400000, 1000))
+		mdat     = box("mdat", make([]byte, 4096))
+		expected = &objects.MediaInfo{
+			Container:  "mp4",
+			Duration:   time.Millisecond * 5400500,
+			Width:      1280,
+			Height:     720,
+			FrameRate:  24000.0 / 1001.0,
+			VideoCodec: "h264",
+			Tracks: []objects.Track{
+				{Kind: objects.TrackAudio, Codec: "aac", Language: "deu", Channels: 2},
+				{Kind: objects.TrackSubtitle, Codec: "mov_text", Language: "eng"},
+			},
+		}
+	)
+
+	// The moov box may come before or after the mdat box, we should find
+	// it either way.
+	for _, file := range [][]byte{
+		bytes.Join([][]byte{box("ftyp", []byte("isom"), be(uint32(512)), []byte("isomavc1")), moov, mdat}, nil),
+		bytes.Join([][]byte{box("ftyp", []byte("mp42"), be(uint32(0))), mdat, moov}, nil),
+	} {
+		if mi, err = probe(bytes.NewReader(file), int64(len(file))); err != nil {
+			t.Fatalf("Cannot probe MP4 file: %s", err.Error())
+		}
+
+		checkInfo(t, mi, expected)
+	}
+
+	// QuickTime files have a different brand.
+	var file = bytes.Join([][]byte{box("ftyp", []byte("qt  "), be(uint32(0))), moov, mdat}, nil)
+	expected.Container = "mov"
+
+	if mi, err = probe(bytes.NewReader(file), int64(len(file))); err != nil {
+		t.Fatalf("Cannot probe QuickTime file: %s", err.Error())
+	}
+
+	checkInfo(t, mi, expected)
+} // func TestProbeMP4(t *testing.T)
+
+func TestProbeUnsupported(t *testing.T) {
+	var files = [][]byte{
+		[]byte("RIFF\x00\x00\x00\x00AVI LIST"),
+		[]byte("short"),
+		{},
+	}
+
+	for _, file := range files {
+		if _, err := probe(bytes.NewReader(file), int64(len(file))); err != ErrUnsupported {
+			t.Errorf("Unexpected result for probing %q: %v (expected %s)",
+				file,
+				err,
+				ErrUnsupported)
+		}
+	}
+} // func TestProbeUnsupported(t *testing.T)
+
+func TestProbeTruncated(t *testing.T) {
+	var file = ebml(ebmlIDHeader, ebmlS(ebmlIDDocType, "matroska"))
+	file = append(file, ebml(ebmlIDSegment,
+		ebml(ebmlIDTracks,
+			ebml(ebmlIDTrackEntry,
+				ebmlU(ebmlIDTrackType, ebmlTrackVideo))))...)
+	file = file[:len(file)-4]
+
+	if _, err := probe(bytes.NewReader(file), int64(len(file))); err == nil {
+		t.Errorf("Probing a truncated file did not return an error")
+	}
+} // func TestProbeTruncated(t *testing.T)
@@ -0,0 +1,378 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/media/bmff.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 02. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-02 23:16:02 krylon>
+
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/blicero/blockbuster/objects"
+)
+
+// MP4 and QuickTime files are made of boxes (QuickTime calls them atoms).
+// Each box starts with its size (32 bits, big endian) and a four-character
+// type. A size of 1 means the real size follows as a 64 bit number, a size
+// of 0 means the box extends to the end of the file.
+// Everything we want to know lives in the moov box, which is either at the
+// beginning or at the end of the file. The sample data in the mdat box can
+// be skipped entirely.
+//
+// See ISO/IEC 14496-12 and Apple's QuickTime File Format Specification.
+
+// bmffTopLevel lists the box types we expect at the top level of a file, so
+// we can recognize one by looking at its first box.
+var bmffTopLevel = map[string]bool{
+	"ftyp": true,
+	"moov": true,
+	"mdat": true,
+	"free": true,
+	"skip": true,
+	"wide": true,
+	"pnot": true,
+}
+
+func isBoxType(typ []byte) bool {
+	return bmffTopLevel[string(typ)]
+} // func isBoxType(typ []byte) bool
+
+// bmffBox is a box we have read into memory completely.
+type bmffBox struct {
+	typ  string
+	data []byte
+}
+
+// bmffChildren splits the payload of a container box into its children.
+func bmffChildren(data []byte) ([]bmffBox, error) {
+	var boxes = make([]bmffBox, 0, 8)
+
+	for len(data) >= 8 {
+		var (
+			size          = uint64(binary.BigEndian.Uint32(data))
+			typ           = string(data[4:8])
+			hdrLen uint64 = 8
+		)
+
+		switch size {
+		case 0:
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return nil, errTruncated
+			}
+			size = binary.BigEndian.Uint64(data[8:])
+			hdrLen = 16
+		}
+
+		if size < hdrLen || size > uint64(len(data)) {
+			return nil, errTruncated
+		}
+
+		boxes = append(boxes, bmffBox{
+			typ:  typ,
+			data: data[hdrLen:size],
+		})
+		data = data[size:]
+	}
+
+	return boxes, nil
+} // func bmffChildren(data []byte) ([]bmffBox, error)
+
+// bmffFind returns the first child box of the given type, or nil.
+func bmffFind(data []byte, typ string) (*bmffBox, error) {
+	var (
+		err   error
+		boxes []bmffBox
+	)
+
+	if boxes, err = bmffChildren(data); err != nil {
+		return nil, err
+	}
+
+	for idx := range boxes {
+		if boxes[idx].typ == typ {
+			return &boxes[idx], nil
+		}
+	}
+
+	return nil, nil
+} // func bmffFind(data []byte, typ string) (*bmffBox, error)
+
+// bmffPath follows a path of nested container boxes, e.g. mdia, minf, stbl,
+// and returns the last one, or nil if any of them is missing.
+func bmffPath(data []byte, path ...string) (*bmffBox, error) {
+	var (
+		err error
+		box = &bmffBox{data: data}
+	)
+
+	for _, typ := range path {
+		if box, err = bmffFind(box.data, typ); err != nil || box == nil {
+			return nil, err
+		}
+	}
+
+	return box, nil
+} // func bmffPath(data []byte, path ...string) (*bmffBox, error)
+
+func probeBMFF(r io.ReadSeeker, fileSize int64) (*objects.MediaInfo, error) {
+	var (
+		err    error
+		hdr    []byte
+		moov   []byte
+		offset int64
+		mi     = &objects.MediaInfo{Container: "mov"}
+	)
+
+	for offset+8 <= fileSize && moov == nil {
+		var (
+			size   int64
+			hdrLen int64 = 8
+			typ    string
+		)
+
+		if hdr, err = readAt(r, offset, 8); err != nil {
+			return nil, err
+		}
+
+		size = int64(binary.BigEndian.Uint32(hdr))
+		typ = string(hdr[4:])
+
+		switch size {
+		case 0:
+			size = fileSize - offset
+		case 1:
+			if hdr, err = readAt(r, offset+8, 8); err != nil {
+				return nil, err
+			}
+			size = int64(binary.BigEndian.Uint64(hdr))
+			hdrLen = 16
+		}
+
+		if size < hdrLen {
+			return nil, errTruncated
+		}
+
+		switch typ {
+		case "ftyp":
+			var brand []byte
+			if brand, err = readAt(r, offset+hdrLen, 4); err != nil {
+				return nil, err
+			} else if string(brand) != "qt  " {
+				mi.Container = "mp4"
+			}
+		case "moov":
+			if moov, err = readAt(r, offset+hdrLen, size-hdrLen); err != nil {
+				return nil, err
+			}
+		}
+
+		offset += size
+	}
+
+	if moov == nil {
+		return nil, errors.New("no moov box found")
+	} else if err = bmffParseMoov(mi, moov); err != nil {
+		return nil, err
+	}
+
+	return mi, nil
+} // func probeBMFF(r io.ReadSeeker, fileSize int64) (*objects.MediaInfo, error)
+
+func bmffParseMoov(mi *objects.MediaInfo, moov []byte) error {
+	var (
+		err      error
+		boxes    []bmffBox
+		gotVideo bool
+	)
+
+	if boxes, err = bmffChildren(moov); err != nil {
+		return err
+	}
+
+	for _, box := range boxes {
+		switch box.typ {
+		case "mvhd":
+			var timescale, duration uint64
+			if timescale, duration, err = bmffTimes(box.data); err != nil {
+				return err
+			} else if timescale != 0 {
+				mi.Duration = time.Duration(float64(duration) / float64(timescale) * float64(time.Second))
+			}
+		case "trak":
+			var t *bmffTrack
+			if t, err = bmffParseTrak(box.data); err != nil {
+				return err
+			}
+
+			switch t.handler {
+			case "vide":
+				if gotVideo {
+					continue
+				}
+
+				gotVideo = true
+				mi.VideoCodec = codecName(t.codec)
+				mi.Width = t.width
+				mi.Height = t.height
+				if t.duration != 0 {
+					mi.FrameRate = float64(t.samples) * float64(t.timescale) / float64(t.duration)
+				}
+			case "soun":
+				mi.Tracks = append(mi.Tracks, objects.Track{
+					Kind:     objects.TrackAudio,
+					Codec:    codecName(t.codec),
+					Language: t.language,
+					Channels: t.channels,
+				})
+			case "sbtl", "subt", "text", "clcp":
+				mi.Tracks = append(mi.Tracks, objects.Track{
+					Kind:     objects.TrackSubtitle,
+					Codec:    codecName(t.codec),
+					Language: t.language,
+				})
+			}
+		}
+	}
+
+	return nil
+} // func bmffParseMoov(mi *objects.MediaInfo, moov []byte) error
+
+// bmffTimes extracts timescale and duration from a mvhd or mdhd box, which
+// share the same layout up to that point.
+func bmffTimes(data []byte) (uint64, uint64, error) {
+	if len(data) < 1 {
+		return 0, 0, errTruncated
+	} else if data[0] == 1 {
+		if len(data) < 32 {
+			return 0, 0, errTruncated
+		}
+		return uint64(binary.BigEndian.Uint32(data[20:])), binary.BigEndian.Uint64(data[24:]), nil
+	} else if len(data) < 20 {
+		return 0, 0, errTruncated
+	}
+
+	return uint64(binary.BigEndian.Uint32(data[12:])), uint64(binary.BigEndian.Uint32(data[16:])), nil
+} // func bmffTimes(data []byte) (uint64, uint64, error)
+
+type bmffTrack struct {
+	handler   string
+	codec     string
+	language  string
+	width     int64
+	height    int64
+	channels  int64
+	timescale uint64
+	duration  uint64
+	samples   uint64
+}
+
+func bmffParseTrak(data []byte) (*bmffTrack, error) {
+	var (
+		err error
+		box *bmffBox
+		t   = new(bmffTrack)
+	)
+
+	// Track header. Width and height are 16.16 fixed point numbers at the
+	// very end.
+	if box, err = bmffFind(data, "tkhd"); err != nil {
+		return nil, err
+	} else if box != nil && len(box.data) >= 84 {
+		var off = 76
+		if box.data[0] == 1 {
+			off = 88
+		}
+
+		if len(box.data) >= off+8 {
+			t.width = int64(binary.BigEndian.Uint32(box.data[off:]) >> 16)
+			t.height = int64(binary.BigEndian.Uint32(box.data[off+4:]) >> 16)
+		}
+	}
+
+	// Media header: timescale, duration, and language.
+	if box, err = bmffPath(data, "mdia", "mdhd"); err != nil {
+		return nil, err
+	} else if box != nil {
+		var langOff = 20
+
+		if t.timescale, t.duration, err = bmffTimes(box.data); err != nil {
+			return nil, err
+		} else if box.data[0] == 1 {
+			langOff = 32
+		}
+
+		if len(box.data) >= langOff+2 {
+			t.language = bmffLanguage(binary.BigEndian.Uint16(box.data[langOff:]))
+		}
+	}
+
+	// Handler type tells us what kind of track this is.
+	if box, err = bmffPath(data, "mdia", "hdlr"); err != nil {
+		return nil, err
+	} else if box != nil && len(box.data) >= 12 {
+		t.handler = string(box.data[8:12])
+	}
+
+	// The sample description tells us the codec. For audio, it also holds
+	// the number of channels, for video, the size of the picture.
+	if box, err = bmffPath(data, "mdia", "minf", "stbl", "stsd"); err != nil {
+		return nil, err
+	} else if box != nil && len(box.data) >= 16 {
+		var entry = box.data[8:]
+		t.codec = string(entry[4:8])
+
+		switch t.handler {
+		case "soun":
+			if len(entry) >= 26 {
+				t.channels = int64(binary.BigEndian.Uint16(entry[24:]))
+			}
+		case "vide":
+			if t.width == 0 && len(entry) >= 36 {
+				t.width = int64(binary.BigEndian.Uint16(entry[32:]))
+				t.height = int64(binary.BigEndian.Uint16(entry[34:]))
+			}
+		}
+	}
+
+	// The number of samples, which, together with the duration, gives us
+	// the frame rate.
+	if box, err = bmffPath(data, "mdia", "minf", "stbl", "stts"); err != nil {
+		return nil, err
+	} else if box != nil && len(box.data) >= 8 {
+		var cnt = int(binary.BigEndian.Uint32(box.data[4:]))
+
+		for i := 0; i < cnt && len(box.data) >= 8+(i+1)*8; i++ {
+			t.samples += uint64(binary.BigEndian.Uint32(box.data[8+i*8:]))
+		}
+	}
+
+	return t, nil
+} // func bmffParseTrak(data []byte) (*bmffTrack, error)
+
+// bmffLanguage decodes an ISO 639-2/T language code packed into 15 bits.
+// Undetermined languages are returned as an empty string.
+func bmffLanguage(packed uint16) string {
+	var lang = []byte{
+		byte(packed>>10&0x1f) + 0x60,
+		byte(packed>>5&0x1f) + 0x60,
+		byte(packed&0x1f) + 0x60,
+	}
+
+	for _, c := range lang {
+		if c < 'a' || c > 'z' {
+			return ""
+		}
+	}
+
+	if string(lang) == "und" {
+		return ""
+	}
+
+	return string(lang)
+} // func bmffLanguage(packed uint16) string
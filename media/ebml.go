@@ -0,0 +1,422 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/media/ebml.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 02. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-02 22:04:58 krylon>
+
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"strings"
+	"time"
+
+	"github.com/blicero/blockbuster/objects"
+)
+
+// Matroska and WebM files are made of EBML elements. Each element starts
+// with an ID and a size, both encoded as variable length integers, followed
+// by its payload. Master elements contain other elements.
+// Fortunately, the information we are after lives in the Info and Tracks
+// elements, which are small and usually come before the first Cluster, so
+// we only need to read a couple of kilobytes, usually.
+//
+// See https://www.matroska.org/technical/elements.html
+
+// The element IDs we care about.
+const (
+	ebmlIDHeader          = 0x1a45dfa3
+	ebmlIDDocType         = 0x4282
+	ebmlIDSegment         = 0x18538067
+	ebmlIDInfo            = 0x1549a966
+	ebmlIDTimecodeScale   = 0x2ad7b1
+	ebmlIDDuration        = 0x4489
+	ebmlIDTracks          = 0x1654ae6b
+	ebmlIDTrackEntry      = 0xae
+	ebmlIDTrackType       = 0x83
+	ebmlIDCodecID         = 0x86
+	ebmlIDName            = 0x536e
+	ebmlIDLanguage        = 0x22b59c
+	ebmlIDLanguageBCP47   = 0x22b59d
+	ebmlIDDefaultDuration = 0x23e383
+	ebmlIDVideo           = 0xe0
+	ebmlIDPixelWidth      = 0xb0
+	ebmlIDPixelHeight     = 0xba
+	ebmlIDAudio           = 0xe1
+	ebmlIDChannels        = 0x9f
+	ebmlIDCluster         = 0x1f43b675
+)
+
+// Matroska track types
+const (
+	ebmlTrackVideo    = 1
+	ebmlTrackAudio    = 2
+	ebmlTrackSubtitle = 0x11
+)
+
+// ebmlDefaultTimecodeScale is the default value of TimecodeScale, in
+// nanoseconds.
+const ebmlDefaultTimecodeScale = 1000000
+
+// ebmlUnknownSize is what we return as an element's size if it is not known,
+// which is allowed for the Segment and Clusters, so files can be written
+// sequentially.
+const ebmlUnknownSize = -1
+
+var errInvalidVint = errors.New("invalid variable length integer")
+
+// ebmlVint decodes a variable length integer at the beginning of buf.
+// It returns the value and the number of bytes it occupied. If keepMarker is
+// true, the length marker bit is kept, that is how element IDs are written
+// down.
+func ebmlVint(buf []byte, keepMarker bool) (uint64, int, error) {
+	if len(buf) == 0 {
+		return 0, 0, errTruncated
+	} else if buf[0] == 0 {
+		return 0, 0, errInvalidVint
+	}
+
+	var (
+		length = bits.LeadingZeros8(buf[0]) + 1
+		val    uint64
+	)
+
+	if len(buf) < length {
+		return 0, 0, errTruncated
+	} else if keepMarker {
+		val = uint64(buf[0])
+	} else {
+		val = uint64(buf[0] & (0xff >> length))
+	}
+
+	for _, b := range buf[1:length] {
+		val = val<<8 | uint64(b)
+	}
+
+	return val, length, nil
+} // func ebmlVint(buf []byte, keepMarker bool) (uint64, int, error)
+
+// ebmlHeader decodes the ID and size of an element at the beginning of buf.
+// It returns the ID, the size of the payload, and the length of the header.
+func ebmlHeader(buf []byte) (uint64, int64, int, error) {
+	var (
+		err            error
+		id, size       uint64
+		idLen, sizeLen int
+	)
+
+	if id, idLen, err = ebmlVint(buf, true); err != nil {
+		return 0, 0, 0, err
+	} else if size, sizeLen, err = ebmlVint(buf[idLen:], false); err != nil {
+		return 0, 0, 0, err
+	} else if size == 1<<(7*uint(sizeLen))-1 {
+		// All bits set means the size is unknown.
+		return id, ebmlUnknownSize, idLen + sizeLen, nil
+	} else if size > math.MaxInt64 {
+		return 0, 0, 0, errInvalidVint
+	}
+
+	return id, int64(size), idLen + sizeLen, nil
+} // func ebmlHeader(buf []byte) (uint64, int64, int, error)
+
+// ebmlElement is an element we have read into memory completely.
+type ebmlElement struct {
+	id   uint64
+	data []byte
+}
+
+// ebmlChildren splits the payload of a master element into its children.
+func ebmlChildren(data []byte) ([]ebmlElement, error) {
+	var elements = make([]ebmlElement, 0, 8)
+
+	for len(data) > 0 {
+		var (
+			err    error
+			id     uint64
+			size   int64
+			hdrLen int
+		)
+
+		if id, size, hdrLen, err = ebmlHeader(data); err != nil {
+			return nil, err
+		} else if size == ebmlUnknownSize || size > int64(len(data)-hdrLen) {
+			return nil, errTruncated
+		}
+
+		elements = append(elements, ebmlElement{
+			id:   id,
+			data: data[hdrLen : hdrLen+int(size)],
+		})
+		data = data[hdrLen+int(size):]
+	}
+
+	return elements, nil
+} // func ebmlChildren(data []byte) ([]ebmlElement, error)
+
+func (e *ebmlElement) uint() uint64 {
+	var val uint64
+
+	for _, b := range e.data {
+		val = val<<8 | uint64(b)
+	}
+
+	return val
+} // func (e *ebmlElement) uint() uint64
+
+func (e *ebmlElement) float() float64 {
+	switch len(e.data) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(e.data)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(e.data))
+	default:
+		return 0
+	}
+} // func (e *ebmlElement) float() float64
+
+func (e *ebmlElement) string() string {
+	return strings.TrimRight(string(e.data), "\x00")
+} // func (e *ebmlElement) string() string
+
+// ebmlReadHeader reads the header of the element starting at offset.
+func ebmlReadHeader(r io.ReadSeeker, offset, fileSize int64) (uint64, int64, int, error) {
+	var (
+		err    error
+		buf    []byte
+		bufLen int64 = 12 // 4 bytes of ID plus 8 bytes of size, at most
+	)
+
+	if fileSize-offset < bufLen {
+		bufLen = fileSize - offset
+	}
+
+	if buf, err = readAt(r, offset, bufLen); err != nil {
+		return 0, 0, 0, err
+	}
+
+	return ebmlHeader(buf)
+} // func ebmlReadHeader(r io.ReadSeeker, offset, fileSize int64) (uint64, int64, int, error)
+
+func probeEBML(r io.ReadSeeker, fileSize int64) (*objects.MediaInfo, error) {
+	var (
+		err            error
+		id             uint64
+		size           int64
+		hdrLen         int
+		data           []byte
+		children       []ebmlElement
+		offset, end    int64
+		haveInfo       bool
+		haveTracks     bool
+		duration       float64
+		timecodeScale  uint64 = ebmlDefaultTimecodeScale
+		mi                    = &objects.MediaInfo{Container: "matroska"}
+		errNotMatroska        = errors.New("not a Matroska file")
+	)
+
+	// First comes the EBML header, which tells us the DocType.
+	if id, size, hdrLen, err = ebmlReadHeader(r, 0, fileSize); err != nil {
+		return nil, err
+	} else if id != ebmlIDHeader || size == ebmlUnknownSize {
+		return nil, errNotMatroska
+	} else if data, err = readAt(r, int64(hdrLen), size); err != nil {
+		return nil, err
+	} else if children, err = ebmlChildren(data); err != nil {
+		return nil, err
+	}
+
+	for _, c := range children {
+		if c.id == ebmlIDDocType {
+			mi.Container = c.string()
+		}
+	}
+
+	// Then, the Segment, which contains everything else.
+	offset = int64(hdrLen) + size
+
+	if id, size, hdrLen, err = ebmlReadHeader(r, offset, fileSize); err != nil {
+		return nil, err
+	} else if id != ebmlIDSegment {
+		return nil, fmt.Errorf("expected Segment, found element %x", id)
+	}
+
+	offset += int64(hdrLen)
+
+	if end = offset + size; size == ebmlUnknownSize || end > fileSize {
+		end = fileSize
+	}
+
+	for offset < end && !(haveInfo && haveTracks) {
+		if id, size, hdrLen, err = ebmlReadHeader(r, offset, fileSize); err != nil {
+			return nil, err
+		} else if size == ebmlUnknownSize {
+			// Only a Cluster may have an unknown size inside the
+			// Segment, and there is no telling where it ends without
+			// parsing it, so this is as far as we go.
+			break
+		}
+
+		switch id {
+		case ebmlIDInfo:
+			if data, err = readAt(r, offset+int64(hdrLen), size); err != nil {
+				return nil, err
+			} else if children, err = ebmlChildren(data); err != nil {
+				return nil, err
+			}
+
+			for _, c := range children {
+				switch c.id {
+				case ebmlIDTimecodeScale:
+					timecodeScale = c.uint()
+				case ebmlIDDuration:
+					duration = c.float()
+				}
+			}
+
+			haveInfo = true
+		case ebmlIDTracks:
+			if data, err = readAt(r, offset+int64(hdrLen), size); err != nil {
+				return nil, err
+			} else if err = ebmlParseTracks(mi, data); err != nil {
+				return nil, err
+			}
+
+			haveTracks = true
+		}
+
+		offset += int64(hdrLen) + size
+	}
+
+	if !haveTracks {
+		return nil, errors.New("no Tracks element found")
+	}
+
+	mi.Duration = time.Duration(duration * float64(timecodeScale))
+
+	return mi, nil
+} // func probeEBML(r io.ReadSeeker, fileSize int64) (*objects.MediaInfo, error)
+
+// ebmlParseTracks fills in the video properties of the first video track and
+// the list of audio and subtitle tracks from the payload of a Tracks element.
+func ebmlParseTracks(mi *objects.MediaInfo, data []byte) error {
+	var (
+		err      error
+		entries  []ebmlElement
+		gotVideo bool
+	)
+
+	if entries, err = ebmlChildren(data); err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.id != ebmlIDTrackEntry {
+			continue
+		}
+
+		var (
+			children   []ebmlElement
+			trackType  uint64
+			codec      string
+			name       string
+			lang       = "eng" // The default according to the spec
+			bcp47      string
+			frameDur   uint64
+			width      uint64
+			height     uint64
+			channels   uint64
+			videoProps []ebmlElement
+			audioProps []ebmlElement
+		)
+
+		if children, err = ebmlChildren(entry.data); err != nil {
+			return err
+		}
+
+		for _, c := range children {
+			switch c.id {
+			case ebmlIDTrackType:
+				trackType = c.uint()
+			case ebmlIDCodecID:
+				codec = codecName(c.string())
+			case ebmlIDName:
+				name = c.string()
+			case ebmlIDLanguage:
+				lang = c.string()
+			case ebmlIDLanguageBCP47:
+				bcp47 = c.string()
+			case ebmlIDDefaultDuration:
+				frameDur = c.uint()
+			case ebmlIDVideo:
+				if videoProps, err = ebmlChildren(c.data); err != nil {
+					return err
+				}
+			case ebmlIDAudio:
+				if audioProps, err = ebmlChildren(c.data); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, p := range videoProps {
+			switch p.id {
+			case ebmlIDPixelWidth:
+				width = p.uint()
+			case ebmlIDPixelHeight:
+				height = p.uint()
+			}
+		}
+
+		for _, p := range audioProps {
+			if p.id == ebmlIDChannels {
+				channels = p.uint()
+			}
+		}
+
+		// If both are present, LanguageBCP47 takes precedence.
+		if bcp47 != "" {
+			lang = bcp47
+		}
+
+		if lang == "und" {
+			lang = ""
+		}
+
+		switch trackType {
+		case ebmlTrackVideo:
+			if gotVideo {
+				continue
+			}
+
+			gotVideo = true
+			mi.VideoCodec = codec
+			mi.Width = int64(width)
+			mi.Height = int64(height)
+			if frameDur != 0 {
+				mi.FrameRate = float64(time.Second) / float64(frameDur)
+			}
+		case ebmlTrackAudio:
+			mi.Tracks = append(mi.Tracks, objects.Track{
+				Kind:     objects.TrackAudio,
+				Codec:    codec,
+				Language: lang,
+				Name:     name,
+				Channels: int64(channels),
+			})
+		case ebmlTrackSubtitle:
+			mi.Tracks = append(mi.Tracks, objects.Track{
+				Kind:     objects.TrackSubtitle,
+				Codec:    codec,
+				Language: lang,
+				Name:     name,
+			})
+		}
+	}
+
+	return nil
+} // func ebmlParseTracks(mi *objects.MediaInfo, data []byte) error
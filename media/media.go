@@ -0,0 +1,170 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/media/media.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 02. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-02 21:13:40 krylon>
+
+// Package media extracts information on duration, resolution, codecs and so
+// on from video files.
+// It only reads the container headers and does not decode anything, so it
+// does not need ffprobe or any other external tool. Matroska/WebM and
+// MP4/QuickTime are supported, which covers most of what I have.
+package media
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	"github.com/blicero/blockbuster/objects"
+)
+
+// ErrUnsupported indicates that a file's container format is not one we
+// know how to parse.
+var ErrUnsupported = errors.New("unsupported container format")
+
+// errTruncated indicates that a file (or a part of it) ended prematurely.
+var errTruncated = errors.New("file is truncated")
+
+// maxHeaderSize is the largest header element or box we are willing to read
+// into memory. The moov box of a long movie can grow to a couple of
+// megabytes, but anything beyond this is probably garbage.
+const maxHeaderSize = 64 * 1024 * 1024
+
+// codecNames maps the codec identifiers used by Matroska and MP4 to
+// friendlier names. Identifiers not listed here are used as they are.
+var codecNames = map[string]string{
+	// Matroska
+	"V_MPEG4/ISO/AVC":  "h264",
+	"V_MPEGH/ISO/HEVC": "hevc",
+	"V_MPEG4/ISO/ASP":  "mpeg4",
+	"V_MPEG2":          "mpeg2",
+	"V_VP8":            "vp8",
+	"V_VP9":            "vp9",
+	"V_AV1":            "av1",
+	"A_AAC":            "aac",
+	"A_AC3":            "ac3",
+	"A_EAC3":           "eac3",
+	"A_DTS":            "dts",
+	"A_FLAC":           "flac",
+	"A_MPEG/L3":        "mp3",
+	"A_OPUS":           "opus",
+	"A_VORBIS":         "vorbis",
+	"A_TRUEHD":         "truehd",
+	"S_TEXT/UTF8":      "subrip",
+	"S_TEXT/ASS":       "ass",
+	"S_TEXT/SSA":       "ssa",
+	"S_TEXT/WEBVTT":    "webvtt",
+	"S_HDMV/PGS":       "pgs",
+	"S_VOBSUB":         "vobsub",
+	// MP4
+	"avc1": "h264",
+	"avc3": "h264",
+	"hvc1": "hevc",
+	"hev1": "hevc",
+	"mp4v": "mpeg4",
+	"vp09": "vp9",
+	"av01": "av1",
+	"mp4a": "aac",
+	"ac-3": "ac3",
+	"ec-3": "eac3",
+	"Opus": "opus",
+	"fLaC": "flac",
+	".mp3": "mp3",
+	"tx3g": "mov_text",
+	"wvtt": "webvtt",
+	"c608": "eia_608",
+}
+
+func codecName(id string) string {
+	if name, ok := codecNames[id]; ok {
+		return name
+	}
+
+	return id
+} // func codecName(id string) string
+
+// Probe reads the container headers of the video file at path.
+// If the container format is not supported, the error is ErrUnsupported.
+func Probe(path string) (*objects.MediaInfo, error) {
+	var (
+		err  error
+		fh   *os.File
+		info os.FileInfo
+	)
+
+	if fh, err = os.Open(path); err != nil {
+		return nil, err
+	}
+
+	defer fh.Close() // nolint: errcheck
+
+	if info, err = fh.Stat(); err != nil {
+		return nil, err
+	}
+
+	return probe(fh, info.Size())
+} // func Probe(path string) (*objects.MediaInfo, error)
+
+// probe looks at the first few bytes of the data to determine the
+// container format and hands the rest of the work to the appropriate
+// parser.
+func probe(r io.ReadSeeker, size int64) (*objects.MediaInfo, error) {
+	var (
+		err  error
+		mi   *objects.MediaInfo
+		head = make([]byte, 8)
+	)
+
+	if _, err = io.ReadFull(r, head); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrUnsupported
+		}
+		return nil, err
+	} else if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case bytes.Equal(head[:4], []byte{0x1a, 0x45, 0xdf, 0xa3}):
+		mi, err = probeEBML(r, size)
+	case isBoxType(head[4:]):
+		mi, err = probeBMFF(r, size)
+	default:
+		return nil, ErrUnsupported
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	mi.Probed = time.Now()
+	return mi, nil
+} // func probe(r io.ReadSeeker, size int64) (*objects.MediaInfo, error)
+
+// readAt reads size bytes from r, starting at offset.
+func readAt(r io.ReadSeeker, offset, size int64) ([]byte, error) {
+	var (
+		err error
+		buf []byte
+	)
+
+	if size > maxHeaderSize {
+		return nil, errors.New("header is too large")
+	} else if _, err = r.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	buf = make([]byte, size)
+
+	if _, err = io.ReadFull(r, buf); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, errTruncated
+		}
+		return nil, err
+	}
+
+	return buf, nil
+} // func readAt(r io.ReadSeeker, offset, size int64) ([]byte, error)
@@ -0,0 +1,198 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/metadata/01_tmdb_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 16. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-16 22:14:36 krylon>
+
+package metadata
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testKey = "s3cr3t"
+
+// tmdbServer pretends to be the TMDB API, serving the responses from
+// testdata.
+func tmdbServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var (
+			file string
+			q    = r.URL.Query()
+		)
+
+		if q.Get("api_key") != testKey {
+			http.Error(w, "Invalid API key", http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case r.URL.Path == "/3/search/movie":
+			if q.Get("query") != "Alien" {
+				t.Errorf("Unexpected query: %q", q.Get("query"))
+			}
+			file = "search_alien.json"
+		case strings.HasPrefix(r.URL.Path, "/3/movie/"):
+			if q.Get("append_to_response") != "credits" {
+				t.Errorf("Credits were not requested: %s", r.URL.RawQuery)
+			}
+			file = "movie_" + filepath.Base(r.URL.Path) + ".json"
+		case strings.HasPrefix(r.URL.Path, "/3/person/"):
+			file = "person_" + filepath.Base(r.URL.Path) + ".json"
+		default:
+			http.NotFound(w, r)
+			return
+		}
+
+		http.ServeFile(w, r, filepath.Join("testdata", file))
+	}))
+} // func tmdbServer(t *testing.T) *httptest.Server
+
+func TestRegistry(t *testing.T) {
+	var (
+		err  error
+		a, b *TMDB
+		list []Provider
+	)
+
+	if a, err = NewTMDB("", testKey); err != nil {
+		t.Fatalf("Cannot create Provider: %s", err.Error())
+	} else if b, err = NewTMDB("http://localhost:1/3/", testKey); err != nil {
+		t.Fatalf("Cannot create Provider: %s", err.Error())
+	} else if a.base.String() != TMDBBaseURL || b.base.String() != "http://localhost:1/3" {
+		t.Errorf("Unexpected base URLs: %s, %s", a.base, b.base)
+	}
+
+	defer Unregister(a.Name())
+
+	if err = Register(a); err != nil {
+		t.Fatalf("Cannot register Provider: %s", err.Error())
+	} else if err = Register(b); err == nil {
+		t.Error("Registering a second Provider with the same name did not fail")
+	} else if Get(a.Name()) != a {
+		t.Errorf("Registry returned the wrong Provider for %s", a.Name())
+	} else if Get("Nonexistent") != nil {
+		t.Error("Registry returned a Provider that was never registered")
+	} else if list = Providers(); len(list) != 1 || list[0] != a {
+		t.Errorf("Unexpected list of Providers: %v", list)
+	}
+
+	Unregister(a.Name())
+
+	if Get(a.Name()) != nil {
+		t.Errorf("%s is still registered", a.Name())
+	}
+} // func TestRegistry(t *testing.T)
+
+func TestTMDB(t *testing.T) {
+	var (
+		err     error
+		p       *TMDB
+		titles  []Title
+		title   *Title
+		person  *Person
+		srv     = tmdbServer(t)
+		expLink = []string{
+			"https://www.themoviedb.org/movie/348",
+			"https://www.imdb.com/title/tt0078748/",
+		}
+		expCast = []string{"Sigourney Weaver", "Tom Skerritt", "Veronica Cartwright"}
+	)
+
+	defer srv.Close()
+
+	if p, err = NewTMDB(srv.URL+"/3", testKey); err != nil {
+		t.Fatalf("Cannot create Provider: %s", err.Error())
+	} else if titles, err = p.SearchTitle("Alien", 1979); err != nil {
+		t.Fatalf("Cannot search for Alien: %s", err.Error())
+	} else if len(titles) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(titles))
+	} else if titles[0].String() != "Alien (1979)" || titles[0].ID != "348" || titles[0].Provider != p.Name() {
+		t.Errorf("Unexpected first result: %#v", titles[0])
+	} else if titles[2].Year != 0 {
+		t.Errorf("Missing release date was not handled: %#v", titles[2])
+	} else if title, err = p.GetTitle("348"); err != nil {
+		t.Fatalf("Cannot get title 348: %s", err.Error())
+	} else if len(title.Links) != len(expLink) {
+		t.Errorf("Unexpected links: %v", title.Links)
+	} else if len(title.Actors) != len(expCast) {
+		t.Errorf("Unexpected cast: %v", title.Actors)
+	} else if len(title.Directors) != 1 || title.Directors[0].Person.Name != "Ridley Scott" {
+		t.Errorf("Unexpected directors: %v", title.Directors)
+	}
+
+	for idx := range title.Links {
+		if title.Links[idx].URL.String() != expLink[idx] {
+			t.Errorf("Unexpected link #%d: %s", idx, title.Links[idx].URL)
+		}
+	}
+
+	for idx := range title.Actors {
+		if title.Actors[idx].Person.Name != expCast[idx] {
+			t.Errorf("Unexpected actor #%d: %s (expected %s)",
+				idx,
+				title.Actors[idx].Person.Name,
+				expCast[idx])
+		}
+	}
+
+	if _, err = p.GetTitle("1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Getting a nonexistent title did not fail properly: %v", err)
+	} else if person, err = p.GetPerson("10205"); err != nil {
+		t.Fatalf("Cannot get Person 10205: %s", err.Error())
+	} else if person.Name != "Sigourney Weaver" || person.Birthday.Format("2006-01-02") != "1949-10-08" {
+		t.Errorf("Unexpected Person: %#v", person)
+	} else if person, err = p.GetPerson("5048"); err != nil {
+		t.Fatalf("Cannot get Person 5048: %s", err.Error())
+	} else if !person.Birthday.IsZero() {
+		t.Errorf("Missing birthday was not handled: %s", person.Birthday)
+	}
+
+	p.key = "wrong"
+
+	if _, err = p.SearchTitle("Alien", 0); err == nil {
+		t.Error("Search with an invalid API key did not fail")
+	} else if strings.Contains(err.Error(), p.key) {
+		t.Errorf("Error message contains the API key: %s", err.Error())
+	}
+} // func TestTMDB(t *testing.T)
+
+func TestFetch(t *testing.T) {
+	var (
+		err   error
+		p     *TMDB
+		title *Title
+		srv   = tmdbServer(t)
+	)
+
+	defer srv.Close()
+
+	if p, err = NewTMDB(srv.URL+"/3", testKey); err != nil {
+		t.Fatalf("Cannot create Provider: %s", err.Error())
+	} else if err = Register(p); err != nil {
+		t.Fatalf("Cannot register Provider: %s", err.Error())
+	}
+
+	defer Unregister(p.Name())
+
+	if _, err = Fetch(&Title{Provider: "Nonexistent", ID: "348"}); err == nil {
+		t.Error("Fetching from an unknown Provider did not fail")
+	} else if title, err = Fetch(&Title{Provider: p.Name(), ID: "348"}); err != nil {
+		t.Fatalf("Cannot fetch title 348: %s", err.Error())
+	} else if title.Actors[0].Person.Birthday.Year() != 1949 {
+		t.Errorf("Details on %s were not fetched: %#v",
+			title.Actors[0].Person.Name,
+			title.Actors[0].Person)
+	} else if title.Actors[0].Character != "Ripley" {
+		t.Errorf("Character got lost: %#v", title.Actors[0])
+	} else if len(title.Directors[0].Person.Links) != 1 {
+		t.Errorf("Unexpected links for %s: %v",
+			title.Directors[0].Person.Name,
+			title.Directors[0].Person.Links)
+	}
+} // func TestFetch(t *testing.T)
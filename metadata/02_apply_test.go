@@ -0,0 +1,98 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/metadata/02_apply_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 16. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-16 22:51:09 krylon>
+
+package metadata
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blicero/blockbuster/common"
+	"github.com/blicero/blockbuster/database"
+	"github.com/blicero/blockbuster/objects"
+)
+
+func TestApply(t *testing.T) {
+	var (
+		err       error
+		db        *database.Database
+		folder    *objects.Folder
+		f         *objects.File
+		p         *objects.Person
+		res       *Result
+		title     *Title
+		prov      *TMDB
+		links     []objects.Link
+		actors    []objects.Person
+		conflicts []string
+		srv       = tmdbServer(t)
+		dir       = filepath.Join(common.BaseDir, "movies")
+	)
+
+	defer srv.Close()
+
+	if prov, err = NewTMDB(srv.URL+"/3", testKey); err != nil {
+		t.Fatalf("Cannot create Provider: %s", err.Error())
+	} else if err = Register(prov); err != nil {
+		t.Fatalf("Cannot register Provider: %s", err.Error())
+	}
+
+	defer Unregister(prov.Name())
+
+	if title, err = Fetch(&Title{Provider: prov.Name(), ID: "348"}); err != nil {
+		t.Fatalf("Cannot fetch title: %s", err.Error())
+	} else if db, err = database.Open(common.DbPath); err != nil {
+		t.Fatalf("Cannot open database: %s", err.Error())
+	}
+
+	defer db.Close() // nolint: errcheck
+
+	if folder, err = db.FolderAdd(dir); err != nil {
+		t.Fatalf("Cannot add Folder %s: %s", dir, err.Error())
+	} else if f, err = db.FileAdd(filepath.Join(dir, "alien_dc.mkv"), folder); err != nil {
+		t.Fatalf("Cannot add File: %s", err.Error())
+	} else if err = db.FileUpdateTitle(f, "Alien - Director's Cut"); err != nil {
+		t.Fatalf("Cannot set title: %s", err.Error())
+	} else if p, err = db.PersonAdd("Tom Skerritt", time.Time{}); err != nil {
+		t.Fatalf("Cannot add Person: %s", err.Error())
+	} else if conflicts = Conflicts(f, title); len(conflicts) != 1 {
+		t.Errorf("Unexpected conflicts: %v", conflicts)
+	}
+
+	// We already know Tom Skerritt, and we keep the title we have.
+	if res, err = Apply(db, f, title, false); err != nil {
+		t.Fatalf("Cannot apply metadata: %s", err.Error())
+	} else if exp := (Result{Year: true, People: 3, Actors: 3, Directors: 1, Links: 2}); *res != exp {
+		t.Errorf("Unexpected result: %#v (expected %#v)", *res, exp)
+	} else if f.Title != "Alien - Director's Cut" || f.Year != 1979 {
+		t.Errorf("Unexpected title and year: %q (%d)", f.Title, f.Year)
+	} else if links, err = db.PersonURLGetByPerson(p); err != nil {
+		t.Fatalf("Cannot get links: %s", err.Error())
+	} else if len(links) != 1 {
+		t.Errorf("Known Person did not get a link: %v", links)
+	} else if actors, err = db.ActorGetByFile(f); err != nil {
+		t.Fatalf("Cannot get actors: %s", err.Error())
+	} else if len(actors) != 3 {
+		t.Errorf("Expected 3 actors, got %d", len(actors))
+	}
+
+	if res, err = Apply(db, f, title, false); err != nil {
+		t.Fatalf("Cannot apply metadata again: %s", err.Error())
+	} else if *res != (Result{}) {
+		t.Errorf("Applying the same metadata twice changed something: %#v", *res)
+	} else if res, err = Apply(db, f, title, true); err != nil {
+		t.Fatalf("Cannot apply metadata with overwrite: %s", err.Error())
+	} else if *res != (Result{Title: true}) {
+		t.Errorf("Unexpected result: %#v", *res)
+	} else if f, err = db.FileGetByID(f.ID); err != nil {
+		t.Fatalf("Cannot look up File: %s", err.Error())
+	} else if f.Title != "Alien" {
+		t.Errorf("Title was not overwritten: %q", f.Title)
+	} else if conflicts = Conflicts(f, title); len(conflicts) != 0 {
+		t.Errorf("Unexpected conflicts: %v", conflicts)
+	}
+} // func TestApply(t *testing.T)
@@ -0,0 +1,184 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/metadata/apply.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 16. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-16 21:26:50 krylon>
+
+package metadata
+
+import (
+	"fmt"
+
+	"github.com/blicero/blockbuster/database"
+	"github.com/blicero/blockbuster/objects"
+)
+
+// Result counts what Apply has changed.
+type Result struct {
+	Title     bool
+	Year      bool
+	People    int
+	Actors    int
+	Directors int
+	Links     int
+}
+
+// Conflicts returns a human-readable description of the values of the File
+// that Apply would replace if asked to overwrite them. An empty list means
+// there is nothing to confirm.
+func Conflicts(f *objects.File, t *Title) []string {
+	var list []string
+
+	if f.Title != "" && t.Title != "" && f.Title != t.Title {
+		list = append(list, fmt.Sprintf("Title: %s → %s", f.Title, t.Title))
+	}
+
+	if f.Year != 0 && t.Year != 0 && f.Year != t.Year {
+		list = append(list, fmt.Sprintf("Year: %d → %d", f.Year, t.Year))
+	}
+
+	return list
+} // func Conflicts(f *objects.File, t *Title) []string
+
+// Apply fills in a File's title and year, its actors, directors and links
+// from a Title we got from Fetch. Title and year are only replaced if the
+// File does not have them, yet, unless overwrite is true. People we do not
+// know, yet, are added, along with their links. Actors, directors and
+// links the File already has are left alone, so applying the same Title
+// twice does no harm.
+func Apply(db *database.Database, f *objects.File, t *Title, overwrite bool) (*Result, error) {
+	var (
+		err       error
+		res       = new(Result)
+		links     []objects.Link
+		actors    []objects.Person
+		directors []objects.Person
+		known     = make(map[string]bool)
+	)
+
+	if links, err = db.FileURLGetByFile(f); err != nil {
+		return nil, err
+	} else if actors, err = db.ActorGetByFile(f); err != nil {
+		return nil, err
+	} else if directors, err = db.DirectorGetByFile(f); err != nil {
+		return nil, err
+	}
+
+	for _, l := range links {
+		known[l.URL.String()] = true
+	}
+
+	if err = db.Begin(); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			db.Rollback() // nolint: errcheck,gosec
+		}
+	}()
+
+	if t.Title != "" && f.Title != t.Title && (f.Title == "" || overwrite) {
+		if err = db.FileUpdateTitle(f, t.Title); err != nil {
+			return nil, err
+		}
+		res.Title = true
+	}
+
+	if t.Year != 0 && f.Year != t.Year && (f.Year == 0 || overwrite) {
+		if err = db.FileUpdateYear(f, t.Year); err != nil {
+			return nil, err
+		}
+		res.Year = true
+	}
+
+	for idx := range t.Links {
+		var l = t.Links[idx]
+
+		if known[l.URL.String()] {
+			continue
+		} else if err = db.FileURLAdd(f, &l); err != nil {
+			return nil, err
+		}
+
+		known[l.URL.String()] = true
+		res.Links++
+	}
+
+	var crew = []struct {
+		credits []Credit
+		present []objects.Person
+		add     func(*objects.File, *objects.Person) error
+		cnt     *int
+	}{
+		{t.Actors, actors, db.ActorAdd, &res.Actors},
+		{t.Directors, directors, db.DirectorAdd, &res.Directors},
+	}
+
+	for _, c := range crew {
+		var present = make(map[int64]bool, len(c.present))
+
+		for _, p := range c.present {
+			present[p.ID] = true
+		}
+
+		for idx := range c.credits {
+			var p *objects.Person
+
+			if p, err = person(db, &c.credits[idx].Person, res); err != nil {
+				return nil, err
+			} else if present[p.ID] {
+				continue
+			} else if err = c.add(f, p); err != nil {
+				return nil, err
+			}
+
+			present[p.ID] = true
+			*c.cnt++
+		}
+	}
+
+	if err = db.Commit(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+} // func Apply(db *database.Database, f *objects.File, t *Title, overwrite bool) (*Result, error)
+
+// person looks up a Person by their name, or adds them, and adds whatever
+// links the Provider gave us that they do not have, yet.
+func person(db *database.Database, mp *Person, res *Result) (*objects.Person, error) {
+	var (
+		err   error
+		p     *objects.Person
+		links []objects.Link
+		known = make(map[string]bool)
+	)
+
+	if p, err = db.PersonGetByName(mp.Name); err != nil {
+		return nil, err
+	} else if p == nil {
+		if p, err = db.PersonAdd(mp.Name, mp.Birthday); err != nil {
+			return nil, err
+		}
+		res.People++
+	} else if links, err = db.PersonURLGetByPerson(p); err != nil {
+		return nil, err
+	}
+
+	for _, l := range links {
+		known[l.URL.String()] = true
+	}
+
+	for idx := range mp.Links {
+		var l = mp.Links[idx]
+
+		if known[l.URL.String()] {
+			continue
+		} else if err = db.PersonURLAdd(p, &l); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+} // func person(db *database.Database, mp *Person, res *Result) (*objects.Person, error)
@@ -0,0 +1,197 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/metadata/metadata.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 16. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-16 19:12:40 krylon>
+
+// Package metadata fetches information on movies and the people who made
+// them from online databases. Each database is accessed through a Provider,
+// and the Providers we know about are kept in a registry.
+package metadata
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/blicero/blockbuster/objects"
+)
+
+// ErrNotFound is returned by a Provider if it does not know the title or
+// Person we asked for.
+var ErrNotFound = errors.New("Not found")
+
+// Person is what a Provider tells us about a Person.
+type Person struct {
+	ID       string
+	Name     string
+	Birthday time.Time
+	Links    []objects.Link
+}
+
+// Credit is a Person's part in a Title. Character is only used for actors.
+type Credit struct {
+	Person    Person
+	Character string
+}
+
+// Title is what a Provider tells us about a movie. Search results only
+// have the ID, Title and Year filled in, GetTitle also returns the cast
+// and crew, though only with the IDs and names of the People involved.
+type Title struct {
+	Provider  string
+	ID        string
+	Title     string
+	Year      int64
+	Links     []objects.Link
+	Actors    []Credit
+	Directors []Credit
+}
+
+func (t *Title) String() string {
+	if t.Year == 0 {
+		return t.Title
+	}
+
+	return fmt.Sprintf("%s (%d)", t.Title, t.Year)
+} // func (t *Title) String() string
+
+// Provider is the interface to an online database.
+type Provider interface {
+	// Name returns a short, human-readable name for the Provider. It
+	// must be unique among the registered Providers.
+	Name() string
+	// SearchTitle looks for movies with the given title. year may be 0
+	// if we do not know it.
+	SearchTitle(title string, year int64) ([]Title, error)
+	// GetTitle returns the details of a movie, including its cast and
+	// its directors.
+	GetTitle(id string) (*Title, error)
+	// GetPerson returns the details of a Person.
+	GetPerson(id string) (*Person, error)
+}
+
+var (
+	regLock  sync.RWMutex
+	registry = make(map[string]Provider)
+)
+
+// Register adds a Provider to the registry.
+func Register(p Provider) error {
+	regLock.Lock()
+	defer regLock.Unlock()
+
+	if _, ok := registry[p.Name()]; ok {
+		return fmt.Errorf("A Provider named %s is already registered",
+			p.Name())
+	}
+
+	registry[p.Name()] = p
+	return nil
+} // func Register(p Provider) error
+
+// Unregister removes the Provider with the given name from the registry.
+func Unregister(name string) {
+	regLock.Lock()
+	delete(registry, name)
+	regLock.Unlock()
+} // func Unregister(name string)
+
+// Get returns the Provider with the given name, or nil if there is none.
+func Get(name string) Provider {
+	regLock.RLock()
+	defer regLock.RUnlock()
+	return registry[name]
+} // func Get(name string) Provider
+
+// Providers returns all registered Providers, ordered by name.
+func Providers() []Provider {
+	var list []Provider
+
+	regLock.RLock()
+	for _, p := range registry {
+		list = append(list, p)
+	}
+	regLock.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+
+	return list
+} // func Providers() []Provider
+
+// Search asks all registered Providers for the given title. A Provider
+// that fails does not keep us from returning what the others found, but
+// if all of them fail, the first error is returned.
+func Search(title string, year int64) ([]Title, error) {
+	var (
+		err     error
+		results []Title
+		failed  int
+		list    = Providers()
+	)
+
+	for _, p := range list {
+		var (
+			res []Title
+			e   error
+		)
+
+		if res, e = p.SearchTitle(title, year); e != nil {
+			if err == nil {
+				err = fmt.Errorf("Cannot search %s: %w", p.Name(), e)
+			}
+			failed++
+			continue
+		}
+
+		results = append(results, res...)
+	}
+
+	if failed > 0 && failed == len(list) {
+		return nil, err
+	}
+
+	return results, nil
+} // func Search(title string, year int64) ([]Title, error)
+
+// MaxActors is the number of actors, in order of billing, we fetch for a
+// title. The rest of the cast is rarely worth the trouble.
+const MaxActors = 15
+
+// Fetch gets the details of a title and of the People in its cast and
+// crew from the Provider the title came from.
+func Fetch(t *Title) (*Title, error) {
+	var (
+		err  error
+		p    Provider
+		full *Title
+	)
+
+	if p = Get(t.Provider); p == nil {
+		return nil, fmt.Errorf("Unknown Provider %q", t.Provider)
+	} else if full, err = p.GetTitle(t.ID); err != nil {
+		return nil, err
+	}
+
+	if len(full.Actors) > MaxActors {
+		full.Actors = full.Actors[:MaxActors]
+	}
+
+	for _, list := range [][]Credit{full.Actors, full.Directors} {
+		for idx := range list {
+			var person *Person
+
+			if person, err = p.GetPerson(list[idx].Person.ID); err != nil {
+				return nil, fmt.Errorf("Cannot get details on %s: %w",
+					list[idx].Person.Name,
+					err)
+			}
+
+			list[idx].Person = *person
+		}
+	}
+
+	return full, nil
+} // func Fetch(t *Title) (*Title, error)
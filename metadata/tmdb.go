@@ -0,0 +1,260 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/metadata/tmdb.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 16. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-16 20:03:17 krylon>
+
+package metadata
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blicero/blockbuster/nfo"
+	"github.com/blicero/blockbuster/objects"
+)
+
+// TMDBBaseURL is the URL of version 3 of the API of The Movie Database.
+const TMDBBaseURL = "https://api.themoviedb.org/3"
+
+const tmdbTimeout = time.Second * 15
+
+// TMDB is a Provider that speaks the JSON API of The Movie Database, or
+// anything that looks enough like it.
+type TMDB struct {
+	base   *url.URL
+	key    string
+	client http.Client
+}
+
+// NewTMDB creates a TMDB Provider that talks to the API at the given base
+// URL. If base is empty, TMDBBaseURL is used.
+func NewTMDB(base, key string) (*TMDB, error) {
+	var (
+		err error
+		t   = &TMDB{
+			key:    key,
+			client: http.Client{Timeout: tmdbTimeout},
+		}
+	)
+
+	if base == "" {
+		base = TMDBBaseURL
+	}
+
+	if t.base, err = url.Parse(strings.TrimSuffix(base, "/")); err != nil {
+		return nil, fmt.Errorf("Invalid base URL %q: %w", base, err)
+	}
+
+	return t, nil
+} // func NewTMDB(base, key string) (*TMDB, error)
+
+// Name returns the name of the Provider.
+func (t *TMDB) Name() string {
+	return "TMDB"
+} // func (t *TMDB) Name() string
+
+// get requests the given path from the API and decodes the JSON response
+// into res.
+func (t *TMDB) get(path string, params url.Values, res interface{}) error {
+	var (
+		err  error
+		resp *http.Response
+		addr = *t.base
+	)
+
+	if params == nil {
+		params = make(url.Values)
+	}
+
+	params.Set("api_key", t.key)
+	addr.Path += path
+	addr.RawQuery = params.Encode()
+
+	// We leave the query string out of error messages, it contains the
+	// API key.
+	if resp, err = t.client.Get(addr.String()); err != nil {
+		var uerr *url.Error
+
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+
+		return fmt.Errorf("Cannot get %s: %w", path, err)
+	}
+
+	defer resp.Body.Close() // nolint: errcheck
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return ErrNotFound
+	default:
+		return fmt.Errorf("Cannot get %s: %s", path, resp.Status)
+	}
+
+	if err = json.NewDecoder(resp.Body).Decode(res); err != nil {
+		return fmt.Errorf("Cannot decode response for %s: %w", path, err)
+	}
+
+	return nil
+} // func (t *TMDB) get(path string, params url.Values, res interface{}) error
+
+type tmdbMovie struct {
+	ID          int64  `json:"id"`
+	Title       string `json:"title"`
+	ReleaseDate string `json:"release_date"`
+	IMDbID      string `json:"imdb_id"`
+	Credits     struct {
+		Cast []struct {
+			ID        int64  `json:"id"`
+			Name      string `json:"name"`
+			Character string `json:"character"`
+			Order     int64  `json:"order"`
+		} `json:"cast"`
+		Crew []struct {
+			ID   int64  `json:"id"`
+			Name string `json:"name"`
+			Job  string `json:"job"`
+		} `json:"crew"`
+	} `json:"credits"`
+}
+
+// title converts what TMDB tells us about a movie to a Title.
+func (m *tmdbMovie) title() Title {
+	var t = Title{
+		Provider: "TMDB",
+		ID:       strconv.FormatInt(m.ID, 10),
+		Title:    m.Title,
+	}
+
+	// TMDB gives us the date in ISO 8601 format, but we only care about
+	// the year.
+	if len(m.ReleaseDate) >= 4 {
+		t.Year, _ = strconv.ParseInt(m.ReleaseDate[:4], 10, 64)
+	}
+
+	return t
+} // func (m *tmdbMovie) title() Title
+
+// SearchTitle looks for movies with the given title.
+func (t *TMDB) SearchTitle(title string, year int64) ([]Title, error) {
+	var (
+		err    error
+		params = url.Values{"query": []string{title}}
+		res    struct {
+			Results []tmdbMovie `json:"results"`
+		}
+	)
+
+	if year != 0 {
+		params.Set("year", strconv.FormatInt(year, 10))
+	}
+
+	if err = t.get("/search/movie", params, &res); err != nil {
+		return nil, err
+	}
+
+	var titles = make([]Title, len(res.Results))
+
+	for idx := range res.Results {
+		titles[idx] = res.Results[idx].title()
+	}
+
+	return titles, nil
+} // func (t *TMDB) SearchTitle(title string, year int64) ([]Title, error)
+
+// GetTitle returns the details of the movie with the given ID.
+func (t *TMDB) GetTitle(id string) (*Title, error) {
+	var (
+		err    error
+		m      tmdbMovie
+		res    Title
+		params = url.Values{"append_to_response": []string{"credits"}}
+	)
+
+	if err = t.get("/movie/"+url.PathEscape(id), params, &m); err != nil {
+		return nil, err
+	}
+
+	res = m.title()
+	res.Links = append(res.Links, link("TMDB", nfo.TMDBURL(res.ID)))
+
+	if m.IMDbID != "" {
+		res.Links = append(res.Links, link("IMDb", nfo.IMDbURL(m.IMDbID)))
+	}
+
+	// The cast is usually sorted by billing already, but the API does not
+	// promise it.
+	sort.SliceStable(m.Credits.Cast, func(i, j int) bool {
+		return m.Credits.Cast[i].Order < m.Credits.Cast[j].Order
+	})
+
+	for _, c := range m.Credits.Cast {
+		res.Actors = append(res.Actors, Credit{
+			Person: Person{
+				ID:   strconv.FormatInt(c.ID, 10),
+				Name: c.Name,
+			},
+			Character: c.Character,
+		})
+	}
+
+	for _, c := range m.Credits.Crew {
+		if c.Job == "Director" {
+			res.Directors = append(res.Directors, Credit{
+				Person: Person{
+					ID:   strconv.FormatInt(c.ID, 10),
+					Name: c.Name,
+				},
+			})
+		}
+	}
+
+	return &res, nil
+} // func (t *TMDB) GetTitle(id string) (*Title, error)
+
+// GetPerson returns the details of the Person with the given ID.
+func (t *TMDB) GetPerson(id string) (*Person, error) {
+	var (
+		err error
+		p   Person
+		res struct {
+			ID       int64  `json:"id"`
+			Name     string `json:"name"`
+			Birthday string `json:"birthday"`
+		}
+	)
+
+	if err = t.get("/person/"+url.PathEscape(id), nil, &res); err != nil {
+		return nil, err
+	}
+
+	p.ID = strconv.FormatInt(res.ID, 10)
+	p.Name = res.Name
+	p.Links = []objects.Link{
+		link("TMDB", fmt.Sprintf("https://www.themoviedb.org/person/%s", p.ID)),
+	}
+
+	if res.Birthday != "" {
+		if p.Birthday, err = time.ParseInLocation("2006-01-02", res.Birthday, time.Local); err != nil {
+			return nil, fmt.Errorf("Invalid birthday for %s: %q", p.Name, res.Birthday)
+		}
+	}
+
+	return &p, nil
+} // func (t *TMDB) GetPerson(id string) (*Person, error)
+
+// link builds a Link from a URL we built ourselves, so we know it is valid.
+func link(title, addr string) objects.Link {
+	var u, _ = url.Parse(addr)
+
+	return objects.Link{Title: title, URL: u}
+} // func link(title, addr string) objects.Link
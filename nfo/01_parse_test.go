@@ -0,0 +1,136 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/nfo/01_parse_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 13. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-13 22:40:27 krylon>
+
+package nfo
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	type testCase struct {
+		file      string
+		title     string
+		year      int64
+		imdb      string
+		tmdb      string
+		genres    []string
+		directors []string
+		actors    []string
+		err       bool
+	}
+
+	var cases = []testCase{
+		{
+			file:      "kodi.nfo",
+			title:     "Alien",
+			year:      1979,
+			imdb:      "tt0078748",
+			tmdb:      "348",
+			genres:    []string{"Horror", "Science Fiction"},
+			directors: []string{"Ridley Scott"},
+			actors:    []string{"Sigourney Weaver", "Tom Skerritt", "Yaphet Kotto"},
+		},
+		{
+			file:      "tmm.nfo",
+			title:     "The Thing",
+			year:      1982,
+			imdb:      "tt0084787",
+			tmdb:      "1091",
+			genres:    []string{"Horror", "Mystery"},
+			directors: []string{"John Carpenter"},
+			actors:    []string{"Kurt Russell", "Wilford Brimley"},
+		},
+		{
+			file: "url.nfo",
+			imdb: "tt0083658",
+		},
+		{
+			file: "junk.nfo",
+			err:  true,
+		},
+	}
+
+	for _, c := range cases {
+		var m, err = Load(filepath.Join("testdata", c.file))
+
+		if c.err {
+			if err == nil {
+				t.Errorf("Parsing %s should have failed", c.file)
+			}
+			continue
+		} else if err != nil {
+			t.Fatalf("Cannot parse %s: %s", c.file, err.Error())
+		}
+
+		var actors []string
+
+		for _, a := range m.Actors {
+			actors = append(actors, a.Name)
+		}
+
+		if m.Title != c.title {
+			t.Errorf("Unexpected title in %s: %q (expected %q)", c.file, m.Title, c.title)
+		} else if m.YearNum() != c.year {
+			t.Errorf("Unexpected year in %s: %d (expected %d)", c.file, m.YearNum(), c.year)
+		} else if id := m.GetID(IDTypeIMDb); id != c.imdb {
+			t.Errorf("Unexpected IMDb ID in %s: %q (expected %q)", c.file, id, c.imdb)
+		} else if id = m.GetID(IDTypeTMDB); id != c.tmdb {
+			t.Errorf("Unexpected TMDB ID in %s: %q (expected %q)", c.file, id, c.tmdb)
+		} else if !reflect.DeepEqual(m.Genres, c.genres) {
+			t.Errorf("Unexpected genres in %s: %v (expected %v)", c.file, m.Genres, c.genres)
+		} else if !reflect.DeepEqual(m.Directors, c.directors) {
+			t.Errorf("Unexpected directors in %s: %v (expected %v)", c.file, m.Directors, c.directors)
+		} else if !reflect.DeepEqual(actors, c.actors) {
+			t.Errorf("Unexpected actors in %s: %v (expected %v)", c.file, actors, c.actors)
+		}
+	}
+} // func TestParse(t *testing.T)
+
+// TestWrite makes sure writing an .nfo file and reading it back gives us
+// the same Movie, including the elements we do not understand.
+func TestWrite(t *testing.T) {
+	for _, file := range []string{"kodi.nfo", "tmm.nfo"} {
+		var (
+			err           error
+			m1, m2        *Movie
+			first, second bytes.Buffer
+		)
+
+		if m1, err = Load(filepath.Join("testdata", file)); err != nil {
+			t.Fatalf("Cannot parse %s: %s", file, err.Error())
+		} else if err = m1.Write(&first); err != nil {
+			t.Fatalf("Cannot write %s: %s", file, err.Error())
+		} else if m2, err = Parse(bytes.NewReader(first.Bytes())); err != nil {
+			t.Fatalf("Cannot parse %s after writing it: %s\n%s",
+				file,
+				err.Error(),
+				first.String())
+		} else if err = m2.Write(&second); err != nil {
+			t.Fatalf("Cannot write %s again: %s", file, err.Error())
+		} else if !reflect.DeepEqual(m1, m2) {
+			t.Errorf("%s has changed after writing it:\n%#v\n\n%#v", file, m1, m2)
+		} else if first.String() != second.String() {
+			t.Errorf("Writing %s twice gives different results:\n%s\n\n%s",
+				file,
+				first.String(),
+				second.String())
+		}
+
+		var orig, _ = ioutil.ReadFile(filepath.Join("testdata", file))
+
+		for _, elt := range []string{"<plot>", "<studio>", "<streamdetails>", "<source>"} {
+			if bytes.Contains(orig, []byte(elt)) && !strings.Contains(first.String(), elt) {
+				t.Errorf("%s lost %s when writing it", file, elt)
+			}
+		}
+	}
+} // func TestWrite(t *testing.T)
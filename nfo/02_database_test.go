@@ -0,0 +1,145 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/nfo/02_database_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 13. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-13 23:02:51 krylon>
+
+package nfo
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/blicero/blockbuster/common"
+	"github.com/blicero/blockbuster/database"
+	"github.com/blicero/blockbuster/objects"
+	"github.com/blicero/krylib"
+)
+
+// summary is the part of a Movie that goes into the database and comes
+// back out again.
+type summary struct {
+	Title     string
+	Year      int64
+	IMDb      string
+	TMDB      string
+	Genres    []string
+	Directors []string
+	Writers   []string
+	Actors    []Actor
+}
+
+func summarize(m *Movie) summary {
+	var s = summary{
+		Title:     m.Title,
+		Year:      m.YearNum(),
+		IMDb:      m.GetID(IDTypeIMDb),
+		TMDB:      m.GetID(IDTypeTMDB),
+		Genres:    append([]string(nil), m.Genres...),
+		Directors: m.Directors,
+		Writers:   m.Credits,
+	}
+
+	sort.Strings(s.Genres)
+
+	for _, a := range m.Actors {
+		s.Actors = append(s.Actors, Actor{Name: a.Name, Role: a.Role, Order: a.Order})
+	}
+
+	return s
+} // func summarize(m *Movie) summary
+
+// TestRoundTrip applies the sample .nfo files to Files in a fresh
+// database, writes them back out and checks we get the same metadata.
+func TestRoundTrip(t *testing.T) {
+	var (
+		err    error
+		db     *database.Database
+		folder *objects.Folder
+		dir    = filepath.Join(common.BaseDir, "movies")
+	)
+
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Cannot create %s: %s", dir, err.Error())
+	} else if db, err = database.Open(common.DbPath); err != nil {
+		t.Fatalf("Cannot open database: %s", err.Error())
+	}
+
+	defer db.Close() // nolint: errcheck
+
+	if folder, err = db.FolderAdd(dir); err != nil {
+		t.Fatalf("Cannot add Folder %s: %s", dir, err.Error())
+	}
+
+	for _, name := range []string{"kodi", "tmm"} {
+		var (
+			orig, exported *Movie
+			found          bool
+			f              *objects.File
+			video          = filepath.Join(dir, name+".mkv")
+			sidecar        = filepath.Join(dir, name+Suffix)
+		)
+
+		if err = krylib.CopyFile(filepath.Join("testdata", name+Suffix), sidecar); err != nil {
+			t.Fatalf("Cannot copy %s: %s", sidecar, err.Error())
+		} else if orig, err = Load(sidecar); err != nil {
+			t.Fatalf("Cannot parse %s: %s", sidecar, err.Error())
+		} else if f, err = db.FileAdd(video, folder); err != nil {
+			t.Fatalf("Cannot add File %s: %s", video, err.Error())
+		} else if found, err = Import(db, f); err != nil {
+			t.Fatalf("Cannot import %s: %s", sidecar, err.Error())
+		} else if !found {
+			t.Fatalf("Did not find %s", sidecar)
+		} else if found, err = Import(db, f); err != nil {
+			// Doing it twice should not hurt.
+			t.Fatalf("Cannot import %s again: %s", sidecar, err.Error())
+		} else if f, err = db.FileGetByID(f.ID); err != nil {
+			t.Fatalf("Cannot look up File %s: %s", video, err.Error())
+		} else if _, err = ExportFolder(db, folder); err != nil {
+			t.Fatalf("Cannot export Folder %s: %s", dir, err.Error())
+		} else if exported, err = Load(sidecar); err != nil {
+			t.Fatalf("Cannot parse exported %s: %s", sidecar, err.Error())
+		}
+
+		if f.Title != orig.Title || f.Year != orig.YearNum() {
+			t.Errorf("File %s has unexpected title or year: %s (%d)",
+				video,
+				f.Title,
+				f.Year)
+		}
+
+		if s1, s2 := summarize(orig), summarize(exported); !reflect.DeepEqual(s1, s2) {
+			t.Errorf("Metadata for %s changed on the way through the database:\n%#v\n\n%#v",
+				name,
+				s1,
+				s2)
+		}
+
+		// Whatever we do not know about must survive.
+		if !reflect.DeepEqual(orig.Rest, exported.Rest) {
+			t.Errorf("Unknown elements in %s were not preserved", sidecar)
+		}
+	}
+
+	// A File without an .nfo file gets a new one.
+	var (
+		f     *objects.File
+		m     *Movie
+		video = filepath.Join(dir, "nothing.mkv")
+	)
+
+	if f, err = db.FileAdd(video, folder); err != nil {
+		t.Fatalf("Cannot add File %s: %s", video, err.Error())
+	} else if err = db.FileUpdateTitle(f, "Nothing"); err != nil {
+		t.Fatalf("Cannot set title of %s: %s", video, err.Error())
+	} else if _, err = ExportFolder(db, folder); err != nil {
+		t.Fatalf("Cannot export Folder %s: %s", dir, err.Error())
+	} else if m, err = Load(SidecarPath(video)); err != nil {
+		t.Fatalf("Cannot read .nfo file for %s: %s", video, err.Error())
+	} else if m.Title != "Nothing" {
+		t.Errorf("Unexpected title in .nfo file for %s: %q", video, m.Title)
+	}
+} // func TestRoundTrip(t *testing.T)
@@ -0,0 +1,360 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/nfo/database.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 13. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-13 22:18:03 krylon>
+
+package nfo
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/blicero/blockbuster/database"
+	"github.com/blicero/blockbuster/objects"
+)
+
+// Genres become Tags, the cast and crew become Credits, and the IDs become
+// links to IMDb and TMDB.
+
+// IMDbURL returns the URL of a movie's page on IMDb.
+func IMDbURL(id string) string {
+	return fmt.Sprintf("https://www.imdb.com/title/%s/", id)
+} // func IMDbURL(id string) string
+
+// TMDBURL returns the URL of a movie's page on TMDB.
+func TMDBURL(id string) string {
+	return fmt.Sprintf("https://www.themoviedb.org/movie/%s", id)
+} // func TMDBURL(id string) string
+
+var linkTitles = map[string]string{
+	IDTypeIMDb: "IMDb",
+	IDTypeTMDB: "TMDB",
+}
+
+// Apply adds the metadata from an .nfo file to a File. Title and year are
+// only set if the File does not have them, yet, Tags, Credits and links are
+// added unless the File already has them, so applying the same .nfo file
+// twice does no harm.
+func Apply(db *database.Database, f *objects.File, m *Movie) error {
+	var (
+		err   error
+		tags  map[int64]objects.Tag
+		cast  []objects.CastMember
+		links []objects.Link
+		names = make(map[string]bool)
+		crew  = make(map[string]bool)
+		urls  = make(map[string]bool)
+	)
+
+	if err = db.Begin(); err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			db.Rollback() // nolint: errcheck,gosec
+		}
+	}()
+
+	if tags, err = db.TagLinkGetByFile(f); err != nil {
+		return err
+	} else if cast, err = db.CreditGetByFile(f); err != nil {
+		return err
+	} else if links, err = db.FileURLGetByFile(f); err != nil {
+		return err
+	}
+
+	for _, t := range tags {
+		names[t.Name] = true
+	}
+
+	for _, c := range cast {
+		crew[creditKey(c.Person.ID, c.Credit.Role)] = true
+	}
+
+	for _, l := range links {
+		urls[l.URL.String()] = true
+	}
+
+	if f.Title == "" && m.Title != "" {
+		if err = db.FileUpdateTitle(f, m.Title); err != nil {
+			return err
+		}
+	}
+
+	if year := m.YearNum(); f.Year == 0 && year != 0 {
+		if err = db.FileUpdateYear(f, year); err != nil {
+			return err
+		}
+	}
+
+	for _, genre := range m.Genres {
+		var t *objects.Tag
+
+		if genre == "" || names[genre] {
+			continue
+		} else if t, err = db.TagGetByName(genre); err != nil {
+			return err
+		} else if t == nil {
+			if t, err = db.TagAdd(genre); err != nil {
+				return err
+			}
+		}
+
+		if err = db.TagLinkAdd(f, t); err != nil {
+			return err
+		}
+
+		names[genre] = true
+	}
+
+	var credits = make([]credit, 0, len(m.Actors)+len(m.Directors)+len(m.Credits))
+
+	for _, a := range m.Actors {
+		var c = credit{name: a.Name, role: objects.RoleActor, character: a.Role}
+
+		if a.Order != nil {
+			c.billing = *a.Order + 1
+		}
+
+		credits = append(credits, c)
+	}
+
+	for _, name := range m.Directors {
+		credits = append(credits, credit{name: name, role: objects.RoleDirector})
+	}
+
+	for _, name := range m.Credits {
+		credits = append(credits, credit{name: name, role: objects.RoleWriter})
+	}
+
+	for _, c := range credits {
+		var p *objects.Person
+
+		if c.name == "" {
+			continue
+		} else if p, err = db.PersonGetByName(c.name); err != nil {
+			return err
+		} else if p == nil {
+			if p, err = db.PersonAdd(c.name, time.Time{}); err != nil {
+				return err
+			}
+		}
+
+		// An actor may play more than one part, but we can only credit
+		// them once per Role.
+		if crew[creditKey(p.ID, c.role)] {
+			continue
+		} else if _, err = db.CreditAdd(f, p, c.role, c.character, c.billing); err != nil {
+			return err
+		}
+
+		crew[creditKey(p.ID, c.role)] = true
+	}
+
+	for _, idType := range []string{IDTypeIMDb, IDTypeTMDB} {
+		var (
+			id   = m.GetID(idType)
+			link = objects.Link{Title: linkTitles[idType]}
+		)
+
+		if id == "" {
+			continue
+		} else if idType == IDTypeIMDb {
+			link.URL, err = url.Parse(IMDbURL(id))
+		} else {
+			link.URL, err = url.Parse(TMDBURL(id))
+		}
+
+		if err != nil {
+			return err
+		} else if urls[link.URL.String()] {
+			continue
+		} else if err = db.FileURLAdd(f, &link); err != nil {
+			return err
+		}
+	}
+
+	err = db.Commit()
+	return err
+} // func Apply(db *database.Database, f *objects.File, m *Movie) error
+
+type credit struct {
+	name      string
+	role      objects.Role
+	character string
+	billing   int64
+}
+
+func creditKey(id int64, role objects.Role) string {
+	return fmt.Sprintf("%d/%d", id, role)
+} // func creditKey(id int64, role objects.Role) string
+
+// Fill puts the metadata we have on a File into m. Whatever we do not
+// know about is left alone, so m may well be the content of an existing
+// .nfo file.
+func Fill(db *database.Database, f *objects.File, m *Movie) error {
+	var (
+		err    error
+		tags   map[int64]objects.Tag
+		cast   []objects.CastMember
+		links  []objects.Link
+		actors = make(map[string]*Actor)
+	)
+
+	if tags, err = db.TagLinkGetByFile(f); err != nil {
+		return err
+	} else if cast, err = db.CreditGetByFile(f); err != nil {
+		return err
+	} else if links, err = db.FileURLGetByFile(f); err != nil {
+		return err
+	}
+
+	if f.Title != "" {
+		m.Title = f.Title
+	}
+
+	if f.Year != 0 {
+		m.Year = fmt.Sprintf("%d", f.Year)
+	}
+
+	m.Genres = make([]string, 0, len(tags))
+	for _, t := range tags {
+		m.Genres = append(m.Genres, t.Name)
+	}
+	sort.Strings(m.Genres)
+
+	// Kodi keeps artwork and other things on the actors, we keep those
+	// for the actors we still have.
+	for i := range m.Actors {
+		actors[m.Actors[i].Name] = &m.Actors[i]
+	}
+
+	var list = make([]Actor, 0, len(cast))
+
+	m.Directors = nil
+	m.Credits = nil
+
+	for _, c := range cast {
+		switch c.Credit.Role {
+		case objects.RoleActor:
+			var a = Actor{Name: c.Person.Name, Role: c.Credit.Character}
+
+			if old, ok := actors[a.Name]; ok {
+				a.Rest = old.Rest
+			}
+
+			if c.Credit.Billing > 0 {
+				var order = c.Credit.Billing - 1
+				a.Order = &order
+			}
+
+			list = append(list, a)
+		case objects.RoleDirector:
+			m.Directors = append(m.Directors, c.Person.Name)
+		case objects.RoleWriter:
+			m.Credits = append(m.Credits, c.Person.Name)
+		}
+	}
+
+	m.Actors = list
+
+	for _, l := range links {
+		if match := imdbPattern.FindStringSubmatch(l.URL.String()); match != nil && l.URL.Host == "www.imdb.com" {
+			m.SetID(IDTypeIMDb, match[1])
+		} else if match = tmdbPattern.FindStringSubmatch(l.URL.String()); match != nil {
+			m.SetID(IDTypeTMDB, match[1])
+		}
+	}
+
+	return nil
+} // func Fill(db *database.Database, f *objects.File, m *Movie) error
+
+// Import looks for an .nfo file next to a File and applies it. It returns
+// false if there is none.
+func Import(db *database.Database, f *objects.File) (bool, error) {
+	var (
+		err  error
+		path string
+		m    *Movie
+	)
+
+	if path, err = Sidecar(f.Path); err != nil {
+		return false, err
+	} else if path == "" {
+		return false, nil
+	} else if m, err = Load(path); err != nil {
+		return false, fmt.Errorf("Cannot read %s: %w", path, err)
+	} else if err = Apply(db, f, m); err != nil {
+		return false, fmt.Errorf("Cannot apply %s to %s: %w",
+			path,
+			f.Path,
+			err)
+	}
+
+	return true, nil
+} // func Import(db *database.Database, f *objects.File) (bool, error)
+
+// ExportFolder writes an .nfo file for every File in the Folder. Existing
+// .nfo files are updated, keeping everything we do not know about, but
+// .nfo files we cannot make sense of are left alone, as are Files that are
+// missing. It returns the number of .nfo files written.
+func ExportFolder(db *database.Database, folder *objects.Folder) (int, error) {
+	var (
+		err   error
+		cnt   int
+		files []objects.File
+		dirs  = make(map[string]int)
+	)
+
+	if files, err = db.FileGetByFolder(folder); err != nil {
+		return 0, err
+	}
+
+	for _, f := range files {
+		dirs[filepath.Dir(f.Path)]++
+	}
+
+	for idx := range files {
+		var (
+			path string
+			m    *Movie
+			f    = &files[idx]
+		)
+
+		if f.IsMissing() {
+			continue
+		} else if path, err = Sidecar(f.Path); err != nil {
+			return cnt, err
+		}
+
+		// movie.nfo only works if there is a single movie in the
+		// directory, otherwise each File gets an .nfo file of its own.
+		if path != "" && filepath.Base(path) == MovieNFO && dirs[filepath.Dir(f.Path)] > 1 {
+			path = ""
+		}
+
+		if path == "" {
+			path = SidecarPath(f.Path)
+			m = new(Movie)
+		} else if m, err = Load(path); err != nil {
+			continue
+		}
+
+		if err = Fill(db, f, m); err != nil {
+			return cnt, fmt.Errorf("Cannot gather metadata for %s: %w",
+				f.Path,
+				err)
+		} else if err = m.Save(path); err != nil {
+			return cnt, fmt.Errorf("Cannot write %s: %w", path, err)
+		}
+
+		cnt++
+	}
+
+	return cnt, nil
+} // func ExportFolder(db *database.Database, folder *objects.Folder) (int, error)
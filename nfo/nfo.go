@@ -0,0 +1,318 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/nfo/nfo.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 13. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-13 20:47:12 krylon>
+
+// Package nfo reads and writes the .nfo files Kodi, Jellyfin and
+// tinyMediaManager keep next to video files.
+// An .nfo file is either an XML document with a <movie> root element, or,
+// in its simplest form, a text file that contains nothing but a link to
+// IMDb or TMDB.
+// We only care about a few of the elements, title, year, genres, cast and
+// crew and the IDs on IMDb and TMDB. Everything else, like the plot, the
+// artwork, or the stream details, is passed through untouched when we write
+// an .nfo file we have read before.
+package nfo
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/blicero/krylib"
+)
+
+// Suffix is the file name suffix of .nfo files.
+const Suffix = ".nfo"
+
+// MovieNFO is the name of the .nfo file that describes the only movie in a
+// directory.
+const MovieNFO = "movie.nfo"
+
+// These are the types of unique IDs we understand.
+const (
+	IDTypeIMDb = "imdb"
+	IDTypeTMDB = "tmdb"
+)
+
+// ErrNoMetadata is returned when an .nfo file contains neither a <movie>
+// element nor a link we recognize.
+var ErrNoMetadata = errors.New("no metadata found")
+
+var (
+	imdbPattern = regexp.MustCompile(`\b(tt\d{7,8})\b`)
+	tmdbPattern = regexp.MustCompile(`themoviedb\.org/movie/(\d+)`)
+)
+
+// Element is an XML element we do not know about. We keep them around, so
+// we do not lose anything when we write a Movie back to disk.
+type Element struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Inner   string     `xml:",innerxml"`
+}
+
+// UniqueID is the ID of a movie in some database on the web.
+type UniqueID struct {
+	Type    string `xml:"type,attr"`
+	Default bool   `xml:"default,attr,omitempty"`
+	Value   string `xml:",chardata"`
+}
+
+// Actor is an entry in the cast of a movie. Order is the position in the
+// credits, starting at 0.
+type Actor struct {
+	Name  string    `xml:"name"`
+	Role  string    `xml:"role,omitempty"`
+	Order *int64    `xml:"order,omitempty"`
+	Rest  []Element `xml:",any"`
+}
+
+// Movie is the content of an .nfo file.
+// Older files keep the IMDb ID in <id>, tinyMediaManager also writes
+// <tmdbid>, newer ones use <uniqueid> for both.
+type Movie struct {
+	XMLName   xml.Name   `xml:"movie"`
+	Title     string     `xml:"title,omitempty"`
+	Year      string     `xml:"year,omitempty"`
+	Premiered string     `xml:"premiered,omitempty"`
+	Genres    []string   `xml:"genre"`
+	Credits   []string   `xml:"credits"`
+	Directors []string   `xml:"director"`
+	Actors    []Actor    `xml:"actor"`
+	UniqueIDs []UniqueID `xml:"uniqueid"`
+	ID        string     `xml:"id,omitempty"`
+	TMDBID    string     `xml:"tmdbid,omitempty"`
+	Rest      []Element  `xml:",any"`
+}
+
+// Sidecar returns the path of the .nfo file that describes the video file
+// at the given path, or an empty string if there is none.
+// <name>.nfo takes precedence over movie.nfo, like it does in Kodi.
+func Sidecar(path string) (string, error) {
+	var candidates = []string{
+		SidecarPath(path),
+		filepath.Join(filepath.Dir(path), MovieNFO),
+	}
+
+	for _, c := range candidates {
+		if exists, err := krylib.Fexists(c); err != nil {
+			return "", err
+		} else if exists {
+			return c, nil
+		}
+	}
+
+	return "", nil
+} // func Sidecar(path string) (string, error)
+
+// SidecarPath returns the path of the <name>.nfo file for the video file at
+// the given path, whether it exists or not.
+func SidecarPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + Suffix
+} // func SidecarPath(path string) string
+
+// Parse reads an .nfo file. If the file is not an XML document, we look for
+// links to IMDb or TMDB in it.
+func Parse(r io.Reader) (*Movie, error) {
+	var (
+		err error
+		buf []byte
+		m   = new(Movie)
+	)
+
+	if buf, err = ioutil.ReadAll(r); err != nil {
+		return nil, err
+	} else if err = xml.Unmarshal(buf, m); err == nil {
+		m.trim()
+		return m, nil
+	}
+
+	// Kodi also accepts .nfo files that contain a URL and nothing else,
+	// possibly after the XML document, which we did not manage to parse.
+	m = new(Movie)
+
+	if match := imdbPattern.FindSubmatch(buf); match != nil {
+		m.SetID(IDTypeIMDb, string(match[1]))
+	}
+
+	if match := tmdbPattern.FindSubmatch(buf); match != nil {
+		m.SetID(IDTypeTMDB, string(match[1]))
+	}
+
+	if len(m.UniqueIDs) == 0 {
+		return nil, ErrNoMetadata
+	}
+
+	return m, nil
+} // func Parse(r io.Reader) (*Movie, error)
+
+// Load reads the .nfo file at the given path.
+func Load(path string) (*Movie, error) {
+	var (
+		err error
+		fh  *os.File
+	)
+
+	if fh, err = os.Open(path); err != nil {
+		return nil, err
+	}
+
+	defer fh.Close() // nolint: errcheck
+
+	return Parse(fh)
+} // func Load(path string) (*Movie, error)
+
+// trim removes the whitespace some tools leave around values.
+func (m *Movie) trim() {
+	m.Title = strings.TrimSpace(m.Title)
+	m.Year = strings.TrimSpace(m.Year)
+	m.Premiered = strings.TrimSpace(m.Premiered)
+	m.ID = strings.TrimSpace(m.ID)
+	m.TMDBID = strings.TrimSpace(m.TMDBID)
+
+	for _, list := range [][]string{m.Genres, m.Credits, m.Directors} {
+		for i := range list {
+			list[i] = strings.TrimSpace(list[i])
+		}
+	}
+
+	for i := range m.Actors {
+		m.Actors[i].Name = strings.TrimSpace(m.Actors[i].Name)
+		m.Actors[i].Role = strings.TrimSpace(m.Actors[i].Role)
+	}
+
+	for i := range m.UniqueIDs {
+		m.UniqueIDs[i].Value = strings.TrimSpace(m.UniqueIDs[i].Value)
+	}
+} // func (m *Movie) trim()
+
+// YearNum returns the year the movie was released, or 0 if we do not know.
+// If <year> is missing, we look at <premiered>, which is a date.
+func (m *Movie) YearNum() int64 {
+	for _, s := range []string{m.Year, m.Premiered} {
+		if len(s) < 4 {
+			continue
+		} else if year, err := strconv.ParseInt(s[:4], 10, 64); err == nil && year > 0 {
+			return year
+		}
+	}
+
+	return 0
+} // func (m *Movie) YearNum() int64
+
+// GetID returns the movie's ID of the given type, or an empty string.
+func (m *Movie) GetID(idType string) string {
+	for _, id := range m.UniqueIDs {
+		if id.Type == idType && id.Value != "" {
+			return id.Value
+		}
+	}
+
+	switch idType {
+	case IDTypeIMDb:
+		if imdbPattern.MatchString(m.ID) {
+			return m.ID
+		}
+	case IDTypeTMDB:
+		return m.TMDBID
+	}
+
+	return ""
+} // func (m *Movie) GetID(idType string) string
+
+// SetID sets the movie's ID of the given type. The IMDb ID is the default,
+// if there is one, because that is what Kodi's scrapers expect.
+func (m *Movie) SetID(idType, value string) {
+	var found bool
+
+	for i := range m.UniqueIDs {
+		if m.UniqueIDs[i].Type == idType {
+			m.UniqueIDs[i].Value = value
+			found = true
+		}
+	}
+
+	if !found {
+		m.UniqueIDs = append(m.UniqueIDs, UniqueID{Type: idType, Value: value})
+	}
+
+	// Keep the legacy elements in sync, so we do not end up with an .nfo
+	// file that contradicts itself.
+	switch idType {
+	case IDTypeIMDb:
+		if m.ID != "" {
+			m.ID = value
+		}
+	case IDTypeTMDB:
+		if m.TMDBID != "" {
+			m.TMDBID = value
+		}
+	}
+
+	var hasIMDb = m.GetID(IDTypeIMDb) != ""
+
+	for i := range m.UniqueIDs {
+		if hasIMDb {
+			m.UniqueIDs[i].Default = m.UniqueIDs[i].Type == IDTypeIMDb
+		} else {
+			m.UniqueIDs[i].Default = i == 0
+		}
+	}
+} // func (m *Movie) SetID(idType, value string)
+
+// Write writes the Movie as an XML document.
+func (m *Movie) Write(w io.Writer) error {
+	var (
+		err error
+		buf []byte
+	)
+
+	if buf, err = xml.MarshalIndent(m, "", "  "); err != nil {
+		return err
+	}
+
+	var doc bytes.Buffer
+
+	doc.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes" ?>`)
+	doc.WriteByte('\n')
+	doc.Write(buf)
+	doc.WriteByte('\n')
+
+	_, err = w.Write(doc.Bytes())
+	return err
+} // func (m *Movie) Write(w io.Writer) error
+
+// Save writes the Movie to the given path. The file is written to a
+// temporary file first, so we never leave a half-written .nfo file behind.
+func (m *Movie) Save(path string) error {
+	var (
+		err error
+		fh  *os.File
+		tmp = path + ".tmp"
+	)
+
+	if fh, err = os.Create(tmp); err != nil {
+		return err
+	} else if err = m.Write(fh); err != nil {
+		fh.Close()     // nolint: errcheck,gosec
+		os.Remove(tmp) // nolint: errcheck,gosec
+		return err
+	} else if err = fh.Close(); err != nil {
+		os.Remove(tmp) // nolint: errcheck,gosec
+		return err
+	} else if err = os.Rename(tmp, path); err != nil {
+		os.Remove(tmp) // nolint: errcheck,gosec
+		return err
+	}
+
+	return nil
+} // func (m *Movie) Save(path string) error
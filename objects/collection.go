@@ -0,0 +1,115 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/objects/collection.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 09. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-09 19:48:12 krylon>
+
+package objects
+
+import "fmt"
+
+// CondKind identifies what a Condition looks at.
+// Conditions are stored in the database as JSON, so the numeric values must
+// not change, and new kinds must be added at the end.
+type CondKind uint8
+
+// These are the kinds of Conditions we know of.
+const (
+	CondAnd      CondKind = iota + 1 // All of Sub match
+	CondOr                           // Any of Sub matches
+	CondNot                          // Sub[0] does not match
+	CondTag                          // Tagged with ID (or the Tag named Text) or one of its descendants
+	CondUntagged                     // Not tagged at all
+	CondPerson                       // Person ID (or named Text) is credited, in Role if non-zero
+	CondYear                         // Year compares to Value
+	CondAdded                        // Year the File was added compares to Value, 0 is the current year
+	CondWatched                      // Has been watched
+	CondTitle                        // Title or path contains Text
+)
+
+var condNames = map[CondKind]string{
+	CondAnd:      "And",
+	CondOr:       "Or",
+	CondNot:      "Not",
+	CondTag:      "Tag",
+	CondUntagged: "Untagged",
+	CondPerson:   "Person",
+	CondYear:     "Year",
+	CondAdded:    "Added",
+	CondWatched:  "Watched",
+	CondTitle:    "Title",
+}
+
+func (k CondKind) String() string {
+	if name, ok := condNames[k]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("CondKind(%d)", k)
+} // func (k CondKind) String() string
+
+// CmpOp is a comparison operator for Conditions on numbers.
+type CmpOp uint8
+
+// These are the comparison operators.
+const (
+	OpEq CmpOp = iota + 1
+	OpNe
+	OpLt
+	OpLe
+	OpGt
+	OpGe
+)
+
+var opNames = map[CmpOp]string{
+	OpEq: "=",
+	OpNe: "<>",
+	OpLt: "<",
+	OpLe: "<=",
+	OpGt: ">",
+	OpGe: ">=",
+}
+
+func (o CmpOp) String() string {
+	if name, ok := opNames[o]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("CmpOp(%d)", o)
+} // func (o CmpOp) String() string
+
+// Condition is a predicate on Files. Conditions can be combined into
+// arbitrary boolean expressions using CondAnd, CondOr and CondNot.
+// Which of the other fields are used depends on the Kind.
+type Condition struct {
+	Kind  CondKind    `json:"kind"`
+	Op    CmpOp       `json:"op,omitempty"`
+	ID    int64       `json:"id,omitempty"`
+	Role  Role        `json:"role,omitempty"`
+	Value int64       `json:"value,omitempty"`
+	Text  string      `json:"text,omitempty"`
+	Sub   []Condition `json:"sub,omitempty"`
+}
+
+// And returns a Condition that matches if all of conds match.
+func And(conds ...Condition) Condition {
+	return Condition{Kind: CondAnd, Sub: conds}
+} // func And(conds ...Condition) Condition
+
+// Or returns a Condition that matches if any of conds matches.
+func Or(conds ...Condition) Condition {
+	return Condition{Kind: CondOr, Sub: conds}
+} // func Or(conds ...Condition) Condition
+
+// Not returns a Condition that matches if c does not match.
+func Not(c Condition) Condition {
+	return Condition{Kind: CondNot, Sub: []Condition{c}}
+} // func Not(c Condition) Condition
+
+// Collection is a named, saved Condition, a.k.a. a smart collection. Its
+// members are whatever Files match the Condition at the time we look.
+type Collection struct {
+	ID   int64
+	Name string
+	Cond Condition
+}
@@ -0,0 +1,87 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/objects/credit.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 06. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-06 20:14:09 krylon>
+
+package objects
+
+import "fmt"
+
+// Role identifies what a Person did on a movie.
+// The numeric values are stored in the database, so they must not change,
+// and new Roles must be added at the end.
+type Role uint8
+
+// These are the Roles we know of.
+const (
+	RoleActor Role = iota + 1
+	RoleDirector
+	RoleWriter
+	RoleComposer
+	RoleProducer
+	RoleCinematographer
+	RoleEditor
+	RoleOther
+)
+
+var roleNames = map[Role]string{
+	RoleActor:           "Actor",
+	RoleDirector:        "Director",
+	RoleWriter:          "Writer",
+	RoleComposer:        "Composer",
+	RoleProducer:        "Producer",
+	RoleCinematographer: "Cinematographer",
+	RoleEditor:          "Editor",
+	RoleOther:           "Other",
+}
+
+func (r Role) String() string {
+	if name, ok := roleNames[r]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("Role(%d)", r)
+} // func (r Role) String() string
+
+// AllRoles returns all the Roles, in the order they appear in the credits.
+func AllRoles() []Role {
+	return []Role{
+		RoleActor,
+		RoleDirector,
+		RoleWriter,
+		RoleComposer,
+		RoleProducer,
+		RoleCinematographer,
+		RoleEditor,
+		RoleOther,
+	}
+} // func AllRoles() []Role
+
+// Credit links a Person to a File in a particular Role.
+// Character is only meaningful for actors.
+// Billing is the position in the credits, lower numbers come first. Zero
+// means we do not know, those come last.
+type Credit struct {
+	ID        int64
+	FileID    int64
+	PersonID  int64
+	Role      Role
+	Character string
+	Billing   int64
+}
+
+// CastMember is a Person along with their Credit on a particular File.
+type CastMember struct {
+	Person Person
+	Credit Credit
+}
+
+// FilmCredit is a File along with a Person's Credit on it.
+type FilmCredit struct {
+	File   File
+	Credit Credit
+}
+
+// Filmography holds a Person's Credits, grouped by Role.
+type Filmography map[Role][]FilmCredit
@@ -8,20 +8,42 @@ package objects
 
 import (
 	"path"
+	"time"
 
 	"github.com/blicero/krylib"
 )
 
 // File represents a simple video file.
 type File struct {
-	ID       int64
-	FolderID int64
-	Path     string
-	Title    string
-	Year     int64
-	Hidden   bool
+	ID             int64
+	FolderID       int64
+	Path           string
+	Title          string
+	Year           int64
+	Hidden         bool
+	EpisodeID      int64
+	Fingerprint    string
+	MissingSince   time.Time
+	Watched        bool
+	ResumePosition time.Duration
+	Stat           FileStat
 }
 
+// FileStat is what we saw of a File on disk the last time we looked at it.
+// If it has not changed, we do not need to look at the File again.
+type FileStat struct {
+	Size  int64
+	Mtime time.Time
+	Inode uint64
+}
+
+// Equal returns true if both FileStats describe the same state of a file.
+func (s FileStat) Equal(other FileStat) bool {
+	return s.Size == other.Size &&
+		s.Mtime.Equal(other.Mtime) &&
+		s.Inode == other.Inode
+} // func (s FileStat) Equal(other FileStat) bool
+
 // DisplayTitle returns the File's Title, or its basename,
 // if the Title is not set.
 func (f *File) DisplayTitle() string {
@@ -32,6 +54,12 @@ func (f *File) DisplayTitle() string {
 	return path.Base(f.Path)
 } // func (f *File) DisplayTitle() string
 
+// IsMissing returns true if the File has been found missing during a scan
+// of its Folder.
+func (f *File) IsMissing() bool {
+	return !f.MissingSince.IsZero()
+} // func (f *File) IsMissing() bool
+
 func (f *File) Size() int64 {
 	if size, err := krylib.FileSize(f.Path); err != nil {
 		return 0
@@ -10,10 +10,17 @@ import "time"
 
 // Folder represents the root of a directory tree that is scanned
 // for Files.
+// MinSize, Suffixes and Exclude are the Folder's scan rules. A MinSize of 0
+// means the default is used, Suffixes are file name extensions considered in
+// addition to the usual video formats, and Exclude holds gitignore-style
+// patterns, relative to the Folder, for files and directories to skip.
 type Folder struct {
 	ID       int64
 	Path     string
 	LastScan time.Time
+	MinSize  int64
+	Suffixes []string
+	Exclude  []string
 }
 
 // IsKnown returns true if the Folder's timestamp from the most recent scan
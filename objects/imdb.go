@@ -0,0 +1,40 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/objects/imdb.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 15. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-15 19:05:47 krylon>
+
+package objects
+
+// IMDbTitle is a row from IMDb's title.basics.tsv.gz. ID is IMDb's ID for
+// the title, like tt0078748. Year is 0 if IMDb does not know it.
+type IMDbTitle struct {
+	ID            string
+	Type          string
+	Title         string
+	OriginalTitle string
+	Year          int64
+}
+
+// IMDbPrincipal is a row from IMDb's title.principals.tsv.gz, a member of
+// the cast or crew of a title. Category is the job, like "actor" or
+// "director", Characters is a JSON array of the parts an actor played, as
+// IMDb gives it to us.
+// Name and BirthYear come from name.basics.tsv.gz.
+type IMDbPrincipal struct {
+	TitleID    string
+	Ordering   int64
+	NameID     string
+	Category   string
+	Characters string
+	Name       string
+	BirthYear  int64
+}
+
+// IMDbName is a row from IMDb's name.basics.tsv.gz. ID is IMDb's ID for the
+// person, like nm0000244. BirthYear is 0 if IMDb does not know it.
+type IMDbName struct {
+	ID        string
+	Name      string
+	BirthYear int64
+}
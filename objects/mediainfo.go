@@ -0,0 +1,84 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/objects/mediainfo.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 02. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-02 18:47:21 krylon>
+
+package objects
+
+import (
+	"fmt"
+	"time"
+)
+
+// TrackKind identifies the type of a Track.
+// The numeric values are stored in the database, so they must not change.
+type TrackKind uint8
+
+// These are the kinds of Tracks we keep track of, besides the video track.
+const (
+	TrackAudio TrackKind = iota + 1
+	TrackSubtitle
+)
+
+var trackKindNames = map[TrackKind]string{
+	TrackAudio:    "Audio",
+	TrackSubtitle: "Subtitle",
+}
+
+func (k TrackKind) String() string {
+	if name, ok := trackKindNames[k]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("TrackKind(%d)", k)
+} // func (k TrackKind) String() string
+
+// Track is an audio or subtitle track in a video file.
+type Track struct {
+	ID       int64
+	Kind     TrackKind
+	Codec    string
+	Language string
+	Name     string
+	Channels int64
+}
+
+// MediaInfo describes the content of a video file, as far as we can tell
+// from its container headers.
+// If Container is empty, the file has been probed, but we did not
+// understand its format.
+type MediaInfo struct {
+	FileID     int64
+	Container  string
+	Duration   time.Duration
+	Width      int64
+	Height     int64
+	FrameRate  float64
+	VideoCodec string
+	Tracks     []Track
+	Probed     time.Time
+}
+
+// Resolution returns the size of the video as a string like 1920x1080, or an
+// empty string if it is not known.
+func (m *MediaInfo) Resolution() string {
+	if m.Width == 0 || m.Height == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%dx%d", m.Width, m.Height)
+} // func (m *MediaInfo) Resolution() string
+
+// TracksOfKind returns the Tracks of the given kind.
+func (m *MediaInfo) TracksOfKind(k TrackKind) []Track {
+	var tracks = make([]Track, 0, len(m.Tracks))
+
+	for _, t := range m.Tracks {
+		if t.Kind == k {
+			tracks = append(tracks, t)
+		}
+	}
+
+	return tracks
+} // func (m *MediaInfo) TracksOfKind(k TrackKind) []Track
@@ -0,0 +1,36 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/objects/playlog.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 04. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-04 16:58:30 krylon>
+
+package objects
+
+import "time"
+
+// PlayLog records one invocation of the player for a File.
+// End is the zero time.Time while the player is still running (or if the
+// application crashed before the player exited).
+// Position is where playback stopped, as far as we know.
+type PlayLog struct {
+	ID         int64
+	FileID     int64
+	Start      time.Time
+	End        time.Time
+	ExitStatus int
+	Position   time.Duration
+}
+
+// IsFinished returns true if the player has exited.
+func (l *PlayLog) IsFinished() bool {
+	return !l.End.IsZero()
+} // func (l *PlayLog) IsFinished() bool
+
+// Duration returns for how long the player was running.
+func (l *PlayLog) Duration() time.Duration {
+	if !l.IsFinished() {
+		return 0
+	}
+
+	return l.End.Sub(l.Start)
+} // func (l *PlayLog) Duration() time.Duration
@@ -0,0 +1,57 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/objects/scanrun.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 17. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-17 18:04:12 krylon>
+
+package objects
+
+import "time"
+
+// ScanStatus describes how a scan of a Folder ended.
+type ScanStatus int
+
+// A scan either ran to completion, was cancelled by the user, or failed.
+const (
+	ScanComplete ScanStatus = iota
+	ScanCancelled
+	ScanFailed
+)
+
+func (s ScanStatus) String() string {
+	switch s {
+	case ScanComplete:
+		return "Complete"
+	case ScanCancelled:
+		return "Cancelled"
+	case ScanFailed:
+		return "Failed"
+	default:
+		return "Unknown"
+	}
+} // func (s ScanStatus) String() string
+
+// ScanRun is the summary of one scan of a Folder.
+// Dirs and Files count what the Scanner saw, the *Skipped counters how much
+// of that it did not need to look at, because nothing had changed since the
+// previous scan. Errors counts the files and directories the Scanner could
+// not read.
+type ScanRun struct {
+	ID           int64
+	FolderID     int64
+	Start        time.Time
+	End          time.Time
+	Status       ScanStatus
+	Dirs         int
+	DirsSkipped  int
+	Files        int
+	FilesSkipped int
+	FilesNew     int
+	FilesChanged int
+	Errors       int
+}
+
+// Duration returns for how long the scan was running.
+func (r *ScanRun) Duration() time.Duration {
+	return r.End.Sub(r.Start)
+} // func (r *ScanRun) Duration() time.Duration
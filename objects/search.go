@@ -0,0 +1,51 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/objects/search.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 30. 08. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-08-30 19:02:11 krylon>
+
+package objects
+
+import "fmt"
+
+// SearchKind identifies the type of object a SearchHit refers to.
+// The numeric values are stored in the search index, so they must not change.
+type SearchKind uint8
+
+// These are the kinds of objects that are included in the search index.
+const (
+	SearchFile SearchKind = iota + 1
+	SearchPerson
+	SearchTag
+	SearchPersonURL
+	SearchFileURL
+)
+
+var searchKindNames = map[SearchKind]string{
+	SearchFile:      "File",
+	SearchPerson:    "Person",
+	SearchTag:       "Tag",
+	SearchPersonURL: "PersonURL",
+	SearchFileURL:   "FileURL",
+}
+
+func (k SearchKind) String() string {
+	if name, ok := searchKindNames[k]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("SearchKind(%d)", k)
+} // func (k SearchKind) String() string
+
+// SearchHit is a single result of a full-text search.
+// For Links, ID refers to the Link itself, while OwnerID refers to the
+// File or Person the Link is attached to. For all other kinds of objects,
+// OwnerID is the same as ID.
+type SearchHit struct {
+	Kind    SearchKind
+	ID      int64
+	OwnerID int64
+	Title   string
+	Snippet string
+	Rank    float64
+}
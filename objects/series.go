@@ -0,0 +1,53 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/objects/series.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 29. 08. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-08-29 16:12:40 krylon>
+
+package objects
+
+import "fmt"
+
+// Series is a TV show, or anything else that comes in Seasons and Episodes.
+type Series struct {
+	ID    int64
+	Title string
+	Year  int64
+}
+
+// Season is one Season of a Series.
+type Season struct {
+	ID       int64
+	SeriesID int64
+	Number   int64
+	Title    string
+	Year     int64
+}
+
+// DisplayTitle returns the Season's Title, or a generic title based on the
+// Season's number, if the Title is not set.
+func (s *Season) DisplayTitle() string {
+	if s.Title != "" {
+		return s.Title
+	}
+
+	return fmt.Sprintf("Season %d", s.Number)
+} // func (s *Season) DisplayTitle() string
+
+// Episode is a single Episode of a Season. A File can be linked to an Episode.
+type Episode struct {
+	ID       int64
+	SeasonID int64
+	Number   int64
+	Title    string
+}
+
+// DisplayTitle returns the Episode's Title, or a generic title based on the
+// Episode's number, if the Title is not set.
+func (e *Episode) DisplayTitle() string {
+	if e.Title != "" {
+		return fmt.Sprintf("%02d - %s", e.Number, e.Title)
+	}
+
+	return fmt.Sprintf("Episode %d", e.Number)
+} // func (e *Episode) DisplayTitle() string
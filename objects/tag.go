@@ -2,14 +2,17 @@
 // -*- mode: go; coding: utf-8; -*-
 // Created on 09. 08. 2021 by Benjamin Walkenhorst
 // (c) 2021 Benjamin Walkenhorst
-// Time-stamp: <2021-08-11 09:29:09 krylon>
+// Time-stamp: <2021-09-08 18:24:51 krylon>
 
 package objects
 
 // Tag is a ... tag that can be attached to videos. Duh.
+// Tags form a hierarchy, Parent is the ID of the parent Tag, or 0 for Tags at
+// the top level.
 type Tag struct {
-	ID   int64
-	Name string
+	ID     int64
+	Name   string
+	Parent int64
 }
 
 // TagList is a helper type to sort Tags by Name.
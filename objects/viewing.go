@@ -0,0 +1,29 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/objects/viewing.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 14. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-14 18:12:40 krylon>
+
+package objects
+
+import "time"
+
+// SourcePlayer is the Source of the Viewings we recorded ourselves, by
+// playing a File.
+const SourcePlayer = "blockbuster"
+
+// Viewing records that a File was watched at some point. Viewings we know
+// about from elsewhere, like a diary kept on Letterboxd, carry the name of
+// the service in Source. Those have an ID, the ones derived from our own
+// PlayLog do not.
+type Viewing struct {
+	ID      int64
+	FileID  int64
+	Watched time.Time
+	Source  string
+}
+
+// MaxRating is the highest rating a File can have. Ratings are counted in
+// half stars, so 10 means five stars, and 0 means the File has not been
+// rated.
+const MaxRating = 10
@@ -0,0 +1,260 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/player/01_ipc_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 05. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-05 18:47:55 krylon>
+
+package player
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blicero/blockbuster/common"
+	"github.com/blicero/blockbuster/logdomain"
+)
+
+// fakeMPV stands in for mpv on the other end of the IPC socket. It knows
+// just enough commands to keep a Session happy.
+type fakeMPV struct {
+	lock     sync.Mutex
+	ln       net.Listener
+	pos      float64
+	duration float64
+	paused   bool
+	quit     bool
+}
+
+func newFakeMPV(path string, pos, duration float64) (*fakeMPV, error) {
+	var (
+		err error
+		m   = &fakeMPV{pos: pos, duration: duration}
+	)
+
+	if m.ln, err = net.Listen("unix", path); err != nil {
+		return nil, err
+	}
+
+	go m.serve()
+
+	return m, nil
+} // func newFakeMPV(path string, pos, duration float64) (*fakeMPV, error)
+
+func (m *fakeMPV) serve() {
+	for {
+		var (
+			err  error
+			conn net.Conn
+		)
+
+		if conn, err = m.ln.Accept(); err != nil {
+			return
+		}
+
+		go m.handle(conn)
+	}
+} // func (m *fakeMPV) serve()
+
+func (m *fakeMPV) handle(conn net.Conn) {
+	defer conn.Close() // nolint: errcheck
+
+	var (
+		rd  = bufio.NewScanner(conn)
+		enc = json.NewEncoder(conn)
+	)
+
+	for rd.Scan() {
+		var (
+			req ipcRequest
+			res = map[string]interface{}{"error": "success"}
+		)
+
+		if err := json.Unmarshal(rd.Bytes(), &req); err != nil {
+			return
+		}
+
+		res["request_id"] = req.RequestID
+
+		// mpv sends events whenever it feels like it, so do we.
+		enc.Encode(map[string]string{"event": "playback-restart"}) // nolint: errcheck
+
+		m.lock.Lock()
+		switch req.Command[0] {
+		case "get_property":
+			switch req.Command[1] {
+			case "time-pos":
+				res["data"] = m.pos
+			case "duration":
+				res["data"] = m.duration
+			default:
+				res["error"] = "property unavailable"
+			}
+		case "cycle":
+			m.paused = !m.paused
+		case "seek":
+			m.pos += req.Command[1].(float64)
+		case "quit":
+			m.quit = true
+		default:
+			res["error"] = "invalid parameter"
+		}
+		m.lock.Unlock()
+
+		if err := enc.Encode(res); err != nil {
+			return
+		}
+	}
+} // func (m *fakeMPV) handle(conn net.Conn)
+
+func TestMkArgs(t *testing.T) {
+	type testCase struct {
+		cmdline  []string
+		sockPath string
+		resume   time.Duration
+		expected []string
+	}
+
+	var testCases = []testCase{
+		{
+			cmdline:  []string{"/usr/bin/vlc"},
+			expected: []string{"/data/movie.mkv"},
+		},
+		{
+			cmdline:  []string{"/usr/bin/mpv", "--fs"},
+			sockPath: "/tmp/mpv.sock",
+			expected: []string{"--fs", "--input-ipc-server=/tmp/mpv.sock", "/data/movie.mkv"},
+		},
+		{
+			cmdline:  []string{"mpv"},
+			sockPath: "/tmp/mpv.sock",
+			resume:   time.Minute*42 + time.Millisecond*500,
+			expected: []string{"--input-ipc-server=/tmp/mpv.sock", "--start=2520.5", "/data/movie.mkv"},
+		},
+	}
+
+	for _, c := range testCases {
+		var args = mkArgs(c.cmdline, "/data/movie.mkv", c.sockPath, c.resume)
+
+		if !reflect.DeepEqual(args, c.expected) {
+			t.Errorf("Unexpected arguments for %v:\nExpected: %v\nGot:      %v",
+				c.cmdline,
+				c.expected,
+				args)
+		}
+	}
+
+	if !IsMPV([]string{"/usr/local/bin/mpv", "--fs"}) {
+		t.Error("IsMPV does not recognize mpv")
+	} else if IsMPV([]string{"/usr/bin/vlc"}) {
+		t.Error("IsMPV mistakes vlc for mpv")
+	}
+} // func TestMkArgs(t *testing.T)
+
+func TestClient(t *testing.T) {
+	var (
+		err  error
+		m    *fakeMPV
+		c    *Client
+		pos  float64
+		path = filepath.Join(common.BaseDir, "client.sock")
+	)
+
+	if m, err = newFakeMPV(path, 42.5, 5400); err != nil {
+		t.Fatalf("Cannot start fake mpv: %s", err.Error())
+	}
+
+	defer m.ln.Close() // nolint: errcheck
+
+	if c, err = Dial(path); err != nil {
+		t.Fatalf("Cannot connect to fake mpv: %s", err.Error())
+	}
+
+	defer c.Close() // nolint: errcheck
+
+	if pos, err = c.GetFloat("time-pos"); err != nil {
+		t.Fatalf("Cannot get time-pos: %s", err.Error())
+	} else if pos != 42.5 {
+		t.Errorf("Unexpected time-pos: %f (expected 42.5)", pos)
+	}
+
+	if _, err = c.Command("seek", 10, "relative"); err != nil {
+		t.Fatalf("Cannot seek: %s", err.Error())
+	} else if pos, err = c.GetFloat("time-pos"); err != nil {
+		t.Fatalf("Cannot get time-pos: %s", err.Error())
+	} else if pos != 52.5 {
+		t.Errorf("Unexpected time-pos after seeking: %f (expected 52.5)", pos)
+	}
+
+	if _, err = c.GetFloat("chapter"); err != ErrUnavailable {
+		t.Errorf("Expected ErrUnavailable for unknown property, got %v", err)
+	} else if _, err = c.Command("frobnicate"); err == nil {
+		t.Error("Unknown command did not cause an error")
+	}
+} // func TestClient(t *testing.T)
+
+func TestSession(t *testing.T) {
+	var (
+		err           error
+		m             *fakeMPV
+		status        int
+		pos, duration time.Duration
+		path          = filepath.Join(common.BaseDir, "session.sock")
+		s             = newSession(exec.Command("sleep", "1.5"), path)
+	)
+
+	if s.log, err = common.GetLogger(logdomain.Player); err != nil {
+		t.Fatalf("Cannot create Logger: %s", err.Error())
+	} else if err = s.start(); err != nil {
+		t.Fatalf("Cannot start Session: %s", err.Error())
+	}
+
+	// The socket appears some time after the player has started.
+	time.Sleep(dialInterval * 3)
+
+	if m, err = newFakeMPV(path, 600, 5400); err != nil {
+		t.Fatalf("Cannot start fake mpv: %s", err.Error())
+	}
+
+	defer m.ln.Close() // nolint: errcheck
+
+	time.Sleep(dialInterval * 3)
+
+	if err = s.Pause(); err != nil {
+		t.Errorf("Cannot pause: %s", err.Error())
+	} else if err = s.Stop(); err != nil {
+		t.Errorf("Cannot stop: %s", err.Error())
+	}
+
+	if status, err = s.Wait(); err != nil {
+		t.Fatalf("Player failed: %s", err.Error())
+	} else if status != 0 {
+		t.Errorf("Unexpected exit status %d", status)
+	}
+
+	m.lock.Lock()
+	if !m.paused {
+		t.Error("Player was not paused")
+	} else if !m.quit {
+		t.Error("Player was not told to quit")
+	}
+	m.lock.Unlock()
+
+	if !s.Tracked() {
+		t.Fatal("Session did not track the playback position")
+	} else if pos, duration = s.Position(); pos != time.Minute*10 {
+		t.Errorf("Unexpected position %s (expected 10m)", pos)
+	} else if duration != time.Minute*90 {
+		t.Errorf("Unexpected duration %s (expected 1h30m)", duration)
+	}
+
+	if err = s.Pause(); err != ErrNoIPC {
+		t.Errorf("Pausing a finished Session should fail with ErrNoIPC, got %v", err)
+	}
+} // func TestSession(t *testing.T)
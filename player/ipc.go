@@ -0,0 +1,142 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/player/ipc.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 05. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-05 16:40:12 krylon>
+
+package player
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// mpv's JSON IPC protocol is pleasantly simple: We send a command as a JSON
+// object on a single line, mpv replies with a JSON object on a single line.
+// In between, mpv may send events we did not ask for, which we ignore.
+// Replies carry the request_id of the command they answer.
+//
+// See https://mpv.io/manual/stable/#json-ipc
+
+// ipcTimeout is how long we wait for mpv to answer a command.
+const ipcTimeout = time.Second * 5
+
+// ErrUnavailable is returned when asking for a property mpv does not have a
+// value for at the moment, e.g. the playback position before a file has
+// been loaded.
+var ErrUnavailable = errors.New("property unavailable")
+
+type ipcRequest struct {
+	Command   []interface{} `json:"command"`
+	RequestID int64         `json:"request_id"`
+}
+
+type ipcResponse struct {
+	Error     string          `json:"error"`
+	Data      json.RawMessage `json:"data"`
+	RequestID int64           `json:"request_id"`
+	Event     string          `json:"event"`
+}
+
+// Client talks to a running mpv instance through its IPC socket.
+type Client struct {
+	lock  sync.Mutex
+	conn  net.Conn
+	rd    *bufio.Reader
+	reqID int64
+}
+
+// Dial connects to the IPC socket at path.
+func Dial(path string) (*Client, error) {
+	var (
+		err  error
+		conn net.Conn
+	)
+
+	if conn, err = net.Dial("unix", path); err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		conn: conn,
+		rd:   bufio.NewReader(conn),
+	}, nil
+} // func Dial(path string) (*Client, error)
+
+// Close closes the connection to mpv.
+func (c *Client) Close() error {
+	return c.conn.Close()
+} // func (c *Client) Close() error
+
+// Command sends a command to mpv and returns the data of the reply, if any.
+func (c *Client) Command(args ...interface{}) (json.RawMessage, error) {
+	var (
+		err  error
+		buf  []byte
+		line []byte
+		req  ipcRequest
+	)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.reqID++
+	req.Command = args
+	req.RequestID = c.reqID
+
+	if buf, err = json.Marshal(&req); err != nil {
+		return nil, err
+	} else if err = c.conn.SetDeadline(time.Now().Add(ipcTimeout)); err != nil {
+		return nil, err
+	} else if _, err = c.conn.Write(append(buf, '\n')); err != nil {
+		return nil, err
+	}
+
+	for {
+		var res ipcResponse
+
+		if line, err = c.rd.ReadBytes('\n'); err != nil {
+			return nil, err
+		} else if err = json.Unmarshal(line, &res); err != nil {
+			return nil, fmt.Errorf("Cannot parse reply from mpv %q: %s",
+				line,
+				err.Error())
+		} else if res.Event != "" || res.RequestID != req.RequestID {
+			continue
+		}
+
+		switch res.Error {
+		case "success":
+			return res.Data, nil
+		case "property unavailable":
+			return nil, ErrUnavailable
+		default:
+			return nil, fmt.Errorf("mpv: %s", res.Error)
+		}
+	}
+} // func (c *Client) Command(args ...interface{}) (json.RawMessage, error)
+
+// GetFloat returns the value of a numeric property.
+func (c *Client) GetFloat(prop string) (float64, error) {
+	var (
+		err  error
+		val  float64
+		data json.RawMessage
+	)
+
+	if data, err = c.Command("get_property", prop); err != nil {
+		return 0, err
+	} else if err = json.Unmarshal(data, &val); err != nil {
+		return 0, fmt.Errorf("Cannot parse value of %s (%q): %s",
+			prop,
+			data,
+			err.Error())
+	}
+
+	return val, nil
+} // func (c *Client) GetFloat(prop string) (float64, error)
@@ -0,0 +1,297 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/player/player.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 05. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-05 18:02:37 krylon>
+
+// Package player runs the external video player.
+// If the player is mpv, we talk to it through its JSON IPC interface, so we
+// know where playback stopped and can control it from the GUI. Any other
+// player is just started and waited for.
+package player
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/blicero/blockbuster/common"
+	"github.com/blicero/blockbuster/logdomain"
+)
+
+const (
+	pollInterval = time.Second
+	dialInterval = time.Millisecond * 100
+	dialTimeout  = time.Second * 10
+)
+
+// ErrNoIPC is returned when trying to control a player we cannot talk to.
+var ErrNoIPC = errors.New("player cannot be controlled")
+
+var sockCnt int64
+
+// IsMPV returns true if the player command line runs mpv.
+func IsMPV(cmdline []string) bool {
+	return len(cmdline) > 0 && filepath.Base(cmdline[0]) == "mpv"
+} // func IsMPV(cmdline []string) bool
+
+// mkArgs builds the arguments for the player. For mpv, we add the IPC
+// socket and the position to start from.
+func mkArgs(cmdline []string, path, sockPath string, resume time.Duration) []string {
+	var args = make([]string, 0, len(cmdline)+2)
+
+	args = append(args, cmdline[1:]...)
+
+	if sockPath != "" {
+		args = append(args, "--input-ipc-server="+sockPath)
+		if resume > 0 {
+			args = append(args, fmt.Sprintf("--start=%.1f", resume.Seconds()))
+		}
+	}
+
+	return append(args, path)
+} // func mkArgs(cmdline []string, path, sockPath string, resume time.Duration) []string
+
+// Session is a running instance of the player.
+type Session struct {
+	log        *log.Logger
+	cmd        *exec.Cmd
+	sockPath   string
+	lock       sync.RWMutex
+	client     *Client
+	pos        time.Duration
+	duration   time.Duration
+	done       chan struct{}
+	exitStatus int
+	err        error
+}
+
+// Start runs the player given by cmdline on the file at path. If the player
+// is mpv, playback starts at resume.
+func Start(cmdline []string, path string, resume time.Duration) (*Session, error) {
+	var (
+		err      error
+		sockPath string
+	)
+
+	if len(cmdline) == 0 {
+		return nil, errors.New("no player command given")
+	} else if IsMPV(cmdline) {
+		// The BaseDir is only accessible to us, so nobody else gets to
+		// talk to our player.
+		sockPath = filepath.Join(
+			common.BaseDir,
+			fmt.Sprintf("mpv.%d.%d.sock",
+				os.Getpid(),
+				atomic.AddInt64(&sockCnt, 1)))
+	}
+
+	var s = newSession(
+		exec.Command(cmdline[0], mkArgs(cmdline, path, sockPath, resume)...),
+		sockPath)
+
+	if s.log, err = common.GetLogger(logdomain.Player); err != nil {
+		return nil, err
+	} else if err = s.start(); err != nil {
+		s.log.Printf("[ERROR] Cannot start player for %s: %s\n",
+			path,
+			err.Error())
+		return nil, err
+	}
+
+	return s, nil
+} // func Start(cmdline []string, path string, resume time.Duration) (*Session, error)
+
+func newSession(cmd *exec.Cmd, sockPath string) *Session {
+	return &Session{
+		cmd:        cmd,
+		sockPath:   sockPath,
+		done:       make(chan struct{}),
+		exitStatus: -1,
+	}
+} // func newSession(cmd *exec.Cmd, sockPath string) *Session
+
+func (s *Session) start() error {
+	var err error
+
+	if err = s.cmd.Start(); err != nil {
+		return err
+	}
+
+	go s.wait()
+
+	if s.sockPath != "" {
+		go s.monitor()
+	}
+
+	return nil
+} // func (s *Session) start() error
+
+func (s *Session) wait() {
+	var err = s.cmd.Wait()
+
+	s.lock.Lock()
+	s.err = err
+	if s.cmd.ProcessState != nil {
+		s.exitStatus = s.cmd.ProcessState.ExitCode()
+	}
+	s.lock.Unlock()
+
+	close(s.done)
+} // func (s *Session) wait()
+
+// monitor connects to the player's IPC socket as soon as it appears and
+// then keeps track of the playback position until the player exits.
+func (s *Session) monitor() {
+	var (
+		err      error
+		c        *Client
+		deadline = time.Now().Add(dialTimeout)
+		ticker   = time.NewTicker(pollInterval)
+	)
+
+	defer ticker.Stop()
+	defer os.Remove(s.sockPath) // nolint: errcheck
+
+	for c == nil {
+		if c, err = Dial(s.sockPath); err == nil {
+			break
+		} else if time.Now().After(deadline) {
+			s.log.Printf("[ERROR] Cannot connect to player at %s: %s\n",
+				s.sockPath,
+				err.Error())
+			return
+		}
+
+		select {
+		case <-s.done:
+			return
+		case <-time.After(dialInterval):
+		}
+	}
+
+	defer c.Close() // nolint: errcheck
+
+	s.lock.Lock()
+	s.client = c
+	s.lock.Unlock()
+
+	for {
+		s.poll(c)
+
+		select {
+		case <-s.done:
+			s.lock.Lock()
+			s.client = nil
+			s.lock.Unlock()
+			return
+		case <-ticker.C:
+		}
+	}
+} // func (s *Session) monitor()
+
+func (s *Session) poll(c *Client) {
+	var (
+		err           error
+		pos, duration float64
+	)
+
+	// While mpv is loading a file or shutting down, the position is not
+	// available, but the position from the last poll is still good.
+	if pos, err = c.GetFloat("time-pos"); err != nil {
+		if err != ErrUnavailable {
+			s.log.Printf("[DEBUG] Cannot get playback position: %s\n",
+				err.Error())
+		}
+		return
+	} else if duration, err = c.GetFloat("duration"); err != nil && err != ErrUnavailable {
+		s.log.Printf("[DEBUG] Cannot get duration: %s\n",
+			err.Error())
+	}
+
+	s.lock.Lock()
+	s.pos = time.Duration(pos * float64(time.Second))
+	if duration > 0 {
+		s.duration = time.Duration(duration * float64(time.Second))
+	}
+	s.lock.Unlock()
+} // func (s *Session) poll(c *Client)
+
+// Wait blocks until the player exits and returns its exit status.
+func (s *Session) Wait() (int, error) {
+	<-s.done
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.exitStatus, s.err
+} // func (s *Session) Wait() (int, error)
+
+// Done returns a channel that is closed when the player exits.
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+} // func (s *Session) Done() <-chan struct{}
+
+// Tracked returns true if we know the playback position, i.e. if we have
+// been able to talk to the player at all.
+func (s *Session) Tracked() bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.pos > 0 || s.duration > 0
+} // func (s *Session) Tracked() bool
+
+// Position returns the last known playback position and the duration of the
+// file being played.
+func (s *Session) Position() (time.Duration, time.Duration) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.pos, s.duration
+} // func (s *Session) Position() (time.Duration, time.Duration)
+
+func (s *Session) command(args ...interface{}) error {
+	var (
+		err error
+		c   *Client
+	)
+
+	select {
+	case <-s.done:
+		return ErrNoIPC
+	default:
+	}
+
+	s.lock.RLock()
+	c = s.client
+	s.lock.RUnlock()
+
+	if c == nil {
+		return ErrNoIPC
+	} else if _, err = c.Command(args...); err != nil {
+		s.log.Printf("[ERROR] Cannot send command %v to player: %s\n",
+			args,
+			err.Error())
+		return err
+	}
+
+	return nil
+} // func (s *Session) command(args ...interface{}) error
+
+// Pause toggles the player between playing and paused.
+func (s *Session) Pause() error {
+	return s.command("cycle", "pause")
+} // func (s *Session) Pause() error
+
+// Seek moves the playback position by offset, which may be negative.
+func (s *Session) Seek(offset time.Duration) error {
+	return s.command("seek", offset.Seconds(), "relative")
+} // func (s *Session) Seek(offset time.Duration) error
+
+// Stop tells the player to quit.
+func (s *Session) Stop() error {
+	return s.command("quit")
+} // func (s *Session) Stop() error
@@ -0,0 +1,171 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/tree/01_fingerprint_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 31. 08. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-08-31 21:04:17 krylon>
+
+package tree
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blicero/blockbuster/common"
+	"github.com/blicero/blockbuster/database"
+	"github.com/blicero/blockbuster/logdomain"
+	"github.com/blicero/blockbuster/objects"
+)
+
+// mkVideo creates a sparse file of the given size that is large enough for
+// the walker to pick it up, with content at the beginning and the end,
+// so different files get different fingerprints.
+func mkVideo(path, content string, size int64) error {
+	var (
+		err error
+		fh  *os.File
+	)
+
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	} else if fh, err = os.Create(path); err != nil {
+		return err
+	}
+
+	defer fh.Close() // nolint: errcheck
+
+	if _, err = fh.WriteString(content); err != nil {
+		return err
+	} else if _, err = fh.WriteAt([]byte(content), size-int64(len(content))); err != nil {
+		return err
+	}
+
+	return nil
+} // func mkVideo(path, content string, size int64) error
+
+func TestFingerprint(t *testing.T) {
+	var (
+		err                error
+		fp1, fp2, fp3, fp4 string
+		dir                = filepath.Join(common.BaseDir, "fingerprint")
+		p1                 = filepath.Join(dir, "one.mkv")
+		p2                 = filepath.Join(dir, "two.mkv")
+		p3                 = filepath.Join(dir, "three.mkv")
+		p4                 = filepath.Join(dir, "four.mkv")
+	)
+
+	if err = mkVideo(p1, "Hello, world!", minSize); err != nil {
+		t.Fatalf("Cannot create %s: %s", p1, err.Error())
+	} else if err = mkVideo(p2, "Hello, world!", minSize); err != nil {
+		t.Fatalf("Cannot create %s: %s", p2, err.Error())
+	} else if err = mkVideo(p3, "Goodbye, world!", minSize); err != nil {
+		t.Fatalf("Cannot create %s: %s", p3, err.Error())
+	} else if err = mkVideo(p4, "Hello, world!", minSize*2); err != nil {
+		t.Fatalf("Cannot create %s: %s", p4, err.Error())
+	}
+
+	if fp1, err = fingerprint(p1); err != nil {
+		t.Fatalf("Cannot compute fingerprint of %s: %s", p1, err.Error())
+	} else if fp2, err = fingerprint(p2); err != nil {
+		t.Fatalf("Cannot compute fingerprint of %s: %s", p2, err.Error())
+	} else if fp3, err = fingerprint(p3); err != nil {
+		t.Fatalf("Cannot compute fingerprint of %s: %s", p3, err.Error())
+	} else if fp4, err = fingerprint(p4); err != nil {
+		t.Fatalf("Cannot compute fingerprint of %s: %s", p4, err.Error())
+	}
+
+	if fp1 != fp2 {
+		t.Errorf("Files with identical content have different fingerprints: %s != %s",
+			fp1,
+			fp2)
+	}
+
+	if fp1 == fp3 {
+		t.Errorf("Files with different content have the same fingerprint %s",
+			fp1)
+	}
+
+	if fp1 == fp4 {
+		t.Errorf("Files with different sizes have the same fingerprint %s",
+			fp1)
+	}
+} // func TestFingerprint(t *testing.T)
+
+func TestWalkerRelink(t *testing.T) {
+	var (
+		err          error
+		db           *database.Database
+		folder       *objects.Folder
+		f, moved     *objects.File
+		fileQ        = make(chan *objects.File, 8)
+		dir          = filepath.Join(common.BaseDir, "videos")
+		path         = filepath.Join(dir, "Some.Movie.2001.mkv")
+		newPath      = filepath.Join(dir, "Movies", "Some Movie (2001).mkv")
+		otherPath    = filepath.Join(dir, "Another.Movie.1999.mkv")
+		otherNewPath = filepath.Join(dir, "Another Movie (1999).mkv")
+	)
+
+	if err = mkVideo(path, "Some Movie", minSize); err != nil {
+		t.Fatalf("Cannot create %s: %s", path, err.Error())
+	} else if db, err = database.Open(common.DbPath); err != nil {
+		t.Fatalf("Cannot open database: %s", err.Error())
+	}
+
+	defer db.Close() // nolint: errcheck
+
+	var w = walker{
+		fileQ: fileQ,
+		db:    db,
+	}
+
+	if w.log, err = common.GetLogger(logdomain.Scanner); err != nil {
+		t.Fatalf("Cannot create Logger: %s", err.Error())
+	} else if folder, err = db.FolderAdd(dir); err != nil {
+		t.Fatalf("Cannot add Folder %s: %s", dir, err.Error())
+	}
+
+	w.root = folder
+
+	if err = filepath.WalkDir(dir, w.visitFile); err != nil {
+		t.Fatalf("Error scanning %s: %s", dir, err.Error())
+	} else if len(fileQ) != 1 {
+		t.Fatalf("Unexpected number of new Files: %d (expected 1)", len(fileQ))
+	}
+
+	f = <-fileQ
+
+	if f.Fingerprint == "" {
+		t.Fatalf("New File %s does not have a fingerprint", f.Path)
+	} else if err = db.FolderUpdateScan(folder, time.Now()); err != nil {
+		t.Fatalf("Cannot update scan timestamp of Folder %s: %s",
+			dir,
+			err.Error())
+	}
+
+	// Now we move the file and copy it, more or less. The moved file should
+	// be recognized and relinked, the copy is a new File, because the
+	// original File still exists.
+	if err = os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		t.Fatalf("Cannot create directory for %s: %s", newPath, err.Error())
+	} else if err = os.Rename(path, newPath); err != nil {
+		t.Fatalf("Cannot rename %s to %s: %s", path, newPath, err.Error())
+	} else if err = mkVideo(otherPath, "Another Movie", minSize); err != nil {
+		t.Fatalf("Cannot create %s: %s", otherPath, err.Error())
+	} else if err = mkVideo(otherNewPath, "Another Movie", minSize); err != nil {
+		t.Fatalf("Cannot create %s: %s", otherNewPath, err.Error())
+	} else if err = filepath.WalkDir(dir, w.visitFile); err != nil {
+		t.Fatalf("Error scanning %s: %s", dir, err.Error())
+	} else if len(fileQ) != 2 {
+		t.Fatalf("Unexpected number of new Files: %d (expected 2)", len(fileQ))
+	} else if moved, err = db.FileGetByPath(newPath); err != nil {
+		t.Fatalf("Cannot look up File %s: %s", newPath, err.Error())
+	} else if moved == nil {
+		t.Fatalf("Moved File %s was not found in database", newPath)
+	} else if moved.ID != f.ID {
+		t.Errorf("Moved File %s was added as a new File (#%d), not relinked (#%d)",
+			newPath,
+			moved.ID,
+			f.ID)
+	}
+} // func TestWalkerRelink(t *testing.T)
@@ -0,0 +1,119 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/tree/02_missing_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 01. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-01 20:17:33 krylon>
+
+package tree
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blicero/blockbuster/common"
+	"github.com/blicero/blockbuster/database"
+	"github.com/blicero/blockbuster/logdomain"
+	"github.com/blicero/blockbuster/objects"
+)
+
+// scan walks the walker's Folder and updates the missing Files, much like
+// Scanner.scanFolder does.
+func (w *walker) scan() error {
+	var err error
+
+	w.seen = nil
+	w.errDirs = nil
+
+	if err = filepath.WalkDir(w.root.Path, w.visitFile); err != nil {
+		return err
+	}
+
+	return w.updateMissing()
+} // func (w *walker) scan() error
+
+func TestWalkerMissing(t *testing.T) {
+	var (
+		err    error
+		db     *database.Database
+		folder *objects.Folder
+		f      *objects.File
+		fileQ  = make(chan *objects.File, 8)
+		dir    = filepath.Join(common.BaseDir, "missing")
+		away   = filepath.Join(common.BaseDir, "unmounted")
+		path   = filepath.Join(dir, "Lost.Highway.1997.mkv")
+	)
+
+	if err = mkVideo(path, "Lost Highway", minSize); err != nil {
+		t.Fatalf("Cannot create %s: %s", path, err.Error())
+	} else if db, err = database.Open(common.DbPath); err != nil {
+		t.Fatalf("Cannot open database: %s", err.Error())
+	}
+
+	defer db.Close() // nolint: errcheck
+
+	var w = walker{
+		fileQ: fileQ,
+		db:    db,
+	}
+
+	if w.log, err = common.GetLogger(logdomain.Scanner); err != nil {
+		t.Fatalf("Cannot create Logger: %s", err.Error())
+	} else if folder, err = db.FolderAdd(dir); err != nil {
+		t.Fatalf("Cannot add Folder %s: %s", dir, err.Error())
+	}
+
+	w.root = folder
+
+	if err = w.scan(); err != nil {
+		t.Fatalf("Error scanning %s: %s", dir, err.Error())
+	} else if len(fileQ) != 1 {
+		t.Fatalf("Unexpected number of new Files: %d (expected 1)", len(fileQ))
+	} else if err = db.FolderUpdateScan(folder, time.Now()); err != nil {
+		t.Fatalf("Cannot update scan timestamp of Folder %s: %s",
+			dir,
+			err.Error())
+	}
+
+	f = <-fileQ
+
+	// If the whole Folder is gone, e.g. because it lives on a network share
+	// that is not mounted, we cannot tell anything about the Files in it,
+	// so they must not be marked as missing.
+	if err = os.Rename(dir, away); err != nil {
+		t.Fatalf("Cannot rename %s to %s: %s", dir, away, err.Error())
+	} else if err = w.scan(); err != nil {
+		t.Fatalf("Error scanning %s: %s", dir, err.Error())
+	} else if f, err = db.FileGetByID(f.ID); err != nil {
+		t.Fatalf("Cannot look up File %d: %s", f.ID, err.Error())
+	} else if f.IsMissing() {
+		t.Fatalf("File %s in unreachable Folder was marked as missing",
+			f.Path)
+	} else if err = os.Rename(away, dir); err != nil {
+		t.Fatalf("Cannot rename %s to %s: %s", away, dir, err.Error())
+	}
+
+	if err = os.Remove(path); err != nil {
+		t.Fatalf("Cannot remove %s: %s", path, err.Error())
+	} else if err = w.scan(); err != nil {
+		t.Fatalf("Error scanning %s: %s", dir, err.Error())
+	} else if f, err = db.FileGetByID(f.ID); err != nil {
+		t.Fatalf("Cannot look up File %d: %s", f.ID, err.Error())
+	} else if !f.IsMissing() {
+		t.Fatalf("Deleted File %s was not marked as missing", f.Path)
+	}
+
+	// When the file comes back, it is no longer missing.
+	if err = mkVideo(path, "Lost Highway", minSize); err != nil {
+		t.Fatalf("Cannot create %s: %s", path, err.Error())
+	} else if err = w.scan(); err != nil {
+		t.Fatalf("Error scanning %s: %s", dir, err.Error())
+	} else if len(fileQ) != 0 {
+		t.Errorf("Reappeared File %s was added again", path)
+	} else if f, err = db.FileGetByID(f.ID); err != nil {
+		t.Fatalf("Cannot look up File %d: %s", f.ID, err.Error())
+	} else if f.IsMissing() {
+		t.Errorf("File %s is still missing after it reappeared", f.Path)
+	}
+} // func TestWalkerMissing(t *testing.T)
@@ -0,0 +1,59 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/tree/03_probe_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 03. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-03 22:10:45 krylon>
+
+package tree
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blicero/blockbuster/common"
+	"github.com/blicero/blockbuster/database"
+	"github.com/blicero/blockbuster/objects"
+)
+
+func TestScannerProbe(t *testing.T) {
+	var (
+		err   error
+		s     *Scanner
+		db    *database.Database
+		f     *objects.File
+		info  *objects.MediaInfo
+		fileQ = make(chan *objects.File, 8)
+		dir   = filepath.Join(common.BaseDir, "probe")
+		path  = filepath.Join(dir, "Not.Really.A.Movie.mkv")
+	)
+
+	if err = mkVideo(path, "This is not a Matroska file", minSize); err != nil {
+		t.Fatalf("Cannot create %s: %s", path, err.Error())
+	} else if s, err = NewScanner(fileQ); err != nil {
+		t.Fatalf("Cannot create Scanner: %s", err.Error())
+	}
+
+	s.ScanPath(context.Background(), dir)
+
+	// New Files are only passed on after they have been probed.
+	select {
+	case f = <-fileQ:
+	case <-time.After(time.Second * 10):
+		t.Fatalf("Scanner did not find %s", path)
+	}
+
+	db = s.pool.Get()
+	defer s.pool.Put(db)
+
+	if info, err = db.MediaInfoGetByFile(f); err != nil {
+		t.Fatalf("Cannot get MediaInfo for %s: %s", path, err.Error())
+	} else if info == nil {
+		t.Fatalf("File %s was not probed", path)
+	} else if info.Container != "" {
+		t.Errorf("Unexpected container for garbage file %s: %q",
+			path,
+			info.Container)
+	}
+} // func TestScannerProbe(t *testing.T)
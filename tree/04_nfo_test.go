@@ -0,0 +1,60 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/tree/04_nfo_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 13. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-13 23:19:44 krylon>
+
+package tree
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blicero/blockbuster/common"
+	"github.com/blicero/blockbuster/objects"
+)
+
+const sidecar = `<?xml version="1.0" encoding="UTF-8" standalone="yes" ?>
+<movie>
+  <title>Dark Star</title>
+  <year>1974</year>
+  <genre>Comedy</genre>
+  <director>John Carpenter</director>
+</movie>
+`
+
+func TestScannerSidecar(t *testing.T) {
+	var (
+		err   error
+		s     *Scanner
+		f     *objects.File
+		fileQ = make(chan *objects.File, 8)
+		dir   = filepath.Join(common.BaseDir, "sidecar")
+		path  = filepath.Join(dir, "dark_star.mkv")
+	)
+
+	if err = mkVideo(path, "Let there be light", minSize); err != nil {
+		t.Fatalf("Cannot create %s: %s", path, err.Error())
+	} else if err = ioutil.WriteFile(filepath.Join(dir, "dark_star.nfo"), []byte(sidecar), 0644); err != nil {
+		t.Fatalf("Cannot create .nfo file: %s", err.Error())
+	} else if s, err = NewScanner(fileQ); err != nil {
+		t.Fatalf("Cannot create Scanner: %s", err.Error())
+	}
+
+	s.ScanPath(context.Background(), dir)
+
+	select {
+	case f = <-fileQ:
+	case <-time.After(time.Second * 10):
+		t.Fatalf("Scanner did not find %s", path)
+	}
+
+	if f.Title != "Dark Star" || f.Year != 1974 {
+		t.Errorf("Metadata from .nfo file was not applied: %q (%d)",
+			f.Title,
+			f.Year)
+	}
+} // func TestScannerSidecar(t *testing.T)
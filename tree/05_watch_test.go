@@ -0,0 +1,191 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/tree/05_watch_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 17. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-17 01:12:40 krylon>
+
+package tree
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/blicero/blockbuster/common"
+	"github.com/blicero/blockbuster/objects"
+)
+
+const testDebounce = time.Millisecond * 250
+
+// waitFile waits for a File with the given path to come out of fileQ,
+// ignoring anything else the Scanner sends.
+func waitFile(t *testing.T, fileQ <-chan *objects.File, path string) *objects.File {
+	var timeout = time.After(time.Second * 10)
+
+	for {
+		select {
+		case f := <-fileQ:
+			if f.Path == path {
+				return f
+			}
+		case <-timeout:
+			t.Fatalf("Scanner did not report %s", path)
+			return nil
+		}
+	}
+} // func waitFile(t *testing.T, fileQ <-chan *objects.File, path string) *objects.File
+
+func TestWatch(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("inotify is only available on Linux")
+	}
+
+	var (
+		err     error
+		s       *Scanner
+		f, g    *objects.File
+		written time.Time
+		fileQ   = make(chan *objects.File, 8)
+		dir     = filepath.Join(common.BaseDir, "watch")
+		p1      = filepath.Join(dir, "existing.mkv")
+		p2      = filepath.Join(dir, "new.mkv")
+		p3      = filepath.Join(dir, "renamed.mkv")
+		p4      = filepath.Join(dir, "subdir", "nested.mkv")
+	)
+
+	if err = mkVideo(p1, "I was here first", minSize); err != nil {
+		t.Fatalf("Cannot create %s: %s", p1, err.Error())
+	} else if s, err = NewScanner(fileQ); err != nil {
+		t.Fatalf("Cannot create Scanner: %s", err.Error())
+	}
+
+	s.ScanPath(context.Background(), dir)
+	waitFile(t, fileQ, p1)
+
+	if err = s.watch(testDebounce, time.Hour); err != nil {
+		t.Fatalf("Cannot watch Folders: %s", err.Error())
+	}
+
+	defer s.StopWatching()
+
+	if err = s.watch(testDebounce, time.Hour); err == nil {
+		t.Error("Scanner started watching twice")
+	}
+
+	// Wait for the scan to finish, so the changes below are only seen by
+	// the watcher.
+	for s.Active() {
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	if err = mkVideo(p2, "Fresh off the press", minSize); err != nil {
+		t.Fatalf("Cannot create %s: %s", p2, err.Error())
+	}
+
+	written = time.Now()
+	f = waitFile(t, fileQ, p2)
+
+	if time.Since(written) < testDebounce {
+		t.Errorf("File was picked up before it had settled")
+	} else if f.ID == 0 {
+		t.Errorf("New File %s was not added to the Database", p2)
+	}
+
+	if err = os.Rename(p2, p3); err != nil {
+		t.Fatalf("Cannot rename %s: %s", p2, err.Error())
+	} else if g = waitFile(t, fileQ, p3); g.ID != f.ID {
+		t.Errorf("Renamed File has a new ID: %d (expected %d)", g.ID, f.ID)
+	}
+
+	if err = os.Remove(p3); err != nil {
+		t.Fatalf("Cannot remove %s: %s", p3, err.Error())
+	} else if g = waitFile(t, fileQ, p3); !g.IsMissing() {
+		t.Errorf("Deleted File %s is not marked as missing", p3)
+	}
+
+	if err = mkVideo(p4, "Deep down below", minSize); err != nil {
+		t.Fatalf("Cannot create %s: %s", p4, err.Error())
+	}
+
+	waitFile(t, fileQ, p4)
+} // func TestWatch(t *testing.T)
+
+func TestWatchPoll(t *testing.T) {
+	var (
+		err   error
+		s     *Scanner
+		fileQ = make(chan *objects.File, 8)
+		dir   = filepath.Join(common.BaseDir, "poll")
+		p1    = filepath.Join(dir, "first.mkv")
+		p2    = filepath.Join(dir, "second.mkv")
+	)
+
+	if err = mkVideo(p1, "Knock knock", minSize); err != nil {
+		t.Fatalf("Cannot create %s: %s", p1, err.Error())
+	} else if s, err = NewScanner(fileQ); err != nil {
+		t.Fatalf("Cannot create Scanner: %s", err.Error())
+	}
+
+	s.ScanPath(context.Background(), dir)
+	waitFile(t, fileQ, p1)
+
+	for s.Active() {
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	// Pretend the Folder lives on a file system inotify does not work
+	// on.
+	var w = &watcher{
+		s:        s,
+		log:      s.log,
+		debounce: testDebounce,
+		interval: time.Second,
+		roots:    make(map[string]*objects.Folder),
+		polled:   make(map[string]bool),
+		pending:  make(map[string]time.Time),
+		rescan:   make(map[string]time.Time),
+		stopQ:    make(chan struct{}),
+	}
+
+	w.addRoot(&objects.Folder{Path: dir})
+	if !w.polled[dir] {
+		t.Fatalf("Folder %s is not polled without inotify", dir)
+	}
+
+	s.lock.Lock()
+	s.watcher = w
+	s.lock.Unlock()
+	go w.tickLoop()
+
+	defer s.StopWatching()
+
+	if err = mkVideo(p2, "Who's there?", minSize); err != nil {
+		t.Fatalf("Cannot create %s: %s", p2, err.Error())
+	}
+
+	waitFile(t, fileQ, p2)
+} // func TestWatchPoll(t *testing.T)
+
+func TestWatchRootOf(t *testing.T) {
+	var (
+		outer = &objects.Folder{Path: "/data/movies"}
+		inner = &objects.Folder{Path: "/data/movies/kids"}
+		w     = &watcher{
+			roots: map[string]*objects.Folder{
+				outer.Path: outer,
+				inner.Path: inner,
+			},
+		}
+	)
+
+	if r := w.rootOf("/data/movies/alien.mkv"); r != outer {
+		t.Errorf("Wrong root for a file in the outer Folder: %v", r)
+	} else if r = w.rootOf("/data/movies/kids/up/up.mkv"); r != inner {
+		t.Errorf("Wrong root for a file in the inner Folder: %v", r)
+	} else if r = w.rootOf("/data/movies2/alien.mkv"); r != nil {
+		t.Errorf("File outside all Folders has a root: %v", r)
+	}
+} // func TestWatchRootOf(t *testing.T)
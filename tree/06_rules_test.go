@@ -0,0 +1,181 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/tree/06_rules_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 17. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-17 11:48:20 krylon>
+
+package tree
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/blicero/blockbuster/common"
+	"github.com/blicero/blockbuster/database"
+	"github.com/blicero/blockbuster/logdomain"
+	"github.com/blicero/blockbuster/objects"
+)
+
+func TestPattern(t *testing.T) {
+	type testCase struct {
+		pattern string
+		path    string
+		dir     bool
+		match   bool
+	}
+
+	var cases = []testCase{
+		{pattern: "*sample*", path: "alien-sample.mkv", match: true},
+		{pattern: "*sample*", path: "extras/sample/x.mkv", match: false},
+		{pattern: "*sample*", path: "extras/sample", dir: true, match: true},
+		{pattern: "*.sfv", path: "a/b/c.sfv", match: true},
+		{pattern: "/top.mkv", path: "top.mkv", match: true},
+		{pattern: "/top.mkv", path: "sub/top.mkv", match: false},
+		{pattern: "extras/", path: "extras", dir: true, match: true},
+		{pattern: "extras/", path: "extras", match: false},
+		{pattern: "a/*.mkv", path: "a/b.mkv", match: true},
+		{pattern: "a/*.mkv", path: "a/b/c.mkv", match: false},
+		{pattern: "a/**/c.mkv", path: "a/c.mkv", match: true},
+		{pattern: "a/**/c.mkv", path: "a/b/b/c.mkv", match: true},
+		{pattern: "**/trailers", path: "x/y/trailers", dir: true, match: true},
+		{pattern: "part?.avi", path: "part1.avi", match: true},
+		{pattern: "part[!0-9].avi", path: "part1.avi", match: false},
+		{pattern: "part[!0-9].avi", path: "partx.avi", match: true},
+		{pattern: `\#1.mkv`, path: "#1.mkv", match: true},
+	}
+
+	for _, c := range cases {
+		var (
+			err error
+			p   *pattern
+		)
+
+		if p, err = compilePattern(c.pattern); err != nil {
+			t.Errorf("Cannot compile pattern %q: %s", c.pattern, err.Error())
+		} else if m := match([]*pattern{p}, c.path, c.dir, false); m != c.match {
+			t.Errorf("Pattern %q, path %q (dir: %t): expected %t, got %t",
+				c.pattern,
+				c.path,
+				c.dir,
+				c.match,
+				m)
+		}
+	}
+
+	for _, line := range []string{"", "   ", "# comment", "/"} {
+		if p, err := compilePattern(line); err != nil || p != nil {
+			t.Errorf("Line %q should be ignored: %v, %v", line, p, err)
+		}
+	}
+} // func TestPattern(t *testing.T)
+
+func TestCheckRules(t *testing.T) {
+	if err := CheckRules(1024, []string{"ts", ".m2ts"}, []string{"*sample*", "!keep"}); err != nil {
+		t.Errorf("Valid rules were rejected: %s", err.Error())
+	}
+
+	if err := CheckRules(-1, nil, nil); err == nil {
+		t.Error("Negative minimum size was accepted")
+	} else if err = CheckRules(0, []string{"tar.gz"}, nil); err == nil {
+		t.Error("Invalid suffix was accepted")
+	}
+} // func TestCheckRules(t *testing.T)
+
+func TestScanRules(t *testing.T) {
+	var (
+		err    error
+		db     *database.Database
+		folder *objects.Folder
+		fileQ  = make(chan *objects.File, 16)
+		dir    = filepath.Join(common.BaseDir, "rules")
+		videos = map[string]int64{
+			"movie.mkv":                minSize,
+			"clip.mp4":                 1024,
+			"recording.ts":             minSize,
+			"movie-sample.mkv":         minSize,
+			"keep/keep-sample.mkv":     minSize,
+			"extras/making_of.mkv":     minSize,
+			"trailers/trailer.mkv":     minSize,
+			"trailers/director.mkv":    minSize,
+			"checksums/checksums.sfv":  minSize,
+			"deep/down/below/deep.mkv": minSize,
+		}
+		expected = []string{
+			"clip.mp4",
+			"deep/down/below/deep.mkv",
+			"keep/keep-sample.mkv",
+			"movie.mkv",
+			"recording.ts",
+			"trailers/director.mkv",
+		}
+		found []string
+	)
+
+	for name, size := range videos {
+		var path = filepath.Join(dir, filepath.FromSlash(name))
+		if err = mkVideo(path, name, size); err != nil {
+			t.Fatalf("Cannot create %s: %s", path, err.Error())
+		}
+	}
+
+	if err = ioutil.WriteFile(filepath.Join(dir, IgnoreFile), []byte("# No extras\nextras/\n"), 0644); err != nil {
+		t.Fatalf("Cannot create %s: %s", IgnoreFile, err.Error())
+	} else if err = ioutil.WriteFile(filepath.Join(dir, "trailers", IgnoreFile), []byte("*.mkv\n!director.mkv\n"), 0644); err != nil {
+		t.Fatalf("Cannot create %s: %s", IgnoreFile, err.Error())
+	} else if db, err = database.Open(common.DbPath); err != nil {
+		t.Fatalf("Cannot open database: %s", err.Error())
+	}
+
+	defer db.Close() // nolint: errcheck
+
+	if folder, err = db.FolderAdd(dir); err != nil {
+		t.Fatalf("Cannot add Folder %s: %s", dir, err.Error())
+	} else if err = db.FolderUpdateRules(folder, 512, []string{"ts"}, []string{"*sample*", "!keep/*"}); err != nil {
+		t.Fatalf("Cannot set scan rules: %s", err.Error())
+	}
+
+	var w = walker{
+		root:  folder,
+		fileQ: fileQ,
+		db:    db,
+	}
+
+	if w.log, err = common.GetLogger(logdomain.Scanner); err != nil {
+		t.Fatalf("Cannot create Logger: %s", err.Error())
+	} else if err = filepath.WalkDir(dir, w.visitFile); err != nil {
+		t.Fatalf("Cannot walk %s: %s", dir, err.Error())
+	}
+
+	close(fileQ)
+
+	for f := range fileQ {
+		var rel, _ = filepath.Rel(dir, f.Path)
+		found = append(found, filepath.ToSlash(rel))
+	}
+
+	sort.Strings(found)
+
+	if len(found) != len(expected) {
+		t.Fatalf("Unexpected Files: %v (expected %v)", found, expected)
+	}
+
+	for i := range found {
+		if found[i] != expected[i] {
+			t.Errorf("Unexpected File #%d: %s (expected %s)",
+				i,
+				found[i],
+				expected[i])
+		}
+	}
+
+	// The watcher has to check the directories above a file, too.
+	var r = newRules(w.log, folder)
+
+	if !r.excluded(filepath.Join(dir, "extras", "new", "interview.mkv"), false) {
+		t.Error("File in excluded directory is not excluded")
+	} else if r.excluded(filepath.Join(dir, "new.mkv"), false) {
+		t.Error("New file is excluded")
+	}
+} // func TestScanRules(t *testing.T)
@@ -0,0 +1,165 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/tree/07_incremental_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 17. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-17 16:20:45 krylon>
+
+package tree
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blicero/blockbuster/common"
+	"github.com/blicero/blockbuster/objects"
+)
+
+// scanOnce scans a Folder synchronously and returns the statistics.
+func scanOnce(t *testing.T, s *Scanner, fileQ <-chan *objects.File, dir string) ScanStats {
+	var stats []ScanStats
+
+	s.scanFolder(context.Background(), dir)
+
+	// Drain the queue, so the next scan does not block.
+	for {
+		select {
+		case <-fileQ:
+			continue
+		case <-time.After(time.Millisecond * 250):
+		}
+		break
+	}
+
+	if stats = s.Stats(); len(stats) != 1 {
+		t.Fatalf("Expected statistics for one scan, got %d", len(stats))
+	}
+
+	return stats[0]
+} // func scanOnce(t *testing.T, s *Scanner, fileQ <-chan *objects.File, dir string) ScanStats
+
+// settle sets the mtime of the given directories to some time in the past,
+// so the walker trusts them.
+func settle(t *testing.T, stamp time.Time, dirs ...string) {
+	for _, d := range dirs {
+		if err := os.Chtimes(d, stamp, stamp); err != nil {
+			t.Fatalf("Cannot set mtime of %s: %s", d, err.Error())
+		}
+	}
+} // func settle(t *testing.T, stamp time.Time, dirs ...string)
+
+func TestScanIncremental(t *testing.T) {
+	var (
+		err   error
+		s     *Scanner
+		st    ScanStats
+		fileQ = make(chan *objects.File, 16)
+		dir   = filepath.Join(common.BaseDir, "incremental")
+		dirA  = filepath.Join(dir, "a")
+		dirB  = filepath.Join(dir, "b")
+		dirC  = filepath.Join(dirB, "c")
+		p1    = filepath.Join(dirA, "one.mkv")
+		p2    = filepath.Join(dirC, "two.mkv")
+		p3    = filepath.Join(dirC, "three.mkv")
+		past  = time.Now().Add(-time.Hour)
+	)
+
+	if err = mkVideo(p1, "One", minSize); err != nil {
+		t.Fatalf("Cannot create %s: %s", p1, err.Error())
+	} else if err = mkVideo(p2, "Two", minSize); err != nil {
+		t.Fatalf("Cannot create %s: %s", p2, err.Error())
+	} else if s, err = NewScanner(fileQ); err != nil {
+		t.Fatalf("Cannot create Scanner: %s", err.Error())
+	}
+
+	settle(t, past, dir, dirA, dirB, dirC)
+
+	if st = scanOnce(t, s, fileQ, dir); st.Dirs != 4 || st.DirsSkipped != 0 || st.FilesNew != 2 {
+		t.Errorf("Unexpected statistics for first scan: %#v", st)
+	}
+
+	// Nothing has changed, so we do not need to read any directory.
+	if st = scanOnce(t, s, fileQ, dir); st.Dirs != 4 || st.DirsSkipped != 4 || st.Files != 2 || st.FilesSkipped != 2 {
+		t.Errorf("Unexpected statistics for second scan: %#v", st)
+	}
+
+	// Adding a file changes the mtime of its directory.
+	if err = mkVideo(p3, "Three", minSize); err != nil {
+		t.Fatalf("Cannot create %s: %s", p3, err.Error())
+	}
+
+	settle(t, past.Add(time.Minute), dirC)
+
+	if st = scanOnce(t, s, fileQ, dir); st.DirsSkipped != 3 || st.FilesNew != 1 || st.FilesSkipped != 2 {
+		t.Errorf("Unexpected statistics after adding a file: %#v", st)
+	}
+
+	// A file that was replaced is looked at again.
+	if err = mkVideo(p1, "One, again", minSize*2); err != nil {
+		t.Fatalf("Cannot create %s: %s", p1, err.Error())
+	}
+
+	settle(t, past.Add(time.Minute), dirA)
+
+	if st = scanOnce(t, s, fileQ, dir); st.DirsSkipped != 3 || st.FilesChanged != 1 || st.FilesNew != 0 {
+		t.Errorf("Unexpected statistics after replacing a file: %#v", st)
+	}
+
+	// Modifying a file in place does not change the mtime of its
+	// directory, but we still notice.
+	if err = mkVideo(p2, "Two, again", minSize*2); err != nil {
+		t.Fatalf("Cannot update %s: %s", p2, err.Error())
+	}
+
+	settle(t, past.Add(time.Minute), dirC)
+
+	if st = scanOnce(t, s, fileQ, dir); st.DirsSkipped != 4 || st.FilesChanged != 1 || st.FilesSkipped != 2 {
+		t.Errorf("Unexpected statistics after modifying a file: %#v", st)
+	}
+
+	// Editing an ignore file in place does not change the mtime of its
+	// directory, but everything below it has to be looked at again.
+	if err = ioutil.WriteFile(filepath.Join(dir, IgnoreFile), []byte("two.mkv\n"), 0644); err != nil {
+		t.Fatalf("Cannot create %s: %s", IgnoreFile, err.Error())
+	}
+
+	settle(t, past.Add(time.Minute*2), dir)
+	scanOnce(t, s, fileQ, dir)
+
+	if err = ioutil.WriteFile(filepath.Join(dir, IgnoreFile), []byte("# Nothing\n"), 0644); err != nil {
+		t.Fatalf("Cannot update %s: %s", IgnoreFile, err.Error())
+	}
+
+	settle(t, past.Add(time.Minute*2), dir)
+
+	if st = scanOnce(t, s, fileQ, dir); st.DirsSkipped != 0 || st.Files != 3 {
+		t.Errorf("Unexpected statistics after editing %s: %#v", IgnoreFile, st)
+	}
+
+	// A file that is too small might still be copied, so its directory is
+	// read again next time.
+	if err = mkVideo(filepath.Join(dirA, "partial.mkv"), "Partial", 1024); err != nil {
+		t.Fatalf("Cannot create partial file: %s", err.Error())
+	}
+
+	settle(t, past.Add(time.Minute*3), dirA)
+	scanOnce(t, s, fileQ, dir)
+
+	if st = scanOnce(t, s, fileQ, dir); st.Dirs != 4 || st.DirsSkipped != 3 {
+		t.Errorf("Directory with a small file was skipped: %#v", st)
+	}
+
+	// The same goes for a directory that has changed very recently.
+	if err = mkVideo(filepath.Join(dirB, "four.mkv"), "Four", minSize); err != nil {
+		t.Fatalf("Cannot create four.mkv: %s", err.Error())
+	}
+
+	scanOnce(t, s, fileQ, dir)
+
+	if st = scanOnce(t, s, fileQ, dir); st.Dirs != 4 || st.DirsSkipped != 2 {
+		t.Errorf("Recently changed directory was skipped: %#v", st)
+	}
+} // func TestScanIncremental(t *testing.T)
@@ -0,0 +1,162 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/tree/08_progress_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 17. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-17 19:02:44 krylon>
+
+package tree
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blicero/blockbuster/common"
+	"github.com/blicero/blockbuster/database"
+	"github.com/blicero/blockbuster/objects"
+)
+
+// drainProgress returns the Progress reports the Scanner has sent so far.
+func drainProgress(s *Scanner) []Progress {
+	var list []Progress
+
+	for {
+		select {
+		case p := <-s.Progress():
+			list = append(list, p)
+		default:
+			return list
+		}
+	}
+} // func drainProgress(s *Scanner) []Progress
+
+func TestScanProgress(t *testing.T) {
+	var (
+		err   error
+		s     *Scanner
+		list  []Progress
+		fileQ = make(chan *objects.File, 16)
+		dir   = filepath.Join(common.BaseDir, "progress")
+	)
+
+	for i := 1; i <= 3; i++ {
+		var path = filepath.Join(dir, fmt.Sprintf("sub%d", i), "video.mkv")
+		if err = mkVideo(path, path, minSize); err != nil {
+			t.Fatalf("Cannot create %s: %s", path, err.Error())
+		}
+	}
+
+	if s, err = NewScanner(fileQ); err != nil {
+		t.Fatalf("Cannot create Scanner: %s", err.Error())
+	}
+
+	scanOnce(t, s, fileQ, dir)
+
+	if list = drainProgress(s); len(list) < 2 {
+		t.Fatalf("Expected at least two Progress reports, got %d", len(list))
+	} else if list[0].Done || list[0].Fraction() != -1 {
+		t.Errorf("Unexpected first Progress report: %#v", list[0])
+	}
+
+	var last = list[len(list)-1]
+
+	if !last.Done || last.Fraction() != 1 || last.Stats.Dirs != 4 || last.Stats.FilesNew != 3 {
+		t.Errorf("Unexpected last Progress report: %#v", last)
+	}
+
+	// Now we know how many directories there are.
+	scanOnce(t, s, fileQ, dir)
+
+	if list = drainProgress(s); len(list) < 2 {
+		t.Fatalf("Expected at least two Progress reports, got %d", len(list))
+	} else if list[0].Expected != 4 {
+		t.Errorf("Unexpected number of expected directories: %d (expected 4)",
+			list[0].Expected)
+	} else if f := list[0].Fraction(); f <= 0 || f >= 1 {
+		t.Errorf("Unexpected Fraction for first Progress report: %f", f)
+	}
+} // func TestScanProgress(t *testing.T)
+
+func TestScanCancel(t *testing.T) {
+	var (
+		err    error
+		s      *Scanner
+		db     *database.Database
+		folder *objects.Folder
+		files  []objects.File
+		runs   []objects.ScanRun
+		stats  []ScanStats
+		fileQ  = make(chan *objects.File)
+		dir    = filepath.Join(common.BaseDir, "cancel")
+	)
+
+	for i := 1; i <= 10; i++ {
+		var path = filepath.Join(dir, fmt.Sprintf("sub%02d", i), "video.mkv")
+		if err = mkVideo(path, path, minSize); err != nil {
+			t.Fatalf("Cannot create %s: %s", path, err.Error())
+		}
+	}
+
+	if s, err = NewScanner(fileQ); err != nil {
+		t.Fatalf("Cannot create Scanner: %s", err.Error())
+	}
+
+	// Nobody takes Files off fileQ, so the scan gets stuck after the
+	// first File.
+	s.ScanPath(context.Background(), dir)
+	waitFile(t, fileQ, filepath.Join(dir, "sub01", "video.mkv"))
+	s.Cancel()
+
+	for s.Active() {
+		select {
+		case <-fileQ:
+		case <-time.After(time.Millisecond * 10):
+		}
+	}
+
+	if stats = s.Stats(); len(stats) != 1 {
+		t.Fatalf("Expected statistics for one scan, got %d", len(stats))
+	} else if stats[0].Status != objects.ScanCancelled {
+		t.Errorf("Unexpected status of cancelled scan: %s", stats[0].Status)
+	} else if stats[0].FilesNew == 10 {
+		t.Error("Cancelled scan has looked at all Files")
+	}
+
+	// A scan that is cancelled before it has started must not mark any
+	// Files as missing.
+	var ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	s.scanFolder(ctx, dir)
+
+	if stats = s.Stats(); len(stats) != 1 || stats[0].Status != objects.ScanCancelled {
+		t.Errorf("Unexpected statistics for cancelled scan: %#v", stats)
+	} else if db, err = database.Open(common.DbPath); err != nil {
+		t.Fatalf("Cannot open database: %s", err.Error())
+	}
+
+	defer db.Close() // nolint: errcheck
+
+	if folder, err = db.FolderGetByPath(dir); err != nil || folder == nil {
+		t.Fatalf("Cannot look up Folder %s: %v", dir, err)
+	} else if files, err = db.FileGetByFolder(folder); err != nil {
+		t.Fatalf("Cannot get Files in Folder %s: %s", dir, err.Error())
+	} else if runs, err = db.ScanRunGetByFolder(folder, 10); err != nil {
+		t.Fatalf("Cannot get ScanRuns of Folder %s: %s", dir, err.Error())
+	} else if len(runs) != 2 {
+		t.Errorf("Unexpected number of ScanRuns: %d (expected 2)", len(runs))
+	}
+
+	for _, f := range files {
+		if f.IsMissing() {
+			t.Errorf("File %s was marked as missing", f.Path)
+		}
+	}
+
+	for _, r := range runs {
+		if r.Status != objects.ScanCancelled {
+			t.Errorf("Unexpected status of ScanRun %d: %s", r.ID, r.Status)
+		}
+	}
+} // func TestScanCancel(t *testing.T)
@@ -0,0 +1,136 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/tree/09_scheduler_test.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 17. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-17 20:41:19 krylon>
+
+package tree
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blicero/blockbuster/common"
+	"github.com/blicero/blockbuster/objects"
+)
+
+// mkFolders creates directories with three videos each below dir.
+func mkFolders(t *testing.T, dir string, names ...string) []string {
+	var paths = make([]string, len(names))
+
+	for idx, name := range names {
+		paths[idx] = filepath.Join(dir, name)
+
+		for i := 1; i <= 3; i++ {
+			var path = filepath.Join(paths[idx], fmt.Sprintf("video%d.mkv", i))
+			if err := mkVideo(path, path, minSize); err != nil {
+				t.Fatalf("Cannot create %s: %s", path, err.Error())
+			}
+		}
+	}
+
+	return paths
+} // func mkFolders(t *testing.T, dir string, names ...string) []string
+
+// waitIdle takes Files off fileQ until the Scanner is done.
+func waitIdle(s *Scanner, fileQ <-chan *objects.File) {
+	for s.Active() {
+		select {
+		case <-fileQ:
+		case <-time.After(time.Millisecond * 10):
+		}
+	}
+} // func waitIdle(s *Scanner, fileQ <-chan *objects.File)
+
+// queueState returns the number of running and waiting scan jobs.
+func queueState(s *Scanner) (int, int) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.running, len(s.jobs)
+} // func queueState(s *Scanner) (int, int)
+
+func TestScheduler(t *testing.T) {
+	var (
+		err              error
+		s                *Scanner
+		stats            []ScanStats
+		running, waiting int
+		fileQ            = make(chan *objects.File)
+		dir              = filepath.Join(common.BaseDir, "scheduler")
+		paths            = mkFolders(t, dir, "one", "two")
+	)
+
+	if s, err = NewScanner(fileQ); err != nil {
+		t.Fatalf("Cannot create Scanner: %s", err.Error())
+	}
+
+	// Nobody takes Files off fileQ, so the first scan gets stuck. Both
+	// Folders live on the same device, so the second one has to wait.
+	s.ScanPath(context.Background(), paths...)
+
+	if running, waiting = queueState(s); running != 1 || waiting != 1 {
+		t.Fatalf("Expected one running and one waiting job, got %d/%d",
+			running,
+			waiting)
+	}
+
+	s.ScanPath(context.Background(), paths[0], paths[1]+string(filepath.Separator))
+
+	if running, waiting = queueState(s); running != 1 || waiting != 1 {
+		t.Errorf("Duplicate scan jobs were not coalesced: %d running, %d waiting",
+			running,
+			waiting)
+	}
+
+	s.SetWalkersPerDevice(2)
+
+	if running, waiting = queueState(s); running != 2 || waiting != 0 {
+		t.Errorf("Expected two running jobs, got %d/%d",
+			running,
+			waiting)
+	}
+
+	waitIdle(s, fileQ)
+
+	if stats = s.Stats(); len(stats) != 2 {
+		t.Errorf("Expected statistics for two scans, got %d", len(stats))
+	}
+
+	for _, st := range stats {
+		if st.FilesNew != 3 || st.Status != objects.ScanComplete {
+			t.Errorf("Unexpected statistics: %#v", st)
+		}
+	}
+
+	// Cancel also forgets the jobs that are waiting.
+	paths = mkFolders(t, dir, "three", "four")
+	s.SetWalkersPerDevice(1)
+	s.ScanPath(context.Background(), paths...)
+	s.Cancel()
+
+	if running, waiting = queueState(s); waiting != 0 {
+		t.Errorf("Cancel left %d jobs in the queue", waiting)
+	}
+
+	waitIdle(s, fileQ)
+
+	if stats = s.Stats(); len(stats) != 1 {
+		t.Errorf("Expected statistics for one scan, got %d", len(stats))
+	} else if stats[0].Folder != paths[0] || stats[0].Status != objects.ScanCancelled {
+		t.Errorf("Unexpected statistics: %#v", stats[0])
+	}
+
+	// There are never more than maxWalkers walkers in total.
+	s.SetWalkersPerDevice(maxWalkers + 2)
+
+	s.lock.Lock()
+	if s.perDevice != maxWalkers {
+		t.Errorf("Walkers per device were not limited to %d: %d",
+			maxWalkers,
+			s.perDevice)
+	}
+	s.lock.Unlock()
+} // func TestScheduler(t *testing.T)
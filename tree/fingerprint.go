@@ -0,0 +1,68 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/tree/fingerprint.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 31. 08. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-08-31 19:26:50 krylon>
+
+package tree
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// fpChunkSize is the size of the chunks at the beginning and the end of a
+// file that go into its fingerprint.
+const fpChunkSize = 64 * 1024
+
+// fingerprint computes a cheap fingerprint of a file's content, the same
+// way OpenSubtitles does: The file size plus the sum of all 64 bit words in
+// the first and the last 64 KiB of the file.
+// It is not a cryptographic hash by any stretch, but it only has to read
+// 128 KiB, no matter how large the file is, and it is good enough to
+// recognize a video file that has been moved or renamed.
+func fingerprint(path string) (string, error) {
+	var (
+		err   error
+		fh    *os.File
+		info  os.FileInfo
+		size  int64
+		chunk int64
+		hash  uint64
+		buf   = make([]byte, fpChunkSize)
+	)
+
+	if fh, err = os.Open(path); err != nil {
+		return "", err
+	}
+
+	defer fh.Close() // nolint: errcheck
+
+	if info, err = fh.Stat(); err != nil {
+		return "", err
+	}
+
+	size = info.Size()
+	hash = uint64(size)
+
+	if chunk = fpChunkSize; size < chunk {
+		chunk = size
+	}
+
+	for _, offset := range []int64{0, size - chunk} {
+		if _, err = fh.ReadAt(buf[:chunk], offset); err != nil {
+			return "", fmt.Errorf("Cannot read %d bytes at offset %d from %s: %s",
+				chunk,
+				offset,
+				path,
+				err.Error())
+		}
+
+		for i := int64(0); i+8 <= chunk; i += 8 {
+			hash += binary.LittleEndian.Uint64(buf[i:])
+		}
+	}
+
+	return fmt.Sprintf("%016x", hash), nil
+} // func fingerprint(path string) (string, error)
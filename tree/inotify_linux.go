@@ -0,0 +1,153 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/tree/inotify_linux.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 16. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-16 23:58:41 krylon>
+
+//go:build linux
+// +build linux
+
+package tree
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// These are the events we care about. A file that is being written to
+// keeps sending IN_MODIFY, which is what lets us wait for it to settle.
+const inotifyMask = syscall.IN_CREATE |
+	syscall.IN_CLOSE_WRITE |
+	syscall.IN_MODIFY |
+	syscall.IN_MOVED_FROM |
+	syscall.IN_MOVED_TO |
+	syscall.IN_DELETE |
+	syscall.IN_DELETE_SELF |
+	syscall.IN_ONLYDIR
+
+// Values of f_type from statfs(2) for file systems that do not send
+// inotify events for changes made by other machines.
+var remoteMagic = map[int64]string{
+	0x6969:     "NFS",
+	0x517b:     "SMB",
+	0xff534d42: "CIFS",
+	0xfe534d42: "SMB2",
+	0x65735546: "FUSE",
+	0x01021997: "9P",
+}
+
+// inotify wraps an inotify instance. We open the file descriptor in
+// non-blocking mode and hand it to the os package, so reading from it goes
+// through the runtime's poller, and closing it wakes up a pending read.
+type inotify struct {
+	fh   *os.File
+	lock sync.Mutex
+	dirs map[int32]string
+	wds  map[string]int32
+}
+
+func newInotify() (*inotify, error) {
+	var fd, err = syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+
+	return &inotify{
+		fh:   os.NewFile(uintptr(fd), "inotify"),
+		dirs: make(map[int32]string),
+		wds:  make(map[string]int32),
+	}, nil
+} // func newInotify() (*inotify, error)
+
+// add watches a single directory.
+func (in *inotify) add(dir string) error {
+	var wd, err = syscall.InotifyAddWatch(int(in.fh.Fd()), dir, inotifyMask)
+
+	if err != nil {
+		return &os.PathError{Op: "inotify_add_watch", Path: dir, Err: err}
+	}
+
+	in.lock.Lock()
+	in.dirs[int32(wd)] = dir
+	in.wds[dir] = int32(wd)
+	in.lock.Unlock()
+
+	return nil
+} // func (in *inotify) add(dir string) error
+
+// forget drops a directory the kernel no longer watches, because it was
+// deleted or the watch was removed.
+func (in *inotify) forget(wd int32) {
+	in.lock.Lock()
+	delete(in.wds, in.dirs[wd])
+	delete(in.dirs, wd)
+	in.lock.Unlock()
+} // func (in *inotify) forget(wd int32)
+
+// read blocks until events are available and returns them.
+func (in *inotify) read() ([]event, error) {
+	var (
+		err    error
+		cnt    int
+		events []event
+		buf    [64 * (syscall.SizeofInotifyEvent + syscall.NAME_MAX + 1)]byte
+	)
+
+	if cnt, err = in.fh.Read(buf[:]); err != nil {
+		return nil, err
+	}
+
+	for off := 0; off+syscall.SizeofInotifyEvent <= cnt; {
+		var (
+			raw  = (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			name = buf[off+syscall.SizeofInotifyEvent : off+syscall.SizeofInotifyEvent+int(raw.Len)]
+			ev   = event{dir: raw.Mask&syscall.IN_ISDIR != 0}
+		)
+
+		off += syscall.SizeofInotifyEvent + int(raw.Len)
+
+		if raw.Mask&syscall.IN_Q_OVERFLOW != 0 {
+			events = append(events, event{overflow: true})
+			continue
+		} else if raw.Mask&syscall.IN_IGNORED != 0 {
+			in.forget(raw.Wd)
+			continue
+		}
+
+		in.lock.Lock()
+		ev.path = in.dirs[raw.Wd]
+		in.lock.Unlock()
+
+		if ev.path == "" {
+			continue
+		} else if raw.Len > 0 {
+			ev.path = filepath.Join(ev.path, string(bytes.TrimRight(name, "\x00")))
+		}
+
+		ev.gone = raw.Mask&(syscall.IN_DELETE|syscall.IN_DELETE_SELF|syscall.IN_MOVED_FROM) != 0
+		events = append(events, ev)
+	}
+
+	return events, nil
+} // func (in *inotify) read() ([]event, error)
+
+func (in *inotify) close() error {
+	return in.fh.Close()
+} // func (in *inotify) close() error
+
+// remoteFS returns the name of the file system path lives on if it is one
+// that inotify does not work on, or an empty string otherwise.
+func remoteFS(path string) string {
+	var st syscall.Statfs_t
+
+	if err := syscall.Statfs(path, &st); err != nil {
+		return ""
+	}
+
+	return remoteMagic[int64(st.Type)]
+} // func remoteFS(path string) string
@@ -0,0 +1,36 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/tree/inotify_other.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 16. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-16 23:59:30 krylon>
+
+//go:build !linux
+// +build !linux
+
+package tree
+
+import "errors"
+
+// Without inotify, every Folder is rescanned periodically.
+
+type inotify struct{}
+
+func newInotify() (*inotify, error) {
+	return nil, errors.New("inotify is only available on Linux")
+} // func newInotify() (*inotify, error)
+
+func (in *inotify) add(dir string) error {
+	return errors.New("inotify is only available on Linux")
+} // func (in *inotify) add(dir string) error
+
+func (in *inotify) read() ([]event, error) {
+	return nil, errors.New("inotify is only available on Linux")
+} // func (in *inotify) read() ([]event, error)
+
+func (in *inotify) close() error {
+	return nil
+} // func (in *inotify) close() error
+
+func remoteFS(path string) string {
+	return ""
+} // func remoteFS(path string) string
@@ -0,0 +1,110 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/tree/probe.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 03. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-03 21:37:12 krylon>
+
+package tree
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"github.com/blicero/blockbuster/database"
+	"github.com/blicero/blockbuster/media"
+	"github.com/blicero/blockbuster/objects"
+)
+
+// Probing a file only requires reading its headers, but on a NAS, that can
+// still take a moment, so we do it in the background.
+// New Files the walkers find go through newQ, and we pass them on to fileQ
+// once we have probed them, so the GUI can display their duration and
+// resolution right away. Files we already know, but have not probed, yet,
+// go through probeQ.
+
+// probeLoop probes the Files it receives from the walkers and the Scanner.
+func (s *Scanner) probeLoop() {
+	for {
+		select {
+		case f := <-s.newQ:
+			s.probeFile(f)
+			s.fileQ <- f
+		case f := <-s.probeQ:
+			s.probeFile(f)
+		}
+	}
+} // func (s *Scanner) probeLoop()
+
+// probeFile probes a File and stores the results in the Database.
+// If we do not understand the file's format, we store an empty MediaInfo,
+// so we do not try again on every scan. If we cannot read the file at all,
+// we do not store anything, the problem might go away.
+func (s *Scanner) probeFile(f *objects.File) {
+	var (
+		err     error
+		info    *objects.MediaInfo
+		pathErr *os.PathError
+		db      *database.Database
+	)
+
+	// The walkers hold on to their connections while they wait for us to
+	// take Files off their queue, so we must not wait for one of them to
+	// become available.
+	if db, err = s.pool.GetNoWait(); err != nil {
+		s.log.Printf("[ERROR] Cannot get database connection: %s\n",
+			err.Error())
+		return
+	}
+
+	defer s.pool.Put(db)
+
+	if info, err = db.MediaInfoGetByFile(f); err != nil {
+		s.log.Printf("[ERROR] Cannot look up MediaInfo for %s: %s\n",
+			f.Path,
+			err.Error())
+		return
+	} else if info != nil {
+		s.log.Printf("[TRACE] %s has been probed already\n", f.Path)
+		return
+	} else if info, err = media.Probe(f.Path); err != nil {
+		if errors.As(err, &pathErr) {
+			s.log.Printf("[ERROR] Cannot probe %s: %s\n",
+				f.Path,
+				err.Error())
+			return
+		}
+
+		s.log.Printf("[INFO] Cannot make sense of %s: %s\n",
+			f.Path,
+			err.Error())
+		info = &objects.MediaInfo{Probed: time.Now()}
+	}
+
+	if err = db.MediaInfoSet(f, info); err != nil {
+		s.log.Printf("[ERROR] Cannot store MediaInfo for %s: %s\n",
+			f.Path,
+			err.Error())
+	}
+} // func (s *Scanner) probeFile(f *objects.File)
+
+// queueUnprobed sends the Files in the given Folder that have not been
+// probed, yet, to the probe queue.
+func (s *Scanner) queueUnprobed(db *database.Database, folder *objects.Folder) {
+	var (
+		err   error
+		files []objects.File
+	)
+
+	if files, err = db.FileGetUnprobed(); err != nil {
+		s.log.Printf("[ERROR] Cannot get list of unprobed Files: %s\n",
+			err.Error())
+		return
+	}
+
+	for idx := range files {
+		if files[idx].FolderID == folder.ID {
+			s.probeQ <- &files[idx]
+		}
+	}
+} // func (s *Scanner) queueUnprobed(db *database.Database, folder *objects.Folder)
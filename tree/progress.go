@@ -0,0 +1,69 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/tree/progress.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 17. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-17 18:40:03 krylon>
+
+package tree
+
+import "time"
+
+// progressInterval is how often a walker reports its progress at most.
+const progressInterval = time.Millisecond * 250
+
+// Progress tells how a scan of a Folder is coming along.
+// Dir is the directory the walker is currently looking at. Expected is the
+// number of directories the walker expects to visit, going by the previous
+// scan of the Folder, or 0 if we do not know.
+// The last Progress of each scan has Done set.
+type Progress struct {
+	Stats    ScanStats
+	Dir      string
+	Expected int
+	Done     bool
+}
+
+// Fraction returns a rough estimate of how much of the scan is done, between
+// 0 and 1. If we cannot tell, it returns -1.
+func (p *Progress) Fraction() float64 {
+	if p.Done {
+		return 1
+	} else if p.Expected == 0 {
+		return -1
+	}
+
+	var frac = float64(p.Stats.Dirs) / float64(p.Expected)
+
+	// If the Folder has grown since the previous scan, we do not want to
+	// claim we are finished before we are.
+	if frac > 0.99 {
+		frac = 0.99
+	}
+
+	return frac
+} // func (p *Progress) Fraction() float64
+
+// report sends the walker's progress to the Scanner, unless it has done so
+// very recently. If nobody is listening, the Progress is dropped, walking
+// the Folder is more important.
+func (w *walker) report(dir string, done bool) {
+	if w.progressQ == nil {
+		return
+	} else if !done && time.Since(w.lastReport) < progressInterval {
+		return
+	}
+
+	w.lastReport = time.Now()
+
+	var p = Progress{
+		Stats:    w.stats,
+		Dir:      dir,
+		Expected: w.expected,
+		Done:     done,
+	}
+
+	select {
+	case w.progressQ <- p:
+	default:
+	}
+} // func (w *walker) report(dir string, done bool)
@@ -0,0 +1,319 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/tree/rules.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 17. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-17 11:02:37 krylon>
+
+package tree
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/blicero/blockbuster/objects"
+)
+
+// IgnoreFile is the name of the files that list patterns for files and
+// directories the Scanner should skip. They work like .gitignore files:
+// patterns are relative to the directory the file is in, and they apply to
+// everything below it.
+const IgnoreFile = ".blockbusterignore"
+
+// pattern is a single line from an ignore file or a Folder's exclude list.
+type pattern struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// compilePattern turns a gitignore-style pattern into a regular expression.
+// It returns nil for blank lines and comments.
+//
+// A pattern without a slash matches a file or directory of that name at
+// any depth, a pattern with a slash at the beginning or in the middle is
+// relative to the directory it was defined for. A trailing slash means the
+// pattern only matches directories, a leading ! re-includes what an
+// earlier pattern excluded. "*" and "?" do not match a slash, "**" does.
+func compilePattern(line string) (*pattern, error) {
+	var (
+		err error
+		p   = new(pattern)
+		buf strings.Builder
+	)
+
+	if line = strings.TrimSpace(line); line == "" || line[0] == '#' {
+		return nil, nil
+	} else if line[0] == '!' {
+		p.negate = true
+		line = line[1:]
+	} else if line[0] == '\\' {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	if line == "" {
+		return nil, nil
+	} else if strings.Contains(line, "/") {
+		buf.WriteString("^")
+		line = strings.TrimPrefix(line, "/")
+	} else {
+		buf.WriteString("^(?:.*/)?")
+	}
+
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; c {
+		case '*':
+			if i+1 < len(line) && line[i+1] == '*' {
+				i++
+				if i+1 < len(line) && line[i+1] == '/' {
+					// "**/" matches zero or more directories.
+					i++
+					buf.WriteString("(?:.*/)?")
+				} else {
+					buf.WriteString(".*")
+				}
+			} else {
+				buf.WriteString("[^/]*")
+			}
+		case '?':
+			buf.WriteString("[^/]")
+		case '[':
+			var end = strings.IndexByte(line[i+1:], ']')
+			if end < 0 {
+				buf.WriteString(`\[`)
+				continue
+			}
+			var class = line[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			buf.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(line) {
+				i++
+				buf.WriteString(regexp.QuoteMeta(line[i : i+1]))
+			}
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	buf.WriteString("$")
+
+	if p.re, err = regexp.Compile(buf.String()); err != nil {
+		return nil, fmt.Errorf("Invalid pattern %q: %s", line, err.Error())
+	}
+
+	return p, nil
+} // func compilePattern(line string) (*pattern, error)
+
+// CheckRules returns an error if the scan rules of a Folder cannot be
+// used.
+func CheckRules(minSize int64, suffixes, exclude []string) error {
+	var err error
+
+	if minSize < 0 {
+		return errors.New("The minimum size must not be negative")
+	}
+
+	for _, s := range suffixes {
+		if strings.ContainsAny(strings.TrimPrefix(s, "."), "./ \t") {
+			return fmt.Errorf("Invalid suffix %q", s)
+		}
+	}
+
+	for _, line := range exclude {
+		if _, err = compilePattern(line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+} // func CheckRules(minSize int64, suffixes, exclude []string) error
+
+// rules decides which files in a Folder the Scanner looks at, going by the
+// Folder's scan rules and the ignore files found in it.
+type rules struct {
+	log      *log.Logger
+	root     string
+	minSize  int64
+	suffixes map[string]bool
+	exclude  []*pattern
+	lock     sync.Mutex
+	ignore   map[string][]*pattern
+}
+
+func newRules(l *log.Logger, f *objects.Folder) *rules {
+	var r = &rules{
+		log:      l,
+		root:     f.Path,
+		minSize:  f.MinSize,
+		suffixes: make(map[string]bool, len(f.Suffixes)),
+		ignore:   make(map[string][]*pattern),
+	}
+
+	if r.minSize == 0 {
+		r.minSize = minSize
+	}
+
+	for _, s := range f.Suffixes {
+		r.suffixes["."+strings.ToLower(strings.TrimPrefix(s, "."))] = true
+	}
+
+	r.exclude = r.compile(f.Path, f.Exclude)
+
+	return r
+} // func newRules(l *log.Logger, f *objects.Folder) *rules
+
+// wanted returns true if path has one of the suffixes we consider.
+func (r *rules) wanted(path string) bool {
+	return suffixRe.MatchString(path) ||
+		r.suffixes[strings.ToLower(filepath.Ext(path))]
+} // func (r *rules) wanted(path string) bool
+
+// patterns returns the patterns from the ignore file in dir, reading it
+// the first time we need it.
+func (r *rules) patterns(dir string) []*pattern {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if list, ok := r.ignore[dir]; ok {
+		return list
+	}
+
+	var (
+		err   error
+		fh    *os.File
+		lines []string
+		list  []*pattern
+		path  = filepath.Join(dir, IgnoreFile)
+	)
+
+	if fh, err = os.Open(path); err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			r.log.Printf("[ERROR] Cannot open %s: %s\n",
+				path,
+				err.Error())
+		}
+		r.ignore[dir] = nil
+		return nil
+	}
+
+	defer fh.Close() // nolint: errcheck
+
+	var scanner = bufio.NewScanner(fh)
+
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	if err = scanner.Err(); err != nil {
+		r.log.Printf("[ERROR] Cannot read %s: %s\n",
+			path,
+			err.Error())
+	}
+
+	list = r.compile(path, lines)
+	r.ignore[dir] = list
+	return list
+} // func (r *rules) patterns(dir string) []*pattern
+
+// compile compiles a list of patterns, skipping blank lines and comments.
+// One broken pattern should not make us ignore all the others, so invalid
+// patterns are logged and skipped. src is only used in the log message.
+func (r *rules) compile(src string, lines []string) []*pattern {
+	var list = make([]*pattern, 0, len(lines))
+
+	for _, line := range lines {
+		var (
+			err error
+			p   *pattern
+		)
+
+		if p, err = compilePattern(line); err != nil {
+			r.log.Printf("[ERROR] %s: %s\n",
+				src,
+				err.Error())
+		} else if p != nil {
+			list = append(list, p)
+		}
+	}
+
+	return list
+} // func (r *rules) compile(src string, lines []string) []*pattern
+
+// match applies a list of patterns to path, which is relative to the
+// directory the patterns were defined for. The last pattern that matches
+// wins, so a later pattern can re-include what an earlier one excluded.
+func match(list []*pattern, rel string, dir, excluded bool) bool {
+	for _, p := range list {
+		if p.dirOnly && !dir {
+			continue
+		} else if p.re.MatchString(rel) {
+			excluded = !p.negate
+		}
+	}
+
+	return excluded
+} // func match(list []*pattern, rel string, dir, excluded bool) bool
+
+// excludedSelf checks path against the Folder's patterns and those of the
+// ignore files in the directories above it, but not against the patterns
+// that apply to those directories themselves.
+func (r *rules) excludedSelf(path string, dir bool) bool {
+	var (
+		rel, _   = filepath.Rel(r.root, path)
+		excluded = match(r.exclude, filepath.ToSlash(rel), dir, false)
+		parent   = r.root
+		dirs     = []string{parent}
+	)
+
+	for _, part := range strings.Split(filepath.Dir(rel), string(filepath.Separator)) {
+		if part != "." {
+			parent = filepath.Join(parent, part)
+			dirs = append(dirs, parent)
+		}
+	}
+
+	for _, d := range dirs {
+		rel, _ = filepath.Rel(d, path)
+		excluded = match(r.patterns(d), filepath.ToSlash(rel), dir, excluded)
+	}
+
+	return excluded
+} // func (r *rules) excludedSelf(path string, dir bool) bool
+
+// excluded returns true if path, or any directory between the Folder and
+// path, has been excluded. The walker does not descend into excluded
+// directories in the first place, the watcher needs to check the whole
+// way down.
+func (r *rules) excluded(path string, dir bool) bool {
+	var rel, err = filepath.Rel(r.root, path)
+
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false
+	}
+
+	var parts = strings.Split(rel, string(filepath.Separator))
+
+	for i := 1; i < len(parts); i++ {
+		if r.excludedSelf(filepath.Join(r.root, filepath.Join(parts[:i]...)), true) {
+			return true
+		}
+	}
+
+	return r.excludedSelf(path, dir)
+} // func (r *rules) excluded(path string, dir bool) bool
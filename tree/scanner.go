@@ -8,6 +8,8 @@
 package tree
 
 import (
+	"context"
+	"errors"
 	"log"
 	"path/filepath"
 	"regexp"
@@ -20,7 +22,10 @@ import (
 	"github.com/blicero/blockbuster/objects"
 )
 
-const poolSize = 4
+const (
+	poolSize   = 4
+	progressQD = 64
+)
 
 var suffixRe = regexp.MustCompile(suffixPattern)
 
@@ -31,6 +36,18 @@ type Scanner struct {
 	lock      sync.RWMutex
 	workerCnt int
 	fileQ     chan<- *objects.File
+	newQ      chan *objects.File
+	probeQ    chan *objects.File
+	watcher   *watcher
+	stats     []ScanStats
+	progressQ chan Progress
+	// The scheduler's state, see scheduler.go
+	scans      map[*scanJob]context.CancelFunc
+	jobs       []*scanJob
+	pending    map[string]bool
+	devRunning map[uint64]int
+	running    int
+	perDevice  int
 }
 
 // NewScanner creates a new Scanner that will handle the given list of paths.
@@ -40,7 +57,14 @@ func NewScanner(fileQ chan<- *objects.File) (*Scanner, error) {
 	var (
 		err error
 		s   = &Scanner{
-			fileQ: fileQ,
+			fileQ:      fileQ,
+			newQ:       make(chan *objects.File, cap(fileQ)),
+			probeQ:     make(chan *objects.File, cap(fileQ)),
+			progressQ:  make(chan Progress, progressQD),
+			scans:      make(map[*scanJob]context.CancelFunc),
+			pending:    make(map[string]bool),
+			devRunning: make(map[uint64]int),
+			perDevice:  defaultWalkersPerDevice,
 		}
 	)
 
@@ -52,6 +76,8 @@ func NewScanner(fileQ chan<- *objects.File) (*Scanner, error) {
 			err.Error())
 	}
 
+	go s.probeLoop()
+
 	return s, nil
 } // func NewScanner(cnt int) (*Scanner, error)
 
@@ -67,25 +93,80 @@ func (s *Scanner) delWorker() {
 	s.lock.Unlock()
 } // func (s *Scanner) delWorker()
 
-// Active returns true if the Scanner is currently scanning any Folders.
+// Active returns true if the Scanner is currently scanning any Folders, or
+// has any Folders waiting to be scanned.
 func (s *Scanner) Active() bool {
 	s.lock.RLock()
-	var active = s.workerCnt > 0
+	var active = s.workerCnt > 0 || s.running > 0 || len(s.jobs) > 0
 	s.lock.RUnlock()
 	return active
 } // func (s *Scanner) Active() bool
 
+func (s *Scanner) addStats(st *ScanStats) {
+	s.log.Printf("[INFO] %s\n", st)
+
+	s.lock.Lock()
+	s.stats = append(s.stats, *st)
+	s.lock.Unlock()
+} // func (s *Scanner) addStats(st *ScanStats)
+
+// Cancel stops all scans that are currently running, and forgets the ones
+// that are waiting to be started. Files the Scanner has already looked at
+// stay in the Database, but Files it did not get to are not marked as
+// missing.
+func (s *Scanner) Cancel() {
+	s.dropQueue()
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	s.log.Printf("[INFO] Cancel %d scans\n", len(s.scans))
+
+	for _, cancel := range s.scans {
+		cancel()
+	}
+} // func (s *Scanner) Cancel()
+
+// Progress returns the channel the Scanner reports the progress of running
+// scans on. If nobody takes them off the channel, progress reports are
+// dropped.
+func (s *Scanner) Progress() <-chan Progress {
+	return s.progressQ
+} // func (s *Scanner) Progress() <-chan Progress
+
+// Stats returns the statistics of the scans that have finished since the
+// last call.
+func (s *Scanner) Stats() []ScanStats {
+	s.lock.Lock()
+	var stats = s.stats
+	s.stats = nil
+	s.lock.Unlock()
+
+	return stats
+} // func (s *Scanner) Stats() []ScanStats
+
+// RestoreDatabase replaces the database with the given snapshot. See
+// database.Pool.Restore for the details.
+func (s *Scanner) RestoreDatabase(snapshot string) error {
+	return s.pool.Restore(snapshot)
+} // func (s *Scanner) RestoreDatabase(snapshot string) error
+
 // ScanPath tells the Scanner to inspect the given directories.
-// The scanning itself happens in separate goroutines (one per directory).
-func (s *Scanner) ScanPath(paths ...string) {
+// The scanning itself happens in the background, see scheduler.go for how
+// many directories are scanned at the same time. Directories that are
+// already waiting to be scanned, or being scanned, are skipped.
+// Cancelling ctx, or calling Cancel, stops the scans.
+func (s *Scanner) ScanPath(ctx context.Context, paths ...string) {
 	for _, path := range paths {
 		s.log.Printf("[TRACE] Adding %q to scan queue\n",
 			path)
-		go s.scanFolder(path)
+		s.enqueue(ctx, path)
 	}
-} // func (s *Scanner) ScanPath(path string)
 
-func (s *Scanner) scanFolder(path string) {
+	s.dispatch()
+} // func (s *Scanner) ScanPath(ctx context.Context, paths ...string)
+
+func (s *Scanner) scanFolder(ctx context.Context, path string) {
 	var (
 		err    error
 		db     *database.Database
@@ -117,20 +198,95 @@ func (s *Scanner) scanFolder(path string) {
 		if r = db.FolderUpdateScan(folder, time.Now()); r != nil {
 			s.log.Printf("[ERROR] Cannot update scan timestamp on Folder %q: %s\n",
 				path,
-				err.Error())
+				r.Error())
 		}
 	}()
 
-	var w = walker{
-		log:   s.log,
-		root:  folder,
-		fileQ: s.fileQ,
-		db:    db,
+	var w = &walker{
+		ctx:       ctx,
+		log:       s.log,
+		root:      folder,
+		fileQ:     s.newQ,
+		updateQ:   s.fileQ,
+		db:        db,
+		progressQ: s.progressQ,
 	}
 
-	if err = filepath.WalkDir(path, w.visitFile); err != nil {
+	w.stats.Folder = path
+	w.stats.Started = time.Now()
+
+	if err = w.loadCache(); err != nil {
+		s.log.Printf("[ERROR] Cannot load cache for Folder %q, looking at everything: %s\n",
+			path,
+			err.Error())
+		w.known, w.dirs = nil, nil
+	}
+
+	w.expected = s.expectedDirs(db, w)
+
+	err = filepath.WalkDir(path, w.visitFile)
+
+	switch {
+	case err == nil:
+		w.updateMissing() // nolint: errcheck
+		w.saveCache()     // nolint: errcheck
+	case errors.Is(err, context.Canceled):
+		// We have not seen the whole Folder, so we cannot tell which
+		// Files are missing. The directory cache from the previous
+		// scan is still good, we just have not used all of it.
+		s.log.Printf("[INFO] Scan of Folder %q was cancelled\n", path)
+		w.stats.Status = objects.ScanCancelled
+	default:
 		s.log.Printf("[ERROR] Failed to scan Folder %q: %s\n",
 			path,
 			err.Error())
+		w.stats.Status = objects.ScanFailed
+		w.stats.Errors++
+	}
+
+	w.stats.Duration = time.Since(w.stats.Started)
+	w.report(path, true)
+	s.addStats(&w.stats)
+
+	if err = db.ScanRunAdd(w.stats.run(folder)); err != nil {
+		s.log.Printf("[ERROR] Cannot record scan of Folder %q: %s\n",
+			path,
+			err.Error())
+	}
+
+	if w.stats.Status == objects.ScanComplete {
+		s.queueUnprobed(db, folder)
+		s.watchFolder(folder)
 	}
-} // func (s *Scanner) scanFolder(path string)
+} // func (s *Scanner) scanFolder(ctx context.Context, path string)
+
+// expectedDirs guesses how many directories a walker is going to visit,
+// going by the most recent complete scan of its Folder, or the directory
+// cache, if we do not have one. If we cannot tell, it returns 0.
+func (s *Scanner) expectedDirs(db *database.Database, w *walker) int {
+	var (
+		err  error
+		cnt  int
+		runs []objects.ScanRun
+	)
+
+	if runs, err = db.ScanRunGetByFolder(w.root, 10); err != nil {
+		s.log.Printf("[ERROR] Cannot get previous scans of Folder %q: %s\n",
+			w.root.Path,
+			err.Error())
+	}
+
+	for _, r := range runs {
+		if r.Status == objects.ScanComplete {
+			return r.Dirs
+		}
+	}
+
+	for path := range w.dirCache {
+		if filepath.Base(path) != IgnoreFile {
+			cnt++
+		}
+	}
+
+	return cnt
+} // func (s *Scanner) expectedDirs(db *database.Database, w *walker) int
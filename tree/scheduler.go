@@ -0,0 +1,149 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/tree/scheduler.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 17. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-17 20:14:52 krylon>
+
+package tree
+
+import (
+	"context"
+	"path/filepath"
+)
+
+// Walking several directory trees on the same disk at once makes the disk
+// seek back and forth between them, which is a lot slower than walking them
+// one after the other, especially on spinning disks and USB drives. So
+// ScanPath does not start scanning right away, it puts a job in a queue,
+// and we start the jobs as soon as the device their Folder lives on is not
+// busy.
+//
+// Each walker holds on to a database connection for the whole scan, so we
+// run fewer walkers than the Pool has connections in total, the watcher and
+// the probeLoop need one, too.
+
+const (
+	defaultWalkersPerDevice = 1
+	maxWalkers              = poolSize - 1
+)
+
+// scanJob is a request to scan a Folder.
+type scanJob struct {
+	ctx  context.Context
+	path string
+	dev  uint64
+}
+
+// SetWalkersPerDevice sets how many Folders on the same device the Scanner
+// scans at the same time. It does not affect scans that are already
+// running. No more than maxWalkers scans run at the same time in total, so
+// larger values are clamped to that.
+func (s *Scanner) SetWalkersPerDevice(n int) {
+	if n < 1 {
+		n = 1
+	} else if n > maxWalkers {
+		s.log.Printf("[WARN] Cannot run more than %d walkers at once, using %d walkers per device instead of %d\n",
+			maxWalkers,
+			maxWalkers,
+			n)
+		n = maxWalkers
+	}
+
+	s.lock.Lock()
+	s.perDevice = n
+	s.lock.Unlock()
+
+	s.dispatch()
+} // func (s *Scanner) SetWalkersPerDevice(n int)
+
+// enqueue adds a scan job to the queue, unless the Folder is already
+// waiting to be scanned or being scanned.
+func (s *Scanner) enqueue(ctx context.Context, path string) {
+	var (
+		err error
+		job = &scanJob{
+			ctx:  ctx,
+			path: filepath.Clean(path),
+		}
+	)
+
+	if job.dev, err = deviceOf(job.path); err != nil {
+		// We will find out what's wrong when we scan it.
+		s.log.Printf("[ERROR] Cannot find the device %s lives on: %s\n",
+			job.path,
+			err.Error())
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.pending[job.path] {
+		s.log.Printf("[DEBUG] %s is already queued or being scanned\n",
+			job.path)
+		return
+	}
+
+	s.pending[job.path] = true
+	s.jobs = append(s.jobs, job)
+} // func (s *Scanner) enqueue(ctx context.Context, path string)
+
+// dispatch starts the queued jobs for which there is a free walker. Jobs
+// are started in the order they were queued, but a job whose device is busy
+// does not hold up the jobs behind it.
+func (s *Scanner) dispatch() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var waiting = s.jobs[:0]
+
+	for _, job := range s.jobs {
+		if job.ctx.Err() != nil {
+			s.log.Printf("[DEBUG] Scan of %s was cancelled before it started\n",
+				job.path)
+			delete(s.pending, job.path)
+		} else if s.running >= maxWalkers || s.devRunning[job.dev] >= s.perDevice {
+			waiting = append(waiting, job)
+		} else {
+			var ctx, cancel = context.WithCancel(job.ctx)
+			s.running++
+			s.devRunning[job.dev]++
+			s.scans[job] = cancel
+			go s.runJob(ctx, job)
+		}
+	}
+
+	// Do not keep the jobs we have started alive through the backing
+	// array.
+	for i := len(waiting); i < len(s.jobs); i++ {
+		s.jobs[i] = nil
+	}
+
+	s.jobs = waiting
+} // func (s *Scanner) dispatch()
+
+// runJob scans a Folder and starts the next job once it is done.
+func (s *Scanner) runJob(ctx context.Context, job *scanJob) {
+	s.scanFolder(ctx, job.path)
+
+	s.lock.Lock()
+	s.scans[job]()
+	delete(s.scans, job)
+	s.running--
+	s.devRunning[job.dev]--
+	delete(s.pending, job.path)
+	s.lock.Unlock()
+
+	s.dispatch()
+} // func (s *Scanner) runJob(ctx context.Context, job *scanJob)
+
+// dropQueue forgets all scan jobs that have not started, yet.
+func (s *Scanner) dropQueue() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, job := range s.jobs {
+		delete(s.pending, job.path)
+	}
+
+	s.jobs = nil
+} // func (s *Scanner) dropQueue()
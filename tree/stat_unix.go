@@ -0,0 +1,48 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/tree/stat_unix.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 17. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-17 15:03:44 krylon>
+
+//go:build !windows
+// +build !windows
+
+package tree
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+
+	"github.com/blicero/blockbuster/objects"
+)
+
+// statOf returns the size, modification time and inode of a file.
+func statOf(info fs.FileInfo) objects.FileStat {
+	var st = objects.FileStat{
+		Size:  info.Size(),
+		Mtime: info.ModTime(),
+	}
+
+	if sys, ok := info.Sys().(*syscall.Stat_t); ok {
+		st.Inode = uint64(sys.Ino) // nolint: unconvert
+	}
+
+	return st
+} // func statOf(info fs.FileInfo) objects.FileStat
+
+// deviceOf returns the ID of the device the file at path lives on.
+func deviceOf(path string) (uint64, error) {
+	var (
+		err  error
+		info fs.FileInfo
+	)
+
+	if info, err = os.Stat(path); err != nil {
+		return 0, err
+	} else if sys, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(sys.Dev), nil // nolint: unconvert
+	}
+
+	return 0, nil
+} // func deviceOf(path string) (uint64, error)
@@ -0,0 +1,34 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/tree/stat_windows.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 17. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-17 15:04:10 krylon>
+
+//go:build windows
+// +build windows
+
+package tree
+
+import (
+	"io/fs"
+	"os"
+
+	"github.com/blicero/blockbuster/objects"
+)
+
+// statOf returns the size and modification time of a file. There are no
+// inodes on Windows.
+func statOf(info fs.FileInfo) objects.FileStat {
+	return objects.FileStat{
+		Size:  info.Size(),
+		Mtime: info.ModTime(),
+	}
+} // func statOf(info fs.FileInfo) objects.FileStat
+
+// deviceOf returns the ID of the device the file at path lives on. We do
+// not know how to tell on Windows, so all files live on the same device,
+// as far as we are concerned.
+func deviceOf(path string) (uint64, error) {
+	var _, err = os.Stat(path)
+	return 0, err
+} // func deviceOf(path string) (uint64, error)
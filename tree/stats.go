@@ -0,0 +1,71 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/tree/stats.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 17. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-17 15:12:30 krylon>
+
+package tree
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/blicero/blockbuster/objects"
+)
+
+// ScanStats counts what the Scanner did while scanning a Folder, and how
+// much of it it could skip, because nothing had changed since the previous
+// scan.
+type ScanStats struct {
+	Folder       string
+	Started      time.Time
+	Duration     time.Duration
+	Status       objects.ScanStatus
+	Dirs         int // Directories in the Folder
+	DirsSkipped  int // Directories we did not read, because they had not changed
+	Files        int // Video files in the Folder
+	FilesSkipped int // Files we did not look at, because they had not changed
+	FilesNew     int
+	FilesChanged int
+	Errors       int // Files and directories we could not read
+}
+
+func (st *ScanStats) String() string {
+	var verb = "Scanned"
+
+	switch st.Status {
+	case objects.ScanCancelled:
+		verb = "Cancelled scan of"
+	case objects.ScanFailed:
+		verb = "Failed to scan"
+	}
+
+	return fmt.Sprintf("%s %s in %s: %d of %d directories and %d of %d files unchanged, %d new, %d changed, %d errors",
+		verb,
+		st.Folder,
+		st.Duration.Round(time.Millisecond),
+		st.DirsSkipped,
+		st.Dirs,
+		st.FilesSkipped,
+		st.Files,
+		st.FilesNew,
+		st.FilesChanged,
+		st.Errors)
+} // func (st *ScanStats) String() string
+
+// run turns the statistics into a ScanRun we can store in the Database.
+func (st *ScanStats) run(f *objects.Folder) *objects.ScanRun {
+	return &objects.ScanRun{
+		FolderID:     f.ID,
+		Start:        st.Started,
+		End:          st.Started.Add(st.Duration),
+		Status:       st.Status,
+		Dirs:         st.Dirs,
+		DirsSkipped:  st.DirsSkipped,
+		Files:        st.Files,
+		FilesSkipped: st.FilesSkipped,
+		FilesNew:     st.FilesNew,
+		FilesChanged: st.FilesChanged,
+		Errors:       st.Errors,
+	}
+} // func (st *ScanStats) run(f *objects.Folder) *objects.ScanRun
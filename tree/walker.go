@@ -7,37 +7,270 @@
 package tree
 
 import (
+	"context"
 	"io/fs"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/blicero/blockbuster/database"
+	"github.com/blicero/blockbuster/nfo"
 	"github.com/blicero/blockbuster/objects"
 	"github.com/blicero/krylib"
 )
 
 const (
 	minSize       = 1024 * 1024 * 32 // 32 MB, minimum size for files to consider
-	suffixPattern = "(?i)[.](?:avi|mp4|mpg|asf|avi|flv|m4v|mkv|mov|mpg|ogm|ogv|sfv|webm|wmv)$"
+	suffixPattern = "(?i)[.](?:avi|mp4|mpg|asf|avi|flv|m4v|mkv|mov|mpg|ogm|ogv|webm|wmv)$"
 )
 
 // The walker struct handles the state required to scan a folder.
 
 type walker struct {
-	log   *log.Logger
-	root  *objects.Folder
-	fileQ chan<- *objects.File
-	db    *database.Database
+	ctx     context.Context
+	log     *log.Logger
+	root    *objects.Folder
+	fileQ   chan<- *objects.File
+	updateQ chan<- *objects.File
+	db      *database.Database
+	rules   *rules
+	seen    map[string]bool
+	errDirs []string
+	// The following are only used when the walker scans a whole Folder,
+	// see loadCache.
+	known    map[string]*objects.File
+	byDir    map[string][]*objects.File
+	dirCache map[string]time.Time
+	children map[string][]string
+	dirs     map[string]time.Time
+	forced   []string
+	failed   bool
+	stats    ScanStats
+	// Progress reports go to progressQ, if we have one. expected is
+	// the number of directories the previous scan has seen.
+	progressQ  chan<- Progress
+	lastReport time.Time
+	expected   int
 }
 
+// We do not trust the modification time of a directory that has changed
+// very recently, there might be more changes within the same tick of the
+// file system's clock. Such directories are recorded with a modification
+// time of unsettled, so the next scan reads them again. We cannot leave
+// them out entirely, or the next scan would not visit them at all if their
+// parent is unchanged.
+const dirSettleTime = time.Minute
+
+var unsettled = time.Unix(0, 0)
+
+// loadCache fetches the Files in the walker's Folder and the modification
+// times of its directories from the previous scan. With those, the walker
+// can skip the directories that have not changed, and the Files whose size,
+// mtime and inode are still the same.
+//
+// A directory's mtime changes when files are added to it, removed from it,
+// or renamed, but not when a file in it is modified, or when anything
+// changes in its subdirectories. So we still visit every directory, but we
+// do not need to read the ones that have not changed, we already know what
+// is in them.
+func (w *walker) loadCache() error {
+	var (
+		err   error
+		files []objects.File
+	)
+
+	if files, err = w.db.FileGetByFolder(w.root); err != nil {
+		w.log.Printf("[ERROR] Cannot get Files in Folder %s: %s\n",
+			w.root.Path,
+			err.Error())
+		return err
+	} else if w.dirCache, err = w.db.DirCacheGet(w.root); err != nil {
+		w.log.Printf("[ERROR] Cannot get directory cache for Folder %s: %s\n",
+			w.root.Path,
+			err.Error())
+		return err
+	}
+
+	w.known = make(map[string]*objects.File, len(files))
+	w.byDir = make(map[string][]*objects.File)
+	w.children = make(map[string][]string)
+	w.dirs = make(map[string]time.Time)
+
+	for idx := range files {
+		var (
+			f   = &files[idx]
+			dir = filepath.Dir(f.Path)
+		)
+
+		w.known[f.Path] = f
+		w.byDir[dir] = append(w.byDir[dir], f)
+	}
+
+	for path := range w.dirCache {
+		if path != w.root.Path && filepath.Base(path) != IgnoreFile {
+			var parent = filepath.Dir(path)
+			w.children[parent] = append(w.children[parent], path)
+		}
+	}
+
+	return nil
+} // func (w *walker) loadCache() error
+
+// saveCache stores the modification times of the directories we have
+// seen, for the next scan.
+// If we had trouble reading any part of the Folder, we forget them
+// instead, and the next scan will look at everything.
+func (w *walker) saveCache() error {
+	if w.dirs == nil {
+		return nil
+	} else if w.failed {
+		return w.db.DirCacheClear(w.root)
+	}
+
+	return w.db.DirCacheSet(w.root, w.dirs)
+} // func (w *walker) saveCache() error
+
+// inForced returns true if path is below a directory whose ignore file has
+// changed. We have to look at everything below such a directory again.
+func (w *walker) inForced(path string) bool {
+	for _, dir := range w.forced {
+		if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	return false
+} // func (w *walker) inForced(path string) bool
+
+// visitDir decides if we need to read a directory. If it has not changed
+// since the previous scan, we skip it, and visit its subdirectories
+// ourselves.
+func (w *walker) visitDir(path string, d fs.DirEntry) error {
+	var (
+		err     error
+		info    fs.FileInfo
+		mtime   time.Time
+		ignPath = filepath.Join(path, IgnoreFile)
+	)
+
+	w.stats.Dirs++
+	w.report(path, false)
+
+	if w.dirs == nil {
+		return nil
+	} else if info, err = d.Info(); err != nil {
+		w.log.Printf("[ERROR] Cannot read Info for %s: %s\n",
+			path,
+			err.Error())
+		w.failed = true
+		w.stats.Errors++
+		return nil
+	}
+
+	mtime = info.ModTime()
+
+	// Changes to an ignore file do not necessarily change the mtime of its
+	// directory, so we keep track of them separately.
+	if info, err = os.Stat(ignPath); err == nil {
+		w.dirs[ignPath] = info.ModTime()
+	}
+
+	if cached, ok := w.dirCache[ignPath]; ok != (err == nil) || (ok && !cached.Equal(w.dirs[ignPath])) {
+		w.log.Printf("[DEBUG] %s has changed, rescanning %s\n",
+			ignPath,
+			path)
+		w.forced = append(w.forced, path)
+	}
+
+	if time.Since(mtime) >= dirSettleTime {
+		w.dirs[path] = mtime
+	} else {
+		w.dirs[path] = unsettled
+	}
+
+	if cached, ok := w.dirCache[path]; !ok || !cached.Equal(mtime) || w.inForced(path) {
+		return nil
+	}
+
+	w.log.Printf("[TRACE] Skip %q -- unchanged\n", path)
+	w.stats.DirsSkipped++
+
+	// Modifying a file, or replacing it by renaming another file over it,
+	// does not change the mtime of its directory on many file systems, so
+	// we still need to look at the Files we know. Lstat is cheap enough,
+	// even over SMB, and addFile only goes further if the stat data has
+	// changed. If a File is gone, we do not mark it as seen, and
+	// updateMissing takes care of it.
+	for _, f := range w.byDir[path] {
+		if f.IsMissing() {
+			continue
+		} else if info, err = os.Lstat(f.Path); err != nil {
+			if !os.IsNotExist(err) {
+				w.log.Printf("[ERROR] Cannot stat %s: %s\n",
+					f.Path,
+					err.Error())
+				w.markSeen(f.Path)
+				w.stats.Errors++
+			}
+			continue
+		} else if !info.Mode().IsRegular() {
+			w.log.Printf("[TRACE] Skip %q -- not a regular file.\n", f.Path)
+			continue
+		} else if err = w.addFile(f.Path, info); err != nil {
+			return err
+		}
+	}
+
+	for _, child := range w.children[path] {
+		if err = filepath.WalkDir(child, w.visitFile); err != nil {
+			return err
+		}
+	}
+
+	return fs.SkipDir
+} // func (w *walker) visitDir(path string, d fs.DirEntry) error
+
+// getRules returns the walker's scan rules. If the walker was not given
+// any, it uses the ones of its Folder.
+func (w *walker) getRules() *rules {
+	if w.rules == nil {
+		w.rules = newRules(w.log, w.root)
+	}
+
+	return w.rules
+} // func (w *walker) getRules() *rules
+
 func (w *walker) visitFile(path string, d fs.DirEntry, incoming error) error {
-	if incoming != nil {
+	if w.ctx != nil && w.ctx.Err() != nil {
+		return w.ctx.Err()
+	} else if incoming != nil {
 		w.log.Printf("[ERROR] Incoming error when visiting %s: %s\n",
 			path,
 			incoming.Error())
+		// We cannot tell if the files below path are still there, so we
+		// must not mark them as missing.
+		w.errDirs = append(w.errDirs, path)
+		w.failed = true
+		w.stats.Errors++
 		return fs.SkipDir
-	} else if !suffixRe.MatchString(path) {
+	} else if d.IsDir() {
+		if path != w.root.Path && w.getRules().excludedSelf(path, true) {
+			w.log.Printf("[TRACE] Skip %q -- excluded\n", path)
+			// Files we know below an excluded directory are not
+			// missing, we just do not look at them anymore.
+			w.errDirs = append(w.errDirs, path)
+			return fs.SkipDir
+		}
+		return w.visitDir(path, d)
+	} else if !w.getRules().wanted(path) {
 		w.log.Printf("[TRACE] Skip %q -- suffix\n", path)
 		return nil
+	} else if w.getRules().excludedSelf(path, false) {
+		w.log.Printf("[TRACE] Skip %q -- excluded\n", path)
+		w.markSeen(path)
+		return nil
 	} else if !d.Type().IsRegular() {
 		w.log.Printf("[TRACE] Skip %q -- not a regular file.\n", path)
 		return nil
@@ -45,7 +278,6 @@ func (w *walker) visitFile(path string, d fs.DirEntry, incoming error) error {
 
 	var (
 		err  error
-		file *objects.File
 		info fs.FileInfo
 	)
 
@@ -53,15 +285,47 @@ func (w *walker) visitFile(path string, d fs.DirEntry, incoming error) error {
 		w.log.Printf("[ERROR] Cannot read Info for %s: %s\n",
 			path,
 			err.Error())
+		w.stats.Errors++
 		return err
-	} else if info.Size() < minSize {
+	}
+
+	return w.addFile(path, info)
+} // func (w *walker) visitFile(path string, d fs.DirEntry, incoming error) error
+
+// addFile looks at a video file we found while walking the Folder, or that
+// the watcher told us about. New Files are added to the Database and sent
+// to fileQ, Files that have been moved or have reappeared after going
+// missing are sent to updateQ, if we have one.
+func (w *walker) addFile(path string, info fs.FileInfo) error {
+	var (
+		err  error
+		file *objects.File
+		st   = statOf(info)
+	)
+
+	if info.Size() < w.getRules().minSize {
 		w.log.Printf("[TRACE] Skip %q -- too small (%s)\n",
 			path,
 			krylib.FmtBytes(info.Size()))
+		// The file might still be in the process of being copied. The
+		// mtime of its directory will not change when it is complete,
+		// so we must not skip the directory next time.
+		if _, ok := w.dirs[filepath.Dir(path)]; ok {
+			w.dirs[filepath.Dir(path)] = unsettled
+		}
 		return nil
 	}
 
-	if w.root.IsKnown() {
+	w.markSeen(path)
+	w.stats.Files++
+
+	if w.known != nil {
+		if file = w.known[path]; file != nil {
+			w.log.Printf("[TRACE] We already know %q\n",
+				path)
+			return w.checkKnown(file, st)
+		}
+	} else if w.root.IsKnown() {
 		if file, err = w.db.FileGetByPath(path); err != nil {
 			w.log.Printf("[ERROR] Cannot lookup File %q in Database: %s\n",
 				path,
@@ -70,18 +334,251 @@ func (w *walker) visitFile(path string, d fs.DirEntry, incoming error) error {
 		} else if file != nil {
 			w.log.Printf("[TRACE] We already know %q\n",
 				path)
-			return nil
+			return w.checkKnown(file, st)
 		}
 	}
 
-	if file, err = w.db.FileAdd(path, w.root); err != nil {
+	var fp string
+
+	if fp, err = fingerprint(path); err != nil {
+		w.log.Printf("[ERROR] Cannot compute fingerprint of %s: %s\n",
+			path,
+			err.Error())
+		w.stats.Errors++
+		return nil
+	} else if file, err = w.relink(path, fp); err != nil {
+		return err
+	} else if file != nil {
+		w.setStat(file, st)
+		w.update(file)
+		return nil
+	} else if file, err = w.db.FileAdd(path, w.root); err != nil {
 		w.log.Printf("[ERROR] Cannot add File %q to Database: %s\n",
 			path,
 			err.Error())
 		return err
+	} else if err = w.db.FileSetFingerprint(file, fp); err != nil {
+		w.log.Printf("[ERROR] Cannot set fingerprint of File %q: %s\n",
+			path,
+			err.Error())
+		return err
 	}
 
+	w.setStat(file, st)
+	w.readSidecar(file)
+	w.stats.FilesNew++
+
 	w.fileQ <- file
 
 	return nil
-} // func (w *walker) visitFile(path string, d fs.DirEntry, incoming error) error
+} // func (w *walker) addFile(path string, info fs.FileInfo) error
+
+// checkKnown looks at a File we already know. If its size, mtime or inode
+// have changed, the file has been replaced or modified, so we compute its
+// fingerprint again.
+func (w *walker) checkKnown(file *objects.File, st objects.FileStat) error {
+	var err error
+
+	if file.Stat.Equal(st) && file.Fingerprint != "" && !file.IsMissing() {
+		w.stats.FilesSkipped++
+		return nil
+	}
+
+	if file.Fingerprint == "" {
+		// Files we added before we started computing fingerprints
+		// do not have one, yet.
+		w.setFingerprint(file)
+	} else if file.Stat.Size != 0 && !file.Stat.Equal(st) {
+		// Files we added before we started keeping track of their stat
+		// data have a size of 0, there is no need to look at them
+		// again.
+		w.log.Printf("[INFO] File %s has changed\n", file.Path)
+		w.setFingerprint(file)
+		w.stats.FilesChanged++
+	}
+
+	if !file.Stat.Equal(st) {
+		w.setStat(file, st)
+	}
+
+	if file.IsMissing() {
+		w.log.Printf("[INFO] File %s has reappeared\n", file.Path)
+		if err = w.db.FileSetMissing(file, time.Time{}); err != nil {
+			w.log.Printf("[ERROR] Cannot unmark File %s as missing: %s\n",
+				file.Path,
+				err.Error())
+			return err
+		}
+		w.update(file)
+	}
+
+	return nil
+} // func (w *walker) checkKnown(file *objects.File, st objects.FileStat) error
+
+// setStat records the size, mtime and inode of a File. Errors are logged,
+// but otherwise ignored, we will look at the File again during the next
+// scan.
+func (w *walker) setStat(f *objects.File, st objects.FileStat) {
+	if err := w.db.FileSetStat(f, st); err != nil {
+		w.log.Printf("[ERROR] Cannot set stat data of File %s: %s\n",
+			f.Path,
+			err.Error())
+	}
+} // func (w *walker) setStat(f *objects.File, st objects.FileStat)
+
+// update passes a File we already knew, but that has changed, on to the
+// GUI.
+func (w *walker) update(f *objects.File) {
+	if w.updateQ != nil {
+		w.updateQ <- f
+	}
+} // func (w *walker) update(f *objects.File)
+
+func (w *walker) markSeen(path string) {
+	if w.seen == nil {
+		w.seen = make(map[string]bool)
+	}
+
+	w.seen[path] = true
+} // func (w *walker) markSeen(path string)
+
+// inErrDir returns true if path is in a directory we could not read, or
+// one that is excluded by the scan rules.
+func (w *walker) inErrDir(path string) bool {
+	for _, dir := range w.errDirs {
+		if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	return false
+} // func (w *walker) inErrDir(path string) bool
+
+// updateMissing compares the Files the Database knows in the walker's
+// Folder with the ones we saw during the walk. Files we did not see are
+// marked as missing. Files that were missing and have reappeared have been
+// unmarked by addFile already.
+// It must only be called after a complete walk of the Folder.
+func (w *walker) updateMissing() error {
+	var (
+		err   error
+		files []objects.File
+		now   = time.Now()
+	)
+
+	if files, err = w.db.FileGetByFolder(w.root); err != nil {
+		w.log.Printf("[ERROR] Cannot get Files in Folder %s: %s\n",
+			w.root.Path,
+			err.Error())
+		return err
+	}
+
+	for idx := range files {
+		var f = &files[idx]
+
+		if w.seen[f.Path] || f.IsMissing() || w.inErrDir(f.Path) {
+			continue
+		}
+
+		w.log.Printf("[INFO] File %s has gone missing\n", f.Path)
+		if err = w.db.FileSetMissing(f, now); err != nil {
+			w.log.Printf("[ERROR] Cannot mark File %s as missing: %s\n",
+				f.Path,
+				err.Error())
+			return err
+		}
+
+		w.update(f)
+	}
+
+	return nil
+} // func (w *walker) updateMissing() error
+
+// relink looks for a File with the given fingerprint whose path no longer
+// exists. If there is one, we assume it has been moved or renamed to path,
+// and we update its path in the Database, so it keeps its Tags, Actors,
+// and so on.
+// If there is no such File, relink returns nil.
+func (w *walker) relink(path, fp string) (*objects.File, error) {
+	var (
+		err   error
+		files []objects.File
+	)
+
+	if files, err = w.db.FileGetByFingerprint(fp); err != nil {
+		w.log.Printf("[ERROR] Cannot look up Files by fingerprint %s: %s\n",
+			fp,
+			err.Error())
+		return nil, err
+	}
+
+	for idx := range files {
+		var (
+			exists bool
+			f      = &files[idx]
+		)
+
+		if exists, err = krylib.Fexists(f.Path); err != nil {
+			w.log.Printf("[ERROR] Cannot check if %s exists: %s\n",
+				f.Path,
+				err.Error())
+			continue
+		} else if exists {
+			// Looks like a copy, not a move.
+			continue
+		}
+
+		w.log.Printf("[INFO] %s looks like it has been moved to %s\n",
+			f.Path,
+			path)
+
+		if err = w.db.FileMove(f, path, w.root); err != nil {
+			w.log.Printf("[ERROR] Cannot move File %s to %s: %s\n",
+				f.Path,
+				path,
+				err.Error())
+			return nil, err
+		}
+
+		return f, nil
+	}
+
+	return nil, nil
+} // func (w *walker) relink(path, fp string) (*objects.File, error)
+
+// setFingerprint computes the fingerprint of a File we already know and
+// stores it in the Database. Errors are logged, but otherwise ignored.
+func (w *walker) setFingerprint(f *objects.File) {
+	var (
+		err error
+		fp  string
+	)
+
+	if fp, err = fingerprint(f.Path); err != nil {
+		w.log.Printf("[ERROR] Cannot compute fingerprint of %s: %s\n",
+			f.Path,
+			err.Error())
+	} else if err = w.db.FileSetFingerprint(f, fp); err != nil {
+		w.log.Printf("[ERROR] Cannot set fingerprint of File %s: %s\n",
+			f.Path,
+			err.Error())
+	}
+} // func (w *walker) setFingerprint(f *objects.File)
+
+// readSidecar picks up the metadata from an .nfo file next to a new File,
+// if there is one. Errors are logged, but otherwise ignored, the File is in
+// the Database, after all.
+func (w *walker) readSidecar(f *objects.File) {
+	var (
+		err   error
+		found bool
+	)
+
+	if found, err = nfo.Import(w.db, f); err != nil {
+		w.log.Printf("[ERROR] Cannot import .nfo file for %s: %s\n",
+			f.Path,
+			err.Error())
+	} else if found {
+		w.log.Printf("[INFO] Imported .nfo file for %s\n", f.Path)
+	}
+} // func (w *walker) readSidecar(f *objects.File)
@@ -0,0 +1,471 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/tree/watch.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 16. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-17 00:41:18 krylon>
+
+package tree
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blicero/blockbuster/database"
+	"github.com/blicero/blockbuster/objects"
+)
+
+// Once the Scanner is watching, we get notified by the kernel about
+// changes in our Folders. Ripping or copying a movie takes a while, and we
+// get an event for every chunk written, so we wait until a file has been
+// quiet for a moment before we look at it.
+// On file systems where inotify does not see changes made by other
+// machines, like NFS or CIFS mounts, or if we run out of inotify watches,
+// we fall back to rescanning the Folder periodically.
+
+const (
+	watchDebounce  = time.Second * 10
+	rescanInterval = time.Minute * 30
+)
+
+// event is what the platform-specific part of the watcher tells us about
+// a change.
+type event struct {
+	path     string
+	dir      bool
+	gone     bool
+	overflow bool
+}
+
+type watcher struct {
+	s        *Scanner
+	log      *log.Logger
+	notify   *inotify
+	debounce time.Duration
+	interval time.Duration
+	lock     sync.Mutex
+	roots    map[string]*objects.Folder
+	polled   map[string]bool
+	pending  map[string]time.Time
+	rescan   map[string]time.Time
+	stopQ    chan struct{}
+}
+
+// Watch starts watching all the Folders in the Database, and all Folders
+// that are scanned later on, for new, renamed and deleted video files.
+// Changes are passed on through the Scanner's fileQ.
+func (s *Scanner) Watch() error {
+	return s.watch(watchDebounce, rescanInterval)
+} // func (s *Scanner) Watch() error
+
+func (s *Scanner) watch(debounce, interval time.Duration) error {
+	var (
+		err     error
+		db      *database.Database
+		folders []objects.Folder
+		w       = &watcher{
+			s:        s,
+			log:      s.log,
+			debounce: debounce,
+			interval: interval,
+			roots:    make(map[string]*objects.Folder),
+			polled:   make(map[string]bool),
+			pending:  make(map[string]time.Time),
+			rescan:   make(map[string]time.Time),
+			stopQ:    make(chan struct{}),
+		}
+	)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.watcher != nil {
+		return errors.New("Scanner is watching already")
+	}
+
+	db = s.pool.Get()
+	folders, err = db.FolderGetAll()
+	s.pool.Put(db)
+
+	if err != nil {
+		s.log.Printf("[ERROR] Cannot get list of Folders: %s\n",
+			err.Error())
+		return err
+	} else if w.notify, err = newInotify(); err != nil {
+		s.log.Printf("[INFO] Cannot use inotify, all Folders will be rescanned every %s: %s\n",
+			interval,
+			err.Error())
+		w.notify = nil
+	} else {
+		go w.readLoop()
+	}
+
+	for idx := range folders {
+		w.addRoot(&folders[idx])
+	}
+
+	s.watcher = w
+	go w.tickLoop()
+
+	return nil
+} // func (s *Scanner) watch(debounce, interval time.Duration) error
+
+// StopWatching stops watching the Folders.
+func (s *Scanner) StopWatching() {
+	s.lock.Lock()
+	var w = s.watcher
+	s.watcher = nil
+	s.lock.Unlock()
+
+	if w == nil {
+		return
+	}
+
+	close(w.stopQ)
+	if w.notify != nil {
+		w.notify.close() // nolint: errcheck,gosec
+	}
+} // func (s *Scanner) StopWatching()
+
+// watchFolder adds a Folder to the watch list, if we are watching.
+func (s *Scanner) watchFolder(f *objects.Folder) {
+	s.lock.RLock()
+	var w = s.watcher
+	s.lock.RUnlock()
+
+	if w != nil {
+		w.addRoot(f)
+	}
+} // func (s *Scanner) watchFolder(f *objects.Folder)
+
+// addRoot starts watching a Folder and all directories below it.
+func (w *watcher) addRoot(f *objects.Folder) {
+	w.lock.Lock()
+	if _, ok := w.roots[f.Path]; ok {
+		w.lock.Unlock()
+		return
+	}
+	w.roots[f.Path] = f
+	w.lock.Unlock()
+
+	if w.notify == nil {
+		w.poll(f.Path)
+		return
+	} else if fsType := remoteFS(f.Path); fsType != "" {
+		w.log.Printf("[INFO] %s is on a %s file system, it will be rescanned every %s\n",
+			f.Path,
+			fsType,
+			w.interval)
+		w.poll(f.Path)
+		return
+	} else if err := w.addTree(f.Path, nil); err != nil {
+		w.log.Printf("[ERROR] Cannot watch %s, it will be rescanned every %s: %s\n",
+			f.Path,
+			w.interval,
+			err.Error())
+		w.poll(f.Path)
+	}
+} // func (w *watcher) addRoot(f *objects.Folder)
+
+func (w *watcher) poll(root string) {
+	w.lock.Lock()
+	w.polled[root] = true
+	w.lock.Unlock()
+} // func (w *watcher) poll(root string)
+
+// addTree watches dir and all directories below it. If found is not nil,
+// the files in the tree are passed to it, which lets us catch files that
+// were created in a new directory before we started watching it.
+func (w *watcher) addTree(dir string, found func(path string)) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// The directory may have vanished again already, the next
+			// scan will sort that out.
+			if path == dir {
+				return err
+			}
+			w.log.Printf("[ERROR] Cannot watch %s: %s\n",
+				path,
+				err.Error())
+			return fs.SkipDir
+		} else if d.IsDir() {
+			return w.notify.add(path)
+		} else if found != nil {
+			found(path)
+		}
+
+		return nil
+	})
+} // func (w *watcher) addTree(dir string, found func(path string)) error
+
+// rootOf returns the Folder path belongs to, or nil if it does not belong
+// to any of them. If Folders are nested, the innermost one wins.
+func (w *watcher) rootOf(path string) *objects.Folder {
+	var root *objects.Folder
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for p, f := range w.roots {
+		if (path == p || strings.HasPrefix(path, p+string(filepath.Separator))) &&
+			(root == nil || len(p) > len(root.Path)) {
+			root = f
+		}
+	}
+
+	return root
+} // func (w *watcher) rootOf(path string) *objects.Folder
+
+func (w *watcher) touch(path string) {
+	w.lock.Lock()
+	w.pending[path] = time.Now()
+	w.lock.Unlock()
+} // func (w *watcher) touch(path string)
+
+// rescanLater schedules a full scan of the Folder path belongs to. We do
+// that when we cannot tell what happened from the events alone, e.g. when
+// a whole directory has been moved away.
+func (w *watcher) rescanLater(path string) {
+	var root = w.rootOf(path)
+
+	if root == nil {
+		return
+	}
+
+	w.lock.Lock()
+	w.rescan[root.Path] = time.Now()
+	w.lock.Unlock()
+} // func (w *watcher) rescanLater(path string)
+
+// readLoop receives events from the kernel and records which paths have
+// changed. It does not look at the files itself, that is left to
+// tickLoop, once the files have settled.
+func (w *watcher) readLoop() {
+	for {
+		var (
+			err    error
+			events []event
+		)
+
+		if events, err = w.notify.read(); err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				w.log.Printf("[ERROR] Cannot read inotify events: %s\n",
+					err.Error())
+			}
+			return
+		}
+
+		for _, ev := range events {
+			switch {
+			case ev.overflow:
+				// We have missed events, so we have to look at
+				// everything again.
+				w.log.Println("[INFO] inotify queue overflowed, rescanning all Folders")
+				w.lock.Lock()
+				for p := range w.roots {
+					w.rescan[p] = time.Now()
+				}
+				w.lock.Unlock()
+			case ev.dir && ev.gone:
+				w.rescanLater(ev.path)
+			case ev.dir:
+				if err = w.addTree(ev.path, w.touch); err != nil {
+					w.log.Printf("[ERROR] Cannot watch new directory %s: %s\n",
+						ev.path,
+						err.Error())
+					w.rescanLater(ev.path)
+				}
+			case filepath.Base(ev.path) == IgnoreFile:
+				// Files that were ignored so far may be wanted now.
+				w.rescanLater(ev.path)
+			default:
+				// Whether we care about the file depends on the scan
+				// rules of its Folder, process sorts that out.
+				w.touch(ev.path)
+			}
+		}
+	}
+} // func (w *watcher) readLoop()
+
+// tickLoop processes the files that have been quiet for long enough, and
+// rescans the Folders that need it.
+func (w *watcher) tickLoop() {
+	var (
+		ticker   = time.NewTicker(w.debounce / 5)
+		lastPoll = time.Now()
+	)
+
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stopQ:
+			return
+		case now := <-ticker.C:
+			var (
+				paths, roots []string
+				cutoff       = now.Add(-w.debounce)
+				poll         = now.Sub(lastPoll) >= w.interval
+			)
+
+			w.lock.Lock()
+			for p, stamp := range w.pending {
+				if stamp.Before(cutoff) {
+					paths = append(paths, p)
+					delete(w.pending, p)
+				}
+			}
+			for p, stamp := range w.rescan {
+				if stamp.Before(cutoff) {
+					roots = append(roots, p)
+					delete(w.rescan, p)
+				}
+			}
+			if poll {
+				for p := range w.polled {
+					roots = append(roots, p)
+				}
+			}
+			w.lock.Unlock()
+
+			if poll {
+				lastPoll = now
+			}
+
+			if len(paths) > 0 {
+				w.process(paths)
+			}
+
+			if len(roots) > 0 && !w.s.Active() {
+				sort.Strings(roots)
+				w.s.ScanPath(context.Background(), roots...)
+			} else if len(roots) > 0 {
+				// A scan is running already, try again later.
+				w.lock.Lock()
+				for _, p := range roots {
+					w.rescan[p] = now
+				}
+				w.lock.Unlock()
+			}
+		}
+	}
+} // func (w *watcher) tickLoop()
+
+// refresh looks up a Folder in the Database, so we pick up changes to its
+// scan rules. If that fails, we keep using the Folder we have.
+func (w *watcher) refresh(db *database.Database, f *objects.Folder) *objects.Folder {
+	var (
+		err   error
+		fresh *objects.Folder
+	)
+
+	if fresh, err = db.FolderGetByPath(f.Path); err != nil {
+		w.log.Printf("[ERROR] Cannot look up Folder %s: %s\n",
+			f.Path,
+			err.Error())
+		return f
+	} else if fresh == nil {
+		return f
+	}
+
+	w.lock.Lock()
+	w.roots[f.Path] = fresh
+	w.lock.Unlock()
+
+	return fresh
+} // func (w *watcher) refresh(db *database.Database, f *objects.Folder) *objects.Folder
+
+// process looks at the files that have changed. Files that exist are
+// handled the same way a scan would handle them, Files that have vanished
+// are marked as missing. We look at the files that exist first, so a File
+// that has been renamed is moved to its new path rather than marked as
+// missing.
+func (w *watcher) process(paths []string) {
+	var (
+		err      error
+		db       *database.Database
+		vanished []string
+		ruleMap  = make(map[string]*rules)
+	)
+
+	sort.Strings(paths)
+
+	w.s.addWorker()
+	defer w.s.delWorker()
+
+	db = w.s.pool.Get()
+	defer w.s.pool.Put(db)
+
+	for _, path := range paths {
+		var (
+			info os.FileInfo
+			root = w.rootOf(path)
+		)
+
+		if root == nil {
+			continue
+		} else if ruleMap[root.Path] == nil {
+			ruleMap[root.Path] = newRules(w.log, w.refresh(db, root))
+		}
+
+		if !ruleMap[root.Path].wanted(path) {
+			continue
+		} else if info, err = os.Stat(path); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				vanished = append(vanished, path)
+			} else {
+				w.log.Printf("[ERROR] Cannot stat %s: %s\n",
+					path,
+					err.Error())
+			}
+			continue
+		} else if !info.Mode().IsRegular() || ruleMap[root.Path].excluded(path, false) {
+			continue
+		}
+
+		var wk = walker{
+			log:     w.log,
+			root:    root,
+			fileQ:   w.s.newQ,
+			updateQ: w.s.fileQ,
+			db:      db,
+			rules:   ruleMap[root.Path],
+		}
+
+		if err = wk.addFile(path, info); err != nil {
+			w.log.Printf("[ERROR] Cannot process %s: %s\n",
+				path,
+				err.Error())
+		}
+	}
+
+	for _, path := range vanished {
+		var f *objects.File
+
+		if f, err = db.FileGetByPath(path); err != nil {
+			w.log.Printf("[ERROR] Cannot look up File %s: %s\n",
+				path,
+				err.Error())
+			continue
+		} else if f == nil || f.IsMissing() {
+			continue
+		}
+
+		w.log.Printf("[INFO] File %s has gone missing\n", path)
+		if err = db.FileSetMissing(f, time.Now()); err != nil {
+			w.log.Printf("[ERROR] Cannot mark File %s as missing: %s\n",
+				path,
+				err.Error())
+			continue
+		}
+
+		w.s.fileQ <- f
+	}
+} // func (w *watcher) process(paths []string)
@@ -0,0 +1,203 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/ui/backup.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 11. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-11 20:14:38 krylon>
+
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/blicero/blockbuster/common"
+	"github.com/blicero/blockbuster/database"
+	"github.com/blicero/krylib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// We check once an hour if any snapshots of the database are due. Taking
+// them is quick enough to do it on the main thread, which saves us from
+// having to worry about the database being swapped out underneath us by a
+// restore.
+const snapshotCheckMillis = 3600 * 1000
+
+// takeSnapshots takes whatever snapshots of the database are due. It is
+// meant to be called from a glib timeout, so it always returns true.
+func (g *GUI) takeSnapshots() bool {
+	var (
+		err   error
+		taken []database.Snapshot
+	)
+
+	if taken, err = g.db.SnapshotRotate(common.SnapshotDir, time.Now()); err != nil {
+		var msg = fmt.Sprintf("Cannot take snapshot of database: %s",
+			err.Error())
+		g.log.Printf("[ERROR] %s\n", msg)
+		g.statusbar.Push(statusBackup, msg)
+		return true
+	}
+
+	for _, s := range taken {
+		g.log.Printf("[INFO] Took %s snapshot %s\n",
+			s.Kind,
+			s.Path)
+	}
+
+	return true
+} // func (g *GUI) takeSnapshots() bool
+
+// handleRestoreSnapshot lets the user pick a snapshot and replaces the
+// database with it.
+func (g *GUI) handleRestoreSnapshot() {
+	krylib.Trace()
+	defer g.log.Printf("[TRACE] EXIT %s\n",
+		krylib.TraceInfo())
+
+	var (
+		err   error
+		msg   string
+		idx   int
+		snaps []database.Snapshot
+		dlg   *gtk.Dialog
+		dbox  *gtk.Box
+		combo *gtk.ComboBoxText
+		snap  *database.Snapshot
+	)
+
+	if snaps, err = database.SnapshotList(common.SnapshotDir); err != nil {
+		msg = fmt.Sprintf("Cannot list snapshots in %s: %s",
+			common.SnapshotDir,
+			err.Error())
+		goto ERROR
+	} else if len(snaps) == 0 {
+		g.displayMsg("There are no snapshots to restore from, yet.")
+		return
+	} else if g.scanner.Active() {
+		g.displayMsg("Please wait for the scan to finish before restoring the database.")
+		return
+	} else if g.session != nil {
+		g.displayMsg("Please close the player before restoring the database.")
+		return
+	} else if dlg, err = gtk.DialogNewWithButtons(
+		"Restore from snapshot",
+		g.win,
+		gtk.DIALOG_MODAL,
+		[]interface{}{
+			"_Cancel",
+			gtk.RESPONSE_CANCEL,
+			"_OK",
+			gtk.RESPONSE_OK,
+		},
+	); err != nil {
+		msg = fmt.Sprintf("Cannot create dialog: %s",
+			err.Error())
+		goto ERROR
+	}
+
+	defer dlg.Close()
+
+	// See handleTagAdd on why we add the OK button again.
+	if _, err = dlg.AddButton("OK", gtk.RESPONSE_OK); err != nil {
+		msg = fmt.Sprintf("Cannot add OK button to dialog: %s",
+			err.Error())
+		goto ERROR
+	} else if dbox, err = dlg.GetContentArea(); err != nil {
+		msg = fmt.Sprintf("Cannot get ContentArea of dialog: %s",
+			err.Error())
+		goto ERROR
+	} else if combo, err = gtk.ComboBoxTextNew(); err != nil {
+		msg = fmt.Sprintf("Cannot create ComboBox: %s",
+			err.Error())
+		goto ERROR
+	}
+
+	for i := range snaps {
+		combo.Append(strconv.Itoa(i),
+			fmt.Sprintf("%s (%s)",
+				snaps[i].Time.Format(common.TimestampFormat),
+				snaps[i].Kind))
+	}
+
+	combo.SetActive(0)
+	dbox.PackStart(combo, true, true, 0)
+	dlg.ShowAll()
+
+	if res := dlg.Run(); res != gtk.RESPONSE_OK {
+		g.log.Println("[DEBUG] User cancelled restoring from snapshot")
+		return
+	} else if idx, err = strconv.Atoi(combo.GetActiveID()); err != nil {
+		msg = fmt.Sprintf("Cannot get selected snapshot: %s",
+			err.Error())
+		goto ERROR
+	}
+
+	snap = &snaps[idx]
+
+	if !g.confirm(fmt.Sprintf("Replace the database with the snapshot from %s? Everything that happened since then will be lost.",
+		snap.Time.Format(common.TimestampFormat))) {
+		return
+	} else if err = database.CheckSnapshot(snap.Path); err != nil {
+		msg = fmt.Sprintf("Snapshot %s cannot be used: %s",
+			snap.Path,
+			err.Error())
+		goto ERROR
+	} else if err = g.restoreDatabase(snap.Path); err != nil {
+		msg = err.Error()
+		goto ERROR
+	}
+
+	g.displayMsg(fmt.Sprintf("Restored database from %s", snap.Path))
+	return
+
+ERROR:
+	g.log.Printf("[ERROR] %s\n", msg)
+	g.displayMsg(msg)
+} // func (g *GUI) handleRestoreSnapshot()
+
+// restoreDatabase closes our own connection to the database, lets the
+// Scanner swap in the snapshot while its connections are closed, and opens a
+// fresh connection afterwards. Since every view still displays rows from the
+// old database, we clear and reload all of them, just like at startup.
+func (g *GUI) restoreDatabase(path string) error {
+	var err, rerr error
+
+	if err = g.db.Close(); err != nil {
+		return fmt.Errorf("Cannot close database: %s", err.Error())
+	}
+
+	// Even if restoring fails, we need a working database connection.
+	rerr = g.scanner.RestoreDatabase(path)
+
+	if g.db, err = database.Open(common.DbPath); err != nil {
+		g.log.Printf("[CRITICAL] Cannot reopen database %s: %s\n",
+			common.DbPath,
+			err.Error())
+		return fmt.Errorf("Cannot reopen database: %s", err.Error())
+	} else if rerr != nil {
+		return fmt.Errorf("Cannot restore database from %s: %s",
+			path,
+			rerr.Error())
+	}
+
+	// Whatever the searches matched refers to rows of the old database.
+	// Clearing the search entries resets the filters, too.
+	for idx := range g.tabs {
+		g.tabs[idx].search.SetText("")
+		g.tabs[idx].hits = nil
+		g.tabs[idx].files = nil
+		g.tabs[idx].pattern = ""
+	}
+
+	if g.tags, err = g.db.TagGetAll(); err != nil {
+		return fmt.Errorf("Cannot fetch all Tags from Database: %s",
+			err.Error())
+	}
+
+	sort.Sort(g.tags)
+	g.reloadData()
+
+	return nil
+} // func (g *GUI) restoreDatabase(path string) error
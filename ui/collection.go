@@ -0,0 +1,621 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/ui/collection.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 09. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-10 00:12:41 krylon>
+
+package ui
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/blicero/blockbuster/objects"
+	"github.com/blicero/krylib"
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// loadCollections fills the Collections view with all smart collections and
+// their current members.
+func (g *GUI) loadCollections() bool {
+	krylib.Trace()
+	defer g.log.Printf("[TRACE] EXIT %s\n",
+		krylib.TraceInfo())
+	var (
+		err   error
+		msg   string
+		colls []objects.Collection
+		store = g.tabs[tiCollections].store.(*gtk.TreeStore)
+	)
+
+	store.Clear()
+
+	if colls, err = g.db.CollectionGetAll(); err != nil {
+		msg = fmt.Sprintf("Cannot load Collections from Database: %s",
+			err.Error())
+		goto ERROR
+	}
+
+	g.colls = colls
+
+	for cidx := range colls {
+		var (
+			files []objects.File
+			citer *gtk.TreeIter
+			c     = &colls[cidx]
+		)
+
+		if files, err = g.db.CollectionGetMembers(c); err != nil {
+			msg = fmt.Sprintf("Cannot get members of Collection %s: %s",
+				c.Name,
+				err.Error())
+			goto ERROR
+		}
+
+		citer = store.Append(nil)
+		store.SetValue(citer, 0, c.ID)                     // nolint: errcheck
+		store.SetValue(citer, 1, c.Name)                   // nolint: errcheck
+		store.SetValue(citer, 3, strconv.Itoa(len(files))) // nolint: errcheck
+
+		for fidx := range files {
+			var (
+				f     = &files[fidx]
+				fiter = store.Append(citer)
+			)
+
+			store.SetValue(fiter, 0, f.ID)             // nolint: errcheck
+			store.SetValue(fiter, 1, f.DisplayTitle()) // nolint: errcheck
+			if f.Year != 0 {
+				store.SetValue(fiter, 2, strconv.FormatInt(f.Year, 10)) // nolint: errcheck
+			}
+		}
+	}
+
+	return false
+
+ERROR:
+	g.log.Printf("[ERROR] %s\n", msg)
+	g.displayMsg(msg)
+	return false
+} // func (g *GUI) loadCollections() bool
+
+// handleSwitchPage refreshes the Collections view whenever it is displayed,
+// so it always shows the current members.
+func (g *GUI) handleSwitchPage(nb *gtk.Notebook, page *gtk.Widget, idx uint) {
+	if tabIdx(idx) == tiCollections {
+		g.loadCollections()
+	}
+} // func (g *GUI) handleSwitchPage(nb *gtk.Notebook, page *gtk.Widget, idx uint)
+
+func (g *GUI) getCollection(id int64) *objects.Collection {
+	for idx := range g.colls {
+		if g.colls[idx].ID == id {
+			return &g.colls[idx]
+		}
+	}
+
+	return nil
+} // func (g *GUI) getCollection(id int64) *objects.Collection
+
+func (g *GUI) handleCollectionListClick(view *gtk.TreeView, evt *gdk.Event) {
+	krylib.Trace()
+	var be = gdk.EventButtonNewFromEvent(evt)
+
+	if be.Button() != gdk.BUTTON_SECONDARY {
+		return
+	}
+
+	var (
+		err    error
+		msg    string
+		exists bool
+		id     int64
+		path   *gtk.TreePath
+		imodel gtk.ITreeModel
+		model  *gtk.TreeModel
+		iter   *gtk.TreeIter
+		val    *glib.Value
+		gval   interface{}
+		menu   *gtk.Menu
+	)
+
+	if path, _, _, _, exists = view.GetPathAtPos(int(be.X()), int(be.Y())); !exists {
+		return
+	} else if imodel, err = view.GetModel(); err != nil {
+		g.log.Printf("[ERROR] Cannot get Model from View: %s\n",
+			err.Error())
+		return
+	}
+
+	model = imodel.ToTreeModel()
+
+	if iter, err = model.GetIter(path); err != nil {
+		msg = fmt.Sprintf("Cannot get Iter from TreePath %s: %s",
+			path,
+			err.Error())
+		goto ERROR
+	} else if val, err = model.GetValue(iter, 0); err != nil {
+		msg = fmt.Sprintf("Cannot get ID from column 0: %s",
+			err.Error())
+		goto ERROR
+	} else if gval, err = val.GoValue(); err != nil {
+		msg = fmt.Sprintf("Cannot get go value for ID: %s",
+			err.Error())
+		goto ERROR
+	}
+
+	id = int64(gval.(int))
+
+	if path.GetDepth() > 1 {
+		var f *objects.File
+
+		if f, err = g.db.FileGetByID(id); err != nil {
+			msg = fmt.Sprintf("Cannot lookup File #%d: %s",
+				id,
+				err.Error())
+			goto ERROR
+		} else if f == nil {
+			msg = fmt.Sprintf("File #%d was not found in database", id)
+			goto ERROR
+		} else if menu, err = g.mkPersonFileContextMenu(path, f); err != nil {
+			return
+		}
+	} else {
+		var c = g.getCollection(id)
+
+		if c == nil {
+			msg = fmt.Sprintf("Collection #%d does not exist", id)
+			goto ERROR
+		} else if menu, err = g.mkCollectionContextMenu(c); err != nil {
+			msg = fmt.Sprintf("Cannot create context Menu for Collection %s: %s",
+				c.Name,
+				err.Error())
+			goto ERROR
+		}
+	}
+
+	menu.ShowAll()
+	menu.PopupAtPointer(evt)
+	return
+
+ERROR:
+	g.log.Printf("[ERROR] %s\n", msg)
+	g.displayMsg(msg)
+} // func (g *GUI) handleCollectionListClick(view *gtk.TreeView, evt *gdk.Event)
+
+func (g *GUI) mkCollectionContextMenu(c *objects.Collection) (*gtk.Menu, error) {
+	var (
+		err               error
+		menu              *gtk.Menu
+		itemEdit, itemDel *gtk.MenuItem
+	)
+
+	if menu, err = gtk.MenuNew(); err != nil {
+		return nil, err
+	} else if itemEdit, err = gtk.MenuItemNewWithMnemonic("_Edit…"); err != nil {
+		return nil, err
+	} else if itemDel, err = gtk.MenuItemNewWithMnemonic("_Delete"); err != nil {
+		return nil, err
+	}
+
+	itemEdit.Connect("activate", func() { g.editCollection(c) })
+	itemDel.Connect("activate", func() {
+		if !g.confirm(fmt.Sprintf("Delete Collection %s?", c.Name)) {
+			return
+		} else if err := g.db.CollectionDelete(c); err != nil {
+			var msg = fmt.Sprintf("Cannot delete Collection %s: %s",
+				c.Name,
+				err.Error())
+			g.log.Printf("[ERROR] %s\n", msg)
+			g.displayMsg(msg)
+			return
+		}
+
+		g.loadCollections()
+	})
+
+	menu.Append(itemEdit)
+	menu.Append(itemDel)
+
+	return menu, nil
+} // func (g *GUI) mkCollectionContextMenu(c *objects.Collection) (*gtk.Menu, error)
+
+func (g *GUI) handleCollectionAdd() {
+	krylib.Trace()
+	defer g.log.Printf("[TRACE] EXIT %s\n",
+		krylib.TraceInfo())
+	var (
+		err  error
+		ok   bool
+		name string
+		cond objects.Condition
+	)
+
+	if name, cond, ok = g.collectionDialog("Add Collection", "", objects.And()); !ok {
+		return
+	} else if _, err = g.db.CollectionAdd(name, cond); err != nil {
+		var msg = fmt.Sprintf("Cannot add Collection %s: %s",
+			name,
+			err.Error())
+		g.log.Printf("[ERROR] %s\n", msg)
+		g.displayMsg(msg)
+		return
+	}
+
+	g.loadCollections()
+} // func (g *GUI) handleCollectionAdd()
+
+func (g *GUI) editCollection(c *objects.Collection) {
+	krylib.Trace()
+	defer g.log.Printf("[TRACE] EXIT %s\n",
+		krylib.TraceInfo())
+	var (
+		err  error
+		ok   bool
+		name string
+		cond objects.Condition
+		edit = *c
+	)
+
+	if name, cond, ok = g.collectionDialog("Edit Collection", c.Name, c.Cond); !ok {
+		return
+	}
+
+	edit.Name = name
+	edit.Cond = cond
+
+	if err = g.db.CollectionUpdate(&edit); err != nil {
+		var msg = fmt.Sprintf("Cannot update Collection %s: %s",
+			c.Name,
+			err.Error())
+		g.log.Printf("[ERROR] %s\n", msg)
+		g.displayMsg(msg)
+		return
+	}
+
+	g.loadCollections()
+} // func (g *GUI) editCollection(c *objects.Collection)
+
+// collectionForm holds what the user can edit about a Condition in the
+// Collection dialog. The dialog only covers the common case, a list of
+// simple Conditions of which all or any have to match. More complicated
+// Conditions cannot be edited in the dialog.
+type collectionForm struct {
+	any       bool
+	tag       int64
+	notTag    int64
+	person    int64
+	watched   int // 0: don't care, 1: watched, 2: not watched
+	untagged  bool
+	yearFrom  int64
+	yearTo    int64
+	thisYear  bool
+	titleText string
+}
+
+// formFromCondition fills a collectionForm from a Condition. It returns
+// false if the Condition is too complicated for the form.
+func formFromCondition(c *objects.Condition) (collectionForm, bool) {
+	var form collectionForm
+
+	if c.Kind != objects.CondAnd && c.Kind != objects.CondOr {
+		return form, false
+	}
+
+	form.any = c.Kind == objects.CondOr
+
+	for _, s := range c.Sub {
+		switch {
+		case s.Kind == objects.CondTag && form.tag == 0:
+			form.tag = s.ID
+		case s.Kind == objects.CondPerson && s.Role == 0 && form.person == 0:
+			form.person = s.ID
+		case s.Kind == objects.CondWatched && form.watched == 0:
+			form.watched = 1
+		case s.Kind == objects.CondUntagged:
+			form.untagged = true
+		case s.Kind == objects.CondYear && s.Op == objects.OpGe && form.yearFrom == 0:
+			form.yearFrom = s.Value
+		case s.Kind == objects.CondYear && s.Op == objects.OpLe && form.yearTo == 0:
+			form.yearTo = s.Value
+		case s.Kind == objects.CondAdded && s.Op == objects.OpEq && s.Value == 0:
+			form.thisYear = true
+		case s.Kind == objects.CondTitle && form.titleText == "":
+			form.titleText = s.Text
+		case s.Kind == objects.CondNot && len(s.Sub) == 1:
+			switch {
+			case s.Sub[0].Kind == objects.CondTag && form.notTag == 0:
+				form.notTag = s.Sub[0].ID
+			case s.Sub[0].Kind == objects.CondWatched && form.watched == 0:
+				form.watched = 2
+			default:
+				return form, false
+			}
+		default:
+			return form, false
+		}
+	}
+
+	return form, true
+} // func formFromCondition(c *objects.Condition) (collectionForm, bool)
+
+func (form *collectionForm) condition() objects.Condition {
+	var conds = make([]objects.Condition, 0, 8)
+
+	if form.tag != 0 {
+		conds = append(conds, objects.Condition{Kind: objects.CondTag, ID: form.tag})
+	}
+	if form.notTag != 0 {
+		conds = append(conds, objects.Not(objects.Condition{Kind: objects.CondTag, ID: form.notTag}))
+	}
+	if form.person != 0 {
+		conds = append(conds, objects.Condition{Kind: objects.CondPerson, ID: form.person})
+	}
+	switch form.watched {
+	case 1:
+		conds = append(conds, objects.Condition{Kind: objects.CondWatched})
+	case 2:
+		conds = append(conds, objects.Not(objects.Condition{Kind: objects.CondWatched}))
+	}
+	if form.untagged {
+		conds = append(conds, objects.Condition{Kind: objects.CondUntagged})
+	}
+	if form.yearFrom != 0 {
+		conds = append(conds, objects.Condition{Kind: objects.CondYear, Op: objects.OpGe, Value: form.yearFrom})
+	}
+	if form.yearTo != 0 {
+		conds = append(conds, objects.Condition{Kind: objects.CondYear, Op: objects.OpLe, Value: form.yearTo})
+	}
+	if form.thisYear {
+		conds = append(conds, objects.Condition{Kind: objects.CondAdded, Op: objects.OpEq})
+	}
+	if form.titleText != "" {
+		conds = append(conds, objects.Condition{Kind: objects.CondTitle, Text: form.titleText})
+	}
+
+	if form.any {
+		return objects.Or(conds...)
+	}
+
+	return objects.And(conds...)
+} // func (form *collectionForm) condition() objects.Condition
+
+// collectionDialog lets the user edit the name and Condition of a
+// Collection. If the Condition is too complicated for the dialog, only the
+// name can be changed.
+// The last return value is false if the user cancelled the dialog.
+func (g *GUI) collectionDialog(title, name string, cond objects.Condition) (string, objects.Condition, bool) {
+	krylib.Trace()
+	defer g.log.Printf("[TRACE] EXIT %s\n",
+		krylib.TraceInfo())
+	var (
+		err                         error
+		editable                    bool
+		form                        collectionForm
+		dlg                         *gtk.Dialog
+		dbox                        *gtk.Box
+		grid                        *gtk.Grid
+		nameEntry, titleEntry       *gtk.Entry
+		matchBox, tagBox, notTagBox *gtk.ComboBoxText
+		personBox, watchedBox       *gtk.ComboBoxText
+		untaggedBtn, thisYearBtn    *gtk.CheckButton
+		fromSpin, toSpin            *gtk.SpinButton
+		people                      []objects.Person
+		widgets                     []gtk.IWidget
+		labels                      = []string{
+			"Name:",
+			"Match:",
+			"Tagged:",
+			"Not tagged:",
+			"Person:",
+			"Watched:",
+			"",
+			"Year from:",
+			"Year to:",
+			"",
+			"Title contains:",
+		}
+	)
+
+	form, editable = formFromCondition(&cond)
+
+	if people, err = g.db.PersonGetAll(); err != nil {
+		g.log.Printf("[ERROR] Cannot load People: %s\n",
+			err.Error())
+		g.displayMsg(err.Error())
+		return "", cond, false
+	} else if dlg, err = gtk.DialogNewWithButtons(
+		title,
+		g.win,
+		gtk.DIALOG_MODAL,
+		[]interface{}{
+			"_Cancel",
+			gtk.RESPONSE_CANCEL,
+			"_OK",
+			gtk.RESPONSE_OK,
+		},
+	); err != nil {
+		g.log.Printf("[ERROR] Cannot create Dialog %q: %s\n",
+			title,
+			err.Error())
+		return "", cond, false
+	}
+
+	defer dlg.Close()
+
+	// See handleTagAdd on why we add the OK button again.
+	if _, err = dlg.AddButton("OK", gtk.RESPONSE_OK); err != nil {
+		g.log.Printf("[ERROR] Cannot add OK button to Dialog %q: %s\n",
+			title,
+			err.Error())
+		return "", cond, false
+	} else if dbox, err = dlg.GetContentArea(); err != nil {
+		g.log.Printf("[ERROR] Cannot get ContentArea of Dialog %q: %s\n",
+			title,
+			err.Error())
+		return "", cond, false
+	} else if grid, err = gtk.GridNew(); err != nil {
+		g.log.Printf("[ERROR] Cannot create gtk.Grid: %s\n",
+			err.Error())
+		return "", cond, false
+	} else if nameEntry, err = gtk.EntryNew(); err != nil {
+		g.log.Printf("[ERROR] Cannot create Entry: %s\n",
+			err.Error())
+		return "", cond, false
+	} else if titleEntry, err = gtk.EntryNew(); err != nil {
+		g.log.Printf("[ERROR] Cannot create Entry: %s\n",
+			err.Error())
+		return "", cond, false
+	} else if matchBox, err = gtk.ComboBoxTextNew(); err != nil {
+		g.log.Printf("[ERROR] Cannot create ComboBox: %s\n",
+			err.Error())
+		return "", cond, false
+	} else if tagBox, err = gtk.ComboBoxTextNew(); err != nil {
+		g.log.Printf("[ERROR] Cannot create ComboBox: %s\n",
+			err.Error())
+		return "", cond, false
+	} else if notTagBox, err = gtk.ComboBoxTextNew(); err != nil {
+		g.log.Printf("[ERROR] Cannot create ComboBox: %s\n",
+			err.Error())
+		return "", cond, false
+	} else if personBox, err = gtk.ComboBoxTextNew(); err != nil {
+		g.log.Printf("[ERROR] Cannot create ComboBox: %s\n",
+			err.Error())
+		return "", cond, false
+	} else if watchedBox, err = gtk.ComboBoxTextNew(); err != nil {
+		g.log.Printf("[ERROR] Cannot create ComboBox: %s\n",
+			err.Error())
+		return "", cond, false
+	} else if untaggedBtn, err = gtk.CheckButtonNewWithLabel("Not tagged at all"); err != nil {
+		g.log.Printf("[ERROR] Cannot create CheckButton: %s\n",
+			err.Error())
+		return "", cond, false
+	} else if thisYearBtn, err = gtk.CheckButtonNewWithLabel("Added this year"); err != nil {
+		g.log.Printf("[ERROR] Cannot create CheckButton: %s\n",
+			err.Error())
+		return "", cond, false
+	} else if fromSpin, err = gtk.SpinButtonNewWithRange(0, 9999, 1); err != nil {
+		g.log.Printf("[ERROR] Cannot create SpinButton: %s\n",
+			err.Error())
+		return "", cond, false
+	} else if toSpin, err = gtk.SpinButtonNewWithRange(0, 9999, 1); err != nil {
+		g.log.Printf("[ERROR] Cannot create SpinButton: %s\n",
+			err.Error())
+		return "", cond, false
+	}
+
+	matchBox.Append("all", "all of the following")
+	matchBox.Append("any", "any of the following")
+	watchedBox.Append("0", "")
+	watchedBox.Append("1", "watched")
+	watchedBox.Append("2", "not watched")
+	tagBox.Append("0", "")
+	notTagBox.Append("0", "")
+	personBox.Append("0", "")
+
+	for _, t := range g.tags {
+		var id = strconv.FormatInt(t.ID, 10)
+		tagBox.Append(id, t.Name)
+		notTagBox.Append(id, t.Name)
+	}
+
+	for _, p := range people {
+		personBox.Append(strconv.FormatInt(p.ID, 10), p.Name)
+	}
+
+	nameEntry.SetText(name)
+	titleEntry.SetText(form.titleText)
+	matchBox.SetActiveID(map[bool]string{false: "all", true: "any"}[form.any])
+	tagBox.SetActiveID(strconv.FormatInt(form.tag, 10))
+	notTagBox.SetActiveID(strconv.FormatInt(form.notTag, 10))
+	personBox.SetActiveID(strconv.FormatInt(form.person, 10))
+	watchedBox.SetActiveID(strconv.Itoa(form.watched))
+	untaggedBtn.SetActive(form.untagged)
+	thisYearBtn.SetActive(form.thisYear)
+	fromSpin.SetValue(float64(form.yearFrom))
+	toSpin.SetValue(float64(form.yearTo))
+
+	widgets = []gtk.IWidget{
+		nameEntry,
+		matchBox,
+		tagBox,
+		notTagBox,
+		personBox,
+		watchedBox,
+		untaggedBtn,
+		fromSpin,
+		toSpin,
+		thisYearBtn,
+		titleEntry,
+	}
+
+	grid.SetColumnSpacing(10)
+	grid.SetRowSpacing(5)
+
+	for row, lblText := range labels {
+		var lbl *gtk.Label
+
+		if lbl, err = gtk.LabelNew(lblText); err != nil {
+			g.log.Printf("[ERROR] Cannot create Label %q: %s\n",
+				lblText,
+				err.Error())
+			return "", cond, false
+		}
+
+		lbl.SetXAlign(0)
+		grid.Attach(lbl, 0, row, 1, 1)
+		grid.Attach(widgets[row], 1, row, 1, 1)
+
+		// The name is always editable.
+		if row > 0 && !editable {
+			widgets[row].ToWidget().SetSensitive(false)
+		}
+	}
+
+	if !editable {
+		var lbl *gtk.Label
+
+		if lbl, err = gtk.LabelNew("The condition of this Collection is too complex to be edited here."); err != nil {
+			g.log.Printf("[ERROR] Cannot create Label: %s\n",
+				err.Error())
+			return "", cond, false
+		}
+
+		grid.Attach(lbl, 0, len(labels), 2, 1)
+	}
+
+	dbox.PackStart(grid, true, true, 0)
+	dlg.ShowAll()
+
+	if res := dlg.Run(); res != gtk.RESPONSE_OK {
+		g.log.Printf("[DEBUG] User cancelled Dialog %q\n", title)
+		return "", cond, false
+	} else if name, err = nameEntry.GetText(); err != nil {
+		g.log.Printf("[ERROR] Cannot get name from Dialog: %s\n",
+			err.Error())
+		return "", cond, false
+	} else if name == "" {
+		g.displayMsg("A Collection needs a name.")
+		return "", cond, false
+	} else if !editable {
+		return name, cond, true
+	} else if form.titleText, err = titleEntry.GetText(); err != nil {
+		g.log.Printf("[ERROR] Cannot get title from Dialog: %s\n",
+			err.Error())
+		return "", cond, false
+	}
+
+	form.any = matchBox.GetActiveID() == "any"
+	form.tag, _ = strconv.ParseInt(tagBox.GetActiveID(), 10, 64)
+	form.notTag, _ = strconv.ParseInt(notTagBox.GetActiveID(), 10, 64)
+	form.person, _ = strconv.ParseInt(personBox.GetActiveID(), 10, 64)
+	form.watched, _ = strconv.Atoi(watchedBox.GetActiveID())
+	form.untagged = untaggedBtn.GetActive()
+	form.thisYear = thisYearBtn.GetActive()
+	form.yearFrom = int64(fromSpin.GetValueAsInt())
+	form.yearTo = int64(toSpin.GetValueAsInt())
+
+	return name, form.condition(), true
+} // func (g *GUI) collectionDialog(title, name string, cond objects.Condition) (string, objects.Condition, bool)
@@ -0,0 +1,336 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/ui/diary.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 15. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-15 00:48:33 krylon>
+
+package ui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/blicero/blockbuster/diary"
+	"github.com/blicero/krylib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// ratingStars renders a rating, counted in half stars, the way Letterboxd
+// does.
+func ratingStars(rating int64) string {
+	var s = strings.Repeat("★", int(rating/2))
+
+	if rating%2 == 1 {
+		s += "½"
+	}
+
+	return s
+} // func ratingStars(rating int64) string
+
+// handleImportHistory reads a watch history exported from Letterboxd or
+// IMDb, matches it to our Files and records the ratings and viewings.
+// Entries we cannot match with confidence are shown to the user first.
+func (g *GUI) handleImportHistory() {
+	krylib.Trace()
+	defer g.log.Printf("[TRACE] EXIT %s\n",
+		krylib.TraceInfo())
+
+	var (
+		err       error
+		msg       string
+		path      string
+		fh        *os.File
+		format    diary.Format
+		entries   []diary.Entry
+		cands     []diary.Candidate
+		results   []diary.Result
+		ok        bool
+		cnt       int
+		unmatched []string
+	)
+
+	if path, err = g.chooseFile("Import watch history", gtk.FILE_CHOOSER_ACTION_OPEN, ""); err != nil {
+		msg = err.Error()
+		goto ERROR
+	} else if path == "" {
+		g.log.Println("[DEBUG] User cancelled import")
+		return
+	} else if fh, err = os.Open(path); err != nil {
+		msg = fmt.Sprintf("Cannot open %s: %s",
+			path,
+			err.Error())
+		goto ERROR
+	}
+
+	defer fh.Close() // nolint: errcheck
+
+	if format, entries, err = diary.Read(fh); err != nil {
+		msg = fmt.Sprintf("Cannot read watch history from %s: %s",
+			path,
+			err.Error())
+		goto ERROR
+	} else if cands, err = diary.Candidates(g.db); err != nil {
+		msg = fmt.Sprintf("Cannot load Files: %s",
+			err.Error())
+		goto ERROR
+	}
+
+	results = diary.NewMatcher(cands).MatchAll(entries)
+
+	if ok, err = g.reviewMatches(results); err != nil {
+		msg = err.Error()
+		goto ERROR
+	} else if !ok {
+		g.log.Println("[DEBUG] User cancelled import")
+		return
+	} else if cnt, err = diary.Apply(g.db, results, format); err != nil {
+		msg = fmt.Sprintf("Cannot import watch history: %s",
+			err.Error())
+		goto ERROR
+	}
+
+	for idx := range results {
+		if results[idx].File() == nil {
+			unmatched = append(unmatched, results[idx].Entry.String())
+			g.log.Printf("[INFO] No match for %s, line %d\n",
+				results[idx].Entry.String(),
+				results[idx].Entry.Line)
+		}
+	}
+
+	g.reloadData()
+
+	msg = fmt.Sprintf("Imported %d entries from %s (%s), %d new viewings.",
+		len(results)-len(unmatched),
+		path,
+		format,
+		cnt)
+
+	if len(unmatched) > 0 {
+		msg += fmt.Sprintf("\n\n%d entries did not match any File:\n", len(unmatched))
+
+		for i, title := range unmatched {
+			if i == maxConflictsShown {
+				msg += fmt.Sprintf("\t… and %d more, see the log for details\n",
+					len(unmatched)-i)
+				break
+			}
+			msg += fmt.Sprintf("\t%s\n", title)
+		}
+	}
+
+	g.displayMsg(msg)
+	return
+
+ERROR:
+	g.log.Printf("[ERROR] %s\n", msg)
+	g.displayMsg(msg)
+} // func (g *GUI) handleImportHistory()
+
+// reviewMatches lets the user pick the right File for every ambiguous
+// Result, or none at all. It returns false if the user cancelled.
+func (g *GUI) reviewMatches(results []diary.Result) (bool, error) {
+	var (
+		err       error
+		dlg       *gtk.Dialog
+		dbox      *gtk.Box
+		scr       *gtk.ScrolledWindow
+		grid      *gtk.Grid
+		ambiguous []int
+		combos    []*gtk.ComboBoxText
+	)
+
+	for idx := range results {
+		if results[idx].Status == diary.Ambiguous {
+			ambiguous = append(ambiguous, idx)
+		}
+	}
+
+	if len(ambiguous) == 0 {
+		return true, nil
+	} else if dlg, err = gtk.DialogNewWithButtons(
+		"Review matches",
+		g.win,
+		gtk.DIALOG_MODAL,
+		[]interface{}{
+			"Cancel",
+			gtk.RESPONSE_CANCEL,
+			"OK",
+			gtk.RESPONSE_OK,
+		},
+	); err != nil {
+		return false, fmt.Errorf("Cannot create dialog: %s",
+			err.Error())
+	}
+
+	defer dlg.Close()
+
+	// See handleTagAdd on why we add the OK button again.
+	if _, err = dlg.AddButton("OK", gtk.RESPONSE_OK); err != nil {
+		return false, fmt.Errorf("Cannot add OK button to dialog: %s",
+			err.Error())
+	} else if dbox, err = dlg.GetContentArea(); err != nil {
+		return false, fmt.Errorf("Cannot get ContentArea of dialog: %s",
+			err.Error())
+	} else if scr, err = gtk.ScrolledWindowNew(nil, nil); err != nil {
+		return false, fmt.Errorf("Cannot create ScrolledWindow: %s",
+			err.Error())
+	} else if grid, err = gtk.GridNew(); err != nil {
+		return false, fmt.Errorf("Cannot create Grid: %s",
+			err.Error())
+	}
+
+	grid.SetColumnSpacing(10)
+	grid.SetRowSpacing(5)
+
+	for i, title := range []string{"Title", "Watched", "Rating", "File"} {
+		var lbl *gtk.Label
+
+		if lbl, err = gtk.LabelNew(""); err != nil {
+			return false, fmt.Errorf("Cannot create Label: %s",
+				err.Error())
+		}
+
+		lbl.SetMarkup(fmt.Sprintf("<b>%s</b>", title))
+		grid.Attach(lbl, i, 0, 1, 1)
+	}
+
+	combos = make([]*gtk.ComboBoxText, len(ambiguous))
+
+	for row, idx := range ambiguous {
+		var (
+			r                = &results[idx]
+			tLbl, wLbl, rLbl *gtk.Label
+			watched          string
+		)
+
+		if !r.Entry.Watched.IsZero() {
+			watched = r.Entry.Watched.Format("2006-01-02")
+		}
+
+		if tLbl, err = gtk.LabelNew(r.Entry.String()); err != nil {
+			return false, fmt.Errorf("Cannot create Label: %s",
+				err.Error())
+		} else if wLbl, err = gtk.LabelNew(watched); err != nil {
+			return false, fmt.Errorf("Cannot create Label: %s",
+				err.Error())
+		} else if rLbl, err = gtk.LabelNew(ratingStars(r.Entry.Rating)); err != nil {
+			return false, fmt.Errorf("Cannot create Label: %s",
+				err.Error())
+		} else if combos[row], err = gtk.ComboBoxTextNew(); err != nil {
+			return false, fmt.Errorf("Cannot create ComboBox: %s",
+				err.Error())
+		}
+
+		combos[row].AppendText("Skip")
+
+		for _, c := range r.Candidates {
+			var title = c.Title
+
+			if c.Year != 0 {
+				title = fmt.Sprintf("%s (%d)", c.Title, c.Year)
+			}
+
+			combos[row].AppendText(fmt.Sprintf("%s - %s",
+				title,
+				filepath.Base(c.File.Path)))
+		}
+
+		// We would rather miss a match than record a viewing for the
+		// wrong File, so the user has to pick one explicitly.
+		combos[row].SetActive(0)
+
+		tLbl.SetXAlign(0)
+
+		grid.Attach(tLbl, 0, row+1, 1, 1)
+		grid.Attach(wLbl, 1, row+1, 1, 1)
+		grid.Attach(rLbl, 2, row+1, 1, 1)
+		grid.Attach(combos[row], 3, row+1, 1, 1)
+	}
+
+	scr.SetPolicy(gtk.POLICY_AUTOMATIC, gtk.POLICY_AUTOMATIC)
+	scr.SetMinContentHeight(300)
+	scr.SetMinContentWidth(800)
+	scr.Add(grid)
+	dbox.PackStart(scr, true, true, 0)
+	dlg.ShowAll()
+
+	if res := dlg.Run(); res != gtk.RESPONSE_OK {
+		return false, nil
+	}
+
+	for row, idx := range ambiguous {
+		results[idx].Resolve(combos[row].GetActive() - 1)
+	}
+
+	return true, nil
+} // func (g *GUI) reviewMatches(results []diary.Result) (bool, error)
+
+// handleExportHistory writes our watch history to a CSV file Letterboxd can
+// import.
+func (g *GUI) handleExportHistory() {
+	krylib.Trace()
+	defer g.log.Printf("[TRACE] EXIT %s\n",
+		krylib.TraceInfo())
+
+	var (
+		err     error
+		msg     string
+		path    string
+		tmp     string
+		fh      *os.File
+		entries []diary.Entry
+	)
+
+	if entries, err = diary.History(g.db); err != nil {
+		msg = fmt.Sprintf("Cannot get watch history: %s",
+			err.Error())
+		goto ERROR
+	} else if len(entries) == 0 {
+		g.displayMsg("There is no watch history to export.")
+		return
+	} else if path, err = g.chooseFile("Export watch history", gtk.FILE_CHOOSER_ACTION_SAVE, "letterboxd.csv"); err != nil {
+		msg = err.Error()
+		goto ERROR
+	} else if path == "" {
+		g.log.Println("[DEBUG] User cancelled export")
+		return
+	}
+
+	tmp = path + ".tmp"
+
+	if fh, err = os.Create(tmp); err != nil {
+		msg = fmt.Sprintf("Cannot create %s: %s",
+			tmp,
+			err.Error())
+		goto ERROR
+	} else if err = diary.WriteLetterboxd(fh, entries); err != nil {
+		fh.Close()     // nolint: errcheck,gosec
+		os.Remove(tmp) // nolint: errcheck,gosec
+		msg = fmt.Sprintf("Cannot export watch history: %s",
+			err.Error())
+		goto ERROR
+	} else if err = fh.Close(); err != nil {
+		os.Remove(tmp) // nolint: errcheck,gosec
+		msg = fmt.Sprintf("Cannot close %s: %s",
+			tmp,
+			err.Error())
+		goto ERROR
+	} else if err = os.Rename(tmp, path); err != nil {
+		os.Remove(tmp) // nolint: errcheck,gosec
+		msg = fmt.Sprintf("Cannot rename %s to %s: %s",
+			tmp,
+			path,
+			err.Error())
+		goto ERROR
+	}
+
+	g.displayMsg(fmt.Sprintf("Exported %d entries to %s", len(entries), path))
+	return
+
+ERROR:
+	g.log.Printf("[ERROR] %s\n", msg)
+	g.displayMsg(msg)
+} // func (g *GUI) handleExportHistory()
@@ -0,0 +1,213 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/ui/export.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 12. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-15 00:12:09 krylon>
+
+package ui
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/blicero/blockbuster/common"
+	"github.com/blicero/blockbuster/database"
+	"github.com/blicero/krylib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// We do not want to flood the user with a dialog the size of the screen,
+// if there are more conflicts than this, the rest are only logged.
+const maxConflictsShown = 20
+
+// chooseFile asks the user for a file to export something to or import it
+// from. name is suggested when saving. It returns an empty string if the
+// user cancelled.
+func (g *GUI) chooseFile(title string, action gtk.FileChooserAction, name string) (string, error) {
+	var (
+		err error
+		dlg *gtk.FileChooserDialog
+	)
+
+	if dlg, err = gtk.FileChooserDialogNewWith2Buttons(
+		title,
+		g.win,
+		action,
+		"Cancel",
+		gtk.RESPONSE_CANCEL,
+		"OK",
+		gtk.RESPONSE_OK,
+	); err != nil {
+		return "", fmt.Errorf("Cannot create FileChooserDialog: %s",
+			err.Error())
+	}
+
+	defer dlg.Close()
+
+	if action == gtk.FILE_CHOOSER_ACTION_SAVE {
+		dlg.SetDoOverwriteConfirmation(true)
+		dlg.SetCurrentName(name)
+	}
+
+	if res := dlg.Run(); res != gtk.RESPONSE_OK {
+		return "", nil
+	}
+
+	return dlg.GetFilename(), nil
+} // func (g *GUI) chooseFile(title string, action gtk.FileChooserAction, name string) (string, error)
+
+func libraryFileName() string {
+	return fmt.Sprintf("%s.json", strings.ToLower(common.AppName))
+} // func libraryFileName() string
+
+// handleExportLibrary writes the library to a JSON file of the user's
+// choosing.
+func (g *GUI) handleExportLibrary() {
+	krylib.Trace()
+	defer g.log.Printf("[TRACE] EXIT %s\n",
+		krylib.TraceInfo())
+
+	var (
+		err  error
+		msg  string
+		path string
+		fh   *os.File
+		tmp  string
+	)
+
+	if path, err = g.chooseFile("Export library", gtk.FILE_CHOOSER_ACTION_SAVE, libraryFileName()); err != nil {
+		msg = err.Error()
+		goto ERROR
+	} else if path == "" {
+		g.log.Println("[DEBUG] User cancelled export")
+		return
+	}
+
+	// Like the backups, we write to a temporary file first, so a failed
+	// export does not clobber a previous one.
+	tmp = path + ".tmp"
+
+	if fh, err = os.Create(tmp); err != nil {
+		msg = fmt.Sprintf("Cannot create %s: %s",
+			tmp,
+			err.Error())
+		goto ERROR
+	} else if err = g.db.Export(fh); err != nil {
+		fh.Close()     // nolint: errcheck,gosec
+		os.Remove(tmp) // nolint: errcheck,gosec
+		msg = fmt.Sprintf("Cannot export library: %s",
+			err.Error())
+		goto ERROR
+	} else if err = fh.Close(); err != nil {
+		os.Remove(tmp) // nolint: errcheck,gosec
+		msg = fmt.Sprintf("Cannot close %s: %s",
+			tmp,
+			err.Error())
+		goto ERROR
+	} else if err = os.Rename(tmp, path); err != nil {
+		os.Remove(tmp) // nolint: errcheck,gosec
+		msg = fmt.Sprintf("Cannot rename %s to %s: %s",
+			tmp,
+			path,
+			err.Error())
+		goto ERROR
+	}
+
+	g.displayMsg(fmt.Sprintf("Exported library to %s", path))
+	return
+
+ERROR:
+	g.log.Printf("[ERROR] %s\n", msg)
+	g.displayMsg(msg)
+} // func (g *GUI) handleExportLibrary()
+
+// handleImportLibrary adds the content of a JSON file written by
+// handleExportLibrary to the database.
+func (g *GUI) handleImportLibrary() {
+	krylib.Trace()
+	defer g.log.Printf("[TRACE] EXIT %s\n",
+		krylib.TraceInfo())
+
+	var (
+		err    error
+		msg    string
+		path   string
+		fh     *os.File
+		report *database.ImportReport
+	)
+
+	if g.scanner.Active() {
+		g.displayMsg("Please wait for the scan to finish before importing a library.")
+		return
+	} else if path, err = g.chooseFile("Import library", gtk.FILE_CHOOSER_ACTION_OPEN, ""); err != nil {
+		msg = err.Error()
+		goto ERROR
+	} else if path == "" {
+		g.log.Println("[DEBUG] User cancelled import")
+		return
+	} else if fh, err = os.Open(path); err != nil {
+		msg = fmt.Sprintf("Cannot open %s: %s",
+			path,
+			err.Error())
+		goto ERROR
+	}
+
+	defer fh.Close() // nolint: errcheck
+
+	if report, err = g.db.Import(fh); err != nil {
+		msg = fmt.Sprintf("Cannot import library from %s: %s",
+			path,
+			err.Error())
+		goto ERROR
+	}
+
+	for _, c := range report.Conflicts {
+		g.log.Printf("[INFO] Import conflict: %s\n", c)
+	}
+
+	g.reloadTags()
+	g.displayMsg(importSummary(path, report))
+	return
+
+ERROR:
+	g.log.Printf("[ERROR] %s\n", msg)
+	g.displayMsg(msg)
+} // func (g *GUI) handleImportLibrary()
+
+func importSummary(path string, report *database.ImportReport) string {
+	var (
+		sections = make([]string, 0, len(report.Added))
+		b        strings.Builder
+	)
+
+	for s := range report.Added {
+		sections = append(sections, s)
+	}
+
+	sort.Strings(sections)
+
+	fmt.Fprintf(&b, "Imported library from %s\n\nAdded:\n", path)
+
+	for _, s := range sections {
+		fmt.Fprintf(&b, "\t%s: %d\n", s, report.Added[s])
+	}
+
+	if len(report.Conflicts) == 0 {
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "\n%d conflicts:\n", len(report.Conflicts))
+
+	for i, c := range report.Conflicts {
+		if i == maxConflictsShown {
+			fmt.Fprintf(&b, "\t… and %d more, see the log for details\n",
+				len(report.Conflicts)-i)
+			break
+		}
+		fmt.Fprintf(&b, "\t%s\n", c)
+	}
+
+	return b.String()
+} // func importSummary(path string, report *database.ImportReport) string
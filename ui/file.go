@@ -116,7 +116,13 @@ func (g *GUI) handleFileListClick(view *gtk.TreeView, evt *gdk.Event) {
 			id,
 			err.Error())
 		goto ERROR
-	} else if contextMenu, err = g.mkFileContextMenu(path, f); err != nil {
+	}
+
+	// The context menu needs the path in the ListStore, not in the filter
+	// the view displays.
+	path = g.tabs[tiFile].filter.ConvertPathToChildPath(path)
+
+	if contextMenu, err = g.mkFileContextMenu(path, f); err != nil {
 		msg = fmt.Sprintf("Cannot create File context menu: %s",
 			err.Error())
 		goto ERROR
@@ -136,13 +142,21 @@ func (g *GUI) mkFileContextMenu(path *gtk.TreePath, f *objects.File) (*gtk.Menu,
 	defer g.log.Printf("[TRACE] EXIT %s\n",
 		krylib.TraceInfo())
 	var (
-		err                                    error
-		msg                                    string
-		actItem, dirItem, tagItem, playItem    *gtk.MenuItem
-		hideItem                               *gtk.CheckMenuItem
-		contextMenu, tagMenu, actMenu, dirMenu *gtk.Menu
+		err                                             error
+		msg                                             string
+		actItem, dirItem, tagItem, playItem             *gtk.MenuItem
+		linkItem, linkAddItem, epItem, watchItem        *gtk.MenuItem
+		fetchItem                                       *gtk.MenuItem
+		hideItem                                        *gtk.CheckMenuItem
+		contextMenu, tagMenu, actMenu, dirMenu, urlMenu *gtk.Menu
+		epMenu                                          *gtk.Menu
+		watchLabel                                      = "Mark as _watched"
 	)
 
+	if f.Watched {
+		watchLabel = "Mark as _unwatched"
+	}
+
 	if contextMenu, err = gtk.MenuNew(); err != nil {
 		msg = fmt.Sprintf("Cannot create context menu: %s",
 			err.Error())
@@ -179,18 +193,57 @@ func (g *GUI) mkFileContextMenu(path *gtk.TreePath, f *objects.File) (*gtk.Menu,
 		msg = fmt.Sprintf("Cannot create context menu item Play: %s",
 			err.Error())
 		goto ERROR
+	} else if urlMenu, err = g.getFileLinks(f); err != nil {
+		msg = fmt.Sprintf("Cannot create submenu Links: %s",
+			err.Error())
+		goto ERROR
+	} else if linkItem, err = gtk.MenuItemNewWithMnemonic("_Links"); err != nil {
+		msg = fmt.Sprintf("Cannot create context menu item Links: %s",
+			err.Error())
+		goto ERROR
+	} else if linkAddItem, err = gtk.MenuItemNewWithMnemonic("Add _link"); err != nil {
+		msg = fmt.Sprintf("Cannot create context menu item Add link: %s",
+			err.Error())
+		goto ERROR
+	} else if epMenu, err = g.mkFileEpisodeMenu(f); err != nil {
+		msg = fmt.Sprintf("Cannot create submenu Episode: %s",
+			err.Error())
+		goto ERROR
+	} else if epItem, err = gtk.MenuItemNewWithMnemonic("_Episode"); err != nil {
+		msg = fmt.Sprintf("Cannot create context menu item Episode: %s",
+			err.Error())
+		goto ERROR
+	} else if watchItem, err = gtk.MenuItemNewWithMnemonic(watchLabel); err != nil {
+		msg = fmt.Sprintf("Cannot create context menu item %s: %s",
+			watchLabel,
+			err.Error())
+		goto ERROR
+	} else if fetchItem, err = gtk.MenuItemNewWithMnemonic("_Fetch metadata…"); err != nil {
+		msg = fmt.Sprintf("Cannot create context menu item Fetch metadata: %s",
+			err.Error())
+		goto ERROR
 	}
 
 	playItem.Connect("activate", func() { g.playFile(f) })
+	watchItem.Connect("activate", func() { g.setWatched(f, !f.Watched) })
+	linkAddItem.Connect("activate", g.mkFileAddURLHandler(f))
+	fetchItem.Connect("activate", g.mkFetchMetadataHandler(f))
 
 	actItem.SetSubmenu(actMenu)
 	tagItem.SetSubmenu(tagMenu)
 	dirItem.SetSubmenu(dirMenu)
+	linkItem.SetSubmenu(urlMenu)
+	epItem.SetSubmenu(epMenu)
 
 	contextMenu.Append(tagItem)
 	contextMenu.Append(actItem)
 	contextMenu.Append(dirItem)
+	contextMenu.Append(epItem)
+	contextMenu.Append(linkItem)
+	contextMenu.Append(linkAddItem)
+	contextMenu.Append(fetchItem)
 	contextMenu.Append(hideItem)
+	contextMenu.Append(watchItem)
 	contextMenu.Append(playItem)
 
 	return contextMenu, nil
@@ -200,6 +253,84 @@ ERROR:
 	return nil, err
 } // func (g *GUI) mkFileContextMenu(path *gtk.TreePath, f *objects.File) (*gtk.Menu, error)
 
+func (g *GUI) getFileLinks(f *objects.File) (*gtk.Menu, error) {
+	krylib.Trace()
+	var (
+		err   error
+		msg   string
+		menu  *gtk.Menu
+		links []objects.Link
+	)
+
+	if links, err = g.db.FileURLGetByFile(f); err != nil {
+		msg = fmt.Sprintf("Cannot get Links for %s: %s",
+			f.DisplayTitle(),
+			err.Error())
+		goto ERROR
+	} else if menu, err = gtk.MenuNew(); err != nil {
+		msg = fmt.Sprintf("Cannot create URL menu for %s: %s",
+			f.DisplayTitle(),
+			err.Error())
+		goto ERROR
+	}
+
+	for lidx := range links {
+		var (
+			item *gtk.MenuItem
+			l    = &links[lidx]
+		)
+
+		if item, err = gtk.MenuItemNewWithLabel(l.DisplayTitle()); err != nil {
+			msg = fmt.Sprintf("Cannot create menu handler for URL %q: %s",
+				l.DisplayTitle(),
+				err.Error())
+			goto ERROR
+		}
+
+		item.Connect("activate", g.mkURLHandler(l))
+		menu.Append(item)
+	}
+
+	return menu, nil
+
+ERROR:
+	g.log.Printf("[ERROR] %s\n", msg)
+	g.displayMsg(msg)
+	return nil, err
+} // func (g *GUI) getFileLinks(f *objects.File) (*gtk.Menu, error)
+
+func (g *GUI) mkFileAddURLHandler(f *objects.File) func() {
+	krylib.Trace()
+	defer g.log.Printf("[TRACE] EXIT %s\n",
+		krylib.TraceInfo())
+	return func() {
+		krylib.Trace()
+		defer g.log.Printf("[TRACE] EXIT %s\n",
+			krylib.TraceInfo())
+		var (
+			err error
+			l   *objects.Link
+		)
+
+		if l = g.linkDialog(); l == nil {
+			return
+		} else if err = g.db.FileURLAdd(f, l); err != nil {
+			var msg = fmt.Sprintf("Cannot attach Link %q to %s: %s",
+				l.URL.String(),
+				f.DisplayTitle(),
+				err.Error())
+			g.log.Printf("[ERROR] %s\n", msg)
+			g.displayMsg(msg)
+			return
+		}
+
+		g.log.Printf("[DEBUG] Added URL %q (%s) to %s\n",
+			l.URL.String(),
+			l.Title,
+			f.DisplayTitle())
+	}
+} // func (g *GUI) mkFileAddURLHandler(f *objects.File) func()
+
 func (g *GUI) mkFileTagMenu(path *gtk.TreePath, f *objects.File) (*gtk.Menu, error) {
 	krylib.Trace()
 	defer g.log.Printf("[TRACE] EXIT %s\n",
@@ -329,7 +460,7 @@ func (g *GUI) mkFileTagListUpdater(path *gtk.TreePath, f *objects.File) func() b
 				f.DisplayTitle(),
 				err.Error())
 			goto ERROR
-		} else if err = store.Set(iter, []int{6}, []interface{}{tstr}); err != nil {
+		} else if err = store.Set(iter, []int{9}, []interface{}{tstr}); err != nil {
 			msg = fmt.Sprintf("Cannot set Tag list for File %s: %s",
 				f.DisplayTitle(),
 				err.Error())
@@ -353,6 +484,7 @@ func (g *GUI) mkFileActorMenu(path *gtk.TreePath, f *objects.File) (*gtk.Menu, e
 		err           error
 		msg           string
 		actors        map[int64]objects.Person
+		aliases       map[int64][]string
 		alist, people []objects.Person
 		menu          *gtk.Menu
 	)
@@ -361,6 +493,10 @@ func (g *GUI) mkFileActorMenu(path *gtk.TreePath, f *objects.File) (*gtk.Menu, e
 		msg = fmt.Sprintf("Cannot load all people from Database: %s",
 			err.Error())
 		goto ERROR
+	} else if aliases, err = g.db.PersonAliasGetAll(); err != nil {
+		msg = fmt.Sprintf("Cannot load aliases from Database: %s",
+			err.Error())
+		goto ERROR
 	} else if alist, err = g.db.ActorGetByFile(f); err != nil {
 		msg = fmt.Sprintf("Cannot load Actors for %s from Database: %s",
 			f.DisplayTitle(),
@@ -388,7 +524,7 @@ func (g *GUI) mkFileActorMenu(path *gtk.TreePath, f *objects.File) (*gtk.Menu, e
 
 		_, linked = actors[p.ID]
 
-		if item, err = gtk.CheckMenuItemNewWithLabel(p.Name); err != nil {
+		if item, err = gtk.CheckMenuItemNewWithLabel(personLabel(&p, aliases)); err != nil {
 			msg = fmt.Sprintf("Cannot create gtk.CheckMenuItem for Person %s: %s",
 				p.Name,
 				err.Error())
@@ -497,7 +633,7 @@ func (g *GUI) mkFileActorListUpdate(path *gtk.TreePath, f *objects.File) func()
 				path,
 				err.Error())
 			goto ERROR
-		} else if err = store.Set(iter, []int{5}, []interface{}{astr}); err != nil {
+		} else if err = store.Set(iter, []int{8}, []interface{}{astr}); err != nil {
 			msg = fmt.Sprintf("Error updating Actor list for %s: %s",
 				f.DisplayTitle(),
 				err.Error())
@@ -521,6 +657,7 @@ func (g *GUI) mkFileDirectorMenu(path *gtk.TreePath, f *objects.File) (*gtk.Menu
 		err           error
 		msg           string
 		directors     map[int64]objects.Person
+		aliases       map[int64][]string
 		alist, people []objects.Person
 		menu          *gtk.Menu
 	)
@@ -529,6 +666,10 @@ func (g *GUI) mkFileDirectorMenu(path *gtk.TreePath, f *objects.File) (*gtk.Menu
 		msg = fmt.Sprintf("Cannot load all people from Database: %s",
 			err.Error())
 		goto ERROR
+	} else if aliases, err = g.db.PersonAliasGetAll(); err != nil {
+		msg = fmt.Sprintf("Cannot load aliases from Database: %s",
+			err.Error())
+		goto ERROR
 	} else if alist, err = g.db.DirectorGetByFile(f); err != nil {
 		msg = fmt.Sprintf("Cannot load Directors for %s from Database: %s",
 			f.DisplayTitle(),
@@ -556,7 +697,7 @@ func (g *GUI) mkFileDirectorMenu(path *gtk.TreePath, f *objects.File) (*gtk.Menu
 
 		_, linked = directors[p.ID]
 
-		if item, err = gtk.CheckMenuItemNewWithLabel(p.Name); err != nil {
+		if item, err = gtk.CheckMenuItemNewWithLabel(personLabel(&p, aliases)); err != nil {
 			msg = fmt.Sprintf("Cannot create gtk.CheckMenuItem for Person %s: %s",
 				p.Name,
 				err.Error())
@@ -662,7 +803,7 @@ func (g *GUI) mkFileDirectorListUpdate(path *gtk.TreePath, f *objects.File) func
 				path,
 				err.Error())
 			goto ERROR
-		} else if err = store.Set(iter, []int{5}, []interface{}{astr}); err != nil {
+		} else if err = store.Set(iter, []int{7}, []interface{}{astr}); err != nil {
 			msg = fmt.Sprintf("Error updating Director list for %s: %s",
 				f.DisplayTitle(),
 				err.Error())
@@ -711,6 +852,10 @@ func (g *GUI) mkFileEditHandler(colIdx int) func(*gtk.CellRendererText, string,
 				pStr,
 				err.Error())
 			goto ERROR
+		} else if path = g.tabs[tiFile].filter.ConvertPathToChildPath(path); path == nil {
+			msg = fmt.Sprintf("Cannot convert TreePath %s to path in ListStore",
+				pStr)
+			goto ERROR
 		} else if iter, err = store.GetIter(path); err != nil {
 			msg = fmt.Sprintf("Cannot get TreeIter from TreePath %s: %s",
 				path,
@@ -747,7 +892,7 @@ func (g *GUI) mkFileEditHandler(colIdx int) func(*gtk.CellRendererText, string,
 				goto ERROR
 			}
 			val = text
-		case 3: // Year
+		case 5: // Year
 			if year, err = strconv.ParseInt(text, 10, 64); err != nil {
 				msg = fmt.Sprintf("Cannot parse year %q: %s",
 					text,
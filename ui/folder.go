@@ -0,0 +1,474 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/ui/folder.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 13. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-13 23:41:06 krylon>
+
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/blicero/blockbuster/common"
+	"github.com/blicero/blockbuster/nfo"
+	"github.com/blicero/blockbuster/objects"
+	"github.com/blicero/blockbuster/tree"
+	"github.com/blicero/krylib"
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+func (g *GUI) handleFolderListClick(view *gtk.TreeView, evt *gdk.Event) {
+	krylib.Trace()
+	var be = gdk.EventButtonNewFromEvent(evt)
+
+	if be.Button() != gdk.BUTTON_SECONDARY {
+		return
+	}
+
+	var (
+		err    error
+		msg    string
+		exists bool
+		path   *gtk.TreePath
+		imodel gtk.ITreeModel
+		model  *gtk.TreeModel
+		iter   *gtk.TreeIter
+		val    *glib.Value
+		gval   interface{}
+		folder *objects.Folder
+		menu   *gtk.Menu
+	)
+
+	if path, _, _, _, exists = view.GetPathAtPos(int(be.X()), int(be.Y())); !exists {
+		return
+	} else if imodel, err = view.GetModel(); err != nil {
+		g.log.Printf("[ERROR] Cannot get Model from View: %s\n",
+			err.Error())
+		return
+	}
+
+	model = imodel.ToTreeModel()
+
+	if iter, err = model.GetIter(path); err != nil {
+		msg = fmt.Sprintf("Cannot get Iter from TreePath %s: %s",
+			path,
+			err.Error())
+		goto ERROR
+	} else if val, err = model.GetValue(iter, 1); err != nil {
+		msg = fmt.Sprintf("Cannot get path from column 1: %s",
+			err.Error())
+		goto ERROR
+	} else if gval, err = val.GoValue(); err != nil {
+		msg = fmt.Sprintf("Cannot get go value for path: %s",
+			err.Error())
+		goto ERROR
+	} else if folder, err = g.db.FolderGetByPath(gval.(string)); err != nil {
+		msg = fmt.Sprintf("Cannot lookup Folder %s: %s",
+			gval,
+			err.Error())
+		goto ERROR
+	} else if folder == nil {
+		msg = fmt.Sprintf("Folder %s was not found in database", gval)
+		goto ERROR
+	} else if menu, err = g.mkFolderContextMenu(folder); err != nil {
+		msg = fmt.Sprintf("Cannot create context Menu for Folder %s: %s",
+			folder.Path,
+			err.Error())
+		goto ERROR
+	}
+
+	menu.ShowAll()
+	menu.PopupAtPointer(evt)
+	return
+
+ERROR:
+	g.log.Printf("[ERROR] %s\n", msg)
+	g.displayMsg(msg)
+} // func (g *GUI) handleFolderListClick(view *gtk.TreeView, evt *gdk.Event)
+
+func (g *GUI) mkFolderContextMenu(f *objects.Folder) (*gtk.Menu, error) {
+	var (
+		err       error
+		menu      *gtk.Menu
+		itemNfo   *gtk.MenuItem
+		itemScan  *gtk.MenuItem
+		itemRules *gtk.MenuItem
+		itemHist  *gtk.MenuItem
+	)
+
+	if menu, err = gtk.MenuNew(); err != nil {
+		return nil, err
+	} else if itemScan, err = gtk.MenuItemNewWithMnemonic("_Scan"); err != nil {
+		return nil, err
+	} else if itemNfo, err = gtk.MenuItemNewWithMnemonic("Write ._nfo files"); err != nil {
+		return nil, err
+	} else if itemRules, err = gtk.MenuItemNewWithMnemonic("Scan _rules…"); err != nil {
+		return nil, err
+	} else if itemHist, err = gtk.MenuItemNewWithMnemonic("Scan _history…"); err != nil {
+		return nil, err
+	}
+
+	itemScan.Connect("activate", func() { g.scanner.ScanPath(context.Background(), f.Path) })
+	itemNfo.Connect("activate", func() { g.exportNFO(f) })
+	itemRules.Connect("activate", func() { g.editScanRules(f) })
+	itemHist.Connect("activate", func() { g.showScanHistory(f) })
+
+	menu.Append(itemScan)
+	menu.Append(itemNfo)
+	menu.Append(itemRules)
+	menu.Append(itemHist)
+
+	return menu, nil
+} // func (g *GUI) mkFolderContextMenu(f *objects.Folder) (*gtk.Menu, error)
+
+// exportNFO writes an .nfo file for every File in the Folder, so Kodi,
+// Jellyfin and the like see the same metadata we do.
+func (g *GUI) exportNFO(f *objects.Folder) {
+	krylib.Trace()
+	defer g.log.Printf("[TRACE] EXIT %s\n",
+		krylib.TraceInfo())
+
+	var (
+		err error
+		cnt int
+		msg string
+	)
+
+	if !g.confirm(fmt.Sprintf("Write .nfo files for all Files in %s? Existing .nfo files will be updated.",
+		f.Path)) {
+		return
+	} else if cnt, err = nfo.ExportFolder(g.db, f); err != nil {
+		msg = fmt.Sprintf("Cannot write .nfo files for %s (%d written so far): %s",
+			f.Path,
+			cnt,
+			err.Error())
+		g.log.Printf("[ERROR] %s\n", msg)
+		g.displayMsg(msg)
+		return
+	}
+
+	msg = fmt.Sprintf("Wrote %d .nfo files for %s", cnt, f.Path)
+	g.log.Printf("[INFO] %s\n", msg)
+	g.statusbar.Push(statusScan, msg)
+} // func (g *GUI) exportNFO(f *objects.Folder)
+
+// editScanRules lets the user edit the scan rules of a Folder.
+func (g *GUI) editScanRules(f *objects.Folder) {
+	krylib.Trace()
+	defer g.log.Printf("[TRACE] EXIT %s\n",
+		krylib.TraceInfo())
+
+	const mb = 1024 * 1024
+
+	var (
+		err                    error
+		msg, suffixStr, exStr  string
+		minSize                int64
+		suffixes, exclude      []string
+		dlg                    *gtk.Dialog
+		dbox                   *gtk.Box
+		grid                   *gtk.Grid
+		sizeLbl, sufLbl, exLbl *gtk.Label
+		hint                   *gtk.Label
+		spin                   *gtk.SpinButton
+		entry                  *gtk.Entry
+		scroll                 *gtk.ScrolledWindow
+		view                   *gtk.TextView
+		buf                    *gtk.TextBuffer
+	)
+
+	if dlg, err = gtk.DialogNewWithButtons(
+		fmt.Sprintf("Scan rules for %s", f.Path),
+		g.win,
+		gtk.DIALOG_MODAL,
+		[]interface{}{
+			"_Cancel",
+			gtk.RESPONSE_CANCEL,
+			"_OK",
+			gtk.RESPONSE_OK,
+		},
+	); err != nil {
+		msg = fmt.Sprintf("Cannot create dialog: %s",
+			err.Error())
+		goto ERROR
+	}
+
+	defer dlg.Close()
+
+	// See handleTagAdd on why we add the OK button again.
+	if _, err = dlg.AddButton("OK", gtk.RESPONSE_OK); err != nil {
+		msg = fmt.Sprintf("Cannot add OK button to dialog: %s",
+			err.Error())
+		goto ERROR
+	} else if dbox, err = dlg.GetContentArea(); err != nil {
+		msg = fmt.Sprintf("Cannot get ContentArea of dialog: %s",
+			err.Error())
+		goto ERROR
+	} else if grid, err = gtk.GridNew(); err != nil {
+		msg = fmt.Sprintf("Cannot create Grid: %s",
+			err.Error())
+		goto ERROR
+	} else if sizeLbl, err = gtk.LabelNew("Minimum size in MB (0 for the default):"); err != nil {
+		msg = fmt.Sprintf("Cannot create Label: %s",
+			err.Error())
+		goto ERROR
+	} else if sufLbl, err = gtk.LabelNew("Additional suffixes:"); err != nil {
+		msg = fmt.Sprintf("Cannot create Label: %s",
+			err.Error())
+		goto ERROR
+	} else if exLbl, err = gtk.LabelNew("Exclude:"); err != nil {
+		msg = fmt.Sprintf("Cannot create Label: %s",
+			err.Error())
+		goto ERROR
+	} else if hint, err = gtk.LabelNew(fmt.Sprintf("One pattern per line, like in a .gitignore file.\nPatterns from %s files in the Folder apply, too.",
+		tree.IgnoreFile)); err != nil {
+		msg = fmt.Sprintf("Cannot create Label: %s",
+			err.Error())
+		goto ERROR
+	} else if spin, err = gtk.SpinButtonNewWithRange(0, 1024*1024, 1); err != nil {
+		msg = fmt.Sprintf("Cannot create SpinButton: %s",
+			err.Error())
+		goto ERROR
+	} else if entry, err = gtk.EntryNew(); err != nil {
+		msg = fmt.Sprintf("Cannot create Entry: %s",
+			err.Error())
+		goto ERROR
+	} else if scroll, err = gtk.ScrolledWindowNew(nil, nil); err != nil {
+		msg = fmt.Sprintf("Cannot create ScrolledWindow: %s",
+			err.Error())
+		goto ERROR
+	} else if view, err = gtk.TextViewNew(); err != nil {
+		msg = fmt.Sprintf("Cannot create TextView: %s",
+			err.Error())
+		goto ERROR
+	} else if buf, err = view.GetBuffer(); err != nil {
+		msg = fmt.Sprintf("Cannot get TextBuffer: %s",
+			err.Error())
+		goto ERROR
+	}
+
+	spin.SetValue(float64(f.MinSize / mb))
+	entry.SetText(strings.Join(f.Suffixes, " "))
+	entry.SetPlaceholderText("e.g. ts m2ts")
+	buf.SetText(strings.Join(f.Exclude, "\n"))
+	scroll.SetSizeRequest(400, 150)
+	scroll.Add(view)
+
+	grid.Attach(sizeLbl, 0, 0, 1, 1)
+	grid.Attach(spin, 1, 0, 1, 1)
+	grid.Attach(sufLbl, 0, 1, 1, 1)
+	grid.Attach(entry, 1, 1, 1, 1)
+	grid.Attach(exLbl, 0, 2, 1, 1)
+	grid.Attach(scroll, 1, 2, 1, 1)
+	grid.Attach(hint, 0, 3, 2, 1)
+
+	dbox.PackStart(grid, true, true, 0)
+	dlg.ShowAll()
+
+	if res := dlg.Run(); res != gtk.RESPONSE_OK {
+		g.log.Printf("[DEBUG] User cancelled editing scan rules for %s\n",
+			f.Path)
+		return
+	} else if suffixStr, err = entry.GetText(); err != nil {
+		msg = fmt.Sprintf("Cannot get suffixes: %s",
+			err.Error())
+		goto ERROR
+	} else if exStr, err = buf.GetText(buf.GetStartIter(), buf.GetEndIter(), false); err != nil {
+		msg = fmt.Sprintf("Cannot get exclude patterns: %s",
+			err.Error())
+		goto ERROR
+	}
+
+	minSize = int64(spin.GetValueAsInt()) * mb
+	suffixes = strings.Fields(strings.ReplaceAll(suffixStr, ",", " "))
+
+	for _, line := range strings.Split(exStr, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			exclude = append(exclude, line)
+		}
+	}
+
+	if err = tree.CheckRules(minSize, suffixes, exclude); err != nil {
+		msg = fmt.Sprintf("Invalid scan rules for %s: %s",
+			f.Path,
+			err.Error())
+		goto ERROR
+	} else if err = g.db.FolderUpdateRules(f, minSize, suffixes, exclude); err != nil {
+		msg = fmt.Sprintf("Cannot save scan rules for %s: %s",
+			f.Path,
+			err.Error())
+		goto ERROR
+	} else if err = g.db.DirCacheClear(f); err != nil {
+		// With different rules, directories that have not changed may
+		// still contain Files we want now, or Files we do not want.
+		msg = fmt.Sprintf("Cannot clear directory cache for %s: %s",
+			f.Path,
+			err.Error())
+		goto ERROR
+	}
+
+	msg = fmt.Sprintf("Updated scan rules for %s", f.Path)
+	g.log.Printf("[INFO] %s\n", msg)
+	g.statusbar.Push(statusScan, msg)
+
+	if g.confirm(fmt.Sprintf("Scan %s with the new rules now?", f.Path)) {
+		g.scanner.ScanPath(context.Background(), f.Path)
+	}
+	return
+
+ERROR:
+	g.log.Printf("[ERROR] %s\n", msg)
+	g.displayMsg(msg)
+} // func (g *GUI) editScanRules(f *objects.Folder)
+
+// scanHistoryMax is the number of scans the scan history dialog displays.
+const scanHistoryMax = 50
+
+var scanHistoryView = view{
+	title: "Scan history",
+	store: storeList,
+	columns: []column{
+		column{
+			colType: glib.TYPE_STRING,
+			title:   "Started",
+		},
+		column{
+			colType: glib.TYPE_STRING,
+			title:   "Duration",
+		},
+		column{
+			colType: glib.TYPE_STRING,
+			title:   "Status",
+		},
+		column{
+			colType: glib.TYPE_INT,
+			title:   "Directories",
+		},
+		column{
+			colType: glib.TYPE_INT,
+			title:   "Unchanged",
+		},
+		column{
+			colType: glib.TYPE_INT,
+			title:   "Files",
+		},
+		column{
+			colType: glib.TYPE_INT,
+			title:   "Unchanged",
+		},
+		column{
+			colType: glib.TYPE_INT,
+			title:   "New",
+		},
+		column{
+			colType: glib.TYPE_INT,
+			title:   "Changed",
+		},
+		column{
+			colType: glib.TYPE_INT,
+			title:   "Errors",
+		},
+	},
+}
+
+// showScanHistory displays the most recent scans of a Folder.
+func (g *GUI) showScanHistory(f *objects.Folder) {
+	krylib.Trace()
+	defer g.log.Printf("[TRACE] EXIT %s\n",
+		krylib.TraceInfo())
+
+	var (
+		err    error
+		msg    string
+		runs   []objects.ScanRun
+		dlg    *gtk.Dialog
+		dbox   *gtk.Box
+		scroll *gtk.ScrolledWindow
+		model  gtk.ITreeModel
+		tv     *gtk.TreeView
+	)
+
+	if runs, err = g.db.ScanRunGetByFolder(f, scanHistoryMax); err != nil {
+		msg = fmt.Sprintf("Cannot get scan history of %s: %s",
+			f.Path,
+			err.Error())
+		goto ERROR
+	} else if len(runs) == 0 {
+		g.displayMsg(fmt.Sprintf("%s has not been scanned, yet.", f.Path))
+		return
+	} else if dlg, err = gtk.DialogNewWithButtons(
+		fmt.Sprintf("Scan history of %s", f.Path),
+		g.win,
+		gtk.DIALOG_MODAL,
+		[]interface{}{
+			"_Close",
+			gtk.RESPONSE_CLOSE,
+		},
+	); err != nil {
+		msg = fmt.Sprintf("Cannot create dialog: %s",
+			err.Error())
+		goto ERROR
+	}
+
+	defer dlg.Close()
+
+	if dbox, err = dlg.GetContentArea(); err != nil {
+		msg = fmt.Sprintf("Cannot get ContentArea of dialog: %s",
+			err.Error())
+		goto ERROR
+	} else if scroll, err = gtk.ScrolledWindowNew(nil, nil); err != nil {
+		msg = fmt.Sprintf("Cannot create ScrolledWindow: %s",
+			err.Error())
+		goto ERROR
+	} else if model, tv, err = scanHistoryView.create(nil); err != nil {
+		msg = fmt.Sprintf("Cannot create TreeView: %s",
+			err.Error())
+		goto ERROR
+	}
+
+	for idx := range runs {
+		var (
+			r     = &runs[idx]
+			store = model.(*gtk.ListStore)
+			iter  = store.Append()
+		)
+
+		if err = store.Set(
+			iter,
+			[]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+			[]interface{}{
+				r.Start.Format(common.TimestampFormat),
+				r.Duration().Round(time.Millisecond).String(),
+				r.Status.String(),
+				r.Dirs,
+				r.DirsSkipped,
+				r.Files,
+				r.FilesSkipped,
+				r.FilesNew,
+				r.FilesChanged,
+				r.Errors,
+			},
+		); err != nil {
+			msg = fmt.Sprintf("Cannot add scan from %s to Store: %s",
+				r.Start.Format(common.TimestampFormat),
+				err.Error())
+			goto ERROR
+		}
+	}
+
+	scroll.SetSizeRequest(800, 300)
+	scroll.Add(tv)
+	dbox.PackStart(scroll, true, true, 0)
+	dlg.ShowAll()
+	dlg.Run()
+	return
+
+ERROR:
+	g.log.Printf("[ERROR] %s\n", msg)
+	g.displayMsg(msg)
+} // func (g *GUI) showScanHistory(f *objects.Folder)
@@ -8,7 +8,10 @@ package ui
 
 import (
 	"fmt"
+	"net/url"
+	"time"
 
+	"github.com/blicero/blockbuster/objects"
 	"github.com/blicero/krylib"
 	"github.com/gotk3/gotk3/gtk"
 )
@@ -33,6 +36,22 @@ func createCol(title string, id int) (*gtk.TreeViewColumn, *gtk.CellRendererText
 	return col, renderer, nil
 } // func createCol(title string, id int) (*gtk.TreeViewColumn, *gtk.CellRendererText, error)
 
+// fmtDuration formats the duration of a video as hours, minutes, and
+// seconds, e.g. 1:42:07. A duration of zero, i.e. unknown, yields an empty
+// string.
+func fmtDuration(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+
+	var secs = int64(d.Round(time.Second) / time.Second)
+
+	return fmt.Sprintf("%d:%02d:%02d",
+		secs/3600,
+		secs/60%60,
+		secs%60)
+} // func fmtDuration(d time.Duration) string
+
 func (g *GUI) displayMsg(msg string) {
 	krylib.Trace()
 	defer g.log.Printf("[TRACE] EXIT %s\n",
@@ -78,3 +97,410 @@ func (g *GUI) displayMsg(msg string) {
 	dlg.ShowAll()
 	dlg.Run()
 } // func (g *GUI) displayMsg(msg string)
+
+// confirm asks the user a yes-or-no question and returns true if they
+// answered with yes.
+func (g *GUI) confirm(question string) bool {
+	krylib.Trace()
+	defer g.log.Printf("[TRACE] EXIT %s\n",
+		krylib.TraceInfo())
+
+	var (
+		err error
+		dlg *gtk.Dialog
+		lbl *gtk.Label
+		box *gtk.Box
+	)
+
+	if dlg, err = gtk.DialogNewWithButtons(
+		"Question",
+		g.win,
+		gtk.DIALOG_MODAL,
+		[]interface{}{
+			"_No",
+			gtk.RESPONSE_NO,
+			"_Yes",
+			gtk.RESPONSE_YES,
+		},
+	); err != nil {
+		g.log.Printf("[ERROR] Cannot create dialog to ask question: %s\nQuestion would've been %q\n",
+			err.Error(),
+			question)
+		return false
+	}
+
+	defer dlg.Close()
+
+	if _, err = dlg.AddButton("_Yes", gtk.RESPONSE_YES); err != nil {
+		g.log.Printf("[ERROR] Cannot add Yes button to Dialog: %s\n",
+			err.Error())
+		return false
+	} else if lbl, err = gtk.LabelNew(question); err != nil {
+		g.log.Printf("[ERROR] Cannot create label to ask question: %s\nQuestion would've been: %q\n",
+			err.Error(),
+			question)
+		return false
+	} else if box, err = dlg.GetContentArea(); err != nil {
+		g.log.Printf("[ERROR] Cannot get ContentArea of Dialog to ask question: %s\nQuestion would've been %q\n",
+			err.Error(),
+			question)
+		return false
+	}
+
+	box.PackStart(lbl, true, true, 0)
+	dlg.ShowAll()
+
+	return dlg.Run() == gtk.RESPONSE_YES
+} // func (g *GUI) confirm(question string) bool
+
+// linkDialog asks the user for a URL, along with a title and a description.
+// It returns nil if the user cancelled the dialog or did not enter a valid
+// URL.
+func (g *GUI) linkDialog() *objects.Link {
+	krylib.Trace()
+	defer g.log.Printf("[TRACE] EXIT %s\n",
+		krylib.TraceInfo())
+	var (
+		err                    error
+		s                      string
+		l                      objects.Link
+		dlg                    *gtk.Dialog
+		dbox                   *gtk.Box
+		grid                   *gtk.Grid
+		uLbl, tLbl, dLbl       *gtk.Label
+		uEntry, tEntry, dEntry *gtk.Entry
+	)
+
+	if dlg, err = gtk.DialogNewWithButtons(
+		"Add URL",
+		g.win,
+		gtk.DIALOG_MODAL,
+		[]interface{}{
+			"_Cancel",
+			gtk.RESPONSE_CANCEL,
+			"_OK",
+			gtk.RESPONSE_OK,
+		},
+	); err != nil {
+		g.log.Printf("[ERROR] Cannot create Dialog for adding URL: %s\n",
+			err.Error())
+		return nil
+	}
+
+	defer dlg.Close()
+
+	if _, err = dlg.AddButton("OK", gtk.RESPONSE_OK); err != nil {
+		g.log.Printf("[ERROR] Cannot add OK button to AddURL Dialog: %s\n",
+			err.Error())
+		return nil
+	} else if grid, err = gtk.GridNew(); err != nil {
+		g.log.Printf("[ERROR] Cannot create gtk.Grid for AddURL Dialog: %s\n",
+			err.Error())
+		return nil
+	} else if uLbl, err = gtk.LabelNew("URL:"); err != nil {
+		g.log.Printf("[ERROR] Cannot create URL Label: %s\n",
+			err.Error())
+		return nil
+	} else if tLbl, err = gtk.LabelNew("Title:"); err != nil {
+		g.log.Printf("[ERROR] Cannot create Title Label: %s\n",
+			err.Error())
+		return nil
+	} else if dLbl, err = gtk.LabelNew("Description:"); err != nil {
+		g.log.Printf("[ERROR] Cannot create Description Label: %s\n",
+			err.Error())
+		return nil
+	} else if uEntry, err = gtk.EntryNew(); err != nil {
+		g.log.Printf("[ERROR] Cannot create Entry for URL: %s\n",
+			err.Error())
+		return nil
+	} else if tEntry, err = gtk.EntryNew(); err != nil {
+		g.log.Printf("[ERROR] Cannot create Entry for Title: %s\n",
+			err.Error())
+		return nil
+	} else if dEntry, err = gtk.EntryNew(); err != nil {
+		g.log.Printf("[ERROR] Cannot create Entry for URL Description: %s\n",
+			err.Error())
+		return nil
+	} else if dbox, err = dlg.GetContentArea(); err != nil {
+		g.log.Printf("[ERROR] Cannot get ContentArea of AddURL Dialog: %s\n",
+			err.Error())
+		return nil
+	}
+
+	grid.InsertColumn(0)
+	grid.InsertColumn(1)
+	grid.InsertRow(0)
+	grid.InsertRow(1)
+	grid.InsertRow(2)
+
+	grid.Attach(uLbl, 0, 0, 1, 1)
+	grid.Attach(tLbl, 0, 1, 1, 1)
+	grid.Attach(dLbl, 0, 2, 1, 1)
+	grid.Attach(uEntry, 1, 0, 1, 1)
+	grid.Attach(tEntry, 1, 1, 1, 1)
+	grid.Attach(dEntry, 1, 2, 1, 1)
+
+	dbox.PackStart(grid, true, true, 0)
+	dlg.ShowAll()
+
+	var res = dlg.Run()
+
+	switch res {
+	case gtk.RESPONSE_NONE:
+		fallthrough
+	case gtk.RESPONSE_DELETE_EVENT:
+		fallthrough
+	case gtk.RESPONSE_CLOSE:
+		fallthrough
+	case gtk.RESPONSE_CANCEL:
+		g.log.Println("[DEBUG] User changed their mind about adding a Link. Fine with me.")
+		return nil
+	case gtk.RESPONSE_OK:
+		// 's ist los, Hund?
+	default:
+		g.log.Printf("[CANTHAPPEN] Well, I did NOT see this coming: %d\n",
+			res)
+		return nil
+	}
+
+	if s, err = uEntry.GetText(); err != nil {
+		g.log.Printf("[ERROR] Cannot get input from URL field: %s\n",
+			err.Error())
+		return nil
+	} else if l.Title, err = tEntry.GetText(); err != nil {
+		g.log.Printf("[ERROR] Cannot get input from Title field: %s\n",
+			err.Error())
+		return nil
+	} else if l.Description, err = dEntry.GetText(); err != nil {
+		g.log.Printf("[ERROR] Cannot get input from Description field: %s\n",
+			err.Error())
+		return nil
+	} else if l.URL, err = url.Parse(s); err != nil {
+		var msg = fmt.Sprintf("This is not a valid URL: %q",
+			s)
+		g.log.Printf("[ERROR] %s\n", msg)
+		g.displayMsg(msg)
+		return nil
+	}
+
+	return &l
+} // func (g *GUI) linkDialog() *objects.Link
+
+// numberDialog asks the user for a number and a title, e.g. for a Season or
+// an Episode. numLbl is the label displayed next to the number, and num is
+// the number the dialog is prefilled with.
+// The last return value is false if the user cancelled the dialog.
+func (g *GUI) numberDialog(title, numLbl string, num int64) (int64, string, bool) {
+	krylib.Trace()
+	defer g.log.Printf("[TRACE] EXIT %s\n",
+		krylib.TraceInfo())
+	var (
+		err        error
+		s          string
+		dlg        *gtk.Dialog
+		dbox       *gtk.Box
+		grid       *gtk.Grid
+		nLbl, tLbl *gtk.Label
+		spin       *gtk.SpinButton
+		entry      *gtk.Entry
+	)
+
+	if dlg, err = gtk.DialogNewWithButtons(
+		title,
+		g.win,
+		gtk.DIALOG_MODAL,
+		[]interface{}{
+			"_Cancel",
+			gtk.RESPONSE_CANCEL,
+			"_OK",
+			gtk.RESPONSE_OK,
+		},
+	); err != nil {
+		g.log.Printf("[ERROR] Cannot create Dialog %q: %s\n",
+			title,
+			err.Error())
+		return 0, "", false
+	}
+
+	defer dlg.Close()
+
+	if _, err = dlg.AddButton("OK", gtk.RESPONSE_OK); err != nil {
+		g.log.Printf("[ERROR] Cannot add OK button to Dialog %q: %s\n",
+			title,
+			err.Error())
+		return 0, "", false
+	} else if grid, err = gtk.GridNew(); err != nil {
+		g.log.Printf("[ERROR] Cannot create gtk.Grid for Dialog %q: %s\n",
+			title,
+			err.Error())
+		return 0, "", false
+	} else if nLbl, err = gtk.LabelNew(numLbl); err != nil {
+		g.log.Printf("[ERROR] Cannot create Label %q: %s\n",
+			numLbl,
+			err.Error())
+		return 0, "", false
+	} else if tLbl, err = gtk.LabelNew("Title:"); err != nil {
+		g.log.Printf("[ERROR] Cannot create Title Label: %s\n",
+			err.Error())
+		return 0, "", false
+	} else if spin, err = gtk.SpinButtonNewWithRange(0, 9999, 1); err != nil {
+		g.log.Printf("[ERROR] Cannot create SpinButton for %s: %s\n",
+			numLbl,
+			err.Error())
+		return 0, "", false
+	} else if entry, err = gtk.EntryNew(); err != nil {
+		g.log.Printf("[ERROR] Cannot create Entry for Title: %s\n",
+			err.Error())
+		return 0, "", false
+	} else if dbox, err = dlg.GetContentArea(); err != nil {
+		g.log.Printf("[ERROR] Cannot get ContentArea of Dialog %q: %s\n",
+			title,
+			err.Error())
+		return 0, "", false
+	}
+
+	spin.SetValue(float64(num))
+
+	grid.InsertColumn(0)
+	grid.InsertColumn(1)
+	grid.InsertRow(0)
+	grid.InsertRow(1)
+
+	grid.Attach(tLbl, 0, 0, 1, 1)
+	grid.Attach(nLbl, 0, 1, 1, 1)
+	grid.Attach(entry, 1, 0, 1, 1)
+	grid.Attach(spin, 1, 1, 1, 1)
+
+	dbox.PackStart(grid, true, true, 0)
+	dlg.ShowAll()
+
+	var res = dlg.Run()
+
+	switch res {
+	case gtk.RESPONSE_NONE:
+		fallthrough
+	case gtk.RESPONSE_DELETE_EVENT:
+		fallthrough
+	case gtk.RESPONSE_CLOSE:
+		fallthrough
+	case gtk.RESPONSE_CANCEL:
+		g.log.Printf("[DEBUG] User cancelled Dialog %q\n", title)
+		return 0, "", false
+	case gtk.RESPONSE_OK:
+		// Carry on
+	default:
+		g.log.Printf("[CANTHAPPEN] Well, I did NOT see this coming: %d\n",
+			res)
+		return 0, "", false
+	}
+
+	if s, err = entry.GetText(); err != nil {
+		g.log.Printf("[ERROR] Cannot get input from Title field: %s\n",
+			err.Error())
+		return 0, "", false
+	}
+
+	return int64(spin.GetValueAsInt()), s, true
+} // func (g *GUI) numberDialog(title, numLbl string, num int64) (int64, string, bool)
+
+// textDialog asks the user for a single line of text. The Entry is prefilled
+// with text. The second return value is false if the user cancelled the
+// dialog.
+func (g *GUI) textDialog(title, lblText, text string) (string, bool) {
+	krylib.Trace()
+	defer g.log.Printf("[TRACE] EXIT %s\n",
+		krylib.TraceInfo())
+	var (
+		err        error
+		s          string
+		dlg        *gtk.Dialog
+		dbox, hbox *gtk.Box
+		lbl        *gtk.Label
+		entry      *gtk.Entry
+	)
+
+	// See handleTagAdd on why we add the OK button twice.
+	if dlg, err = gtk.DialogNewWithButtons(
+		title,
+		g.win,
+		gtk.DIALOG_MODAL,
+		[]interface{}{
+			"_Cancel",
+			gtk.RESPONSE_CANCEL,
+			"_OK",
+			gtk.RESPONSE_OK,
+		},
+	); err != nil {
+		g.log.Printf("[ERROR] Cannot create Dialog %q: %s\n",
+			title,
+			err.Error())
+		return "", false
+	}
+
+	defer dlg.Close()
+
+	if _, err = dlg.AddButton("OK", gtk.RESPONSE_OK); err != nil {
+		g.log.Printf("[ERROR] Cannot add OK button to Dialog %q: %s\n",
+			title,
+			err.Error())
+		return "", false
+	} else if hbox, err = gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 1); err != nil {
+		g.log.Printf("[ERROR] Cannot create gtk.Box for Dialog %q: %s\n",
+			title,
+			err.Error())
+		return "", false
+	} else if lbl, err = gtk.LabelNew(lblText); err != nil {
+		g.log.Printf("[ERROR] Cannot create Label %q: %s\n",
+			lblText,
+			err.Error())
+		return "", false
+	} else if entry, err = gtk.EntryNew(); err != nil {
+		g.log.Printf("[ERROR] Cannot create Entry for Dialog %q: %s\n",
+			title,
+			err.Error())
+		return "", false
+	} else if dbox, err = dlg.GetContentArea(); err != nil {
+		g.log.Printf("[ERROR] Cannot get ContentArea of Dialog %q: %s\n",
+			title,
+			err.Error())
+		return "", false
+	}
+
+	entry.SetText(text)
+	entry.SetActivatesDefault(true)
+	dlg.SetDefaultResponse(gtk.RESPONSE_OK)
+
+	dbox.PackStart(hbox, true, true, 0)
+	hbox.PackStart(lbl, false, false, 0)
+	hbox.PackStart(entry, true, true, 0)
+	dlg.ShowAll()
+
+	var res = dlg.Run()
+
+	switch res {
+	case gtk.RESPONSE_NONE:
+		fallthrough
+	case gtk.RESPONSE_DELETE_EVENT:
+		fallthrough
+	case gtk.RESPONSE_CLOSE:
+		fallthrough
+	case gtk.RESPONSE_CANCEL:
+		g.log.Printf("[DEBUG] User cancelled Dialog %q\n", title)
+		return "", false
+	case gtk.RESPONSE_OK:
+		// Carry on
+	default:
+		g.log.Printf("[CANTHAPPEN] Well, I did NOT see this coming: %d\n",
+			res)
+		return "", false
+	}
+
+	if s, err = entry.GetText(); err != nil {
+		g.log.Printf("[ERROR] Cannot get input from Dialog %q: %s\n",
+			title,
+			err.Error())
+		return "", false
+	}
+
+	return s, true
+} // func (g *GUI) textDialog(title, lblText, text string) (string, bool)
@@ -0,0 +1,276 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/ui/history.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 04. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-04 18:52:31 krylon>
+
+package ui
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/blicero/blockbuster/common"
+	"github.com/blicero/blockbuster/objects"
+	"github.com/blicero/krylib"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// If playback got through most of the movie, we consider it watched, the
+// remaining few percent are usually the credits.
+// If we stopped after a short while, we probably just wanted to check
+// something, so there is no point in remembering where we were.
+const (
+	watchedThreshold = 0.9
+	minResumePos     = time.Minute
+)
+
+// watchedMark returns what the Watched column of the Files view displays for
+// a File.
+func watchedMark(f *objects.File) string {
+	if f.Watched {
+		return "✓"
+	}
+
+	return ""
+} // func watchedMark(f *objects.File) string
+
+// mkPlaybackFinishedHandler returns a function that records where playback
+// of a File stopped. If the duration is not known, we look it up in the
+// File's MediaInfo.
+// Unless tracked is true, the player has not told us its position, so we
+// only record that the File was played, not where it stopped.
+func (g *GUI) mkPlaybackFinishedHandler(f *objects.File, l *objects.PlayLog, end time.Time, status int, tracked bool, pos, duration time.Duration) func() bool {
+	return func() bool {
+		krylib.Trace()
+		defer g.log.Printf("[TRACE] EXIT %s\n",
+			krylib.TraceInfo())
+
+		var (
+			err  error
+			info *objects.MediaInfo
+		)
+
+		if err = g.db.PlayLogFinish(l, end, status, pos); err != nil {
+			g.log.Printf("[ERROR] Cannot finish PlayLog for %s: %s\n",
+				f.DisplayTitle(),
+				err.Error())
+			return false
+		} else if status != 0 || !tracked {
+			// If the player crashed or never told us its position,
+			// we do not know where it stopped.
+			return false
+		} else if duration == 0 {
+			if info, err = g.db.MediaInfoGetByFile(f); err != nil {
+				g.log.Printf("[ERROR] Cannot get MediaInfo for %s: %s\n",
+					f.DisplayTitle(),
+					err.Error())
+				return false
+			} else if info != nil {
+				duration = info.Duration
+			}
+		}
+
+		if duration > 0 && float64(pos) >= float64(duration)*watchedThreshold {
+			g.setWatched(f, true)
+			return false
+		} else if pos < minResumePos {
+			return false
+		} else if err = g.db.FileSetResumePosition(f, pos); err != nil {
+			g.log.Printf("[ERROR] Cannot save resume position for %s: %s\n",
+				f.DisplayTitle(),
+				err.Error())
+			return false
+		}
+
+		f.ResumePosition = pos
+		g.refreshContinue()
+		return false
+	}
+} // func (g *GUI) mkPlaybackFinishedHandler(f *objects.File, l *objects.PlayLog, end time.Time, status int, tracked bool, pos, duration time.Duration) func() bool
+
+// setWatched marks a File as watched or unwatched and updates the Files view
+// and the Continue watching view accordingly.
+func (g *GUI) setWatched(f *objects.File, watched bool) {
+	krylib.Trace()
+	defer g.log.Printf("[TRACE] EXIT %s\n",
+		krylib.TraceInfo())
+
+	var (
+		err   error
+		msg   string
+		iter  *gtk.TreeIter
+		store = g.tabs[tiFile].store.(*gtk.ListStore)
+	)
+
+	if err = g.db.FileSetWatched(f, watched); err != nil {
+		msg = fmt.Sprintf("Cannot set Watched flag for %s: %s",
+			f.DisplayTitle(),
+			err.Error())
+		goto ERROR
+	}
+
+	f.Watched = watched
+	if watched {
+		f.ResumePosition = 0
+	}
+
+	if iter = g.findFileRow(f.ID); iter == nil {
+		g.log.Printf("[ERROR] Did not find File %s in the Files view\n",
+			f.DisplayTitle())
+	} else if err = store.Set(iter, []int{6}, []interface{}{watchedMark(f)}); err != nil {
+		msg = fmt.Sprintf("Cannot update Watched column for %s: %s",
+			f.DisplayTitle(),
+			err.Error())
+		goto ERROR
+	}
+
+	g.refreshContinue()
+	return
+
+ERROR:
+	g.log.Printf("[ERROR] %s\n", msg)
+	g.displayMsg(msg)
+} // func (g *GUI) setWatched(f *objects.File, watched bool)
+
+// findFileRow returns the TreeIter of the row in the Files view that
+// displays the File with the given ID, or nil if there is no such row.
+func (g *GUI) findFileRow(id int64) *gtk.TreeIter {
+	var (
+		store    = g.tabs[tiFile].store.(*gtk.ListStore)
+		iter, ok = store.GetIterFirst()
+	)
+
+	for ; ok; ok = store.IterNext(iter) {
+		var (
+			err error
+			val *glib.Value
+			gv  interface{}
+		)
+
+		if val, err = store.GetValue(iter, 0); err != nil {
+			g.log.Printf("[ERROR] Cannot get ID from Files view: %s\n",
+				err.Error())
+			return nil
+		} else if gv, err = val.GoValue(); err != nil {
+			g.log.Printf("[ERROR] Cannot get Go value from GLib value: %s\n",
+				err.Error())
+			return nil
+		} else if gv.(int) == int(id) {
+			return iter
+		}
+	}
+
+	return nil
+} // func (g *GUI) findFileRow(id int64) *gtk.TreeIter
+
+func (g *GUI) refreshContinue() {
+	g.clearData(tiContinue)
+	g.loadContinue()
+} // func (g *GUI) refreshContinue()
+
+// loadContinue fills the Continue watching view with the Files we have
+// started but not finished watching, the most recently played first.
+func (g *GUI) loadContinue() {
+	krylib.Trace()
+	defer g.log.Printf("[TRACE] EXIT %s\n",
+		krylib.TraceInfo())
+
+	var (
+		err   error
+		files []objects.File
+		store = g.tabs[tiContinue].store.(*gtk.ListStore)
+	)
+
+	if files, err = g.db.FileGetResumable(); err != nil {
+		g.log.Printf("[ERROR] Cannot get list of partially watched Files: %s\n",
+			err.Error())
+		return
+	}
+
+	for fidx := range files {
+		var (
+			info          *objects.MediaInfo
+			plays         []objects.PlayLog
+			durStr, lastp string
+			f             = &files[fidx]
+			iter          = store.Append()
+		)
+
+		if info, err = g.db.MediaInfoGetByFile(f); err != nil {
+			g.log.Printf("[ERROR] Cannot get MediaInfo for %s: %s\n",
+				f.DisplayTitle(),
+				err.Error())
+		} else if info != nil {
+			durStr = fmtDuration(info.Duration)
+		}
+
+		if plays, err = g.db.PlayLogGetByFile(f); err != nil {
+			g.log.Printf("[ERROR] Cannot get PlayLog for %s: %s\n",
+				f.DisplayTitle(),
+				err.Error())
+		} else if len(plays) > 0 {
+			lastp = plays[0].Start.Format(common.TimestampFormat)
+		}
+
+		if err = store.Set(
+			iter,
+			[]int{0, 1, 2, 3, 4},
+			[]interface{}{f.ID, f.DisplayTitle(), fmtDuration(f.ResumePosition), durStr, lastp},
+		); err != nil {
+			g.log.Printf("[ERROR] Cannot add File %s to Continue view: %s\n",
+				f.DisplayTitle(),
+				err.Error())
+		}
+	}
+} // func (g *GUI) loadContinue()
+
+func (g *GUI) handleContinueActivate(view *gtk.TreeView, path *gtk.TreePath, col *gtk.TreeViewColumn) {
+	krylib.Trace()
+	defer g.log.Printf("[TRACE] EXIT %s\n",
+		krylib.TraceInfo())
+
+	var (
+		err  error
+		msg  string
+		id   int64
+		f    *objects.File
+		iter *gtk.TreeIter
+		val  *glib.Value
+		gv   interface{}
+	)
+
+	if iter, err = g.tabs[tiContinue].filter.GetIter(path); err != nil {
+		msg = fmt.Sprintf("Cannot get TreeIter for activated row: %s",
+			err.Error())
+		goto ERROR
+	} else if val, err = g.tabs[tiContinue].filter.GetValue(iter, 0); err != nil {
+		msg = fmt.Sprintf("Cannot get ID of activated row: %s",
+			err.Error())
+		goto ERROR
+	} else if gv, err = val.GoValue(); err != nil {
+		msg = fmt.Sprintf("Cannot get Go value from GLib value: %s",
+			err.Error())
+		goto ERROR
+	}
+
+	id = int64(gv.(int))
+
+	if f, err = g.db.FileGetByID(id); err != nil {
+		msg = fmt.Sprintf("Cannot look up File #%d: %s",
+			id,
+			err.Error())
+		goto ERROR
+	} else if f == nil {
+		msg = fmt.Sprintf("File #%d was not found in database", id)
+		goto ERROR
+	}
+
+	g.playFile(f)
+	return
+
+ERROR:
+	g.log.Printf("[ERROR] %s\n", msg)
+	g.displayMsg(msg)
+} // func (g *GUI) handleContinueActivate(view *gtk.TreeView, path *gtk.TreePath, col *gtk.TreeViewColumn)
@@ -0,0 +1,279 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/ui/imdb.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 15. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-15 23:52:18 krylon>
+
+package ui
+
+import (
+	"fmt"
+
+	"github.com/blicero/blockbuster/common"
+	"github.com/blicero/blockbuster/database"
+	"github.com/blicero/blockbuster/imdb"
+	"github.com/blicero/krylib"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// handleLoadIMDb asks the user for the directory they downloaded the IMDb
+// datasets to and loads them into the staging area. The datasets are
+// large, so this happens in the background, and once it is done, we offer
+// to match them against our Files.
+func (g *GUI) handleLoadIMDb() {
+	krylib.Trace()
+	defer g.log.Printf("[TRACE] EXIT %s\n",
+		krylib.TraceInfo())
+
+	var (
+		err error
+		msg string
+		dir string
+	)
+
+	if g.imdbBusy {
+		g.displayMsg("The IMDb datasets are being loaded already.")
+		return
+	} else if g.scanner.Active() {
+		g.displayMsg("Please wait for the scan to finish before loading the IMDb datasets.")
+		return
+	} else if dir, err = g.chooseFile("Load IMDb datasets", gtk.FILE_CHOOSER_ACTION_SELECT_FOLDER, ""); err != nil {
+		msg = err.Error()
+		goto ERROR
+	} else if dir == "" {
+		g.log.Println("[DEBUG] User cancelled loading IMDb datasets")
+		return
+	}
+
+	g.imdbBusy = true
+	g.statusbar.Push(statusIMDb, fmt.Sprintf("Loading IMDb datasets from %s", dir))
+
+	go g.loadIMDb(dir)
+	return
+
+ERROR:
+	g.log.Printf("[ERROR] %s\n", msg)
+	g.displayMsg(msg)
+} // func (g *GUI) handleLoadIMDb()
+
+// loadIMDb runs in its own goroutine. The Database is not safe for
+// concurrent use, so we open a connection of our own.
+func (g *GUI) loadIMDb(dir string) {
+	var (
+		err   error
+		db    *database.Database
+		stats *imdb.Stats
+	)
+
+	if db, err = database.Open(common.DbPath); err != nil {
+		g.log.Printf("[ERROR] Cannot open Database at %s: %s\n",
+			common.DbPath,
+			err.Error())
+	} else {
+		stats, err = imdb.Load(db, dir, func(file string, rows int) {
+			var msg = fmt.Sprintf("Loading IMDb datasets: %d rows from %s",
+				rows,
+				file)
+
+			glib.IdleAdd(func() bool {
+				g.statusbar.Push(statusIMDb, msg)
+				return false
+			})
+		})
+		db.Close() // nolint: errcheck,gosec
+	}
+
+	glib.IdleAdd(func() bool {
+		var msg string
+
+		g.imdbBusy = false
+
+		if err != nil {
+			msg = fmt.Sprintf("Cannot load IMDb datasets from %s: %s",
+				dir,
+				err.Error())
+			g.log.Printf("[ERROR] %s\n", msg)
+			g.statusbar.Push(statusIMDb, msg)
+			g.displayMsg(msg)
+			return false
+		}
+
+		msg = fmt.Sprintf("Loaded %d titles, %d credits and %d names from IMDb",
+			stats.Titles,
+			stats.Principals,
+			stats.Names)
+		g.log.Printf("[INFO] %s\n", msg)
+		g.statusbar.Push(statusIMDb, msg)
+		g.handleMatchIMDb()
+		return false
+	})
+} // func (g *GUI) loadIMDb(dir string)
+
+// handleMatchIMDb matches our Files against the IMDb datasets loaded
+// earlier and lets the user choose which matches to apply.
+func (g *GUI) handleMatchIMDb() {
+	krylib.Trace()
+	defer g.log.Printf("[TRACE] EXIT %s\n",
+		krylib.TraceInfo())
+
+	var (
+		err     error
+		msg     string
+		matches []imdb.Match
+		ok      bool
+		res     *imdb.Result
+	)
+
+	if g.imdbBusy {
+		g.displayMsg("Please wait for the IMDb datasets to finish loading.")
+		return
+	} else if matches, err = imdb.FindMatches(g.db); err != nil {
+		msg = fmt.Sprintf("Cannot match Files with IMDb datasets: %s",
+			err.Error())
+		goto ERROR
+	} else if len(matches) == 0 {
+		g.displayMsg("None of the Files matched a title from the IMDb datasets.")
+		return
+	} else if matches, ok, err = g.reviewIMDbMatches(matches); err != nil {
+		msg = err.Error()
+		goto ERROR
+	} else if !ok || len(matches) == 0 {
+		g.log.Println("[DEBUG] User cancelled matching with IMDb")
+		return
+	} else if res, err = imdb.Apply(g.db, matches); err != nil {
+		msg = fmt.Sprintf("Cannot add cast and crew from IMDb: %s",
+			err.Error())
+		goto ERROR
+	}
+
+	g.reloadData()
+	g.displayMsg(fmt.Sprintf("Updated %d Files from IMDb, added %d People and %d credits.",
+		res.Files,
+		res.People,
+		res.Credits))
+	return
+
+ERROR:
+	g.log.Printf("[ERROR] %s\n", msg)
+	g.displayMsg(msg)
+} // func (g *GUI) handleMatchIMDb()
+
+// reviewIMDbMatches shows the matches to the user, who may uncheck the
+// ones they do not want. It returns the remaining matches, and false if
+// the user cancelled.
+func (g *GUI) reviewIMDbMatches(matches []imdb.Match) ([]imdb.Match, bool, error) {
+	var (
+		err      error
+		dlg      *gtk.Dialog
+		dbox     *gtk.Box
+		scr      *gtk.ScrolledWindow
+		grid     *gtk.Grid
+		checks   []*gtk.CheckButton
+		selected []imdb.Match
+	)
+
+	if dlg, err = gtk.DialogNewWithButtons(
+		"Match Files with IMDb",
+		g.win,
+		gtk.DIALOG_MODAL,
+		[]interface{}{
+			"Cancel",
+			gtk.RESPONSE_CANCEL,
+			"OK",
+			gtk.RESPONSE_OK,
+		},
+	); err != nil {
+		return nil, false, fmt.Errorf("Cannot create dialog: %s",
+			err.Error())
+	}
+
+	defer dlg.Close()
+
+	// See handleTagAdd on why we add the OK button again.
+	if _, err = dlg.AddButton("OK", gtk.RESPONSE_OK); err != nil {
+		return nil, false, fmt.Errorf("Cannot add OK button to dialog: %s",
+			err.Error())
+	} else if dbox, err = dlg.GetContentArea(); err != nil {
+		return nil, false, fmt.Errorf("Cannot get ContentArea of dialog: %s",
+			err.Error())
+	} else if scr, err = gtk.ScrolledWindowNew(nil, nil); err != nil {
+		return nil, false, fmt.Errorf("Cannot create ScrolledWindow: %s",
+			err.Error())
+	} else if grid, err = gtk.GridNew(); err != nil {
+		return nil, false, fmt.Errorf("Cannot create Grid: %s",
+			err.Error())
+	}
+
+	grid.SetColumnSpacing(10)
+	grid.SetRowSpacing(5)
+
+	for i, title := range []string{"", "File", "IMDb", "Found by"} {
+		var lbl *gtk.Label
+
+		if lbl, err = gtk.LabelNew(""); err != nil {
+			return nil, false, fmt.Errorf("Cannot create Label: %s",
+				err.Error())
+		}
+
+		lbl.SetMarkup(fmt.Sprintf("<b>%s</b>", title))
+		grid.Attach(lbl, i, 0, 1, 1)
+	}
+
+	checks = make([]*gtk.CheckButton, len(matches))
+
+	for idx := range matches {
+		var (
+			m                = &matches[idx]
+			fLbl, tLbl, bLbl *gtk.Label
+			by               = "title"
+		)
+
+		if m.ByID {
+			by = "link"
+		}
+
+		if checks[idx], err = gtk.CheckButtonNew(); err != nil {
+			return nil, false, fmt.Errorf("Cannot create CheckButton: %s",
+				err.Error())
+		} else if fLbl, err = gtk.LabelNew(m.File.DisplayTitle()); err != nil {
+			return nil, false, fmt.Errorf("Cannot create Label: %s",
+				err.Error())
+		} else if tLbl, err = gtk.LabelNew(fmt.Sprintf("%s (%d)", m.Title.Title, m.Title.Year)); err != nil {
+			return nil, false, fmt.Errorf("Cannot create Label: %s",
+				err.Error())
+		} else if bLbl, err = gtk.LabelNew(by); err != nil {
+			return nil, false, fmt.Errorf("Cannot create Label: %s",
+				err.Error())
+		}
+
+		checks[idx].SetActive(true)
+		fLbl.SetXAlign(0)
+		fLbl.SetTooltipText(m.File.Path)
+		tLbl.SetXAlign(0)
+
+		grid.Attach(checks[idx], 0, idx+1, 1, 1)
+		grid.Attach(fLbl, 1, idx+1, 1, 1)
+		grid.Attach(tLbl, 2, idx+1, 1, 1)
+		grid.Attach(bLbl, 3, idx+1, 1, 1)
+	}
+
+	scr.SetPolicy(gtk.POLICY_AUTOMATIC, gtk.POLICY_AUTOMATIC)
+	scr.SetMinContentHeight(300)
+	scr.SetMinContentWidth(800)
+	scr.Add(grid)
+	dbox.PackStart(scr, true, true, 0)
+	dlg.ShowAll()
+
+	if res := dlg.Run(); res != gtk.RESPONSE_OK {
+		return nil, false, nil
+	}
+
+	for idx, chk := range checks {
+		if chk.GetActive() {
+			selected = append(selected, matches[idx])
+		}
+	}
+
+	return selected, true, nil
+} // func (g *GUI) reviewIMDbMatches(matches []imdb.Match) ([]imdb.Match, bool, error)
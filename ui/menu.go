@@ -29,6 +29,11 @@ func (g *GUI) initMenu() error {
 		fileMenu, addMenu                      *gtk.Menu
 		scanItem, reloadItem, quitItem, fmItem *gtk.MenuItem
 		itemAddTag, itemAddPerson, amItem      *gtk.MenuItem
+		itemAddSeries, missingItem             *gtk.MenuItem
+		itemAddCollection, restoreItem         *gtk.MenuItem
+		exportItem, importItem                 *gtk.MenuItem
+		historyImportItem, historyExportItem   *gtk.MenuItem
+		imdbLoadItem, imdbMatchItem            *gtk.MenuItem
 	)
 
 	if fileMenu, err = gtk.MenuNew(); err != nil {
@@ -43,6 +48,38 @@ func (g *GUI) initMenu() error {
 		g.log.Printf("[ERROR] Cannot create menu item File/Reload: %s\n",
 			err.Error())
 		return err
+	} else if missingItem, err = gtk.MenuItemNewWithMnemonic("_Missing Files…"); err != nil {
+		g.log.Printf("[ERROR] Cannot create menu item File/Missing Files: %s\n",
+			err.Error())
+		return err
+	} else if restoreItem, err = gtk.MenuItemNewWithMnemonic("Res_tore from snapshot…"); err != nil {
+		g.log.Printf("[ERROR] Cannot create menu item File/Restore from snapshot: %s\n",
+			err.Error())
+		return err
+	} else if exportItem, err = gtk.MenuItemNewWithMnemonic("_Export library…"); err != nil {
+		g.log.Printf("[ERROR] Cannot create menu item File/Export library: %s\n",
+			err.Error())
+		return err
+	} else if importItem, err = gtk.MenuItemNewWithMnemonic("_Import library…"); err != nil {
+		g.log.Printf("[ERROR] Cannot create menu item File/Import library: %s\n",
+			err.Error())
+		return err
+	} else if historyImportItem, err = gtk.MenuItemNewWithMnemonic("Import watch _history…"); err != nil {
+		g.log.Printf("[ERROR] Cannot create menu item File/Import watch history: %s\n",
+			err.Error())
+		return err
+	} else if historyExportItem, err = gtk.MenuItemNewWithMnemonic("Export watch history for _Letterboxd…"); err != nil {
+		g.log.Printf("[ERROR] Cannot create menu item File/Export watch history: %s\n",
+			err.Error())
+		return err
+	} else if imdbLoadItem, err = gtk.MenuItemNewWithMnemonic("Load IMDb _datasets…"); err != nil {
+		g.log.Printf("[ERROR] Cannot create menu item File/Load IMDb datasets: %s\n",
+			err.Error())
+		return err
+	} else if imdbMatchItem, err = gtk.MenuItemNewWithMnemonic("_Match Files with IMDb datasets…"); err != nil {
+		g.log.Printf("[ERROR] Cannot create menu item File/Match Files with IMDb datasets: %s\n",
+			err.Error())
+		return err
 	} else if quitItem, err = gtk.MenuItemNewWithMnemonic("_Quit"); err != nil {
 		g.log.Printf("[ERROR] Cannot create menu item File/Quit: %s\n",
 			err.Error())
@@ -65,6 +102,14 @@ func (g *GUI) initMenu() error {
 		g.log.Printf("[ERROR] Cannot create menu item Add/Person: %s\n",
 			err.Error())
 		return err
+	} else if itemAddSeries, err = gtk.MenuItemNewWithMnemonic("_Series"); err != nil {
+		g.log.Printf("[ERROR] Cannot create menu item Add/Series: %s\n",
+			err.Error())
+		return err
+	} else if itemAddCollection, err = gtk.MenuItemNewWithMnemonic("_Collection"); err != nil {
+		g.log.Printf("[ERROR] Cannot create menu item Add/Collection: %s\n",
+			err.Error())
+		return err
 	} else if amItem, err = gtk.MenuItemNewWithMnemonic("_Add"); err != nil {
 		g.log.Printf("[ERROR] Cannot create menu Item Add/: %s\n",
 			err.Error())
@@ -73,12 +118,28 @@ func (g *GUI) initMenu() error {
 
 	scanItem.Connect("activate", g.promptScanFolder)
 	reloadItem.Connect("activate", g.reloadData)
+	missingItem.Connect("activate", g.handleReconcileMissing)
+	restoreItem.Connect("activate", g.handleRestoreSnapshot)
+	exportItem.Connect("activate", g.handleExportLibrary)
+	importItem.Connect("activate", g.handleImportLibrary)
+	historyImportItem.Connect("activate", g.handleImportHistory)
+	historyExportItem.Connect("activate", g.handleExportHistory)
+	imdbLoadItem.Connect("activate", g.handleLoadIMDb)
+	imdbMatchItem.Connect("activate", g.handleMatchIMDb)
 	quitItem.Connect("activate", gtk.MainQuit)
 
 	fmItem.SetSubmenu(fileMenu)
 
 	fileMenu.Append(scanItem)
 	fileMenu.Append(reloadItem)
+	fileMenu.Append(missingItem)
+	fileMenu.Append(restoreItem)
+	fileMenu.Append(exportItem)
+	fileMenu.Append(importItem)
+	fileMenu.Append(historyImportItem)
+	fileMenu.Append(historyExportItem)
+	fileMenu.Append(imdbLoadItem)
+	fileMenu.Append(imdbMatchItem)
 	fileMenu.Append(quitItem)
 
 	g.menubar.Append(fmItem)
@@ -86,9 +147,13 @@ func (g *GUI) initMenu() error {
 	amItem.SetSubmenu(addMenu)
 	addMenu.Append(itemAddTag)
 	addMenu.Append(itemAddPerson)
+	addMenu.Append(itemAddSeries)
+	addMenu.Append(itemAddCollection)
 
 	itemAddTag.Connect("activate", g.handleTagAdd)
 	itemAddPerson.Connect("activate", g.handlePersonAdd)
+	itemAddSeries.Connect("activate", g.handleSeriesAdd)
+	itemAddCollection.Connect("activate", g.handleCollectionAdd)
 
 	g.menubar.Append(amItem)
 
@@ -0,0 +1,234 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/ui/metadata.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 16. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-16 23:38:12 krylon>
+
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/blicero/blockbuster/metadata"
+	"github.com/blicero/blockbuster/objects"
+	"github.com/blicero/krylib"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// To use The Movie Database, the user needs an API key. The base URL only
+// needs to be set to use something other than TMDB itself.
+const (
+	tmdbKeyEnv = "TMDB_API_KEY"
+	tmdbURLEnv = "TMDB_BASE_URL"
+)
+
+// registerProviders registers the metadata Providers the user has
+// configured.
+func registerProviders() error {
+	var (
+		err  error
+		tmdb *metadata.TMDB
+		key  = os.Getenv(tmdbKeyEnv)
+	)
+
+	if key == "" {
+		return nil
+	} else if tmdb, err = metadata.NewTMDB(os.Getenv(tmdbURLEnv), key); err != nil {
+		return err
+	}
+
+	return metadata.Register(tmdb)
+} // func registerProviders() error
+
+// mkFetchMetadataHandler returns a handler that looks up a File in the
+// online databases we know about. Talking to the network can take a while,
+// so the searching and fetching happen in the background.
+func (g *GUI) mkFetchMetadataHandler(f *objects.File) func() {
+	return func() {
+		krylib.Trace()
+		defer g.log.Printf("[TRACE] EXIT %s\n",
+			krylib.TraceInfo())
+
+		var (
+			query string
+			ok    bool
+		)
+
+		if len(metadata.Providers()) == 0 {
+			g.displayMsg(fmt.Sprintf("No metadata provider is configured. Set %s to use The Movie Database.",
+				tmdbKeyEnv))
+			return
+		} else if query, ok = g.textDialog("Fetch metadata", "Title:", f.DisplayTitle()); !ok {
+			return
+		} else if query = strings.TrimSpace(query); query == "" {
+			return
+		}
+
+		g.statusbar.Push(statusInternet, fmt.Sprintf("Searching for %s", query))
+
+		go func() {
+			var results, err = metadata.Search(query, f.Year)
+
+			glib.IdleAdd(func() bool {
+				g.pickMetadata(f, query, results, err)
+				return false
+			})
+		}()
+	}
+} // func (g *GUI) mkFetchMetadataHandler(f *objects.File) func()
+
+// pickMetadata lets the user choose from the results of a search, and then
+// fetches the details of the chosen title.
+func (g *GUI) pickMetadata(f *objects.File, query string, results []metadata.Title, err error) {
+	krylib.Trace()
+	defer g.log.Printf("[TRACE] EXIT %s\n",
+		krylib.TraceInfo())
+
+	var (
+		msg   string
+		idx   int
+		dlg   *gtk.Dialog
+		dbox  *gtk.Box
+		combo *gtk.ComboBoxText
+		title *metadata.Title
+	)
+
+	if err != nil {
+		msg = fmt.Sprintf("Cannot search for %s: %s",
+			query,
+			err.Error())
+		goto ERROR
+	} else if len(results) == 0 {
+		g.statusbar.Push(statusInternet, "")
+		g.displayMsg(fmt.Sprintf("Nothing was found for %s.", query))
+		return
+	} else if dlg, err = gtk.DialogNewWithButtons(
+		fmt.Sprintf("Metadata for %s", f.DisplayTitle()),
+		g.win,
+		gtk.DIALOG_MODAL,
+		[]interface{}{
+			"_Cancel",
+			gtk.RESPONSE_CANCEL,
+			"_OK",
+			gtk.RESPONSE_OK,
+		},
+	); err != nil {
+		msg = fmt.Sprintf("Cannot create dialog: %s",
+			err.Error())
+		goto ERROR
+	}
+
+	defer dlg.Close()
+
+	// See handleTagAdd on why we add the OK button again.
+	if _, err = dlg.AddButton("OK", gtk.RESPONSE_OK); err != nil {
+		msg = fmt.Sprintf("Cannot add OK button to dialog: %s",
+			err.Error())
+		goto ERROR
+	} else if dbox, err = dlg.GetContentArea(); err != nil {
+		msg = fmt.Sprintf("Cannot get ContentArea of dialog: %s",
+			err.Error())
+		goto ERROR
+	} else if combo, err = gtk.ComboBoxTextNew(); err != nil {
+		msg = fmt.Sprintf("Cannot create ComboBox: %s",
+			err.Error())
+		goto ERROR
+	}
+
+	for i := range results {
+		combo.Append(strconv.Itoa(i),
+			fmt.Sprintf("%s (%s)",
+				results[i].String(),
+				results[i].Provider))
+	}
+
+	combo.SetActive(0)
+	dbox.PackStart(combo, true, true, 0)
+	dlg.ShowAll()
+
+	if res := dlg.Run(); res != gtk.RESPONSE_OK {
+		g.log.Println("[DEBUG] User cancelled fetching metadata")
+		g.statusbar.Push(statusInternet, "")
+		return
+	} else if idx, err = strconv.Atoi(combo.GetActiveID()); err != nil {
+		msg = fmt.Sprintf("Cannot get selected title: %s",
+			err.Error())
+		goto ERROR
+	}
+
+	title = &results[idx]
+	g.statusbar.Push(statusInternet, fmt.Sprintf("Fetching %s from %s",
+		title,
+		title.Provider))
+
+	go func() {
+		var full, err = metadata.Fetch(title)
+
+		glib.IdleAdd(func() bool {
+			g.applyMetadata(f, title, full, err)
+			return false
+		})
+	}()
+	return
+
+ERROR:
+	g.log.Printf("[ERROR] %s\n", msg)
+	g.statusbar.Push(statusInternet, msg)
+	g.displayMsg(msg)
+} // func (g *GUI) pickMetadata(f *objects.File, query string, results []metadata.Title, err error)
+
+// applyMetadata adds what we fetched to the File. If that would replace
+// the title or year the File already has, the user is asked first.
+func (g *GUI) applyMetadata(f *objects.File, title, full *metadata.Title, err error) {
+	krylib.Trace()
+	defer g.log.Printf("[TRACE] EXIT %s\n",
+		krylib.TraceInfo())
+
+	var (
+		msg       string
+		overwrite bool
+		conflicts []string
+		res       *metadata.Result
+	)
+
+	if err != nil {
+		msg = fmt.Sprintf("Cannot fetch %s from %s: %s",
+			title,
+			title.Provider,
+			err.Error())
+		goto ERROR
+	}
+
+	if conflicts = metadata.Conflicts(f, full); len(conflicts) > 0 {
+		overwrite = g.confirm(fmt.Sprintf("%s already has a different\n\n%s\n\nReplace?",
+			f.DisplayTitle(),
+			strings.Join(conflicts, "\n")))
+	}
+
+	if res, err = metadata.Apply(g.db, f, full, overwrite); err != nil {
+		msg = fmt.Sprintf("Cannot add metadata to %s: %s",
+			f.DisplayTitle(),
+			err.Error())
+		goto ERROR
+	}
+
+	msg = fmt.Sprintf("Added %d actors, %d directors and %d links from %s to %s",
+		res.Actors,
+		res.Directors,
+		res.Links,
+		full.Provider,
+		f.DisplayTitle())
+	g.log.Printf("[INFO] %s (%d new People)\n", msg, res.People)
+	g.statusbar.Push(statusInternet, msg)
+	g.reloadData()
+	return
+
+ERROR:
+	g.log.Printf("[ERROR] %s\n", msg)
+	g.statusbar.Push(statusInternet, msg)
+	g.displayMsg(msg)
+} // func (g *GUI) applyMetadata(f *objects.File, title, full *metadata.Title, err error)
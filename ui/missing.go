@@ -0,0 +1,263 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/ui/missing.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 01. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-01 22:38:04 krylon>
+
+package ui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/blicero/blockbuster/common"
+	"github.com/blicero/blockbuster/objects"
+	"github.com/blicero/krylib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// colorMissing is the foreground color for Files that have gone missing.
+const colorMissing = "gray"
+
+// The Scanner marks Files it does not find anymore as missing. The user has
+// to decide what to do with them. They might be gone for good, in which case
+// we purge them, or they might have been moved somewhere the Scanner did not
+// look, in which case we relink them, or they might come back, in which case
+// we keep them.
+type missingAction int
+
+const (
+	missingKeep missingAction = iota
+	missingPurge
+	missingRelink
+)
+
+var missingActionLabels = []string{
+	missingKeep:   "Keep",
+	missingPurge:  "Purge",
+	missingRelink: "Relink…",
+}
+
+func (g *GUI) handleReconcileMissing() {
+	krylib.Trace()
+	defer g.log.Printf("[TRACE] EXIT %s\n",
+		krylib.TraceInfo())
+
+	var (
+		err    error
+		msg    string
+		files  []objects.File
+		combos []*gtk.ComboBoxText
+		dlg    *gtk.Dialog
+		dbox   *gtk.Box
+		scr    *gtk.ScrolledWindow
+		grid   *gtk.Grid
+		errs   []string
+	)
+
+	if files, err = g.db.FileGetMissing(); err != nil {
+		msg = fmt.Sprintf("Cannot get list of missing Files: %s",
+			err.Error())
+		goto ERROR
+	} else if len(files) == 0 {
+		g.displayMsg("No Files are missing.")
+		return
+	} else if dlg, err = gtk.DialogNewWithButtons(
+		"Missing Files",
+		g.win,
+		gtk.DIALOG_MODAL,
+		[]interface{}{
+			"Cancel",
+			gtk.RESPONSE_CANCEL,
+			"OK",
+			gtk.RESPONSE_OK,
+		},
+	); err != nil {
+		msg = fmt.Sprintf("Cannot create dialog: %s",
+			err.Error())
+		goto ERROR
+	}
+
+	defer dlg.Close()
+
+	// See handleTagAdd on why we add the OK button again.
+	if _, err = dlg.AddButton("OK", gtk.RESPONSE_OK); err != nil {
+		msg = fmt.Sprintf("Cannot add OK button to dialog: %s",
+			err.Error())
+		goto ERROR
+	} else if dbox, err = dlg.GetContentArea(); err != nil {
+		msg = fmt.Sprintf("Cannot get ContentArea of dialog: %s",
+			err.Error())
+		goto ERROR
+	} else if scr, err = gtk.ScrolledWindowNew(nil, nil); err != nil {
+		msg = fmt.Sprintf("Cannot create ScrolledWindow: %s",
+			err.Error())
+		goto ERROR
+	} else if grid, err = gtk.GridNew(); err != nil {
+		msg = fmt.Sprintf("Cannot create Grid: %s",
+			err.Error())
+		goto ERROR
+	}
+
+	grid.SetColumnSpacing(10)
+	grid.SetRowSpacing(5)
+
+	for i, title := range []string{"Title", "Path", "Missing since", "Action"} {
+		var lbl *gtk.Label
+
+		if lbl, err = gtk.LabelNew(""); err != nil {
+			msg = fmt.Sprintf("Cannot create Label: %s",
+				err.Error())
+			goto ERROR
+		}
+
+		lbl.SetMarkup(fmt.Sprintf("<b>%s</b>", title))
+		grid.Attach(lbl, i, 0, 1, 1)
+	}
+
+	combos = make([]*gtk.ComboBoxText, len(files))
+
+	for idx := range files {
+		var (
+			f                = &files[idx]
+			tLbl, pLbl, mLbl *gtk.Label
+		)
+
+		if tLbl, err = gtk.LabelNew(f.DisplayTitle()); err != nil {
+			msg = fmt.Sprintf("Cannot create Label: %s",
+				err.Error())
+			goto ERROR
+		} else if pLbl, err = gtk.LabelNew(f.Path); err != nil {
+			msg = fmt.Sprintf("Cannot create Label: %s",
+				err.Error())
+			goto ERROR
+		} else if mLbl, err = gtk.LabelNew(f.MissingSince.Format(common.TimestampFormat)); err != nil {
+			msg = fmt.Sprintf("Cannot create Label: %s",
+				err.Error())
+			goto ERROR
+		} else if combos[idx], err = gtk.ComboBoxTextNew(); err != nil {
+			msg = fmt.Sprintf("Cannot create ComboBox: %s",
+				err.Error())
+			goto ERROR
+		}
+
+		for _, l := range missingActionLabels {
+			combos[idx].AppendText(l)
+		}
+
+		combos[idx].SetActive(int(missingKeep))
+
+		tLbl.SetXAlign(0)
+		pLbl.SetXAlign(0)
+
+		grid.Attach(tLbl, 0, idx+1, 1, 1)
+		grid.Attach(pLbl, 1, idx+1, 1, 1)
+		grid.Attach(mLbl, 2, idx+1, 1, 1)
+		grid.Attach(combos[idx], 3, idx+1, 1, 1)
+	}
+
+	scr.SetPolicy(gtk.POLICY_AUTOMATIC, gtk.POLICY_AUTOMATIC)
+	scr.SetMinContentHeight(300)
+	scr.SetMinContentWidth(800)
+	scr.Add(grid)
+	dbox.PackStart(scr, true, true, 0)
+	dlg.ShowAll()
+
+	if res := dlg.Run(); res != gtk.RESPONSE_OK {
+		g.log.Println("[DEBUG] User cancelled reconciling missing Files.")
+		return
+	}
+
+	for idx := range files {
+		var f = &files[idx]
+
+		switch missingAction(combos[idx].GetActive()) {
+		case missingKeep:
+			continue
+		case missingPurge:
+			if err = g.db.FilePurge(f); err != nil {
+				errs = append(errs, err.Error())
+			}
+		case missingRelink:
+			if err = g.relinkFile(f); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+
+	g.reloadData()
+
+	if len(errs) > 0 {
+		msg = fmt.Sprintf("Errors reconciling missing Files:\n%s",
+			strings.Join(errs, "\n"))
+		goto ERROR
+	}
+
+	return
+
+ERROR:
+	g.log.Printf("[ERROR] %s\n", msg)
+	g.displayMsg(msg)
+} // func (g *GUI) handleReconcileMissing()
+
+// relinkFile asks the user where a missing File has gone and updates its
+// path. If the new path is not inside any Folder we know, the File stays in
+// the Folder it was in before.
+func (g *GUI) relinkFile(f *objects.File) error {
+	krylib.Trace()
+	defer g.log.Printf("[TRACE] EXIT %s\n",
+		krylib.TraceInfo())
+
+	var (
+		err     error
+		dlg     *gtk.FileChooserDialog
+		path    string
+		folders []objects.Folder
+		folder  = &objects.Folder{ID: f.FolderID}
+	)
+
+	if dlg, err = gtk.FileChooserDialogNewWith2Buttons(
+		fmt.Sprintf("Where did %s go?", f.DisplayTitle()),
+		g.win,
+		gtk.FILE_CHOOSER_ACTION_OPEN,
+		"Cancel",
+		gtk.RESPONSE_CANCEL,
+		"OK",
+		gtk.RESPONSE_OK,
+	); err != nil {
+		return fmt.Errorf("Cannot create FileChooserDialog: %s",
+			err.Error())
+	}
+
+	defer dlg.Close()
+
+	dlg.SetCurrentFolder(filepath.Dir(f.Path))
+
+	if res := dlg.Run(); res != gtk.RESPONSE_OK {
+		g.log.Printf("[DEBUG] User did not pick a new path for %s\n",
+			f.Path)
+		return nil
+	} else if path = dlg.GetFilename(); path == "" {
+		return nil
+	} else if folders, err = g.db.FolderGetAll(); err != nil {
+		return fmt.Errorf("Cannot get list of Folders: %s",
+			err.Error())
+	}
+
+	for idx := range folders {
+		if strings.HasPrefix(path, folders[idx].Path+string(filepath.Separator)) {
+			folder = &folders[idx]
+			break
+		}
+	}
+
+	if err = g.db.FileMove(f, path, folder); err != nil {
+		return fmt.Errorf("Cannot relink %s to %s: %s",
+			f.Path,
+			path,
+			err.Error())
+	}
+
+	return nil
+} // func (g *GUI) relinkFile(f *objects.File) error
@@ -2,15 +2,15 @@
 // -*- mode: go; coding: utf-8; -*-
 // Created on 14. 08. 2021 by Benjamin Walkenhorst
 // (c) 2021 Benjamin Walkenhorst
-// Time-stamp: <2021-08-24 22:32:42 krylon>
+// Time-stamp: <2021-09-07 21:02:17 krylon>
 
 package ui
 
 import (
 	"fmt"
-	"net/url"
 	"os/exec"
 	"strconv"
+	"strings"
 
 	"github.com/blicero/blockbuster/objects"
 	"github.com/blicero/krylib"
@@ -218,8 +218,9 @@ func (g *GUI) mkPersonContextMenu(path *gtk.TreePath, p *objects.Person) (*gtk.M
 	krylib.Trace()
 	var (
 		err                              error
-		menu, urlMenu                    *gtk.Menu
+		menu, urlMenu, mergeMenu         *gtk.Menu
 		itemDel, itemURLAdd, itemURLList *gtk.MenuItem
+		itemMerge                        *gtk.MenuItem
 	)
 
 	// It would be nice if I could skip displaying the URL list submenu
@@ -233,15 +234,21 @@ func (g *GUI) mkPersonContextMenu(path *gtk.TreePath, p *objects.Person) (*gtk.M
 		return nil, err
 	} else if itemURLList, err = gtk.MenuItemNewWithMnemonic("_URLs"); err != nil {
 		return nil, err
+	} else if itemMerge, err = gtk.MenuItemNewWithMnemonic("_Merge into"); err != nil {
+		return nil, err
 	} else if urlMenu, err = g.getPersonLinks(p); err != nil {
 		return nil, err
+	} else if mergeMenu, err = g.mkPersonMergeMenu(p); err != nil {
+		return nil, err
 	}
 
 	itemURLList.SetSubmenu(urlMenu)
+	itemMerge.SetSubmenu(mergeMenu)
 	itemURLAdd.Connect("activate", g.mkPersonAddURLHandler(p))
 
 	menu.Append(itemURLAdd)
 	menu.Append(itemURLList)
+	menu.Append(itemMerge)
 	menu.Append(itemDel)
 
 	itemURLList.SetSubmenu(urlMenu)
@@ -362,139 +369,102 @@ func (g *GUI) mkPersonAddURLHandler(p *objects.Person) func() {
 		defer g.log.Printf("[TRACE] EXIT %s\n",
 			krylib.TraceInfo())
 		var (
-			err                    error
-			s                      string
-			l                      objects.Link
-			dlg                    *gtk.Dialog
-			dbox                   *gtk.Box
-			grid                   *gtk.Grid
-			uLbl, tLbl, dLbl       *gtk.Label
-			uEntry, tEntry, dEntry *gtk.Entry
+			err error
+			l   *objects.Link
 		)
 
-		if dlg, err = gtk.DialogNewWithButtons(
-			"Add URL",
-			g.win,
-			gtk.DIALOG_MODAL,
-			[]interface{}{
-				"_Cancel",
-				gtk.RESPONSE_CANCEL,
-				"_OK",
-				gtk.RESPONSE_OK,
-			},
-		); err != nil {
-			g.log.Printf("[ERROR] Cannot create Dialog for adding URL: %s\n",
+		if l = g.linkDialog(); l == nil {
+			return
+		} else if err = g.db.PersonURLAdd(p, l); err != nil {
+			var msg = fmt.Sprintf("Cannot attach Link %q to %s: %s",
+				l.URL.String(),
+				p.Name,
 				err.Error())
+			g.log.Printf("[ERROR] %s\n", msg)
+			g.displayMsg(msg)
 			return
 		}
 
-		defer dlg.Close()
+		g.log.Printf("[DEBUG] Guess what? We *successfully* added the URL %q (%s) to %s\n",
+			l.URL.String(),
+			l.Title,
+			p.Name)
+	}
+} // func (g *GUI) mkPersonAddURLHandler(p *objects.Person) func()
 
-		if _, err = dlg.AddButton("OK", gtk.RESPONSE_OK); err != nil {
-			g.log.Printf("[ERROR] Cannot add cancel button to AddPerson Dialog: %s\n",
-				err.Error())
-			return
-		} else if grid, err = gtk.GridNew(); err != nil {
-			g.log.Printf("[ERROR] Cannot create gtk.Grid for AddPerson Dialog: %s\n",
-				err.Error())
-			return
-		} else if uLbl, err = gtk.LabelNew("URL:"); err != nil {
-			g.log.Printf("[ERROR] Cannot create URL Label: %s\n",
-				err.Error())
-			return
-		} else if tLbl, err = gtk.LabelNew("Title:"); err != nil {
-			g.log.Printf("[ERROR] Cannot create Title Label: %s\n",
-				err.Error())
-			return
-		} else if dLbl, err = gtk.LabelNew("Description:"); err != nil {
-			g.log.Printf("[ERROR] Cannot create Description Label: %s\n",
-				err.Error())
-			return
-		} else if uEntry, err = gtk.EntryNew(); err != nil {
-			g.log.Printf("[ERROR] Cannot create Entry for URL: %s\n",
-				err.Error())
-			return
-		} else if tEntry, err = gtk.EntryNew(); err != nil {
-			g.log.Printf("[ERROR] Cannot create Entry for Title: %s\n",
-				err.Error())
-			return
-		} else if dEntry, err = gtk.EntryNew(); err != nil {
-			g.log.Printf("[ERROR] Cannot create Entry for URL Description: %s\n",
-				err.Error())
-			return
-		} else if dbox, err = dlg.GetContentArea(); err != nil {
-			g.log.Printf("[ERROR] Cannot get ContentArea of AddPerson Dialog: %s\n",
-				err.Error())
-			return
-		}
+// personLabel returns the name of a Person, followed by their aliases, if
+// they have any, so a Person can be found in a menu by any of their names.
+func personLabel(p *objects.Person, aliases map[int64][]string) string {
+	var names = aliases[p.ID]
 
-		grid.InsertColumn(0)
-		grid.InsertColumn(1)
-		grid.InsertRow(0)
-		grid.InsertRow(1)
-		grid.InsertRow(2)
-
-		grid.Attach(uLbl, 0, 0, 1, 1)
-		grid.Attach(tLbl, 0, 1, 1, 1)
-		grid.Attach(dLbl, 0, 2, 1, 1)
-		grid.Attach(uEntry, 1, 0, 1, 1)
-		grid.Attach(tEntry, 1, 1, 1, 1)
-		grid.Attach(dEntry, 1, 2, 1, 1)
-
-		dbox.PackStart(grid, true, true, 0)
-		dlg.ShowAll()
-
-		var res = dlg.Run()
-
-		switch res {
-		case gtk.RESPONSE_NONE:
-			fallthrough
-		case gtk.RESPONSE_DELETE_EVENT:
-			fallthrough
-		case gtk.RESPONSE_CLOSE:
-			fallthrough
-		case gtk.RESPONSE_CANCEL:
-			g.log.Println("[DEBUG] User changed their mind about adding a Link. Fine with me.")
-			return
-		case gtk.RESPONSE_OK:
-			// 's ist los, Hund?
-		default:
-			g.log.Printf("[CANTHAPPEN] Well, I did NOT see this coming: %d\n",
-				res)
-			return
+	if len(names) == 0 {
+		return p.Name
+	}
+
+	return fmt.Sprintf("%s (%s)",
+		p.Name,
+		strings.Join(names, ", "))
+} // func personLabel(p *objects.Person, aliases map[int64][]string) string
+
+// mkPersonMergeMenu creates a submenu listing all the other people. Picking
+// one of them merges drop into that Person, drop's name is kept as an alias.
+func (g *GUI) mkPersonMergeMenu(drop *objects.Person) (*gtk.Menu, error) {
+	var (
+		err     error
+		menu    *gtk.Menu
+		people  []objects.Person
+		aliases map[int64][]string
+	)
+
+	if people, err = g.db.PersonGetAll(); err != nil {
+		return nil, err
+	} else if aliases, err = g.db.PersonAliasGetAll(); err != nil {
+		return nil, err
+	} else if menu, err = gtk.MenuNew(); err != nil {
+		return nil, err
+	}
+
+	for i, p := range people {
+		var item *gtk.MenuItem
+
+		if p.ID == drop.ID {
+			continue
+		} else if item, err = gtk.MenuItemNewWithLabel(personLabel(&p, aliases)); err != nil {
+			return nil, err
 		}
 
-		if s, err = uEntry.GetText(); err != nil {
-			g.log.Printf("[ERROR] Cannot get input from URL field: %s\n",
-				err.Error())
-			return
-		} else if l.Title, err = tEntry.GetText(); err != nil {
-			g.log.Printf("[ERROR] Cannot get input from Title field: %s\n",
-				err.Error())
-			return
-		} else if l.Description, err = dEntry.GetText(); err != nil {
-			g.log.Printf("[ERROR] Cannot get input from Description field: %s\n",
-				err.Error())
-			return
-		} else if l.URL, err = url.Parse(s); err != nil {
-			var msg = fmt.Sprintf("This is not a valid URL: %q",
-				s)
-			g.log.Printf("[ERROR] %s\n", msg)
-			g.displayMsg(msg)
-			return
-		} else if err = g.db.PersonURLAdd(p, &l); err != nil {
-			var msg = fmt.Sprintf("Cannot attach Link %q to %s: %s",
-				s,
-				p.Name,
+		item.Connect("activate", g.mkPersonMergeHandler(&people[i], drop))
+		menu.Append(item)
+	}
+
+	return menu, nil
+} // func (g *GUI) mkPersonMergeMenu(drop *objects.Person) (*gtk.Menu, error)
+
+func (g *GUI) mkPersonMergeHandler(keep, drop *objects.Person) func() {
+	return func() {
+		krylib.Trace()
+		defer g.log.Printf("[TRACE] EXIT %s\n",
+			krylib.TraceInfo())
+
+		var err error
+
+		if err = g.db.PersonMerge(keep, drop); err != nil {
+			var msg = fmt.Sprintf("Cannot merge %s into %s: %s",
+				drop.Name,
+				keep.Name,
 				err.Error())
 			g.log.Printf("[ERROR] %s\n", msg)
 			g.displayMsg(msg)
 			return
 		}
 
-		g.log.Printf("[DEBUG] Guess what? We *successfully* added the URL %q (%s) to %s\n",
-			s,
-			l.Title,
-			p.Name)
+		g.log.Printf("[INFO] Merged %s (%d) into %s (%d)\n",
+			drop.Name,
+			drop.ID,
+			keep.Name,
+			keep.ID)
+
+		// Credits show up in several views, so we just reload everything.
+		g.reloadData()
 	}
-} // func (g *GUI) mkPersonAddURLHandler(p *objects.Person) func()
+} // func (g *GUI) mkPersonMergeHandler(keep, drop *objects.Person) func()
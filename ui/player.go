@@ -0,0 +1,127 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/ui/player.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 05. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-05 19:36:20 krylon>
+
+package ui
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/blicero/blockbuster/player"
+	"github.com/blicero/krylib"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+const (
+	seekStep        = time.Second * 10
+	posUpdateMillis = 1000
+)
+
+// initPlayerControls adds buttons to pause, seek and stop the player to the
+// status bar. They only do anything if the player is mpv, so we can talk to
+// it.
+func (g *GUI) initPlayerControls() error {
+	var (
+		err                             error
+		pauseBtn, backBtn, fwdBtn, stop *gtk.Button
+	)
+
+	if g.playCtl, err = gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 1); err != nil {
+		return err
+	} else if g.posLbl, err = gtk.LabelNew(""); err != nil {
+		return err
+	} else if backBtn, err = gtk.ButtonNewWithLabel("⏪"); err != nil {
+		return err
+	} else if pauseBtn, err = gtk.ButtonNewWithLabel("⏯"); err != nil {
+		return err
+	} else if fwdBtn, err = gtk.ButtonNewWithLabel("⏩"); err != nil {
+		return err
+	} else if stop, err = gtk.ButtonNewWithLabel("⏹"); err != nil {
+		return err
+	}
+
+	backBtn.SetTooltipText(fmt.Sprintf("Back %s", seekStep))
+	pauseBtn.SetTooltipText("Pause/Resume")
+	fwdBtn.SetTooltipText(fmt.Sprintf("Forward %s", seekStep))
+	stop.SetTooltipText("Stop")
+
+	backBtn.Connect("clicked", g.mkPlayerHandler(func(s *player.Session) error { return s.Seek(-seekStep) }))
+	pauseBtn.Connect("clicked", g.mkPlayerHandler(func(s *player.Session) error { return s.Pause() }))
+	fwdBtn.Connect("clicked", g.mkPlayerHandler(func(s *player.Session) error { return s.Seek(seekStep) }))
+	stop.Connect("clicked", g.mkPlayerHandler(func(s *player.Session) error { return s.Stop() }))
+
+	g.playCtl.PackStart(g.posLbl, false, false, 1)
+	g.playCtl.PackStart(backBtn, false, false, 1)
+	g.playCtl.PackStart(pauseBtn, false, false, 1)
+	g.playCtl.PackStart(fwdBtn, false, false, 1)
+	g.playCtl.PackStart(stop, false, false, 1)
+	g.playCtl.SetSensitive(false)
+
+	g.statusbar.PackEnd(g.playCtl, false, false, 1)
+
+	return nil
+} // func (g *GUI) initPlayerControls() error
+
+func (g *GUI) mkPlayerHandler(op func(s *player.Session) error) func() {
+	return func() {
+		krylib.Trace()
+		defer g.log.Printf("[TRACE] EXIT %s\n",
+			krylib.TraceInfo())
+
+		var err error
+
+		if g.session == nil {
+			return
+		} else if err = op(g.session); err != nil {
+			var msg = fmt.Sprintf("Cannot control player: %s",
+				err.Error())
+			g.log.Printf("[ERROR] %s\n", msg)
+			g.statusbar.Push(statusPlayer, msg)
+		}
+	}
+} // func (g *GUI) mkPlayerHandler(op func(s *player.Session) error) func()
+
+// attachPlayer connects the player controls to a Session. If a previous
+// Session is still running, it can no longer be controlled from the GUI.
+func (g *GUI) attachPlayer(s *player.Session) {
+	g.session = s
+
+	if !player.IsMPV(g.playCmd) {
+		return
+	}
+
+	g.playCtl.SetSensitive(true)
+	glib.TimeoutAdd(posUpdateMillis, g.mkPlayerPosUpdater(s)) // nolint: errcheck
+} // func (g *GUI) attachPlayer(s *player.Session)
+
+// mkPlayerPosUpdater returns a function that displays the playback position
+// of the Session in the status bar until the player exits.
+func (g *GUI) mkPlayerPosUpdater(s *player.Session) func() bool {
+	return func() bool {
+		if g.session != s {
+			return false
+		}
+
+		select {
+		case <-s.Done():
+			g.session = nil
+			g.posLbl.SetText("")
+			g.playCtl.SetSensitive(false)
+			return false
+		default:
+		}
+
+		if s.Tracked() {
+			var pos, duration = s.Position()
+			g.posLbl.SetText(fmt.Sprintf("%s / %s",
+				fmtDuration(pos),
+				fmtDuration(duration)))
+		}
+
+		return true
+	}
+} // func (g *GUI) mkPlayerPosUpdater(s *player.Session) func() bool
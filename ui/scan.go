@@ -0,0 +1,129 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/ui/scan.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 17. 09. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-09-17 19:31:08 krylon>
+
+package ui
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/blicero/blockbuster/tree"
+	"github.com/blicero/krylib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// initScanControls adds a progress bar and a button to cancel running scans
+// to the status bar. They are only visible while the Scanner is busy.
+func (g *GUI) initScanControls() error {
+	var (
+		err       error
+		cancelBtn *gtk.Button
+	)
+
+	if g.scanCtl, err = gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 1); err != nil {
+		return err
+	} else if g.scanBar, err = gtk.ProgressBarNew(); err != nil {
+		return err
+	} else if cancelBtn, err = gtk.ButtonNewWithLabel("Cancel"); err != nil {
+		return err
+	}
+
+	g.scanProgress = make(map[string]tree.Progress)
+
+	g.scanBar.SetShowText(true)
+	g.scanBar.SetSizeRequest(300, -1)
+	cancelBtn.SetTooltipText("Stop all running scans")
+	cancelBtn.Connect("clicked", func() {
+		krylib.Trace()
+		g.scanner.Cancel()
+	})
+
+	g.scanCtl.PackStart(g.scanBar, false, false, 1)
+	g.scanCtl.PackStart(cancelBtn, false, false, 1)
+	g.scanBar.Show()
+	cancelBtn.Show()
+
+	// We show and hide the controls ourselves, ShowAll on the window must
+	// not make them visible.
+	g.scanCtl.SetNoShowAll(true)
+
+	g.statusbar.PackEnd(g.scanCtl, false, false, 1)
+
+	return nil
+} // func (g *GUI) initScanControls() error
+
+// mkScanProgressHandler returns a function that updates the progress bar
+// with a Progress report from the Scanner.
+func (g *GUI) mkScanProgressHandler(p tree.Progress) func() bool {
+	return func() bool {
+		if p.Done {
+			delete(g.scanProgress, p.Stats.Folder)
+		} else {
+			g.scanProgress[p.Stats.Folder] = p
+		}
+
+		g.updateScanControls()
+		return false
+	}
+} // func (g *GUI) mkScanProgressHandler(p tree.Progress) func() bool
+
+// clearScanProgress hides the progress bar once the Scanner is idle. The
+// Scanner drops Progress reports if we do not keep up, so we might miss the
+// final one.
+func (g *GUI) clearScanProgress() bool {
+	if len(g.scanProgress) > 0 {
+		g.scanProgress = make(map[string]tree.Progress)
+		g.updateScanControls()
+	}
+
+	return false
+} // func (g *GUI) clearScanProgress() bool
+
+// updateScanControls displays the combined progress of all running scans.
+// If we do not know for any of them how much is left, we can only show
+// that something is happening.
+func (g *GUI) updateScanControls() {
+	var (
+		dirs, files, expected int
+		msg                   string
+		unknown               bool
+	)
+
+	if len(g.scanProgress) == 0 {
+		g.scanCtl.Hide()
+		return
+	}
+
+	for _, p := range g.scanProgress {
+		dirs += p.Stats.Dirs
+		files += p.Stats.Files
+		expected += p.Expected
+		unknown = unknown || p.Fraction() < 0
+
+		msg = fmt.Sprintf("Scanning %s: %d directories, %d files",
+			filepath.Base(p.Stats.Folder),
+			p.Stats.Dirs,
+			p.Stats.Files)
+	}
+
+	if len(g.scanProgress) > 1 {
+		msg = fmt.Sprintf("Scanning %d Folders: %d directories, %d files",
+			len(g.scanProgress),
+			dirs,
+			files)
+	}
+
+	if unknown {
+		g.scanBar.Pulse()
+	} else if frac := float64(dirs) / float64(expected); frac < 0.99 {
+		g.scanBar.SetFraction(frac)
+	} else {
+		g.scanBar.SetFraction(0.99)
+	}
+
+	g.scanBar.SetText(msg)
+	g.scanCtl.Show()
+} // func (g *GUI) updateScanControls()
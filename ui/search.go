@@ -0,0 +1,312 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/ui/search.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 30. 08. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-08-30 22:41:18 krylon>
+
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blicero/blockbuster/filter"
+	"github.com/blicero/blockbuster/objects"
+	"github.com/blicero/krylib"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// Each tab has its own search bar. (See the org file on why that is.)
+// The views display their stores through a TreeModelFilter, so we can hide
+// the rows that do not match.
+//
+// For the tabs listed in tabSearchKinds, we ask the full-text search in the
+// database. Hits on a Link count as hits on the File or Person it is
+// attached to. All other tabs just look for the search string in their
+// title column.
+//
+// Queries that use the query language (see package filter) are evaluated by
+// the database, too, they work on the tabs listed in tabFileDepth.
+var tabSearchKinds = map[tabIdx][]objects.SearchKind{
+	tiFile:     {objects.SearchFile, objects.SearchFileURL},
+	tiActor:    {objects.SearchPerson, objects.SearchPersonURL},
+	tiDirector: {objects.SearchPerson, objects.SearchPersonURL},
+	tiPerson:   {objects.SearchPerson, objects.SearchPersonURL},
+	tiTags:     {objects.SearchTag},
+}
+
+// tabFileDepth maps the tabs that list Files to the depth of the rows that
+// represent Files. Rows above that depth are visible if any of their
+// children match a query, rows below are visible if their parent is.
+var tabFileDepth = map[tabIdx]int{
+	tiFile:        1,
+	tiContinue:    1,
+	tiActor:       2,
+	tiDirector:    2,
+	tiPerson:      2,
+	tiCollections: 2,
+}
+
+func (g *GUI) mkSearchHandler(idx tabIdx) func() {
+	krylib.Trace()
+	return func() {
+		krylib.Trace()
+		defer g.log.Printf("[TRACE] EXIT %s\n",
+			krylib.TraceInfo())
+		var (
+			err   error
+			text  string
+			q     *filter.Query
+			hits  []objects.SearchHit
+			files []objects.File
+			kinds []objects.SearchKind
+			ok    bool
+			tab   = &g.tabs[idx]
+		)
+
+		if text, err = tab.search.GetText(); err != nil {
+			g.log.Printf("[ERROR] Cannot get text from search entry: %s\n",
+				err.Error())
+			return
+		}
+
+		// While the query cannot be parsed, we keep displaying whatever
+		// the last valid one matched.
+		if q, err = filter.Parse(text); err != nil {
+			g.searchError(idx, err.Error())
+			return
+		} else if _, ok = tabFileDepth[idx]; !q.Plain && !ok {
+			g.searchError(idx, "This tab does not list Files, it only understands plain words")
+			return
+		}
+
+		g.searchError(idx, "")
+		text = strings.TrimSpace(text)
+		tab.hits = nil
+		tab.files = nil
+		tab.pattern = ""
+
+		if text == "" {
+			g.statusbar.Pop(statusSearch)
+			tab.filter.Refilter()
+			return
+		} else if !q.Plain {
+			if files, err = g.db.FileGetByCondition(&q.Cond); err != nil {
+				var msg = fmt.Sprintf("Query %q failed: %s",
+					text,
+					err.Error())
+				g.log.Printf("[ERROR] %s\n", msg)
+				g.statusbar.Push(statusSearch, msg)
+				return
+			}
+
+			tab.files = make(map[int64]bool, len(files))
+			for _, f := range files {
+				tab.files[f.ID] = true
+			}
+
+			g.statusbar.Push(statusSearch,
+				fmt.Sprintf("%d Files match %q", len(files), text))
+			tab.filter.Refilter()
+			return
+		} else if kinds, ok = tabSearchKinds[idx]; !ok {
+			tab.pattern = strings.ToLower(text)
+			tab.filter.Refilter()
+			return
+		} else if hits, err = g.db.Search(text, 0); err != nil {
+			var msg = fmt.Sprintf("Search for %q failed: %s",
+				text,
+				err.Error())
+			g.log.Printf("[ERROR] %s\n", msg)
+			g.statusbar.Push(statusSearch, msg)
+			return
+		}
+
+		tab.hits = make(map[int64]bool, len(hits))
+
+		for _, h := range hits {
+			for _, k := range kinds {
+				if h.Kind == k {
+					tab.hits[h.OwnerID] = true
+					break
+				}
+			}
+		}
+
+		g.statusbar.Push(statusSearch,
+			fmt.Sprintf("%d matches for %q", len(tab.hits), text))
+		tab.filter.Refilter()
+	}
+} // func (g *GUI) mkSearchHandler(idx tabIdx) func()
+
+// searchError displays an error message in the search bar of the given tab,
+// or removes it if msg is empty.
+func (g *GUI) searchError(idx tabIdx, msg string) {
+	var (
+		entry = g.tabs[idx].search
+		pos   = gtk.EntryIconPosition(gtk.ENTRY_ICON_SECONDARY)
+	)
+
+	if msg == "" {
+		entry.RemoveIcon(pos)
+		return
+	}
+
+	entry.SetIconFromIconName(pos, "dialog-error")
+	entry.SetIconTooltipText(pos, msg)
+	g.statusbar.Push(statusSearch, msg)
+} // func (g *GUI) searchError(idx tabIdx, msg string)
+
+// rowID returns the ID in column 0 of the given row.
+func (g *GUI) rowID(model *gtk.TreeModel, iter *gtk.TreeIter) (int64, bool) {
+	var (
+		err  error
+		val  *glib.Value
+		gval interface{}
+	)
+
+	if val, err = model.GetValue(iter, 0); err != nil {
+		g.log.Printf("[ERROR] Cannot get value for column 0: %s\n",
+			err.Error())
+		return 0, false
+	} else if gval, err = val.GoValue(); err != nil {
+		g.log.Printf("[ERROR] Cannot get Go value for ID: %s\n",
+			err.Error())
+		return 0, false
+	}
+
+	var id, ok = gval.(int)
+	return int64(id), ok
+} // func (g *GUI) rowID(model *gtk.TreeModel, iter *gtk.TreeIter) (int64, bool)
+
+// fileRowVisible decides if a row is visible given the Files that match the
+// query in the search bar of the tab.
+func (g *GUI) fileRowVisible(idx tabIdx, model *gtk.TreeModel, iter *gtk.TreeIter, depth int) bool {
+	var (
+		tab       = &g.tabs[idx]
+		fileDepth = tabFileDepth[idx]
+	)
+
+	if depth > fileDepth {
+		return true
+	} else if depth == fileDepth {
+		var id, ok = g.rowID(model, iter)
+		return ok && tab.files[id]
+	}
+
+	var child gtk.TreeIter
+
+	if !model.IterChildren(iter, &child) {
+		return false
+	}
+
+	for {
+		if g.fileRowVisible(idx, model, &child, depth+1) {
+			return true
+		} else if !model.IterNext(&child) {
+			return false
+		}
+	}
+} // func (g *GUI) fileRowVisible(idx tabIdx, model *gtk.TreeModel, iter *gtk.TreeIter, depth int) bool
+
+// rowMatches tells if a row matches the plain search of its tab, either
+// because the full-text search found it or because its title contains the
+// search string. In tabs that list Files below other objects, the column 0
+// of a File row holds the ID of the File, so it cannot match the hits for
+// the objects above it.
+func (g *GUI) rowMatches(idx tabIdx, model *gtk.TreeModel, iter *gtk.TreeIter, depth int) bool {
+	var (
+		err error
+		s   string
+		val *glib.Value
+		tab = &g.tabs[idx]
+	)
+
+	if fileDepth, ok := tabFileDepth[idx]; ok && fileDepth > 1 && depth >= fileDepth {
+		return false
+	} else if tab.hits != nil {
+		var id, ok = g.rowID(model, iter)
+		return ok && tab.hits[id]
+	} else if val, err = model.GetValue(iter, 1); err != nil {
+		g.log.Printf("[ERROR] Cannot get value for column 1: %s\n",
+			err.Error())
+		return false
+	} else if s, err = val.GetString(); err != nil {
+		// Rows that have no title, like the Files in the Tag view,
+		// do not match anything.
+		return false
+	}
+
+	return strings.Contains(strings.ToLower(s), tab.pattern)
+} // func (g *GUI) rowMatches(idx tabIdx, model *gtk.TreeModel, iter *gtk.TreeIter, depth int) bool
+
+// childRowMatches tells if any descendant of a row matches the plain search
+// of its tab.
+func (g *GUI) childRowMatches(idx tabIdx, model *gtk.TreeModel, iter *gtk.TreeIter, depth int) bool {
+	var child gtk.TreeIter
+
+	if !model.IterChildren(iter, &child) {
+		return false
+	}
+
+	for {
+		if g.rowMatches(idx, model, &child, depth+1) ||
+			g.childRowMatches(idx, model, &child, depth+1) {
+			return true
+		} else if !model.IterNext(&child) {
+			return false
+		}
+	}
+} // func (g *GUI) childRowMatches(idx tabIdx, model *gtk.TreeModel, iter *gtk.TreeIter, depth int) bool
+
+// textRowVisible decides if a row is visible given the plain search of its
+// tab. A row is visible if it matches, if any of its ancestors matches - so
+// we see the Files of a Person we searched for - or if any of its
+// descendants matches - so a nested Tag is not hidden by its parent.
+func (g *GUI) textRowVisible(idx tabIdx, model *gtk.TreeModel, iter *gtk.TreeIter, depth int) bool {
+	if g.rowMatches(idx, model, iter, depth) {
+		return true
+	}
+
+	var row = *iter
+
+	for d := depth - 1; d > 0; d-- {
+		var parent gtk.TreeIter
+
+		if !model.IterParent(&parent, &row) {
+			break
+		} else if g.rowMatches(idx, model, &parent, d) {
+			return true
+		}
+
+		row = parent
+	}
+
+	return g.childRowMatches(idx, model, iter, depth)
+} // func (g *GUI) textRowVisible(idx tabIdx, model *gtk.TreeModel, iter *gtk.TreeIter, depth int) bool
+
+// mkFilterFunc returns the visibility function for the TreeModelFilter of the
+// given tab.
+func (g *GUI) mkFilterFunc(idx tabIdx) gtk.TreeModelFilterVisibleFunc {
+	krylib.Trace()
+	return func(model *gtk.TreeModel, iter *gtk.TreeIter) bool {
+		var (
+			err  error
+			path *gtk.TreePath
+			tab  = &g.tabs[idx]
+		)
+
+		if tab.hits == nil && tab.files == nil && tab.pattern == "" {
+			return true
+		} else if path, err = model.GetPath(iter); err != nil {
+			g.log.Printf("[ERROR] Cannot get TreePath from TreeIter: %s\n",
+				err.Error())
+			return true
+		} else if tab.files != nil {
+			return g.fileRowVisible(idx, model, iter, path.GetDepth())
+		}
+
+		return g.textRowVisible(idx, model, iter, path.GetDepth())
+	}
+} // func (g *GUI) mkFilterFunc(idx tabIdx) gtk.TreeModelFilterVisibleFunc
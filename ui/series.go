@@ -0,0 +1,675 @@
+// /home/krylon/go/src/github.com/blicero/blockbuster/ui/series.go
+// -*- mode: go; coding: utf-8; -*-
+// Created on 29. 08. 2021 by Benjamin Walkenhorst
+// (c) 2021 Benjamin Walkenhorst
+// Time-stamp: <2021-08-29 21:17:03 krylon>
+
+package ui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/blicero/blockbuster/objects"
+	"github.com/blicero/krylib"
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// The Series view is a tree with three levels: Series, Seasons, and Episodes.
+// When the user clicks on a row, we use the depth of the path to tell what
+// kind of object the row represents.
+const (
+	seriesDepthSeries = iota + 1
+	seriesDepthSeason
+	seriesDepthEpisode
+)
+
+func (g *GUI) loadSeries() bool {
+	krylib.Trace()
+	defer g.log.Printf("[TRACE] EXIT %s\n",
+		krylib.TraceInfo())
+	var (
+		err    error
+		msg    string
+		series []objects.Series
+		store  *gtk.TreeStore
+	)
+
+	store = g.tabs[tiSeries].store.(*gtk.TreeStore)
+	store.Clear()
+
+	if series, err = g.db.SeriesGetAll(); err != nil {
+		msg = fmt.Sprintf("Cannot load all Series from Database: %s",
+			err.Error())
+		goto ERROR
+	}
+
+	for sidx := range series {
+		var (
+			seasons []objects.Season
+			siter   *gtk.TreeIter
+			s       = &series[sidx]
+		)
+
+		if seasons, err = g.db.SeasonGetBySeries(s); err != nil {
+			msg = fmt.Sprintf("Cannot load Seasons of %s: %s",
+				s.Title,
+				err.Error())
+			goto ERROR
+		}
+
+		siter = store.Append(nil)
+		store.SetValue(siter, 0, s.ID)    // nolint: errcheck
+		store.SetValue(siter, 1, s.Title) // nolint: errcheck
+		if s.Year != 0 {
+			store.SetValue(siter, 2, strconv.FormatInt(s.Year, 10)) // nolint: errcheck
+		}
+
+		for nidx := range seasons {
+			var (
+				episodes []objects.Episode
+				niter    *gtk.TreeIter
+				season   = &seasons[nidx]
+			)
+
+			if episodes, err = g.db.EpisodeGetBySeason(season); err != nil {
+				msg = fmt.Sprintf("Cannot load Episodes of %s, %s: %s",
+					s.Title,
+					season.DisplayTitle(),
+					err.Error())
+				goto ERROR
+			}
+
+			niter = store.Append(siter)
+			store.SetValue(niter, 0, season.ID)             // nolint: errcheck
+			store.SetValue(niter, 1, season.DisplayTitle()) // nolint: errcheck
+			if season.Year != 0 {
+				store.SetValue(niter, 2, strconv.FormatInt(season.Year, 10)) // nolint: errcheck
+			}
+
+			for eidx := range episodes {
+				var (
+					files  []objects.File
+					titles []string
+					eiter  *gtk.TreeIter
+					e      = &episodes[eidx]
+				)
+
+				if files, err = g.db.FileGetByEpisode(e); err != nil {
+					msg = fmt.Sprintf("Cannot load Files of Episode %s: %s",
+						e.DisplayTitle(),
+						err.Error())
+					goto ERROR
+				}
+
+				titles = make([]string, len(files))
+				for fidx := range files {
+					titles[fidx] = files[fidx].DisplayTitle()
+				}
+
+				eiter = store.Append(niter)
+				store.SetValue(eiter, 0, e.ID)                       // nolint: errcheck
+				store.SetValue(eiter, 1, e.DisplayTitle())           // nolint: errcheck
+				store.SetValue(eiter, 3, strings.Join(titles, ", ")) // nolint: errcheck
+			}
+		}
+	}
+
+	return false
+
+ERROR:
+	g.log.Printf("[ERROR] %s\n", msg)
+	g.displayMsg(msg)
+	return false
+} // func (g *GUI) loadSeries() bool
+
+func (g *GUI) handleSeriesListClick(view *gtk.TreeView, evt *gdk.Event) {
+	krylib.Trace()
+	defer g.log.Printf("[TRACE] EXIT %s\n",
+		krylib.TraceInfo())
+	var be = gdk.EventButtonNewFromEvent(evt)
+
+	if be.Button() != gdk.BUTTON_SECONDARY {
+		return
+	}
+
+	var (
+		err    error
+		msg    string
+		exists bool
+		x, y   float64
+		path   *gtk.TreePath
+		model  *gtk.TreeModel
+		imodel gtk.ITreeModel
+		iter   *gtk.TreeIter
+		menu   *gtk.Menu
+		val    *glib.Value
+		gval   interface{}
+		id     int64
+	)
+
+	x = be.X()
+	y = be.Y()
+
+	path, _, _, _, exists = view.GetPathAtPos(int(x), int(y))
+
+	if !exists {
+		g.log.Printf("[DEBUG] There is no item at %f/%f\n",
+			x,
+			y)
+		return
+	}
+
+	if imodel, err = view.GetModel(); err != nil {
+		g.log.Printf("[ERROR] Cannot get Model from View: %s\n",
+			err.Error())
+		return
+	}
+
+	model = imodel.ToTreeModel()
+
+	if iter, err = model.GetIter(path); err != nil {
+		g.log.Printf("[ERROR] Cannot get Iter from TreePath %s: %s\n",
+			path,
+			err.Error())
+		return
+	} else if val, err = model.GetValue(iter, 0); err != nil {
+		msg = fmt.Sprintf("Cannot get ID from column 0: %s",
+			err.Error())
+		goto ERROR
+	} else if gval, err = val.GoValue(); err != nil {
+		msg = fmt.Sprintf("Cannot get go value for ID: %s",
+			err.Error())
+		goto ERROR
+	}
+
+	id = int64(gval.(int))
+
+	switch path.GetDepth() {
+	case seriesDepthSeries:
+		var s *objects.Series
+
+		if s, err = g.db.SeriesGetByID(id); err != nil {
+			msg = fmt.Sprintf("Cannot lookup Series #%d: %s",
+				id,
+				err.Error())
+			goto ERROR
+		} else if s == nil {
+			msg = fmt.Sprintf("Series #%d was not found in database", id)
+			goto ERROR
+		} else if menu, err = g.mkSeriesContextMenu(s); err != nil {
+			msg = fmt.Sprintf("Cannot create context menu for %s: %s",
+				s.Title,
+				err.Error())
+			goto ERROR
+		}
+	case seriesDepthSeason:
+		var s *objects.Season
+
+		if s, err = g.db.SeasonGetByID(id); err != nil {
+			msg = fmt.Sprintf("Cannot lookup Season #%d: %s",
+				id,
+				err.Error())
+			goto ERROR
+		} else if s == nil {
+			msg = fmt.Sprintf("Season #%d was not found in database", id)
+			goto ERROR
+		} else if menu, err = g.mkSeasonContextMenu(s); err != nil {
+			msg = fmt.Sprintf("Cannot create context menu for %s: %s",
+				s.DisplayTitle(),
+				err.Error())
+			goto ERROR
+		}
+	case seriesDepthEpisode:
+		var e *objects.Episode
+
+		if e, err = g.db.EpisodeGetByID(id); err != nil {
+			msg = fmt.Sprintf("Cannot lookup Episode #%d: %s",
+				id,
+				err.Error())
+			goto ERROR
+		} else if e == nil {
+			msg = fmt.Sprintf("Episode #%d was not found in database", id)
+			goto ERROR
+		} else if menu, err = g.mkEpisodeContextMenu(e); err != nil {
+			msg = fmt.Sprintf("Cannot create context menu for %s: %s",
+				e.DisplayTitle(),
+				err.Error())
+			goto ERROR
+		}
+	default:
+		g.log.Printf("[CANTHAPPEN] Unexpected depth of TreePath %s: %d\n",
+			path,
+			path.GetDepth())
+		return
+	}
+
+	menu.ShowAll()
+	menu.PopupAtPointer(evt)
+
+	return
+
+ERROR:
+	g.log.Printf("[ERROR] %s\n", msg)
+	g.displayMsg(msg)
+} // func (g *GUI) handleSeriesListClick(view *gtk.TreeView, evt *gdk.Event)
+
+func (g *GUI) mkSeriesContextMenu(s *objects.Series) (*gtk.Menu, error) {
+	krylib.Trace()
+	var (
+		err              error
+		menu             *gtk.Menu
+		itemAdd, itemDel *gtk.MenuItem
+	)
+
+	if menu, err = gtk.MenuNew(); err != nil {
+		return nil, err
+	} else if itemAdd, err = gtk.MenuItemNewWithMnemonic("Add _Season"); err != nil {
+		return nil, err
+	} else if itemDel, err = gtk.MenuItemNewWithMnemonic("_Delete"); err != nil {
+		return nil, err
+	}
+
+	itemAdd.Connect("activate", g.mkSeasonAddHandler(s))
+	itemDel.Connect("activate", g.mkSeriesDeleteHandler(
+		fmt.Sprintf("Delete %s with all its Seasons and Episodes?", s.Title),
+		func() error { return g.db.SeriesDelete(s) }))
+
+	menu.Append(itemAdd)
+	menu.Append(itemDel)
+
+	return menu, nil
+} // func (g *GUI) mkSeriesContextMenu(s *objects.Series) (*gtk.Menu, error)
+
+func (g *GUI) mkSeasonContextMenu(s *objects.Season) (*gtk.Menu, error) {
+	krylib.Trace()
+	var (
+		err                        error
+		menu                       *gtk.Menu
+		itemAdd, itemYear, itemDel *gtk.MenuItem
+	)
+
+	if menu, err = gtk.MenuNew(); err != nil {
+		return nil, err
+	} else if itemAdd, err = gtk.MenuItemNewWithMnemonic("Add _Episode"); err != nil {
+		return nil, err
+	} else if itemYear, err = gtk.MenuItemNewWithMnemonic("Set _Year…"); err != nil {
+		return nil, err
+	} else if itemDel, err = gtk.MenuItemNewWithMnemonic("_Delete"); err != nil {
+		return nil, err
+	}
+
+	itemAdd.Connect("activate", g.mkEpisodeAddHandler(s))
+	itemYear.Connect("activate", g.mkSeasonYearHandler(s))
+	itemDel.Connect("activate", g.mkSeriesDeleteHandler(
+		fmt.Sprintf("Delete %s with all its Episodes?", s.DisplayTitle()),
+		func() error { return g.db.SeasonDelete(s) }))
+
+	menu.Append(itemAdd)
+	menu.Append(itemYear)
+	menu.Append(itemDel)
+
+	return menu, nil
+} // func (g *GUI) mkSeasonContextMenu(s *objects.Season) (*gtk.Menu, error)
+
+func (g *GUI) mkEpisodeContextMenu(e *objects.Episode) (*gtk.Menu, error) {
+	krylib.Trace()
+	var (
+		err     error
+		menu    *gtk.Menu
+		itemDel *gtk.MenuItem
+		files   []objects.File
+	)
+
+	if files, err = g.db.FileGetByEpisode(e); err != nil {
+		return nil, err
+	} else if menu, err = gtk.MenuNew(); err != nil {
+		return nil, err
+	} else if itemDel, err = gtk.MenuItemNewWithMnemonic("_Delete"); err != nil {
+		return nil, err
+	}
+
+	// There usually is only one File per Episode, but there is no reason
+	// there could not be more, e.g. in different resolutions.
+	for fidx := range files {
+		var (
+			item *gtk.MenuItem
+			f    = &files[fidx]
+		)
+
+		if item, err = gtk.MenuItemNewWithLabel("Play " + f.DisplayTitle()); err != nil {
+			return nil, err
+		}
+
+		item.Connect("activate", func() { g.playFile(f) })
+		menu.Append(item)
+	}
+
+	itemDel.Connect("activate", g.mkSeriesDeleteHandler(
+		fmt.Sprintf("Delete Episode %s?", e.DisplayTitle()),
+		func() error { return g.db.EpisodeDelete(e) }))
+
+	menu.Append(itemDel)
+
+	return menu, nil
+} // func (g *GUI) mkEpisodeContextMenu(e *objects.Episode) (*gtk.Menu, error)
+
+// mkSeriesDeleteHandler returns a handler that asks the user to confirm
+// the question, and if they do, calls del and reloads the Series view.
+func (g *GUI) mkSeriesDeleteHandler(question string, del func() error) func() {
+	krylib.Trace()
+	return func() {
+		krylib.Trace()
+		defer g.log.Printf("[TRACE] EXIT %s\n",
+			krylib.TraceInfo())
+
+		if !g.confirm(question) {
+			return
+		} else if err := del(); err != nil {
+			var msg = fmt.Sprintf("Failed to delete: %s",
+				err.Error())
+			g.log.Printf("[ERROR] %s\n", msg)
+			g.displayMsg(msg)
+			return
+		}
+
+		g.loadSeries()
+	}
+} // func (g *GUI) mkSeriesDeleteHandler(question string, del func() error) func()
+
+func (g *GUI) handleSeriesAdd() {
+	krylib.Trace()
+	defer g.log.Printf("[TRACE] EXIT %s\n",
+		krylib.TraceInfo())
+	var (
+		err   error
+		ok    bool
+		year  int64
+		title string
+		s     *objects.Series
+	)
+
+	if year, title, ok = g.numberDialog("Add Series", "Year:", 0); !ok {
+		return
+	} else if title == "" {
+		g.displayMsg("A Series needs a title")
+		return
+	} else if s, err = g.db.SeriesAdd(title, year); err != nil {
+		var msg = fmt.Sprintf("Cannot add Series %q to database: %s",
+			title,
+			err.Error())
+		g.log.Printf("[ERROR] %s\n", msg)
+		g.displayMsg(msg)
+		return
+	}
+
+	g.log.Printf("[DEBUG] Series %s (%d) was added to Database\n",
+		s.Title,
+		s.ID)
+	g.loadSeries()
+} // func (g *GUI) handleSeriesAdd()
+
+func (g *GUI) mkSeasonAddHandler(s *objects.Series) func() {
+	krylib.Trace()
+	return func() {
+		krylib.Trace()
+		defer g.log.Printf("[TRACE] EXIT %s\n",
+			krylib.TraceInfo())
+		var (
+			err     error
+			msg     string
+			ok      bool
+			num     int64
+			title   string
+			seasons []objects.Season
+		)
+
+		if seasons, err = g.db.SeasonGetBySeries(s); err != nil {
+			msg = fmt.Sprintf("Cannot load Seasons of %s: %s",
+				s.Title,
+				err.Error())
+			goto ERROR
+		} else if num, title, ok = g.numberDialog(
+			"Add Season to "+s.Title,
+			"Season:",
+			int64(len(seasons)+1)); !ok {
+			return
+		} else if _, err = g.db.SeasonAdd(s, num, title, 0); err != nil {
+			msg = fmt.Sprintf("Cannot add Season %d to %s: %s",
+				num,
+				s.Title,
+				err.Error())
+			goto ERROR
+		}
+
+		g.loadSeries()
+		return
+
+	ERROR:
+		g.log.Printf("[ERROR] %s\n", msg)
+		g.displayMsg(msg)
+	}
+} // func (g *GUI) mkSeasonAddHandler(s *objects.Series) func()
+
+// mkSeasonYearHandler returns a handler that asks the user for the year a
+// Season aired. An empty year means we do not know.
+func (g *GUI) mkSeasonYearHandler(s *objects.Season) func() {
+	krylib.Trace()
+	return func() {
+		krylib.Trace()
+		defer g.log.Printf("[TRACE] EXIT %s\n",
+			krylib.TraceInfo())
+		var (
+			err  error
+			msg  string
+			ok   bool
+			year int64
+			text string
+		)
+
+		if s.Year != 0 {
+			text = strconv.FormatInt(s.Year, 10)
+		}
+
+		if text, ok = g.textDialog("Year of "+s.DisplayTitle(), "Year:", text); !ok {
+			return
+		} else if text = strings.TrimSpace(text); text == "" {
+			year = 0
+		} else if year, err = strconv.ParseInt(text, 10, 64); err != nil || year < 0 {
+			msg = fmt.Sprintf("Invalid year: %q", text)
+			goto ERROR
+		}
+
+		if err = g.db.SeasonSetYear(s, year); err != nil {
+			msg = fmt.Sprintf("Cannot set year of %s: %s",
+				s.DisplayTitle(),
+				err.Error())
+			goto ERROR
+		}
+
+		g.loadSeries()
+		return
+
+	ERROR:
+		g.log.Printf("[ERROR] %s\n", msg)
+		g.displayMsg(msg)
+	}
+} // func (g *GUI) mkSeasonYearHandler(s *objects.Season) func()
+
+func (g *GUI) mkEpisodeAddHandler(s *objects.Season) func() {
+	krylib.Trace()
+	return func() {
+		krylib.Trace()
+		defer g.log.Printf("[TRACE] EXIT %s\n",
+			krylib.TraceInfo())
+		var (
+			err      error
+			msg      string
+			ok       bool
+			num      int64
+			title    string
+			episodes []objects.Episode
+		)
+
+		if episodes, err = g.db.EpisodeGetBySeason(s); err != nil {
+			msg = fmt.Sprintf("Cannot load Episodes of %s: %s",
+				s.DisplayTitle(),
+				err.Error())
+			goto ERROR
+		} else if num, title, ok = g.numberDialog(
+			"Add Episode to "+s.DisplayTitle(),
+			"Episode:",
+			int64(len(episodes)+1)); !ok {
+			return
+		} else if _, err = g.db.EpisodeAdd(s, num, title); err != nil {
+			msg = fmt.Sprintf("Cannot add Episode %d to %s: %s",
+				num,
+				s.DisplayTitle(),
+				err.Error())
+			goto ERROR
+		}
+
+		g.loadSeries()
+		return
+
+	ERROR:
+		g.log.Printf("[ERROR] %s\n", msg)
+		g.displayMsg(msg)
+	}
+} // func (g *GUI) mkEpisodeAddHandler(s *objects.Season) func()
+
+// mkFileEpisodeMenu creates a submenu for the File context menu that lists
+// all Series, their Seasons and Episodes, so the user can link the File to
+// an Episode.
+func (g *GUI) mkFileEpisodeMenu(f *objects.File) (*gtk.Menu, error) {
+	krylib.Trace()
+	defer g.log.Printf("[TRACE] EXIT %s\n",
+		krylib.TraceInfo())
+	var (
+		err    error
+		menu   *gtk.Menu
+		series []objects.Series
+	)
+
+	if series, err = g.db.SeriesGetAll(); err != nil {
+		g.log.Printf("[ERROR] Cannot load all Series: %s\n",
+			err.Error())
+		return nil, err
+	} else if menu, err = gtk.MenuNew(); err != nil {
+		g.log.Printf("[ERROR] Cannot create Episode menu: %s\n",
+			err.Error())
+		return nil, err
+	}
+
+	for sidx := range series {
+		var (
+			sItem   *gtk.MenuItem
+			sMenu   *gtk.Menu
+			seasons []objects.Season
+			s       = &series[sidx]
+		)
+
+		if seasons, err = g.db.SeasonGetBySeries(s); err != nil {
+			g.log.Printf("[ERROR] Cannot load Seasons of %s: %s\n",
+				s.Title,
+				err.Error())
+			return nil, err
+		} else if sItem, err = gtk.MenuItemNewWithLabel(s.Title); err != nil {
+			g.log.Printf("[ERROR] Cannot create menu item for %s: %s\n",
+				s.Title,
+				err.Error())
+			return nil, err
+		} else if sMenu, err = gtk.MenuNew(); err != nil {
+			g.log.Printf("[ERROR] Cannot create submenu for %s: %s\n",
+				s.Title,
+				err.Error())
+			return nil, err
+		}
+
+		for nidx := range seasons {
+			var (
+				nItem    *gtk.MenuItem
+				nMenu    *gtk.Menu
+				episodes []objects.Episode
+				season   = &seasons[nidx]
+			)
+
+			if episodes, err = g.db.EpisodeGetBySeason(season); err != nil {
+				g.log.Printf("[ERROR] Cannot load Episodes of %s: %s\n",
+					season.DisplayTitle(),
+					err.Error())
+				return nil, err
+			} else if nItem, err = gtk.MenuItemNewWithLabel(season.DisplayTitle()); err != nil {
+				g.log.Printf("[ERROR] Cannot create menu item for %s: %s\n",
+					season.DisplayTitle(),
+					err.Error())
+				return nil, err
+			} else if nMenu, err = gtk.MenuNew(); err != nil {
+				g.log.Printf("[ERROR] Cannot create submenu for %s: %s\n",
+					season.DisplayTitle(),
+					err.Error())
+				return nil, err
+			}
+
+			for eidx := range episodes {
+				var (
+					eItem *gtk.CheckMenuItem
+					e     = &episodes[eidx]
+				)
+
+				if eItem, err = gtk.CheckMenuItemNewWithLabel(e.DisplayTitle()); err != nil {
+					g.log.Printf("[ERROR] Cannot create menu item for %s: %s\n",
+						e.DisplayTitle(),
+						err.Error())
+					return nil, err
+				}
+
+				eItem.SetActive(f.EpisodeID == e.ID)
+				eItem.Connect("activate", g.mkFileEpisodeToggleHandler(f, e))
+				nMenu.Append(eItem)
+			}
+
+			nItem.SetSubmenu(nMenu)
+			sMenu.Append(nItem)
+		}
+
+		sItem.SetSubmenu(sMenu)
+		menu.Append(sItem)
+	}
+
+	return menu, nil
+} // func (g *GUI) mkFileEpisodeMenu(f *objects.File) (*gtk.Menu, error)
+
+func (g *GUI) mkFileEpisodeToggleHandler(f *objects.File, e *objects.Episode) func() {
+	krylib.Trace()
+	return func() {
+		krylib.Trace()
+		defer g.log.Printf("[TRACE] EXIT %s\n",
+			krylib.TraceInfo())
+		var (
+			err    error
+			target = e
+		)
+
+		// If the File is already linked to the Episode, the user wants to
+		// unlink it.
+		if f.EpisodeID == e.ID {
+			target = nil
+		}
+
+		if err = g.db.FileSetEpisode(f, target); err != nil {
+			var msg = fmt.Sprintf("Cannot link %s to Episode %s: %s",
+				f.DisplayTitle(),
+				e.DisplayTitle(),
+				err.Error())
+			g.log.Printf("[ERROR] %s\n", msg)
+			g.displayMsg(msg)
+			return
+		}
+
+		glib.IdleAdd(g.loadSeries)
+	}
+} // func (g *GUI) mkFileEpisodeToggleHandler(f *objects.File, e *objects.Episode) func()
@@ -2,17 +2,20 @@
 // -*- mode: go; coding: utf-8; -*-
 // Created on 10. 08. 2021 by Benjamin Walkenhorst
 // (c) 2021 Benjamin Walkenhorst
-// Time-stamp: <2021-08-21 23:11:45 krylon>
+// Time-stamp: <2021-09-08 22:41:30 krylon>
 
 package ui
 
 import (
 	"fmt"
 	"sort"
+	"strconv"
 
 	"github.com/blicero/blockbuster/common"
 	"github.com/blicero/blockbuster/objects"
 	"github.com/blicero/krylib"
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/glib"
 	"github.com/gotk3/gotk3/gtk"
 )
 
@@ -54,10 +57,12 @@ func (g *GUI) loadTagView() bool {
 	defer g.log.Printf("[TRACE] EXIT %s\n",
 		krylib.TraceInfo())
 	var (
-		err   error
-		msg   string
-		tags  []objects.Tag
-		store *gtk.TreeStore
+		err      error
+		msg      string
+		tags     []objects.Tag
+		known    map[int64]bool
+		children map[int64][]*objects.Tag
+		store    *gtk.TreeStore
 	)
 
 	if tags, err = g.db.TagGetAll(); err != nil {
@@ -69,32 +74,28 @@ func (g *GUI) loadTagView() bool {
 	store = g.tabs[tiTags].store.(*gtk.TreeStore)
 	store.Clear() // ???
 
-	for tidx := range tags {
-		var (
-			files []objects.File
-			titer *gtk.TreeIter
-			t     = &tags[tidx]
-		)
+	sort.Sort(objects.TagList(tags))
+	known = make(map[int64]bool, len(tags))
+	children = make(map[int64][]*objects.Tag, len(tags))
 
-		if files, err = g.db.TagLinkGetByTag(t); err != nil {
-			msg = fmt.Sprintf("Failed to load Files linked to Tag %s: %s",
-				t.Name,
-				err.Error())
-			goto ERROR
-		}
+	for tidx := range tags {
+		known[tags[tidx].ID] = true
+	}
 
-		titer = store.Append(nil)
-		store.SetValue(titer, 0, t.ID)   // nolint: errcheck
-		store.SetValue(titer, 1, t.Name) // nolint: errcheck
+	for tidx := range tags {
+		var t = &tags[tidx]
 
-		for fidx := range files {
-			var (
-				f     = &files[fidx]
-				fiter = store.Append(titer)
-			)
+		if known[t.Parent] {
+			children[t.Parent] = append(children[t.Parent], t)
+		} else {
+			children[0] = append(children[0], t)
+		}
+	}
 
-			store.SetValue(fiter, 2, f.DisplayTitle()) // nolint: errcheck
-			store.SetValue(fiter, 3, int(f.Year))      // nolint: errcheck
+	for _, t := range children[0] {
+		if err = g.tagViewAdd(store, nil, t, children); err != nil {
+			msg = err.Error()
+			goto ERROR
 		}
 	}
 
@@ -107,3 +108,438 @@ ERROR:
 	g.displayMsg(msg)
 	return false
 } // func (g *GUI) loadTagView() bool
+
+// tagViewAdd adds a Tag to the Tag view below parent, followed by its
+// children and the Files tagged with it or any of its descendants.
+func (g *GUI) tagViewAdd(store *gtk.TreeStore, parent *gtk.TreeIter, t *objects.Tag, children map[int64][]*objects.Tag) error {
+	var (
+		err   error
+		files []objects.File
+		titer *gtk.TreeIter
+	)
+
+	if files, err = g.db.TagLinkGetByTag(t); err != nil {
+		return fmt.Errorf("Failed to load Files linked to Tag %s: %s",
+			t.Name,
+			err.Error())
+	}
+
+	titer = store.Append(parent)
+	store.SetValue(titer, 0, t.ID)   // nolint: errcheck
+	store.SetValue(titer, 1, t.Name) // nolint: errcheck
+
+	for _, c := range children[t.ID] {
+		if err = g.tagViewAdd(store, titer, c, children); err != nil {
+			return err
+		}
+	}
+
+	for fidx := range files {
+		var (
+			f     = &files[fidx]
+			fiter = store.Append(titer)
+		)
+
+		store.SetValue(fiter, 2, f.DisplayTitle()) // nolint: errcheck
+		store.SetValue(fiter, 3, int(f.Year))      // nolint: errcheck
+	}
+
+	return nil
+} // func (g *GUI) tagViewAdd(store *gtk.TreeStore, parent *gtk.TreeIter, t *objects.Tag, children map[int64][]*objects.Tag) error
+
+// tagDragTarget identifies Tags dragged around in the Tag view. The data
+// carried by the drag is the ID of the Tag.
+const tagDragTarget = "blockbuster/tag"
+
+// initTagDragAndDrop allows the user to drag a Tag onto another one to make
+// it a child of the latter. Dropping a Tag between two top-level Tags moves
+// it to the top level.
+func (g *GUI) initTagDragAndDrop() error {
+	var (
+		err    error
+		target *gtk.TargetEntry
+		view   = g.tabs[tiTags].view
+	)
+
+	if target, err = gtk.TargetEntryNew(tagDragTarget, gtk.TARGET_SAME_WIDGET, 0); err != nil {
+		return err
+	}
+
+	// The view's model is a TreeModelFilter, which cannot be a drop target
+	// by itself, so we let Gtk handle the drop and then do the work
+	// ourselves.
+	view.EnableModelDragSource(gdk.ModifierType(gdk.BUTTON1_MASK), []gtk.TargetEntry{*target}, gdk.ACTION_MOVE)
+	view.DragDestSet(gtk.DEST_DEFAULT_ALL, []gtk.TargetEntry{*target}, gdk.ACTION_MOVE)
+
+	view.Connect("drag-data-get", g.handleTagDragGet)
+	view.Connect("drag-data-received", g.handleTagDragReceived)
+	view.Connect("drag-drop", func(v *gtk.TreeView) bool {
+		v.StopEmission("drag-drop")
+		return false
+	})
+	// We rebuild the view after moving a Tag, so the model must not
+	// remove the row on its own.
+	view.Connect("drag-data-delete", func(v *gtk.TreeView) {
+		v.StopEmission("drag-data-delete")
+	})
+
+	return nil
+} // func (g *GUI) initTagDragAndDrop() error
+
+// tagIDAtPath returns the ID of the Tag at the given path in the Tag view.
+// If the path points to a File, it returns the ID of the Tag the File is
+// listed under.
+func (g *GUI) tagIDAtPath(model *gtk.TreeModel, path *gtk.TreePath) (int64, error) {
+	var (
+		err  error
+		iter *gtk.TreeIter
+		val  *glib.Value
+		gval interface{}
+		name string
+	)
+
+	for path.GetDepth() > 0 {
+		if iter, err = model.GetIter(path); err != nil {
+			return 0, err
+		} else if val, err = model.GetValue(iter, 1); err != nil {
+			return 0, err
+		} else if name, err = val.GetString(); err != nil {
+			return 0, err
+		} else if name == "" {
+			// Files do not have a name in the Tag view, so we look at
+			// the parent.
+			path.Up()
+			continue
+		} else if val, err = model.GetValue(iter, 0); err != nil {
+			return 0, err
+		} else if gval, err = val.GoValue(); err != nil {
+			return 0, err
+		}
+
+		return int64(gval.(int)), nil
+	}
+
+	return 0, nil
+} // func (g *GUI) tagIDAtPath(model *gtk.TreeModel, path *gtk.TreePath) (int64, error)
+
+func (g *GUI) handleTagDragGet(view *gtk.TreeView, ctx *gdk.DragContext, data *gtk.SelectionData, info, time uint) {
+	krylib.Trace()
+	defer g.log.Printf("[TRACE] EXIT %s\n",
+		krylib.TraceInfo())
+	var (
+		err    error
+		ok     bool
+		id     int64
+		sel    *gtk.TreeSelection
+		imodel gtk.ITreeModel
+		iter   *gtk.TreeIter
+		path   *gtk.TreePath
+		model  *gtk.TreeModel
+	)
+
+	if sel, err = view.GetSelection(); err != nil {
+		g.log.Printf("[ERROR] Cannot get selection of Tag view: %s\n",
+			err.Error())
+		return
+	} else if imodel, iter, ok = sel.GetSelected(); !ok {
+		g.log.Println("[DEBUG] No Tag is selected.")
+		return
+	}
+
+	model = imodel.ToTreeModel()
+
+	if path, err = model.GetPath(iter); err != nil {
+		g.log.Printf("[ERROR] Cannot get path of selected row: %s\n",
+			err.Error())
+		return
+	} else if path.GetDepth() > 0 && !g.isTagRow(model, iter) {
+		// Only Tags can be dragged around.
+		return
+	} else if id, err = g.tagIDAtPath(model, path); err != nil {
+		g.log.Printf("[ERROR] Cannot get ID of selected Tag: %s\n",
+			err.Error())
+		return
+	}
+
+	data.SetData(gdk.GdkAtomIntern(tagDragTarget, false), []byte(strconv.FormatInt(id, 10)))
+} // func (g *GUI) handleTagDragGet(view *gtk.TreeView, ctx *gdk.DragContext, data *gtk.SelectionData, info, time uint)
+
+func (g *GUI) isTagRow(model *gtk.TreeModel, iter *gtk.TreeIter) bool {
+	var (
+		err  error
+		val  *glib.Value
+		name string
+	)
+
+	if val, err = model.GetValue(iter, 1); err != nil {
+		return false
+	} else if name, err = val.GetString(); err != nil {
+		return false
+	}
+
+	return name != ""
+} // func (g *GUI) isTagRow(model *gtk.TreeModel, iter *gtk.TreeIter) bool
+
+func (g *GUI) handleTagDragReceived(view *gtk.TreeView, ctx *gdk.DragContext, x, y int, data *gtk.SelectionData, info, time uint) {
+	krylib.Trace()
+	defer g.log.Printf("[TRACE] EXIT %s\n",
+		krylib.TraceInfo())
+
+	view.StopEmission("drag-data-received")
+
+	var (
+		err        error
+		msg        string
+		ok         bool
+		id, destID int64
+		t, parent  *objects.Tag
+		path       *gtk.TreePath
+		pos        gtk.TreeViewDropPosition
+		imodel     gtk.ITreeModel
+		raw        = data.GetData()
+	)
+
+	if len(raw) == 0 {
+		return
+	} else if id, err = strconv.ParseInt(string(raw), 10, 64); err != nil {
+		msg = fmt.Sprintf("Cannot parse ID of dragged Tag %q: %s",
+			raw,
+			err.Error())
+		goto ERROR
+	} else if imodel, err = view.GetModel(); err != nil {
+		msg = fmt.Sprintf("Cannot get Model of Tag view: %s",
+			err.Error())
+		goto ERROR
+	}
+
+	if path, pos, ok = view.GetDestRowAtPos(x, y); ok {
+		// Dropping a Tag between two others makes it their sibling.
+		if pos == gtk.TREE_VIEW_DROP_BEFORE || pos == gtk.TREE_VIEW_DROP_AFTER {
+			path.Up()
+		}
+
+		if destID, err = g.tagIDAtPath(imodel.ToTreeModel(), path); err != nil {
+			msg = fmt.Sprintf("Cannot get Tag at drop location: %s",
+				err.Error())
+			goto ERROR
+		}
+	}
+
+	if destID == id {
+		return
+	} else if t, err = g.db.TagGetByID(id); err != nil {
+		msg = fmt.Sprintf("Cannot look up Tag #%d: %s",
+			id,
+			err.Error())
+		goto ERROR
+	} else if t == nil {
+		msg = fmt.Sprintf("Tag #%d does not exist", id)
+		goto ERROR
+	} else if t.Parent == destID {
+		return
+	} else if destID != 0 {
+		if parent, err = g.db.TagGetByID(destID); err != nil {
+			msg = fmt.Sprintf("Cannot look up Tag #%d: %s",
+				destID,
+				err.Error())
+			goto ERROR
+		} else if parent == nil {
+			msg = fmt.Sprintf("Tag #%d does not exist", destID)
+			goto ERROR
+		}
+	}
+
+	if err = g.db.TagSetParent(t, parent); err != nil {
+		msg = err.Error()
+		goto ERROR
+	}
+
+	glib.IdleAdd(g.loadTagView)
+	return
+
+ERROR:
+	g.log.Printf("[ERROR] %s\n", msg)
+	g.displayMsg(msg)
+} // func (g *GUI) handleTagDragReceived(view *gtk.TreeView, ctx *gdk.DragContext, x, y int, data *gtk.SelectionData, info, time uint)
+
+func (g *GUI) handleTagListClick(view *gtk.TreeView, evt *gdk.Event) {
+	krylib.Trace()
+	var be = gdk.EventButtonNewFromEvent(evt)
+
+	if be.Button() != gdk.BUTTON_SECONDARY {
+		return
+	}
+
+	var (
+		err    error
+		msg    string
+		exists bool
+		id     int64
+		path   *gtk.TreePath
+		imodel gtk.ITreeModel
+		t      *objects.Tag
+		menu   *gtk.Menu
+	)
+
+	if path, _, _, _, exists = view.GetPathAtPos(int(be.X()), int(be.Y())); !exists {
+		return
+	} else if imodel, err = view.GetModel(); err != nil {
+		g.log.Printf("[ERROR] Cannot get Model from View: %s\n",
+			err.Error())
+		return
+	} else if id, err = g.tagIDAtPath(imodel.ToTreeModel(), path); err != nil {
+		msg = fmt.Sprintf("Cannot get ID of Tag: %s",
+			err.Error())
+		goto ERROR
+	} else if id == 0 {
+		return
+	} else if t, err = g.db.TagGetByID(id); err != nil {
+		msg = fmt.Sprintf("Cannot lookup Tag #%d: %s",
+			id,
+			err.Error())
+		goto ERROR
+	} else if t == nil {
+		msg = fmt.Sprintf("Tag #%d does not exist", id)
+		goto ERROR
+	} else if menu, err = g.mkTagContextMenu(t); err != nil {
+		msg = fmt.Sprintf("Cannot create context Menu for Tag %s: %s",
+			t.Name,
+			err.Error())
+		goto ERROR
+	}
+
+	menu.ShowAll()
+	menu.PopupAtPointer(evt)
+	return
+
+ERROR:
+	g.log.Printf("[ERROR] %s\n", msg)
+	g.displayMsg(msg)
+} // func (g *GUI) handleTagListClick(view *gtk.TreeView, evt *gdk.Event)
+
+func (g *GUI) mkTagContextMenu(t *objects.Tag) (*gtk.Menu, error) {
+	krylib.Trace()
+	var (
+		err                            error
+		menu, mergeMenu                *gtk.Menu
+		itemRename, itemMerge, itemTop *gtk.MenuItem
+	)
+
+	if menu, err = gtk.MenuNew(); err != nil {
+		return nil, err
+	} else if mergeMenu, err = gtk.MenuNew(); err != nil {
+		return nil, err
+	} else if itemRename, err = gtk.MenuItemNewWithMnemonic("_Rename"); err != nil {
+		return nil, err
+	} else if itemMerge, err = gtk.MenuItemNewWithMnemonic("_Merge into"); err != nil {
+		return nil, err
+	} else if itemTop, err = gtk.MenuItemNewWithMnemonic("Move to _top level"); err != nil {
+		return nil, err
+	}
+
+	for idx := range g.tags {
+		var (
+			item  *gtk.MenuItem
+			other = &g.tags[idx]
+		)
+
+		if other.ID == t.ID {
+			continue
+		} else if item, err = gtk.MenuItemNewWithLabel(other.Name); err != nil {
+			return nil, err
+		}
+
+		item.Connect("activate", g.mkTagMergeHandler(other, t))
+		mergeMenu.Append(item)
+	}
+
+	itemMerge.SetSubmenu(mergeMenu)
+	itemTop.SetSensitive(t.Parent != 0)
+
+	itemRename.Connect("activate", g.mkTagRenameHandler(t))
+	itemTop.Connect("activate", func() {
+		if err := g.db.TagSetParent(t, nil); err != nil {
+			var msg = fmt.Sprintf("Cannot move Tag %s to the top level: %s",
+				t.Name,
+				err.Error())
+			g.log.Printf("[ERROR] %s\n", msg)
+			g.displayMsg(msg)
+			return
+		}
+		g.loadTagView()
+	})
+
+	menu.Append(itemRename)
+	menu.Append(itemMerge)
+	menu.Append(itemTop)
+
+	return menu, nil
+} // func (g *GUI) mkTagContextMenu(t *objects.Tag) (*gtk.Menu, error)
+
+func (g *GUI) mkTagRenameHandler(t *objects.Tag) func() {
+	return func() {
+		krylib.Trace()
+		defer g.log.Printf("[TRACE] EXIT %s\n",
+			krylib.TraceInfo())
+		var (
+			err  error
+			ok   bool
+			name string
+		)
+
+		if name, ok = g.textDialog("Rename Tag", "Name:", t.Name); !ok || name == "" || name == t.Name {
+			return
+		} else if err = g.db.TagRename(t, name); err != nil {
+			var msg = fmt.Sprintf("Cannot rename Tag %s to %q: %s",
+				t.Name,
+				name,
+				err.Error())
+			g.log.Printf("[ERROR] %s\n", msg)
+			g.displayMsg(msg)
+			return
+		}
+
+		g.reloadTags()
+	}
+} // func (g *GUI) mkTagRenameHandler(t *objects.Tag) func()
+
+func (g *GUI) mkTagMergeHandler(keep, drop *objects.Tag) func() {
+	return func() {
+		krylib.Trace()
+		defer g.log.Printf("[TRACE] EXIT %s\n",
+			krylib.TraceInfo())
+		var err error
+
+		if !g.confirm(fmt.Sprintf("Merge Tag %s into %s?", drop.Name, keep.Name)) {
+			return
+		} else if err = g.db.TagMerge(keep, drop); err != nil {
+			var msg = fmt.Sprintf("Cannot merge Tag %s into %s: %s",
+				drop.Name,
+				keep.Name,
+				err.Error())
+			g.log.Printf("[ERROR] %s\n", msg)
+			g.displayMsg(msg)
+			return
+		}
+
+		g.reloadTags()
+	}
+} // func (g *GUI) mkTagMergeHandler(keep, drop *objects.Tag) func()
+
+// reloadTags refreshes our list of Tags after Tags have been renamed or
+// merged. Since the Tags of a File are displayed in the File view, too, we
+// reload everything.
+func (g *GUI) reloadTags() {
+	var err error
+
+	if g.tags, err = g.db.TagGetAll(); err != nil {
+		var msg = fmt.Sprintf("Cannot fetch all Tags from Database: %s",
+			err.Error())
+		g.log.Printf("[ERROR] %s\n", msg)
+		g.displayMsg(msg)
+		return
+	}
+
+	sort.Sort(g.tags)
+	g.reloadData()
+} // func (g *GUI) reloadTags()
@@ -8,11 +8,12 @@
 package ui
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -22,6 +23,7 @@ import (
 	"github.com/blicero/blockbuster/database"
 	"github.com/blicero/blockbuster/logdomain"
 	"github.com/blicero/blockbuster/objects"
+	"github.com/blicero/blockbuster/player"
 	"github.com/blicero/blockbuster/tree"
 	"github.com/blicero/krylib"
 	"github.com/gotk3/gotk3/glib"
@@ -34,23 +36,31 @@ const (
 	statusSearch        // nolint: deadcode,unused,varcheck
 	statusScan          // nolint: deadcode,unused,varcheck
 	statusInternet      // nolint: deadcode,unused,varcheck
+	statusBackup
+	statusIMDb
 )
 
 const (
 	qDepth        = 128
-	refInterval   = time.Second * 10 // nolint: deadcode,unused,varcheck
+	refInterval   = time.Second * 10
 	defaultPlayer = "/usr/bin/mpv"
 	playerEnv     = "VIDEOPLAYER"
+	// How many Folders on the same disk we scan at the same time.
+	scanWalkersEnv = "SCAN_WALKERS_PER_DEVICE"
 )
 
 type tabContent struct {
-	vbox   *gtk.Box
-	sbox   *gtk.Box
-	lbl    *gtk.Label
-	search *gtk.Entry
-	store  gtk.ITreeModel
-	view   *gtk.TreeView
-	scr    *gtk.ScrolledWindow
+	vbox    *gtk.Box
+	sbox    *gtk.Box
+	lbl     *gtk.Label
+	search  *gtk.Entry
+	store   gtk.ITreeModel
+	filter  *gtk.TreeModelFilter
+	hits    map[int64]bool
+	files   map[int64]bool
+	pattern string
+	view    *gtk.TreeView
+	scr     *gtk.ScrolledWindow
 }
 
 // GUI is the ... well, GUI of the application.
@@ -73,7 +83,17 @@ type GUI struct {
 	statusbar *gtk.Statusbar
 	tabs      []tabContent
 	tags      objects.TagList
+	colls     []objects.Collection
 	playCmd   []string
+	session   *player.Session
+	playCtl   *gtk.Box
+	posLbl    *gtk.Label
+	imdbBusy  bool
+	scanCtl   *gtk.Box
+	scanBar   *gtk.ProgressBar
+	// scanProgress holds the most recent Progress report of each
+	// running scan. It is only touched from the Gtk main loop.
+	scanProgress map[string]tree.Progress
 }
 
 // Create creates a new GUI. You didn't see *that* coming, now, did you?
@@ -116,6 +136,30 @@ func Create() (*GUI, error) {
 		return nil, err
 	}
 
+	// A bad value is no reason not to start, the Scanner keeps its default
+	// in that case.
+	if walkers := os.Getenv(scanWalkersEnv); walkers != "" {
+		var n int
+		if n, err = strconv.Atoi(walkers); err != nil {
+			g.log.Printf("[WARN] Cannot parse %s=%q, using the default: %s\n",
+				scanWalkersEnv,
+				walkers,
+				err.Error())
+		} else if n < 1 {
+			g.log.Printf("[WARN] %s must be at least 1, not %d, using the default\n",
+				scanWalkersEnv,
+				n)
+		} else {
+			g.scanner.SetWalkersPerDevice(n)
+		}
+	}
+
+	if err = registerProviders(); err != nil {
+		g.log.Printf("[ERROR] Cannot register metadata providers: %s\n",
+			err.Error())
+		return nil, err
+	}
+
 	sort.Sort(g.tags)
 
 	gtk.Init(nil)
@@ -146,6 +190,14 @@ func Create() (*GUI, error) {
 		g.log.Printf("[ERROR] Failed to create menu: %s\n",
 			err.Error())
 		return nil, err
+	} else if err = g.initPlayerControls(); err != nil {
+		g.log.Printf("[ERROR] Failed to create player controls: %s\n",
+			err.Error())
+		return nil, err
+	} else if err = g.initScanControls(); err != nil {
+		g.log.Printf("[ERROR] Failed to create scan controls: %s\n",
+			err.Error())
+		return nil, err
 	}
 
 	g.tabs = make([]tabContent, len(viewList))
@@ -197,8 +249,17 @@ func Create() (*GUI, error) {
 				v.title,
 				err.Error())
 			return nil, err
+		} else if tab.filter, err = tab.store.ToTreeModel().FilterNew(nil); err != nil {
+			g.log.Printf("[ERROR] Cannot create TreeModelFilter for %s: %s\n",
+				v.title,
+				err.Error())
+			return nil, err
 		}
 
+		tab.filter.SetVisibleFunc(g.mkFilterFunc(tabIdx(tIdx)))
+		tab.view.SetModel(tab.filter)
+		tab.search.Connect("changed", g.mkSearchHandler(tabIdx(tIdx)))
+
 		tab.sbox.PackStart(tab.lbl, false, false, 1)
 		tab.sbox.PackStart(tab.search, true, true, 1)
 		tab.vbox.PackStart(tab.sbox, false, false, 1)
@@ -222,7 +283,18 @@ func Create() (*GUI, error) {
 
 	g.tabs[tiFile].view.Connect("button-press-event", g.handleFileListClick)
 	g.tabs[tiPerson].view.Connect("button-press-event", g.handlePersonListClick)
-	// g.tabs[tiFolder].view.Connect("button-press-event", g.handleFileListClick)
+	g.tabs[tiSeries].view.Connect("button-press-event", g.handleSeriesListClick)
+	g.tabs[tiTags].view.Connect("button-press-event", g.handleTagListClick)
+	g.tabs[tiContinue].view.Connect("row-activated", g.handleContinueActivate)
+	g.tabs[tiCollections].view.Connect("button-press-event", g.handleCollectionListClick)
+	g.tabs[tiFolder].view.Connect("button-press-event", g.handleFolderListClick)
+	g.notebook.Connect("switch-page", g.handleSwitchPage)
+
+	if err = g.initTagDragAndDrop(); err != nil {
+		g.log.Printf("[ERROR] Cannot enable drag and drop for Tags: %s\n",
+			err.Error())
+		return nil, err
+	}
 
 	g.win.Connect("destroy", gtk.MainQuit)
 
@@ -249,7 +321,15 @@ func (g *GUI) ShowAndRun() {
 		return
 	}
 
-	// go g.scanLoop()
+	go g.scanLoop()
+
+	if err := g.scanner.Watch(); err != nil {
+		g.log.Printf("[ERROR] Cannot watch Folders for changes: %s\n",
+			err.Error())
+	}
+
+	g.takeSnapshots()
+	glib.TimeoutAdd(snapshotCheckMillis, g.takeSnapshots) // nolint: errcheck
 
 	g.win.ShowAll()
 	if common.Debug {
@@ -264,7 +344,8 @@ func (g *GUI) ShowAndRun() {
 	gtk.Main()
 } // func (g *GUI) ShowAndRun()
 
-// nolint: deadcode,unused
+// scanLoop passes the Files the Scanner finds or updates on to the GUI,
+// and shows how the scans are coming along, and how they went.
 func (g *GUI) scanLoop() {
 	krylib.Trace()
 	defer g.log.Printf("[TRACE] EXIT %s\n",
@@ -276,8 +357,19 @@ func (g *GUI) scanLoop() {
 	for {
 		select {
 		case <-ticker.C:
-			g.log.Println("[TRACE] scanLoop says Hello.")
-			continue
+			for _, st := range g.scanner.Stats() {
+				var msg = st.String()
+				glib.IdleAdd(func() bool {
+					g.statusbar.Push(statusScan, msg)
+					return false
+				})
+			}
+
+			if !g.scanner.Active() {
+				glib.IdleAdd(g.clearScanProgress)
+			}
+		case p := <-g.scanner.Progress():
+			glib.IdleAdd(g.mkScanProgressHandler(p))
 		case f := <-g.fileQ:
 			g.log.Printf("[DEBUG] Received new File %d: %s\n",
 				f.ID,
@@ -347,6 +439,9 @@ func (g *GUI) loadData() error {
 
 	g.loadTagView()
 	g.loadPeople()
+	g.loadSeries()
+	g.loadContinue()
+	g.loadCollections()
 
 	return nil
 } // func (g *GUI) loadData() error
@@ -405,10 +500,22 @@ func (g *GUI) makeNewFileHandler(f *objects.File) func() bool {
 		var (
 			err                 error
 			astr, tstr, sizeStr string
+			durStr, resStr      string
 			size                int64
-			iter                = store.Append()
+			info                *objects.MediaInfo
+			iter                *gtk.TreeIter
 		)
 
+		// If the File is shown already, it has been renamed or has gone
+		// missing, so we replace its row.
+		if f.ID != 0 {
+			if iter = g.findFileRow(f.ID); iter != nil {
+				store.Remove(iter)
+			}
+		}
+
+		iter = store.Append()
+
 		if f.ID != 0 {
 			var (
 				actors []objects.Person
@@ -441,17 +548,33 @@ func (g *GUI) makeNewFileHandler(f *objects.File) func() bool {
 				}
 				astr = strings.Join(slist, ", ")
 			}
+
+			if info, err = g.db.MediaInfoGetByFile(f); err != nil {
+				g.log.Printf("[ERROR] Cannot get MediaInfo for File %s: %s\n",
+					f.DisplayTitle(),
+					err.Error())
+			} else if info != nil {
+				durStr = fmtDuration(info.Duration)
+				resStr = info.Resolution()
+			}
 		}
 
 		if size = f.Size(); size != 0 {
 			sizeStr = krylib.FmtBytes(size)
 		}
 
-		if err = store.Set(
-			iter,
-			[]int{0, 1, 2, 3, 5, 6, 7},
-			[]interface{}{f.ID, f.DisplayTitle(), sizeStr, f.Year, astr, tstr, f.Path},
-		); err != nil {
+		var (
+			cols = []int{0, 1, 2, 3, 4, 5, 6, 8, 9, 10}
+			vals = []interface{}{f.ID, f.DisplayTitle(), sizeStr, durStr, resStr, f.Year, watchedMark(f), astr, tstr, f.Path}
+		)
+
+		// Files that have gone missing are greyed out.
+		if f.IsMissing() {
+			cols = append(cols, 11)
+			vals = append(vals, colorMissing)
+		}
+
+		if err = store.Set(iter, cols, vals); err != nil {
 			g.log.Printf("[ERROR] Cannot add File %d (%s) to Store: %s\n",
 				f.ID,
 				f.Path,
@@ -560,6 +683,7 @@ func (g *GUI) makeNewActorHandler(p *objects.Person, f *objects.File) func() boo
 		// iter now points to the node of the Person
 		pos = store.IterNChildren(iter)
 		fiter = store.Insert(iter, pos+1)
+		store.SetValue(fiter, 0, f.ID)             // nolint: errcheck
 		store.SetValue(fiter, 3, f.DisplayTitle()) // nolint: errcheck
 
 		return false
@@ -632,6 +756,7 @@ func (g *GUI) makeNewDirectorHandler(p *objects.Person, f *objects.File) func()
 		// iter now points to the node of the Person
 		pos = store.IterNChildren(iter)
 		fiter = store.Insert(iter, pos+1)
+		store.SetValue(fiter, 0, f.ID)             // nolint: errcheck
 		store.SetValue(fiter, 3, f.DisplayTitle()) // nolint: errcheck
 
 		return false
@@ -686,7 +811,7 @@ func (g *GUI) promptScanFolder() {
 		g.log.Printf("[DEBUG] Telling Scanner to visit %s\n",
 			path)
 
-		g.scanner.ScanPath(path)
+		g.scanner.ScanPath(context.Background(), path)
 	}
 
 } // func (g *GUI) promptScanFolder()
@@ -998,35 +1123,78 @@ func (g *GUI) playFile(f *objects.File) {
 		krylib.TraceInfo())
 
 	var (
-		err  error
-		cmd  *exec.Cmd
-		args = make([]string, len(g.playCmd))
+		err    error
+		exists bool
+		resume time.Duration
+		sess   *player.Session
+		plog   *objects.PlayLog
 	)
 
-	for i, a := range g.playCmd[1:] {
-		args[i] = a
+	if exists, err = krylib.Fexists(f.Path); err != nil || !exists {
+		var msg = fmt.Sprintf("Cannot play %s: %s does not exist.",
+			f.DisplayTitle(),
+			f.Path)
+		if f.IsMissing() {
+			msg += fmt.Sprintf(" It has been missing since %s.",
+				f.MissingSince.Format(common.TimestampFormat))
+		}
+		g.log.Printf("[ERROR] %s\n", msg)
+		g.displayMsg(msg)
+		return
 	}
-	args[len(args)-1] = f.Path
 
-	cmd = exec.Command(g.playCmd[0], args...)
+	if !f.Watched {
+		resume = f.ResumePosition
+	}
 
-	if err = cmd.Start(); err != nil {
+	if sess, err = player.Start(g.playCmd, f.Path, resume); err != nil {
 		var msg = fmt.Sprintf("Failed to start player for %s: %s",
 			f.DisplayTitle(),
 			err.Error())
 		g.log.Printf("[ERROR] %s\n", msg)
 		g.displayMsg(msg)
+		return
+	}
+
+	// If we cannot record the playback, that is annoying, but no reason to
+	// not watch the movie.
+	if plog, err = g.db.PlayLogAdd(f, time.Now()); err != nil {
+		g.log.Printf("[ERROR] Cannot record playback of %s: %s\n",
+			f.DisplayTitle(),
+			err.Error())
 	}
 
 	var msg = fmt.Sprintf("Playing %s", f.DisplayTitle())
 	g.statusbar.Push(statusPlayer, msg)
+	g.attachPlayer(sess)
 
 	go func() {
 		krylib.Trace()
 		defer g.log.Printf("[TRACE] EXIT %s\n",
 			krylib.TraceInfo())
-		var e error
-		if e = cmd.Wait(); e != nil {
+		var (
+			e             error
+			status        int
+			tracked       bool
+			pos, duration time.Duration
+		)
+
+		status, e = sess.Wait()
+
+		var end = time.Now()
+
+		// If we could not talk to the player, we do not know where it
+		// stopped. How long it was running tells us nothing, it may
+		// have been paused the whole time.
+		if tracked = sess.Tracked(); tracked {
+			pos, duration = sess.Position()
+		}
+
+		if plog != nil {
+			glib.IdleAdd(g.mkPlaybackFinishedHandler(f, plog, end, status, tracked, pos, duration))
+		}
+
+		if e != nil {
 			var m = fmt.Sprintf("Error playing %q: %s",
 				f.DisplayTitle(),
 				e.Error())
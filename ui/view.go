@@ -29,6 +29,9 @@ const (
 	tiTags
 	tiPerson
 	tiFolder
+	tiSeries
+	tiContinue
+	tiCollections
 )
 
 type storeType uint8
@@ -42,14 +45,19 @@ type column struct {
 	colType glib.Type
 	title   string
 	edit    bool
+	hidden  bool
 }
 
 type cellEditHandlerFactory func(int) func(*gtk.CellRendererText, string, string)
 
+// If fgCol is non-zero, it is the index of a column that holds the
+// foreground color for each row. (Column 0 is always the ID, so 0 is safe to
+// mean "none".)
 type view struct {
 	title   string
 	store   storeType
 	columns []column
+	fgCol   int
 }
 
 func (v *view) typeList() []glib.Type {
@@ -108,6 +116,12 @@ func (v *view) create(handlerFactory cellEditHandlerFactory) (gtk.ITreeModel, *g
 			renderer.Connect("edited", handlerFactory(idx))
 		}
 
+		if v.fgCol != 0 {
+			col.AddAttribute(renderer, "foreground", v.fgCol)
+		}
+
+		col.SetVisible(!cSpec.hidden)
+
 		tv.AppendColumn(col)
 	}
 
@@ -132,11 +146,23 @@ var viewList = []view{
 				colType: glib.TYPE_STRING,
 				title:   "Size",
 			},
+			column{
+				colType: glib.TYPE_STRING,
+				title:   "Duration",
+			},
+			column{
+				colType: glib.TYPE_STRING,
+				title:   "Resolution",
+			},
 			column{
 				colType: glib.TYPE_INT,
 				title:   "Year",
 				edit:    true,
 			},
+			column{
+				colType: glib.TYPE_STRING,
+				title:   "Watched",
+			},
 			column{
 				colType: glib.TYPE_STRING,
 				title:   "Director",
@@ -153,7 +179,13 @@ var viewList = []view{
 				colType: glib.TYPE_STRING,
 				title:   "Path",
 			},
+			column{
+				colType: glib.TYPE_STRING,
+				title:   "Color",
+				hidden:  true,
+			},
 		},
+		fgCol: 11,
 	},
 	view{
 		title: "Actor",
@@ -261,4 +293,74 @@ var viewList = []view{
 			},
 		},
 	},
+	view{
+		title: "Series",
+		store: storeTree,
+		columns: []column{
+			column{
+				colType: glib.TYPE_INT,
+				title:   "ID",
+			},
+			column{
+				colType: glib.TYPE_STRING,
+				title:   "Title",
+			},
+			column{
+				colType: glib.TYPE_STRING,
+				title:   "Year",
+			},
+			column{
+				colType: glib.TYPE_STRING,
+				title:   "Files",
+			},
+		},
+	},
+	view{
+		title: "Continue watching",
+		store: storeList,
+		columns: []column{
+			column{
+				colType: glib.TYPE_INT,
+				title:   "ID",
+			},
+			column{
+				colType: glib.TYPE_STRING,
+				title:   "Title",
+			},
+			column{
+				colType: glib.TYPE_STRING,
+				title:   "Position",
+			},
+			column{
+				colType: glib.TYPE_STRING,
+				title:   "Duration",
+			},
+			column{
+				colType: glib.TYPE_STRING,
+				title:   "Last played",
+			},
+		},
+	},
+	view{
+		title: "Collections",
+		store: storeTree,
+		columns: []column{
+			column{
+				colType: glib.TYPE_INT,
+				title:   "ID",
+			},
+			column{
+				colType: glib.TYPE_STRING,
+				title:   "Title",
+			},
+			column{
+				colType: glib.TYPE_STRING,
+				title:   "Year",
+			},
+			column{
+				colType: glib.TYPE_STRING,
+				title:   "Files",
+			},
+		},
+	},
 }